@@ -0,0 +1,110 @@
+// Package aitest provides a stand-in AI backend for tests that need to
+// script the replies, see the prompts sent or hold requests in flight.
+// Tests that only need some reply can use the "mock" provider instead.
+package aitest
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"ai-rpg-mvp/ai"
+)
+
+// Stub is an Ollama stand-in that answers with each reply in turn,
+// repeating the last one once they run out. Streamed replies are sent a
+// word at a time.
+type Stub struct {
+	replies []string
+
+	mu      sync.Mutex
+	prompts []string      // the last message of each request, in order
+	held    chan struct{} // when set, requests wait for it to close
+	arrived chan struct{} // signalled as each held request comes in
+}
+
+// NewService creates an AI service backed by a Stub answering with replies.
+// The stub is shut down when the test ends.
+func NewService(t testing.TB, replies ...string) (*ai.AIService, *Stub) {
+	t.Helper()
+
+	stub := &Stub{replies: replies}
+	endpoint := httptest.NewServer(http.HandlerFunc(stub.serve))
+	t.Cleanup(endpoint.Close)
+
+	service, err := ai.NewAIService(ai.AIConfig{Provider: "ollama", BaseURL: endpoint.URL})
+	if err != nil {
+		t.Fatalf("Failed to create AI service: %v", err)
+	}
+	return service, stub
+}
+
+// Hold makes requests wait until release is called, signalling arrived as
+// each one comes in. Held requests are released when the test ends.
+func (s *Stub) Hold(t testing.TB) (arrived <-chan struct{}, release func()) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	held := make(chan struct{})
+	s.held = held
+	s.arrived = make(chan struct{}, 10)
+
+	var once sync.Once
+	release = func() { once.Do(func() { close(held) }) }
+	t.Cleanup(release)
+	return s.arrived, release
+}
+
+// Prompts returns the prompts the stub has been sent so far
+func (s *Stub) Prompts() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string{}, s.prompts...)
+}
+
+func (s *Stub) serve(w http.ResponseWriter, r *http.Request) {
+	var request struct {
+		Stream   bool `json:"stream"`
+		Messages []struct {
+			Content string `json:"content"`
+		} `json:"messages"`
+	}
+	json.NewDecoder(r.Body).Decode(&request)
+
+	prompt := ""
+	if n := len(request.Messages); n > 0 {
+		prompt = request.Messages[n-1].Content
+	}
+
+	s.mu.Lock()
+	s.prompts = append(s.prompts, prompt)
+	reply := ""
+	if len(s.replies) > 0 {
+		reply = s.replies[min(len(s.prompts), len(s.replies))-1]
+	}
+	held, arrived := s.held, s.arrived
+	s.mu.Unlock()
+
+	if held != nil {
+		arrived <- struct{}{}
+		<-held
+	}
+
+	encoder := json.NewEncoder(w)
+	if !request.Stream {
+		encoder.Encode(map[string]interface{}{
+			"message": map[string]string{"role": "assistant", "content": reply},
+			"done":    true,
+		})
+		return
+	}
+	for _, chunk := range strings.SplitAfter(reply, " ") {
+		encoder.Encode(map[string]interface{}{
+			"message": map[string]string{"role": "assistant", "content": chunk},
+		})
+	}
+	encoder.Encode(map[string]interface{}{"done": true})
+}
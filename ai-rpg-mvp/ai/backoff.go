@@ -0,0 +1,105 @@
+package ai
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/anthropics/anthropic-sdk-go"
+)
+
+// waitToRetry sleeps before retry attempt n (counting from 1), returning
+// early with ctx's error if it is cancelled. The provider's Retry-After is
+// honored when lastErr carries one; otherwise the wait is a random share of
+// the exponential backoff, so clients that failed together don't retry
+// together.
+func (s *AIService) waitToRetry(ctx context.Context, attempt int, lastErr error) error {
+	delay, ok := retryAfter(lastErr)
+	if !ok {
+		delay = time.Duration(s.randomFloat() * float64(s.backoff(attempt)))
+	}
+
+	if s.sleep != nil {
+		s.sleep(delay)
+		return ctx.Err()
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// backoff is the longest wait before retry attempt n: RetryDelay doubled for
+// each earlier retry, capped at RetryMaxDelay
+func (s *AIService) backoff(attempt int) time.Duration {
+	delay := s.config.RetryDelay
+	maxDelay := s.config.RetryMaxDelay
+	for i := 1; i < attempt; i++ {
+		if maxDelay > 0 && delay >= maxDelay {
+			break
+		}
+		delay *= 2
+	}
+
+	if maxDelay > 0 && delay > maxDelay {
+		return maxDelay
+	}
+	return delay
+}
+
+// randomFloat returns a number in [0, 1) for jitter
+func (s *AIService) randomFloat() float64 {
+	if s.random != nil {
+		return s.random()
+	}
+	return rand.Float64()
+}
+
+// retryAfterError is implemented by errors that know how long the provider
+// asked clients to wait
+type retryAfterError interface {
+	RetryAfter() time.Duration
+}
+
+// retryAfter returns how long the provider asked to wait before retrying, if
+// err carries a Retry-After
+func retryAfter(err error) (time.Duration, bool) {
+	var waiter retryAfterError
+	if errors.As(err, &waiter) {
+		return waiter.RetryAfter(), true
+	}
+
+	var apiErr *anthropic.Error
+	if errors.As(err, &apiErr) && apiErr.Response != nil {
+		return parseRetryAfter(apiErr.Response.Header.Get("Retry-After"), time.Now())
+	}
+	return 0, false
+}
+
+// parseRetryAfter reads a Retry-After header, given either in seconds or as
+// an HTTP date
+func parseRetryAfter(value string, now time.Time) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		if wait := date.Sub(now); wait > 0 {
+			return wait, true
+		}
+		return 0, true
+	}
+	return 0, false
+}
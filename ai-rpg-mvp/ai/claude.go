@@ -3,6 +3,7 @@ package ai
 import (
 	"context"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/anthropics/anthropic-sdk-go"
@@ -57,134 +58,145 @@ func NewClaudeProvider(config AIConfig) (*ClaudeProvider, error) {
 }
 
 // GenerateGMResponse generates a Game Master response using Claude
-func (c *ClaudeProvider) GenerateGMResponse(prompt string) (string, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), c.timeout)
-	defer cancel()
-
-	// Enhance the prompt with GM-specific instructions
-	systemPrompt := `You are an expert AI Game Master running a fantasy RPG session. Your role:
-
-PERSONALITY: Helpful yet challenging guide who creates immersive experiences
-TONE: Descriptive, engaging, appropriate to fantasy setting  
-GOALS: Player agency, narrative flow, consistent world-building
+func (c *ClaudeProvider) GenerateGMResponse(ctx context.Context, prompt string) (string, Usage, error) {
+	return c.generateGM(ctx, gmSystemPrompt, prompt)
+}
 
-RESPONSE GUIDELINES:
-- Always respond in character as the GM
-- Maintain world consistency across interactions
-- React contextually to player actions and equipment  
-- Balance guidance with player discovery
-- Generate consequences for player choices
-- Keep responses engaging and immersive (2-4 sentences)
-- End with a clear situation that allows player response
+// GenerateStructuredGMResponse generates a Game Master turn as JSON using Claude
+func (c *ClaudeProvider) GenerateStructuredGMResponse(ctx context.Context, prompt string) (string, Usage, error) {
+	return c.generateGM(ctx, gmSystemPrompt+structuredGMInstruction, prompt)
+}
 
-Current game situation requires your response as Game Master.`
+// generateGM sends a Game Master request with the given system prompt
+func (c *ClaudeProvider) generateGM(ctx context.Context, systemPrompt, prompt string) (string, Usage, error) {
+	opts := GenOptsFromContext(ctx)
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
 
 	message, err := c.client.Messages.New(ctx, anthropic.MessageNewParams{
 		Model:     anthropic.Model(c.model),
-		MaxTokens: c.maxTokens,
+		MaxTokens: int64(opts.maxTokens(int(c.maxTokens))),
 		System:    []anthropic.TextBlockParam{{Type: "text", Text: systemPrompt}},
 		Messages: []anthropic.MessageParam{
 			anthropic.NewUserMessage(anthropic.NewTextBlock(prompt)),
 		},
-		Temperature: anthropic.Float(c.temperature),
+		Temperature: anthropic.Float(opts.temperature(c.temperature)),
 	})
 
 	if err != nil {
-		return "", fmt.Errorf("Claude API error: %w", err)
+		return "", Usage{}, fmt.Errorf("Claude API error: %w", err)
 	}
 
 	if len(message.Content) == 0 {
-		return "", fmt.Errorf("empty response from Claude")
+		return "", Usage{}, fmt.Errorf("empty response from Claude")
 	}
 
-	return message.Content[0].Text, nil
+	return message.Content[0].Text, claudeUsage(message.Usage), nil
 }
 
-// GenerateNPCDialogue generates NPC dialogue using Claude
-func (c *ClaudeProvider) GenerateNPCDialogue(npcName, personality, prompt string) (string, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), c.timeout)
+// StreamGMResponse streams a Game Master response from Claude, passing each
+// text delta to onChunk
+func (c *ClaudeProvider) StreamGMResponse(ctx context.Context, prompt string, onChunk func(string)) (string, Usage, error) {
+	opts := GenOptsFromContext(ctx)
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
 	defer cancel()
 
-	systemPrompt := fmt.Sprintf(`You are %s, an NPC in a fantasy RPG world.
+	stream := c.client.Messages.NewStreaming(ctx, anthropic.MessageNewParams{
+		Model:     anthropic.Model(c.model),
+		MaxTokens: int64(opts.maxTokens(int(c.maxTokens))),
+		System:    []anthropic.TextBlockParam{{Type: "text", Text: gmSystemPrompt}},
+		Messages: []anthropic.MessageParam{
+			anthropic.NewUserMessage(anthropic.NewTextBlock(prompt)),
+		},
+		Temperature: anthropic.Float(opts.temperature(c.temperature)),
+	})
+	defer stream.Close()
+
+	// The accumulated message carries the usage reported at the end of the stream
+	var message anthropic.Message
+	var response strings.Builder
+	for stream.Next() {
+		if err := message.Accumulate(stream.Current()); err != nil {
+			return "", Usage{}, fmt.Errorf("Claude stream error: %w", err)
+		}
+
+		event, ok := stream.Current().AsAny().(anthropic.ContentBlockDeltaEvent)
+		if !ok {
+			continue
+		}
+		if delta, ok := event.Delta.AsAny().(anthropic.TextDelta); ok && delta.Text != "" {
+			response.WriteString(delta.Text)
+			onChunk(delta.Text)
+		}
+	}
+
+	if err := stream.Err(); err != nil {
+		return "", Usage{}, fmt.Errorf("Claude API error: %w", err)
+	}
 
-PERSONALITY TRAITS: %s
+	if response.Len() == 0 {
+		return "", Usage{}, fmt.Errorf("empty response from Claude")
+	}
 
-DIALOGUE GUIDELINES:
-- Stay in character as %s at all times
-- Speak naturally and authentically for this character
-- Reference your personality and background
-- Respond appropriately to the player's actions and reputation
-- Keep dialogue concise but meaningful (1-3 sentences)
-- Include personality quirks or speech patterns
-- Consider your relationship with the player
+	return response.String(), claudeUsage(message.Usage), nil
+}
 
-Respond as %s would naturally speak in this situation.`,
-		npcName, personality, npcName, npcName)
+// GenerateNPCDialogue generates NPC dialogue using Claude
+func (c *ClaudeProvider) GenerateNPCDialogue(ctx context.Context, npcName, personality, prompt string) (string, Usage, error) {
+	opts := GenOptsFromContext(ctx)
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	systemPrompt := npcSystemPrompt(npcName, personality)
 
 	message, err := c.client.Messages.New(ctx, anthropic.MessageNewParams{
 		Model:     anthropic.Model(c.model),
-		MaxTokens: c.maxTokens / 2, // Shorter responses for NPCs
+		MaxTokens: int64(opts.maxTokens(int(c.maxTokens / 2))), // Shorter responses for NPCs
 		System:    []anthropic.TextBlockParam{{Type: "text", Text: systemPrompt}},
 		Messages: []anthropic.MessageParam{
 			anthropic.NewUserMessage(anthropic.NewTextBlock(prompt)),
 		},
-		Temperature: anthropic.Float(c.temperature + 0.1), // Slightly more creative for NPCs
+		Temperature: anthropic.Float(opts.temperature(c.temperature + 0.1)), // Slightly more creative for NPCs
 	})
 
 	if err != nil {
-		return "", fmt.Errorf("Claude API error: %w", err)
+		return "", Usage{}, fmt.Errorf("Claude API error: %w", err)
 	}
 
 	if len(message.Content) == 0 {
-		return "", fmt.Errorf("empty response from Claude")
+		return "", Usage{}, fmt.Errorf("empty response from Claude")
 	}
 
-	return message.Content[0].Text, nil
+	return message.Content[0].Text, claudeUsage(message.Usage), nil
 }
 
 // GenerateSceneDescription generates scene descriptions using Claude
-func (c *ClaudeProvider) GenerateSceneDescription(location, contextInfo, mood string) (string, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), c.timeout)
+func (c *ClaudeProvider) GenerateSceneDescription(ctx context.Context, location, contextInfo, mood string) (string, Usage, error) {
+	opts := GenOptsFromContext(ctx)
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
 	defer cancel()
 
-	systemPrompt := `You are a skilled fantasy writer creating immersive scene descriptions for an RPG.
-
-DESCRIPTION GUIDELINES:
-- Create vivid, atmospheric descriptions that set the mood
-- Include sensory details (sight, sound, smell, feel)
-- Match the tone and mood of the situation
-- Keep descriptions concise but evocative (2-3 sentences)
-- Focus on elements that enhance gameplay and immersion
-- Include details that suggest possible interactions or discoveries
-- Maintain consistency with fantasy RPG conventions
-
-Create an engaging scene description based on the provided context.`
-
-	scenePrompt := fmt.Sprintf(`Location: %s
-Context: %s
-Mood/Atmosphere: %s
-
-Describe this scene:`, location, contextInfo, mood)
+	userPrompt := scenePrompt(location, contextInfo, mood)
 
 	message, err := c.client.Messages.New(ctx, anthropic.MessageNewParams{
 		Model:     anthropic.Model(c.model),
-		MaxTokens: c.maxTokens / 2,
-		System:    []anthropic.TextBlockParam{{Type: "text", Text: systemPrompt}},
+		MaxTokens: int64(opts.maxTokens(int(c.maxTokens / 2))),
+		System:    []anthropic.TextBlockParam{{Type: "text", Text: sceneSystemPrompt}},
 		Messages: []anthropic.MessageParam{
-			anthropic.NewUserMessage(anthropic.NewTextBlock(scenePrompt)),
+			anthropic.NewUserMessage(anthropic.NewTextBlock(userPrompt)),
 		},
-		Temperature: anthropic.Float(c.temperature + 0.2), // More creative for descriptions
+		Temperature: anthropic.Float(opts.temperature(c.temperature + 0.2)), // More creative for descriptions
 	})
 
 	if err != nil {
-		return "", fmt.Errorf("Claude API error: %w", err)
+		return "", Usage{}, fmt.Errorf("Claude API error: %w", err)
 	}
 
 	if len(message.Content) == 0 {
-		return "", fmt.Errorf("empty response from Claude")
+		return "", Usage{}, fmt.Errorf("empty response from Claude")
 	}
 
-	return message.Content[0].Text, nil
+	return message.Content[0].Text, claudeUsage(message.Usage), nil
 }
 
 // GetProviderName returns the provider name
@@ -192,6 +204,14 @@ func (c *ClaudeProvider) GetProviderName() string {
 	return "claude"
 }
 
+// claudeUsage converts the token counts reported by Claude
+func claudeUsage(usage anthropic.Usage) Usage {
+	return Usage{
+		InputTokens:  int(usage.InputTokens),
+		OutputTokens: int(usage.OutputTokens),
+	}
+}
+
 // ValidateClaudeConfig validates Claude-specific configuration
 func ValidateClaudeConfig(config AIConfig) error {
 	if config.APIKey == "" {
@@ -0,0 +1,206 @@
+package ai
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Default endpoints for the supported embedding providers. Anthropic has no
+// embeddings API of its own and points Claude users at Voyage AI instead.
+const (
+	defaultOpenAIEmbeddingsURL = "https://api.openai.com/v1/embeddings"
+	defaultVoyageEmbeddingsURL = "https://api.voyageai.com/v1/embeddings"
+	defaultOllamaEmbeddingsURL = "http://localhost:11434/api/embeddings"
+)
+
+// Embedder turns text into a vector whose distance to other vectors reflects
+// how similar their meanings are
+type Embedder interface {
+	Embed(text string) ([]float32, error)
+}
+
+// NewEmbedder creates the embedder named by config.EmbeddingProvider. The
+// embedding settings fall back to the main API key and timeout when unset.
+func NewEmbedder(config AIConfig) (Embedder, error) {
+	apiKey := config.EmbeddingAPIKey
+	if apiKey == "" {
+		apiKey = config.APIKey
+	}
+
+	timeout := config.Timeout
+	if timeout == 0 {
+		timeout = 30 * time.Second
+	}
+
+	switch strings.ToLower(config.EmbeddingProvider) {
+	case "openai":
+		return newHTTPEmbedder("OpenAI", config.EmbeddingBaseURL, defaultOpenAIEmbeddingsURL,
+			config.EmbeddingModel, "text-embedding-3-small", apiKey, timeout)
+	case "voyage", "claude", "anthropic":
+		return newHTTPEmbedder("Voyage", config.EmbeddingBaseURL, defaultVoyageEmbeddingsURL,
+			config.EmbeddingModel, "voyage-3-lite", apiKey, timeout)
+	case "ollama":
+		return NewOllamaEmbedder(config.EmbeddingBaseURL, config.EmbeddingModel, timeout), nil
+	default:
+		return nil, fmt.Errorf("unsupported embedding provider: %s", config.EmbeddingProvider)
+	}
+}
+
+// HTTPEmbedder calls an OpenAI-compatible embeddings endpoint, which both
+// OpenAI and Voyage AI provide
+type HTTPEmbedder struct {
+	name    string
+	client  *http.Client
+	baseURL string
+	model   string
+	apiKey  string
+}
+
+// embeddingRequest is the body sent to an OpenAI-compatible embeddings endpoint
+type embeddingRequest struct {
+	Input []string `json:"input"`
+	Model string   `json:"model"`
+}
+
+// embeddingResponse is the reply from an OpenAI-compatible embeddings endpoint
+type embeddingResponse struct {
+	Data []struct {
+		Embedding []float32 `json:"embedding"`
+	} `json:"data"`
+	Error *struct {
+		Message string `json:"message"`
+	} `json:"error,omitempty"`
+}
+
+// newHTTPEmbedder creates an embedder for an OpenAI-compatible endpoint,
+// using the given defaults for anything left unset
+func newHTTPEmbedder(name, baseURL, defaultURL, model, defaultModel, apiKey string, timeout time.Duration) (Embedder, error) {
+	if apiKey == "" {
+		return nil, fmt.Errorf("%s embeddings API key is required", name)
+	}
+	if baseURL == "" {
+		baseURL = defaultURL
+	}
+	if model == "" {
+		model = defaultModel
+	}
+
+	return &HTTPEmbedder{
+		name:    name,
+		client:  &http.Client{Timeout: timeout},
+		baseURL: baseURL,
+		model:   model,
+		apiKey:  apiKey,
+	}, nil
+}
+
+// Embed returns the embedding of text
+func (e *HTTPEmbedder) Embed(text string) ([]float32, error) {
+	var resp embeddingResponse
+	err := postEmbeddingRequest(e.client, e.baseURL, e.apiKey, e.name, embeddingRequest{
+		Input: []string{text},
+		Model: e.model,
+	}, &resp)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.Error != nil {
+		return nil, fmt.Errorf("%s embeddings error: %s", e.name, resp.Error.Message)
+	}
+	if len(resp.Data) == 0 || len(resp.Data[0].Embedding) == 0 {
+		return nil, fmt.Errorf("empty embedding from %s", e.name)
+	}
+	return resp.Data[0].Embedding, nil
+}
+
+// OllamaEmbedder calls the embeddings endpoint of a local Ollama server
+type OllamaEmbedder struct {
+	client  *http.Client
+	baseURL string
+	model   string
+}
+
+// ollamaEmbeddingRequest is the body sent to the Ollama embeddings endpoint
+type ollamaEmbeddingRequest struct {
+	Model  string `json:"model"`
+	Prompt string `json:"prompt"`
+}
+
+// ollamaEmbeddingResponse is the reply from the Ollama embeddings endpoint
+type ollamaEmbeddingResponse struct {
+	Embedding []float32 `json:"embedding"`
+	Error     string    `json:"error,omitempty"`
+}
+
+// NewOllamaEmbedder creates an Ollama embedder. No API key is required.
+func NewOllamaEmbedder(baseURL, model string, timeout time.Duration) *OllamaEmbedder {
+	if baseURL == "" {
+		baseURL = defaultOllamaEmbeddingsURL
+	}
+	if model == "" {
+		model = "nomic-embed-text"
+	}
+
+	return &OllamaEmbedder{
+		client:  &http.Client{Timeout: timeout},
+		baseURL: baseURL,
+		model:   model,
+	}
+}
+
+// Embed returns the embedding of text
+func (o *OllamaEmbedder) Embed(text string) ([]float32, error) {
+	var resp ollamaEmbeddingResponse
+	err := postEmbeddingRequest(o.client, o.baseURL, "", "Ollama", ollamaEmbeddingRequest{
+		Model:  o.model,
+		Prompt: text,
+	}, &resp)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.Error != "" {
+		return nil, fmt.Errorf("Ollama embeddings error: %s", resp.Error)
+	}
+	if len(resp.Embedding) == 0 {
+		return nil, fmt.Errorf("empty embedding from Ollama")
+	}
+	return resp.Embedding, nil
+}
+
+// postEmbeddingRequest posts body as JSON and decodes the reply into out
+func postEmbeddingRequest(client *http.Client, url, apiKey, name string, body, out interface{}) error {
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return fmt.Errorf("failed to marshal %s embeddings request: %w", name, err)
+	}
+
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(payload))
+	if err != nil {
+		return fmt.Errorf("failed to create %s embeddings request: %w", name, err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+apiKey)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("%s embeddings API error: %w", name, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s embeddings API error: status %d", name, resp.StatusCode)
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to parse %s embeddings response: %w", name, err)
+	}
+	return nil
+}
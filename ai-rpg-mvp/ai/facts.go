@@ -0,0 +1,59 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+)
+
+// ExtractNPCFacts asks the AI which facts an NPC learned about the player
+// from an exchange. A reply that isn't a JSON list of strings yields no facts
+// rather than an error.
+func (s *AIService) ExtractNPCFacts(npcName string, knownFacts []string, exchange string) ([]string, error) {
+	return s.ExtractNPCFactsForSession("", npcName, knownFacts, exchange)
+}
+
+// ExtractNPCFactsForSession extracts NPC facts, counting the request against
+// the session's own rate limit
+func (s *AIService) ExtractNPCFactsForSession(sessionID, npcName string, knownFacts []string, exchange string) ([]string, error) {
+	if err := s.checkRateLimit(sessionID); err != nil {
+		return nil, err
+	}
+
+	prompt := factExtractionPrompt(npcName, knownFacts, exchange)
+	response, err := s.generateWithRetry(context.Background(), func(ctx context.Context) (string, Usage, error) {
+		return s.provider.GenerateGMResponse(ctx, prompt)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	facts, err := parseFactList(response)
+	if err != nil {
+		s.log().Warn("Ignoring malformed NPC fact list", "npc", npcName, "error", err)
+		return []string{}, nil
+	}
+	return facts, nil
+}
+
+// parseFactList decodes a JSON list of facts, tolerating markdown code fences
+// and dropping blank entries
+func parseFactList(response string) ([]string, error) {
+	text := strings.TrimSpace(response)
+	text = strings.TrimPrefix(text, "```json")
+	text = strings.TrimPrefix(text, "```")
+	text = strings.TrimSuffix(text, "```")
+
+	var raw []string
+	if err := json.Unmarshal([]byte(strings.TrimSpace(text)), &raw); err != nil {
+		return nil, err
+	}
+
+	facts := make([]string, 0, len(raw))
+	for _, fact := range raw {
+		if fact = strings.TrimSpace(fact); fact != "" {
+			facts = append(facts, fact)
+		}
+	}
+	return facts, nil
+}
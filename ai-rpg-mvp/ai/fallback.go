@@ -0,0 +1,114 @@
+package ai
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+)
+
+// ProviderConfig selects and authenticates one provider in a fallback chain
+type ProviderConfig struct {
+	Provider string
+	APIKey   string
+	BaseURL  string
+	Model    string // empty uses the provider's default model
+}
+
+// FallbackProvider tries an ordered list of providers, moving on to the next
+// whenever one returns an error. Every request starts with the primary, so
+// service resumes on it as soon as it recovers.
+type FallbackProvider struct {
+	providers []AIProvider
+	active    int // index of the provider that last answered
+	mutex     sync.RWMutex
+	logger    *slog.Logger
+}
+
+// NewFallbackProvider creates a fallback chain; the first provider is the primary
+func NewFallbackProvider(providers ...AIProvider) (*FallbackProvider, error) {
+	if len(providers) == 0 {
+		return nil, fmt.Errorf("fallback chain needs at least one provider")
+	}
+
+	return &FallbackProvider{providers: providers, logger: slog.Default()}, nil
+}
+
+// GenerateGMResponse generates a Game Master response from the first provider that succeeds
+func (f *FallbackProvider) GenerateGMResponse(ctx context.Context, prompt string) (string, Usage, error) {
+	return f.try(ctx, func(p AIProvider) (string, Usage, error) {
+		return p.GenerateGMResponse(ctx, prompt)
+	})
+}
+
+// StreamGMResponse streams from the first provider that succeeds. Once a
+// provider has sent chunks its failure is returned rather than retried, so the
+// caller never sees a reply start over.
+func (f *FallbackProvider) StreamGMResponse(ctx context.Context, prompt string, onChunk func(string)) (string, Usage, error) {
+	streamed := false
+	return f.tryWhile(ctx, func() bool { return !streamed }, func(p AIProvider) (string, Usage, error) {
+		return p.StreamGMResponse(ctx, prompt, func(chunk string) {
+			streamed = true
+			onChunk(chunk)
+		})
+	})
+}
+
+// GenerateStructuredGMResponse generates a JSON Game Master turn from the first provider that succeeds
+func (f *FallbackProvider) GenerateStructuredGMResponse(ctx context.Context, prompt string) (string, Usage, error) {
+	return f.try(ctx, func(p AIProvider) (string, Usage, error) {
+		return p.GenerateStructuredGMResponse(ctx, prompt)
+	})
+}
+
+// GenerateNPCDialogue generates NPC dialogue from the first provider that succeeds
+func (f *FallbackProvider) GenerateNPCDialogue(ctx context.Context, npcName, personality, prompt string) (string, Usage, error) {
+	return f.try(ctx, func(p AIProvider) (string, Usage, error) {
+		return p.GenerateNPCDialogue(ctx, npcName, personality, prompt)
+	})
+}
+
+// GenerateSceneDescription generates a scene description from the first provider that succeeds
+func (f *FallbackProvider) GenerateSceneDescription(ctx context.Context, location, contextInfo, mood string) (string, Usage, error) {
+	return f.try(ctx, func(p AIProvider) (string, Usage, error) {
+		return p.GenerateSceneDescription(ctx, location, contextInfo, mood)
+	})
+}
+
+// GetProviderName returns the name of the provider that last answered
+func (f *FallbackProvider) GetProviderName() string {
+	f.mutex.RLock()
+	defer f.mutex.RUnlock()
+
+	return f.providers[f.active].GetProviderName()
+}
+
+// try calls fn on each provider in order until one succeeds
+func (f *FallbackProvider) try(ctx context.Context, fn func(AIProvider) (string, Usage, error)) (string, Usage, error) {
+	return f.tryWhile(ctx, func() bool { return true }, fn)
+}
+
+// tryWhile calls fn on each provider in order until one succeeds, canContinue
+// reports that falling back is no longer safe, or ctx is done
+func (f *FallbackProvider) tryWhile(ctx context.Context, canContinue func() bool, fn func(AIProvider) (string, Usage, error)) (string, Usage, error) {
+	var lastErr error
+	for i, provider := range f.providers {
+		response, usage, err := fn(provider)
+		if err == nil {
+			f.mutex.Lock()
+			f.active = i
+			f.mutex.Unlock()
+			return response, usage, nil
+		}
+
+		lastErr = err
+		if !canContinue() || ctx.Err() != nil {
+			break
+		}
+		if i+1 < len(f.providers) {
+			f.logger.Warn("AI provider failed, falling back", "provider", provider.GetProviderName(), "fallback", f.providers[i+1].GetProviderName(), "error", err)
+		}
+	}
+
+	return "", Usage{}, lastErr
+}
@@ -0,0 +1,316 @@
+package ai
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// defaultGeminiURL is the base of Google's Gemini API
+const defaultGeminiURL = "https://generativelanguage.googleapis.com/v1beta"
+
+// GeminiProvider implements the AIProvider interface using Google's Gemini API.
+// It calls the generateContent and streamGenerateContent REST endpoints
+// directly, like OllamaProvider, rather than through
+// github.com/google/generative-ai-go: those two endpoints are all it needs,
+// the SDK brings in the google.golang.org/api and gRPC dependency trees and
+// has been deprecated upstream, and a plain base URL lets tests point the
+// provider at a local stand-in.
+type GeminiProvider struct {
+	client      *http.Client
+	apiKey      string
+	baseURL     string
+	model       string
+	maxTokens   int
+	temperature float64
+	timeout     time.Duration
+}
+
+// geminiPart is one piece of message content; only text is used
+type geminiPart struct {
+	Text string `json:"text"`
+}
+
+// geminiContent is a message in a Gemini request or response
+type geminiContent struct {
+	Role  string       `json:"role,omitempty"`
+	Parts []geminiPart `json:"parts"`
+}
+
+// geminiGenerationConfig holds the sampling settings Gemini accepts per request
+type geminiGenerationConfig struct {
+	Temperature     float64 `json:"temperature"`
+	MaxOutputTokens int     `json:"maxOutputTokens,omitempty"`
+}
+
+// geminiRequest is the body sent to generateContent and streamGenerateContent
+type geminiRequest struct {
+	SystemInstruction geminiContent          `json:"systemInstruction"`
+	Contents          []geminiContent        `json:"contents"`
+	GenerationConfig  geminiGenerationConfig `json:"generationConfig"`
+}
+
+// geminiResponse is a reply, or one streamed chunk of a reply, from Gemini
+type geminiResponse struct {
+	Candidates []struct {
+		Content      geminiContent `json:"content"`
+		FinishReason string        `json:"finishReason,omitempty"`
+	} `json:"candidates"`
+	UsageMetadata struct {
+		PromptTokenCount     int `json:"promptTokenCount"`
+		CandidatesTokenCount int `json:"candidatesTokenCount"`
+	} `json:"usageMetadata"`
+	Error *geminiError `json:"error,omitempty"`
+}
+
+// geminiError is the error object Google APIs return
+type geminiError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+	Status  string `json:"status"`
+}
+
+// text joins the text parts of the first candidate
+func (r geminiResponse) text() string {
+	if len(r.Candidates) == 0 {
+		return ""
+	}
+	var text strings.Builder
+	for _, part := range r.Candidates[0].Content.Parts {
+		text.WriteString(part.Text)
+	}
+	return text.String()
+}
+
+// usage converts the token counts Gemini reports
+func (r geminiResponse) usage() Usage {
+	return Usage{
+		InputTokens:  r.UsageMetadata.PromptTokenCount,
+		OutputTokens: r.UsageMetadata.CandidatesTokenCount,
+	}
+}
+
+// NewGeminiProvider creates a new Gemini provider
+func NewGeminiProvider(config AIConfig) (*GeminiProvider, error) {
+	if config.APIKey == "" {
+		return nil, fmt.Errorf("Gemini API key is required")
+	}
+
+	baseURL := strings.TrimSuffix(config.BaseURL, "/")
+	if baseURL == "" {
+		baseURL = defaultGeminiURL
+	}
+
+	model := config.Model
+	if model == "" {
+		model = "gemini-1.5-flash"
+	}
+
+	maxTokens := config.MaxTokens
+	if maxTokens == 0 {
+		maxTokens = 1000
+	}
+
+	temperature := config.Temperature
+	if temperature == 0 {
+		temperature = 0.7
+	}
+
+	timeout := config.Timeout
+	if timeout == 0 {
+		timeout = 30 * time.Second
+	}
+
+	return &GeminiProvider{
+		client:      &http.Client{},
+		apiKey:      config.APIKey,
+		baseURL:     baseURL,
+		model:       model,
+		maxTokens:   maxTokens,
+		temperature: temperature,
+		timeout:     timeout,
+	}, nil
+}
+
+// GenerateGMResponse generates a Game Master response using Gemini
+func (g *GeminiProvider) GenerateGMResponse(ctx context.Context, prompt string) (string, Usage, error) {
+	return g.generate(ctx, gmSystemPrompt, prompt, g.maxTokens, g.temperature)
+}
+
+// GenerateStructuredGMResponse generates a Game Master turn as JSON using Gemini
+func (g *GeminiProvider) GenerateStructuredGMResponse(ctx context.Context, prompt string) (string, Usage, error) {
+	return g.generate(ctx, gmSystemPrompt+structuredGMInstruction, prompt, g.maxTokens, g.temperature)
+}
+
+// GenerateNPCDialogue generates NPC dialogue using Gemini
+func (g *GeminiProvider) GenerateNPCDialogue(ctx context.Context, npcName, personality, prompt string) (string, Usage, error) {
+	// Shorter, slightly more creative responses for NPCs
+	return g.generate(ctx, npcSystemPrompt(npcName, personality), prompt, g.maxTokens/2, g.temperature+0.1)
+}
+
+// GenerateSceneDescription generates scene descriptions using Gemini
+func (g *GeminiProvider) GenerateSceneDescription(ctx context.Context, location, contextInfo, mood string) (string, Usage, error) {
+	return g.generate(ctx, sceneSystemPrompt, scenePrompt(location, contextInfo, mood), g.maxTokens/2, g.temperature+0.2)
+}
+
+// GetProviderName returns the provider name
+func (g *GeminiProvider) GetProviderName() string {
+	return "gemini"
+}
+
+// StreamGMResponse streams a Game Master response from Gemini, passing each
+// text chunk to onChunk
+func (g *GeminiProvider) StreamGMResponse(ctx context.Context, prompt string, onChunk func(string)) (string, Usage, error) {
+	opts := GenOptsFromContext(ctx)
+	ctx, cancel := context.WithTimeout(ctx, g.timeout)
+	defer cancel()
+
+	req := g.newRequest(gmSystemPrompt, prompt, opts.maxTokens(g.maxTokens), opts.temperature(g.temperature))
+	resp, err := g.send(ctx, "streamGenerateContent?alt=sse", req)
+	if err != nil {
+		return "", Usage{}, err
+	}
+	defer resp.Body.Close()
+
+	// Server-sent events, one JSON response per data line; the last carries
+	// the final token counts
+	var response strings.Builder
+	var usage Usage
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		data, ok := strings.CutPrefix(scanner.Text(), "data: ")
+		if !ok {
+			continue
+		}
+
+		var chunk geminiResponse
+		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+			return "", Usage{}, fmt.Errorf("failed to parse Gemini stream: %w", err)
+		}
+		if chunk.Error != nil {
+			return "", Usage{}, geminiAPIError(resp.StatusCode, chunk.Error)
+		}
+		if text := chunk.text(); text != "" {
+			response.WriteString(text)
+			onChunk(text)
+		}
+		usage = chunk.usage()
+	}
+	if err := scanner.Err(); err != nil {
+		return "", Usage{}, fmt.Errorf("Gemini stream error: %w", err)
+	}
+
+	if response.Len() == 0 {
+		return "", Usage{}, fmt.Errorf("empty response from Gemini")
+	}
+
+	return response.String(), usage, nil
+}
+
+// generate sends a single system/user exchange to Gemini and returns the
+// reply. Overrides attached to ctx replace maxTokens and temperature.
+func (g *GeminiProvider) generate(ctx context.Context, systemPrompt, prompt string, maxTokens int, temperature float64) (string, Usage, error) {
+	opts := GenOptsFromContext(ctx)
+	ctx, cancel := context.WithTimeout(ctx, g.timeout)
+	defer cancel()
+
+	resp, err := g.send(ctx, "generateContent", g.newRequest(systemPrompt, prompt, opts.maxTokens(maxTokens), opts.temperature(temperature)))
+	if err != nil {
+		return "", Usage{}, err
+	}
+	defer resp.Body.Close()
+
+	var genResp geminiResponse
+	if err := json.NewDecoder(resp.Body).Decode(&genResp); err != nil {
+		return "", Usage{}, fmt.Errorf("failed to parse Gemini response: %w", err)
+	}
+
+	text := genResp.text()
+	if text == "" {
+		return "", Usage{}, fmt.Errorf("empty response from Gemini")
+	}
+
+	return text, genResp.usage(), nil
+}
+
+// newRequest builds a request for a single system/user exchange
+func (g *GeminiProvider) newRequest(systemPrompt, prompt string, maxTokens int, temperature float64) geminiRequest {
+	return geminiRequest{
+		SystemInstruction: geminiContent{Parts: []geminiPart{{Text: systemPrompt}}},
+		Contents: []geminiContent{
+			{Role: "user", Parts: []geminiPart{{Text: prompt}}},
+		},
+		GenerationConfig: geminiGenerationConfig{
+			Temperature:     temperature,
+			MaxOutputTokens: maxTokens,
+		},
+	}
+}
+
+// send posts a request to one of the model's methods and returns the
+// response once its status has been checked. The caller must close the
+// response body.
+func (g *GeminiProvider) send(ctx context.Context, method string, genReq geminiRequest) (*http.Response, error) {
+	body, err := json.Marshal(genReq)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal Gemini request: %w", err)
+	}
+
+	url := fmt.Sprintf("%s/models/%s:%s", g.baseURL, g.model, method)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Gemini request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("x-goog-api-key", g.apiKey)
+
+	resp, err := g.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("Gemini API error: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+
+		var errResp geminiResponse
+		if json.NewDecoder(resp.Body).Decode(&errResp) != nil || errResp.Error == nil {
+			errResp.Error = &geminiError{}
+		}
+		return nil, geminiAPIError(resp.StatusCode, errResp.Error)
+	}
+
+	return resp, nil
+}
+
+// geminiAPIError describes a Gemini error, naming auth and quota failures in
+// the words isNonRetryableError looks for so they aren't retried
+func geminiAPIError(statusCode int, apiErr *geminiError) error {
+	kind := ""
+	switch {
+	case statusCode == http.StatusUnauthorized || apiErr.Status == "UNAUTHENTICATED" || strings.Contains(apiErr.Message, "API key not valid"):
+		kind = "invalid api key: "
+	case statusCode == http.StatusForbidden || apiErr.Status == "PERMISSION_DENIED":
+		kind = "forbidden: "
+	case statusCode == http.StatusTooManyRequests || apiErr.Status == "RESOURCE_EXHAUSTED":
+		kind = "quota exceeded: "
+	}
+
+	if apiErr.Message == "" {
+		return fmt.Errorf("Gemini API error: %sstatus %d", kind, statusCode)
+	}
+	return fmt.Errorf("Gemini API error (%d): %s%s", statusCode, kind, apiErr.Message)
+}
+
+// ValidateGeminiConfig validates Gemini-specific configuration
+func ValidateGeminiConfig(config AIConfig) error {
+	if config.APIKey == "" {
+		return fmt.Errorf("Gemini API key is required")
+	}
+	return nil
+}
@@ -0,0 +1,57 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	gamecontext "ai-rpg-mvp/context"
+)
+
+// InterpretCommand asks the AI which game command a player's free text means,
+// choosing among grounding's verbs and referring to its NPCs, items and exits
+// by ID
+func (s *AIService) InterpretCommand(freeText string, grounding gamecontext.CommandGrounding) (gamecontext.CommandInterpretation, error) {
+	return s.InterpretCommandForSession("", freeText, grounding)
+}
+
+// InterpretCommandForSession interprets a command, counting the request
+// against the session's own rate limit
+func (s *AIService) InterpretCommandForSession(sessionID, freeText string, grounding gamecontext.CommandGrounding) (gamecontext.CommandInterpretation, error) {
+	if err := s.checkRateLimit(sessionID); err != nil {
+		return gamecontext.CommandInterpretation{}, err
+	}
+
+	prompt := commandInterpretationPrompt(freeText, grounding)
+	response, err := s.generateWithRetry(context.Background(), func(ctx context.Context) (string, Usage, error) {
+		return s.provider.GenerateGMResponse(ctx, prompt)
+	})
+	if err != nil {
+		return gamecontext.CommandInterpretation{}, err
+	}
+
+	return parseCommandInterpretation(response)
+}
+
+// parseCommandInterpretation decodes the AI's JSON reading of a command,
+// tolerating markdown code fences
+func parseCommandInterpretation(response string) (gamecontext.CommandInterpretation, error) {
+	text := strings.TrimSpace(response)
+	text = strings.TrimPrefix(text, "```json")
+	text = strings.TrimPrefix(text, "```")
+	text = strings.TrimSuffix(text, "```")
+
+	var interpretation gamecontext.CommandInterpretation
+	if err := json.Unmarshal([]byte(strings.TrimSpace(text)), &interpretation); err != nil {
+		return gamecontext.CommandInterpretation{}, fmt.Errorf("invalid command interpretation: %w", err)
+	}
+
+	interpretation.Verb = strings.ToLower(strings.TrimPrefix(strings.TrimSpace(interpretation.Verb), "/"))
+	interpretation.ActionType = strings.TrimSpace(interpretation.ActionType)
+	interpretation.Target = strings.TrimSpace(interpretation.Target)
+	if interpretation.Verb == "" && interpretation.ActionType == "" {
+		return gamecontext.CommandInterpretation{}, fmt.Errorf("command interpretation names no verb or action type")
+	}
+	return interpretation, nil
+}
@@ -0,0 +1,88 @@
+package ai
+
+import "context"
+
+// maxTrackedSessionKeys is how many cache keys a session may have tracked
+// before keys whose entries have left the cache are pruned
+const maxTrackedSessionKeys = 256
+
+// cacheSet stores a response and remembers key as one of the session's keys
+func (s *AIService) cacheSet(sessionID, key, value string) {
+	s.cache.Set(key, value)
+	s.trackSessionKey(sessionID, key)
+}
+
+// trackSessionKey remembers that sessionID produced or was served key.
+// Requests without a session aren't tracked.
+func (s *AIService) trackSessionKey(sessionID, key string) {
+	if sessionID == "" {
+		return
+	}
+
+	s.sessionKeysMutex.Lock()
+	defer s.sessionKeysMutex.Unlock()
+
+	if s.sessionKeys == nil {
+		s.sessionKeys = make(map[string]map[string]struct{})
+	}
+	keys, exists := s.sessionKeys[sessionID]
+	if !exists {
+		keys = make(map[string]struct{})
+		s.sessionKeys[sessionID] = keys
+	}
+	keys[key] = struct{}{}
+
+	if len(keys) > maxTrackedSessionKeys {
+		for tracked := range keys {
+			if !s.cache.contains(tracked) {
+				delete(keys, tracked)
+			}
+		}
+	}
+}
+
+// InvalidateCache drops one cached response so the next identical request
+// goes back to the provider
+func (s *AIService) InvalidateCache(key string) {
+	if s.cache == nil {
+		return
+	}
+	s.cache.Delete(key)
+}
+
+// RegenerateGMResponseForSessionCtx asks the provider for a fresh Game
+// Master response to a prompt that was already answered, replacing the
+// cached reply instead of serving it again
+func (s *AIService) RegenerateGMResponseForSessionCtx(ctx context.Context, sessionID, prompt string) (string, error) {
+	s.InvalidateCache(s.cacheKey("gm", GenOpts{}, hashString(prompt)))
+	return s.GenerateGMResponseForSessionCtx(ctx, sessionID, prompt)
+}
+
+// InvalidateSessionCache drops every cached response a session produced or
+// was served, so its next requests are generated afresh. Entries shared with
+// other sessions that sent the same prompt are dropped for them too.
+func (s *AIService) InvalidateSessionCache(sessionID string) {
+	s.sessionKeysMutex.Lock()
+	keys := s.sessionKeys[sessionID]
+	delete(s.sessionKeys, sessionID)
+	s.sessionKeysMutex.Unlock()
+
+	if s.cache == nil {
+		return
+	}
+	for key := range keys {
+		s.cache.Delete(key)
+	}
+}
+
+// ClearCache drops every cached response
+func (s *AIService) ClearCache() {
+	s.sessionKeysMutex.Lock()
+	s.sessionKeys = nil
+	s.sessionKeysMutex.Unlock()
+
+	if s.cache == nil {
+		return
+	}
+	s.cache.Clear()
+}
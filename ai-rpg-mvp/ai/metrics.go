@@ -0,0 +1,44 @@
+package ai
+
+import "time"
+
+// Metrics receives AI service events for monitoring. metrics.Registry
+// satisfies it.
+type Metrics interface {
+	AIRequest(provider string, duration time.Duration, err error)
+	AICacheLookup(hit bool)
+	AIRateLimited()
+}
+
+// SetMetrics reports AI requests, cache lookups and rate-limit rejections to
+// m. Call it before the service starts handling requests.
+func (s *AIService) SetMetrics(m Metrics) {
+	s.metrics = m
+}
+
+// cacheGet looks key up in the response cache, recording the hit or miss.
+// A hit is remembered as one of the session's keys.
+func (s *AIService) cacheGet(sessionID, key string) string {
+	cached := s.cache.Get(key)
+	if cached != "" {
+		s.trackSessionKey(sessionID, key)
+	}
+	if s.metrics != nil {
+		s.metrics.AICacheLookup(cached != "")
+	}
+	return cached
+}
+
+// rateLimited records a request turned away by a rate limit
+func (s *AIService) rateLimited() {
+	if s.metrics != nil {
+		s.metrics.AIRateLimited()
+	}
+}
+
+// observeRequest records one provider call that started at start
+func (s *AIService) observeRequest(start time.Time, err error) {
+	if s.metrics != nil {
+		s.metrics.AIRequest(s.GetProviderName(), time.Since(start), err)
+	}
+}
@@ -0,0 +1,125 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// mockActionLimit caps how much of the player's action the mock repeats back
+const mockActionLimit = 80
+
+// MockProvider implements the AIProvider interface with canned replies built
+// from the prompt. It makes no network calls and needs no API key, so demos
+// and tests run offline; the same prompt always gets the same reply.
+type MockProvider struct{}
+
+// NewMockProvider creates a new mock provider
+func NewMockProvider(config AIConfig) (*MockProvider, error) {
+	return &MockProvider{}, nil
+}
+
+// GenerateGMResponse narrates the player action found in the prompt
+func (m *MockProvider) GenerateGMResponse(ctx context.Context, prompt string) (string, Usage, error) {
+	if err := ctx.Err(); err != nil {
+		return "", Usage{}, err
+	}
+	response := mockNarration(prompt)
+	return response, mockUsage(prompt, response), nil
+}
+
+// StreamGMResponse streams the GM reply one word at a time
+func (m *MockProvider) StreamGMResponse(ctx context.Context, prompt string, onChunk func(string)) (string, Usage, error) {
+	response, usage, err := m.GenerateGMResponse(ctx, prompt)
+	if err != nil {
+		return "", Usage{}, err
+	}
+
+	for _, word := range strings.SplitAfter(response, " ") {
+		onChunk(word)
+	}
+	return response, usage, nil
+}
+
+// GenerateStructuredGMResponse returns the GM reply as a GMTurn in JSON
+func (m *MockProvider) GenerateStructuredGMResponse(ctx context.Context, prompt string) (string, Usage, error) {
+	if err := ctx.Err(); err != nil {
+		return "", Usage{}, err
+	}
+
+	encoded, err := json.Marshal(GMTurn{
+		Narration:             mockNarration(prompt),
+		SuggestedConsequences: []string{},
+		Choices:               []string{"Look around", "Press on", "Turn back"},
+	})
+	if err != nil {
+		return "", Usage{}, err
+	}
+	return string(encoded), mockUsage(prompt, string(encoded)), nil
+}
+
+// GenerateNPCDialogue has the NPC acknowledge what the player said
+func (m *MockProvider) GenerateNPCDialogue(ctx context.Context, npcName, personality, prompt string) (string, Usage, error) {
+	if err := ctx.Err(); err != nil {
+		return "", Usage{}, err
+	}
+
+	response := fmt.Sprintf(`%s considers your words. "%s? I'll have to think on that, traveler."`, npcName, mockAction(prompt))
+	return response, mockUsage(prompt, response), nil
+}
+
+// GenerateSceneDescription describes the location in the requested mood
+func (m *MockProvider) GenerateSceneDescription(ctx context.Context, location, contextInfo, mood string) (string, Usage, error) {
+	if err := ctx.Err(); err != nil {
+		return "", Usage{}, err
+	}
+
+	if mood == "" {
+		mood = "quiet"
+	}
+	response := fmt.Sprintf("You stand in %s. The air feels %s, and everything around you waits to be explored.", location, mood)
+	return response, mockUsage(contextInfo, response), nil
+}
+
+// GetProviderName returns the provider name
+func (m *MockProvider) GetProviderName() string {
+	return "mock"
+}
+
+// mockNarration is the GM reply to the action in prompt
+func mockNarration(prompt string) string {
+	return fmt.Sprintf("You %s. The world responds in kind, and for a moment all is still. What do you do next?", strings.TrimPrefix(mockAction(prompt), "/"))
+}
+
+// mockAction picks the player's action out of a prompt: the "Player Action:"
+// line the game servers add, or else the last line of the prompt
+func mockAction(prompt string) string {
+	action := ""
+	for _, line := range strings.Split(prompt, "\n") {
+		line = strings.TrimSpace(line)
+		if rest, ok := strings.CutPrefix(line, "Player Action:"); ok {
+			action = strings.TrimSpace(rest)
+			break
+		}
+		if line != "" {
+			action = line
+		}
+	}
+
+	if action == "" {
+		action = "wait"
+	}
+	if runes := []rune(action); len(runes) > mockActionLimit {
+		action = strings.TrimSpace(string(runes[:mockActionLimit])) + "..."
+	}
+	return action
+}
+
+// mockUsage estimates token counts at roughly four characters per token
+func mockUsage(prompt, response string) Usage {
+	return Usage{
+		InputTokens:  len(prompt) / 4,
+		OutputTokens: len(response) / 4,
+	}
+}
@@ -0,0 +1,247 @@
+package ai
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// defaultOllamaURL is the chat endpoint of a locally running Ollama server
+const defaultOllamaURL = "http://localhost:11434/api/chat"
+
+// OllamaProvider implements the AIProvider interface using a local Ollama server
+type OllamaProvider struct {
+	client      *http.Client
+	baseURL     string
+	model       string
+	maxTokens   int
+	temperature float64
+	timeout     time.Duration
+}
+
+// ollamaMessage is a single chat message in an Ollama request or response
+type ollamaMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+// ollamaOptions holds the model parameters Ollama accepts per request
+type ollamaOptions struct {
+	Temperature float64 `json:"temperature"`
+	NumPredict  int     `json:"num_predict,omitempty"`
+}
+
+// ollamaChatRequest is the body sent to the Ollama chat endpoint
+type ollamaChatRequest struct {
+	Model    string          `json:"model"`
+	Messages []ollamaMessage `json:"messages"`
+	Stream   bool            `json:"stream"`
+	Options  ollamaOptions   `json:"options"`
+}
+
+// ollamaChatResponse is a reply, or one streamed chunk of a reply, from the
+// Ollama chat endpoint
+type ollamaChatResponse struct {
+	Model   string        `json:"model"`
+	Message ollamaMessage `json:"message"`
+	Done    bool          `json:"done"`
+	Error   string        `json:"error,omitempty"`
+
+	// Token counts, reported on the final message
+	PromptEvalCount int `json:"prompt_eval_count,omitempty"`
+	EvalCount       int `json:"eval_count,omitempty"`
+}
+
+// usage converts the token counts Ollama reports on a final message
+func (r ollamaChatResponse) usage() Usage {
+	return Usage{
+		InputTokens:  r.PromptEvalCount,
+		OutputTokens: r.EvalCount,
+	}
+}
+
+// NewOllamaProvider creates a new Ollama provider. No API key is required.
+func NewOllamaProvider(config AIConfig) (*OllamaProvider, error) {
+	baseURL := config.BaseURL
+	if baseURL == "" {
+		baseURL = defaultOllamaURL
+	}
+
+	model := config.Model
+	if model == "" {
+		model = "llama3"
+	}
+
+	maxTokens := config.MaxTokens
+	if maxTokens == 0 {
+		maxTokens = 1000
+	}
+
+	temperature := config.Temperature
+	if temperature == 0 {
+		temperature = 0.7
+	}
+
+	timeout := config.Timeout
+	if timeout == 0 {
+		timeout = 60 * time.Second
+	}
+
+	return &OllamaProvider{
+		client:      &http.Client{},
+		baseURL:     baseURL,
+		model:       model,
+		maxTokens:   maxTokens,
+		temperature: temperature,
+		timeout:     timeout,
+	}, nil
+}
+
+// GenerateGMResponse generates a Game Master response using Ollama
+func (o *OllamaProvider) GenerateGMResponse(ctx context.Context, prompt string) (string, Usage, error) {
+	return o.chat(ctx, gmSystemPrompt, prompt, o.maxTokens, o.temperature)
+}
+
+// GenerateStructuredGMResponse generates a Game Master turn as JSON using Ollama
+func (o *OllamaProvider) GenerateStructuredGMResponse(ctx context.Context, prompt string) (string, Usage, error) {
+	return o.chat(ctx, gmSystemPrompt+structuredGMInstruction, prompt, o.maxTokens, o.temperature)
+}
+
+// GenerateNPCDialogue generates NPC dialogue using Ollama
+func (o *OllamaProvider) GenerateNPCDialogue(ctx context.Context, npcName, personality, prompt string) (string, Usage, error) {
+	// Shorter, slightly more creative responses for NPCs
+	return o.chat(ctx, npcSystemPrompt(npcName, personality), prompt, o.maxTokens/2, o.temperature+0.1)
+}
+
+// GenerateSceneDescription generates scene descriptions using Ollama
+func (o *OllamaProvider) GenerateSceneDescription(ctx context.Context, location, contextInfo, mood string) (string, Usage, error) {
+	return o.chat(ctx, sceneSystemPrompt, scenePrompt(location, contextInfo, mood), o.maxTokens/2, o.temperature+0.2)
+}
+
+// GetProviderName returns the provider name
+func (o *OllamaProvider) GetProviderName() string {
+	return "ollama"
+}
+
+// StreamGMResponse streams a Game Master response from Ollama, passing each
+// partial message to onChunk
+func (o *OllamaProvider) StreamGMResponse(ctx context.Context, prompt string, onChunk func(string)) (string, Usage, error) {
+	opts := GenOptsFromContext(ctx)
+	ctx, cancel := context.WithTimeout(ctx, o.timeout)
+	defer cancel()
+
+	resp, err := o.send(ctx, o.newChatRequest(gmSystemPrompt, prompt, opts.maxTokens(o.maxTokens), opts.temperature(o.temperature), true))
+	if err != nil {
+		return "", Usage{}, err
+	}
+	defer resp.Body.Close()
+
+	// Ollama streams one JSON object per line until done is set
+	var response strings.Builder
+	var usage Usage
+	decoder := json.NewDecoder(resp.Body)
+	for {
+		var chunk ollamaChatResponse
+		if err := decoder.Decode(&chunk); err != nil {
+			if err == io.EOF {
+				break
+			}
+			return "", Usage{}, fmt.Errorf("failed to parse Ollama stream: %w", err)
+		}
+		if chunk.Error != "" {
+			return "", Usage{}, fmt.Errorf("Ollama API error: %s", chunk.Error)
+		}
+		if chunk.Message.Content != "" {
+			response.WriteString(chunk.Message.Content)
+			onChunk(chunk.Message.Content)
+		}
+		if chunk.Done {
+			usage = chunk.usage()
+			break
+		}
+	}
+
+	if response.Len() == 0 {
+		return "", Usage{}, fmt.Errorf("empty response from Ollama")
+	}
+
+	return response.String(), usage, nil
+}
+
+// chat sends a single system/user exchange to Ollama and returns the reply.
+// Overrides attached to ctx replace maxTokens and temperature.
+func (o *OllamaProvider) chat(ctx context.Context, systemPrompt, prompt string, maxTokens int, temperature float64) (string, Usage, error) {
+	opts := GenOptsFromContext(ctx)
+	ctx, cancel := context.WithTimeout(ctx, o.timeout)
+	defer cancel()
+
+	resp, err := o.send(ctx, o.newChatRequest(systemPrompt, prompt, opts.maxTokens(maxTokens), opts.temperature(temperature), false))
+	if err != nil {
+		return "", Usage{}, err
+	}
+	defer resp.Body.Close()
+
+	var chatResp ollamaChatResponse
+	if err := json.NewDecoder(resp.Body).Decode(&chatResp); err != nil {
+		return "", Usage{}, fmt.Errorf("failed to parse Ollama response: %w", err)
+	}
+
+	if chatResp.Message.Content == "" {
+		return "", Usage{}, fmt.Errorf("empty response from Ollama")
+	}
+
+	return chatResp.Message.Content, chatResp.usage(), nil
+}
+
+// newChatRequest builds a chat request for a single system/user exchange
+func (o *OllamaProvider) newChatRequest(systemPrompt, prompt string, maxTokens int, temperature float64, stream bool) ollamaChatRequest {
+	return ollamaChatRequest{
+		Model: o.model,
+		Messages: []ollamaMessage{
+			{Role: "system", Content: systemPrompt},
+			{Role: "user", Content: prompt},
+		},
+		Stream: stream,
+		Options: ollamaOptions{
+			Temperature: temperature,
+			NumPredict:  maxTokens,
+		},
+	}
+}
+
+// send posts a chat request to Ollama and returns the response once its
+// status has been checked. The caller must close the response body.
+func (o *OllamaProvider) send(ctx context.Context, chatReq ollamaChatRequest) (*http.Response, error) {
+	body, err := json.Marshal(chatReq)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal Ollama request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, o.baseURL, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Ollama request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := o.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("Ollama API error: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+
+		var errResp ollamaChatResponse
+		if json.NewDecoder(resp.Body).Decode(&errResp) == nil && errResp.Error != "" {
+			return nil, fmt.Errorf("Ollama API error (%d): %s", resp.StatusCode, errResp.Error)
+		}
+		return nil, fmt.Errorf("Ollama API error: status %d", resp.StatusCode)
+	}
+
+	return resp, nil
+}
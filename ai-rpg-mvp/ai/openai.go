@@ -1,6 +1,7 @@
 package ai
 
 import (
+	"context"
 	"fmt"
 	"time"
 )
@@ -31,22 +32,38 @@ func NewOpenAIProvider(config AIConfig) (*OpenAIProvider, error) {
 }
 
 // GenerateGMResponse generates a Game Master response using OpenAI
-func (o *OpenAIProvider) GenerateGMResponse(prompt string) (string, error) {
+func (o *OpenAIProvider) GenerateGMResponse(ctx context.Context, prompt string) (string, Usage, error) {
 	// TODO: Implement OpenAI API integration
 	// This is a placeholder - you would integrate with OpenAI's Go SDK here
-	return "OpenAI integration not yet implemented. Please use Claude provider.", nil
+	return "OpenAI integration not yet implemented. Please use Claude provider.", Usage{}, nil
+}
+
+// StreamGMResponse falls back to a single chunk until streaming is implemented
+func (o *OpenAIProvider) StreamGMResponse(ctx context.Context, prompt string, onChunk func(string)) (string, Usage, error) {
+	response, usage, err := o.GenerateGMResponse(ctx, prompt)
+	if err != nil {
+		return "", Usage{}, err
+	}
+	onChunk(response)
+	return response, usage, nil
+}
+
+// GenerateStructuredGMResponse generates a Game Master turn as JSON using OpenAI
+func (o *OpenAIProvider) GenerateStructuredGMResponse(ctx context.Context, prompt string) (string, Usage, error) {
+	// TODO: Implement OpenAI API integration
+	return `{"narration": "OpenAI integration not yet implemented. Please use Claude provider.", "suggested_consequences": [], "choices": []}`, Usage{}, nil
 }
 
 // GenerateNPCDialogue generates NPC dialogue using OpenAI
-func (o *OpenAIProvider) GenerateNPCDialogue(npcName, personality, prompt string) (string, error) {
+func (o *OpenAIProvider) GenerateNPCDialogue(ctx context.Context, npcName, personality, prompt string) (string, Usage, error) {
 	// TODO: Implement OpenAI API integration
-	return fmt.Sprintf("[%s]: OpenAI integration not yet implemented.", npcName), nil
+	return fmt.Sprintf("[%s]: OpenAI integration not yet implemented.", npcName), Usage{}, nil
 }
 
 // GenerateSceneDescription generates scene descriptions using OpenAI
-func (o *OpenAIProvider) GenerateSceneDescription(location, contextInfo, mood string) (string, error) {
+func (o *OpenAIProvider) GenerateSceneDescription(ctx context.Context, location, contextInfo, mood string) (string, Usage, error) {
 	// TODO: Implement OpenAI API integration
-	return fmt.Sprintf("A %s scene at %s (OpenAI integration pending)", mood, location), nil
+	return fmt.Sprintf("A %s scene at %s (OpenAI integration pending)", mood, location), Usage{}, nil
 }
 
 // GetProviderName returns the provider name
@@ -0,0 +1,77 @@
+package ai
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+)
+
+// GenOpts overrides the configured sampling settings for a single request,
+// such as a higher temperature for scene descriptions or zero for combat
+// adjudication. Nil fields keep the provider's usual value.
+type GenOpts struct {
+	Temperature *float64
+	MaxTokens   *int
+}
+
+// validate rejects overrides no provider accepts
+func (o GenOpts) validate() error {
+	if o.Temperature != nil && *o.Temperature < 0 {
+		return fmt.Errorf("temperature override must not be negative, got %g", *o.Temperature)
+	}
+	if o.MaxTokens != nil && *o.MaxTokens <= 0 {
+		return fmt.Errorf("max tokens override must be positive, got %d", *o.MaxTokens)
+	}
+	return nil
+}
+
+// temperature returns the override, or def when there isn't one
+func (o GenOpts) temperature(def float64) float64 {
+	if o.Temperature != nil {
+		return *o.Temperature
+	}
+	return def
+}
+
+// maxTokens returns the override, or def when there isn't one
+func (o GenOpts) maxTokens(def int) int {
+	if o.MaxTokens != nil {
+		return *o.MaxTokens
+	}
+	return def
+}
+
+// cacheKeyPart describes the overrides for a cache key, so replies generated
+// with different settings never share an entry. It's empty without overrides,
+// leaving keys for plain requests unchanged.
+func (o GenOpts) cacheKeyPart() string {
+	part := ""
+	if o.Temperature != nil {
+		part += "t=" + strconv.FormatFloat(*o.Temperature, 'f', -1, 64)
+	}
+	if o.MaxTokens != nil {
+		if part != "" {
+			part += ","
+		}
+		part += "max=" + strconv.Itoa(*o.MaxTokens)
+	}
+	return part
+}
+
+type genOptsKey struct{}
+
+// withGenOpts attaches opts to ctx so the provider handling the request
+// applies them
+func withGenOpts(ctx context.Context, opts GenOpts) context.Context {
+	if opts.Temperature == nil && opts.MaxTokens == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, genOptsKey{}, opts)
+}
+
+// GenOptsFromContext returns the overrides attached to a request's context.
+// Providers apply them on top of their own settings.
+func GenOptsFromContext(ctx context.Context) GenOpts {
+	opts, _ := ctx.Value(genOptsKey{}).(GenOpts)
+	return opts
+}
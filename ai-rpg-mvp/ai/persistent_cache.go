@@ -0,0 +1,163 @@
+package ai
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// cacheFlushDelay debounces disk writes so bursts of Set calls share one flush
+const cacheFlushDelay = 2 * time.Second
+
+// persistedEntry is the on-disk form of a cache entry
+type persistedEntry struct {
+	Value     string    `json:"value"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// NewPersistentResponseCache creates a response cache backed by a JSON file.
+// Unexpired entries are loaded from path on startup; a missing or corrupt
+// file starts the cache empty. Entries are written back shortly after each
+// Set and on Close.
+func NewPersistentResponseCache(ttl time.Duration, maxEntries int, path string) *ResponseCache {
+	return newPersistentResponseCache(ttl, maxEntries, path, slog.Default())
+}
+
+// newPersistentResponseCache creates a persistent cache that reports load and
+// flush failures to logger
+func newPersistentResponseCache(ttl time.Duration, maxEntries int, path string, logger *slog.Logger) *ResponseCache {
+	cache := NewResponseCache(ttl, maxEntries)
+	cache.path = path
+	cache.logger = logger
+
+	if err := cache.load(); err != nil {
+		logger.Warn("Starting with empty response cache", "path", path, "error", err)
+	}
+
+	return cache
+}
+
+// Close stops background cleanup and writes any pending entries to disk
+func (rc *ResponseCache) Close() error {
+	var err error
+	rc.closeOnce.Do(func() {
+		close(rc.done)
+
+		rc.mutex.Lock()
+		if rc.flushTimer != nil {
+			rc.flushTimer.Stop()
+			rc.flushTimer = nil
+		}
+		rc.mutex.Unlock()
+
+		err = rc.flush()
+	})
+	return err
+}
+
+// load reads unexpired entries from the cache file
+func (rc *ResponseCache) load() error {
+	data, err := os.ReadFile(rc.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return fmt.Errorf("failed to read cache file: %w", err)
+	}
+
+	var entries map[string]persistedEntry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return fmt.Errorf("failed to parse cache file: %w", err)
+	}
+
+	// Insert oldest first so the most recent entries start as most recently used
+	keys := make([]string, 0, len(entries))
+	for key := range entries {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return entries[keys[i]].Timestamp.Before(entries[keys[j]].Timestamp)
+	})
+
+	rc.mutex.Lock()
+	defer rc.mutex.Unlock()
+
+	now := time.Now()
+	for _, key := range keys {
+		entry := entries[key]
+		if now.Sub(entry.Timestamp) > rc.ttl {
+			continue
+		}
+		rc.put(key, entry.Value, entry.Timestamp)
+	}
+
+	return nil
+}
+
+// scheduleFlush arranges a debounced write to disk. The caller must hold the mutex.
+func (rc *ResponseCache) scheduleFlush() {
+	if rc.path == "" || rc.flushTimer != nil {
+		return
+	}
+
+	rc.flushTimer = time.AfterFunc(cacheFlushDelay, func() {
+		rc.mutex.Lock()
+		rc.flushTimer = nil
+		rc.mutex.Unlock()
+
+		if err := rc.flush(); err != nil {
+			rc.logger.Error("Failed to flush response cache", "path", rc.path, "error", err)
+		}
+	})
+}
+
+// flush writes unexpired entries to the cache file, replacing it atomically
+func (rc *ResponseCache) flush() error {
+	if rc.path == "" {
+		return nil
+	}
+
+	rc.mutex.RLock()
+	entries := make(map[string]persistedEntry, len(rc.cache))
+	now := time.Now()
+	for _, element := range rc.cache {
+		entry := element.Value.(*cacheEntry)
+		if now.Sub(entry.timestamp) > rc.ttl {
+			continue
+		}
+		entries[entry.key] = persistedEntry{
+			Value:     entry.value,
+			Timestamp: entry.timestamp,
+		}
+	}
+	rc.mutex.RUnlock()
+
+	data, err := json.Marshal(entries)
+	if err != nil {
+		return fmt.Errorf("failed to marshal cache: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(rc.path), filepath.Base(rc.path)+".tmp*")
+	if err != nil {
+		return fmt.Errorf("failed to create cache file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write cache file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write cache file: %w", err)
+	}
+
+	if err := os.Rename(tmp.Name(), rc.path); err != nil {
+		return fmt.Errorf("failed to replace cache file: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,179 @@
+package ai
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	gamecontext "ai-rpg-mvp/context"
+)
+
+// gmSystemPrompt instructs the model to act as the Game Master
+const gmSystemPrompt = `You are an expert AI Game Master running a fantasy RPG session. Your role:
+
+PERSONALITY: Helpful yet challenging guide who creates immersive experiences
+TONE: Descriptive, engaging, appropriate to fantasy setting  
+GOALS: Player agency, narrative flow, consistent world-building
+
+RESPONSE GUIDELINES:
+- Always respond in character as the GM
+- Maintain world consistency across interactions
+- React contextually to player actions and equipment  
+- Balance guidance with player discovery
+- Generate consequences for player choices
+- Keep responses engaging and immersive (2-4 sentences)
+- End with a clear situation that allows player response
+
+Current game situation requires your response as Game Master.`
+
+// structuredGMInstruction is appended to the GM system prompt when the reply
+// must be machine-readable
+const structuredGMInstruction = `
+
+OUTPUT FORMAT:
+Respond with a single JSON object and nothing else: no prose, no markdown fences.
+The object must match this schema exactly:
+{
+  "narration": string,                  // your response to the player, as you would normally write it
+  "suggested_consequences": [string],   // state changes you suggest, e.g. "reputation_increase", "health_damage", "item_gained"
+  "choices": [string]                   // 2-4 short options the player could take next
+}`
+
+// repairPrompt asks the model to turn an invalid reply into valid JSON
+func repairPrompt(invalid string, parseErr error) string {
+	return fmt.Sprintf(`Your previous reply was not valid JSON (%v):
+
+%s
+
+Reply again with only the corrected JSON object.`, parseErr, invalid)
+}
+
+// sceneSystemPrompt instructs the model to write scene descriptions
+const sceneSystemPrompt = `You are a skilled fantasy writer creating immersive scene descriptions for an RPG.
+
+DESCRIPTION GUIDELINES:
+- Create vivid, atmospheric descriptions that set the mood
+- Include sensory details (sight, sound, smell, feel)
+- Match the tone and mood of the situation
+- Keep descriptions concise but evocative (2-3 sentences)
+- Focus on elements that enhance gameplay and immersion
+- Include details that suggest possible interactions or discoveries
+- Maintain consistency with fantasy RPG conventions
+
+Create an engaging scene description based on the provided context.`
+
+// npcSystemPrompt instructs the model to speak as a specific NPC
+func npcSystemPrompt(npcName, personality string) string {
+	return fmt.Sprintf(`You are %s, an NPC in a fantasy RPG world.
+
+PERSONALITY TRAITS: %s
+
+DIALOGUE GUIDELINES:
+- Stay in character as %s at all times
+- Speak naturally and authentically for this character
+- Reference your personality and background
+- Respond appropriately to the player's actions and reputation
+- Keep dialogue concise but meaningful (1-3 sentences)
+- Include personality quirks or speech patterns
+- Consider your relationship with the player
+
+Respond as %s would naturally speak in this situation.`,
+		npcName, personality, npcName, npcName)
+}
+
+// scenePrompt builds the user prompt for a scene description
+func scenePrompt(location, contextInfo, mood string) string {
+	return fmt.Sprintf(`Location: %s
+Context: %s
+Mood/Atmosphere: %s
+
+Describe this scene:`, location, contextInfo, mood)
+}
+
+// summaryPrompt asks the model to fold past events into a story summary
+func summaryPrompt(prior string, events []string) string {
+	if prior == "" {
+		prior = "(nothing yet)"
+	}
+	return fmt.Sprintf(`Do not narrate a new scene. Instead, act as the campaign's chronicler.
+
+STORY SO FAR:
+%s
+
+EVENTS TO ADD:
+%s
+
+Rewrite the story so far to include these events, in past tense and at most 8 sentences.
+Keep names, places, promises, debts and unresolved threads; drop routine detail.
+Reply with only the updated summary.`, prior, strings.Join(events, "\n"))
+}
+
+// factExtractionPrompt asks the model which facts an NPC learned about the
+// player from an exchange
+func factExtractionPrompt(npcName string, knownFacts []string, exchange string) string {
+	known := "- (nothing yet)"
+	if len(knownFacts) > 0 {
+		known = "- " + strings.Join(knownFacts, "\n- ")
+	}
+	return fmt.Sprintf(`Do not narrate. Instead, read this exchange and decide what %s has now learned about the player.
+
+WHAT %s ALREADY KNOWS:
+%s
+
+EXCHANGE:
+%s
+
+List only new, lasting facts about the player, each a short sentence, e.g. "Is searching for their lost brother".
+Reply with only a JSON array of strings, such as ["fact one", "fact two"], or [] if nothing new was learned.`,
+		npcName, strings.ToUpper(npcName), known, exchange)
+}
+
+// commandInterpretationPrompt asks the AI to map a player's free text to one
+// of the game's commands
+func commandInterpretationPrompt(freeText string, grounding gamecontext.CommandGrounding) string {
+	exits := "- (none known)"
+	if len(grounding.Exits) > 0 {
+		exits = "- " + strings.Join(grounding.Exits, "\n- ")
+	}
+
+	return fmt.Sprintf(`Do not narrate. Instead, decide which game command the player meant.
+
+PLAYER TYPED: %q
+
+COMMAND VERBS: %s
+CURRENT LOCATION: %s
+
+EXITS:
+%s
+
+NPCS (id: name):
+%s
+
+INVENTORY (id: name):
+%s
+
+Pick the verb that fits best, and the id of what the command is aimed at from the lists above, or a short snake_case name if it isn't listed.
+Reply with only a JSON object: {"verb": "...", "actionType": "...", "target": "..."}
+actionType is one of examine, social, combat, move, or another single word if none fits.`,
+		freeText, strings.Join(grounding.Verbs, ", "), grounding.Location, exits,
+		formatGroundingEntities(grounding.NPCs), formatGroundingEntities(grounding.Inventory))
+}
+
+// formatGroundingEntities lists id: name pairs sorted by id
+func formatGroundingEntities(entities map[string]string) string {
+	if len(entities) == 0 {
+		return "- (none)"
+	}
+
+	ids := make([]string, 0, len(entities))
+	for id := range entities {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	lines := make([]string, len(ids))
+	for i, id := range ids {
+		lines[i] = fmt.Sprintf("- %s: %s", id, entities[id])
+	}
+	return strings.Join(lines, "\n")
+}
@@ -1,64 +1,127 @@
 package ai
 
 import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
-	"log"
+	"log/slog"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 )
 
 // AIProvider defines the interface for AI services
 type AIProvider interface {
-	GenerateGMResponse(prompt string) (string, error)
-	GenerateNPCDialogue(npcName, personality, prompt string) (string, error)
-	GenerateSceneDescription(location, context, mood string) (string, error)
+	GenerateGMResponse(ctx context.Context, prompt string) (string, Usage, error)
+	StreamGMResponse(ctx context.Context, prompt string, onChunk func(string)) (string, Usage, error)
+	GenerateStructuredGMResponse(ctx context.Context, prompt string) (string, Usage, error) // raw JSON text, see GMTurn
+	GenerateNPCDialogue(ctx context.Context, npcName, personality, prompt string) (string, Usage, error)
+	GenerateSceneDescription(ctx context.Context, location, contextInfo, mood string) (string, Usage, error)
 	GetProviderName() string
 }
 
 // AIService manages AI providers and handles requests
 type AIService struct {
-	provider    AIProvider
-	rateLimiter *RateLimiter
-	cache       *ResponseCache
-	config      AIConfig
+	provider       AIProvider
+	rateLimiter    *RateLimiter
+	sessionLimiter *SessionRateLimiter // nil unless per-session limits are configured
+	cache          *ResponseCache
+	usage          *UsageTracker
+	metrics        Metrics // optional, see SetMetrics
+	logger         *slog.Logger
+	config         AIConfig
+
+	// Cache keys each session produced, for InvalidateSessionCache
+	sessionKeys      map[string]map[string]struct{}
+	sessionKeysMutex sync.Mutex
+
+	// Retry backoff hooks; time.Sleep and rand.Float64 unless replaced
+	sleep  func(time.Duration)
+	random func() float64
 }
 
 // AIConfig holds configuration for AI service
 type AIConfig struct {
 	Provider          string
 	APIKey            string
+	BaseURL           string // endpoint override, used by local providers such as Ollama
 	Model             string
 	MaxTokens         int
 	Temperature       float64
 	Timeout           time.Duration
 	MaxRetries        int
-	RetryDelay        time.Duration
+	RetryDelay        time.Duration // first backoff; doubles on each retry
+	RetryMaxDelay     time.Duration // backoff cap; 0 leaves it uncapped
 	EnableCaching     bool
 	CacheTTL          time.Duration
+	CachePath         string // optional file that keeps cached responses across restarts
+	CacheMaxEntries   int    // 0 means unlimited
 	RateLimitRequests int
 	RateLimitDuration time.Duration
+	CostPer1KInput    float64          // dollars per 1000 input tokens, for cost estimates
+	CostPer1KOutput   float64          // dollars per 1000 output tokens, for cost estimates
+	Fallbacks         []ProviderConfig // providers to try, in order, when the primary fails
+
+	// Per-session limits apply on top of the global one to requests made
+	// through the ...ForSession methods; a session's bucket is dropped after
+	// PerSessionRateLimitIdle without requests
+	PerSessionRateLimitRequests int
+	PerSessionRateLimitDuration time.Duration
+	PerSessionRateLimitIdle     time.Duration
+
+	// Embeddings let the game recall past actions by meaning; an empty
+	// EmbeddingProvider disables them. The key falls back to APIKey.
+	EmbeddingProvider string
+	EmbeddingModel    string
+	EmbeddingBaseURL  string
+	EmbeddingAPIKey   string
+
+	Logger *slog.Logger // nil uses slog.Default()
 }
 
 // NewAIService creates a new AI service with the specified provider
 func NewAIService(config AIConfig) (*AIService, error) {
-	var provider AIProvider
-	var err error
-
-	switch strings.ToLower(config.Provider) {
-	case "claude", "anthropic":
-		provider, err = NewClaudeProvider(config)
-	case "openai":
-		provider, err = NewOpenAIProvider(config)
-	default:
-		return nil, fmt.Errorf("unsupported AI provider: %s", config.Provider)
+	logger := config.Logger
+	if logger == nil {
+		logger = slog.Default()
 	}
 
+	provider, err := newProvider(config)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create AI provider: %w", err)
+		return nil, err
+	}
+
+	// Wrap the primary in a fallback chain when backups are configured
+	if len(config.Fallbacks) > 0 {
+		providers := []AIProvider{provider}
+		for _, fallback := range config.Fallbacks {
+			fallbackConfig := config
+			fallbackConfig.Provider = fallback.Provider
+			fallbackConfig.APIKey = fallback.APIKey
+			fallbackConfig.BaseURL = fallback.BaseURL
+			fallbackConfig.Model = fallback.Model
+
+			fallbackProvider, err := newProvider(fallbackConfig)
+			if err != nil {
+				return nil, fmt.Errorf("fallback %s: %w", fallback.Provider, err)
+			}
+			providers = append(providers, fallbackProvider)
+		}
+
+		chain, err := NewFallbackProvider(providers...)
+		if err != nil {
+			return nil, err
+		}
+		chain.logger = logger
+		provider = chain
 	}
 
 	service := &AIService{
 		provider: provider,
+		usage:    NewUsageTracker(config.CostPer1KInput, config.CostPer1KOutput),
+		logger:   logger,
 		config:   config,
 	}
 
@@ -66,36 +129,106 @@ func NewAIService(config AIConfig) (*AIService, error) {
 	if config.RateLimitRequests > 0 {
 		service.rateLimiter = NewRateLimiter(config.RateLimitRequests, config.RateLimitDuration)
 	}
+	if config.PerSessionRateLimitRequests > 0 {
+		idle := config.PerSessionRateLimitIdle
+		if idle <= 0 {
+			idle = DefaultSessionLimiterIdle
+		}
+		service.sessionLimiter = NewSessionRateLimiter(config.PerSessionRateLimitRequests, config.PerSessionRateLimitDuration, idle)
+	}
 
 	// Initialize cache
 	if config.EnableCaching {
-		service.cache = NewResponseCache(config.CacheTTL)
+		if config.CachePath != "" {
+			service.cache = newPersistentResponseCache(config.CacheTTL, config.CacheMaxEntries, config.CachePath, logger)
+		} else {
+			service.cache = NewResponseCache(config.CacheTTL, config.CacheMaxEntries)
+		}
 	}
 
 	return service, nil
 }
 
+// newProvider creates the provider named in the config
+func newProvider(config AIConfig) (AIProvider, error) {
+	var provider AIProvider
+	var err error
+
+	switch strings.ToLower(config.Provider) {
+	case "claude", "anthropic":
+		provider, err = NewClaudeProvider(config)
+	case "openai":
+		provider, err = NewOpenAIProvider(config)
+	case "ollama":
+		provider, err = NewOllamaProvider(config)
+	case "gemini", "google":
+		provider, err = NewGeminiProvider(config)
+	case "mock":
+		provider, err = NewMockProvider(config)
+	default:
+		return nil, fmt.Errorf("unsupported AI provider: %s", config.Provider)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to create AI provider: %w", err)
+	}
+	return provider, nil
+}
+
 // GenerateGMResponse generates a Game Master response
 func (s *AIService) GenerateGMResponse(prompt string) (string, error) {
-	cacheKey := fmt.Sprintf("gm:%s", hashString(prompt))
+	return s.GenerateGMResponseForSessionCtx(context.Background(), "", prompt)
+}
+
+// GenerateGMResponseCtx generates a Game Master response, giving up when ctx
+// is cancelled
+func (s *AIService) GenerateGMResponseCtx(ctx context.Context, prompt string) (string, error) {
+	return s.GenerateGMResponseForSessionCtx(ctx, "", prompt)
+}
+
+// GenerateGMResponseForSession generates a Game Master response, counting it
+// against the session's own rate limit
+func (s *AIService) GenerateGMResponseForSession(sessionID, prompt string) (string, error) {
+	return s.GenerateGMResponseForSessionCtx(context.Background(), sessionID, prompt)
+}
+
+// GenerateGMResponseForSessionCtx generates a Game Master response for a
+// session, giving up when ctx is cancelled
+func (s *AIService) GenerateGMResponseForSessionCtx(ctx context.Context, sessionID, prompt string) (string, error) {
+	return s.GenerateGMResponseForSessionWithOpts(ctx, sessionID, prompt, GenOpts{})
+}
+
+// GenerateGMResponseWithOpts generates a Game Master response with the
+// configured temperature or max tokens overridden by opts
+func (s *AIService) GenerateGMResponseWithOpts(prompt string, opts GenOpts) (string, error) {
+	return s.GenerateGMResponseForSessionWithOpts(context.Background(), "", prompt, opts)
+}
+
+// GenerateGMResponseForSessionWithOpts generates a Game Master response for a
+// session with opts overriding the configured settings, giving up when ctx is
+// cancelled
+func (s *AIService) GenerateGMResponseForSessionWithOpts(ctx context.Context, sessionID, prompt string, opts GenOpts) (string, error) {
+	if err := opts.validate(); err != nil {
+		return "", err
+	}
+	ctx = withGenOpts(ctx, opts)
+	cacheKey := s.cacheKey("gm", opts, hashString(prompt))
 
 	// Check cache first
 	if s.cache != nil {
-		if cached := s.cache.Get(cacheKey); cached != "" {
+		if cached := s.cacheGet(sessionID, cacheKey); cached != "" {
 			return cached, nil
 		}
 	}
 
 	// Check rate limit
-	if s.rateLimiter != nil {
-		if !s.rateLimiter.Allow() {
-			return "", fmt.Errorf("rate limit exceeded")
-		}
+	if err := s.checkRateLimit(sessionID); err != nil {
+		return "", err
 	}
 
 	// Generate response with retries
-	response, err := s.generateWithRetry(func() (string, error) {
-		return s.provider.GenerateGMResponse(prompt)
+	response, err := s.generateWithRetry(ctx, func(ctx context.Context) (string, Usage, error) {
+		return s.provider.GenerateGMResponse(ctx, prompt)
 	})
 
 	if err != nil {
@@ -104,7 +237,61 @@ func (s *AIService) GenerateGMResponse(prompt string) (string, error) {
 
 	// Cache response
 	if s.cache != nil {
-		s.cache.Set(cacheKey, response)
+		s.cacheSet(sessionID, cacheKey, response)
+	}
+
+	return response, nil
+}
+
+// GenerateGMResponseStream generates a Game Master response, passing each
+// chunk of text to onChunk as it arrives. The full response is returned and
+// cached the same way as GenerateGMResponse.
+func (s *AIService) GenerateGMResponseStream(prompt string, onChunk func(string)) (string, error) {
+	return s.GenerateGMResponseStreamForSessionCtx(context.Background(), "", prompt, onChunk)
+}
+
+// GenerateGMResponseStreamCtx streams a Game Master response, stopping when
+// ctx is cancelled
+func (s *AIService) GenerateGMResponseStreamCtx(ctx context.Context, prompt string, onChunk func(string)) (string, error) {
+	return s.GenerateGMResponseStreamForSessionCtx(ctx, "", prompt, onChunk)
+}
+
+// GenerateGMResponseStreamForSession streams a Game Master response, counting
+// it against the session's own rate limit
+func (s *AIService) GenerateGMResponseStreamForSession(sessionID, prompt string, onChunk func(string)) (string, error) {
+	return s.GenerateGMResponseStreamForSessionCtx(context.Background(), sessionID, prompt, onChunk)
+}
+
+// GenerateGMResponseStreamForSessionCtx streams a Game Master response for a
+// session, stopping when ctx is cancelled
+func (s *AIService) GenerateGMResponseStreamForSessionCtx(ctx context.Context, sessionID, prompt string, onChunk func(string)) (string, error) {
+	cacheKey := s.cacheKey("gm", GenOpts{}, hashString(prompt))
+
+	// Check cache first, replaying a hit as a single chunk
+	if s.cache != nil {
+		if cached := s.cacheGet(sessionID, cacheKey); cached != "" {
+			onChunk(cached)
+			return cached, nil
+		}
+	}
+
+	// Check rate limit once for the whole stream
+	if err := s.checkRateLimit(sessionID); err != nil {
+		return "", err
+	}
+
+	// Streams aren't retried since chunks may already have reached the caller
+	start := time.Now()
+	response, usage, err := s.provider.StreamGMResponse(ctx, prompt, onChunk)
+	s.observeRequest(start, err)
+	if err != nil {
+		return "", fmt.Errorf("AI stream request failed: %w", err)
+	}
+	s.recordUsage(usage)
+
+	// Cache response
+	if s.cache != nil {
+		s.cacheSet(sessionID, cacheKey, response)
 	}
 
 	return response, nil
@@ -112,25 +299,57 @@ func (s *AIService) GenerateGMResponse(prompt string) (string, error) {
 
 // GenerateNPCDialogue generates NPC dialogue
 func (s *AIService) GenerateNPCDialogue(npcName, personality, prompt string) (string, error) {
-	cacheKey := fmt.Sprintf("npc:%s:%s", npcName, hashString(prompt))
+	return s.GenerateNPCDialogueForSessionCtx(context.Background(), "", npcName, personality, prompt)
+}
+
+// GenerateNPCDialogueCtx generates NPC dialogue, giving up when ctx is cancelled
+func (s *AIService) GenerateNPCDialogueCtx(ctx context.Context, npcName, personality, prompt string) (string, error) {
+	return s.GenerateNPCDialogueForSessionCtx(ctx, "", npcName, personality, prompt)
+}
+
+// GenerateNPCDialogueForSession generates NPC dialogue, counting it against
+// the session's own rate limit
+func (s *AIService) GenerateNPCDialogueForSession(sessionID, npcName, personality, prompt string) (string, error) {
+	return s.GenerateNPCDialogueForSessionCtx(context.Background(), sessionID, npcName, personality, prompt)
+}
+
+// GenerateNPCDialogueForSessionCtx generates NPC dialogue for a session,
+// giving up when ctx is cancelled
+func (s *AIService) GenerateNPCDialogueForSessionCtx(ctx context.Context, sessionID, npcName, personality, prompt string) (string, error) {
+	return s.GenerateNPCDialogueForSessionWithOpts(ctx, sessionID, npcName, personality, prompt, GenOpts{})
+}
+
+// GenerateNPCDialogueWithOpts generates NPC dialogue with the configured
+// temperature or max tokens overridden by opts
+func (s *AIService) GenerateNPCDialogueWithOpts(npcName, personality, prompt string, opts GenOpts) (string, error) {
+	return s.GenerateNPCDialogueForSessionWithOpts(context.Background(), "", npcName, personality, prompt, opts)
+}
+
+// GenerateNPCDialogueForSessionWithOpts generates NPC dialogue for a session
+// with opts overriding the configured settings, giving up when ctx is
+// cancelled
+func (s *AIService) GenerateNPCDialogueForSessionWithOpts(ctx context.Context, sessionID, npcName, personality, prompt string, opts GenOpts) (string, error) {
+	if err := opts.validate(); err != nil {
+		return "", err
+	}
+	ctx = withGenOpts(ctx, opts)
+	cacheKey := s.cacheKey("npc", opts, npcName, hashString(prompt))
 
 	// Check cache first
 	if s.cache != nil {
-		if cached := s.cache.Get(cacheKey); cached != "" {
+		if cached := s.cacheGet(sessionID, cacheKey); cached != "" {
 			return cached, nil
 		}
 	}
 
 	// Check rate limit
-	if s.rateLimiter != nil {
-		if !s.rateLimiter.Allow() {
-			return "", fmt.Errorf("rate limit exceeded")
-		}
+	if err := s.checkRateLimit(sessionID); err != nil {
+		return "", err
 	}
 
 	// Generate response with retries
-	response, err := s.generateWithRetry(func() (string, error) {
-		return s.provider.GenerateNPCDialogue(npcName, personality, prompt)
+	response, err := s.generateWithRetry(ctx, func(ctx context.Context) (string, Usage, error) {
+		return s.provider.GenerateNPCDialogue(ctx, npcName, personality, prompt)
 	})
 
 	if err != nil {
@@ -139,7 +358,7 @@ func (s *AIService) GenerateNPCDialogue(npcName, personality, prompt string) (st
 
 	// Cache response
 	if s.cache != nil {
-		s.cache.Set(cacheKey, response)
+		s.cacheSet(sessionID, cacheKey, response)
 	}
 
 	return response, nil
@@ -147,25 +366,58 @@ func (s *AIService) GenerateNPCDialogue(npcName, personality, prompt string) (st
 
 // GenerateSceneDescription generates scene descriptions
 func (s *AIService) GenerateSceneDescription(location, contextInfo, mood string) (string, error) {
-	cacheKey := fmt.Sprintf("scene:%s:%s:%s", location, mood, hashString(contextInfo))
+	return s.GenerateSceneDescriptionForSessionCtx(context.Background(), "", location, contextInfo, mood)
+}
+
+// GenerateSceneDescriptionCtx generates a scene description, giving up when
+// ctx is cancelled
+func (s *AIService) GenerateSceneDescriptionCtx(ctx context.Context, location, contextInfo, mood string) (string, error) {
+	return s.GenerateSceneDescriptionForSessionCtx(ctx, "", location, contextInfo, mood)
+}
+
+// GenerateSceneDescriptionForSession generates a scene description, counting
+// it against the session's own rate limit
+func (s *AIService) GenerateSceneDescriptionForSession(sessionID, location, contextInfo, mood string) (string, error) {
+	return s.GenerateSceneDescriptionForSessionCtx(context.Background(), sessionID, location, contextInfo, mood)
+}
+
+// GenerateSceneDescriptionForSessionCtx generates a scene description for a
+// session, giving up when ctx is cancelled
+func (s *AIService) GenerateSceneDescriptionForSessionCtx(ctx context.Context, sessionID, location, contextInfo, mood string) (string, error) {
+	return s.GenerateSceneDescriptionForSessionWithOpts(ctx, sessionID, location, contextInfo, mood, GenOpts{})
+}
+
+// GenerateSceneDescriptionWithOpts generates a scene description with the
+// configured temperature or max tokens overridden by opts
+func (s *AIService) GenerateSceneDescriptionWithOpts(location, contextInfo, mood string, opts GenOpts) (string, error) {
+	return s.GenerateSceneDescriptionForSessionWithOpts(context.Background(), "", location, contextInfo, mood, opts)
+}
+
+// GenerateSceneDescriptionForSessionWithOpts generates a scene description for
+// a session with opts overriding the configured settings, giving up when ctx
+// is cancelled
+func (s *AIService) GenerateSceneDescriptionForSessionWithOpts(ctx context.Context, sessionID, location, contextInfo, mood string, opts GenOpts) (string, error) {
+	if err := opts.validate(); err != nil {
+		return "", err
+	}
+	ctx = withGenOpts(ctx, opts)
+	cacheKey := s.cacheKey("scene", opts, location, mood, hashString(contextInfo))
 
 	// Check cache first
 	if s.cache != nil {
-		if cached := s.cache.Get(cacheKey); cached != "" {
+		if cached := s.cacheGet(sessionID, cacheKey); cached != "" {
 			return cached, nil
 		}
 	}
 
 	// Check rate limit
-	if s.rateLimiter != nil {
-		if !s.rateLimiter.Allow() {
-			return "", fmt.Errorf("rate limit exceeded")
-		}
+	if err := s.checkRateLimit(sessionID); err != nil {
+		return "", err
 	}
 
 	// Generate response with retries
-	response, err := s.generateWithRetry(func() (string, error) {
-		return s.provider.GenerateSceneDescription(location, contextInfo, mood)
+	response, err := s.generateWithRetry(ctx, func(ctx context.Context) (string, Usage, error) {
+		return s.provider.GenerateSceneDescription(ctx, location, contextInfo, mood)
 	})
 
 	if err != nil {
@@ -174,29 +426,61 @@ func (s *AIService) GenerateSceneDescription(location, contextInfo, mood string)
 
 	// Cache response
 	if s.cache != nil {
-		s.cache.Set(cacheKey, response)
+		s.cacheSet(sessionID, cacheKey, response)
 	}
 
 	return response, nil
 }
 
-// generateWithRetry executes a function with retry logic
-func (s *AIService) generateWithRetry(fn func() (string, error)) (string, error) {
+// checkRateLimit applies the global rate limit and, when sessionID is set,
+// the session's own limit
+func (s *AIService) checkRateLimit(sessionID string) error {
+	if s.rateLimiter != nil {
+		if !s.rateLimiter.Allow() {
+			s.rateLimited()
+			return fmt.Errorf("rate limit exceeded")
+		}
+	}
+
+	if s.sessionLimiter != nil && sessionID != "" {
+		if !s.sessionLimiter.Allow(sessionID) {
+			s.rateLimited()
+			return fmt.Errorf("rate limit exceeded for session %s", sessionID)
+		}
+	}
+
+	return nil
+}
+
+// generateWithRetry executes a function with retry logic, recording the
+// token usage of the successful attempt. Once ctx is done nothing more is
+// attempted and its error is returned.
+func (s *AIService) generateWithRetry(ctx context.Context, fn func(context.Context) (string, Usage, error)) (string, error) {
 	var lastErr error
 
 	for attempt := 0; attempt <= s.config.MaxRetries; attempt++ {
 		if attempt > 0 {
-			time.Sleep(s.config.RetryDelay * time.Duration(attempt))
-			log.Printf("AI request retry attempt %d/%d", attempt, s.config.MaxRetries)
+			if err := s.waitToRetry(ctx, attempt, lastErr); err != nil {
+				return "", fmt.Errorf("AI request abandoned: %w", err)
+			}
+			s.log().Info("Retrying AI request", "attempt", attempt, "max_retries", s.config.MaxRetries, "error", lastErr)
 		}
 
-		response, err := fn()
+		start := time.Now()
+		response, usage, err := fn(ctx)
+		s.observeRequest(start, err)
 		if err == nil {
+			s.recordUsage(usage)
 			return response, nil
 		}
 
 		lastErr = err
 
+		// A cancelled caller no longer wants the answer
+		if ctx.Err() != nil {
+			return "", fmt.Errorf("AI request abandoned: %w", ctx.Err())
+		}
+
 		// Don't retry on certain errors (rate limit, invalid key, etc.)
 		if isNonRetryableError(err) {
 			break
@@ -206,11 +490,32 @@ func (s *AIService) generateWithRetry(fn func() (string, error)) (string, error)
 	return "", fmt.Errorf("AI request failed after %d attempts: %w", s.config.MaxRetries+1, lastErr)
 }
 
+// recordUsage adds a call's token usage under the configured model
+func (s *AIService) recordUsage(usage Usage) {
+	if s.usage == nil {
+		return
+	}
+
+	model := s.config.Model
+	if model == "" {
+		model = s.GetProviderName()
+	}
+	s.usage.Record(model, usage)
+}
+
 // GetProviderName returns the name of the current AI provider
 func (s *AIService) GetProviderName() string {
 	return s.provider.GetProviderName()
 }
 
+// Close releases the response cache, writing it to disk if persistent
+func (s *AIService) Close() error {
+	if s.cache != nil {
+		return s.cache.Close()
+	}
+	return nil
+}
+
 // GetStats returns service statistics
 func (s *AIService) GetStats() map[string]interface{} {
 	stats := map[string]interface{}{
@@ -222,10 +527,18 @@ func (s *AIService) GetStats() map[string]interface{} {
 		stats["rate_limiter"] = s.rateLimiter.GetStats()
 	}
 
+	if s.sessionLimiter != nil {
+		stats["session_rate_limiter"] = s.sessionLimiter.GetStats()
+	}
+
 	if s.cache != nil {
 		stats["cache"] = s.cache.GetStats()
 	}
 
+	if s.usage != nil {
+		stats["usage"] = s.usage.GetStats()
+	}
+
 	return stats
 }
 
@@ -253,12 +566,30 @@ func isNonRetryableError(err error) bool {
 	return false
 }
 
-// hashString creates a simple hash of a string for caching
+// cacheKey builds a response cache key of the form
+// kind:model:temperature[:overrides]:parts..., so replies generated with one
+// model, temperature or per-request override are never served for another
+func (s *AIService) cacheKey(kind string, opts GenOpts, parts ...string) string {
+	key := []string{kind, s.config.Model, strconv.FormatFloat(s.config.Temperature, 'f', -1, 64)}
+	if overrides := opts.cacheKeyPart(); overrides != "" {
+		key = append(key, overrides)
+	}
+	return strings.Join(append(key, parts...), ":")
+}
+
+// hashString creates a cache key for a string: the first 128 bits of its
+// SHA-256, in hex. A collision would serve one prompt's reply to another and
+// feed the wrong outcome into game state, so this must be collision resistant.
 func hashString(s string) string {
-	// Simple hash function for cache keys
-	h := uint32(0)
-	for _, c := range s {
-		h = h*31 + uint32(c)
+	sum := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(sum[:16])
+}
+
+// log returns the service's logger, falling back to the default logger for
+// services built without NewAIService
+func (s *AIService) log() *slog.Logger {
+	if s.logger != nil {
+		return s.logger
 	}
-	return fmt.Sprintf("%08x", h)
+	return slog.Default()
 }
@@ -1,9 +1,20 @@
 package ai
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 	"time"
+
+	gamecontext "ai-rpg-mvp/context"
 )
 
 func TestAIService_Configuration(t *testing.T) {
@@ -117,7 +128,7 @@ func TestRateLimiter(t *testing.T) {
 }
 
 func TestResponseCache(t *testing.T) {
-	cache := NewResponseCache(100 * time.Millisecond)
+	cache := NewResponseCache(100*time.Millisecond, 0)
 
 	// Test cache miss
 	result := cache.Get("key1")
@@ -153,6 +164,127 @@ func TestResponseCache(t *testing.T) {
 	}
 }
 
+func TestResponseCache_LRUEviction(t *testing.T) {
+	cache := NewResponseCache(time.Hour, 3)
+	defer cache.Close()
+
+	cache.Set("a", "1")
+	cache.Set("b", "2")
+	cache.Set("c", "3")
+
+	// Touch the oldest-inserted key so "b" becomes least recently used
+	if cache.Get("a") != "1" {
+		t.Fatal("Expected hit for 'a'")
+	}
+
+	cache.Set("d", "4")
+
+	if cache.Get("b") != "" {
+		t.Error("Expected least recently used key 'b' to be evicted")
+	}
+	for key, want := range map[string]string{"a": "1", "c": "3", "d": "4"} {
+		if got := cache.Get(key); got != want {
+			t.Errorf("Expected '%s' for key %s, got '%s'", want, key, got)
+		}
+	}
+
+	stats := cache.GetStats()
+	if stats["evictions"].(int64) != 1 {
+		t.Errorf("Expected 1 eviction, got %v", stats["evictions"])
+	}
+	if stats["size"] != 3 {
+		t.Errorf("Expected size 3, got %v", stats["size"])
+	}
+}
+
+func TestResponseCache_UnlimitedByDefault(t *testing.T) {
+	cache := NewResponseCache(time.Hour, 0)
+	defer cache.Close()
+
+	for i := 0; i < 100; i++ {
+		cache.Set(fmt.Sprintf("key%d", i), "value")
+	}
+
+	stats := cache.GetStats()
+	if stats["size"] != 100 || stats["evictions"].(int64) != 0 {
+		t.Errorf("Expected 100 entries and no evictions, got size %v and %v evictions", stats["size"], stats["evictions"])
+	}
+}
+
+func TestPersistentResponseCache_SurvivesRestart(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cache.json")
+
+	cache := NewPersistentResponseCache(time.Hour, 0, path)
+	cache.Set("gm:1", "The gate creaks open.")
+	cache.Set("npc:2", "Welcome, traveler!")
+	if err := cache.Close(); err != nil {
+		t.Fatalf("Failed to close cache: %v", err)
+	}
+
+	restored := NewPersistentResponseCache(time.Hour, 0, path)
+	defer restored.Close()
+
+	if got := restored.Get("gm:1"); got != "The gate creaks open." {
+		t.Errorf("Expected restored entry, got '%s'", got)
+	}
+	if got := restored.Get("npc:2"); got != "Welcome, traveler!" {
+		t.Errorf("Expected restored entry, got '%s'", got)
+	}
+}
+
+func TestPersistentResponseCache_DropsExpiredEntries(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cache.json")
+
+	stale := time.Now().Add(-2 * time.Hour).Format(time.RFC3339Nano)
+	fresh := time.Now().Format(time.RFC3339Nano)
+	data := fmt.Sprintf(`{"old":{"value":"stale","timestamp":%q},"new":{"value":"fresh","timestamp":%q}}`, stale, fresh)
+	if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatalf("Failed to write cache file: %v", err)
+	}
+
+	cache := NewPersistentResponseCache(time.Hour, 0, path)
+	defer cache.Close()
+
+	if got := cache.Get("old"); got != "" {
+		t.Errorf("Expected expired entry to be dropped, got '%s'", got)
+	}
+	if got := cache.Get("new"); got != "fresh" {
+		t.Errorf("Expected 'fresh', got '%s'", got)
+	}
+}
+
+func TestPersistentResponseCache_CorruptOrMissingFile(t *testing.T) {
+	dir := t.TempDir()
+
+	missing := NewPersistentResponseCache(time.Hour, 0, filepath.Join(dir, "missing.json"))
+	defer missing.Close()
+	if size := missing.GetStats()["size"]; size != 0 {
+		t.Errorf("Expected empty cache for missing file, got size %v", size)
+	}
+
+	path := filepath.Join(dir, "corrupt.json")
+	if err := os.WriteFile(path, []byte("{not json"), 0644); err != nil {
+		t.Fatalf("Failed to write cache file: %v", err)
+	}
+
+	corrupt := NewPersistentResponseCache(time.Hour, 0, path)
+	defer corrupt.Close()
+	if size := corrupt.GetStats()["size"]; size != 0 {
+		t.Errorf("Expected empty cache for corrupt file, got size %v", size)
+	}
+
+	// The corrupt file is replaced on the next flush
+	corrupt.Set("key", "value")
+	if err := corrupt.Close(); err != nil {
+		t.Fatalf("Failed to close cache: %v", err)
+	}
+	restored := NewPersistentResponseCache(time.Hour, 0, path)
+	defer restored.Close()
+	if got := restored.Get("key"); got != "value" {
+		t.Errorf("Expected 'value' after rewrite, got '%s'", got)
+	}
+}
+
 func TestHashString(t *testing.T) {
 	hash1 := hashString("test string")
 	hash2 := hashString("test string")
@@ -166,11 +298,34 @@ func TestHashString(t *testing.T) {
 		t.Error("Different strings should produce different hashes")
 	}
 
-	if len(hash1) != 8 {
-		t.Errorf("Hash should be 8 characters long, got %d", len(hash1))
+	if len(hash1) != 32 {
+		t.Errorf("Hash should be 32 characters long, got %d", len(hash1))
 	}
 }
 
+func TestHashString_NoPolynomialCollisions(t *testing.T) {
+	// "Aa" and "BB" hash the same under h*31+c ('A'*31+'a' == 'B'*31+'B'),
+	// and so does every prompt that differs only by swapping one for the other
+	prompt := "You enter the tavern. %s The barkeep looks up."
+	first, second := fmt.Sprintf(prompt, "Aa"), fmt.Sprintf(prompt, "BB")
+	if legacyHash(first) != legacyHash(second) {
+		t.Fatal("Expected the prompts to collide under the old hash")
+	}
+
+	if hashString(first) == hashString(second) {
+		t.Errorf("Expected different keys for different prompts, got %s for both", hashString(first))
+	}
+}
+
+// legacyHash is the 32-bit polynomial hash cache keys used to be built from
+func legacyHash(s string) string {
+	h := uint32(0)
+	for _, c := range s {
+		h = h*31 + uint32(c)
+	}
+	return fmt.Sprintf("%08x", h)
+}
+
 func TestIsNonRetryableError(t *testing.T) {
 	testCases := []struct {
 		error       string
@@ -220,6 +375,499 @@ func TestClaudeProvider_MockResponse(t *testing.T) {
 	}
 }
 
+func TestOllamaProvider_ChatCompletion(t *testing.T) {
+	var received ollamaChatRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := json.NewDecoder(r.Body).Decode(&received); err != nil {
+			t.Errorf("Failed to decode request: %v", err)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"model":"llama3","message":{"role":"assistant","content":"The tavern falls silent as you enter."},"done":true}`)
+	}))
+	defer server.Close()
+
+	// No API key is required for a local model
+	service, err := NewAIService(AIConfig{
+		Provider:    "ollama",
+		BaseURL:     server.URL,
+		Model:       "llama3",
+		MaxTokens:   200,
+		Temperature: 0.5,
+		Timeout:     5 * time.Second,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create AI service: %v", err)
+	}
+
+	if service.GetProviderName() != "ollama" {
+		t.Errorf("Expected provider 'ollama', got '%s'", service.GetProviderName())
+	}
+
+	response, err := service.GenerateGMResponse("I enter the tavern")
+	if err != nil {
+		t.Fatalf("Failed to generate GM response: %v", err)
+	}
+
+	if response != "The tavern falls silent as you enter." {
+		t.Errorf("Expected canned completion, got '%s'", response)
+	}
+
+	if received.Model != "llama3" {
+		t.Errorf("Expected model 'llama3', got '%s'", received.Model)
+	}
+	if received.Stream {
+		t.Error("Expected non-streaming request")
+	}
+	if received.Options.NumPredict != 200 || received.Options.Temperature != 0.5 {
+		t.Errorf("Expected options {0.5 200}, got %+v", received.Options)
+	}
+	if len(received.Messages) != 2 || received.Messages[0].Content != gmSystemPrompt || received.Messages[1].Content != "I enter the tavern" {
+		t.Errorf("Unexpected messages: %+v", received.Messages)
+	}
+}
+
+// fakeProvider is an AIProvider that returns canned text without network calls
+type fakeProvider struct {
+	chunks     []string
+	structured []string // replies to successive structured requests
+	usage      Usage
+	calls      int
+	opts       GenOpts // overrides attached to the last request
+}
+
+func (f *fakeProvider) GenerateGMResponse(ctx context.Context, prompt string) (string, Usage, error) {
+	f.calls++
+	f.opts = GenOptsFromContext(ctx)
+	return strings.Join(f.chunks, ""), f.usage, nil
+}
+
+func (f *fakeProvider) StreamGMResponse(ctx context.Context, prompt string, onChunk func(string)) (string, Usage, error) {
+	f.calls++
+	f.opts = GenOptsFromContext(ctx)
+	for _, chunk := range f.chunks {
+		onChunk(chunk)
+	}
+	return strings.Join(f.chunks, ""), f.usage, nil
+}
+
+func (f *fakeProvider) GenerateStructuredGMResponse(ctx context.Context, prompt string) (string, Usage, error) {
+	f.calls++
+	f.opts = GenOptsFromContext(ctx)
+	if len(f.structured) == 0 {
+		return strings.Join(f.chunks, ""), f.usage, nil
+	}
+	reply := f.structured[0]
+	f.structured = f.structured[1:]
+	return reply, f.usage, nil
+}
+
+func (f *fakeProvider) GenerateNPCDialogue(ctx context.Context, npcName, personality, prompt string) (string, Usage, error) {
+	f.calls++
+	f.opts = GenOptsFromContext(ctx)
+	return strings.Join(f.chunks, ""), f.usage, nil
+}
+
+func (f *fakeProvider) GenerateSceneDescription(ctx context.Context, location, contextInfo, mood string) (string, Usage, error) {
+	f.calls++
+	f.opts = GenOptsFromContext(ctx)
+	return strings.Join(f.chunks, ""), f.usage, nil
+}
+
+func (f *fakeProvider) GetProviderName() string {
+	return "fake"
+}
+
+func TestAIService_GenerateGMResponseStream(t *testing.T) {
+	provider := &fakeProvider{chunks: []string{"The dragon ", "stirs in ", "its sleep."}}
+	service := &AIService{
+		provider: provider,
+		// A single allowed request proves the limit is checked once per stream
+		rateLimiter: NewRateLimiter(1, time.Minute),
+		cache:       NewResponseCache(time.Minute, 0),
+	}
+
+	var received []string
+	response, err := service.GenerateGMResponseStream("I sneak past the dragon", func(chunk string) {
+		received = append(received, chunk)
+	})
+	if err != nil {
+		t.Fatalf("Failed to stream GM response: %v", err)
+	}
+
+	if len(received) != 3 {
+		t.Errorf("Expected 3 chunks, got %d", len(received))
+	}
+	if strings.Join(received, "") != response {
+		t.Errorf("Expected chunks to concatenate to '%s', got '%s'", response, strings.Join(received, ""))
+	}
+	if response != "The dragon stirs in its sleep." {
+		t.Errorf("Unexpected response: '%s'", response)
+	}
+
+	// The aggregate response is cached like the non-streaming path
+	cached, err := service.GenerateGMResponse("I sneak past the dragon")
+	if err != nil {
+		t.Fatalf("Expected cached response, got error: %v", err)
+	}
+	if cached != response || provider.calls != 1 {
+		t.Errorf("Expected cached '%s' after 1 provider call, got '%s' after %d", response, cached, provider.calls)
+	}
+}
+
+func TestAIService_CacheKeyedByModelAndTemperature(t *testing.T) {
+	// Two configurations sharing a cache, as when A/B testing models or
+	// restarting with a different model over a persistent cache
+	cache := NewResponseCache(time.Minute, 0)
+	sonnet := &fakeProvider{chunks: []string{"The gate creaks open."}}
+	haiku := &fakeProvider{chunks: []string{"Gate opens."}}
+	sonnetService := &AIService{provider: sonnet, cache: cache, config: AIConfig{Model: "sonnet", Temperature: 0.7}}
+	haikuService := &AIService{provider: haiku, cache: cache, config: AIConfig{Model: "haiku", Temperature: 0.7}}
+
+	first, _ := sonnetService.GenerateGMResponse("I push the gate")
+	second, _ := haikuService.GenerateGMResponse("I push the gate")
+	if first == second || sonnet.calls != 1 || haiku.calls != 1 {
+		t.Errorf("Expected each model to generate its own reply, got %q and %q", first, second)
+	}
+
+	// Unchanged settings still hit the cache
+	if again, _ := haikuService.GenerateGMResponse("I push the gate"); again != second || haiku.calls != 1 {
+		t.Errorf("Expected a cached reply for the same model, got %q after %d calls", again, haiku.calls)
+	}
+
+	// A different temperature is a different configuration too
+	haikuService.config.Temperature = 0.2
+	haikuService.GenerateGMResponse("I push the gate")
+	if haiku.calls != 2 {
+		t.Errorf("Expected a new temperature to miss the cache, got %d calls", haiku.calls)
+	}
+}
+
+func TestAIService_InvalidateCache(t *testing.T) {
+	provider := &fakeProvider{chunks: []string{"The gate creaks open."}}
+	service := &AIService{provider: provider, cache: NewResponseCache(time.Minute, 0), config: AIConfig{Model: "test-model"}}
+
+	service.GenerateGMResponse("I push the gate")
+	service.GenerateGMResponse("I push the gate")
+	if provider.calls != 1 {
+		t.Fatalf("Expected the second request to hit the cache, got %d calls", provider.calls)
+	}
+
+	service.InvalidateCache(service.cacheKey("gm", GenOpts{}, hashString("I push the gate")))
+	service.GenerateGMResponse("I push the gate")
+	if provider.calls != 2 {
+		t.Errorf("Expected an invalidated entry to re-call the provider, got %d calls", provider.calls)
+	}
+
+	service.ClearCache()
+	service.GenerateGMResponse("I push the gate")
+	if provider.calls != 3 {
+		t.Errorf("Expected a cleared cache to re-call the provider, got %d calls", provider.calls)
+	}
+}
+
+func TestAIService_InvalidateSessionCache(t *testing.T) {
+	provider := &fakeProvider{chunks: []string{"The gate creaks open."}}
+	service := &AIService{provider: provider, cache: NewResponseCache(time.Minute, 0), config: AIConfig{Model: "test-model"}}
+
+	service.GenerateGMResponseForSession("alice", "I push the gate")
+	service.GenerateSceneDescriptionForSession("alice", "tavern", "busy", "warm")
+	service.GenerateGMResponseForSession("bob", "I open the chest")
+	if provider.calls != 3 {
+		t.Fatalf("Expected 3 provider calls, got %d", provider.calls)
+	}
+
+	service.InvalidateSessionCache("alice")
+
+	service.GenerateGMResponseForSession("alice", "I push the gate")
+	service.GenerateSceneDescriptionForSession("alice", "tavern", "busy", "warm")
+	if provider.calls != 5 {
+		t.Errorf("Expected both of alice's requests to re-call the provider, got %d calls", provider.calls)
+	}
+
+	// Other sessions keep their cached replies
+	service.GenerateGMResponseForSession("bob", "I open the chest")
+	if provider.calls != 5 {
+		t.Errorf("Expected bob's reply to stay cached, got %d calls", provider.calls)
+	}
+
+	// A session served someone else's cached reply can invalidate it too
+	service.GenerateGMResponseForSession("carol", "I open the chest")
+	service.InvalidateSessionCache("carol")
+	service.GenerateGMResponseForSession("bob", "I open the chest")
+	if provider.calls != 6 {
+		t.Errorf("Expected the shared reply to be regenerated, got %d calls", provider.calls)
+	}
+}
+
+func TestAIService_GenOptsOverrides(t *testing.T) {
+	provider := &fakeProvider{chunks: []string{"The gate creaks open."}}
+	service := &AIService{provider: provider, cache: NewResponseCache(time.Minute, 0), config: AIConfig{Model: "test-model", Temperature: 0.7}}
+
+	// Plain requests carry no overrides
+	service.GenerateGMResponse("I push the gate")
+	if provider.opts.Temperature != nil || provider.opts.MaxTokens != nil {
+		t.Errorf("Expected no overrides, got %+v", provider.opts)
+	}
+
+	deterministic := 0.0
+	maxTokens := 200
+	service.GenerateGMResponseWithOpts("I push the gate", GenOpts{Temperature: &deterministic, MaxTokens: &maxTokens})
+	if provider.calls != 2 {
+		t.Fatalf("Expected an override to miss the plain reply's cache entry, got %d calls", provider.calls)
+	}
+	if provider.opts.Temperature == nil || *provider.opts.Temperature != 0 || provider.opts.MaxTokens == nil || *provider.opts.MaxTokens != 200 {
+		t.Errorf("Expected the overrides to reach the provider, got %+v", provider.opts)
+	}
+
+	// The same overrides hit their own cache entry; different ones don't
+	service.GenerateGMResponseWithOpts("I push the gate", GenOpts{Temperature: &deterministic, MaxTokens: &maxTokens})
+	if provider.calls != 2 {
+		t.Errorf("Expected identical overrides to hit the cache, got %d calls", provider.calls)
+	}
+	creative := 1.0
+	service.GenerateSceneDescriptionWithOpts("tavern", "busy", "warm", GenOpts{Temperature: &creative})
+	service.GenerateSceneDescriptionWithOpts("tavern", "busy", "warm", GenOpts{Temperature: &deterministic})
+	if provider.calls != 4 {
+		t.Errorf("Expected each temperature to get its own scene, got %d calls", provider.calls)
+	}
+
+	plain := service.cacheKey("gm", GenOpts{}, "hash")
+	overridden := service.cacheKey("gm", GenOpts{Temperature: &deterministic}, "hash")
+	if plain != "gm:test-model:0.7:hash" || overridden == plain {
+		t.Errorf("Expected overrides to change only their own keys, got %q and %q", plain, overridden)
+	}
+
+	negative := -1
+	if _, err := service.GenerateGMResponseWithOpts("I push the gate", GenOpts{MaxTokens: &negative}); err == nil {
+		t.Error("Expected a non-positive max tokens override to be rejected")
+	}
+}
+
+func TestAIService_UsageTracking(t *testing.T) {
+	provider := &fakeProvider{
+		chunks: []string{"A goblin blocks the path."},
+		usage:  Usage{InputTokens: 1200, OutputTokens: 300},
+	}
+	service := &AIService{
+		provider: provider,
+		usage:    NewUsageTracker(0.003, 0.015),
+		config:   AIConfig{Model: "test-model"},
+	}
+
+	service.GenerateGMResponse("I walk north")
+	service.GenerateNPCDialogue("Goblin", "cranky", "Let me pass")
+	service.GenerateGMResponseStream("I draw my sword", func(string) {})
+
+	usage, ok := service.GetStats()["usage"].(map[string]interface{})
+	if !ok {
+		t.Fatal("Expected usage in stats")
+	}
+
+	if usage["requests"] != int64(3) {
+		t.Errorf("Expected 3 requests, got %v", usage["requests"])
+	}
+	if usage["input_tokens"] != int64(3600) {
+		t.Errorf("Expected 3600 input tokens, got %v", usage["input_tokens"])
+	}
+	if usage["output_tokens"] != int64(900) {
+		t.Errorf("Expected 900 output tokens, got %v", usage["output_tokens"])
+	}
+	if usage["total_tokens"] != int64(4500) {
+		t.Errorf("Expected 4500 total tokens, got %v", usage["total_tokens"])
+	}
+
+	// 3.6K input at $0.003 plus 0.9K output at $0.015
+	cost, _ := usage["estimated_cost_usd"].(float64)
+	if cost < 0.0242 || cost > 0.0244 {
+		t.Errorf("Expected estimated cost 0.0243, got %v", cost)
+	}
+
+	byModel := usage["by_model"].(map[string]interface{})
+	modelStats, ok := byModel["test-model"].(map[string]interface{})
+	if !ok {
+		t.Fatal("Expected per-model usage for test-model")
+	}
+	if modelStats["input_tokens"] != int64(3600) {
+		t.Errorf("Expected 3600 input tokens for test-model, got %v", modelStats["input_tokens"])
+	}
+}
+
+func TestUsageTracker_NoCostConfigured(t *testing.T) {
+	tracker := NewUsageTracker(0, 0)
+	tracker.Record("model-a", Usage{InputTokens: 10, OutputTokens: 5})
+
+	stats := tracker.GetStats()
+	if _, ok := stats["estimated_cost_usd"]; ok {
+		t.Error("Expected no cost estimate without configured costs")
+	}
+	if stats["total_tokens"] != int64(15) {
+		t.Errorf("Expected 15 total tokens, got %v", stats["total_tokens"])
+	}
+}
+
+func TestOllamaProvider_StreamGMResponse(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, `{"message":{"role":"assistant","content":"Torchlight "},"done":false}`)
+		fmt.Fprintln(w, `{"message":{"role":"assistant","content":"flickers."},"done":false}`)
+		fmt.Fprintln(w, `{"message":{"role":"assistant","content":""},"done":true,"prompt_eval_count":42,"eval_count":7}`)
+	}))
+	defer server.Close()
+
+	provider, err := NewOllamaProvider(AIConfig{BaseURL: server.URL})
+	if err != nil {
+		t.Fatalf("Failed to create Ollama provider: %v", err)
+	}
+
+	chunks := 0
+	response, usage, err := provider.StreamGMResponse(context.Background(), "I light a torch", func(string) { chunks++ })
+	if err != nil {
+		t.Fatalf("Failed to stream GM response: %v", err)
+	}
+	if chunks != 2 || response != "Torchlight flickers." {
+		t.Errorf("Expected 2 chunks making 'Torchlight flickers.', got %d making '%s'", chunks, response)
+	}
+	if usage.InputTokens != 42 || usage.OutputTokens != 7 {
+		t.Errorf("Expected usage {42 7}, got %+v", usage)
+	}
+}
+
+func TestMockProvider_GenerateGMResponse(t *testing.T) {
+	// No key and no network: a real service end to end
+	service, err := NewAIService(AIConfig{Provider: "mock", EnableCaching: true, CacheTTL: time.Minute})
+	if err != nil {
+		t.Fatalf("Failed to create AI service: %v", err)
+	}
+	defer service.Close()
+
+	if service.GetProviderName() != "mock" {
+		t.Errorf("Expected provider 'mock', got '%s'", service.GetProviderName())
+	}
+
+	prompt := "CURRENT GAME STATE:\n- Location: tavern\n\nPlayer Action: /look around\n\nAs the Game Master, respond."
+	response, err := service.GenerateGMResponse(prompt)
+	if err != nil {
+		t.Fatalf("Failed to generate GM response: %v", err)
+	}
+	if !strings.HasPrefix(response, "You look around.") {
+		t.Errorf("Expected the reply to narrate the player action, got %q", response)
+	}
+
+	// Deterministic: a fresh service gives the same reply
+	other, _ := NewAIService(AIConfig{Provider: "mock"})
+	if again, _ := other.GenerateGMResponse(prompt); again != response {
+		t.Errorf("Expected the same reply for the same prompt, got %q and %q", response, again)
+	}
+
+	turn, err := service.GenerateStructuredGMResponse(prompt)
+	if err != nil || turn.Narration != response || len(turn.Choices) == 0 {
+		t.Errorf("Expected a parseable structured turn, got %+v (%v)", turn, err)
+	}
+
+	var streamed strings.Builder
+	if _, err := other.GenerateGMResponseStream(prompt, func(chunk string) { streamed.WriteString(chunk) }); err != nil || streamed.String() != response {
+		t.Errorf("Expected the stream to add up to the reply, got %q (%v)", streamed.String(), err)
+	}
+
+	if dialogue, _ := service.GenerateNPCDialogue("Barkeep", "gruff", "Any news?"); !strings.HasPrefix(dialogue, "Barkeep") {
+		t.Errorf("Expected the NPC to speak, got %q", dialogue)
+	}
+}
+
+func TestGeminiProvider_Validation(t *testing.T) {
+	if _, err := NewGeminiProvider(AIConfig{Provider: "gemini"}); err == nil || !strings.Contains(err.Error(), "Gemini API key is required") {
+		t.Errorf("Expected a missing key error, got %v", err)
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("x-goog-api-key") != "fake-key" {
+			t.Errorf("Expected the API key header, got %q", r.Header.Get("x-goog-api-key"))
+		}
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprint(w, `{"error":{"code":400,"message":"API key not valid. Please pass a valid API key.","status":"INVALID_ARGUMENT"}}`)
+	}))
+	defer server.Close()
+
+	// "google" is an alias for the same provider
+	provider, err := newProvider(AIConfig{Provider: "google", APIKey: "fake-key", BaseURL: server.URL})
+	if err != nil {
+		t.Fatalf("Failed to create Gemini provider: %v", err)
+	}
+	if provider.GetProviderName() != "gemini" {
+		t.Errorf("Expected provider 'gemini', got '%s'", provider.GetProviderName())
+	}
+
+	_, _, err = provider.GenerateGMResponse(context.Background(), "I enter the tavern")
+	if err == nil || !strings.Contains(err.Error(), "API key not valid") {
+		t.Fatalf("Expected a descriptive error for a fake key, got %v", err)
+	}
+	if !isNonRetryableError(err) {
+		t.Errorf("Expected a bad key not to be retried: %v", err)
+	}
+}
+
+func TestGeminiProvider_GenerateContent(t *testing.T) {
+	var received geminiRequest
+	var path string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path = r.URL.Path
+		if err := json.NewDecoder(r.Body).Decode(&received); err != nil {
+			t.Errorf("Failed to decode request: %v", err)
+		}
+		fmt.Fprint(w, `{"candidates":[{"content":{"role":"model","parts":[{"text":"The tavern "},{"text":"falls silent."}]},"finishReason":"STOP"}],"usageMetadata":{"promptTokenCount":120,"candidatesTokenCount":9}}`)
+	}))
+	defer server.Close()
+
+	provider, err := NewGeminiProvider(AIConfig{APIKey: "fake-key", BaseURL: server.URL, Model: "gemini-1.5-pro", MaxTokens: 200, Temperature: 0.5})
+	if err != nil {
+		t.Fatalf("Failed to create Gemini provider: %v", err)
+	}
+
+	deterministic := 0.0
+	ctx := withGenOpts(context.Background(), GenOpts{Temperature: &deterministic})
+	response, usage, err := provider.GenerateNPCDialogue(ctx, "Barkeep", "gruff", "Any news?")
+	if err != nil {
+		t.Fatalf("Failed to generate NPC dialogue: %v", err)
+	}
+	if response != "The tavern falls silent." || usage.InputTokens != 120 || usage.OutputTokens != 9 {
+		t.Errorf("Unexpected reply %q with usage %+v", response, usage)
+	}
+
+	if path != "/models/gemini-1.5-pro:generateContent" {
+		t.Errorf("Unexpected request path %q", path)
+	}
+	if received.GenerationConfig.Temperature != 0 || received.GenerationConfig.MaxOutputTokens != 100 {
+		t.Errorf("Expected the override and half the max tokens, got %+v", received.GenerationConfig)
+	}
+	if len(received.SystemInstruction.Parts) != 1 || !strings.Contains(received.SystemInstruction.Parts[0].Text, "Barkeep") {
+		t.Errorf("Expected the NPC system prompt, got %+v", received.SystemInstruction)
+	}
+}
+
+func TestGeminiProvider_StreamGMResponse(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("alt") != "sse" {
+			t.Errorf("Expected a server-sent events stream, got %q", r.URL.RawQuery)
+		}
+		fmt.Fprint(w, "data: {\"candidates\":[{\"content\":{\"parts\":[{\"text\":\"Torchlight \"}]}}]}\n\n")
+		fmt.Fprint(w, "data: {\"candidates\":[{\"content\":{\"parts\":[{\"text\":\"flickers.\"}]}}],\"usageMetadata\":{\"promptTokenCount\":42,\"candidatesTokenCount\":7}}\n\n")
+	}))
+	defer server.Close()
+
+	provider, _ := NewGeminiProvider(AIConfig{APIKey: "fake-key", BaseURL: server.URL})
+
+	chunks := 0
+	response, usage, err := provider.StreamGMResponse(context.Background(), "I light a torch", func(string) { chunks++ })
+	if err != nil {
+		t.Fatalf("Failed to stream GM response: %v", err)
+	}
+	if chunks != 2 || response != "Torchlight flickers." {
+		t.Errorf("Expected 2 chunks making 'Torchlight flickers.', got %d making '%s'", chunks, response)
+	}
+	if usage.InputTokens != 42 || usage.OutputTokens != 7 {
+		t.Errorf("Expected usage {42 7}, got %+v", usage)
+	}
+}
+
 // Benchmark tests
 func BenchmarkHashString(b *testing.B) {
 	testString := "This is a test string for hashing benchmark"
@@ -239,7 +887,7 @@ func BenchmarkRateLimiter(b *testing.B) {
 }
 
 func BenchmarkResponseCache(b *testing.B) {
-	cache := NewResponseCache(1 * time.Hour)
+	cache := NewResponseCache(1*time.Hour, 0)
 	cache.Set("test-key", "test-value")
 	
 	b.ResetTimer()
@@ -247,3 +895,508 @@ func BenchmarkResponseCache(b *testing.B) {
 		cache.Get("test-key")
 	}
 }
+
+func TestAIService_GenerateStructuredGMResponse(t *testing.T) {
+	provider := &fakeProvider{structured: []string{
+		"```json\n" + `{"narration": "The gate creaks open.", "suggested_consequences": ["exploration_success"], "choices": ["Enter", "Wait"]}` + "\n```",
+	}}
+	service := &AIService{provider: provider, cache: NewResponseCache(time.Minute, 0)}
+
+	turn, err := service.GenerateStructuredGMResponse("I push the gate")
+	if err != nil {
+		t.Fatalf("Failed to generate structured response: %v", err)
+	}
+	if turn.Narration != "The gate creaks open." {
+		t.Errorf("Unexpected narration: '%s'", turn.Narration)
+	}
+	if len(turn.SuggestedConsequences) != 1 || turn.SuggestedConsequences[0] != "exploration_success" {
+		t.Errorf("Unexpected consequences: %v", turn.SuggestedConsequences)
+	}
+	if len(turn.Choices) != 2 {
+		t.Errorf("Expected 2 choices, got %v", turn.Choices)
+	}
+	if provider.calls != 1 {
+		t.Errorf("Expected 1 provider call, got %d", provider.calls)
+	}
+
+	// Parsed turns are cached
+	if _, err := service.GenerateStructuredGMResponse("I push the gate"); err != nil || provider.calls != 1 {
+		t.Errorf("Expected cached turn after 1 provider call, got %d calls (err %v)", provider.calls, err)
+	}
+}
+
+func TestAIService_GenerateStructuredGMResponseRepair(t *testing.T) {
+	provider := &fakeProvider{structured: []string{
+		`{"narration": "A wolf howls.", "choices": [`,
+		`{"narration": "A wolf howls.", "choices": ["Run"]}`,
+	}}
+	service := &AIService{provider: provider}
+
+	turn, err := service.GenerateStructuredGMResponse("I listen")
+	if err != nil {
+		t.Fatalf("Failed to generate structured response: %v", err)
+	}
+	if provider.calls != 2 {
+		t.Errorf("Expected 1 repair call, got %d calls", provider.calls)
+	}
+	if turn.Narration != "A wolf howls." || len(turn.Choices) != 1 {
+		t.Errorf("Expected repaired turn, got %+v", turn)
+	}
+}
+
+func TestAIService_GenerateStructuredGMResponseFallback(t *testing.T) {
+	provider := &fakeProvider{structured: []string{
+		"The innkeeper shrugs and returns to polishing mugs.",
+		"Still not JSON, sorry.",
+	}}
+	service := &AIService{provider: provider}
+
+	turn, err := service.GenerateStructuredGMResponse("I ask about rumors")
+	if err != nil {
+		t.Fatalf("Failed to generate structured response: %v", err)
+	}
+	if provider.calls != 2 {
+		t.Errorf("Expected exactly one repair attempt, got %d calls", provider.calls)
+	}
+	if turn.Narration != "The innkeeper shrugs and returns to polishing mugs." {
+		t.Errorf("Expected original prose as narration, got '%s'", turn.Narration)
+	}
+	if turn.Choices == nil || turn.SuggestedConsequences == nil {
+		t.Error("Expected empty, non-nil slices in the fallback turn")
+	}
+}
+
+// failingProvider is an AIProvider whose every call fails with err
+type failingProvider struct {
+	err   error
+	calls int
+}
+
+func (f *failingProvider) GenerateGMResponse(ctx context.Context, prompt string) (string, Usage, error) {
+	f.calls++
+	return "", Usage{}, f.err
+}
+
+func (f *failingProvider) StreamGMResponse(ctx context.Context, prompt string, onChunk func(string)) (string, Usage, error) {
+	f.calls++
+	return "", Usage{}, f.err
+}
+
+func (f *failingProvider) GenerateStructuredGMResponse(ctx context.Context, prompt string) (string, Usage, error) {
+	f.calls++
+	return "", Usage{}, f.err
+}
+
+func (f *failingProvider) GenerateNPCDialogue(ctx context.Context, npcName, personality, prompt string) (string, Usage, error) {
+	f.calls++
+	return "", Usage{}, f.err
+}
+
+func (f *failingProvider) GenerateSceneDescription(ctx context.Context, location, contextInfo, mood string) (string, Usage, error) {
+	f.calls++
+	return "", Usage{}, f.err
+}
+
+func (f *failingProvider) GetProviderName() string {
+	return "failing"
+}
+
+func TestFallbackProvider_FallsThroughOnError(t *testing.T) {
+	errs := []error{
+		fmt.Errorf("API error 529: overloaded"),
+		fmt.Errorf("API error 401: invalid api key"), // non-retryable, still falls through
+	}
+
+	for _, primaryErr := range errs {
+		primary := &failingProvider{err: primaryErr}
+		backup := &fakeProvider{chunks: []string{"The bridge holds."}}
+		provider, err := NewFallbackProvider(primary, backup)
+		if err != nil {
+			t.Fatalf("Failed to create fallback provider: %v", err)
+		}
+
+		if provider.GetProviderName() != "failing" {
+			t.Errorf("Expected primary to be active initially, got %s", provider.GetProviderName())
+		}
+
+		response, _, err := provider.GenerateGMResponse(context.Background(), "I cross the bridge")
+		if err != nil {
+			t.Fatalf("Expected fallback to succeed after '%v', got error: %v", primaryErr, err)
+		}
+		if response != "The bridge holds." {
+			t.Errorf("Expected backup response, got '%s'", response)
+		}
+		if primary.calls != 1 || backup.calls != 1 {
+			t.Errorf("Expected 1 call to each provider, got %d and %d", primary.calls, backup.calls)
+		}
+		if provider.GetProviderName() != "fake" {
+			t.Errorf("Expected active provider fake, got %s", provider.GetProviderName())
+		}
+	}
+}
+
+func TestFallbackProvider_AllFail(t *testing.T) {
+	provider, _ := NewFallbackProvider(
+		&failingProvider{err: fmt.Errorf("first down")},
+		&failingProvider{err: fmt.Errorf("second down")},
+	)
+
+	_, _, err := provider.GenerateNPCDialogue(context.Background(), "Guard", "stern", "Hello")
+	if err == nil || err.Error() != "second down" {
+		t.Errorf("Expected last provider's error, got %v", err)
+	}
+
+	if _, err := NewFallbackProvider(); err == nil {
+		t.Error("Expected error for an empty fallback chain")
+	}
+}
+
+func TestAIService_FallbackProvider(t *testing.T) {
+	primary := &failingProvider{err: fmt.Errorf("unauthorized")}
+	backup := &fakeProvider{chunks: []string{"The ", "gate ", "opens."}}
+	provider, _ := NewFallbackProvider(primary, backup)
+	service := &AIService{
+		provider:    provider,
+		rateLimiter: NewRateLimiter(10, time.Minute),
+		config:      AIConfig{MaxRetries: 3},
+	}
+
+	var received []string
+	response, err := service.GenerateGMResponseStream("I knock", func(chunk string) {
+		received = append(received, chunk)
+	})
+	if err != nil {
+		t.Fatalf("Expected fallback stream to succeed, got error: %v", err)
+	}
+	if response != "The gate opens." || len(received) != 3 {
+		t.Errorf("Expected backup stream, got '%s' in %d chunks", response, len(received))
+	}
+
+	// The primary's bad key is not retried before falling back
+	if primary.calls != 1 {
+		t.Errorf("Expected 1 primary call, got %d", primary.calls)
+	}
+	if service.GetStats()["provider"] != "fake" {
+		t.Errorf("Expected stats to report active provider fake, got %v", service.GetStats()["provider"])
+	}
+}
+
+func TestSessionRateLimiter(t *testing.T) {
+	limiter := NewSessionRateLimiter(2, time.Minute, time.Hour)
+
+	// Exhaust the first session's bucket
+	for i := 0; i < 2; i++ {
+		if !limiter.Allow("session-a") {
+			t.Errorf("Request %d for session-a should be allowed", i+1)
+		}
+	}
+	if limiter.Allow("session-a") {
+		t.Error("Third request for session-a should be denied")
+	}
+
+	// Another session keeps its own budget
+	if !limiter.Allow("session-b") {
+		t.Error("First request for session-b should be allowed")
+	}
+
+	if stats := limiter.GetStats(); stats["active_sessions"] != 2 {
+		t.Errorf("Expected 2 active sessions, got %v", stats["active_sessions"])
+	}
+}
+
+func TestSessionRateLimiter_RemovesIdleSessions(t *testing.T) {
+	limiter := NewSessionRateLimiter(1, time.Minute, 50*time.Millisecond)
+	limiter.Allow("session-a")
+
+	time.Sleep(60 * time.Millisecond)
+	limiter.Allow("session-b")
+
+	limiter.mutex.Lock()
+	_, kept := limiter.limiters["session-a"]
+	count := len(limiter.limiters)
+	limiter.mutex.Unlock()
+
+	if kept || count != 1 {
+		t.Errorf("Expected idle session-a to be dropped, got %d sessions", count)
+	}
+
+	// A returning session starts with a fresh bucket
+	if !limiter.Allow("session-a") {
+		t.Error("Expected returning session-a to be allowed")
+	}
+}
+
+func TestAIService_PerSessionRateLimit(t *testing.T) {
+	service := &AIService{
+		provider:       &fakeProvider{chunks: []string{"The tavern is quiet."}},
+		rateLimiter:    NewRateLimiter(10, time.Minute),
+		sessionLimiter: NewSessionRateLimiter(1, time.Minute, time.Hour),
+	}
+
+	if _, err := service.GenerateGMResponseForSession("busy", "I look around"); err != nil {
+		t.Fatalf("Expected first request to succeed, got error: %v", err)
+	}
+	if _, err := service.GenerateGMResponseForSession("busy", "I look around again"); err == nil {
+		t.Error("Expected busy session to be rate limited")
+	}
+	if _, err := service.GenerateNPCDialogueForSession("quiet", "Barkeep", "gruff", "Any rumors?"); err != nil {
+		t.Errorf("Expected other session to be unaffected, got error: %v", err)
+	}
+
+	// Calls without a session only count against the global limit
+	if _, err := service.GenerateGMResponse("I order an ale"); err != nil {
+		t.Errorf("Expected sessionless request to succeed, got error: %v", err)
+	}
+}
+
+func TestHTTPEmbedder_Embed(t *testing.T) {
+	var received embeddingRequest
+	var auth string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth = r.Header.Get("Authorization")
+		if err := json.NewDecoder(r.Body).Decode(&received); err != nil {
+			t.Errorf("Failed to decode request: %v", err)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"data":[{"embedding":[0.1,0.2,0.3]}]}`)
+	}))
+	defer server.Close()
+
+	// The embedding key falls back to the main API key
+	embedder, err := NewEmbedder(AIConfig{
+		APIKey:            "test-key",
+		EmbeddingProvider: "openai",
+		EmbeddingBaseURL:  server.URL,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create embedder: %v", err)
+	}
+
+	embedding, err := embedder.Embed("I search the chest")
+	if err != nil {
+		t.Fatalf("Failed to embed: %v", err)
+	}
+
+	if len(embedding) != 3 || embedding[2] != 0.3 {
+		t.Errorf("Expected [0.1 0.2 0.3], got %v", embedding)
+	}
+	if auth != "Bearer test-key" {
+		t.Errorf("Expected the main API key to be sent, got '%s'", auth)
+	}
+	if received.Model != "text-embedding-3-small" || len(received.Input) != 1 || received.Input[0] != "I search the chest" {
+		t.Errorf("Unexpected request: %+v", received)
+	}
+}
+
+func TestNewEmbedder_Validation(t *testing.T) {
+	if _, err := NewEmbedder(AIConfig{EmbeddingProvider: "openai"}); err == nil {
+		t.Error("Expected an error without an API key")
+	}
+	if _, err := NewEmbedder(AIConfig{EmbeddingProvider: "unknown", APIKey: "test-key"}); err == nil {
+		t.Error("Expected an error for an unknown provider")
+	}
+	if _, err := NewEmbedder(AIConfig{EmbeddingProvider: "ollama"}); err != nil {
+		t.Errorf("Expected Ollama to need no API key: %v", err)
+	}
+}
+
+func TestAIService_SummarizeHistory(t *testing.T) {
+	provider := &fakeProvider{chunks: []string{"  The hero bought bread and fled the dragon.\n"}}
+	service := &AIService{provider: provider}
+
+	summary, err := service.SummarizeHistory([]gamecontext.ActionEvent{
+		{Command: "/buy bread", Location: "village", Outcome: "Warm bread, two coins."},
+		{Command: "/flee", Location: "mountain"},
+	}, "The hero arrived in the village.")
+	if err != nil {
+		t.Fatalf("Failed to summarize history: %v", err)
+	}
+	if summary != "The hero bought bread and fled the dragon." {
+		t.Errorf("Expected the trimmed summary, got %q", summary)
+	}
+
+	// Nothing to add keeps the prior summary without asking the model
+	summary, _ = service.SummarizeHistory(nil, "The hero arrived in the village.")
+	if summary != "The hero arrived in the village." || provider.calls != 1 {
+		t.Errorf("Expected the prior summary and no extra call, got %q after %d calls", summary, provider.calls)
+	}
+}
+
+func TestAIService_ExtractNPCFacts(t *testing.T) {
+	provider := &fakeProvider{chunks: []string{"```json\n[\"Is looking for their brother\", \" \", \"Owes the guild money\"]\n```"}}
+	service := &AIService{provider: provider}
+
+	facts, err := service.ExtractNPCFacts("Mara", []string{"Paid for a room"}, "Player: Have you seen my brother?\nMara: Not lately.")
+	if err != nil {
+		t.Fatalf("Failed to extract facts: %v", err)
+	}
+	if len(facts) != 2 || facts[0] != "Is looking for their brother" || facts[1] != "Owes the guild money" {
+		t.Errorf("Expected two facts, got %q", facts)
+	}
+
+	// A reply that isn't a JSON list yields nothing rather than an error
+	provider.chunks = []string{"Mara learned that the player is kind."}
+	facts, err = service.ExtractNPCFacts("Mara", nil, "Player: Hello")
+	if err != nil || len(facts) != 0 {
+		t.Errorf("Expected no facts and no error for malformed JSON, got %q, %v", facts, err)
+	}
+}
+
+func TestAIService_InterpretCommand(t *testing.T) {
+	provider := &fakeProvider{chunks: []string{"```json\n{\"verb\": \"/Talk\", \"actionType\": \"social\", \"target\": \"tavern_keeper\"}\n```"}}
+	service := &AIService{provider: provider}
+
+	grounding := gamecontext.CommandGrounding{
+		Verbs:    []string{"look", "talk"},
+		Location: "tavern",
+		NPCs:     map[string]string{"tavern_keeper": "Marcus the Tavern Keeper"},
+	}
+	interpretation, err := service.InterpretCommand("talk to the barkeep", grounding)
+	if err != nil {
+		t.Fatalf("Failed to interpret command: %v", err)
+	}
+	want := gamecontext.CommandInterpretation{Verb: "talk", ActionType: "social", Target: "tavern_keeper"}
+	if interpretation != want {
+		t.Errorf("Expected %+v, got %+v", want, interpretation)
+	}
+
+	provider.chunks = []string{"The player wants to chat."}
+	if _, err := service.InterpretCommand("talk to the barkeep", grounding); err == nil {
+		t.Error("Expected an error for a reply that isn't JSON")
+	}
+}
+
+func TestCommandInterpretationPrompt_Grounding(t *testing.T) {
+	prompt := commandInterpretationPrompt("give the key to marcus", gamecontext.CommandGrounding{
+		Verbs:     []string{"look", "talk"},
+		Location:  "tavern",
+		Exits:     []string{"village_square"},
+		NPCs:      map[string]string{"tavern_keeper": "Marcus the Tavern Keeper"},
+		Inventory: map[string]string{"rusty_key": "Rusty Key"},
+	})
+
+	for _, want := range []string{`"give the key to marcus"`, "look, talk", "- village_square", "- tavern_keeper: Marcus the Tavern Keeper", "- rusty_key: Rusty Key"} {
+		if !strings.Contains(prompt, want) {
+			t.Errorf("Expected prompt to contain %q", want)
+		}
+	}
+}
+
+// slowDownError asks the caller to wait a fixed time before retrying
+type slowDownError struct{ wait time.Duration }
+
+func (e slowDownError) Error() string             { return "slow down" }
+func (e slowDownError) RetryAfter() time.Duration { return e.wait }
+
+func TestAIService_RetryBackoff(t *testing.T) {
+	var delays []time.Duration
+	provider := &failingProvider{err: fmt.Errorf("server overloaded")}
+	service := &AIService{
+		provider: provider,
+		config:   AIConfig{MaxRetries: 5, RetryDelay: 100 * time.Millisecond, RetryMaxDelay: time.Second},
+		sleep:    func(d time.Duration) { delays = append(delays, d) },
+		random:   func() float64 { return 1 }, // always the full backoff
+	}
+
+	if _, err := service.GenerateGMResponse("I knock"); err == nil {
+		t.Fatal("Expected the request to fail")
+	}
+	if provider.calls != 6 {
+		t.Errorf("Expected 6 attempts, got %d", provider.calls)
+	}
+
+	// Doubling from RetryDelay, capped at RetryMaxDelay
+	expected := []time.Duration{100 * time.Millisecond, 200 * time.Millisecond, 400 * time.Millisecond, 800 * time.Millisecond, time.Second}
+	if fmt.Sprint(delays) != fmt.Sprint(expected) {
+		t.Errorf("Expected delays %v, got %v", expected, delays)
+	}
+
+	// Jitter picks anywhere up to the backoff
+	delays = nil
+	service.random = rand.New(rand.NewSource(1)).Float64
+	service.GenerateGMResponse("I knock")
+	for i, delay := range delays {
+		if delay < 0 || delay > expected[i] {
+			t.Errorf("Retry %d waited %v, outside [0, %v]", i+1, delay, expected[i])
+		}
+	}
+
+	// The provider's Retry-After wins over the backoff
+	delays = nil
+	provider.err = fmt.Errorf("Claude API error: %w", slowDownError{wait: 7 * time.Second})
+	service.GenerateGMResponse("I knock")
+	for _, delay := range delays {
+		if delay != 7*time.Second {
+			t.Errorf("Expected to wait the 7s asked for, waited %v", delay)
+		}
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	if wait, ok := parseRetryAfter("120", now); !ok || wait != 2*time.Minute {
+		t.Errorf("Expected 2m from seconds, got %v, %v", wait, ok)
+	}
+	if wait, ok := parseRetryAfter(now.Add(30*time.Second).Format(http.TimeFormat), now); !ok || wait != 30*time.Second {
+		t.Errorf("Expected 30s from an HTTP date, got %v, %v", wait, ok)
+	}
+	if _, ok := parseRetryAfter("soon", now); ok {
+		t.Error("Expected an unreadable Retry-After to be ignored")
+	}
+}
+
+// slowProvider is an AIProvider whose calls block until their context ends
+type slowProvider struct {
+	failingProvider
+	started chan struct{}
+}
+
+func (f *slowProvider) GenerateGMResponse(ctx context.Context, prompt string) (string, Usage, error) {
+	f.calls++
+	close(f.started)
+	<-ctx.Done()
+	return "", Usage{}, fmt.Errorf("Claude API error: %w", ctx.Err())
+}
+
+func TestAIService_GenerateGMResponseCtx_Cancelled(t *testing.T) {
+	provider := &slowProvider{started: make(chan struct{})}
+	service := &AIService{provider: provider, config: AIConfig{MaxRetries: 3}}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		<-provider.started
+		cancel()
+	}()
+
+	_, err := service.GenerateGMResponseCtx(ctx, "I wait")
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("Expected context.Canceled, got %v", err)
+	}
+
+	// A cancelled call isn't retried
+	if provider.calls != 1 {
+		t.Errorf("Expected 1 call, got %d", provider.calls)
+	}
+}
+
+func TestOllamaProvider_CancelledRequest(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release // never answers in time
+	}))
+	defer server.Close()
+	defer close(release)
+
+	provider, _ := NewOllamaProvider(AIConfig{Provider: "ollama", BaseURL: server.URL, Timeout: time.Minute})
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	if _, _, err := provider.GenerateGMResponse(ctx, "I wait"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected the caller's deadline to end the request, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("Expected the request to stop promptly, took %v", elapsed)
+	}
+}
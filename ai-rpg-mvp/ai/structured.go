@@ -0,0 +1,129 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// GMTurn is a Game Master reply in a form programs can consume
+type GMTurn struct {
+	Narration             string   `json:"narration"`
+	SuggestedConsequences []string `json:"suggested_consequences"`
+	Choices               []string `json:"choices"`
+}
+
+// GenerateStructuredGMResponse generates a Game Master reply as a GMTurn. A
+// reply that isn't valid JSON gets one repair attempt; if that fails too the
+// raw text becomes the narration.
+func (s *AIService) GenerateStructuredGMResponse(prompt string) (GMTurn, error) {
+	return s.GenerateStructuredGMResponseForSessionCtx(context.Background(), "", prompt)
+}
+
+// GenerateStructuredGMResponseCtx generates a GMTurn, giving up when ctx is
+// cancelled
+func (s *AIService) GenerateStructuredGMResponseCtx(ctx context.Context, prompt string) (GMTurn, error) {
+	return s.GenerateStructuredGMResponseForSessionCtx(ctx, "", prompt)
+}
+
+// GenerateStructuredGMResponseForSession generates a GMTurn, counting it
+// against the session's own rate limit
+func (s *AIService) GenerateStructuredGMResponseForSession(sessionID, prompt string) (GMTurn, error) {
+	return s.GenerateStructuredGMResponseForSessionCtx(context.Background(), sessionID, prompt)
+}
+
+// GenerateStructuredGMResponseForSessionCtx generates a GMTurn for a session,
+// giving up when ctx is cancelled
+func (s *AIService) GenerateStructuredGMResponseForSessionCtx(ctx context.Context, sessionID, prompt string) (GMTurn, error) {
+	return s.GenerateStructuredGMResponseForSessionWithOpts(ctx, sessionID, prompt, GenOpts{})
+}
+
+// GenerateStructuredGMResponseWithOpts generates a GMTurn with the configured
+// temperature or max tokens overridden by opts, such as a zero temperature
+// for deterministic combat adjudication
+func (s *AIService) GenerateStructuredGMResponseWithOpts(prompt string, opts GenOpts) (GMTurn, error) {
+	return s.GenerateStructuredGMResponseForSessionWithOpts(context.Background(), "", prompt, opts)
+}
+
+// GenerateStructuredGMResponseForSessionWithOpts generates a GMTurn for a
+// session with opts overriding the configured settings, giving up when ctx is
+// cancelled
+func (s *AIService) GenerateStructuredGMResponseForSessionWithOpts(ctx context.Context, sessionID, prompt string, opts GenOpts) (GMTurn, error) {
+	if err := opts.validate(); err != nil {
+		return GMTurn{}, err
+	}
+	ctx = withGenOpts(ctx, opts)
+	cacheKey := s.cacheKey("gmjson", opts, hashString(prompt))
+
+	// Check cache first
+	if s.cache != nil {
+		if cached := s.cacheGet(sessionID, cacheKey); cached != "" {
+			if turn, err := parseGMTurn(cached); err == nil {
+				return turn, nil
+			}
+		}
+	}
+
+	// Check rate limit
+	if err := s.checkRateLimit(sessionID); err != nil {
+		return GMTurn{}, err
+	}
+
+	response, err := s.generateWithRetry(ctx, func(ctx context.Context) (string, Usage, error) {
+		return s.provider.GenerateStructuredGMResponse(ctx, prompt)
+	})
+	if err != nil {
+		return GMTurn{}, err
+	}
+
+	turn, parseErr := parseGMTurn(response)
+	if parseErr != nil {
+		s.log().Warn("GM returned invalid JSON, asking for a repair", "error", parseErr)
+
+		repaired, err := s.generateWithRetry(ctx, func(ctx context.Context) (string, Usage, error) {
+			return s.provider.GenerateStructuredGMResponse(ctx, repairPrompt(response, parseErr))
+		})
+		if err == nil {
+			turn, parseErr = parseGMTurn(repaired)
+		}
+	}
+
+	if parseErr != nil {
+		// Fall back to the original prose rather than losing the reply
+		return GMTurn{Narration: strings.TrimSpace(response), SuggestedConsequences: []string{}, Choices: []string{}}, nil
+	}
+
+	// Cache only replies that parsed
+	if s.cache != nil {
+		if encoded, err := json.Marshal(turn); err == nil {
+			s.cacheSet(sessionID, cacheKey, string(encoded))
+		}
+	}
+
+	return turn, nil
+}
+
+// parseGMTurn decodes a GMTurn, tolerating markdown code fences around it
+func parseGMTurn(response string) (GMTurn, error) {
+	text := strings.TrimSpace(response)
+	text = strings.TrimPrefix(text, "```json")
+	text = strings.TrimPrefix(text, "```")
+	text = strings.TrimSuffix(text, "```")
+
+	var turn GMTurn
+	if err := json.Unmarshal([]byte(strings.TrimSpace(text)), &turn); err != nil {
+		return GMTurn{}, err
+	}
+	if turn.Narration == "" {
+		return GMTurn{}, fmt.Errorf("missing narration")
+	}
+
+	if turn.SuggestedConsequences == nil {
+		turn.SuggestedConsequences = []string{}
+	}
+	if turn.Choices == nil {
+		turn.Choices = []string{}
+	}
+	return turn, nil
+}
@@ -0,0 +1,45 @@
+package ai
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	gamecontext "ai-rpg-mvp/context"
+)
+
+// SummarizeHistory folds actions into prior, the running summary of a
+// session, and returns the updated summary. It is never cached: every call
+// covers different events.
+func (s *AIService) SummarizeHistory(actions []gamecontext.ActionEvent, prior string) (string, error) {
+	if len(actions) == 0 {
+		return prior, nil
+	}
+
+	events := make([]string, len(actions))
+	for i, action := range actions {
+		event := fmt.Sprintf("- At %s: %s", action.Location, action.Command)
+		if action.Outcome != "" {
+			event += " -> " + action.Outcome
+		}
+		events[i] = event
+	}
+
+	if err := s.checkRateLimit(""); err != nil {
+		return "", err
+	}
+
+	prompt := summaryPrompt(prior, events)
+	summary, err := s.generateWithRetry(context.Background(), func(ctx context.Context) (string, Usage, error) {
+		return s.provider.GenerateGMResponse(ctx, prompt)
+	})
+	if err != nil {
+		return "", err
+	}
+
+	summary = strings.TrimSpace(summary)
+	if summary == "" {
+		return "", fmt.Errorf("empty history summary")
+	}
+	return summary, nil
+}
@@ -0,0 +1,87 @@
+package ai
+
+import (
+	"sync"
+)
+
+// Usage holds the token counts reported for a single provider call
+type Usage struct {
+	InputTokens  int
+	OutputTokens int
+}
+
+// modelUsage accumulates usage for a single model
+type modelUsage struct {
+	requests     int64
+	inputTokens  int64
+	outputTokens int64
+}
+
+// UsageTracker accumulates token usage across provider calls
+type UsageTracker struct {
+	totals          modelUsage
+	byModel         map[string]*modelUsage
+	costPer1KInput  float64
+	costPer1KOutput float64
+	mutex           sync.Mutex
+}
+
+// NewUsageTracker creates a usage tracker. The costs are in dollars per
+// thousand tokens; leave them zero to skip cost estimates.
+func NewUsageTracker(costPer1KInput, costPer1KOutput float64) *UsageTracker {
+	return &UsageTracker{
+		byModel:         make(map[string]*modelUsage),
+		costPer1KInput:  costPer1KInput,
+		costPer1KOutput: costPer1KOutput,
+	}
+}
+
+// Record adds the usage of one call against a model
+func (ut *UsageTracker) Record(model string, usage Usage) {
+	ut.mutex.Lock()
+	defer ut.mutex.Unlock()
+
+	perModel, exists := ut.byModel[model]
+	if !exists {
+		perModel = &modelUsage{}
+		ut.byModel[model] = perModel
+	}
+
+	for _, u := range []*modelUsage{&ut.totals, perModel} {
+		u.requests++
+		u.inputTokens += int64(usage.InputTokens)
+		u.outputTokens += int64(usage.OutputTokens)
+	}
+}
+
+// GetStats returns usage totals and a per-model breakdown
+func (ut *UsageTracker) GetStats() map[string]interface{} {
+	ut.mutex.Lock()
+	defer ut.mutex.Unlock()
+
+	byModel := make(map[string]interface{}, len(ut.byModel))
+	for model, u := range ut.byModel {
+		byModel[model] = ut.usageStats(u)
+	}
+
+	stats := ut.usageStats(&ut.totals)
+	stats["by_model"] = byModel
+	return stats
+}
+
+// usageStats formats accumulated usage, adding a cost estimate when costs are configured
+func (ut *UsageTracker) usageStats(u *modelUsage) map[string]interface{} {
+	stats := map[string]interface{}{
+		"requests":      u.requests,
+		"input_tokens":  u.inputTokens,
+		"output_tokens": u.outputTokens,
+		"total_tokens":  u.inputTokens + u.outputTokens,
+	}
+
+	if ut.costPer1KInput > 0 || ut.costPer1KOutput > 0 {
+		stats["estimated_cost_usd"] = float64(u.inputTokens)/1000*ut.costPer1KInput +
+			float64(u.outputTokens)/1000*ut.costPer1KOutput
+	}
+
+	return stats
+}
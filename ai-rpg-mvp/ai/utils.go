@@ -1,6 +1,8 @@
 package ai
 
 import (
+	"container/list"
+	"log/slog"
 	"sync"
 	"time"
 )
@@ -66,25 +68,117 @@ func (rl *RateLimiter) GetStats() map[string]interface{} {
 	}
 }
 
-// ResponseCache implements a simple in-memory cache with TTL
+// DefaultSessionLimiterIdle is how long a session's bucket is kept without requests
+const DefaultSessionLimiterIdle = 30 * time.Minute
+
+// SessionRateLimiter gives each session its own token bucket so one busy
+// player can't use up everyone else's requests. Buckets that go unused for
+// idleTimeout are dropped.
+type SessionRateLimiter struct {
+	limiters    map[string]*sessionBucket
+	maxRequests int
+	duration    time.Duration
+	idleTimeout time.Duration
+	lastSweep   time.Time
+	mutex       sync.Mutex
+}
+
+// sessionBucket is one session's limiter and when it was last used
+type sessionBucket struct {
+	limiter  *RateLimiter
+	lastUsed time.Time
+}
+
+// NewSessionRateLimiter creates a per-session rate limiter
+func NewSessionRateLimiter(maxRequests int, duration, idleTimeout time.Duration) *SessionRateLimiter {
+	return &SessionRateLimiter{
+		limiters:    make(map[string]*sessionBucket),
+		maxRequests: maxRequests,
+		duration:    duration,
+		idleTimeout: idleTimeout,
+		lastSweep:   time.Now(),
+	}
+}
+
+// Allow checks if a request from the session is allowed by its own rate limit
+func (sl *SessionRateLimiter) Allow(sessionID string) bool {
+	sl.mutex.Lock()
+	defer sl.mutex.Unlock()
+
+	now := time.Now()
+	if sl.idleTimeout > 0 && now.Sub(sl.lastSweep) >= sl.idleTimeout {
+		sl.removeIdle(now)
+	}
+
+	bucket, exists := sl.limiters[sessionID]
+	if !exists {
+		bucket = &sessionBucket{limiter: NewRateLimiter(sl.maxRequests, sl.duration)}
+		sl.limiters[sessionID] = bucket
+	}
+	bucket.lastUsed = now
+
+	return bucket.limiter.Allow()
+}
+
+// removeIdle drops the buckets of sessions idle for longer than idleTimeout
+func (sl *SessionRateLimiter) removeIdle(now time.Time) {
+	for sessionID, bucket := range sl.limiters {
+		if now.Sub(bucket.lastUsed) > sl.idleTimeout {
+			delete(sl.limiters, sessionID)
+		}
+	}
+	sl.lastSweep = now
+}
+
+// GetStats returns per-session rate limiter statistics
+func (sl *SessionRateLimiter) GetStats() map[string]interface{} {
+	sl.mutex.Lock()
+	defer sl.mutex.Unlock()
+
+	return map[string]interface{}{
+		"active_sessions":  len(sl.limiters),
+		"max_tokens":       sl.maxRequests,
+		"refill_rate_ms":   (sl.duration / time.Duration(sl.maxRequests)).Milliseconds(),
+		"idle_timeout_sec": sl.idleTimeout.Seconds(),
+	}
+}
+
+// ResponseCache implements a simple in-memory cache with TTL and optional
+// least-recently-used eviction
 type ResponseCache struct {
-	cache   map[string]cacheEntry
-	ttl     time.Duration
-	mutex   sync.RWMutex
-	hits    int64
-	misses  int64
+	cache      map[string]*list.Element
+	lru        *list.List // front is most recently used
+	ttl        time.Duration
+	maxEntries int // 0 means unlimited
+	mutex      sync.RWMutex
+	hits       int64
+	misses     int64
+	evictions  int64
+
+	// Disk persistence, only used by caches from NewPersistentResponseCache
+	path       string
+	flushTimer *time.Timer
+	logger     *slog.Logger
+
+	done      chan struct{}
+	closeOnce sync.Once
 }
 
 type cacheEntry struct {
+	key       string
 	value     string
 	timestamp time.Time
 }
 
-// NewResponseCache creates a new response cache
-func NewResponseCache(ttl time.Duration) *ResponseCache {
+// NewResponseCache creates a new response cache holding at most maxEntries
+// entries, or unlimited entries when maxEntries is 0
+func NewResponseCache(ttl time.Duration, maxEntries int) *ResponseCache {
 	cache := &ResponseCache{
-		cache: make(map[string]cacheEntry),
-		ttl:   ttl,
+		cache:      make(map[string]*list.Element),
+		lru:        list.New(),
+		ttl:        ttl,
+		maxEntries: maxEntries,
+		done:       make(chan struct{}),
 	}
 
 	// Start background cleanup goroutine
@@ -93,36 +187,87 @@ func NewResponseCache(ttl time.Duration) *ResponseCache {
 	return cache
 }
 
-// Get retrieves a value from the cache
+// Get retrieves a value from the cache, marking it as recently used
 func (rc *ResponseCache) Get(key string) string {
-	rc.mutex.RLock()
-	defer rc.mutex.RUnlock()
+	rc.mutex.Lock()
+	defer rc.mutex.Unlock()
 
-	entry, exists := rc.cache[key]
+	element, exists := rc.cache[key]
 	if !exists {
 		rc.misses++
 		return ""
 	}
 
 	// Check if entry has expired
+	entry := element.Value.(*cacheEntry)
 	if time.Since(entry.timestamp) > rc.ttl {
 		rc.misses++
 		return ""
 	}
 
+	rc.lru.MoveToFront(element)
 	rc.hits++
 	return entry.value
 }
 
-// Set stores a value in the cache
+// Set stores a value in the cache, evicting the least recently used entry
+// when the cache is full
 func (rc *ResponseCache) Set(key, value string) {
 	rc.mutex.Lock()
 	defer rc.mutex.Unlock()
 
-	rc.cache[key] = cacheEntry{
+	rc.put(key, value, time.Now())
+	rc.scheduleFlush()
+}
+
+// put inserts or refreshes an entry. The caller must hold the mutex.
+func (rc *ResponseCache) put(key, value string, timestamp time.Time) {
+	if element, exists := rc.cache[key]; exists {
+		entry := element.Value.(*cacheEntry)
+		entry.value = value
+		entry.timestamp = timestamp
+		rc.lru.MoveToFront(element)
+		return
+	}
+
+	if rc.maxEntries > 0 && rc.lru.Len() >= rc.maxEntries {
+		rc.removeElement(rc.lru.Back())
+		rc.evictions++
+	}
+
+	rc.cache[key] = rc.lru.PushFront(&cacheEntry{
+		key:       key,
 		value:     value,
-		timestamp: time.Now(),
+		timestamp: timestamp,
+	})
+}
+
+// removeElement drops an entry from the cache. The caller must hold the mutex.
+func (rc *ResponseCache) removeElement(element *list.Element) {
+	rc.lru.Remove(element)
+	delete(rc.cache, element.Value.(*cacheEntry).key)
+}
+
+// Delete removes key from the cache, doing nothing when it isn't cached
+func (rc *ResponseCache) Delete(key string) {
+	rc.mutex.Lock()
+	defer rc.mutex.Unlock()
+
+	element, exists := rc.cache[key]
+	if !exists {
+		return
 	}
+	rc.removeElement(element)
+	rc.scheduleFlush()
+}
+
+// contains reports whether key has an entry, expired or not
+func (rc *ResponseCache) contains(key string) bool {
+	rc.mutex.RLock()
+	defer rc.mutex.RUnlock()
+
+	_, exists := rc.cache[key]
+	return exists
 }
 
 // GetStats returns cache statistics
@@ -137,11 +282,13 @@ func (rc *ResponseCache) GetStats() map[string]interface{} {
 	}
 
 	return map[string]interface{}{
-		"hits":      rc.hits,
-		"misses":    rc.misses,
-		"hit_rate":  hitRate,
-		"size":      len(rc.cache),
-		"ttl_hours": rc.ttl.Hours(),
+		"hits":        rc.hits,
+		"misses":      rc.misses,
+		"hit_rate":    hitRate,
+		"evictions":   rc.evictions,
+		"size":        len(rc.cache),
+		"max_entries": rc.maxEntries,
+		"ttl_hours":   rc.ttl.Hours(),
 	}
 }
 
@@ -150,15 +297,20 @@ func (rc *ResponseCache) cleanup() {
 	ticker := time.NewTicker(rc.ttl / 2) // Clean up twice per TTL period
 	defer ticker.Stop()
 
-	for range ticker.C {
-		rc.mutex.Lock()
-		now := time.Now()
-		for key, entry := range rc.cache {
-			if now.Sub(entry.timestamp) > rc.ttl {
-				delete(rc.cache, key)
+	for {
+		select {
+		case <-ticker.C:
+			rc.mutex.Lock()
+			now := time.Now()
+			for _, element := range rc.cache {
+				if now.Sub(element.Value.(*cacheEntry).timestamp) > rc.ttl {
+					rc.removeElement(element)
+				}
 			}
+			rc.mutex.Unlock()
+		case <-rc.done:
+			return
 		}
-		rc.mutex.Unlock()
 	}
 }
 
@@ -167,7 +319,11 @@ func (rc *ResponseCache) Clear() {
 	rc.mutex.Lock()
 	defer rc.mutex.Unlock()
 
-	rc.cache = make(map[string]cacheEntry)
+	rc.cache = make(map[string]*list.Element)
+	rc.lru.Init()
 	rc.hits = 0
 	rc.misses = 0
+	rc.evictions = 0
+
+	rc.scheduleFlush()
 }
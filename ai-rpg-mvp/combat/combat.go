@@ -0,0 +1,139 @@
+// Package combat resolves attacks with dice rolls.
+package combat
+
+import (
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// CombatStats are the numbers a combatant brings to an attack
+type CombatStats struct {
+	AttackBonus int `json:"attack_bonus"` // added to the d20 attack roll
+	Defense     int `json:"defense"`      // total an attack must meet to hit
+	DamageDie   int `json:"damage_die"`   // sides on the damage die, e.g. 6 for a d6
+	DamageBonus int `json:"damage_bonus"` // added to damage on a hit
+}
+
+// AttackResult is the outcome of a single attack roll
+type AttackResult struct {
+	Roll     int  `json:"roll"`    // natural d20 roll
+	Total    int  `json:"total"`   // roll plus attack bonus
+	Defense  int  `json:"defense"` // defense the total was compared against
+	Hit      bool `json:"hit"`
+	Critical bool `json:"critical"` // natural 20: always hits and doubles damage dice
+	Fumble   bool `json:"fumble"`   // natural 1: always misses
+	Damage   int  `json:"damage"`
+
+	// Counter is the defender's riposte when the attack misses
+	Counter *AttackResult `json:"counter,omitempty"`
+}
+
+// Modifier converts an attribute score to a roll modifier, D20-style:
+// 10 is +0, every two points above or below shifts it by one
+func Modifier(attribute int) int {
+	if attribute < 10 {
+		return (attribute - 11) / 2
+	}
+	return (attribute - 10) / 2
+}
+
+// Roller rolls dice from a random source. It is safe for concurrent use.
+type Roller struct {
+	rng   *rand.Rand
+	mutex sync.Mutex
+}
+
+// NewRoller creates a roller drawing from src, which makes rolls reproducible
+// for a fixed seed
+func NewRoller(src rand.Source) *Roller {
+	return &Roller{rng: rand.New(src)}
+}
+
+// defaultRoller backs the package-level helpers
+var defaultRoller = NewRoller(rand.NewSource(time.Now().UnixNano()))
+
+// ResolveAttack resolves an attack using the default roller
+func ResolveAttack(attacker, defender CombatStats) AttackResult {
+	return defaultRoller.ResolveAttack(attacker, defender)
+}
+
+// Roll rolls a single die with the given number of sides
+func (r *Roller) Roll(sides int) int {
+	if sides < 1 {
+		return 0
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.rng.Intn(sides) + 1
+}
+
+// ResolveAttack rolls d20 plus the attacker's bonus against the defender's
+// defense and rolls damage on a hit
+func (r *Roller) ResolveAttack(attacker, defender CombatStats) AttackResult {
+	roll := r.Roll(20)
+	result := AttackResult{
+		Roll:     roll,
+		Total:    roll + attacker.AttackBonus,
+		Defense:  defender.Defense,
+		Critical: roll == 20,
+		Fumble:   roll == 1,
+	}
+
+	switch {
+	case result.Critical:
+		result.Hit = true
+	case result.Fumble:
+		result.Hit = false
+	default:
+		result.Hit = result.Total >= defender.Defense
+	}
+
+	if result.Hit {
+		damage := r.Roll(attacker.DamageDie)
+		if result.Critical {
+			damage += r.Roll(attacker.DamageDie)
+		}
+		damage += attacker.DamageBonus
+		if damage < 1 {
+			damage = 1
+		}
+		result.Damage = damage
+	}
+
+	return result
+}
+
+// ResolveExchange resolves an attack and, if it misses, the defender's
+// counterattack
+func (r *Roller) ResolveExchange(attacker, defender CombatStats) AttackResult {
+	result := r.ResolveAttack(attacker, defender)
+	if !result.Hit {
+		counter := r.ResolveAttack(defender, attacker)
+		result.Counter = &counter
+	}
+	return result
+}
+
+// Describe summarizes the result for narration, from the attacker's view
+func (a AttackResult) Describe() string {
+	var outcome string
+	switch {
+	case a.Critical:
+		outcome = fmt.Sprintf("critical hit for %d damage", a.Damage)
+	case a.Hit:
+		outcome = fmt.Sprintf("hit for %d damage", a.Damage)
+	case a.Fumble:
+		outcome = "fumbled the attack"
+	default:
+		outcome = "missed"
+	}
+
+	description := fmt.Sprintf("rolled %d (total %d vs defense %d): %s", a.Roll, a.Total, a.Defense, outcome)
+	if a.Counter != nil {
+		description += "; the defender struck back and " + a.Counter.Describe()
+	}
+	return description
+}
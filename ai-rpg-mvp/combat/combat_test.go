@@ -0,0 +1,84 @@
+package combat
+
+import (
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+func TestResolveAttack_Seeded(t *testing.T) {
+	attacker := CombatStats{AttackBonus: 2, DamageDie: 8, DamageBonus: 1}
+	defender := CombatStats{Defense: 12}
+
+	tests := []struct {
+		seed     int64
+		roll     int
+		hit      bool
+		critical bool
+		fumble   bool
+		damage   int
+	}{
+		{seed: 1, roll: 2, hit: false},
+		{seed: 4, roll: 10, hit: true, damage: 6},
+		{seed: 11, roll: 1, hit: false, fumble: true},
+		{seed: 103, roll: 20, hit: true, critical: true, damage: 11},
+	}
+
+	for _, tt := range tests {
+		result := NewRoller(rand.NewSource(tt.seed)).ResolveAttack(attacker, defender)
+
+		if result.Roll != tt.roll {
+			t.Errorf("Seed %d: expected roll %d, got %d", tt.seed, tt.roll, result.Roll)
+		}
+		if result.Total != tt.roll+attacker.AttackBonus {
+			t.Errorf("Seed %d: expected total %d, got %d", tt.seed, tt.roll+attacker.AttackBonus, result.Total)
+		}
+		if result.Hit != tt.hit || result.Critical != tt.critical || result.Fumble != tt.fumble {
+			t.Errorf("Seed %d: expected hit=%v critical=%v fumble=%v, got %+v", tt.seed, tt.hit, tt.critical, tt.fumble, result)
+		}
+		if result.Damage != tt.damage {
+			t.Errorf("Seed %d: expected damage %d, got %d", tt.seed, tt.damage, result.Damage)
+		}
+	}
+}
+
+func TestResolveAttack_Reproducible(t *testing.T) {
+	attacker := CombatStats{AttackBonus: 3, DamageDie: 6}
+	defender := CombatStats{Defense: 13}
+
+	first := NewRoller(rand.NewSource(42))
+	second := NewRoller(rand.NewSource(42))
+	for i := 0; i < 20; i++ {
+		a := first.ResolveAttack(attacker, defender)
+		b := second.ResolveAttack(attacker, defender)
+		if a != b {
+			t.Fatalf("Attack %d: expected identical results for the same seed, got %+v and %+v", i, a, b)
+		}
+	}
+}
+
+func TestResolveExchange_CounterOnMiss(t *testing.T) {
+	player := CombatStats{Defense: 10, DamageDie: 6}
+	goblin := CombatStats{AttackBonus: 2, Defense: 12, DamageDie: 6}
+
+	result := NewRoller(rand.NewSource(1)).ResolveExchange(player, goblin)
+	if result.Hit {
+		t.Fatalf("Expected the attack to miss, got %+v", result)
+	}
+	if result.Counter == nil || !result.Counter.Hit || result.Counter.Damage != 6 {
+		t.Fatalf("Expected a counterattack hitting for 6, got %+v", result.Counter)
+	}
+
+	if !strings.Contains(result.Describe(), "struck back and rolled 8") {
+		t.Errorf("Expected description to include the counterattack, got %q", result.Describe())
+	}
+}
+
+func TestModifier(t *testing.T) {
+	tests := map[int]int{1: -5, 8: -1, 9: -1, 10: 0, 11: 0, 12: 1, 15: 2, 20: 5}
+	for attribute, expected := range tests {
+		if got := Modifier(attribute); got != expected {
+			t.Errorf("Modifier(%d): expected %d, got %d", attribute, expected, got)
+		}
+	}
+}
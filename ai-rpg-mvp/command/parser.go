@@ -0,0 +1,214 @@
+package command
+
+import (
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Unknown is the action type and target of a command no verb matches
+const Unknown = "unknown"
+
+// Command is a player command broken into what the game records for it
+type Command struct {
+	Verb         string   // registered verb, so aliases share one name
+	Args         []string // words after the verb
+	ActionType   string
+	Target       string
+	Consequences []string
+}
+
+// TargetExtractor picks a command's target from the words after its verb
+type TargetExtractor func(args []string) string
+
+// CommandSpec describes how commands starting with a verb are interpreted
+type CommandSpec struct {
+	ActionType   string
+	Target       TargetExtractor // nil uses FirstArg
+	Consequences []string
+	Aliases      []string // other verbs parsed the same way
+}
+
+// FirstArg targets the first word after the verb, or nothing
+func FirstArg(args []string) string {
+	if len(args) == 0 {
+		return ""
+	}
+	return args[0]
+}
+
+// FirstArgOr targets the first word after the verb, or fallback when there is none
+func FirstArgOr(fallback string) TargetExtractor {
+	return func(args []string) string {
+		if len(args) == 0 {
+			return fallback
+		}
+		return args[0]
+	}
+}
+
+// Fixed always targets target, whatever follows the verb
+func Fixed(target string) TargetExtractor {
+	return func([]string) string {
+		return target
+	}
+}
+
+// Parser maps command verbs to action types, targets and consequences. It is
+// safe for concurrent use.
+type Parser struct {
+	mutex sync.RWMutex
+	verbs map[string]registeredVerb // verb or alias -> spec
+}
+
+type registeredVerb struct {
+	name string // the verb the spec was registered under
+	spec CommandSpec
+}
+
+// NewParser creates a parser that knows the standard game commands
+func NewParser() *Parser {
+	p := &Parser{verbs: make(map[string]registeredVerb)}
+
+	p.RegisterCommand("look", CommandSpec{
+		ActionType:   "examine",
+		Target:       lookTarget,
+		Consequences: []string{"exploration_success"},
+		Aliases:      []string{"examine"},
+	})
+	p.RegisterCommand("search", CommandSpec{
+		ActionType:   "examine",
+		Target:       FirstArgOr("environment"),
+		Consequences: []string{"item_gained", "exploration_success"},
+	})
+	p.RegisterCommand("talk", CommandSpec{
+		ActionType:   "social",
+		Consequences: []string{"social_success", "npc_noticed"},
+		Aliases:      []string{"speak"},
+	})
+	p.RegisterCommand("attack", CommandSpec{
+		ActionType:   "combat",
+		Consequences: []string{"combat_success", "reputation_increase"},
+		Aliases:      []string{"fight"},
+	})
+	p.RegisterCommand("move", CommandSpec{
+		ActionType:   "move",
+		Consequences: []string{"location_change"},
+		Aliases:      []string{"go"},
+	})
+	p.RegisterCommand("inventory", CommandSpec{
+		ActionType: "examine",
+		Target:     Fixed("inventory"),
+		Aliases:    []string{"inv"},
+	})
+	p.RegisterCommand("respawn", CommandSpec{
+		ActionType: "respawn",
+		Target:     Fixed("player"),
+	})
+
+	return p
+}
+
+// lookTarget targets what the player looks at; "/look" and "/look around"
+// take in the surroundings
+func lookTarget(args []string) string {
+	if len(args) == 0 || args[0] == "around" {
+		return "environment"
+	}
+	return args[0]
+}
+
+// RegisterCommand adds a verb and its aliases, replacing any earlier
+// registration of the same words. Verbs are matched without the leading
+// slash and case-insensitively.
+func (p *Parser) RegisterCommand(verb string, spec CommandSpec) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	verb = normalizeVerb(verb)
+	entry := registeredVerb{name: verb, spec: spec}
+	p.verbs[verb] = entry
+	for _, alias := range spec.Aliases {
+		p.verbs[normalizeVerb(alias)] = entry
+	}
+}
+
+// Parse interprets a command such as "/talk tavern_keeper". Commands whose
+// verb isn't registered get the Unknown action type and target.
+func (p *Parser) Parse(input string) Command {
+	fields := strings.Fields(input)
+	if len(fields) == 0 {
+		return unknownCommand("", nil)
+	}
+
+	verb, args := normalizeVerb(fields[0]), fields[1:]
+
+	p.mutex.RLock()
+	entry, ok := p.verbs[verb]
+	p.mutex.RUnlock()
+	if !ok {
+		return unknownCommand(verb, args)
+	}
+
+	target := entry.spec.Target
+	if target == nil {
+		target = FirstArg
+	}
+
+	return Command{
+		Verb:         entry.name,
+		Args:         args,
+		ActionType:   entry.spec.ActionType,
+		Target:       target(args),
+		Consequences: append([]string{}, entry.spec.Consequences...),
+	}
+}
+
+// Verbs returns the registered verbs, without aliases, in alphabetical order
+func (p *Parser) Verbs() []string {
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+
+	var verbs []string
+	for word, entry := range p.verbs {
+		if word == entry.name {
+			verbs = append(verbs, word)
+		}
+	}
+	sort.Strings(verbs)
+	return verbs
+}
+
+// unknownCommand is the result for a verb nothing is registered under
+func unknownCommand(verb string, args []string) Command {
+	return Command{
+		Verb:         verb,
+		Args:         args,
+		ActionType:   Unknown,
+		Target:       Unknown,
+		Consequences: []string{},
+	}
+}
+
+// normalizeVerb strips the leading slash and lowercases a verb
+func normalizeVerb(verb string) string {
+	return strings.ToLower(strings.TrimPrefix(verb, "/"))
+}
+
+// defaultParser backs the package-level functions
+var defaultParser = NewParser()
+
+// RegisterCommand adds a verb to the parser shared by the game servers
+func RegisterCommand(verb string, spec CommandSpec) {
+	defaultParser.RegisterCommand(verb, spec)
+}
+
+// Parse interprets a command with the parser shared by the game servers
+func Parse(input string) Command {
+	return defaultParser.Parse(input)
+}
+
+// Verbs returns the verbs registered with the parser shared by the game servers
+func Verbs() []string {
+	return defaultParser.Verbs()
+}
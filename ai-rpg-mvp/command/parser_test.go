@@ -0,0 +1,74 @@
+package command
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParser_Aliases(t *testing.T) {
+	parser := NewParser()
+
+	look := parser.Parse("/look")
+	examine := parser.Parse("/examine")
+	if !reflect.DeepEqual(look, examine) {
+		t.Errorf("Expected /look and /examine to parse alike, got %+v and %+v", look, examine)
+	}
+	if look.Verb != "look" || look.ActionType != "examine" || look.Target != "environment" {
+		t.Errorf("Unexpected parse of /look: %+v", look)
+	}
+
+	if around := parser.Parse("/look around"); around.Target != "environment" {
+		t.Errorf("Expected /look around to target the environment, got %q", around.Target)
+	}
+	if fight := parser.Parse("/FIGHT goblin"); fight.Verb != "attack" || fight.ActionType != "combat" {
+		t.Errorf("Expected /FIGHT to parse as attack, got %+v", fight)
+	}
+}
+
+func TestParser_TargetExtraction(t *testing.T) {
+	parser := NewParser()
+
+	talk := parser.Parse("/talk tavern_keeper")
+	if talk.ActionType != "social" || talk.Target != "tavern_keeper" {
+		t.Errorf("Expected a social command aimed at tavern_keeper, got %+v", talk)
+	}
+	if want := []string{"social_success", "npc_noticed"}; !reflect.DeepEqual(talk.Consequences, want) {
+		t.Errorf("Expected consequences %v, got %v", want, talk.Consequences)
+	}
+
+	if inventory := parser.Parse("/inv"); inventory.Target != "inventory" || len(inventory.Consequences) != 0 {
+		t.Errorf("Unexpected parse of /inv: %+v", inventory)
+	}
+}
+
+func TestParser_UnknownCommand(t *testing.T) {
+	parser := NewParser()
+
+	for _, input := range []string{"/dance wildly", "", "/looking"} {
+		parsed := parser.Parse(input)
+		if parsed.ActionType != Unknown || parsed.Target != Unknown || parsed.Consequences == nil || len(parsed.Consequences) != 0 {
+			t.Errorf("Expected %q to fall back to unknown, got %+v", input, parsed)
+		}
+	}
+}
+
+func TestParser_RegisterCommand(t *testing.T) {
+	parser := NewParser()
+	parser.RegisterCommand("/rest", CommandSpec{
+		ActionType:   "rest",
+		Target:       Fixed("camp"),
+		Consequences: []string{"rest"},
+		Aliases:      []string{"sleep"},
+	})
+
+	parsed := parser.Parse("/sleep under the stars")
+	if parsed.Verb != "rest" || parsed.ActionType != "rest" || parsed.Target != "camp" {
+		t.Errorf("Unexpected parse of a registered alias: %+v", parsed)
+	}
+
+	// Callers may change the consequences they get back without affecting the spec
+	parsed.Consequences[0] = "changed"
+	if again := parser.Parse("/rest"); again.Consequences[0] != "rest" {
+		t.Errorf("Expected registered consequences to be unaffected, got %v", again.Consequences)
+	}
+}
@@ -0,0 +1,241 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeConfigFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write config file: %v", err)
+	}
+	return path
+}
+
+func TestLoadConfigFromFile_JSON(t *testing.T) {
+	path := writeConfigFile(t, "config.json", `{
+		"server": {"port": 9090, "read_timeout": "45s"},
+		"context": {"max_actions": 75, "cache_timeout": "1h"},
+		"ai": {"provider": "ollama", "model": "llama3", "timeout": 5000000000}
+	}`)
+
+	cfg, err := LoadConfigFromFile(path)
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+
+	if cfg.Server.Port != 9090 {
+		t.Errorf("Expected port 9090, got %d", cfg.Server.Port)
+	}
+	if cfg.Server.ReadTimeout != 45*time.Second {
+		t.Errorf("Expected read timeout 45s, got %v", cfg.Server.ReadTimeout)
+	}
+	if cfg.AI.Timeout != 5*time.Second {
+		t.Errorf("Expected AI timeout 5s from nanoseconds, got %v", cfg.AI.Timeout)
+	}
+	if cfg.Context.MaxActions != 75 || cfg.AI.Model != "llama3" {
+		t.Errorf("Expected file values, got max actions %d and model %s", cfg.Context.MaxActions, cfg.AI.Model)
+	}
+
+	// Values the file leaves out keep their defaults
+	if cfg.Server.Host != "0.0.0.0" || cfg.Context.MaxDialogue != 20 {
+		t.Errorf("Expected defaults for unset values, got host %s and max dialogue %d", cfg.Server.Host, cfg.Context.MaxDialogue)
+	}
+	if cfg.Redis.ContextTTL != time.Hour {
+		t.Errorf("Expected Redis context TTL to follow the cache timeout, got %v", cfg.Redis.ContextTTL)
+	}
+}
+
+func TestLoadConfigFromFile_YAML(t *testing.T) {
+	path := writeConfigFile(t, "config.yaml", `
+server:
+  port: 7070
+  write_timeout: 2m
+ai:
+  provider: ollama
+  temperature: 0.2
+  fallbacks: [openai]
+  fallback_settings:
+    openai:
+      api_key: sk-test
+      model: gpt-4
+`)
+
+	cfg, err := LoadConfigFromFile(path)
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+
+	if cfg.Server.Port != 7070 || cfg.Server.WriteTimeout != 2*time.Minute {
+		t.Errorf("Expected port 7070 and write timeout 2m, got %d and %v", cfg.Server.Port, cfg.Server.WriteTimeout)
+	}
+	if cfg.AI.Temperature != 0.2 {
+		t.Errorf("Expected temperature 0.2, got %v", cfg.AI.Temperature)
+	}
+
+	fallbacks := cfg.AI.FallbackProviders()
+	if len(fallbacks) != 1 || fallbacks[0].Provider != "openai" || fallbacks[0].APIKey != "sk-test" {
+		t.Errorf("Expected openai fallback with key from file, got %+v", fallbacks)
+	}
+}
+
+func TestLoadConfigFromFile_EnvOverridesFile(t *testing.T) {
+	path := writeConfigFile(t, "config.json", `{
+		"server": {"port": 9090},
+		"ai": {"provider": "ollama", "timeout": "10s"}
+	}`)
+
+	t.Setenv("PORT", "6060")
+	t.Setenv("AI_TIMEOUT", "3s")
+
+	cfg, err := LoadConfigFromFile(path)
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+
+	if cfg.Server.Port != 6060 {
+		t.Errorf("Expected env port 6060, got %d", cfg.Server.Port)
+	}
+	if cfg.AI.Timeout != 3*time.Second {
+		t.Errorf("Expected env AI timeout 3s, got %v", cfg.AI.Timeout)
+	}
+}
+
+func TestLoadConfig_StorageBackend(t *testing.T) {
+	if backend := LoadConfig().Context.StorageBackend; backend != "memory" {
+		t.Errorf("Expected memory storage by default, got %q", backend)
+	}
+
+	// The older Redis switch still selects Redis
+	t.Setenv("REDIS_ENABLED", "true")
+	if backend := LoadConfig().Context.StorageBackend; backend != "redis" {
+		t.Errorf("Expected REDIS_ENABLED to select redis, got %q", backend)
+	}
+
+	t.Setenv("STORAGE_BACKEND", "postgres")
+	if backend := LoadConfig().Context.StorageBackend; backend != "postgres" {
+		t.Errorf("Expected STORAGE_BACKEND to win, got %q", backend)
+	}
+}
+
+func TestLoadConfigFromFile_Errors(t *testing.T) {
+	if _, err := LoadConfigFromFile(filepath.Join(t.TempDir(), "missing.json")); err == nil {
+		t.Error("Expected error for a missing file")
+	}
+
+	badDuration := writeConfigFile(t, "config.json", `{"ai": {"provider": "ollama", "timeout": "soon"}}`)
+	if _, err := LoadConfigFromFile(badDuration); err == nil {
+		t.Error("Expected error for an invalid duration")
+	}
+
+	// The loaded configuration goes through Validate
+	invalid := writeConfigFile(t, "config.yml", "server:\n  port: 70000\nai:\n  provider: ollama\n")
+	if _, err := LoadConfigFromFile(invalid); err == nil {
+		t.Error("Expected validation error for an out-of-range port")
+	}
+}
+
+// validConfig returns a configuration that passes Validate
+func validConfig() *Config {
+	cfg := defaultConfig()
+	cfg.AI.APIKey = "sk-test"
+	return cfg
+}
+
+func TestValidate_ValidConfig(t *testing.T) {
+	if err := validConfig().Validate(); err != nil {
+		t.Errorf("Expected valid config, got error: %v", err)
+	}
+}
+
+func TestValidate_MockProviderNeedsNoKey(t *testing.T) {
+	cfg := defaultConfig()
+	cfg.AI.Provider = "mock"
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("Expected the mock provider to run without a key, got %v", err)
+	}
+
+	cfg.AI.Provider = "claude"
+	if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), "API key") {
+		t.Errorf("Expected a real provider to still need a key, got %v", err)
+	}
+}
+
+func TestValidate_UncappedRetryDelay(t *testing.T) {
+	cfg := validConfig()
+	cfg.AI.RetryMaxDelay = 0
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("Expected a zero retry max delay to mean uncapped, got %v", err)
+	}
+}
+
+func TestValidate_InvalidAISettings(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(*Config)
+		want   string
+	}{
+		{"temperature too high", func(c *Config) { c.AI.Temperature = 9.9 }, "temperature"},
+		{"negative temperature", func(c *Config) { c.AI.Temperature = -0.1 }, "temperature"},
+		{"negative max tokens", func(c *Config) { c.AI.MaxTokens = -5 }, "max tokens"},
+		{"zero max tokens", func(c *Config) { c.AI.MaxTokens = 0 }, "max tokens"},
+		{"unknown provider", func(c *Config) { c.AI.Provider = "skynet" }, "unsupported AI provider"},
+		{"negative rate limit", func(c *Config) { c.AI.RateLimitRequests = -1 }, "rate limit"},
+		{"unknown queue policy", func(c *Config) { c.Context.EventQueuePolicy = "shrug" }, "event queue policy"},
+		{"blocking without timeout", func(c *Config) { c.Context.EventQueueTimeout = 0 }, "event queue timeout"},
+		{"retry cap below retry delay", func(c *Config) { c.AI.RetryMaxDelay = c.AI.RetryDelay / 2 }, "retry max delay"},
+		{"negative retry cap", func(c *Config) { c.AI.RetryMaxDelay = -time.Second }, "retry max delay"},
+		{"negative prompt budget", func(c *Config) { c.Context.MaxPromptTokens = -1 }, "max prompt tokens"},
+		{"summary threshold at max actions", func(c *Config) { c.Context.SummaryThreshold = c.Context.MaxActions }, "summary threshold"},
+		{"summary batch over threshold", func(c *Config) { c.Context.SummaryBatch = c.Context.SummaryThreshold + 1 }, "summary batch"},
+		{"no idempotency keys", func(c *Config) { c.Context.IdempotencyKeys = 0 }, "idempotency key"},
+		{"death gold penalty over 100", func(c *Config) { c.Context.DeathGoldPenalty = 150 }, "death gold penalty"},
+		{"negative death reputation penalty", func(c *Config) { c.Context.DeathReputationPenalty = -1 }, "death reputation penalty"},
+		{"no rest healing", func(c *Config) { c.Context.RestHealPerHour = 0 }, "rest heal per hour"},
+		{"rest encounter chance over 100", func(c *Config) { c.Context.RestEncounterChance = 101 }, "rest encounter chance"},
+		{"negative session idle TTL", func(c *Config) { c.Context.SessionIdleTTL = -time.Minute }, "session idle TTL"},
+		{"expiry warning longer than TTL", func(c *Config) { c.Context.SessionIdleTTL = time.Minute; c.Context.SessionExpiryWarning = time.Hour }, "session expiry warning"},
+		{"negative MCP AI tool cooldown", func(c *Config) { c.MCP.AIToolCooldown = -time.Second }, "AI tool cooldown"},
+		{"wildcard CORS origin with credentials", func(c *Config) { c.Server.CORS.AllowCredentials = true }, "CORS credentials"},
+		{"unknown log level", func(c *Config) { c.Logging.Level = "verbose" }, "log level"},
+		{"unknown log format", func(c *Config) { c.Logging.Format = "xml" }, "log format"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := validConfig()
+			tt.modify(cfg)
+
+			err := cfg.Validate()
+			if err == nil {
+				t.Fatal("Expected validation error")
+			}
+			if !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Expected error mentioning %q, got %v", tt.want, err)
+			}
+		})
+	}
+}
+
+func TestValidate_ReportsAllProblems(t *testing.T) {
+	cfg := validConfig()
+	cfg.Server.Port = 0
+	cfg.AI.Temperature = 3
+	cfg.AI.MaxTokens = -5
+
+	err := cfg.Validate()
+	if err == nil {
+		t.Fatal("Expected validation error")
+	}
+
+	for _, want := range []string{"server port", "temperature", "max tokens"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("Expected error to mention %q, got %v", want, err)
+		}
+	}
+}
@@ -0,0 +1,88 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// LoadConfigFromFile loads configuration from a JSON or YAML file (chosen by
+// extension) on top of the defaults, then applies environment variables so
+// they always win. Durations may be written as strings such as "30s".
+func LoadConfigFromFile(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	var raw map[string]interface{}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &raw)
+	default:
+		err = json.Unmarshal(data, &raw)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse config file %s: %w", path, err)
+	}
+
+	if err := normalizeDurations(raw, reflect.TypeOf(Config{})); err != nil {
+		return nil, fmt.Errorf("invalid config file %s: %w", path, err)
+	}
+
+	// Round-trip through JSON so both formats decode with the json tags
+	normalized, err := json.Marshal(raw)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode config: %w", err)
+	}
+
+	cfg := defaultConfig()
+	if err := json.Unmarshal(normalized, cfg); err != nil {
+		return nil, fmt.Errorf("invalid config file %s: %w", path, err)
+	}
+	cfg.applyEnv()
+
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// normalizeDurations rewrites duration strings in raw into nanosecond counts,
+// following the json tags of t to find which keys hold durations
+func normalizeDurations(raw map[string]interface{}, t reflect.Type) error {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		key := strings.Split(field.Tag.Get("json"), ",")[0]
+		value, ok := raw[key]
+		if key == "" || !ok {
+			continue
+		}
+
+		switch {
+		case field.Type == durationType:
+			if text, isString := value.(string); isString {
+				duration, err := time.ParseDuration(text)
+				if err != nil {
+					return fmt.Errorf("%s: %w", key, err)
+				}
+				raw[key] = int64(duration)
+			}
+		case field.Type.Kind() == reflect.Struct:
+			if nested, isMap := value.(map[string]interface{}); isMap {
+				if err := normalizeDurations(nested, field.Type); err != nil {
+					return fmt.Errorf("%s.%w", key, err)
+				}
+			}
+		}
+	}
+	return nil
+}
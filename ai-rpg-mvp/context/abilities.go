@@ -0,0 +1,232 @@
+package context
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Pools abilities spend from
+const (
+	ResourceMana    = "mana"
+	ResourceStamina = "stamina"
+)
+
+// What an ability does when used
+const (
+	AbilityDamage = "damage" // hurts the target
+	AbilityHeal   = "heal"   // restores the player's health
+	AbilityBuff   = "buff"   // raises the player's attributes for a while
+)
+
+// DefaultMaxMana and DefaultMaxStamina size the pools of new characters
+const (
+	DefaultMaxMana    = 20
+	DefaultMaxStamina = 20
+)
+
+// resourceRegenPerTick is how much mana and stamina come back on every
+// status tick
+const resourceRegenPerTick = 2
+
+// Ability is a spell or technique the player can use for a cost, after which
+// it needs Cooldown to recover
+type Ability struct {
+	ID       string         `json:"id"`
+	Name     string         `json:"name"`
+	Resource string         `json:"resource"` // ResourceMana or ResourceStamina
+	Cost     int            `json:"cost"`
+	Cooldown time.Duration  `json:"cooldown"`
+	Effect   string         `json:"effect"`             // AbilityDamage, AbilityHeal or AbilityBuff
+	Amount   int            `json:"amount,omitempty"`   // damage dealt or health restored
+	Buff     map[string]int `json:"buff,omitempty"`     // attribute modifiers a buff grants
+	Duration time.Duration  `json:"duration,omitempty"` // how long a buff lasts
+}
+
+// AbilityResult is what using an ability did
+type AbilityResult struct {
+	Ability       string        `json:"ability"`
+	Target        string        `json:"target,omitempty"`
+	Damage        int           `json:"damage,omitempty"`
+	Healing       int           `json:"healing,omitempty"`
+	Buff          *StatusEffect `json:"buff,omitempty"`
+	ResourceLeft  int           `json:"resource_left"`
+	CooldownUntil time.Time     `json:"cooldown_until"`
+}
+
+// CooldownError reports an ability used again before its cooldown ran out
+type CooldownError struct {
+	Ability   string
+	Remaining time.Duration
+}
+
+func (e *CooldownError) Error() string {
+	return fmt.Sprintf("%s is on cooldown for another %s", e.Ability, e.Remaining.Round(time.Second))
+}
+
+// InsufficientResourceError reports an ability the player can't pay for
+type InsufficientResourceError struct {
+	Ability   string
+	Resource  string
+	Cost      int
+	Available int
+}
+
+func (e *InsufficientResourceError) Error() string {
+	return fmt.Sprintf("not enough %s for %s: it costs %d, only %d available", e.Resource, e.Ability, e.Cost, e.Available)
+}
+
+// defaultAbilities returns the abilities every context manager starts with
+func defaultAbilities() map[string]Ability {
+	return map[string]Ability{
+		"fireball": {
+			ID: "fireball", Name: "Fireball", Resource: ResourceMana, Cost: 6, Cooldown: 10 * time.Second,
+			Effect: AbilityDamage, Amount: 8,
+		},
+		"second_wind": {
+			ID: "second_wind", Name: "Second Wind", Resource: ResourceStamina, Cost: 5, Cooldown: time.Minute,
+			Effect: AbilityHeal, Amount: 6,
+		},
+		"battle_cry": {
+			ID: "battle_cry", Name: "Battle Cry", Resource: ResourceStamina, Cost: 4, Cooldown: 2 * time.Minute,
+			Effect: AbilityBuff, Buff: map[string]int{"strength": 2}, Duration: time.Minute,
+		},
+	}
+}
+
+// Validate checks that the ability has a known pool, effect and cost
+func (a Ability) Validate() error {
+	if a.ID == "" {
+		return fmt.Errorf("ability ID is required")
+	}
+	if a.Resource != ResourceMana && a.Resource != ResourceStamina {
+		return fmt.Errorf("unknown resource %q (supported: %s, %s)", a.Resource, ResourceMana, ResourceStamina)
+	}
+	if a.Cost < 0 || a.Cooldown < 0 {
+		return fmt.Errorf("cost and cooldown cannot be negative")
+	}
+
+	switch a.Effect {
+	case AbilityDamage, AbilityHeal:
+		if a.Amount <= 0 {
+			return fmt.Errorf("a %s ability needs a positive amount, got %d", a.Effect, a.Amount)
+		}
+	case AbilityBuff:
+		if len(a.Buff) == 0 || a.Duration <= 0 {
+			return fmt.Errorf("a buff needs attribute modifiers and a positive duration")
+		}
+	default:
+		return fmt.Errorf("unknown effect %q (supported: %s, %s, %s)", a.Effect, AbilityDamage, AbilityHeal, AbilityBuff)
+	}
+	return nil
+}
+
+// RegisterAbility adds or replaces an ability
+func (cm *ContextManager) RegisterAbility(ability Ability) error {
+	ability.ID = strings.ToLower(strings.TrimSpace(ability.ID))
+	if err := ability.Validate(); err != nil {
+		return fmt.Errorf("invalid ability %s: %w", ability.ID, err)
+	}
+	if ability.Name == "" {
+		ability.Name = ability.ID
+	}
+	ability.Buff = copyIntMap(ability.Buff)
+
+	cm.registryMutex.Lock()
+	defer cm.registryMutex.Unlock()
+
+	cm.abilities[ability.ID] = ability
+	return nil
+}
+
+// UseAbility spends an ability's cost and applies its effect: damage to
+// targetNPC, healing, or a buff lasting its duration. Using an ability on
+// cooldown fails with a *CooldownError and one the player can't pay for with
+// an *InsufficientResourceError; neither changes anything.
+func (cm *ContextManager) UseAbility(sessionID, abilityID, targetNPC string) (AbilityResult, error) {
+	abilityID = strings.ToLower(strings.TrimSpace(abilityID))
+
+	cm.registryMutex.RLock()
+	ability, exists := cm.abilities[abilityID]
+	cm.registryMutex.RUnlock()
+	if !exists {
+		return AbilityResult{}, fmt.Errorf("unknown ability: %s", abilityID)
+	}
+	if ability.Effect == AbilityDamage && targetNPC == "" {
+		return AbilityResult{}, fmt.Errorf("%s needs a target", ability.Name)
+	}
+
+	var result AbilityResult
+	err := cm.mutateContext(sessionID, func(ctx *PlayerContext) error {
+		if err := checkAlive(ctx); err != nil {
+			return err
+		}
+
+		now := cm.now()
+		if readyAt := ctx.Character.Cooldowns[ability.ID]; readyAt.After(now) {
+			return &CooldownError{Ability: ability.Name, Remaining: readyAt.Sub(now)}
+		}
+		pool := resourcePool(&ctx.Character, ability.Resource)
+		if pool.Current < ability.Cost {
+			return &InsufficientResourceError{Ability: ability.Name, Resource: ability.Resource, Cost: ability.Cost, Available: pool.Current}
+		}
+
+		pool.Current -= ability.Cost
+		if ctx.Character.Cooldowns == nil {
+			ctx.Character.Cooldowns = make(map[string]time.Time)
+		}
+		ctx.Character.Cooldowns[ability.ID] = now.Add(ability.Cooldown)
+
+		result = AbilityResult{
+			Ability:       ability.Name,
+			Target:        targetNPC,
+			ResourceLeft:  pool.Current,
+			CooldownUntil: now.Add(ability.Cooldown),
+		}
+		switch ability.Effect {
+		case AbilityDamage:
+			result.Damage = ability.Amount
+		case AbilityHeal:
+			before := ctx.Character.Health.Current
+			ctx.Character.Health.Current = min(ctx.Character.Health.Current+ability.Amount, ctx.Character.Health.Max)
+			result.Healing = ctx.Character.Health.Current - before
+		case AbilityBuff:
+			buff := StatusEffect{
+				Name:               ability.Name,
+				AttributeModifiers: copyIntMap(ability.Buff),
+				AppliedAt:          now,
+				ExpiresAt:          now.Add(ability.Duration),
+			}
+			setStatusEffect(ctx, buff)
+			buff.AttributeModifiers = copyIntMap(ability.Buff)
+			result.Buff = &buff
+		}
+		return nil
+	})
+	if err != nil {
+		return AbilityResult{}, err
+	}
+
+	return result, nil
+}
+
+// resourcePool returns the character's pool for a resource
+func resourcePool(character *CharacterState, resource string) *ResourcePool {
+	if resource == ResourceStamina {
+		return &character.Stamina
+	}
+	return &character.Mana
+}
+
+// regenerateResources restores some mana and stamina, up to their maximums,
+// and reports whether either pool went up
+func regenerateResources(character *CharacterState) bool {
+	regenerated := false
+	for _, pool := range []*ResourcePool{&character.Mana, &character.Stamina} {
+		if pool.Current < pool.Max {
+			pool.Current = min(pool.Current+resourceRegenPerTick, pool.Max)
+			regenerated = true
+		}
+	}
+	return regenerated
+}
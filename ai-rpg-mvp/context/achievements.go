@@ -0,0 +1,166 @@
+package context
+
+import (
+	"fmt"
+	"time"
+)
+
+// ChangeAchievementUnlocked is published when a player unlocks an achievement
+const ChangeAchievementUnlocked = "achievement_unlocked"
+
+// Achievement is a milestone a player has unlocked
+type Achievement struct {
+	ID          string    `json:"id"`
+	Name        string    `json:"name"`
+	Description string    `json:"description"`
+	UnlockedAt  time.Time `json:"unlocked_at"`
+}
+
+// AchievementDef defines a milestone and when it unlocks. Each player can
+// unlock it once.
+type AchievementDef struct {
+	ID           string
+	Name         string
+	Description  string
+	Unlocked     func(ctx *PlayerContext) bool // checked after every recorded action
+	Consequences []string                      // applied like action consequences on unlock
+	Metadata     map[string]interface{}        // parameters for the consequences
+}
+
+// defaultAchievements returns the achievements every context manager starts with
+func defaultAchievements() []AchievementDef {
+	return []AchievementDef{
+		{
+			ID:          "first_blood",
+			Name:        "First Blood",
+			Description: "Won a fight for the first time",
+			Unlocked: func(ctx *PlayerContext) bool {
+				return ctx.SessionStats.CombatVictories >= 1
+			},
+			Consequences: []string{"reputation_increase"},
+		},
+		{
+			ID:          "socialite",
+			Name:        "Socialite",
+			Description: "Took 25 social actions",
+			Unlocked: func(ctx *PlayerContext) bool {
+				return ctx.SessionStats.SocialActions >= 25
+			},
+			Consequences: []string{"reputation_increase"},
+		},
+		{
+			ID:          "wanderer",
+			Name:        "Wanderer",
+			Description: "Visited 10 locations",
+			Unlocked: func(ctx *PlayerContext) bool {
+				return ctx.SessionStats.LocationsVisited >= 10
+			},
+			Consequences: []string{"reputation_increase"},
+		},
+	}
+}
+
+// RegisterAchievement adds an achievement, replacing any with the same ID
+func (cm *ContextManager) RegisterAchievement(def AchievementDef) error {
+	if def.ID == "" {
+		return fmt.Errorf("achievement ID is required")
+	}
+	if def.Unlocked == nil {
+		return fmt.Errorf("achievement %s needs an unlock predicate", def.ID)
+	}
+
+	def.Consequences = copyStrings(def.Consequences)
+	def.Metadata = copyMetadata(def.Metadata)
+
+	cm.registryMutex.Lock()
+	defer cm.registryMutex.Unlock()
+
+	for i, existing := range cm.achievements {
+		if existing.ID == def.ID {
+			cm.achievements[i] = def
+			return nil
+		}
+	}
+	cm.achievements = append(cm.achievements, def)
+	return nil
+}
+
+// GetAchievements returns the achievements a player has unlocked, oldest first
+func (cm *ContextManager) GetAchievements(sessionID string) ([]Achievement, error) {
+	ctx, err := cm.GetContext(sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	return ctx.Achievements, nil
+}
+
+// evaluateAchievements unlocks every achievement whose predicate now holds
+// and that the player doesn't have yet, applying its consequences. The
+// unlocks and their rewards are recorded on effects, when given, so undoing
+// the action that earned them takes them back.
+func (cm *ContextManager) evaluateAchievements(ctx *PlayerContext, effects *ActionEffects) {
+	cm.registryMutex.RLock()
+	defs := append([]AchievementDef{}, cm.achievements...)
+	cm.registryMutex.RUnlock()
+
+	for _, def := range defs {
+		if hasAchievement(ctx, def.ID) || !def.Unlocked(ctx) {
+			continue
+		}
+
+		achievement := Achievement{
+			ID:          def.ID,
+			Name:        def.Name,
+			Description: def.Description,
+			UnlockedAt:  cm.now(),
+		}
+		ctx.Achievements = append(ctx.Achievements, achievement)
+
+		if len(def.Consequences) > 0 {
+			reward := cm.processActionConsequences(ctx, ActionEvent{
+				Type:         "achievement",
+				Target:       def.ID,
+				Consequences: def.Consequences,
+				Metadata:     copyMetadata(def.Metadata),
+			})
+			if effects != nil {
+				if effects.Rewards == nil {
+					effects.Rewards = &ActionEffects{}
+				}
+				effects.Rewards.add(reward)
+			}
+		}
+		if effects != nil {
+			effects.Achievements = append(effects.Achievements, def.ID)
+		}
+
+		cm.publish(ContextChange{
+			Type:        ChangeAchievementUnlocked,
+			SessionID:   ctx.SessionID,
+			Timestamp:   achievement.UnlockedAt,
+			Achievement: &achievement,
+		})
+	}
+}
+
+// hasAchievement reports whether the player already unlocked an achievement
+func hasAchievement(ctx *PlayerContext, achievementID string) bool {
+	for _, achievement := range ctx.Achievements {
+		if achievement.ID == achievementID {
+			return true
+		}
+	}
+	return false
+}
+
+// revokeAchievements removes unlocked achievements by ID
+func revokeAchievements(ctx *PlayerContext, achievementIDs []string) {
+	kept := ctx.Achievements[:0]
+	for _, achievement := range ctx.Achievements {
+		if !contains(achievementIDs, achievement.ID) {
+			kept = append(kept, achievement)
+		}
+	}
+	ctx.Achievements = kept
+}
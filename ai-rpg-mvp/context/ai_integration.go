@@ -2,6 +2,7 @@ package context
 
 import (
 	"fmt"
+	"sort"
 	"strings"
 	"time"
 )
@@ -20,7 +21,7 @@ func (cm *ContextManager) GetContextSummary(sessionID string) (*ContextSummary,
 		PlayerReputation:   ctx.Character.Reputation,
 		RecentActions:      cm.getActionSummary(ctx.Actions, 5),
 		ActiveNPCs:         cm.getRelevantNPCs(ctx),
-		SessionDuration:    time.Since(ctx.StartTime).Minutes(),
+		SessionDuration:    cm.now().Sub(ctx.StartTime).Minutes(),
 		PlayerMood:         cm.determinePlayerMood(ctx),
 		WorldState:         make(map[string]interface{}),
 	}
@@ -31,75 +32,169 @@ func (cm *ContextManager) GetContextSummary(sessionID string) (*ContextSummary,
 	summary.WorldState["combat_experienced"] = ctx.SessionStats.CombatActions > 0
 	summary.WorldState["social_active"] = ctx.SessionStats.SocialActions > ctx.SessionStats.CombatActions
 
+	if world := cm.worldFor(ctx); world != nil {
+		summary.WorldState["world_id"] = world.ID
+		summary.WorldState["players_here"] = world.playersAt(ctx.Location.Current, ctx.SessionID)
+	}
+
 	return summary, nil
 }
 
 // GenerateAIPrompt creates a structured prompt for the AI GM
 func (cm *ContextManager) GenerateAIPrompt(sessionID string) (string, error) {
+	return cm.GenerateAIPromptForCommand(sessionID, "")
+}
+
+// GenerateAIPromptForCommand creates the GM prompt for answering command.
+// When an embedder is set, the prompt also recalls the past actions most
+// related to the command. See AssembleAIPrompt for how it is kept in budget.
+func (cm *ContextManager) GenerateAIPromptForCommand(sessionID, command string) (string, error) {
+	prompt, truncated, err := cm.AssembleAIPrompt(sessionID, command)
+	if truncated {
+		cm.log().Debug("GM prompt truncated to fit token budget", "session_id", sessionID, "max_tokens", cm.maxPromptTokens)
+	}
+	return prompt, err
+}
+
+// AssembleAIPrompt creates the GM prompt for answering command and reports
+// whether it had to be truncated. The prompt and the command together are
+// kept within the MaxPromptTokens budget by dropping recalled and older
+// actions first, then world context, and so on; the current game state and
+// GM instructions are always kept.
+func (cm *ContextManager) AssembleAIPrompt(sessionID, command string) (string, bool, error) {
 	summary, err := cm.GetContextSummary(sessionID)
 	if err != nil {
-		return "", err
+		return "", false, err
 	}
 
 	recentActions, err := cm.GetRecentActions(sessionID, 3)
 	if err != nil {
-		return "", err
+		return "", false, err
 	}
 
 	ctx, err := cm.GetContext(sessionID)
 	if err != nil {
-		return "", err
+		return "", false, err
 	}
 
-	prompt := fmt.Sprintf(`GAME MASTER CONTEXT
+	sections := []promptSection{{
+		entries:  []string{"GAME MASTER CONTEXT"},
+		priority: priorityEssential,
+	}}
+
+	if story := strings.TrimSpace(ctx.HistorySummary); story != "" {
+		sections = append(sections, promptSection{
+			title:    "STORY SO FAR",
+			entries:  []string{story},
+			priority: priorityStory,
+		})
+	}
 
-CURRENT GAME STATE:
-- Location: %s (previously: %s)
+	sections = append(sections, promptSection{
+		title: "CURRENT GAME STATE",
+		entries: []string{fmt.Sprintf(`- Location: %s (previously: %s)
+- Available Exits: %s
+- Time of Day: %s
+- Weather: %s
 - Player Health: %s
+- Status Effects: %s
 - Player Reputation: %d (%s)
+- Player Gold: %d
 - Session Duration: %.1f minutes
-- Player Mood: %s
-
-RECENT PLAYER ACTIONS:
-%s
-
-ACTIVE NPCS IN AREA:
-%s
+- Player Mood: %s`,
+			summary.CurrentLocation,
+			cm.formatPreviousLocation(summary.PreviousLocation),
+			cm.formatExits(summary.CurrentLocation),
+			cm.formatGameTime(ctx.Clock),
+			cm.formatWeather(ctx.Location),
+			summary.PlayerHealth,
+			cm.formatStatusEffects(ctx.Character.StatusEffects),
+			summary.PlayerReputation,
+			ReputationLabel(summary.PlayerReputation),
+			ctx.Character.Gold,
+			summary.SessionDuration,
+			summary.PlayerMood,
+		)+cm.formatDeathState(ctx.Character)+cm.formatUnspentPoints(ctx.Character)+cm.formatEncounter(ctx.Encounter)+cm.formatCombat(ctx.Combat)},
+		priority: priorityEssential,
+	})
+
+	actions := cm.actionEntries(recentActions)
+	if len(actions) == 0 {
+		actions = []string{"- No recent actions"}
+	}
+	sections = append(sections,
+		promptSection{title: "RECENT PLAYER ACTIONS", entries: actions, priority: priorityRecentActions},
+		promptSection{
+			title:     "RECENT NARRATIVE",
+			entries:   cm.dialogueEntries(ctx.DialogueHistory, promptDialogueTurns),
+			separator: "\n\n",
+			priority:  priorityNarrative,
+		},
+	)
 
-PLAYER CHARACTER:
-- Name: %s
+	if relevant := cm.relevantHistory(sessionID, command, recentActions); len(relevant) > 0 {
+		sections = append(sections, promptSection{
+			title:    "RELEVANT HISTORY",
+			entries:  cm.actionEntries(relevant),
+			priority: priorityRelevantHistory,
+		})
+	}
+
+	sections = append(sections,
+		promptSection{
+			title:    "ACTIVE NPCS IN AREA",
+			entries:  []string{cm.formatActiveNPCs(summary.ActiveNPCs)},
+			priority: priorityNPCs,
+		},
+		promptSection{
+			title:    "ACTIVE QUESTS",
+			entries:  []string{cm.formatActiveQuests(activeQuests(ctx))},
+			priority: priorityQuests,
+		},
+		promptSection{
+			title: "PLAYER CHARACTER",
+			entries: []string{fmt.Sprintf(`- Name: %s
+- Class: %s
+- Party Members Here: %s
 - Equipment: %s
-- Recent Focus: %s
-
-WORLD CONTEXT:
-%s
-
-GM INSTRUCTIONS:
-You are the AI Game Master for this fantasy RPG session. Based on the current context:
-1. Respond as the omniscient narrator and world
-2. Maintain consistency with previous interactions
-3. React appropriately to the player's reputation and recent actions
-4. Consider NPC relationships and dispositions
-5. Provide immersive, contextual descriptions
-6. Balance challenge with player agency
-
-Current situation requires your response as Game Master.`,
-		summary.CurrentLocation,
-		cm.formatPreviousLocation(summary.PreviousLocation),
-		summary.PlayerHealth,
-		summary.PlayerReputation,
-		cm.getReputationDescription(summary.PlayerReputation),
-		summary.SessionDuration,
-		summary.PlayerMood,
-		cm.formatRecentActions(recentActions),
-		cm.formatActiveNPCs(summary.ActiveNPCs),
-		ctx.Character.Name,
-		cm.formatEquipment(ctx.Character.Equipment),
-		cm.determinePlayerFocus(ctx),
-		cm.formatWorldContext(summary.WorldState),
+- Faction Standing: %s
+- Recent Focus: %s`,
+				ctx.Character.Name,
+				cm.formatClass(ctx.Character.Class),
+				cm.formatPartyMembers(ctx),
+				cm.formatEquipment(ctx.Character.Equipment),
+				cm.formatFactionStanding(ctx.Character.FactionReputation, 3),
+				cm.determinePlayerFocus(ctx),
+			)},
+			priority: priorityCharacter,
+		},
+		promptSection{
+			title:    "WORLD CONTEXT",
+			entries:  []string{cm.formatWorldContext(summary.WorldState)},
+			priority: priorityWorld,
+		},
+		promptSection{
+			title:    "GM INSTRUCTIONS",
+			entries:  []string{cm.formatGMInstructions(cm.personalityFor(ctx))},
+			priority: priorityEssential,
+		},
 	)
 
-	return prompt, nil
+	truncated := false
+	if cm.maxPromptTokens > 0 {
+		sections, truncated = truncatePrompt(sections, cm.maxPromptTokens-EstimateTokens(command))
+	}
+	return renderPrompt(sections), truncated, nil
+}
+
+// SceneContext describes the time and weather at the player's location, as
+// context for a GenerateSceneDescription call
+func (cm *ContextManager) SceneContext(sessionID string) (string, error) {
+	ctx, err := cm.GetContext(sessionID)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("Time of day: %s. Weather: %s.", cm.formatGameTime(ctx.Clock), cm.formatWeather(ctx.Location)), nil
 }
 
 // GenerateAIPromptData creates structured data for advanced AI integration
@@ -124,7 +219,7 @@ func (cm *ContextManager) GenerateAIPromptData(sessionID string) (*AIPromptData,
 		RecentEvents:   recentEvents,
 		WorldKnowledge: make(map[string]interface{}),
 		PlayerProfile:  make(map[string]interface{}),
-		GMPersonality:  make(map[string]interface{}),
+		GMPersonality:  cm.personalityFor(ctx),
 	}
 
 	// Player profile
@@ -133,12 +228,6 @@ func (cm *ContextManager) GenerateAIPromptData(sessionID string) (*AIPromptData,
 	promptData.PlayerProfile["experience_level"] = cm.determineExperienceLevel(ctx)
 	promptData.PlayerProfile["preferred_activities"] = cm.getPreferredActivities(ctx)
 
-	// GM personality configuration
-	promptData.GMPersonality["helpfulness"] = 0.7
-	promptData.GMPersonality["challenge_level"] = 0.6
-	promptData.GMPersonality["mystery_level"] = 0.6
-	promptData.GMPersonality["immersion_focus"] = 0.9
-
 	// World knowledge
 	promptData.WorldKnowledge["known_locations"] = cm.getKnownLocations(ctx)
 	promptData.WorldKnowledge["established_npcs"] = cm.getEstablishedNPCs(ctx)
@@ -174,26 +263,35 @@ func (cm *ContextManager) getActionSummary(actions []ActionEvent, count int) []s
 
 func (cm *ContextManager) getRelevantNPCs(ctx *PlayerContext) []NPCContextInfo {
 	var npcs []NPCContextInfo
+	now := cm.now()
 	
 	for _, npcRel := range ctx.NPCStates {
-		// Include NPCs the player has interacted with recently
-		if time.Since(npcRel.LastInteraction) < 24*time.Hour {
-			relationship := cm.determineRelationshipLevel(npcRel.Disposition)
+		// Include NPCs the player has interacted with recently, and those at
+		// the player's location however long ago they met, so the GM sees
+		// how their disposition has drifted since
+		if now.Sub(npcRel.LastInteraction) < 24*time.Hour || npcRel.Location == ctx.Location.Current {
+			disposition := cm.decayedDisposition(npcRel, now)
+			relationship := RelationshipLevel(disposition)
 			
 			npc := NPCContextInfo{
 				ID:           npcRel.NPCID,
 				Name:         npcRel.Name,
-				Disposition:  npcRel.Disposition,
-				Mood:         npcRel.Mood,
-				KnownFacts:   npcRel.KnownFacts,
+				Disposition:  disposition,
+				Mood:         MoodForDisposition(disposition),
+				KnownFacts:   cm.recalledFacts(npcRel.KnownFacts, now),
 				LastSeen:     cm.formatTimeSince(npcRel.LastInteraction),
 				Location:     npcRel.Location,
 				Relationship: relationship,
+				Stance:       cm.npcStance(ctx.Character.Reputation, disposition),
 			}
 			npcs = append(npcs, npc)
 		}
 	}
 
+	// Map order is random; keep prompts stable
+	sort.Slice(npcs, func(i, j int) bool {
+		return npcs[i].ID < npcs[j].ID
+	})
 	return npcs
 }
 
@@ -256,11 +354,8 @@ func (cm *ContextManager) determinePlayerMood(ctx *PlayerContext) string {
 	return "focused"
 }
 
-func (cm *ContextManager) formatRecentActions(actions []ActionEvent) string {
-	if len(actions) == 0 {
-		return "- No recent actions"
-	}
-
+// actionEntries lists actions for AI prompts, one line each
+func (cm *ContextManager) actionEntries(actions []ActionEvent) []string {
 	var formatted []string
 	for _, action := range actions {
 		timeAgo := cm.formatTimeSince(action.Timestamp)
@@ -269,7 +364,7 @@ func (cm *ContextManager) formatRecentActions(actions []ActionEvent) string {
 		formatted = append(formatted, entry)
 	}
 
-	return strings.Join(formatted, "\n")
+	return formatted
 }
 
 func (cm *ContextManager) formatActiveNPCs(npcs []NPCContextInfo) string {
@@ -281,6 +376,9 @@ func (cm *ContextManager) formatActiveNPCs(npcs []NPCContextInfo) string {
 	for _, npc := range npcs {
 		entry := fmt.Sprintf("- %s (%s): %s mood, %s relationship (last seen %s)",
 			npc.Name, npc.ID, npc.Mood, npc.Relationship, npc.LastSeen)
+		if stance, gated := npcStanceDescriptions[npc.Stance]; gated {
+			entry += fmt.Sprintf(" - Because of the player's reputation, %s", stance)
+		}
 		if len(npc.KnownFacts) > 0 {
 			entry += fmt.Sprintf(" - Knows: %s", strings.Join(npc.KnownFacts, ", "))
 		}
@@ -303,6 +401,77 @@ func (cm *ContextManager) formatEquipment(equipment []EquipmentItem) string {
 	return strings.Join(items, ", ")
 }
 
+// formatFactionStanding lists the factions the player stands best with
+func (cm *ContextManager) formatFactionStanding(factions map[string]int, limit int) string {
+	if len(factions) == 0 {
+		return "No faction ties"
+	}
+
+	names := make([]string, 0, len(factions))
+	for name := range factions {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		if factions[names[i]] != factions[names[j]] {
+			return factions[names[i]] > factions[names[j]]
+		}
+		return names[i] < names[j]
+	})
+
+	if len(names) > limit {
+		names = names[:limit]
+	}
+
+	var standings []string
+	for _, name := range names {
+		standings = append(standings, fmt.Sprintf("%s %d (%s)", name, factions[name], ReputationLabel(factions[name])))
+	}
+
+	return strings.Join(standings, ", ")
+}
+
+// formatActiveQuests lists active quests with their next incomplete objective
+func (cm *ContextManager) formatActiveQuests(quests []Quest) string {
+	if len(quests) == 0 {
+		return "- No active quests"
+	}
+
+	var lines []string
+	for _, quest := range quests {
+		next := "All objectives complete"
+		if index := nextObjective(quest); index >= 0 {
+			next = fmt.Sprintf("Next: %s", quest.Objectives[index].Description)
+		}
+		lines = append(lines, fmt.Sprintf("- %s: %s", quest.Title, next))
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// formatExits lists the locations reachable from a location on the world map
+func (cm *ContextManager) formatExits(locationID string) string {
+	worldMap := cm.GetWorldMap()
+	if worldMap == nil {
+		return "Unknown"
+	}
+
+	exits := worldMap.Exits(locationID)
+	if len(exits) == 0 {
+		return "None"
+	}
+
+	var names []string
+	for _, id := range exits {
+		if location, ok := worldMap.GetLocation(id); ok && location.Name != "" {
+			names = append(names, fmt.Sprintf("%s (%s)", location.Name, id))
+		} else {
+			names = append(names, id)
+		}
+	}
+
+	return strings.Join(names, ", ")
+}
+
 func (cm *ContextManager) formatPreviousLocation(previous string) string {
 	if previous == "" {
 		return "none"
@@ -310,25 +479,6 @@ func (cm *ContextManager) formatPreviousLocation(previous string) string {
 	return previous
 }
 
-func (cm *ContextManager) getReputationDescription(reputation int) string {
-	switch {
-	case reputation >= 75:
-		return "Heroic"
-	case reputation >= 50:
-		return "Well-regarded"
-	case reputation >= 25:
-		return "Respected"
-	case reputation >= 0:
-		return "Neutral"
-	case reputation >= -25:
-		return "Mistrusted"
-	case reputation >= -50:
-		return "Disliked"
-	default:
-		return "Notorious"
-	}
-}
-
 func (cm *ContextManager) determinePlayerFocus(ctx *PlayerContext) string {
 	stats := ctx.SessionStats
 	
@@ -357,6 +507,10 @@ func (cm *ContextManager) formatWorldContext(worldState map[string]interface{})
 	if val, ok := worldState["social_active"].(bool); ok && val {
 		context = append(context, "- Prefers social interactions")
 	}
+
+	if val, ok := worldState["players_here"].([]string); ok && len(val) > 0 {
+		context = append(context, fmt.Sprintf("- Other players here: %s", strings.Join(val, ", ")))
+	}
 	
 	if len(context) == 0 {
 		return "- New to this world"
@@ -365,27 +519,8 @@ func (cm *ContextManager) formatWorldContext(worldState map[string]interface{})
 	return strings.Join(context, "\n")
 }
 
-func (cm *ContextManager) determineRelationshipLevel(disposition int) string {
-	switch {
-	case disposition >= 75:
-		return "close_friend"
-	case disposition >= 50:
-		return "friend"
-	case disposition >= 25:
-		return "ally"
-	case disposition >= 0:
-		return "acquaintance"
-	case disposition >= -25:
-		return "stranger"
-	case disposition >= -50:
-		return "rival"
-	default:
-		return "enemy"
-	}
-}
-
 func (cm *ContextManager) formatTimeSince(t time.Time) string {
-	duration := time.Since(t)
+	duration := cm.now().Sub(t)
 	
 	if duration < time.Minute {
 		return "moments"
@@ -426,7 +561,7 @@ func (cm *ContextManager) determinePlayStyle(ctx *PlayerContext) string {
 
 func (cm *ContextManager) determineExperienceLevel(ctx *PlayerContext) string {
 	totalActions := ctx.SessionStats.TotalActions
-	sessionTime := time.Since(ctx.StartTime).Minutes()
+	sessionTime := cm.now().Sub(ctx.StartTime).Minutes()
 	
 	if totalActions < 10 || sessionTime < 15 {
 		return "beginner"
@@ -501,6 +636,10 @@ func (cm *ContextManager) getOngoingStorylines(ctx *PlayerContext) []string {
 	// This would analyze actions and NPC interactions to identify ongoing storylines
 	// For now, return basic storylines based on reputation and interactions
 	storylines := []string{}
+
+	for _, quest := range activeQuests(ctx) {
+		storylines = append(storylines, "Pursuing quest: "+quest.Title)
+	}
 	
 	if ctx.Character.Reputation > 25 {
 		storylines = append(storylines, "Building positive reputation in the community")
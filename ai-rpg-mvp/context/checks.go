@@ -0,0 +1,54 @@
+package context
+
+import (
+	"fmt"
+	"strings"
+
+	"ai-rpg-mvp/combat"
+)
+
+// AttributeCheck rolls a d20 for a skill challenge such as persuasion or
+// lockpicking, adds the modifier for one of the player's effective
+// attributes, less any penalty the weather imposes, and succeeds when the
+// total meets difficulty. Rolls come from the manager's dice, so
+// SetDiceSource makes them reproducible. The check is recorded as an action
+// with its result in the outcome.
+func (cm *ContextManager) AttributeCheck(sessionID, attribute string, difficulty int) (success bool, roll int, total int, err error) {
+	attribute = strings.ToLower(strings.TrimSpace(attribute))
+
+	ctx, err := cm.GetContext(sessionID)
+	if err != nil {
+		return false, 0, 0, err
+	}
+	if err := checkAlive(ctx); err != nil {
+		return false, 0, 0, err
+	}
+
+	score, exists := effectiveAttributes(ctx.Character, cm.now())[attribute]
+	if !exists {
+		return false, 0, 0, fmt.Errorf("unknown attribute: %s", attribute)
+	}
+
+	modifier := combat.Modifier(score) + weatherCheckModifier(currentWeather(ctx.Location), attribute)
+	roll = cm.dice.Load().Roll(20)
+	total = roll + modifier
+	success = total >= difficulty
+
+	result := "failure"
+	if success {
+		result = "success"
+	}
+	outcome := fmt.Sprintf("%s check: rolled %d%+d = %d against difficulty %d, %s", attribute, roll, modifier, total, difficulty, result)
+
+	err = cm.RecordActionWithMetadata(sessionID, fmt.Sprintf("/check %s %d", attribute, difficulty), "attribute_check", attribute, ctx.Location.Current, outcome, []string{}, map[string]interface{}{
+		"roll":       roll,
+		"total":      total,
+		"difficulty": difficulty,
+		"success":    success,
+	})
+	if err != nil {
+		return false, 0, 0, fmt.Errorf("failed to record check: %w", err)
+	}
+
+	return success, roll, total, nil
+}
@@ -0,0 +1,119 @@
+package context
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// defaultClasses returns the class presets every context manager starts with
+func defaultClasses() map[string]CharacterTemplate {
+	return map[string]CharacterTemplate{
+		"warrior": {
+			MaxHealth:  30,
+			Attributes: map[string]int{"strength": 15, "dexterity": 10, "intelligence": 6, "charisma": 9},
+		},
+		"mage": {
+			MaxHealth:  16,
+			Attributes: map[string]int{"strength": 6, "dexterity": 9, "intelligence": 15, "charisma": 10},
+		},
+		"rogue": {
+			MaxHealth:  20,
+			Attributes: map[string]int{"strength": 8, "dexterity": 15, "intelligence": 10, "charisma": 11},
+		},
+	}
+}
+
+// RegisterClass adds or replaces a character class and the template its
+// characters start from
+func (cm *ContextManager) RegisterClass(name string, tmpl CharacterTemplate) error {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if name == "" {
+		return fmt.Errorf("class name is required")
+	}
+	if err := tmpl.Validate(); err != nil {
+		return fmt.Errorf("invalid template for class %s: %w", name, err)
+	}
+
+	tmpl.Attributes = copyIntMap(tmpl.Attributes)
+	tmpl.Equipment = copyEquipment(tmpl.Equipment)
+	tmpl.Inventory = copyInventory(tmpl.Inventory)
+
+	cm.registryMutex.Lock()
+	defer cm.registryMutex.Unlock()
+
+	cm.classes[name] = tmpl
+	return nil
+}
+
+// GetClasses returns the names of all registered classes
+func (cm *ContextManager) GetClasses() []string {
+	cm.registryMutex.RLock()
+	defer cm.registryMutex.RUnlock()
+
+	names := make([]string, 0, len(cm.classes))
+	for name := range cm.classes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// CreateSessionWithClass creates a new player session for a character of a
+// registered class
+func (cm *ContextManager) CreateSessionWithClass(playerID, playerName, class string) (string, error) {
+	class = strings.ToLower(strings.TrimSpace(class))
+
+	cm.registryMutex.RLock()
+	tmpl, exists := cm.classes[class]
+	cm.registryMutex.RUnlock()
+	if !exists {
+		return "", fmt.Errorf("unknown class: %s", class)
+	}
+
+	return cm.createSession(playerID, playerName, class, "", tmpl)
+}
+
+// RestrictAction limits an action type to characters of the given classes.
+// Characters without a class are never restricted.
+func (cm *ContextManager) RestrictAction(actionType string, classes ...string) {
+	allowed := make([]string, len(classes))
+	for i, class := range classes {
+		allowed[i] = strings.ToLower(strings.TrimSpace(class))
+	}
+
+	cm.registryMutex.Lock()
+	defer cm.registryMutex.Unlock()
+
+	cm.classActions[actionType] = allowed
+}
+
+// CheckClassAction returns an error if the session's class may not perform
+// the action type
+func (cm *ContextManager) CheckClassAction(sessionID, actionType string) error {
+	cm.registryMutex.RLock()
+	allowed, restricted := cm.classActions[actionType]
+	cm.registryMutex.RUnlock()
+	if !restricted {
+		return nil
+	}
+
+	ctx, err := cm.GetContext(sessionID)
+	if err != nil {
+		return err
+	}
+
+	class := ctx.Character.Class
+	if class == "" || contains(allowed, class) {
+		return nil
+	}
+	return fmt.Errorf("a %s cannot perform %s actions", class, actionType)
+}
+
+// formatClass describes the character's class for AI prompts
+func (cm *ContextManager) formatClass(class string) string {
+	if class == "" {
+		return "None"
+	}
+	return class
+}
@@ -0,0 +1,208 @@
+package context
+
+import "time"
+
+// Clone returns a deep copy of the context so callers can read or modify it
+// without sharing state with the manager's cache
+func (ctx *PlayerContext) Clone() *PlayerContext {
+	if ctx == nil {
+		return nil
+	}
+
+	clone := *ctx
+
+	clone.Character = ctx.Character.clone()
+	if ctx.Location.LocationHistory != nil {
+		clone.Location.LocationHistory = append(make([]LocationVisit, 0, len(ctx.Location.LocationHistory)), ctx.Location.LocationHistory...)
+	}
+	clone.Location.Visits = copyIntMap(ctx.Location.Visits)
+
+	if ctx.Actions != nil {
+		clone.Actions = make([]ActionEvent, len(ctx.Actions))
+		for i, action := range ctx.Actions {
+			clone.Actions[i] = action.clone()
+		}
+	}
+
+	if ctx.DialogueHistory != nil {
+		clone.DialogueHistory = append(make([]DialogueTurn, 0, len(ctx.DialogueHistory)), ctx.DialogueHistory...)
+	}
+
+	if ctx.NPCStates != nil {
+		clone.NPCStates = make(map[string]NPCRelationship, len(ctx.NPCStates))
+		for id, npc := range ctx.NPCStates {
+			clone.NPCStates[id] = npc.clone()
+		}
+	}
+
+	clone.DialogueNodes = copyStringMap(ctx.DialogueNodes)
+
+	if ctx.Achievements != nil {
+		clone.Achievements = append(make([]Achievement, 0, len(ctx.Achievements)), ctx.Achievements...)
+	}
+
+	if ctx.Encounter != nil {
+		encounter := *ctx.Encounter
+		clone.Encounter = &encounter
+	}
+
+	if ctx.Combat != nil {
+		fight := *ctx.Combat
+		clone.Combat = &fight
+	}
+
+	if ctx.GMPersonality != nil {
+		personality := *ctx.GMPersonality
+		clone.GMPersonality = &personality
+	}
+
+	if ctx.Quests != nil {
+		clone.Quests = make(map[string]Quest, len(ctx.Quests))
+		for id, quest := range ctx.Quests {
+			clone.Quests[id] = quest.clone()
+		}
+	}
+
+	return &clone
+}
+
+func (c CharacterState) clone() CharacterState {
+	clone := c
+
+	clone.Equipment = copyEquipment(c.Equipment)
+	clone.Inventory = copyInventory(c.Inventory)
+
+	clone.FactionReputation = copyIntMap(c.FactionReputation)
+	clone.Attributes = copyIntMap(c.Attributes)
+	if c.Cooldowns != nil {
+		clone.Cooldowns = make(map[string]time.Time, len(c.Cooldowns))
+		for id, readyAt := range c.Cooldowns {
+			clone.Cooldowns[id] = readyAt
+		}
+	}
+
+	if c.StatusEffects != nil {
+		clone.StatusEffects = make([]StatusEffect, len(c.StatusEffects))
+		for i, effect := range c.StatusEffects {
+			effect.AttributeModifiers = copyIntMap(effect.AttributeModifiers)
+			clone.StatusEffects[i] = effect
+		}
+	}
+	clone.Metadata = copyMetadata(c.Metadata)
+
+	return clone
+}
+
+func (a ActionEvent) clone() ActionEvent {
+	clone := a
+	clone.Consequences = copyStrings(a.Consequences)
+	clone.Metadata = copyMetadata(a.Metadata)
+	if a.Effects != nil {
+		effects := a.Effects.clone()
+		clone.Effects = &effects
+	}
+	if a.Embedding != nil {
+		clone.Embedding = append(make([]float32, 0, len(a.Embedding)), a.Embedding...)
+	}
+	return clone
+}
+
+func (e ActionEffects) clone() ActionEffects {
+	clone := e
+	clone.ItemsGained = copyInventory(e.ItemsGained)
+	clone.ItemsLost = copyInventory(e.ItemsLost)
+	clone.Achievements = copyStrings(e.Achievements)
+	if e.Rewards != nil {
+		rewards := e.Rewards.clone()
+		clone.Rewards = &rewards
+	}
+	return clone
+}
+
+func (q Quest) clone() Quest {
+	clone := q
+	if q.Objectives != nil {
+		clone.Objectives = append(make([]Objective, 0, len(q.Objectives)), q.Objectives...)
+	}
+	return clone
+}
+
+func (n NPCRelationship) clone() NPCRelationship {
+	clone := n
+	if n.KnownFacts != nil {
+		clone.KnownFacts = append([]NPCFact{}, n.KnownFacts...)
+	}
+	clone.Notes = copyStrings(n.Notes)
+	return clone
+}
+
+// copyEquipment copies equipment items with their stats and metadata, preserving nil
+func copyEquipment(items []EquipmentItem) []EquipmentItem {
+	if items == nil {
+		return nil
+	}
+	clone := make([]EquipmentItem, len(items))
+	for i, item := range items {
+		item.Stats = copyIntMap(item.Stats)
+		item.Metadata = copyMetadata(item.Metadata)
+		clone[i] = item
+	}
+	return clone
+}
+
+// copyInventory copies inventory items and their metadata, preserving nil
+func copyInventory(items []InventoryItem) []InventoryItem {
+	if items == nil {
+		return nil
+	}
+	clone := make([]InventoryItem, len(items))
+	for i, item := range items {
+		item.Metadata = copyMetadata(item.Metadata)
+		clone[i] = item
+	}
+	return clone
+}
+
+// copyStrings copies a string slice, preserving nil
+func copyStrings(s []string) []string {
+	if s == nil {
+		return nil
+	}
+	return append(make([]string, 0, len(s)), s...)
+}
+
+// copyIntMap copies a string->int map, preserving nil
+func copyIntMap(m map[string]int) map[string]int {
+	if m == nil {
+		return nil
+	}
+	clone := make(map[string]int, len(m))
+	for key, value := range m {
+		clone[key] = value
+	}
+	return clone
+}
+
+// copyStringMap copies a string map, preserving nil
+func copyStringMap(m map[string]string) map[string]string {
+	if m == nil {
+		return nil
+	}
+	clone := make(map[string]string, len(m))
+	for key, value := range m {
+		clone[key] = value
+	}
+	return clone
+}
+
+// copyMetadata copies a metadata map one level deep, preserving nil
+func copyMetadata(m map[string]interface{}) map[string]interface{} {
+	if m == nil {
+		return nil
+	}
+	clone := make(map[string]interface{}, len(m))
+	for key, value := range m {
+		clone[key] = value
+	}
+	return clone
+}
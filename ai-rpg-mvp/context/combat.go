@@ -0,0 +1,87 @@
+package context
+
+import (
+	"fmt"
+	"math/rand"
+	"time"
+
+	"ai-rpg-mvp/combat"
+)
+
+// DefaultNPCCombatStats are used for targets without registered stats
+var DefaultNPCCombatStats = combat.CombatStats{
+	AttackBonus: 2,
+	Defense:     12,
+	DamageDie:   6,
+}
+
+// unarmedDamageDie is the player's damage die without a weapon that sets one
+const unarmedDamageDie = 6
+
+// SetDiceSource sets the random source combat rolls draw from, making
+// combat reproducible for a fixed seed. It is safe to call while sessions
+// are being played.
+func (cm *ContextManager) SetDiceSource(src rand.Source) {
+	cm.dice.Store(combat.NewRoller(src))
+}
+
+// SetNPCCombatStats registers the combat stats of an NPC or monster
+func (cm *ContextManager) SetNPCCombatStats(npcID string, stats combat.CombatStats) {
+	cm.registryMutex.Lock()
+	defer cm.registryMutex.Unlock()
+
+	cm.npcCombatStats[npcID] = stats
+}
+
+// ResolveCombatAction rolls the player's attack against a target. If the
+// attack misses, the target strikes back and the damage is applied to the
+// player's health.
+func (cm *ContextManager) ResolveCombatAction(sessionID, targetNPC string) (combat.AttackResult, error) {
+	if targetNPC == "" {
+		return combat.AttackResult{}, fmt.Errorf("combat target is required")
+	}
+
+	defender := cm.npcStats(targetNPC)
+
+	var result combat.AttackResult
+	err := cm.mutateContext(sessionID, func(ctx *PlayerContext) error {
+		if err := checkAlive(ctx); err != nil {
+			return err
+		}
+
+		result = cm.dice.Load().ResolveExchange(playerCombatStats(ctx, cm.now()), defender)
+
+		if result.Counter != nil && result.Counter.Hit {
+			ctx.Character.Health.Current -= result.Counter.Damage
+			if ctx.Character.Health.Current < 0 {
+				ctx.Character.Health.Current = 0
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return combat.AttackResult{}, err
+	}
+
+	return result, nil
+}
+
+// playerCombatStats derives the player's combat stats from their effective
+// attributes at now and main-hand weapon
+func playerCombatStats(ctx *PlayerContext, now time.Time) combat.CombatStats {
+	attributes := effectiveAttributes(ctx.Character, now)
+
+	damageDie := unarmedDamageDie
+	for _, item := range ctx.Character.Equipment {
+		if item.Slot == "mainhand" && item.Stats["damage"] > 0 {
+			damageDie = item.Stats["damage"]
+		}
+	}
+
+	return combat.CombatStats{
+		AttackBonus: combat.Modifier(attributes["strength"]),
+		Defense:     10 + combat.Modifier(attributes["dexterity"]),
+		DamageDie:   damageDie,
+		DamageBonus: combat.Modifier(attributes["strength"]),
+	}
+}
@@ -0,0 +1,233 @@
+package context
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"ai-rpg-mvp/combat"
+)
+
+// ErrNoCombat is returned by CombatTurn when the player isn't fighting anyone
+var ErrNoCombat = errors.New("you are not in combat")
+
+// How a combat encounter stands
+const (
+	CombatOngoing = "ongoing"
+	CombatWon     = "won"
+	CombatLost    = "lost"
+	CombatFled    = "fled"
+)
+
+// What the player can do on their turn in combat
+const (
+	CombatActionAttack = "attack"
+	CombatActionDefend = "defend" // raises the player's defense against this turn's counterattack
+	CombatActionFlee   = "flee"   // a dexterity check to escape before the enemy strikes
+)
+
+const (
+	// defendBonus is how much defending raises the player's defense
+	defendBonus = 4
+	// fleeDifficulty is what a flee check must meet
+	fleeDifficulty = 12
+)
+
+// Enemy is a foe fought over several turns
+type Enemy struct {
+	ID        string             `json:"id"`
+	Name      string             `json:"name"`
+	MaxHealth int                `json:"max_health"`
+	Stats     combat.CombatStats `json:"stats"` // zero uses the stats registered for the ID, or the defaults
+}
+
+// CombatEncounter is a fight in progress, kept in the context until one side
+// falls or the player flees
+type CombatEncounter struct {
+	Enemy       Enemy     `json:"enemy"`
+	EnemyHealth int       `json:"enemy_health"`
+	Turn        int       `json:"turn"` // turns fought so far
+	Status      string    `json:"status"`
+	StartedAt   time.Time `json:"started_at"`
+}
+
+// CombatTurnResult is what happened in one turn of combat
+type CombatTurnResult struct {
+	Turn         int                  `json:"turn"`
+	Action       string               `json:"action"`
+	Enemy        string               `json:"enemy"`
+	PlayerAttack *combat.AttackResult `json:"player_attack,omitempty"`
+	EnemyAttack  *combat.AttackResult `json:"enemy_attack,omitempty"`
+	FleeRoll     int                  `json:"flee_roll,omitempty"` // flee check total, when fleeing
+	EnemyHealth  int                  `json:"enemy_health"`
+	PlayerHealth int                  `json:"player_health"`
+	Status       string               `json:"status"`
+}
+
+// Describe summarizes the turn for narration
+func (r CombatTurnResult) Describe() string {
+	var parts []string
+	switch {
+	case r.PlayerAttack != nil:
+		parts = append(parts, fmt.Sprintf("The player attacked %s and %s", r.Enemy, r.PlayerAttack.Describe()))
+	case r.Action == CombatActionDefend:
+		parts = append(parts, "The player braced to defend")
+	case r.Action == CombatActionFlee:
+		parts = append(parts, fmt.Sprintf("The player tried to flee (check total %d vs %d)", r.FleeRoll, fleeDifficulty))
+	}
+	if r.EnemyAttack != nil {
+		parts = append(parts, fmt.Sprintf("%s struck back and %s", r.Enemy, r.EnemyAttack.Describe()))
+	}
+
+	switch r.Status {
+	case CombatWon:
+		parts = append(parts, fmt.Sprintf("%s is defeated", r.Enemy))
+	case CombatLost:
+		parts = append(parts, fmt.Sprintf("The player fell to %s", r.Enemy))
+	case CombatFled:
+		parts = append(parts, fmt.Sprintf("The player escaped from %s", r.Enemy))
+	default:
+		parts = append(parts, fmt.Sprintf("%s has %d health left", r.Enemy, r.EnemyHealth))
+	}
+	return strings.Join(parts, ". ") + "."
+}
+
+// BeginCombat starts a fight with an enemy at full health. The player must be
+// alive and not already fighting.
+func (cm *ContextManager) BeginCombat(sessionID string, enemy Enemy) error {
+	if enemy.ID == "" {
+		return fmt.Errorf("enemy ID is required")
+	}
+	if enemy.MaxHealth <= 0 {
+		return fmt.Errorf("enemy %s needs positive max health, got %d", enemy.ID, enemy.MaxHealth)
+	}
+	if enemy.Name == "" {
+		enemy.Name = enemy.ID
+	}
+	if enemy.Stats == (combat.CombatStats{}) {
+		enemy.Stats = cm.npcStats(enemy.ID)
+	}
+
+	return cm.mutateContext(sessionID, func(ctx *PlayerContext) error {
+		if err := checkAlive(ctx); err != nil {
+			return err
+		}
+		if ctx.Combat != nil {
+			return fmt.Errorf("already fighting %s", ctx.Combat.Enemy.Name)
+		}
+
+		ctx.Combat = &CombatEncounter{
+			Enemy:       enemy,
+			EnemyHealth: enemy.MaxHealth,
+			Status:      CombatOngoing,
+			StartedAt:   cm.now(),
+		}
+		return nil
+	})
+}
+
+// CombatTurn plays one turn of the current fight: the player's action, then
+// the enemy's counterattack unless it fell or the player got away. The fight
+// ends when the enemy's health or the player's reaches zero, the latter
+// killing the player, or when the player flees. Each turn is recorded as a
+// combat action, and a win as a combat victory.
+func (cm *ContextManager) CombatTurn(sessionID, action string) (CombatTurnResult, error) {
+	action = strings.ToLower(strings.TrimSpace(action))
+	switch action {
+	case CombatActionAttack, CombatActionDefend, CombatActionFlee:
+	default:
+		return CombatTurnResult{}, fmt.Errorf("unknown combat action %q (supported: %s, %s, %s)", action, CombatActionAttack, CombatActionDefend, CombatActionFlee)
+	}
+	if err := cm.CheckClassAction(sessionID, "combat"); err != nil {
+		return CombatTurnResult{}, err
+	}
+
+	var result CombatTurnResult
+	var location string
+	err := cm.mutateContext(sessionID, func(ctx *PlayerContext) error {
+		if err := checkAlive(ctx); err != nil {
+			return err
+		}
+		fight := ctx.Combat
+		if fight == nil {
+			return ErrNoCombat
+		}
+
+		fight.Turn++
+		result = CombatTurnResult{Turn: fight.Turn, Action: action, Enemy: fight.Enemy.Name}
+		location = ctx.Location.Current
+
+		player := playerCombatStats(ctx, cm.now())
+		switch action {
+		case CombatActionAttack:
+			attack := cm.dice.Load().ResolveAttack(player, fight.Enemy.Stats)
+			result.PlayerAttack = &attack
+			fight.EnemyHealth = max(fight.EnemyHealth-attack.Damage, 0)
+			if fight.EnemyHealth == 0 {
+				fight.Status = CombatWon
+			}
+		case CombatActionDefend:
+			player.Defense += defendBonus
+		case CombatActionFlee:
+			dexterity := effectiveAttributes(ctx.Character, cm.now())["dexterity"]
+			result.FleeRoll = cm.dice.Load().Roll(20) + combat.Modifier(dexterity)
+			if result.FleeRoll >= fleeDifficulty {
+				fight.Status = CombatFled
+			}
+		}
+
+		if fight.Status == CombatOngoing {
+			counter := cm.dice.Load().ResolveAttack(fight.Enemy.Stats, player)
+			result.EnemyAttack = &counter
+			ctx.Character.Health.Current = max(ctx.Character.Health.Current-counter.Damage, 0)
+			if ctx.Character.Health.Current == 0 {
+				fight.Status = CombatLost
+			}
+		}
+
+		result.EnemyHealth = fight.EnemyHealth
+		result.PlayerHealth = ctx.Character.Health.Current
+		result.Status = fight.Status
+		if fight.Status != CombatOngoing {
+			ctx.Combat = nil
+		}
+		return nil
+	})
+	if err != nil {
+		return CombatTurnResult{}, err
+	}
+
+	consequences := []string{}
+	if result.Status == CombatWon {
+		consequences = append(consequences, "combat_victory")
+	}
+	err = cm.RecordActionWithMetadata(sessionID, "/"+action, "combat", result.Enemy, location, result.Describe(), consequences, map[string]interface{}{
+		"turn":   result.Turn,
+		"status": result.Status,
+	})
+	if err != nil {
+		return result, fmt.Errorf("failed to record combat turn: %w", err)
+	}
+	return result, nil
+}
+
+// npcStats returns the combat stats registered for an NPC, or the defaults
+func (cm *ContextManager) npcStats(npcID string) combat.CombatStats {
+	cm.registryMutex.RLock()
+	defer cm.registryMutex.RUnlock()
+
+	if stats, exists := cm.npcCombatStats[npcID]; exists {
+		return stats
+	}
+	return DefaultNPCCombatStats
+}
+
+// formatCombat tells the GM about the fight in progress, or nothing
+func (cm *ContextManager) formatCombat(fight *CombatEncounter) string {
+	if fight == nil {
+		return ""
+	}
+	return fmt.Sprintf("\n- In Combat: fighting %s (ID: %s), turn %d, enemy health %d/%d. Narrate only the turn results you are given; never decide hits or damage yourself.",
+		fight.Enemy.Name, fight.Enemy.ID, fight.Turn, fight.EnemyHealth, fight.Enemy.MaxHealth)
+}
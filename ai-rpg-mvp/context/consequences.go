@@ -0,0 +1,163 @@
+package context
+
+import "strings"
+
+// consequenceHandler applies one consequence of an action. Reputation, health
+// and gold changes are worked out afterwards for undo; handlers note anything
+// else they change in effects.
+type consequenceHandler func(ctx *PlayerContext, action ActionEvent, effects *ActionEffects)
+
+// defaultConsequences returns the consequences every context manager
+// understands
+func (cm *ContextManager) defaultConsequences() map[string]consequenceHandler {
+	return map[string]consequenceHandler{
+		"reputation_increase": func(ctx *PlayerContext, action ActionEvent, _ *ActionEffects) {
+			change := 5
+			if val, ok := action.Metadata["reputation_change"].(int); ok {
+				change = val
+			}
+			ctx.Character.Reputation += change
+		},
+		"reputation_decrease": func(ctx *PlayerContext, action ActionEvent, _ *ActionEffects) {
+			change := -10
+			if val, ok := action.Metadata["reputation_change"].(int); ok {
+				change = val
+			}
+			ctx.Character.Reputation += change
+		},
+		"health_damage": func(ctx *PlayerContext, action ActionEvent, _ *ActionEffects) {
+			if damage, ok := action.Metadata["damage"].(int); ok {
+				ctx.Character.Health.Current = max(ctx.Character.Health.Current-damage, 0)
+			}
+		},
+		"health_heal": func(ctx *PlayerContext, action ActionEvent, _ *ActionEffects) {
+			if healing, ok := action.Metadata["healing"].(int); ok {
+				ctx.Character.Health.Current = min(ctx.Character.Health.Current+healing, ctx.Character.Health.Max)
+			}
+		},
+		"npc_noticed": func(ctx *PlayerContext, action ActionEvent, _ *ActionEffects) {
+			npcID, hasID := action.Metadata["npc_id"].(string)
+			npcName, hasName := action.Metadata["npc_name"].(string)
+			if hasID && hasName {
+				cm.applyNPCRelationship(ctx, npcID, npcName, 0, []string{"noticed_player_" + action.Type})
+			}
+		},
+		"rest": func(ctx *PlayerContext, action ActionEvent, _ *ActionEffects) {
+			minutes := restGameMinutes
+			if val, ok := action.Metadata["rest_minutes"].(int); ok {
+				minutes = val
+			}
+			cm.advanceClock(ctx, minutes)
+		},
+		"combat_victory": func(ctx *PlayerContext, _ ActionEvent, _ *ActionEffects) {
+			ctx.Character.Reputation += 2
+		},
+		"combat_defeat": func(ctx *PlayerContext, _ ActionEvent, _ *ActionEffects) {
+			ctx.Character.Reputation--
+		},
+		"gold_gained": func(ctx *PlayerContext, action ActionEvent, _ *ActionEffects) {
+			if amount, ok := action.Metadata["gold_amount"].(int); ok && amount > 0 {
+				ctx.Character.Gold += amount
+			}
+		},
+		"gold_spent": func(ctx *PlayerContext, action ActionEvent, _ *ActionEffects) {
+			if amount, ok := action.Metadata["gold_amount"].(int); ok && amount > 0 {
+				if amount > ctx.Character.Gold {
+					cm.log().Warn("Session cannot afford gold spent", "session_id", ctx.SessionID, "amount", amount, "gold", ctx.Character.Gold)
+				} else {
+					ctx.Character.Gold -= amount
+				}
+			}
+		},
+		"level_up": func(ctx *PlayerContext, action ActionEvent, _ *ActionEffects) {
+			points := attributePointsPerLevel
+			if val, ok := action.Metadata["attribute_points"].(int); ok && val > 0 {
+				points = val
+			}
+			ctx.Character.UnspentPoints += points
+			cm.publish(ContextChange{
+				Type:      ChangeLeveledUp,
+				SessionID: ctx.SessionID,
+				Timestamp: cm.now(),
+				Delta:     points,
+				Value:     ctx.Character.UnspentPoints,
+			})
+		},
+		"quest_completed": func(ctx *PlayerContext, action ActionEvent, _ *ActionEffects) {
+			if reward, ok := action.Metadata["reputation_reward"].(int); ok {
+				ctx.Character.Reputation += reward
+			}
+		},
+		"item_gained": func(ctx *PlayerContext, action ActionEvent, effects *ActionEffects) {
+			itemData, ok := action.Metadata["item"].(map[string]interface{})
+			if !ok {
+				return
+			}
+			item := InventoryItem{
+				ID:       itemData["id"].(string),
+				Name:     itemData["name"].(string),
+				Type:     itemData["type"].(string),
+				Quantity: 1,
+				Value:    0,
+				Metadata: make(map[string]interface{}),
+			}
+			if quantity, ok := itemData["quantity"].(int); ok {
+				item.Quantity = quantity
+			}
+			if value, ok := itemData["value"].(int); ok {
+				item.Value = value
+			}
+			addItemToInventory(ctx, item)
+			effects.ItemsGained = append(effects.ItemsGained, item)
+		},
+		"item_lost": func(ctx *PlayerContext, action ActionEvent, effects *ActionEffects) {
+			itemID, ok := action.Metadata["item_id"].(string)
+			if !ok {
+				return
+			}
+			if index := findInventoryItem(ctx, itemID); index >= 0 {
+				effects.ItemsLost = append(effects.ItemsLost, copyInventory(ctx.Character.Inventory[index:index+1])...)
+			}
+			cm.removeItemFromInventory(ctx, itemID)
+		},
+	}
+}
+
+// RegisterConsequence makes actions carrying the named consequence run
+// handler, such as a game-specific "curse_applied". It replaces any handler
+// already registered under the name, built-in ones included, and a nil
+// handler removes it. Handlers run while the session is locked, so they must
+// change ctx directly rather than through the manager; reputation is clamped
+// and reputation, health and gold changes are recorded for undo once they
+// return.
+func (cm *ContextManager) RegisterConsequence(name string, handler func(ctx *PlayerContext, action ActionEvent)) {
+	name = strings.TrimSpace(name)
+
+	cm.registryMutex.Lock()
+	defer cm.registryMutex.Unlock()
+
+	if handler == nil {
+		delete(cm.consequences, name)
+		return
+	}
+	cm.consequences[name] = func(ctx *PlayerContext, action ActionEvent, _ *ActionEffects) {
+		handler(ctx, action)
+	}
+	cm.unknownConsequences.Delete(name)
+}
+
+// lookupConsequence returns the handler registered for a consequence. The
+// first time an unregistered consequence turns up it is logged, so typos in
+// GM output or callers don't go unnoticed.
+func (cm *ContextManager) lookupConsequence(sessionID, name string) (consequenceHandler, bool) {
+	cm.registryMutex.RLock()
+	handler, exists := cm.consequences[name]
+	cm.registryMutex.RUnlock()
+
+	if !exists {
+		if _, logged := cm.unknownConsequences.LoadOrStore(name, true); !logged {
+			cm.log().Warn("Ignoring unknown consequence", "consequence", name, "session_id", sessionID)
+		}
+	}
+	return handler, exists
+}
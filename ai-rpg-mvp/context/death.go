@@ -0,0 +1,103 @@
+package context
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Defaults for what dying costs, used by NewContextManager
+const (
+	DefaultDeathGoldPenalty       = 10 // percent of gold lost
+	DefaultDeathReputationPenalty = 5
+)
+
+// ErrPlayerDead is returned for actions attempted by a dead player. Only
+// Respawn brings them back.
+var ErrPlayerDead = errors.New("you are dead; respawn to keep playing")
+
+// checkAlive rejects actions by a dead player. Recording the outcome of a
+// turn is still allowed, so the action that killed the player is kept.
+func checkAlive(ctx *PlayerContext) error {
+	if !ctx.Character.Alive {
+		return ErrPlayerDead
+	}
+	return nil
+}
+
+// checkDeath marks a living player dead once their health reaches zero and
+// publishes the death, reporting whether they died. Callers must hold the
+// session lock.
+func (cm *ContextManager) checkDeath(ctx *PlayerContext) bool {
+	if !ctx.Character.Alive || ctx.Character.Health.Current > 0 {
+		return false
+	}
+
+	ctx.Character.Alive = false
+	ctx.Character.DeathCount++
+	cm.log().Info("Player died", "session_id", ctx.SessionID, "location", ctx.Location.Current, "deaths", ctx.Character.DeathCount)
+	cm.publish(ContextChange{
+		Type:      ChangePlayerDied,
+		SessionID: ctx.SessionID,
+		Timestamp: cm.now(),
+		To:        ctx.Location.Current,
+		Value:     ctx.Character.DeathCount,
+	})
+	return true
+}
+
+// Respawn brings a dead player back at full health in the respawn location.
+// Dying costs a share of their gold and some reputation, and clears their
+// status effects so a lingering poison can't kill them again.
+func (cm *ContextManager) Respawn(sessionID string) error {
+	return cm.mutateContextDurably(sessionID, func(ctx *PlayerContext) error {
+		if ctx.Character.Alive {
+			return fmt.Errorf("you are not dead")
+		}
+
+		ctx.Character.Alive = true
+		ctx.Character.Health.Current = ctx.Character.Health.Max
+		ctx.Character.StatusEffects = []StatusEffect{}
+		ctx.Encounter = nil
+		ctx.Combat = nil
+		ctx.Character.Gold -= ctx.Character.Gold * cm.deathGoldPenalty / 100
+		ctx.Character.Reputation -= cm.deathReputationPenalty
+		if ctx.Character.Reputation < -100 {
+			ctx.Character.Reputation = -100
+		}
+
+		if ctx.Location.Current != cm.respawnLocation {
+			cm.applyLocationChange(ctx, cm.respawnLocation)
+		}
+		return nil
+	})
+}
+
+// deadPlayerVerbs are the commands a dead player may still use: looking
+// around changes nothing, and respawning is the way back
+var deadPlayerVerbs = map[string]bool{
+	"respawn":   true,
+	"look":      true,
+	"examine":   true,
+	"inventory": true,
+	"inv":       true,
+}
+
+// DeathValidator rejects commands that would change the game while the
+// player is dead
+type DeathValidator struct{}
+
+// Validate implements ActionValidator
+func (DeathValidator) Validate(ctx *PlayerContext, command string) error {
+	if verb, _ := parseCommand(command); ctx.Character.Alive || deadPlayerVerbs[verb] {
+		return nil
+	}
+	return ErrPlayerDead
+}
+
+// formatDeathState tells the GM the player is dead, or nothing while they live
+func (cm *ContextManager) formatDeathState(character CharacterState) string {
+	if character.Alive {
+		return ""
+	}
+	return fmt.Sprintf("\n- The player is DEAD (deaths so far: %d). Describe their fall; nothing else happens until they respawn.", character.DeathCount)
+}
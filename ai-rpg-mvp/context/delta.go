@@ -0,0 +1,160 @@
+package context
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// ContextDelta reports what changed in a session since a version the client
+// has already seen. Fields that didn't change are left empty.
+type ContextDelta struct {
+	SessionID   string            `json:"session_id"`
+	FromVersion int               `json:"from_version"`
+	Full        bool              `json:"full,omitempty"` // the version was too old to diff, so every field is included
+	Location    *string           `json:"location,omitempty"`
+	Health      *HealthStatus     `json:"health,omitempty"`
+	Reputation  *int              `json:"reputation,omitempty"`
+	NewActions  []ActionEvent     `json:"new_actions,omitempty"`
+	ChangedNPCs []NPCRelationship `json:"changed_npcs,omitempty"`
+}
+
+// versionStamps records the context version in which each tracked field last
+// changed. Stamps only live in memory; sessions loaded from storage start
+// tracking at their stored version.
+type versionStamps struct {
+	base       int // context version when tracking started
+	location   int
+	health     int
+	reputation int
+	npcs       map[string]int // NPC ID -> version
+	actions    map[string]int // action ID -> version it was recorded in
+}
+
+// trackedState is the part of a context a delta can report on
+type trackedState struct {
+	location   string
+	health     HealthStatus
+	reputation int
+	npcs       map[string]NPCRelationship
+	actions    map[string]bool
+}
+
+// captureTrackedState copies the fields deltas report on
+func captureTrackedState(ctx *PlayerContext) trackedState {
+	state := trackedState{
+		location:   ctx.Location.Current,
+		health:     ctx.Character.Health,
+		reputation: ctx.Character.Reputation,
+		npcs:       make(map[string]NPCRelationship, len(ctx.NPCStates)),
+		actions:    make(map[string]bool, len(ctx.Actions)),
+	}
+	for id, npc := range ctx.NPCStates {
+		state.npcs[id] = npc.clone()
+	}
+	for _, action := range ctx.Actions {
+		state.actions[action.ID] = true
+	}
+	return state
+}
+
+// GetContextDelta returns what changed in a session after sinceVersion,
+// along with the session's current version for the next call. Clients that
+// pass a version from before tracking began get a full delta.
+func (cm *ContextManager) GetContextDelta(sessionID string, sinceVersion int) (*ContextDelta, int, error) {
+	lock := cm.sessionLock(sessionID)
+	lock.Lock()
+	defer lock.Unlock()
+
+	ctx, err := cm.loadContext(sessionID)
+	if err != nil {
+		return nil, 0, err
+	}
+	if sinceVersion > ctx.Version {
+		return nil, 0, fmt.Errorf("version %d is ahead of session %s at version %d", sinceVersion, sessionID, ctx.Version)
+	}
+
+	stamps := cm.versionStamps(ctx)
+	delta := &ContextDelta{
+		SessionID:   sessionID,
+		FromVersion: sinceVersion,
+		Full:        sinceVersion < stamps.base,
+	}
+	changed := func(version int) bool {
+		return delta.Full || version > sinceVersion
+	}
+
+	if changed(stamps.location) {
+		location := ctx.Location.Current
+		delta.Location = &location
+	}
+	if changed(stamps.health) {
+		health := ctx.Character.Health
+		delta.Health = &health
+	}
+	if changed(stamps.reputation) {
+		reputation := ctx.Character.Reputation
+		delta.Reputation = &reputation
+	}
+	for _, action := range ctx.Actions {
+		if changed(stamps.actions[action.ID]) {
+			delta.NewActions = append(delta.NewActions, action.clone())
+		}
+	}
+	for id, npc := range ctx.NPCStates {
+		if changed(stamps.npcs[id]) {
+			delta.ChangedNPCs = append(delta.ChangedNPCs, npc.clone())
+		}
+	}
+
+	return delta, ctx.Version, nil
+}
+
+// versionStamps returns the stamps for a context, starting them at its
+// current version. Callers must hold the session lock.
+func (cm *ContextManager) versionStamps(ctx *PlayerContext) *versionStamps {
+	stamps, _ := cm.versions.LoadOrStore(ctx.SessionID, &versionStamps{
+		base:    ctx.Version,
+		npcs:    make(map[string]int),
+		actions: make(map[string]int),
+	})
+	return stamps.(*versionStamps)
+}
+
+// stampChanges bumps the context's version and stamps every tracked field
+// that differs from before. The version is set from previousVersion because
+// restoring a snapshot replaces the whole context, version included. Callers
+// must hold the session lock.
+func (cm *ContextManager) stampChanges(ctx *PlayerContext, before trackedState, previousVersion int) {
+	stamps := cm.versionStamps(ctx)
+	ctx.Version = previousVersion + 1
+	version := ctx.Version
+
+	if ctx.Location.Current != before.location {
+		stamps.location = version
+	}
+	if ctx.Character.Health != before.health {
+		stamps.health = version
+	}
+	if ctx.Character.Reputation != before.reputation {
+		stamps.reputation = version
+	}
+	for id, npc := range ctx.NPCStates {
+		if previous, existed := before.npcs[id]; !existed || !reflect.DeepEqual(previous, npc) {
+			stamps.npcs[id] = version
+		}
+	}
+	for _, action := range ctx.Actions {
+		if !before.actions[action.ID] {
+			stamps.actions[action.ID] = version
+		}
+	}
+
+	// Forget actions that have been trimmed or undone
+	if len(stamps.actions) > len(ctx.Actions) {
+		current := make(map[string]int, len(ctx.Actions))
+		for _, action := range ctx.Actions {
+			current[action.ID] = stamps.actions[action.ID]
+		}
+		stamps.actions = current
+	}
+}
@@ -0,0 +1,56 @@
+package context
+
+import "fmt"
+
+const (
+	// DefaultMaxDialogue is how many dialogue turns a session keeps
+	DefaultMaxDialogue = 20
+	// promptDialogueTurns is how many recent turns AI prompts quote verbatim
+	promptDialogueTurns = 3
+)
+
+// SetMaxDialogue sets how many dialogue turns each session keeps
+func (cm *ContextManager) SetMaxDialogue(maxTurns int) {
+	if maxTurns > 0 {
+		cm.maxDialogue = maxTurns
+	}
+}
+
+// GetDialogueHistory returns the last count dialogue turns, oldest first
+func (cm *ContextManager) GetDialogueHistory(sessionID string, count int) ([]DialogueTurn, error) {
+	ctx, err := cm.GetContext(sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	history := ctx.DialogueHistory
+	if count > 0 && len(history) > count {
+		history = history[len(history)-count:]
+	}
+	return history, nil
+}
+
+// appendDialogue adds a turn to the history, dropping the oldest turns
+// beyond the cap
+func (cm *ContextManager) appendDialogue(ctx *PlayerContext, turn DialogueTurn) {
+	ctx.DialogueHistory = append(ctx.DialogueHistory, turn)
+	if len(ctx.DialogueHistory) > cm.maxDialogue {
+		ctx.DialogueHistory = ctx.DialogueHistory[len(ctx.DialogueHistory)-cm.maxDialogue:]
+	}
+}
+
+// dialogueEntries quotes the last count turns for AI prompts, oldest first
+func (cm *ContextManager) dialogueEntries(history []DialogueTurn, count int) []string {
+	if len(history) == 0 {
+		return []string{"- The story has just begun"}
+	}
+	if len(history) > count {
+		history = history[len(history)-count:]
+	}
+
+	turns := make([]string, 0, len(history))
+	for _, turn := range history {
+		turns = append(turns, fmt.Sprintf("Player: %s\nGM: %s", turn.Command, turn.Response))
+	}
+	return turns
+}
@@ -0,0 +1,212 @@
+package context
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrNoDialogueTree is returned by StepDialogue for NPCs without a scripted
+// dialogue tree
+var ErrNoDialogueTree = errors.New("no dialogue tree registered for this NPC")
+
+// DialogueTree is a scripted conversation with an NPC, such as a quest-giver
+// whose lines must not vary. Conversations begin at the start node and end at
+// a node without options.
+type DialogueTree struct {
+	NPCName string                  `json:"npc_name"`
+	Start   string                  `json:"start"` // ID of the first node
+	Nodes   map[string]DialogueNode `json:"nodes"` // node ID -> node
+}
+
+// DialogueNode is one thing the NPC says and the replies open to the player
+type DialogueNode struct {
+	ID      string           `json:"id"`
+	Text    string           `json:"text"`
+	Options []DialogueOption `json:"options"`
+}
+
+// DialogueOption is a reply the player can choose. Choosing it moves the
+// conversation to the next node and applies the option's effects.
+type DialogueOption struct {
+	ID                string                 `json:"id"`
+	Text              string                 `json:"text"`
+	Next              string                 `json:"next"`                         // node the conversation moves to
+	Consequences      []string               `json:"consequences,omitempty"`       // recorded with the reply, as for any action
+	Metadata          map[string]interface{} `json:"metadata,omitempty"`           // consequence parameters, such as gold_amount
+	Facts             []string               `json:"facts,omitempty"`              // what the NPC learns about the player
+	DispositionChange int                    `json:"disposition_change,omitempty"` // how the NPC's feelings shift
+}
+
+// Validate checks that the tree starts at a known node and every option
+// leads to one
+func (t DialogueTree) Validate() error {
+	if _, exists := t.Nodes[t.Start]; !exists {
+		return fmt.Errorf("start node %q not found", t.Start)
+	}
+
+	for id, node := range t.Nodes {
+		if node.ID != id {
+			return fmt.Errorf("node %q is stored under %q", node.ID, id)
+		}
+		seen := make(map[string]bool, len(node.Options))
+		for _, option := range node.Options {
+			if option.ID == "" || seen[option.ID] {
+				return fmt.Errorf("node %q needs unique, non-empty option IDs", id)
+			}
+			seen[option.ID] = true
+			if _, exists := t.Nodes[option.Next]; !exists {
+				return fmt.Errorf("option %q of node %q leads to unknown node %q", option.ID, id, option.Next)
+			}
+		}
+	}
+	return nil
+}
+
+// currentNode returns the node a session's conversation with an NPC is at
+func (t DialogueTree) currentNode(ctx *PlayerContext, npcID string) DialogueNode {
+	if node, exists := t.Nodes[ctx.DialogueNodes[npcID]]; exists {
+		return node
+	}
+	return t.Nodes[t.Start]
+}
+
+// option finds an option of a node by ID
+func (n DialogueNode) option(optionID string) (DialogueOption, bool) {
+	for _, option := range n.Options {
+		if option.ID == optionID {
+			return option, true
+		}
+	}
+	return DialogueOption{}, false
+}
+
+// RegisterDialogueTree gives an NPC a scripted conversation, replacing any
+// it had. StepDialogue walks it; NPCs without one are voiced by the AI.
+func (cm *ContextManager) RegisterDialogueTree(npcID string, tree DialogueTree) error {
+	if npcID == "" {
+		return fmt.Errorf("NPC ID is required")
+	}
+	if err := tree.Validate(); err != nil {
+		return fmt.Errorf("invalid dialogue tree for %s: %w", npcID, err)
+	}
+
+	nodes := make(map[string]DialogueNode, len(tree.Nodes))
+	for id, node := range tree.Nodes {
+		node.Options = append([]DialogueOption{}, node.Options...)
+		nodes[id] = node
+	}
+	tree.Nodes = nodes
+
+	cm.registryMutex.Lock()
+	defer cm.registryMutex.Unlock()
+
+	cm.dialogueTrees[npcID] = tree
+	return nil
+}
+
+// dialogueTree returns the tree registered for an NPC
+func (cm *ContextManager) dialogueTree(npcID string) (DialogueTree, bool) {
+	cm.registryMutex.RLock()
+	defer cm.registryMutex.RUnlock()
+
+	tree, exists := cm.dialogueTrees[npcID]
+	return tree, exists
+}
+
+// StepDialogue advances the session's scripted conversation with an NPC and
+// returns the node it reaches. An empty optionID returns the node the
+// conversation is at, the start node if it hasn't begun. Choosing an option
+// introduces the NPC if needed, applies the option's disposition change and
+// facts, and records the reply as a dialogue action with the option's
+// consequences. Reaching a node without options ends the conversation, so the
+// next one starts over.
+func (cm *ContextManager) StepDialogue(sessionID, npcID, optionID string) (DialogueNode, error) {
+	tree, exists := cm.dialogueTree(npcID)
+	if !exists {
+		return DialogueNode{}, ErrNoDialogueTree
+	}
+
+	if optionID == "" {
+		ctx, err := cm.GetContext(sessionID)
+		if err != nil {
+			return DialogueNode{}, err
+		}
+		return tree.currentNode(ctx, npcID), nil
+	}
+
+	var node DialogueNode
+	var chosen DialogueOption
+	var location string
+	err := cm.mutateContext(sessionID, func(ctx *PlayerContext) error {
+		if err := checkAlive(ctx); err != nil {
+			return err
+		}
+
+		current := tree.currentNode(ctx, npcID)
+		option, exists := current.option(optionID)
+		if !exists {
+			return fmt.Errorf("%s has no option %q at node %q", tree.NPCName, optionID, current.ID)
+		}
+		chosen, node, location = option, tree.Nodes[option.Next], ctx.Location.Current
+
+		if ctx.DialogueNodes == nil {
+			ctx.DialogueNodes = make(map[string]string)
+		}
+		if len(node.Options) == 0 {
+			delete(ctx.DialogueNodes, npcID)
+		} else {
+			ctx.DialogueNodes[npcID] = node.ID
+		}
+		cm.applyNPCRelationship(ctx, npcID, tree.NPCName, option.DispositionChange, option.Facts)
+		return nil
+	})
+	if err != nil {
+		return DialogueNode{}, err
+	}
+
+	consequences := append([]string{}, chosen.Consequences...)
+	err = cm.RecordActionWithMetadata(sessionID, fmt.Sprintf("/talk %s %q", npcID, chosen.Text), "dialogue", npcID, location,
+		fmt.Sprintf("%s: %s", tree.NPCName, node.Text), consequences, copyMetadata(chosen.Metadata))
+	if err != nil {
+		return node, fmt.Errorf("failed to record dialogue: %w", err)
+	}
+	return node, nil
+}
+
+// TalkToNPC answers the player as an NPC. NPCs with a dialogue tree follow
+// it, taking input as the ID of the chosen option, or empty to hear the
+// current node; the reply lists the options open next. Other NPCs are voiced
+// by the AI with GenerateNPCDialogueInContext. NPCs that won't deal with a
+// player of such low reputation refuse either way.
+func (cm *ContextManager) TalkToNPC(sessionID, npcID, input string) (string, error) {
+	ctx, err := cm.GetContext(sessionID)
+	if err != nil {
+		return "", err
+	}
+	npcName := npcID
+	if tree, exists := cm.dialogueTree(npcID); exists {
+		npcName = tree.NPCName
+	}
+	if npc, exists := ctx.NPCStates[npcID]; exists {
+		npcName = npc.Name
+	}
+	if refusal, refused := cm.npcRefusal(ctx, npcID, npcName); refused {
+		return refusal, nil
+	}
+
+	node, err := cm.StepDialogue(sessionID, npcID, strings.TrimSpace(input))
+	if errors.Is(err, ErrNoDialogueTree) {
+		return cm.GenerateNPCDialogueInContext(sessionID, npcID, input)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	var reply strings.Builder
+	reply.WriteString(node.Text)
+	for _, option := range node.Options {
+		fmt.Fprintf(&reply, "\n- [%s] %s", option.ID, option.Text)
+	}
+	return reply.String(), nil
+}
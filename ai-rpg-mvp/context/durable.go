@@ -0,0 +1,37 @@
+package context
+
+// durableConsequences are action consequences worth saving the moment they
+// apply; losing them to a crash would cost the player real progress
+var durableConsequences = map[string]bool{
+	"level_up":        true,
+	"quest_completed": true,
+}
+
+// hasDurableConsequence reports whether any of consequences is durable
+func hasDurableConsequence(consequences []string) bool {
+	for _, consequence := range consequences {
+		if durableConsequences[consequence] {
+			return true
+		}
+	}
+	return false
+}
+
+// mutateContextDurably is mutateContext for critical updates such as quest
+// completion: the context is saved to storage before it returns rather than
+// on the next periodic save
+func (cm *ContextManager) mutateContextDurably(sessionID string, fn func(ctx *PlayerContext) error) error {
+	return cm.mutateContextWith(sessionID, true, fn)
+}
+
+// writeThrough saves a snapshot of a context straight to storage. A failed
+// save is logged; the periodic saver tries again. Callers must hold the
+// session lock, which the periodic saver also holds while it saves, so the
+// snapshot written here is the latest and the dirty flag can be cleared.
+func (cm *ContextManager) writeThrough(ctx *PlayerContext) {
+	if err := cm.storage.SaveContext(ctx.Clone()); err != nil {
+		cm.log().Error("Failed to write through context", "session_id", ctx.SessionID, "error", err)
+		return
+	}
+	cm.dirty.Delete(ctx.SessionID)
+}
@@ -0,0 +1,139 @@
+package context
+
+import (
+	"fmt"
+	"time"
+
+	"ai-rpg-mvp/combat"
+)
+
+// EncounterTemplate describes something the player can run into
+type EncounterTemplate struct {
+	ID          string             `json:"id"` // NPC or combat target ID seeded into the context
+	Name        string             `json:"name"`
+	Description string             `json:"description"` // what the GM should describe
+	Hostile     bool               `json:"hostile"`     // a combat target rather than someone to talk to
+	Stats       combat.CombatStats `json:"stats"`       // combat stats of a hostile encounter; zero uses the defaults
+}
+
+// EncounterEntry is one row of a location's encounter table. An entry without
+// a template ID is a quiet outcome where nothing happens.
+type EncounterEntry struct {
+	Weight   int               `json:"weight"`
+	Template EncounterTemplate `json:"template"`
+}
+
+// Encounter is an encounter rolled for a session, kept in its context until
+// the player moves on
+type Encounter struct {
+	EncounterTemplate
+	LocationID string    `json:"location_id"`
+	RolledAt   time.Time `json:"rolled_at"`
+}
+
+// SetEncounters replaces a location's encounter table. Each entry is picked
+// with probability proportional to its weight; an empty table clears it.
+// Hostile templates with stats have them registered for combat.
+func (cm *ContextManager) SetEncounters(locationID string, entries []EncounterEntry) error {
+	for _, entry := range entries {
+		if entry.Weight <= 0 {
+			return fmt.Errorf("encounter %q in %s needs a positive weight", entry.Template.ID, locationID)
+		}
+	}
+
+	cm.registryMutex.Lock()
+	defer cm.registryMutex.Unlock()
+
+	if len(entries) == 0 {
+		delete(cm.encounters, locationID)
+		return nil
+	}
+
+	cm.encounters[locationID] = append([]EncounterEntry{}, entries...)
+	for _, entry := range entries {
+		if entry.Template.ID != "" && entry.Template.Hostile && entry.Template.Stats != (combat.CombatStats{}) {
+			cm.npcCombatStats[entry.Template.ID] = entry.Template.Stats
+		}
+	}
+	return nil
+}
+
+// RollEncounter rolls on the encounter table of the player's location. A
+// rolled encounter becomes the context's current encounter, and a friendly
+// one is also introduced as an NPC. It returns nil when the table is empty or
+// the roll comes up quiet. Moving and interrupted rests roll automatically.
+func (cm *ContextManager) RollEncounter(sessionID string) (*Encounter, error) {
+	var encounter *Encounter
+	err := cm.mutateContext(sessionID, func(ctx *PlayerContext) error {
+		if err := checkAlive(ctx); err != nil {
+			return err
+		}
+
+		encounter = cm.rollEncounter(ctx)
+		return nil
+	})
+	if err != nil || encounter == nil {
+		return nil, err
+	}
+
+	rolled := *encounter
+	return &rolled, nil
+}
+
+// rollEncounter rolls on the table for the context's location and seeds the
+// result into the context. Callers must hold the session lock.
+func (cm *ContextManager) rollEncounter(ctx *PlayerContext) *Encounter {
+	locationID := ctx.Location.Current
+
+	cm.registryMutex.RLock()
+	entries := cm.encounters[locationID]
+	cm.registryMutex.RUnlock()
+
+	template, ok := cm.pickEncounter(entries)
+	if !ok || template.ID == "" {
+		return nil
+	}
+
+	ctx.Encounter = &Encounter{
+		EncounterTemplate: template,
+		LocationID:        locationID,
+		RolledAt:          cm.now(),
+	}
+	if !template.Hostile {
+		cm.applyNPCRelationship(ctx, template.ID, template.Name, 0, nil)
+	}
+	return ctx.Encounter
+}
+
+// pickEncounter makes a weighted choice from entries with the manager's dice
+func (cm *ContextManager) pickEncounter(entries []EncounterEntry) (EncounterTemplate, bool) {
+	total := 0
+	for _, entry := range entries {
+		total += entry.Weight
+	}
+	if total == 0 {
+		return EncounterTemplate{}, false
+	}
+
+	roll := cm.dice.Load().Roll(total)
+	for _, entry := range entries {
+		if roll <= entry.Weight {
+			return entry.Template, true
+		}
+		roll -= entry.Weight
+	}
+	return EncounterTemplate{}, false
+}
+
+// formatEncounter tells the GM what the player has run into, or nothing
+func (cm *ContextManager) formatEncounter(encounter *Encounter) string {
+	if encounter == nil {
+		return ""
+	}
+
+	kind := "NPC"
+	if encounter.Hostile {
+		kind = "hostile, combat target"
+	}
+	return fmt.Sprintf("\n- Encounter: %s (%s, ID: %s) - %s Describe it to the player.", encounter.Name, kind, encounter.ID, encounter.Description)
+}
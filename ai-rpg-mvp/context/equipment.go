@@ -0,0 +1,148 @@
+package context
+
+import (
+	"fmt"
+)
+
+const (
+	// SlotMainHand is the equipment slot for primary weapons
+	SlotMainHand = "mainhand"
+	// SlotOffHand is the equipment slot blocked by two-handed weapons
+	SlotOffHand = "offhand"
+
+	// twoHandedKey marks an equipment item as occupying both hands
+	twoHandedKey = "two_handed"
+)
+
+// EquipItem places an item into its declared slot. Any item previously in
+// that slot is moved back to the inventory and returned.
+func (cm *ContextManager) EquipItem(sessionID string, item EquipmentItem) (EquipmentItem, error) {
+	if item.ID == "" {
+		return EquipmentItem{}, fmt.Errorf("item ID is required")
+	}
+	if item.Slot == "" {
+		return EquipmentItem{}, fmt.Errorf("item %s has no equipment slot", item.ID)
+	}
+
+	var displaced EquipmentItem
+	err := cm.mutateContext(sessionID, func(ctx *PlayerContext) error {
+		if err := checkAlive(ctx); err != nil {
+			return err
+		}
+
+		// Two-handed weapons and offhand items are mutually exclusive
+		if isTwoHanded(item) {
+			if item.Slot != SlotMainHand {
+				return fmt.Errorf("two-handed item %s must be equipped in the %s slot", item.ID, SlotMainHand)
+			}
+			if findEquipmentSlot(ctx, SlotOffHand) >= 0 {
+				return fmt.Errorf("cannot equip two-handed item %s while the %s slot is occupied", item.ID, SlotOffHand)
+			}
+		}
+		if item.Slot == SlotOffHand {
+			if index := findEquipmentSlot(ctx, SlotMainHand); index >= 0 && isTwoHanded(ctx.Character.Equipment[index]) {
+				return fmt.Errorf("cannot equip %s: %s slot is blocked by a two-handed item", item.ID, SlotOffHand)
+			}
+		}
+
+		if index := findEquipmentSlot(ctx, item.Slot); index >= 0 {
+			displaced = ctx.Character.Equipment[index]
+			ctx.Character.Equipment = append(ctx.Character.Equipment[:index], ctx.Character.Equipment[index+1:]...)
+			addItemToInventory(ctx, equipmentToInventoryItem(displaced))
+		}
+
+		// Equipping an item the player carries takes it out of the inventory
+		if index := findInventoryItem(ctx, item.ID); index >= 0 {
+			ctx.Character.Inventory[index].Quantity--
+			if ctx.Character.Inventory[index].Quantity <= 0 {
+				cm.removeItemFromInventory(ctx, item.ID)
+			}
+		}
+
+		item.Stats = copyIntMap(item.Stats)
+		if item.Stats == nil {
+			item.Stats = make(map[string]int)
+		}
+		item.Metadata = copyMetadata(item.Metadata)
+		if item.Metadata == nil {
+			item.Metadata = make(map[string]interface{})
+		}
+		ctx.Character.Equipment = append(ctx.Character.Equipment, item)
+
+		return nil
+	})
+
+	return displaced, err
+}
+
+// UnequipItem removes the item in a slot and moves it back to the inventory
+func (cm *ContextManager) UnequipItem(sessionID, slot string) error {
+	return cm.mutateContext(sessionID, func(ctx *PlayerContext) error {
+		if err := checkAlive(ctx); err != nil {
+			return err
+		}
+
+		index := findEquipmentSlot(ctx, slot)
+		if index < 0 {
+			return fmt.Errorf("no item equipped in slot %s", slot)
+		}
+
+		item := ctx.Character.Equipment[index]
+		ctx.Character.Equipment = append(ctx.Character.Equipment[:index], ctx.Character.Equipment[index+1:]...)
+		addItemToInventory(ctx, equipmentToInventoryItem(item))
+
+		return nil
+	})
+}
+
+// GetEquippedBySlot returns the item equipped in a slot, if any
+func (cm *ContextManager) GetEquippedBySlot(sessionID, slot string) (*EquipmentItem, bool, error) {
+	ctx, err := cm.GetContext(sessionID)
+	if err != nil {
+		return nil, false, err
+	}
+
+	index := findEquipmentSlot(ctx, slot)
+	if index < 0 {
+		return nil, false, nil
+	}
+
+	item := ctx.Character.Equipment[index]
+	return &item, true, nil
+}
+
+// findEquipmentSlot returns the index of the item equipped in a slot, or -1
+func findEquipmentSlot(ctx *PlayerContext, slot string) int {
+	for i, item := range ctx.Character.Equipment {
+		if item.Slot == slot {
+			return i
+		}
+	}
+	return -1
+}
+
+// isTwoHanded reports whether an item's metadata marks it as two-handed
+func isTwoHanded(item EquipmentItem) bool {
+	twoHanded, _ := item.Metadata[twoHandedKey].(bool)
+	return twoHanded
+}
+
+// equipmentToInventoryItem converts an unequipped item into an inventory entry
+func equipmentToInventoryItem(item EquipmentItem) InventoryItem {
+	metadata := make(map[string]interface{}, len(item.Metadata)+2)
+	for key, value := range item.Metadata {
+		metadata[key] = value
+	}
+	metadata["slot"] = item.Slot
+	if len(item.Stats) > 0 {
+		metadata["stats"] = item.Stats
+	}
+
+	return InventoryItem{
+		ID:       item.ID,
+		Name:     item.Name,
+		Type:     item.Type,
+		Quantity: 1,
+		Metadata: metadata,
+	}
+}
@@ -1,10 +1,21 @@
 package context
 
 import (
-	"log"
+	"errors"
+	"fmt"
 	"time"
 )
 
+// Event queue policies: what recording an action does when the queue is full
+const (
+	EventQueueBlock      = "block"       // wait up to the queue timeout for room
+	EventQueueDropOldest = "drop_oldest" // evict the oldest queued action
+	EventQueueReject     = "reject"      // fail right away
+)
+
+// ErrEventQueueFull is returned when an action couldn't be queued
+var ErrEventQueueFull = errors.New("event queue full")
+
 // processEvents processes context events in the background
 func (cm *ContextManager) processEvents() {
 	defer cm.wg.Done()
@@ -12,13 +23,13 @@ func (cm *ContextManager) processEvents() {
 	for {
 		select {
 		case event := <-cm.eventQueue:
-			cm.processContextEvent(event)
+			cm.handleEvent(event)
 		case <-cm.shutdownCh:
 			// Process remaining events before shutdown
 			for {
 				select {
 				case event := <-cm.eventQueue:
-					cm.processContextEvent(event)
+					cm.handleEvent(event)
 				default:
 					return
 				}
@@ -27,109 +38,168 @@ func (cm *ContextManager) processEvents() {
 	}
 }
 
-// processContextEvent processes a single context event
-func (cm *ContextManager) processContextEvent(event ContextEvent) {
-	ctx, err := cm.GetContext(event.SessionID)
-	if err != nil {
-		log.Printf("Error getting context for session %s: %v", event.SessionID, err)
+// enqueueAction queues an action event, applying the queue policy when the
+// queue is full. Every action that doesn't make it into the queue, or is
+// evicted from it, is counted as dropped.
+func (cm *ContextManager) enqueueAction(event ContextEvent) error {
+	select {
+	case cm.eventQueue <- event:
+		return nil
+	default:
+	}
+
+	switch cm.queuePolicy {
+	case EventQueueDropOldest:
+		for {
+			select {
+			case cm.eventQueue <- event:
+				return nil
+			default:
+			}
+
+			// Only evict while the queue is still full
+			select {
+			case oldest := <-cm.eventQueue:
+				if oldest.processed != nil {
+					// A barrier at the front has nothing left to wait for
+					// except the event being processed; release it early
+					// rather than lose it
+					close(oldest.processed)
+					continue
+				}
+				cm.droppedEvents.Add(1)
+				cm.log().Warn("Event queue full, dropped action", "action_id", oldest.Event.ID, "session_id", oldest.SessionID)
+			default:
+			}
+		}
+
+	case EventQueueBlock:
+		timer := time.NewTimer(cm.queueTimeout)
+		defer timer.Stop()
+
+		select {
+		case cm.eventQueue <- event:
+			return nil
+		case <-timer.C:
+		case <-cm.shutdownCh:
+			cm.droppedEvents.Add(1)
+			return fmt.Errorf("context manager is shut down")
+		}
+	}
+
+	cm.droppedEvents.Add(1)
+	return ErrEventQueueFull
+}
+
+// handleEvent processes a queued event, releasing anyone waiting on a barrier
+func (cm *ContextManager) handleEvent(event ContextEvent) {
+	if event.processed != nil {
+		close(event.processed)
 		return
 	}
+	cm.processContextEvent(event)
+}
+
+// WaitForEvents blocks until every action recorded so far has been applied
+// to its session
+func (cm *ContextManager) WaitForEvents() error {
+	return cm.waitForQueuedEvents()
+}
 
-	// Add action to history
-	ctx.Actions = append(ctx.Actions, event.Event)
+// waitForQueuedEvents blocks until every event queued so far has been processed
+func (cm *ContextManager) waitForQueuedEvents() error {
+	select {
+	case <-cm.shutdownCh:
+		return fmt.Errorf("context manager is shut down")
+	default:
+	}
+
+	processed := make(chan struct{})
+	select {
+	case cm.eventQueue <- ContextEvent{processed: processed}:
+	case <-cm.shutdownCh:
+		return fmt.Errorf("context manager is shut down")
+	}
 
-	// Trim action history if too long
-	if len(ctx.Actions) > cm.maxActions {
-		ctx.Actions = ctx.Actions[len(ctx.Actions)-cm.maxActions:]
+	select {
+	case <-processed:
+		return nil
+	case <-cm.shutdownCh:
+		return fmt.Errorf("context manager is shut down")
 	}
+}
+
+// processContextEvent processes a single context event
+func (cm *ContextManager) processContextEvent(event ContextEvent) {
+	// Embed before taking the session lock; the embedder may be remote
+	embedding := cm.embedAction(event.Event)
+
+	var actionCount int
+	var metNPC bool
+	err := cm.mutateContextWith(event.SessionID, hasDurableConsequence(event.Event.Consequences), func(ctx *PlayerContext) error {
+		action := event.Event
+		action.Embedding = embedding
+
+		// Process action consequences, keeping what they changed for undo
+		action.Effects = cm.processActionConsequences(ctx, action)
+
+		// Add action to history
+		ctx.Actions = append(ctx.Actions, action)
 
-	// Process action consequences
-	cm.processActionConsequences(ctx, event.Event)
+		// Trim action history if too long
+		if len(ctx.Actions) > cm.maxActions {
+			ctx.Actions = ctx.Actions[len(ctx.Actions)-cm.maxActions:]
+		}
+
+		// Keep the GM's reply so later prompts can stay consistent with it
+		if action.Outcome != "" {
+			cm.appendDialogue(ctx, DialogueTurn{
+				ActionID:  action.ID,
+				Command:   action.Command,
+				Response:  action.Outcome,
+				Timestamp: action.Timestamp,
+			})
+		}
+
+		// Update session stats; any action keeps the session alive
+		cm.updateSessionStats(ctx, action)
+		ctx.ExpiresAt = cm.expiryFrom(cm.now())
+		actionCount = len(ctx.Actions)
+		_, metNPC = ctx.NPCStates[action.Target]
+
+		recorded := action.clone()
+		cm.publish(ContextChange{
+			Type:      ChangeActionRecorded,
+			SessionID: ctx.SessionID,
+			Timestamp: cm.now(),
+			Action:    &recorded,
+		})
+		return nil
+	})
+	if err != nil {
+		cm.log().Error("Failed to apply action", "session_id", event.SessionID, "error", err)
+		return
+	}
 
-	// Update session stats
-	cm.updateSessionStats(ctx, event.Event)
+	cm.maybeSummarize(event.SessionID, actionCount)
 
-	ctx.LastUpdate = time.Now()
-	cm.cache.Store(event.SessionID, ctx)
+	// A GM reply to something the player did to an NPC may teach it something
+	if action := event.Event; metNPC && action.Outcome != "" {
+		cm.extractNPCFactsInBackground(event.SessionID, action.Target, fmt.Sprintf("Player: %s\nGM: %s", action.Command, action.Outcome))
+	}
 }
 
 // processActionConsequences processes the consequences of a player action
-func (cm *ContextManager) processActionConsequences(ctx *PlayerContext, action ActionEvent) {
+// and returns the changes they made
+func (cm *ContextManager) processActionConsequences(ctx *PlayerContext, action ActionEvent) *ActionEffects {
+	effects := &ActionEffects{}
+	reputationBefore := ctx.Character.Reputation
+	healthBefore := ctx.Character.Health.Current
+	goldBefore := ctx.Character.Gold
+
 	for _, consequence := range action.Consequences {
-		switch consequence {
-		case "reputation_increase":
-			change := 5
-			if val, ok := action.Metadata["reputation_change"].(int); ok {
-				change = val
-			}
-			ctx.Character.Reputation += change
-			
-		case "reputation_decrease":
-			change := -10
-			if val, ok := action.Metadata["reputation_change"].(int); ok {
-				change = val
-			}
-			ctx.Character.Reputation += change
-			
-		case "health_damage":
-			if damage, ok := action.Metadata["damage"].(int); ok {
-				ctx.Character.Health.Current -= damage
-				if ctx.Character.Health.Current < 0 {
-					ctx.Character.Health.Current = 0
-				}
-			}
-			
-		case "health_heal":
-			if healing, ok := action.Metadata["healing"].(int); ok {
-				ctx.Character.Health.Current += healing
-				if ctx.Character.Health.Current > ctx.Character.Health.Max {
-					ctx.Character.Health.Current = ctx.Character.Health.Max
-				}
-			}
-			
-		case "npc_noticed":
-			if npcID, ok := action.Metadata["npc_id"].(string); ok {
-				if npcName, ok := action.Metadata["npc_name"].(string); ok {
-					cm.UpdateNPCRelationship(ctx.SessionID, npcID, npcName, 0, []string{
-						"noticed_player_" + action.Type,
-					})
-				}
-			}
-			
-		case "combat_victory":
-			ctx.Character.Reputation += 2
-			
-		case "combat_defeat":
-			ctx.Character.Reputation -= 1
-			
-		case "quest_completed":
-			if reward, ok := action.Metadata["reputation_reward"].(int); ok {
-				ctx.Character.Reputation += reward
-			}
-			
-		case "item_gained":
-			if itemData, ok := action.Metadata["item"].(map[string]interface{}); ok {
-				item := InventoryItem{
-					ID:       itemData["id"].(string),
-					Name:     itemData["name"].(string),
-					Type:     itemData["type"].(string),
-					Quantity: 1,
-					Value:    0,
-					Metadata: make(map[string]interface{}),
-				}
-				if quantity, ok := itemData["quantity"].(int); ok {
-					item.Quantity = quantity
-				}
-				if value, ok := itemData["value"].(int); ok {
-					item.Value = value
-				}
-				ctx.Character.Inventory = append(ctx.Character.Inventory, item)
-			}
-			
-		case "item_lost":
-			if itemID, ok := action.Metadata["item_id"].(string); ok {
-				cm.removeItemFromInventory(ctx, itemID)
-			}
+		if handler, exists := cm.lookupConsequence(ctx.SessionID, consequence); exists {
+			handler(ctx, action, effects)
 		}
 	}
 	
@@ -139,12 +209,17 @@ func (cm *ContextManager) processActionConsequences(ctx *PlayerContext, action A
 	} else if ctx.Character.Reputation < -100 {
 		ctx.Character.Reputation = -100
 	}
+
+	effects.ReputationDelta = ctx.Character.Reputation - reputationBefore
+	effects.HealthDelta = ctx.Character.Health.Current - healthBefore
+	effects.GoldDelta = ctx.Character.Gold - goldBefore
+	return effects
 }
 
 // updateSessionStats updates session statistics based on action
 func (cm *ContextManager) updateSessionStats(ctx *PlayerContext, action ActionEvent) {
 	ctx.SessionStats.TotalActions++
-	ctx.SessionStats.SessionTime = time.Since(ctx.StartTime).Minutes()
+	ctx.SessionStats.SessionTime = cm.now().Sub(ctx.StartTime).Minutes()
 	
 	switch action.Type {
 	case "combat", "attack", "defend":
@@ -154,6 +229,12 @@ func (cm *ContextManager) updateSessionStats(ctx *PlayerContext, action ActionEv
 	case "move", "explore", "examine", "look":
 		ctx.SessionStats.ExploreActions++
 	}
+
+	if contains(action.Consequences, "combat_victory") {
+		ctx.SessionStats.CombatVictories++
+	}
+
+	cm.evaluateAchievements(ctx, action.Effects)
 }
 
 // removeItemFromInventory removes an item from player inventory
@@ -185,32 +266,102 @@ func (cm *ContextManager) persistentSaver() {
 	}
 }
 
-// saveAllCachedContexts saves all cached contexts to storage
+// saveAllCachedContexts saves the cached contexts that changed since they
+// were last saved, counting the ones skipped
 func (cm *ContextManager) saveAllCachedContexts() {
 	cm.cache.Range(func(key, value interface{}) bool {
-		ctx := value.(*PlayerContext)
-		if err := cm.storage.SaveContext(ctx); err != nil {
-			log.Printf("Error saving context for session %s: %v", ctx.SessionID, err)
+		sessionID := key.(string)
+		saved, err := cm.persistCachedContext(sessionID, true)
+		if err != nil {
+			cm.log().Error("Failed to save context", "session_id", sessionID, "error", err)
+		} else if !saved {
+			cm.savesSkipped.Add(1)
 		}
 		return true
 	})
 }
 
+// contextCleaner is implemented by storage backends that can prune old
+// contexts themselves, such as PostgreSQL
+type contextCleaner interface {
+	CleanupOldContexts(olderThan time.Duration) (int, error)
+}
+
+// cleanupTicker periodically evicts idle contexts from the cache and, when
+// a maximum age is configured, prunes old contexts from storage
+func (cm *ContextManager) cleanupTicker() {
+	defer cm.wg.Done()
+
+	ticker := time.NewTicker(cm.cleanupInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			cm.cleanupOldContexts()
+			cm.cleanupStoredContexts()
+		case <-cm.shutdownCh:
+			return
+		}
+	}
+}
+
+// cleanupStoredContexts deletes contexts older than maxContextAge from
+// storage backends that support it
+func (cm *ContextManager) cleanupStoredContexts() {
+	cleaner, ok := cm.storage.(contextCleaner)
+	if !ok || cm.maxContextAge <= 0 {
+		return
+	}
+
+	removed, err := cleaner.CleanupOldContexts(cm.maxContextAge)
+	if err != nil {
+		cm.log().Error("Failed to clean up stored contexts", "error", err)
+		return
+	}
+	if removed > 0 {
+		cm.log().Info("Removed old stored contexts", "count", removed, "max_age", cm.maxContextAge)
+	}
+}
+
 // cleanupOldContexts removes old contexts from cache
 func (cm *ContextManager) cleanupOldContexts() {
-	cutoff := time.Now().Add(-cm.cacheTimeout)
-	
+	if evicted := cm.EvictIdle(cm.cacheTimeout); evicted > 0 {
+		cm.log().Info("Evicted idle contexts", "count", evicted)
+	}
+}
+
+// EvictIdle saves and drops from the cache every context not updated for
+// olderThan, returning how many were evicted. Evicted sessions stay in
+// storage and are loaded again when next used.
+func (cm *ContextManager) EvictIdle(olderThan time.Duration) int {
+	cutoff := cm.now().Add(-olderThan)
+
+	evicted := 0
 	cm.cache.Range(func(key, value interface{}) bool {
-		ctx := value.(*PlayerContext)
+		sessionID := key.(string)
+		lock := cm.sessionLock(sessionID)
+		lock.Lock()
+		defer lock.Unlock()
+
+		// The session may have been replaced or evicted since Range read it
+		current, ok := cm.cache.Load(sessionID)
+		if !ok {
+			return true
+		}
+		ctx := current.(*PlayerContext)
 		if ctx.LastUpdate.Before(cutoff) {
 			// Save before removing from cache
-			if err := cm.storage.SaveContext(ctx); err != nil {
-				log.Printf("Error saving context during cleanup: %v", err)
+			if err := cm.storage.SaveContext(ctx.Clone()); err != nil {
+				cm.log().Error("Failed to save context during cleanup", "session_id", sessionID, "error", err)
 			}
-			cm.cache.Delete(key)
+			cm.cache.Delete(sessionID)
+			cm.dirty.Delete(sessionID)
+			evicted++
 		}
 		return true
 	})
+	return evicted
 }
 
 // GetContextMetrics returns metrics about the context manager
@@ -227,19 +378,43 @@ func (cm *ContextManager) GetContextMetrics() map[string]interface{} {
 	metrics["cached_contexts"] = cacheCount
 	metrics["event_queue_size"] = len(cm.eventQueue)
 	metrics["max_actions"] = cm.maxActions
+	metrics["event_queue_policy"] = cm.queuePolicy
+	metrics["dropped_events"] = cm.droppedEvents.Load()
+	metrics["saves_skipped"] = cm.savesSkipped.Load()
 	metrics["cache_timeout_minutes"] = cm.cacheTimeout.Minutes()
 	metrics["persist_interval_minutes"] = cm.persistInterval.Minutes()
+	metrics["cleanup_interval_minutes"] = cm.cleanupInterval.Minutes()
 	
 	return metrics
 }
 
+// EventQueueDepth returns the number of events waiting to be processed
+func (cm *ContextManager) EventQueueDepth() int {
+	return len(cm.eventQueue)
+}
+
+// DroppedEvents returns how many events were discarded because the queue was full
+func (cm *ContextManager) DroppedEvents() int64 {
+	return cm.droppedEvents.Load()
+}
+
 // FlushContext forces immediate save of a specific context
 func (cm *ContextManager) FlushContext(sessionID string) error {
-	if cached, ok := cm.cache.Load(sessionID); ok {
-		ctx := cached.(*PlayerContext)
-		return cm.storage.SaveContext(ctx)
-	}
-	return nil
+	return cm.saveCachedContext(sessionID)
+}
+
+// FlushAll forces an immediate save of every cached context. A failed save
+// doesn't stop the others; the failures are returned joined.
+func (cm *ContextManager) FlushAll() error {
+	var errs []error
+	cm.cache.Range(func(key, value interface{}) bool {
+		sessionID := key.(string)
+		if err := cm.saveCachedContext(sessionID); err != nil {
+			errs = append(errs, fmt.Errorf("failed to save session %s: %w", sessionID, err))
+		}
+		return true
+	})
+	return errors.Join(errs...)
 }
 
 // GetActiveSessions returns list of active session IDs
@@ -262,11 +437,19 @@ func (cm *ContextManager) GetSessionDuration(sessionID string) (time.Duration, e
 		return 0, err
 	}
 	
-	return time.Since(ctx.StartTime), nil
+	return cm.now().Sub(ctx.StartTime), nil
 }
 
-// IsSessionActive checks if a session is currently active
+// IsSessionActive checks if a session is currently active: cached and, when
+// sessions expire, not idle past its expiry even if not yet swept
 func (cm *ContextManager) IsSessionActive(sessionID string) bool {
-	_, ok := cm.cache.Load(sessionID)
-	return ok
+	lock := cm.sessionLock(sessionID)
+	lock.Lock()
+	defer lock.Unlock()
+
+	value, ok := cm.cache.Load(sessionID)
+	if !ok {
+		return false
+	}
+	return !cm.sessionExpired(value.(*PlayerContext), cm.now())
 }
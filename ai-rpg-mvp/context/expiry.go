@@ -0,0 +1,113 @@
+package context
+
+import (
+	"time"
+)
+
+// DefaultSessionExpiryWarning is how long before an idle session expires that
+// its subscribers are warned, used by NewContextManager
+const DefaultSessionExpiryWarning = 5 * time.Minute
+
+// maxExpirySweepInterval is the longest the expiry sweeper waits between sweeps
+const maxExpirySweepInterval = time.Minute
+
+// expiryFrom returns when a session active at now expires, or zero when
+// sessions don't expire
+func (cm *ContextManager) expiryFrom(now time.Time) time.Time {
+	if cm.sessionTTL <= 0 {
+		return time.Time{}
+	}
+	return now.Add(cm.sessionTTL)
+}
+
+// expiresAt returns when ctx expires. Contexts saved before expiry was
+// enabled count from their last update.
+func (cm *ContextManager) expiresAt(ctx *PlayerContext) time.Time {
+	if ctx.ExpiresAt.IsZero() {
+		return ctx.LastUpdate.Add(cm.sessionTTL)
+	}
+	return ctx.ExpiresAt
+}
+
+// sessionExpired reports whether ctx has been idle past its expiry at now.
+// Callers must hold the session lock.
+func (cm *ContextManager) sessionExpired(ctx *PlayerContext, now time.Time) bool {
+	return cm.sessionTTL > 0 && !now.Before(cm.expiresAt(ctx))
+}
+
+// expiryTicker sweeps for idle sessions often enough to warn before they
+// expire, until shutdown
+func (cm *ContextManager) expiryTicker() {
+	defer cm.wg.Done()
+
+	interval := cm.sessionTTL / 10
+	if cm.expiryWarning > 0 && cm.expiryWarning/2 < interval {
+		interval = cm.expiryWarning / 2
+	}
+	if interval > maxExpirySweepInterval {
+		interval = maxExpirySweepInterval
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			cm.sweepExpiredSessions(cm.now())
+		case <-cm.shutdownCh:
+			return
+		}
+	}
+}
+
+// sweepExpiredSessions warns the subscribers of sessions about to expire, once
+// per expiry time, and saves and evicts sessions that have expired
+func (cm *ContextManager) sweepExpiredSessions(now time.Time) {
+	cm.cache.Range(func(key, value interface{}) bool {
+		sessionID := key.(string)
+		lock := cm.sessionLock(sessionID)
+		lock.Lock()
+		defer lock.Unlock()
+
+		// The session may have been evicted since Range read it
+		current, ok := cm.cache.Load(sessionID)
+		if !ok {
+			return true
+		}
+		ctx := current.(*PlayerContext)
+		expiresAt := cm.expiresAt(ctx)
+
+		if cm.sessionExpired(ctx, now) {
+			cm.publish(ContextChange{
+				Type:      ChangeSessionExpired,
+				SessionID: sessionID,
+				Timestamp: now,
+				ExpiresAt: &expiresAt,
+			})
+			if err := cm.storage.SaveContext(ctx.Clone()); err != nil {
+				cm.log().Error("Failed to save expired context", "session_id", sessionID, "error", err)
+			}
+			cm.cache.Delete(sessionID)
+			cm.dirty.Delete(sessionID)
+			cm.expiryWarned.Delete(sessionID)
+			cm.log().Info("Session expired", "session_id", sessionID, "expired_at", expiresAt)
+			return true
+		}
+
+		if cm.expiryWarning <= 0 || now.Before(expiresAt.Add(-cm.expiryWarning)) {
+			return true
+		}
+		if warned, ok := cm.expiryWarned.Load(sessionID); ok && warned.(time.Time).Equal(expiresAt) {
+			return true
+		}
+		cm.expiryWarned.Store(sessionID, expiresAt)
+		cm.publish(ContextChange{
+			Type:      ChangeSessionExpiring,
+			SessionID: sessionID,
+			Timestamp: now,
+			ExpiresAt: &expiresAt,
+		})
+		return true
+	})
+}
@@ -0,0 +1,154 @@
+package context
+
+import (
+	"fmt"
+	"time"
+)
+
+// Time-of-day buckets and the in-game hour each one starts at
+const (
+	TimeOfDayDawn  = "dawn"
+	TimeOfDayDay   = "day"
+	TimeOfDayDusk  = "dusk"
+	TimeOfDayNight = "night"
+
+	DawnStartHour  = 5
+	DayStartHour   = 8
+	DuskStartHour  = 18
+	NightStartHour = 21
+)
+
+const (
+	// DefaultGameTimeScale is how many game minutes pass per real minute
+	DefaultGameTimeScale = 10.0
+
+	// travelGameMinutes is how long moving between locations takes
+	travelGameMinutes = 30
+	// restGameMinutes is how long a rest takes unless the action says otherwise
+	restGameMinutes = 8 * 60
+)
+
+// gameEpoch is the in-game time new sessions start at: the morning of day 1
+var gameEpoch = time.Date(1000, time.January, 1, DayStartHour, 0, 0, 0, time.UTC)
+
+// GameClock tracks in-game time. Game time runs TimeScale times faster than
+// real time, on top of any time explicitly advanced by play.
+type GameClock struct {
+	GameTime  time.Time `json:"game_time"`  // in-game time as of SyncedAt
+	SyncedAt  time.Time `json:"synced_at"`  // real time GameTime was last brought up to date
+	TimeScale float64   `json:"time_scale"` // game minutes per real minute
+}
+
+// newGameClock starts a clock at the game epoch, synced at now
+func newGameClock(timeScale float64, now time.Time) GameClock {
+	return GameClock{
+		GameTime:  gameEpoch,
+		SyncedAt:  now,
+		TimeScale: timeScale,
+	}
+}
+
+// Now returns the in-game time at the given real time
+func (c GameClock) Now(realNow time.Time) time.Time {
+	if c.GameTime.IsZero() {
+		return gameEpoch
+	}
+	elapsed := realNow.Sub(c.SyncedAt)
+	if elapsed < 0 {
+		elapsed = 0
+	}
+	return c.GameTime.Add(time.Duration(float64(elapsed) * c.TimeScale))
+}
+
+// advance brings the clock up to date at now and moves it forward by
+// gameMinutes
+func (c *GameClock) advance(gameMinutes int, now time.Time) {
+	c.GameTime = c.Now(now).Add(time.Duration(gameMinutes) * time.Minute)
+	c.SyncedAt = now
+}
+
+// gameDay returns the in-game day number, starting at 1
+func gameDay(gameTime time.Time) int {
+	return int(gameTime.Sub(gameEpoch.Truncate(24*time.Hour)).Hours()/24) + 1
+}
+
+// timeOfDay buckets an in-game time into dawn, day, dusk or night
+func timeOfDay(gameTime time.Time) string {
+	hour := gameTime.Hour()
+	switch {
+	case hour >= NightStartHour || hour < DawnStartHour:
+		return TimeOfDayNight
+	case hour >= DuskStartHour:
+		return TimeOfDayDusk
+	case hour >= DayStartHour:
+		return TimeOfDayDay
+	default:
+		return TimeOfDayDawn
+	}
+}
+
+// SetNowFunc replaces the clock the manager reads the current time from,
+// which timestamps events and measures elapsed time in prompts. Set it before
+// creating sessions; a fixed clock makes prompts reproducible. It is safe to
+// call while the background workers run.
+func (cm *ContextManager) SetNowFunc(now func() time.Time) {
+	cm.nowFunc.Store(&now)
+}
+
+// now returns the current time according to the manager's clock
+func (cm *ContextManager) now() time.Time {
+	return (*cm.nowFunc.Load())()
+}
+
+// SetGameTimeScale sets how many game minutes pass per real minute for new sessions
+func (cm *ContextManager) SetGameTimeScale(scale float64) {
+	cm.gameTimeScale = scale
+}
+
+// AdvanceGameTime moves a session's in-game clock forward
+func (cm *ContextManager) AdvanceGameTime(sessionID string, gameMinutes int) error {
+	if gameMinutes < 0 {
+		return fmt.Errorf("cannot move game time backwards by %d minutes", gameMinutes)
+	}
+
+	return cm.mutateContext(sessionID, func(ctx *PlayerContext) error {
+		cm.advanceClock(ctx, gameMinutes)
+		return nil
+	})
+}
+
+// GetGameTime returns the session's current in-game time
+func (cm *ContextManager) GetGameTime(sessionID string) (time.Time, error) {
+	ctx, err := cm.GetContext(sessionID)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	return ctx.Clock.Now(cm.now()), nil
+}
+
+// GetTimeOfDay returns "dawn", "day", "dusk" or "night" for the session
+func (cm *ContextManager) GetTimeOfDay(sessionID string) (string, error) {
+	gameTime, err := cm.GetGameTime(sessionID)
+	if err != nil {
+		return "", err
+	}
+
+	return timeOfDay(gameTime), nil
+}
+
+// advanceClock moves a context's clock forward, starting one if the context predates clocks
+func (cm *ContextManager) advanceClock(ctx *PlayerContext, gameMinutes int) {
+	if ctx.Clock.GameTime.IsZero() {
+		ctx.Clock = newGameClock(cm.gameTimeScale, cm.now())
+	}
+	ctx.Clock.advance(gameMinutes, cm.now())
+	refreshTimeInLocation(ctx, ctx.Clock.SyncedAt)
+	cm.updateWeather(ctx)
+}
+
+// formatGameTime describes the in-game time for AI prompts
+func (cm *ContextManager) formatGameTime(clock GameClock) string {
+	gameTime := clock.Now(cm.now())
+	return fmt.Sprintf("%s (Day %d, %s)", timeOfDay(gameTime), gameDay(gameTime), gameTime.Format("15:04"))
+}
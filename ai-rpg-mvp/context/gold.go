@@ -0,0 +1,57 @@
+package context
+
+import (
+	"fmt"
+)
+
+// AddGold credits gold to the player. With split set, the gold is shared
+// evenly with party members at the same location, the player keeping any
+// remainder; players outside a party keep it all.
+func (cm *ContextManager) AddGold(sessionID string, amount int, split bool) error {
+	if amount <= 0 {
+		return fmt.Errorf("gold amount must be positive, got %d", amount)
+	}
+
+	if split {
+		return cm.splitGold(sessionID, amount)
+	}
+	return cm.creditGold(sessionID, amount)
+}
+
+// creditGold adds gold to a single player
+func (cm *ContextManager) creditGold(sessionID string, amount int) error {
+	return cm.mutateContext(sessionID, func(ctx *PlayerContext) error {
+		ctx.Character.Gold += amount
+		return nil
+	})
+}
+
+// SpendGold debits gold from the player, failing if they cannot afford it
+func (cm *ContextManager) SpendGold(sessionID string, amount int) error {
+	if amount <= 0 {
+		return fmt.Errorf("gold amount must be positive, got %d", amount)
+	}
+
+	return cm.mutateContext(sessionID, func(ctx *PlayerContext) error {
+		if err := checkAlive(ctx); err != nil {
+			return err
+		}
+
+		if ctx.Character.Gold < amount {
+			return fmt.Errorf("cannot spend %d gold: only %d available", amount, ctx.Character.Gold)
+		}
+
+		ctx.Character.Gold -= amount
+		return nil
+	})
+}
+
+// GetGold returns the player's gold
+func (cm *ContextManager) GetGold(sessionID string) (int, error) {
+	ctx, err := cm.GetContext(sessionID)
+	if err != nil {
+		return 0, err
+	}
+
+	return ctx.Character.Gold, nil
+}
@@ -0,0 +1,151 @@
+package context
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// Defaults for remembering idempotency keys
+const (
+	DefaultIdempotencyKeyTTL = 24 * time.Hour
+	DefaultIdempotencyKeys   = 10000
+)
+
+// idempotencyKeys is a bounded set of recently seen keys. Keys expire after
+// ttl, and the least recently seen key is forgotten once the set is full.
+type idempotencyKeys struct {
+	mutex   sync.Mutex
+	entries map[string]*list.Element
+	order   *list.List // front is most recently seen
+	ttl     time.Duration
+	max     int
+}
+
+type idempotencyEntry struct {
+	key    string
+	seenAt time.Time
+}
+
+// newIdempotencyKeys creates a set holding at most max keys for ttl each
+func newIdempotencyKeys(ttl time.Duration, max int) *idempotencyKeys {
+	return &idempotencyKeys{
+		entries: make(map[string]*list.Element),
+		order:   list.New(),
+		ttl:     ttl,
+		max:     max,
+	}
+}
+
+// claim records key as seen and reports whether it was new. A key seen within
+// the TTL is left as it was, so replays don't extend its life.
+func (s *idempotencyKeys) claim(key string, now time.Time) bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.expire(now)
+	if _, exists := s.entries[key]; exists {
+		return false
+	}
+
+	if s.order.Len() >= s.max {
+		s.remove(s.order.Back())
+	}
+	s.entries[key] = s.order.PushFront(&idempotencyEntry{key: key, seenAt: now})
+	return true
+}
+
+// contains reports whether key was seen within the TTL
+func (s *idempotencyKeys) contains(key string, now time.Time) bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.expire(now)
+	_, exists := s.entries[key]
+	return exists
+}
+
+// release forgets key, so a request that failed can be retried with it
+func (s *idempotencyKeys) release(key string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if element, exists := s.entries[key]; exists {
+		s.remove(element)
+	}
+}
+
+// expire drops keys older than the TTL. Keys are claimed in time order, so
+// the oldest are at the back. The caller must hold the mutex.
+func (s *idempotencyKeys) expire(now time.Time) {
+	for element := s.order.Back(); element != nil; element = s.order.Back() {
+		if now.Sub(element.Value.(*idempotencyEntry).seenAt) < s.ttl {
+			return
+		}
+		s.remove(element)
+	}
+}
+
+// remove drops an entry. The caller must hold the mutex.
+func (s *idempotencyKeys) remove(element *list.Element) {
+	s.order.Remove(element)
+	delete(s.entries, element.Value.(*idempotencyEntry).key)
+}
+
+// sessionIdempotencyKey scopes a client's key to its session
+func sessionIdempotencyKey(sessionID, key string) string {
+	return sessionID + "\x00" + key
+}
+
+// RecordActionIdempotent records a player action like RecordAction, but only
+// the first time key is seen for the session; retries of the same request
+// return applied=false without recording anything. An empty key always
+// records. If recording fails the key is forgotten so the client can retry.
+func (cm *ContextManager) RecordActionIdempotent(sessionID, key, command, actionType, target, location, outcome string, consequences []string) (bool, error) {
+	if key == "" {
+		return true, cm.RecordAction(sessionID, command, actionType, target, location, outcome, consequences)
+	}
+
+	scoped := sessionIdempotencyKey(sessionID, key)
+	if !cm.idempotency.claim(scoped, cm.now()) {
+		return false, nil
+	}
+
+	if err := cm.RecordAction(sessionID, command, actionType, target, location, outcome, consequences); err != nil {
+		cm.idempotency.release(scoped)
+		return false, err
+	}
+	return true, nil
+}
+
+// ClaimIdempotencyKey takes key for the session before a request changes
+// anything and reports whether it was free. A false result means the request
+// is a retry, of one that finished or one still in progress, and must not be
+// played again. Servers whose requests have effects beyond the recorded
+// action, such as combat or NPC changes, claim the key before applying them
+// and record with RecordAction; if the request fails before changing
+// anything they hand the key back with ReleaseIdempotencyKey. An empty key
+// is always free.
+func (cm *ContextManager) ClaimIdempotencyKey(sessionID, key string) bool {
+	if key == "" {
+		return true
+	}
+	return cm.idempotency.claim(sessionIdempotencyKey(sessionID, key), cm.now())
+}
+
+// ReleaseIdempotencyKey forgets a key claimed with ClaimIdempotencyKey, so the
+// client can retry a request that failed
+func (cm *ContextManager) ReleaseIdempotencyKey(sessionID, key string) {
+	if key != "" {
+		cm.idempotency.release(sessionIdempotencyKey(sessionID, key))
+	}
+}
+
+// IdempotencyKeySeen reports whether an action with key was already claimed
+// for the session, so servers can answer a retry without redoing its work
+func (cm *ContextManager) IdempotencyKeySeen(sessionID, key string) bool {
+	if key == "" {
+		return false
+	}
+	return cm.idempotency.contains(sessionIdempotencyKey(sessionID, key), cm.now())
+}
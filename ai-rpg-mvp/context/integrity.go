@@ -0,0 +1,98 @@
+package context
+
+import (
+	"errors"
+	"fmt"
+)
+
+// InvalidContextError reports a context that breaks one of the invariants the
+// rest of the package relies on, such as health above its maximum
+type InvalidContextError struct {
+	Field   string // path of the offending field, such as "character.health"
+	Problem string
+}
+
+func (e *InvalidContextError) Error() string {
+	return fmt.Sprintf("invalid context: %s %s", e.Field, e.Problem)
+}
+
+// ValidateContext checks a context from outside the manager, such as an
+// imported one or one restored from a backup or snapshot, before the game
+// trusts it. It reports every broken invariant, joined, as
+// *InvalidContextError values: health, mana and stamina within 0 and their
+// maximum, reputation and NPC dispositions within -100 and 100, non-empty
+// session, NPC, item and action IDs, and a previous location only alongside
+// a different current one.
+func ValidateContext(ctx *PlayerContext) error {
+	if ctx == nil {
+		return &InvalidContextError{Field: "context", Problem: "is missing"}
+	}
+
+	var errs []error
+	invalid := func(field, format string, args ...interface{}) {
+		errs = append(errs, &InvalidContextError{Field: field, Problem: fmt.Sprintf(format, args...)})
+	}
+	checkRange := func(field string, value, low, high int) {
+		if value < low || value > high {
+			invalid(field, "is %d, outside %d to %d", value, low, high)
+		}
+	}
+
+	if ctx.SessionID == "" {
+		invalid("session_id", "is empty")
+	}
+
+	character := ctx.Character
+	if character.Health.Max < 0 {
+		invalid("character.health.max", "is negative (%d)", character.Health.Max)
+	} else {
+		checkRange("character.health.current", character.Health.Current, 0, character.Health.Max)
+	}
+	for _, pool := range []struct {
+		name string
+		ResourcePool
+	}{{ResourceMana, character.Mana}, {ResourceStamina, character.Stamina}} {
+		if pool.Max < 0 {
+			invalid("character."+pool.name+".max", "is negative (%d)", pool.Max)
+		} else {
+			checkRange("character."+pool.name+".current", pool.Current, 0, pool.Max)
+		}
+	}
+	checkRange("character.reputation", character.Reputation, -100, 100)
+	for faction, standing := range character.FactionReputation {
+		checkRange("character.faction_reputation."+faction, standing, -100, 100)
+	}
+	if character.Gold < 0 {
+		invalid("character.gold", "is negative (%d)", character.Gold)
+	}
+	for i, item := range character.Inventory {
+		if item.ID == "" {
+			invalid(fmt.Sprintf("character.inventory[%d].id", i), "is empty")
+		}
+	}
+
+	if ctx.Location.Previous != "" {
+		if ctx.Location.Current == "" {
+			invalid("location.current", "is empty but the previous location is %q", ctx.Location.Previous)
+		} else if ctx.Location.Previous == ctx.Location.Current {
+			invalid("location.previous", "is the current location %q", ctx.Location.Current)
+		}
+	}
+
+	for key, npc := range ctx.NPCStates {
+		if npc.NPCID == "" {
+			invalid("npc_states."+key+".npc_id", "is empty")
+		} else if npc.NPCID != key {
+			invalid("npc_states."+key+".npc_id", "is %q, stored under %q", npc.NPCID, key)
+		}
+		checkRange("npc_states."+key+".disposition", npc.Disposition, -100, 100)
+	}
+
+	for i, action := range ctx.Actions {
+		if action.ID == "" {
+			invalid(fmt.Sprintf("actions[%d].id", i), "is empty")
+		}
+	}
+
+	return errors.Join(errs...)
+}
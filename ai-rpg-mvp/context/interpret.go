@@ -0,0 +1,214 @@
+package context
+
+import (
+	"container/list"
+	"fmt"
+	"hash/fnv"
+	"sort"
+	"strings"
+	"sync"
+
+	"ai-rpg-mvp/command"
+)
+
+// DefaultInterpretationCacheSize bounds how many interpreted phrases are remembered
+const DefaultInterpretationCacheSize = 1000
+
+// CommandInterpreter maps free text a player typed to a game command. The
+// grounding lists what the player can refer to. ai.AIService satisfies it.
+type CommandInterpreter interface {
+	InterpretCommandForSession(sessionID, freeText string, grounding CommandGrounding) (CommandInterpretation, error)
+}
+
+// CommandGrounding is what a free-text command may refer to
+type CommandGrounding struct {
+	Verbs     []string          // command verbs the game understands
+	Location  string            // where the player is
+	Exits     []string          // locations reachable from there
+	NPCs      map[string]string // NPC ID -> name, for NPCs the player has met
+	Inventory map[string]string // item ID -> name
+}
+
+// CommandInterpretation is the interpreter's reading of a free-text command
+type CommandInterpretation struct {
+	Verb       string `json:"verb"`
+	ActionType string `json:"actionType"`
+	Target     string `json:"target"`
+}
+
+// SetCommandInterpreter sets the AI used by InterpretCommand
+func (cm *ContextManager) SetCommandInterpreter(interpreter CommandInterpreter) {
+	cm.registryMutex.Lock()
+	defer cm.registryMutex.Unlock()
+
+	cm.commandInterpreter = interpreter
+}
+
+// InterpretCommand parses a player's command. Slash commands go straight to
+// the command parser; anything else is interpreted by the AI, grounded in the
+// session's location, NPCs and inventory. Interpretations are cached by
+// session, grounding and normalized text, so "talk to him" is only reused
+// while it can still mean the same NPC.
+// When no interpreter is set or interpretation fails the text is parsed as
+// typed, so a leading verb still works without the slash.
+func (cm *ContextManager) InterpretCommand(sessionID, freeText string) (command.Command, error) {
+	text := strings.TrimSpace(freeText)
+	if strings.HasPrefix(text, "/") {
+		return command.Parse(text), nil
+	}
+
+	ctx, err := cm.GetContext(sessionID)
+	if err != nil {
+		return command.Command{}, err
+	}
+
+	cm.registryMutex.RLock()
+	interpreter := cm.commandInterpreter
+	cm.registryMutex.RUnlock()
+	if interpreter == nil || text == "" {
+		return command.Parse(text), nil
+	}
+
+	grounding := cm.commandGrounding(ctx)
+	key := interpretationKey(sessionID, grounding, text)
+	if interpretation, ok := cm.interpretations.get(key); ok {
+		return interpretedCommand(interpretation), nil
+	}
+
+	interpretation, err := interpreter.InterpretCommandForSession(sessionID, text, grounding)
+	if err != nil {
+		cm.log().Warn("Failed to interpret command", "session_id", sessionID, "command", text, "error", err)
+		return command.Parse(text), nil
+	}
+
+	cm.interpretations.put(key, interpretation)
+	return interpretedCommand(interpretation), nil
+}
+
+// commandGrounding collects what a session's free-text commands may refer to
+func (cm *ContextManager) commandGrounding(ctx *PlayerContext) CommandGrounding {
+	grounding := CommandGrounding{
+		Verbs:     command.Verbs(),
+		Location:  ctx.Location.Current,
+		NPCs:      make(map[string]string, len(ctx.NPCStates)),
+		Inventory: make(map[string]string, len(ctx.Character.Inventory)),
+	}
+	if worldMap := cm.GetWorldMap(); worldMap != nil {
+		grounding.Exits = worldMap.Exits(ctx.Location.Current)
+	}
+	for id, npc := range ctx.NPCStates {
+		grounding.NPCs[id] = npc.Name
+	}
+	for _, item := range ctx.Character.Inventory {
+		grounding.Inventory[item.ID] = item.Name
+	}
+	return grounding
+}
+
+// interpretationKey is the cache key for a session's free text: the session,
+// a hash of what the text could refer to and the normalized text
+func interpretationKey(sessionID string, grounding CommandGrounding, text string) string {
+	h := fnv.New64a()
+	fmt.Fprintf(h, "%s\x00%s\x00", grounding.Location, strings.Join(grounding.Exits, ","))
+	for _, names := range []map[string]string{grounding.NPCs, grounding.Inventory} {
+		ids := make([]string, 0, len(names))
+		for id := range names {
+			ids = append(ids, id)
+		}
+		sort.Strings(ids)
+		for _, id := range ids {
+			fmt.Fprintf(h, "%s=%s,", id, names[id])
+		}
+		h.Write([]byte{0})
+	}
+	return fmt.Sprintf("%s\x00%x\x00%s", sessionID, h.Sum64(), normalizeFreeText(text))
+}
+
+// interpretedCommand turns an interpretation into a command. A verb the
+// parser knows brings its consequences; any other is recorded as the AI
+// described it, with none.
+func interpretedCommand(interpretation CommandInterpretation) command.Command {
+	target := interpretation.Target
+	if target == "" {
+		target = command.Unknown
+	}
+
+	parsed := command.Parse("/" + interpretation.Verb)
+	if interpretation.Verb != "" && parsed.ActionType != command.Unknown {
+		parsed.Target = target
+		return parsed
+	}
+
+	actionType := interpretation.ActionType
+	if actionType == "" {
+		actionType = command.Unknown
+	}
+	return command.Command{
+		Verb:         interpretation.Verb,
+		ActionType:   actionType,
+		Target:       target,
+		Consequences: []string{},
+	}
+}
+
+// normalizeFreeText folds case, spacing and trailing punctuation so phrasings
+// that differ only in those share a cache entry
+func normalizeFreeText(text string) string {
+	text = strings.ToLower(strings.Join(strings.Fields(text), " "))
+	return strings.TrimRight(text, ".!?")
+}
+
+// interpretationCache remembers recent interpretations, forgetting the least
+// recently used once full
+type interpretationCache struct {
+	mutex   sync.Mutex
+	entries map[string]*list.Element
+	order   *list.List // front is most recently used
+	max     int
+}
+
+type interpretationEntry struct {
+	text           string
+	interpretation CommandInterpretation
+}
+
+// newInterpretationCache creates a cache holding at most max interpretations
+func newInterpretationCache(max int) *interpretationCache {
+	return &interpretationCache{
+		entries: make(map[string]*list.Element),
+		order:   list.New(),
+		max:     max,
+	}
+}
+
+// get returns the interpretation cached for text
+func (c *interpretationCache) get(text string) (CommandInterpretation, bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	element, ok := c.entries[text]
+	if !ok {
+		return CommandInterpretation{}, false
+	}
+	c.order.MoveToFront(element)
+	return element.Value.(*interpretationEntry).interpretation, true
+}
+
+// put caches an interpretation of text
+func (c *interpretationCache) put(text string, interpretation CommandInterpretation) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if element, ok := c.entries[text]; ok {
+		element.Value.(*interpretationEntry).interpretation = interpretation
+		c.order.MoveToFront(element)
+		return
+	}
+
+	if c.order.Len() >= c.max {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*interpretationEntry).text)
+	}
+	c.entries[text] = c.order.PushFront(&interpretationEntry{text: text, interpretation: interpretation})
+}
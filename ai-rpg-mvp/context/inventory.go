@@ -0,0 +1,86 @@
+package context
+
+import (
+	"fmt"
+)
+
+// AddInventoryItem adds an item to the player's inventory, stacking it onto
+// an existing entry with the same ID
+func (cm *ContextManager) AddInventoryItem(sessionID string, item InventoryItem) error {
+	if item.ID == "" {
+		return fmt.Errorf("item ID is required")
+	}
+	if item.Quantity < 0 {
+		return fmt.Errorf("invalid quantity %d for item %s", item.Quantity, item.ID)
+	}
+
+	return cm.mutateContext(sessionID, func(ctx *PlayerContext) error {
+		addItemToInventory(ctx, item)
+		return nil
+	})
+}
+
+// RemoveInventoryItem removes quantity units of an item, deleting the entry
+// once its quantity reaches zero
+func (cm *ContextManager) RemoveInventoryItem(sessionID, itemID string, quantity int) error {
+	if quantity <= 0 {
+		return fmt.Errorf("quantity to remove must be positive, got %d", quantity)
+	}
+
+	return cm.mutateContext(sessionID, func(ctx *PlayerContext) error {
+		index := findInventoryItem(ctx, itemID)
+		if index < 0 {
+			return fmt.Errorf("item %s not in inventory", itemID)
+		}
+
+		item := &ctx.Character.Inventory[index]
+		if item.Quantity < quantity {
+			return fmt.Errorf("cannot remove %d of item %s: only %d in inventory", quantity, itemID, item.Quantity)
+		}
+
+		item.Quantity -= quantity
+		if item.Quantity == 0 {
+			cm.removeItemFromInventory(ctx, itemID)
+		}
+
+		return nil
+	})
+}
+
+// GetInventory returns the player's inventory
+func (cm *ContextManager) GetInventory(sessionID string) ([]InventoryItem, error) {
+	ctx, err := cm.GetContext(sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	return ctx.Character.Inventory, nil
+}
+
+// addItemToInventory stacks an item onto a matching entry or appends it
+func addItemToInventory(ctx *PlayerContext, item InventoryItem) {
+	if item.Quantity == 0 {
+		item.Quantity = 1
+	}
+	item.Metadata = copyMetadata(item.Metadata)
+	if item.Metadata == nil {
+		item.Metadata = make(map[string]interface{})
+	}
+
+	if index := findInventoryItem(ctx, item.ID); index >= 0 {
+		ctx.Character.Inventory[index].Quantity += item.Quantity
+		return
+	}
+
+	ctx.Character.Inventory = append(ctx.Character.Inventory, item)
+}
+
+// findInventoryItem returns the index of an item in the inventory, or -1
+func findInventoryItem(ctx *PlayerContext, itemID string) int {
+	for i, item := range ctx.Character.Inventory {
+		if item.ID == itemID {
+			return i
+		}
+	}
+	return -1
+}
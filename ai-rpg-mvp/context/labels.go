@@ -0,0 +1,54 @@
+package context
+
+import "math"
+
+// standingBand is one band of the shared -100 to 100 scale used for both the
+// player's reputation and an NPC's disposition toward them
+type standingBand struct {
+	min          int    // lowest value in the band
+	reputation   string // ReputationLabel
+	mood         string // MoodForDisposition
+	relationship string // RelationshipLevel
+}
+
+// standingBands classifies reputation and disposition, highest band first.
+// Every label uses the same 25-point boundaries so they can't drift apart.
+// Moods have no band of their own above 50: a close friend and a friend are
+// both "friendly", since NPC dialogue tones are keyed by mood.
+var standingBands = []standingBand{
+	{75, "Heroic", "friendly", "close_friend"},
+	{50, "Well-regarded", "friendly", "friend"},
+	{25, "Respected", "helpful", "ally"},
+	{0, "Neutral", "neutral", "acquaintance"},
+	{-25, "Mistrusted", "suspicious", "stranger"},
+	{-50, "Disliked", "unfriendly", "rival"},
+	{math.MinInt, "Notorious", "hostile", "enemy"},
+}
+
+// standingBandFor returns the band value falls in
+func standingBandFor(value int) standingBand {
+	for _, band := range standingBands {
+		if value >= band.min {
+			return band
+		}
+	}
+	return standingBands[len(standingBands)-1]
+}
+
+// ReputationLabel describes a reputation from -100 to 100 in a word, as shown
+// to players and the GM
+func ReputationLabel(reputation int) string {
+	return standingBandFor(reputation).reputation
+}
+
+// MoodForDisposition returns the mood of an NPC with the given disposition
+// toward the player
+func MoodForDisposition(disposition int) string {
+	return standingBandFor(disposition).mood
+}
+
+// RelationshipLevel names the relationship an NPC's disposition amounts to,
+// from "enemy" to "close_friend"
+func RelationshipLevel(disposition int) string {
+	return standingBandFor(disposition).relationship
+}
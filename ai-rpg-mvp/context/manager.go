@@ -1,11 +1,17 @@
 package context
 
 import (
+	"errors"
 	"fmt"
-	"log"
+	"log/slog"
+	"math/rand"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"ai-rpg-mvp/combat"
+	"ai-rpg-mvp/config"
+
 	"github.com/google/uuid"
 )
 
@@ -13,106 +19,518 @@ import (
 type ContextManager struct {
 	storage         ContextStorage
 	cache          *sync.Map // session_id -> *PlayerContext
+	locks          sync.Map  // session_id -> *sync.Mutex
 	eventQueue     chan ContextEvent
+	queuePolicy    string        // what enqueueAction does when the queue is full
+	queueTimeout   time.Duration // how long the block policy waits
+	droppedEvents  atomic.Int64  // actions lost to a full queue
 	shutdownCh     chan struct{}
 	wg             sync.WaitGroup
+	worldMap       atomic.Pointer[WorldMap] // optional, validates movement when set
+	embedder       Embedder  // optional, enables recall of relevant past actions
+	worlds         sync.Map  // world_id -> *World
+	parties        sync.Map  // party_id -> *Party
+	subscribers    subscriberSet
+	versions       sync.Map  // session_id -> *versionStamps
+	dirty          sync.Map  // session_id -> struct{}, while the cached context has changes not yet saved
+	savesSkipped   atomic.Int64 // periodic saves skipped because the context hadn't changed
+	idempotency    *idempotencyKeys // keys of recently claimed actions, for RecordActionIdempotent and ClaimIdempotencyKey
+	logger         atomic.Pointer[slog.Logger] // slog.Default() until SetLogger
+
+	// Game Master
+	gmPersonality      GMPersonality        // used by sessions without their own
+	narrations         sync.Map             // session_id -> narration, the prompt behind its latest GM response
+	dialogueGenerator  DialogueGenerator    // optional, voices NPCs in context
+	factExtractor      FactExtractor        // optional, learns NPC facts from exchanges
+	commandInterpreter CommandInterpreter   // optional, reads free-text commands
+	interpretations    *interpretationCache // session, grounding and free text -> interpretation, for InterpretCommand
+	extractNPCFacts    bool                 // extract facts after every NPC exchange
+
+	// History summaries
+	summarizer     Summarizer     // optional, folds old actions into HistorySummary
+	summarizing    sync.Map       // session_id -> struct{}, while a summary is being written
+	aiTasks        sync.WaitGroup // background AI calls in flight
+
+	// Character classes and combat
+	classes        map[string]CharacterTemplate  // class name -> starting template
+	classActions   map[string][]string           // action type -> classes allowed to perform it
+	npcCombatStats map[string]combat.CombatStats // NPC ID -> stats, DefaultNPCCombatStats otherwise
+	encounters     map[string][]EncounterEntry   // location ID -> encounter table
+	dialogueTrees  map[string]DialogueTree       // NPC ID -> scripted dialogue
+	shops          map[string]*Shop              // NPC ID -> what the NPC sells
+	abilities      map[string]Ability            // ability ID -> definition
+	validators     []ActionValidator             // consulted by ValidateAction
+	achievements   []AchievementDef              // checked after every recorded action
+	consequences   map[string]consequenceHandler // consequence name -> what it does
+	registryMutex  sync.RWMutex                  // guards the registries above
+
+	unknownConsequences sync.Map // consequence names already logged as unknown, to log each once
+
+	dice           atomic.Pointer[combat.Roller] // rolls checks, combat, encounters and rest interruptions
+	weatherDice    atomic.Pointer[combat.Roller] // rolls weather changes
+	nowFunc        atomic.Pointer[func() time.Time] // the manager's clock, time.Now until SetNowFunc
+	idGenerator    atomic.Pointer[func() string] // new session IDs, random UUIDs unless replaced
 
 	// Configuration
-	maxActions      int           // Keep last N actions
-	cacheTimeout    time.Duration // How long to keep in memory
-	persistInterval time.Duration // How often to save to storage
+	maxActions       int           // Keep last N actions
+	maxDialogue      int           // Keep last N dialogue turns
+	maxPromptTokens  int           // GM prompt budget; 0 disables truncation
+	summaryThreshold int           // Summarize once a session holds more actions than this
+	summaryBatch     int           // Oldest actions folded into the summary at a time
+	cacheTimeout     time.Duration // How long to keep in memory
+	persistInterval  time.Duration // How often to save to storage
+	cleanupInterval  time.Duration // How often idle contexts are evicted
+	maxContextAge    time.Duration // Stored contexts idle this long are deleted; 0 keeps them
+	statusTick       time.Duration // How often status effects tick
+	factMemory       time.Duration // How long NPCs remember facts in prompts
+	dispositionDecay float64       // Disposition points per day NPCs drift back toward neutral
+	gameTimeScale    float64       // Game minutes per real minute for new sessions
+
+	// Death
+	respawnLocation        string // where Respawn puts the player
+	deathGoldPenalty       int    // percent of gold lost on respawn
+	deathReputationPenalty int    // reputation lost on respawn
+
+	// Resting
+	restHealPerHour     int // health regained per in-game hour of rest
+	restEncounterChance int // percent chance per hour that a rest is interrupted
+
+	// Session expiry
+	sessionTTL    time.Duration // idle sessions expire after this long; 0 never
+	expiryWarning time.Duration // how long before expiry to warn subscribers
+	expiryWarned  sync.Map      // session_id -> the ExpiresAt a warning was sent for
+
+	// Reputation gates
+	shunnedReputation int // at or below, NPCs refuse the player
+	honoredReputation int // at or above, NPCs welcome the player
 }
 
-// NewContextManager creates a new context manager instance
+// Defaults used by NewContextManager and for unset ContextConfig values
+const (
+	DefaultMaxActions        = 50
+	DefaultSummaryThreshold  = 40
+	DefaultSummaryBatch      = 20
+	DefaultCacheTimeout      = 30 * time.Minute
+	DefaultPersistInterval   = 5 * time.Minute
+	DefaultCleanupInterval   = 6 * time.Hour
+	DefaultEventQueueSize    = 1000
+	DefaultEventQueueTimeout = 5 * time.Second
+)
+
+// NewContextManager creates a new context manager instance with the default
+// configuration
 func NewContextManager(storage ContextStorage) *ContextManager {
+	return NewContextManagerWithConfig(storage, config.ContextConfig{
+		MaxActions:          DefaultMaxActions,
+		MaxDialogue:         DefaultMaxDialogue,
+		MaxPromptTokens:     DefaultMaxPromptTokens,
+		SummaryThreshold:    DefaultSummaryThreshold,
+		SummaryBatch:        DefaultSummaryBatch,
+		CacheTimeout:        DefaultCacheTimeout,
+		PersistInterval:     DefaultPersistInterval,
+		EventQueueSize:      DefaultEventQueueSize,
+		EventQueuePolicy:    EventQueueBlock,
+		EventQueueTimeout:   DefaultEventQueueTimeout,
+		CleanupInterval:     DefaultCleanupInterval,
+		StatusTick:          DefaultStatusTick,
+		NPCFactWindow:       DefaultFactMemoryWindow,
+		NPCDispositionDecay: DefaultDispositionDecayPerDay,
+		IdempotencyKeyTTL:   DefaultIdempotencyKeyTTL,
+		IdempotencyKeys:     DefaultIdempotencyKeys,
+
+		DeathGoldPenalty:       DefaultDeathGoldPenalty,
+		DeathReputationPenalty: DefaultDeathReputationPenalty,
+
+		RestHealPerHour:     DefaultRestHealPerHour,
+		RestEncounterChance: DefaultRestEncounterChance,
+
+		SessionExpiryWarning: DefaultSessionExpiryWarning,
+
+		ShunnedReputation: DefaultShunnedReputation,
+		HonoredReputation: DefaultHonoredReputation,
+	})
+}
+
+// NewContextManagerWithConfig creates a context manager using the history
+// limits, cache timings and NPC memory policy from cfg. Sizes and intervals
+// that aren't positive fall back to their defaults.
+func NewContextManagerWithConfig(storage ContextStorage, cfg config.ContextConfig) *ContextManager {
 	cm := &ContextManager{
 		storage:         storage,
 		cache:          &sync.Map{},
-		eventQueue:     make(chan ContextEvent, 1000),
+		eventQueue:     make(chan ContextEvent, positiveOr(cfg.EventQueueSize, DefaultEventQueueSize)),
+		queuePolicy:    cfg.EventQueuePolicy,
+		queueTimeout:   positiveOr(cfg.EventQueueTimeout, DefaultEventQueueTimeout),
 		shutdownCh:     make(chan struct{}),
-		maxActions:     50,
-		cacheTimeout:   30 * time.Minute,
-		persistInterval: 5 * time.Minute,
+		classes:        defaultClasses(),
+		classActions:   make(map[string][]string),
+		npcCombatStats: make(map[string]combat.CombatStats),
+		encounters:     make(map[string][]EncounterEntry),
+		dialogueTrees:  make(map[string]DialogueTree),
+		shops:          make(map[string]*Shop),
+		abilities:      defaultAbilities(),
+		gmPersonality:  DefaultGMPersonality(),
+		interpretations: newInterpretationCache(DefaultInterpretationCacheSize),
+		idempotency:    newIdempotencyKeys(positiveOr(cfg.IdempotencyKeyTTL, DefaultIdempotencyKeyTTL), positiveOr(cfg.IdempotencyKeys, DefaultIdempotencyKeys)),
+		maxActions:       positiveOr(cfg.MaxActions, DefaultMaxActions),
+		maxDialogue:      positiveOr(cfg.MaxDialogue, DefaultMaxDialogue),
+		maxPromptTokens:  cfg.MaxPromptTokens,
+		summaryThreshold: positiveOr(cfg.SummaryThreshold, DefaultSummaryThreshold),
+		summaryBatch:     positiveOr(cfg.SummaryBatch, DefaultSummaryBatch),
+		extractNPCFacts:  cfg.ExtractNPCFacts,
+		cacheTimeout:     positiveOr(cfg.CacheTimeout, DefaultCacheTimeout),
+		persistInterval:  positiveOr(cfg.PersistInterval, DefaultPersistInterval),
+		cleanupInterval:  positiveOr(cfg.CleanupInterval, DefaultCleanupInterval),
+		maxContextAge:    cfg.MaxContextAge,
+		statusTick:       positiveOr(cfg.StatusTick, DefaultStatusTick),
+		factMemory:       cfg.NPCFactWindow,
+		dispositionDecay: cfg.NPCDispositionDecay,
+		gameTimeScale:    DefaultGameTimeScale,
+
+		respawnLocation:        cfg.RespawnLocation,
+		deathGoldPenalty:       cfg.DeathGoldPenalty,
+		deathReputationPenalty: cfg.DeathReputationPenalty,
+
+		restHealPerHour:     positiveOr(cfg.RestHealPerHour, DefaultRestHealPerHour),
+		restEncounterChance: cfg.RestEncounterChance,
+
+		sessionTTL:    cfg.SessionIdleTTL,
+		expiryWarning: cfg.SessionExpiryWarning,
+	}
+
+	cm.consequences = cm.defaultConsequences()
+	cm.SetNowFunc(time.Now)
+	cm.SetIDGenerator(newSessionID)
+	cm.SetDiceSource(rand.NewSource(time.Now().UnixNano()))
+	cm.SetWeatherSource(rand.NewSource(time.Now().UnixNano()))
+
+	if err := cm.SetReputationGates(cfg.ShunnedReputation, cfg.HonoredReputation); err != nil {
+		cm.shunnedReputation, cm.honoredReputation = DefaultShunnedReputation, DefaultHonoredReputation
 	}
 
+	if cm.respawnLocation == "" {
+		cm.respawnLocation = defaultStartingLocation
+	}
+	if cm.queuePolicy == "" {
+		cm.queuePolicy = EventQueueBlock
+	}
+	cm.validators = cm.defaultActionValidators()
+	cm.achievements = defaultAchievements()
+
 	// Start background processors
-	cm.wg.Add(2)
+	cm.wg.Add(4)
 	go cm.processEvents()
 	go cm.persistentSaver()
+	go cm.statusTicker()
+	go cm.cleanupTicker()
+	if cm.sessionTTL > 0 {
+		cm.wg.Add(1)
+		go cm.expiryTicker()
+	}
 
 	return cm
 }
 
+// SetLogger sets where the manager and its background workers log
+func (cm *ContextManager) SetLogger(logger *slog.Logger) {
+	cm.logger.Store(logger)
+}
+
+// log returns the manager's logger
+func (cm *ContextManager) log() *slog.Logger {
+	if logger := cm.logger.Load(); logger != nil {
+		return logger
+	}
+	return slog.Default()
+}
+
+// positiveOr returns value, or fallback when value isn't positive
+func positiveOr[T int | time.Duration](value, fallback T) T {
+	if value > 0 {
+		return value
+	}
+	return fallback
+}
+
 // Shutdown gracefully shuts down the context manager
 func (cm *ContextManager) Shutdown() {
 	close(cm.shutdownCh)
 	cm.wg.Wait()
+	cm.aiTasks.Wait()
 	
 	// Save all cached contexts before shutdown
 	cm.cache.Range(func(key, value interface{}) bool {
-		ctx := value.(*PlayerContext)
-		if err := cm.storage.SaveContext(ctx); err != nil {
-			log.Printf("Error saving context during shutdown: %v", err)
+		if err := cm.saveCachedContext(key.(string)); err != nil {
+			cm.log().Error("Failed to save context during shutdown", "session_id", key, "error", err)
 		}
 		return true
 	})
 }
 
-// GetContext retrieves context for a session
+// GetContext retrieves a copy of the context for a session. The returned
+// context is detached from the cache; use the Update* methods to change state.
 func (cm *ContextManager) GetContext(sessionID string) (*PlayerContext, error) {
+	lock := cm.sessionLock(sessionID)
+	lock.Lock()
+	defer lock.Unlock()
+
+	ctx, err := cm.loadContext(sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	return ctx.Clone(), nil
+}
+
+// GetExistingContext is GetContext for sessions that must already exist: an
+// unknown session ID fails with ErrSessionNotFound rather than starting a new
+// session, so a mistyped ID isn't mistaken for a fresh player
+func (cm *ContextManager) GetExistingContext(sessionID string) (*PlayerContext, error) {
+	lock := cm.sessionLock(sessionID)
+	lock.Lock()
+	defer lock.Unlock()
+
+	ctx, err := cm.cachedContext(sessionID, false)
+	if err != nil {
+		return nil, err
+	}
+
+	return ctx.Clone(), nil
+}
+
+// sessionLock returns the mutex guarding a session's cached context
+func (cm *ContextManager) sessionLock(sessionID string) *sync.Mutex {
+	lock, _ := cm.locks.LoadOrStore(sessionID, &sync.Mutex{})
+	return lock.(*sync.Mutex)
+}
+
+// loadContext returns the live cached context, loading or creating it on a
+// cache miss. Callers must hold the session lock.
+func (cm *ContextManager) loadContext(sessionID string) (*PlayerContext, error) {
+	return cm.cachedContext(sessionID, true)
+}
+
+// cachedContext returns the live cached context, loading it from storage on a
+// cache miss. A session storage can't load is created when create is set;
+// otherwise the load fails, with ErrSessionNotFound if the session was never
+// stored. Callers must hold the session lock.
+func (cm *ContextManager) cachedContext(sessionID string, create bool) (*PlayerContext, error) {
 	// Check cache first
+	var ctx *PlayerContext
 	if cached, ok := cm.cache.Load(sessionID); ok {
-		return cached.(*PlayerContext), nil
+		ctx = cached.(*PlayerContext)
+	} else {
+		// Load from storage
+		var err error
+		ctx, err = cm.storage.LoadContext(sessionID)
+		switch {
+		case err == nil:
+		case create:
+			// Create new context if not found; the periodic saver stores it
+			ctx = cm.createNewContext(sessionID)
+			cm.dirty.Store(sessionID, struct{}{})
+		case errors.Is(err, ErrSessionNotFound):
+			return nil, err
+		default:
+			return nil, fmt.Errorf("failed to load session %s: %w", sessionID, err)
+		}
+
+		// Cache for future use
+		cm.cache.Store(sessionID, ctx)
+		cm.rejoinParty(ctx)
+	}
+
+	// Pick up what other players changed in a shared world
+	if world := cm.worldFor(ctx); world != nil {
+		world.sync(ctx)
 	}
+	return ctx, nil
+}
+
+// mutateContext applies fn to the live cached context under the session lock.
+// When fn succeeds, LastUpdate is refreshed, location or reputation changes
+// are published and a player left at zero health is marked dead. fn should
+// validate before making changes, so a returned error leaves the context
+// untouched.
+func (cm *ContextManager) mutateContext(sessionID string, fn func(ctx *PlayerContext) error) error {
+	return cm.mutateContextWith(sessionID, false, fn)
+}
 
-	// Load from storage
-	ctx, err := cm.storage.LoadContext(sessionID)
+// mutateContextWith is mutateContext, additionally saving the context to
+// storage before releasing the lock when writeThrough is set or the player
+// dies; everything else waits for the periodic saver
+func (cm *ContextManager) mutateContextWith(sessionID string, writeThrough bool, fn func(ctx *PlayerContext) error) error {
+	lock := cm.sessionLock(sessionID)
+	lock.Lock()
+	defer lock.Unlock()
+
+	ctx, err := cm.loadContext(sessionID)
 	if err != nil {
-		// Create new context if not found
-		ctx = cm.createNewContext(sessionID)
+		return err
 	}
 
-	// Cache for future use
-	cm.cache.Store(sessionID, ctx)
-	return ctx, nil
+	locationBefore, reputationBefore := ctx.Location.Current, ctx.Character.Reputation
+	before, version := captureTrackedState(ctx), ctx.Version
+	if err := fn(ctx); err != nil {
+		return err
+	}
+
+	ctx.LastUpdate = cm.now()
+	cm.dirty.Store(sessionID, struct{}{})
+	cm.stampChanges(ctx, before, version)
+	cm.publishStateChanges(ctx, locationBefore, reputationBefore)
+	if died := cm.checkDeath(ctx); writeThrough || died {
+		cm.writeThrough(ctx)
+	}
+	return nil
+}
+
+// saveCachedContext persists a snapshot of a cached context taken under the
+// session lock, so storage never serializes a context mid-mutation
+func (cm *ContextManager) saveCachedContext(sessionID string) error {
+	_, err := cm.persistCachedContext(sessionID, false)
+	return err
+}
+
+// persistCachedContext is saveCachedContext that, with onlyDirty, skips a
+// context unchanged since it was last saved, reporting whether it saved. The
+// save happens under the session lock, like writeThrough, so saves reach
+// storage in the order the snapshots were taken and an older snapshot can
+// never overwrite a newer write-through. A failed save marks the context
+// dirty again.
+func (cm *ContextManager) persistCachedContext(sessionID string, onlyDirty bool) (bool, error) {
+	lock := cm.sessionLock(sessionID)
+	lock.Lock()
+	defer lock.Unlock()
+
+	cached, ok := cm.cache.Load(sessionID)
+	if !ok {
+		return false, nil
+	}
+	if _, dirty := cm.dirty.LoadAndDelete(sessionID); onlyDirty && !dirty {
+		return false, nil
+	}
+
+	if err := cm.storage.SaveContext(cached.(*PlayerContext).Clone()); err != nil {
+		cm.dirty.Store(sessionID, struct{}{})
+		return false, err
+	}
+	return true, nil
 }
 
-// CreateSession creates a new player session
+// ErrSessionExists is returned when creating a session under an ID that is
+// already in use
+var ErrSessionExists = errors.New("session already exists")
+
+// CreateSession creates a new player session with the default character
 func (cm *ContextManager) CreateSession(playerID, playerName string) (string, error) {
-	sessionID := uuid.New().String()
-	
+	return cm.CreateSessionWithTemplate(playerID, playerName, DefaultCharacterTemplate())
+}
+
+// CreateSessionWithTemplate creates a new player session whose character
+// starts with the template's stats, gear and location
+func (cm *ContextManager) CreateSessionWithTemplate(playerID, playerName string, tmpl CharacterTemplate) (string, error) {
+	return cm.createSession(playerID, playerName, "", "", tmpl)
+}
+
+// CreateSessionWithID creates a new player session with the default
+// character under a session ID chosen by the caller, such as one derived from
+// an auth token. It fails with ErrSessionExists if the ID is taken.
+func (cm *ContextManager) CreateSessionWithID(playerID, playerName, sessionID string) error {
+	if sessionID == "" {
+		return fmt.Errorf("session ID is required")
+	}
+	_, err := cm.createSession(playerID, playerName, "", sessionID, DefaultCharacterTemplate())
+	return err
+}
+
+// SetIDGenerator replaces how new session IDs are made, such as with
+// sequential IDs for tests or sharding. It is safe to call while sessions are
+// being created.
+func (cm *ContextManager) SetIDGenerator(generate func() string) {
+	cm.idGenerator.Store(&generate)
+}
+
+// newSessionID returns a random UUID
+func newSessionID() string {
+	return uuid.New().String()
+}
+
+// createSession creates a session for a character of the given class, under
+// sessionID or a newly generated ID when it is empty
+func (cm *ContextManager) createSession(playerID, playerName, class, sessionID string, tmpl CharacterTemplate) (string, error) {
+	if err := tmpl.Validate(); err != nil {
+		return "", fmt.Errorf("invalid character template: %w", err)
+	}
+
+	startingLocation := tmpl.StartingLocation
+	if startingLocation == "" {
+		startingLocation = defaultStartingLocation
+	}
+
+	if sessionID == "" {
+		sessionID = (*cm.idGenerator.Load())()
+	}
+
+	lock := cm.sessionLock(sessionID)
+	lock.Lock()
+	defer lock.Unlock()
+
+	if _, cached := cm.cache.Load(sessionID); cached {
+		return "", fmt.Errorf("%w: %s", ErrSessionExists, sessionID)
+	}
+	// Only a session that's known to be missing is free to take
+	_, err := cm.storage.LoadContext(sessionID)
+	if err == nil {
+		return "", fmt.Errorf("%w: %s", ErrSessionExists, sessionID)
+	}
+	if !errors.Is(err, ErrSessionNotFound) {
+		return "", fmt.Errorf("failed to check session %s: %w", sessionID, err)
+	}
+
 	ctx := &PlayerContext{
 		PlayerID:   playerID,
 		SessionID:  sessionID,
-		StartTime:  time.Now(),
-		LastUpdate: time.Now(),
+		StartTime:  cm.now(),
+		LastUpdate: cm.now(),
+		ExpiresAt:  cm.expiryFrom(cm.now()),
 		Character: CharacterState{
-			Name: playerName,
+			Name:  playerName,
+			Class: class,
 			Health: HealthStatus{
-				Current: 20,
-				Max:     20,
-			},
-			Reputation: 0,
-			Equipment:  []EquipmentItem{},
-			Inventory:  []InventoryItem{},
-			Attributes: map[string]int{
-				"strength":     10,
-				"dexterity":    10,
-				"intelligence": 10,
-				"charisma":     10,
+				Current: tmpl.MaxHealth,
+				Max:     tmpl.MaxHealth,
 			},
+			Alive:             true,
+			Reputation:        0,
+			Gold:              tmpl.Gold,
+			FactionReputation: make(map[string]int),
+			Equipment:         []EquipmentItem{},
+			Inventory:         []InventoryItem{},
+			StatusEffects:     []StatusEffect{},
+			Attributes:        copyIntMap(tmpl.Attributes),
+			Mana:              ResourcePool{Current: DefaultMaxMana, Max: DefaultMaxMana},
+			Stamina:           ResourcePool{Current: DefaultMaxStamina, Max: DefaultMaxStamina},
 			Metadata: make(map[string]interface{}),
 		},
 		Location: LocationState{
-			Current:         "starting_village",
+			Current:         startingLocation,
 			Previous:        "",
 			VisitCount:      1,
-			FirstVisit:      time.Now(),
+			FirstVisit:      cm.now(),
 			TimeInLocation:  0,
+			EnteredAt:       gameEpoch,
+			Visits:          map[string]int{startingLocation: 1},
+			Weather:         WeatherClear,
+			WeatherSince:    gameEpoch,
 			LocationHistory: []LocationVisit{},
 		},
-		Actions:    []ActionEvent{},
-		NPCStates:  make(map[string]NPCRelationship),
+		Clock:           newGameClock(cm.gameTimeScale, cm.now()),
+		Actions:         []ActionEvent{},
+		DialogueHistory: []DialogueTurn{},
+		NPCStates:       make(map[string]NPCRelationship),
+		Quests:          make(map[string]Quest),
+		Achievements:    []Achievement{},
 		SessionStats: SessionMetrics{
 			TotalActions:     0,
 			CombatActions:    0,
@@ -124,101 +542,265 @@ func (cm *ContextManager) CreateSession(playerID, playerName string) (string, er
 		},
 	}
 
+	if tmpl.Equipment != nil {
+		ctx.Character.Equipment = copyEquipment(tmpl.Equipment)
+	}
+	if ctx.Character.Attributes == nil {
+		ctx.Character.Attributes = make(map[string]int)
+	}
+	for _, item := range tmpl.Inventory {
+		addItemToInventory(ctx, item)
+	}
+
 	// Cache and save
 	cm.cache.Store(sessionID, ctx)
-	if err := cm.storage.SaveContext(ctx); err != nil {
+	if err := cm.storage.SaveContext(ctx.Clone()); err != nil {
 		return "", fmt.Errorf("failed to save new context: %w", err)
 	}
 
 	return sessionID, nil
 }
 
+// ImportContext saves a context from outside the manager, such as a backup,
+// and caches it so it replaces any live copy of the session. Contexts that
+// fail ValidateContext are rejected.
+func (cm *ContextManager) ImportContext(ctx *PlayerContext) error {
+	if ctx == nil || ctx.SessionID == "" {
+		return fmt.Errorf("imported context needs a session ID")
+	}
+	if err := ValidateContext(ctx); err != nil {
+		return err
+	}
+
+	lock := cm.sessionLock(ctx.SessionID)
+	lock.Lock()
+	defer lock.Unlock()
+
+	imported := ctx.Clone()
+	if err := cm.storage.SaveContext(imported.Clone()); err != nil {
+		return fmt.Errorf("failed to save imported context: %w", err)
+	}
+
+	cm.cache.Store(imported.SessionID, imported)
+	cm.dirty.Delete(imported.SessionID)
+	cm.versions.Delete(imported.SessionID)
+	cm.rejoinParty(imported)
+	return nil
+}
+
+// ErrSessionNotFound is returned for sessions that don't exist, such as by
+// GetExistingContext, EndSession and the storage backends' LoadContext
+var ErrSessionNotFound = errors.New("session not found")
+
+// EndSession removes a session from the cache and from storage. Actions still
+// waiting in the event queue are processed first so none of them recreates it.
+func (cm *ContextManager) EndSession(sessionID string) error {
+	if err := cm.waitForQueuedEvents(); err != nil {
+		return err
+	}
+
+	lock := cm.sessionLock(sessionID)
+	lock.Lock()
+	defer func() {
+		lock.Unlock()
+		cm.locks.Delete(sessionID)
+	}()
+
+	cachedCtx, cached := cm.cache.Load(sessionID)
+	stored, loadErr := cm.storage.LoadContext(sessionID)
+	persisted := loadErr == nil
+	if !cached && !persisted {
+		return fmt.Errorf("%w: %s", ErrSessionNotFound, sessionID)
+	}
+
+	ctx := stored
+	if cached {
+		ctx = cachedCtx.(*PlayerContext)
+	}
+	if world := cm.worldFor(ctx); world != nil {
+		world.leave(sessionID)
+	}
+	if ctx.PartyID != "" {
+		cm.leaveParty(ctx.PartyID, sessionID)
+	}
+
+	cm.cache.Delete(sessionID)
+	cm.versions.Delete(sessionID)
+	cm.dirty.Delete(sessionID)
+	cm.narrations.Delete(sessionID)
+	if persisted {
+		if err := cm.storage.DeleteContext(sessionID); err != nil {
+			return fmt.Errorf("failed to delete session %s: %w", sessionID, err)
+		}
+	}
+
+	return nil
+}
+
 // RecordAction records a player action with context
 func (cm *ContextManager) RecordAction(sessionID, command, actionType, target, location, outcome string, consequences []string) error {
+	return cm.RecordActionWithMetadata(sessionID, command, actionType, target, location, outcome, consequences, nil)
+}
+
+// RecordActionWithMetadata records a player action along with metadata that
+// parameterizes its consequences, such as "damage" or "reputation_change"
+func (cm *ContextManager) RecordActionWithMetadata(sessionID, command, actionType, target, location, outcome string, consequences []string, metadata map[string]interface{}) error {
+	if err := cm.CheckClassAction(sessionID, actionType); err != nil {
+		return err
+	}
+
+	metadata = copyMetadata(metadata)
+	if metadata == nil {
+		metadata = make(map[string]interface{})
+	}
+
 	action := ActionEvent{
 		ID:           uuid.New().String(),
-		Timestamp:    time.Now(),
+		Timestamp:    cm.now(),
 		Type:         actionType,
 		Command:      command,
 		Target:       target,
 		Location:     location,
 		Outcome:      outcome,
-		Consequences: consequences,
-		Metadata:     make(map[string]interface{}),
+		Consequences: copyStrings(consequences),
+		Metadata:     metadata,
 	}
 
 	// Queue for processing
-	select {
-	case cm.eventQueue <- ContextEvent{
+	return cm.enqueueAction(ContextEvent{
 		SessionID: sessionID,
 		Event:     action,
-		Timestamp: time.Now(),
-	}:
-		return nil
-	default:
-		return fmt.Errorf("event queue full")
-	}
+		Timestamp: cm.now(),
+	})
 }
 
 // UpdateLocation updates player location
 func (cm *ContextManager) UpdateLocation(sessionID, newLocation string) error {
-	ctx, err := cm.GetContext(sessionID)
-	if err != nil {
-		return err
-	}
+	return cm.mutateContext(sessionID, func(ctx *PlayerContext) error {
+		if err := checkAlive(ctx); err != nil {
+			return err
+		}
 
+		moved := ctx.Location.Current != newLocation
+		cm.applyLocationChange(ctx, newLocation)
+		if moved {
+			cm.rollEncounter(ctx)
+		}
+		return nil
+	})
+}
+
+// applyLocationChange moves the player to a new location
+func (cm *ContextManager) applyLocationChange(ctx *PlayerContext, newLocation string) {
 	// Update location state
 	if ctx.Location.Current != newLocation {
 		// Record exit from previous location
 		if len(ctx.Location.LocationHistory) > 0 && ctx.Location.LocationHistory[len(ctx.Location.LocationHistory)-1].ExitTime.IsZero() {
 			lastVisit := &ctx.Location.LocationHistory[len(ctx.Location.LocationHistory)-1]
-			lastVisit.ExitTime = time.Now()
-			lastVisit.Duration = int(time.Since(lastVisit.EntryTime).Minutes())
+			lastVisit.ExitTime = cm.now()
+			lastVisit.Duration = int(cm.now().Sub(lastVisit.EntryTime).Minutes())
+		}
+
+		// Contexts from before visits were tracked have only seen where they are
+		if ctx.Location.Visits == nil {
+			ctx.Location.Visits = make(map[string]int)
+			if ctx.Location.VisitCount > 0 {
+				ctx.Location.Visits[ctx.Location.Current] = ctx.Location.VisitCount
+			}
 		}
 
-		// Update current location
+		// Update current location, leaving any encounter behind
+		ctx.Encounter = nil
 		ctx.Location.Previous = ctx.Location.Current
 		ctx.Location.Current = newLocation
-		ctx.Location.TimeInLocation = 0
+		ctx.Location.Visits[newLocation]++
+		ctx.Location.VisitCount = ctx.Location.Visits[newLocation]
 
 		// Add to location history
 		ctx.Location.LocationHistory = append(ctx.Location.LocationHistory, LocationVisit{
 			Location:  newLocation,
-			EntryTime: time.Now(),
+			EntryTime: cm.now(),
 		})
 
+		// Travelling takes time; the clock starts for the new location on arrival
+		cm.advanceClock(ctx, travelGameMinutes)
+		ctx.Location.EnteredAt = ctx.Clock.GameTime
+		ctx.Location.TimeInLocation = 0
+
+		if world := cm.worldFor(ctx); world != nil {
+			world.move(ctx.SessionID, ctx.Location.Previous, newLocation)
+		}
+
 		// Increment stats
 		ctx.SessionStats.LocationsVisited++
 		if ctx.Location.FirstVisit.IsZero() {
-			ctx.Location.FirstVisit = time.Now()
+			ctx.Location.FirstVisit = cm.now()
 		}
 	}
+}
 
-	ctx.LastUpdate = time.Now()
-	cm.cache.Store(sessionID, ctx)
+// GetLocationStats returns the player's location state with the time spent
+// at the current location brought up to date
+func (cm *ContextManager) GetLocationStats(sessionID string) (LocationState, error) {
+	ctx, err := cm.GetContext(sessionID)
+	if err != nil {
+		return LocationState{}, err
+	}
 
-	return nil
+	refreshTimeInLocation(ctx, cm.now())
+	return ctx.Location, nil
+}
+
+// refreshTimeInLocation sets TimeInLocation to the game minutes since the
+// player arrived. Contexts saved before arrivals were timed keep their value.
+func refreshTimeInLocation(ctx *PlayerContext, now time.Time) {
+	if ctx.Location.EnteredAt.IsZero() {
+		return
+	}
+	ctx.Location.TimeInLocation = int(ctx.Clock.Now(now).Sub(ctx.Location.EnteredAt).Minutes())
 }
 
 // UpdateNPCRelationship updates relationship with an NPC
 func (cm *ContextManager) UpdateNPCRelationship(sessionID, npcID, npcName string, dispositionChange int, facts []string) error {
-	ctx, err := cm.GetContext(sessionID)
-	if err != nil {
-		return err
-	}
+	return cm.mutateContext(sessionID, func(ctx *PlayerContext) error {
+		cm.applyNPCRelationship(ctx, npcID, npcName, dispositionChange, facts)
+		return nil
+	})
+}
 
+// applyNPCRelationship updates an NPC relationship on a context. In a shared
+// world the change is made to the world's copy so every member sees it.
+func (cm *ContextManager) applyNPCRelationship(ctx *PlayerContext, npcID, npcName string, dispositionChange int, facts []string) {
 	if ctx.NPCStates == nil {
 		ctx.NPCStates = make(map[string]NPCRelationship)
 	}
 
-	npcRel, exists := ctx.NPCStates[npcID]
+	world := cm.worldFor(ctx)
+	if world == nil {
+		npcRel, exists := ctx.NPCStates[npcID]
+		ctx.NPCStates[npcID] = cm.changeNPCRelationship(ctx, npcRel, exists, npcID, npcName, dispositionChange, facts)
+		return
+	}
+
+	world.mutex.Lock()
+	defer world.mutex.Unlock()
+
+	npcRel, exists := world.npcStates[npcID]
+	npcRel = cm.changeNPCRelationship(ctx, npcRel, exists, npcID, npcName, dispositionChange, facts)
+	world.npcStates[npcID] = npcRel
+	ctx.NPCStates[npcID] = npcRel.clone()
+}
+
+// changeNPCRelationship applies an interaction to a relationship, starting a
+// new one if the NPC hasn't been met
+func (cm *ContextManager) changeNPCRelationship(ctx *PlayerContext, npcRel NPCRelationship, exists bool, npcID, npcName string, dispositionChange int, facts []string) NPCRelationship {
 	if !exists {
 		npcRel = NPCRelationship{
 			NPCID:       npcID,
 			Name:        npcName,
 			Disposition: 0,
-			FirstMet:    time.Now(),
-			KnownFacts:  []string{},
+			FirstMet:    cm.now(),
+			KnownFacts:  []NPCFact{},
 			Mood:        "neutral",
 			Location:    ctx.Location.Current,
 			Notes:       []string{},
@@ -226,6 +808,10 @@ func (cm *ContextManager) UpdateNPCRelationship(sessionID, npcID, npcName string
 		ctx.SessionStats.NPCsInteracted++
 	}
 
+	// Let time away soften the NPC's feelings before applying the change
+	now := cm.now()
+	npcRel.Disposition = cm.decayedDisposition(npcRel, now)
+
 	// Update relationship
 	npcRel.Disposition += dispositionChange
 	
@@ -236,69 +822,96 @@ func (cm *ContextManager) UpdateNPCRelationship(sessionID, npcID, npcName string
 		npcRel.Disposition = -100
 	}
 
-	npcRel.LastInteraction = time.Now()
+	npcRel.LastInteraction = now
 	npcRel.InteractionCount++
 	npcRel.Location = ctx.Location.Current
 
-	// Add new facts
+	// Add new facts; hearing a known fact again refreshes the memory
 	for _, fact := range facts {
-		if !contains(npcRel.KnownFacts, fact) {
-			npcRel.KnownFacts = append(npcRel.KnownFacts, fact)
+		if index := findFact(npcRel.KnownFacts, fact); index >= 0 {
+			npcRel.KnownFacts[index].LearnedAt = now
+		} else {
+			npcRel.KnownFacts = append(npcRel.KnownFacts, NPCFact{Fact: fact, LearnedAt: now})
 		}
 	}
 
 	// Update mood based on disposition
-	npcRel.Mood = cm.calculateMood(npcRel.Disposition)
-
-	ctx.NPCStates[npcID] = npcRel
-	ctx.LastUpdate = time.Now()
-	cm.cache.Store(sessionID, ctx)
+	npcRel.Mood = MoodForDisposition(npcRel.Disposition)
 
-	return nil
+	return npcRel
 }
 
-// UpdateCharacterHealth updates player health
+// UpdateCharacterHealth updates player health. A player brought to zero
+// health dies; the dead can't be healed, only respawned.
 func (cm *ContextManager) UpdateCharacterHealth(sessionID string, healthChange int) error {
-	ctx, err := cm.GetContext(sessionID)
-	if err != nil {
-		return err
-	}
+	return cm.mutateContext(sessionID, func(ctx *PlayerContext) error {
+		if err := checkAlive(ctx); err != nil {
+			return err
+		}
 
-	ctx.Character.Health.Current += healthChange
-	
-	// Clamp health
-	if ctx.Character.Health.Current > ctx.Character.Health.Max {
-		ctx.Character.Health.Current = ctx.Character.Health.Max
-	} else if ctx.Character.Health.Current < 0 {
-		ctx.Character.Health.Current = 0
-	}
+		ctx.Character.Health.Current += healthChange
 
-	ctx.LastUpdate = time.Now()
-	cm.cache.Store(sessionID, ctx)
+		// Clamp health
+		if ctx.Character.Health.Current > ctx.Character.Health.Max {
+			ctx.Character.Health.Current = ctx.Character.Health.Max
+		} else if ctx.Character.Health.Current < 0 {
+			ctx.Character.Health.Current = 0
+		}
 
-	return nil
+		return nil
+	})
 }
 
 // UpdateReputation updates player reputation
 func (cm *ContextManager) UpdateReputation(sessionID string, reputationChange int) error {
-	ctx, err := cm.GetContext(sessionID)
-	if err != nil {
-		return err
-	}
+	return cm.mutateContext(sessionID, func(ctx *PlayerContext) error {
+		ctx.Character.Reputation += reputationChange
+
+		// Clamp reputation
+		if ctx.Character.Reputation > 100 {
+			ctx.Character.Reputation = 100
+		} else if ctx.Character.Reputation < -100 {
+			ctx.Character.Reputation = -100
+		}
 
-	ctx.Character.Reputation += reputationChange
-	
-	// Clamp reputation
-	if ctx.Character.Reputation > 100 {
-		ctx.Character.Reputation = 100
-	} else if ctx.Character.Reputation < -100 {
-		ctx.Character.Reputation = -100
+		return nil
+	})
+}
+
+// UpdateFactionReputation updates the player's standing with a faction
+func (cm *ContextManager) UpdateFactionReputation(sessionID, faction string, change int) error {
+	if faction == "" {
+		return fmt.Errorf("faction is required")
 	}
 
-	ctx.LastUpdate = time.Now()
-	cm.cache.Store(sessionID, ctx)
+	return cm.mutateContext(sessionID, func(ctx *PlayerContext) error {
+		if ctx.Character.FactionReputation == nil {
+			ctx.Character.FactionReputation = make(map[string]int)
+		}
 
-	return nil
+		standing := ctx.Character.FactionReputation[faction] + change
+
+		// Clamp faction reputation
+		if standing > 100 {
+			standing = 100
+		} else if standing < -100 {
+			standing = -100
+		}
+
+		ctx.Character.FactionReputation[faction] = standing
+		return nil
+	})
+}
+
+// GetFactionReputation returns the player's standing with a faction.
+// Factions the player has never dealt with report zero.
+func (cm *ContextManager) GetFactionReputation(sessionID, faction string) (int, error) {
+	ctx, err := cm.GetContext(sessionID)
+	if err != nil {
+		return 0, err
+	}
+
+	return ctx.Character.FactionReputation[faction], nil
 }
 
 // GetRecentActions gets recent actions for AI context
@@ -316,21 +929,32 @@ func (cm *ContextManager) GetRecentActions(sessionID string, count int) ([]Actio
 	return actions, nil
 }
 
+// PingStorage checks that the storage backend is reachable
+func (cm *ContextManager) PingStorage() error {
+	return cm.storage.Ping()
+}
+
 // createNewContext creates a new player context
 func (cm *ContextManager) createNewContext(sessionID string) *PlayerContext {
 	return &PlayerContext{
 		SessionID:  sessionID,
-		StartTime:  time.Now(),
-		LastUpdate: time.Now(),
+		StartTime:  cm.now(),
+		LastUpdate: cm.now(),
+		ExpiresAt:  cm.expiryFrom(cm.now()),
 		Character: CharacterState{
 			Health: HealthStatus{
 				Current: 20,
 				Max:     20,
 			},
-			Reputation: 0,
-			Equipment:  []EquipmentItem{},
-			Inventory:  []InventoryItem{},
-			Attributes: make(map[string]int),
+			Alive:             true,
+			Reputation:        0,
+			FactionReputation: make(map[string]int),
+			Equipment:         []EquipmentItem{},
+			Inventory:         []InventoryItem{},
+			Attributes:        make(map[string]int),
+			StatusEffects:     []StatusEffect{},
+			Mana:              ResourcePool{Current: DefaultMaxMana, Max: DefaultMaxMana},
+			Stamina:           ResourcePool{Current: DefaultMaxStamina, Max: DefaultMaxStamina},
 			Metadata:   make(map[string]interface{}),
 		},
 		Location: LocationState{
@@ -338,27 +962,13 @@ func (cm *ContextManager) createNewContext(sessionID string) *PlayerContext {
 			VisitCount:      0,
 			LocationHistory: []LocationVisit{},
 		},
-		Actions:      []ActionEvent{},
-		NPCStates:    make(map[string]NPCRelationship),
-		SessionStats: SessionMetrics{},
-	}
-}
-
-// calculateMood determines NPC mood based on disposition
-func (cm *ContextManager) calculateMood(disposition int) string {
-	switch {
-	case disposition >= 50:
-		return "friendly"
-	case disposition >= 25:
-		return "helpful"
-	case disposition >= 0:
-		return "neutral"
-	case disposition >= -25:
-		return "suspicious"
-	case disposition >= -50:
-		return "unfriendly"
-	default:
-		return "hostile"
+		Actions:         []ActionEvent{},
+		DialogueHistory: []DialogueTurn{},
+		NPCStates:       make(map[string]NPCRelationship),
+		Quests:          make(map[string]Quest),
+		Achievements:    []Achievement{},
+		Clock:           newGameClock(cm.gameTimeScale, cm.now()),
+		SessionStats:    SessionMetrics{},
 	}
 }
 
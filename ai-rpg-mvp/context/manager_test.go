@@ -1,8 +1,23 @@
 package context
 
 import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
+
+	"ai-rpg-mvp/combat"
+	"ai-rpg-mvp/config"
 )
 
 func TestContextManager_CreateSession(t *testing.T) {
@@ -273,44 +288,80 @@ func TestContextManager_GetContextSummary(t *testing.T) {
 	}
 }
 
+var updateGolden = flag.Bool("update", false, "rewrite golden files in testdata")
+
+// testClock is a manually advanced clock for SetNowFunc
+type testClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func newTestClock() *testClock {
+	return &testClock{now: time.Date(2024, time.March, 1, 18, 0, 0, 0, time.UTC)}
+}
+
+func (c *testClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *testClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+// assertGolden compares got with testdata/name, or rewrites the file when
+// the tests run with -update
+func assertGolden(t *testing.T, name, got string) {
+	t.Helper()
+
+	path := filepath.Join("testdata", name)
+	if *updateGolden {
+		if err := os.WriteFile(path, []byte(got), 0644); err != nil {
+			t.Fatalf("Failed to update %s: %v", path, err)
+		}
+	}
+
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read %s: %v", path, err)
+	}
+	if got != string(want) {
+		t.Errorf("Output doesn't match %s (run with -update to accept it):\n%s", path, got)
+	}
+}
+
 func TestContextManager_GenerateAIPrompt(t *testing.T) {
 	storage := NewMemoryStorage()
 	cm := NewContextManager(storage)
 	defer cm.Shutdown()
 
+	clock := newTestClock()
+	cm.SetNowFunc(clock.Now)
+	cm.SetGameTimeScale(0) // only explicit time passes
+
 	sessionID, _ := cm.CreateSession("player123", "TestHero")
 
 	// Add some game context
 	cm.UpdateLocation(sessionID, "dark_forest")
 	cm.UpdateReputation(sessionID, 40)
+	cm.UpdateNPCRelationship(sessionID, "hermit", "Old Hermit", 30, []string{"Knows the forest paths"})
 	cm.RecordAction(sessionID, "/examine tree", "explore", "ancient_tree", "dark_forest", "You find strange markings", []string{"exploration_success"})
-	
-	// Give time for event processing
-	time.Sleep(100 * time.Millisecond)
+	cm.WaitForEvents()
+	clock.Advance(5 * time.Minute)
 
-	// Generate AI prompt
 	prompt, err := cm.GenerateAIPrompt(sessionID)
 	if err != nil {
 		t.Fatalf("Failed to generate AI prompt: %v", err)
 	}
+	assertGolden(t, "generate_ai_prompt.golden", prompt)
 
-	if prompt == "" {
-		t.Fatal("Expected non-empty AI prompt")
-	}
-
-	// Check that prompt contains key information
-	expectedStrings := []string{
-		"dark_forest",
-		"TestHero",
-		"40",
-		"examine tree",
-		"GAME MASTER CONTEXT",
-	}
-
-	for _, expected := range expectedStrings {
-		if !contains([]string{prompt}, expected) {
-			t.Errorf("Expected AI prompt to contain '%s'", expected)
-		}
+	// The same context and clock give the same prompt, byte for byte
+	again, _ := cm.GenerateAIPrompt(sessionID)
+	if again != prompt {
+		t.Error("Expected identical prompts for an unchanged context")
 	}
 }
 
@@ -432,7 +483,7 @@ func TestNPCMoodCalculation(t *testing.T) {
 	}
 
 	for _, tc := range testCases {
-		mood := cm.calculateMood(tc.disposition)
+		mood := MoodForDisposition(tc.disposition)
 		if mood != tc.expectedMood {
 			t.Errorf("For disposition %d, expected mood '%s', got '%s'", 
 				tc.disposition, tc.expectedMood, mood)
@@ -440,6 +491,42 @@ func TestNPCMoodCalculation(t *testing.T) {
 	}
 }
 
+func TestDispositionAndReputationLabels(t *testing.T) {
+	// One row per band; each label is checked at both edges of its band
+	bands := []struct {
+		min, max     int
+		reputation   string
+		mood         string
+		relationship string
+	}{
+		{75, 100, "Heroic", "friendly", "close_friend"},
+		{50, 74, "Well-regarded", "friendly", "friend"},
+		{25, 49, "Respected", "helpful", "ally"},
+		{0, 24, "Neutral", "neutral", "acquaintance"},
+		{-25, -1, "Mistrusted", "suspicious", "stranger"},
+		{-50, -26, "Disliked", "unfriendly", "rival"},
+		{-100, -51, "Notorious", "hostile", "enemy"},
+	}
+
+	if len(bands) != len(standingBands) {
+		t.Fatalf("Expected %d bands, the classifier has %d", len(bands), len(standingBands))
+	}
+
+	for _, band := range bands {
+		for _, value := range []int{band.min, band.max} {
+			if got := ReputationLabel(value); got != band.reputation {
+				t.Errorf("ReputationLabel(%d) = %q, want %q", value, got, band.reputation)
+			}
+			if got := MoodForDisposition(value); got != band.mood {
+				t.Errorf("MoodForDisposition(%d) = %q, want %q", value, got, band.mood)
+			}
+			if got := RelationshipLevel(value); got != band.relationship {
+				t.Errorf("RelationshipLevel(%d) = %q, want %q", value, got, band.relationship)
+			}
+		}
+	}
+}
+
 func TestConcurrentAccess(t *testing.T) {
 	storage := NewMemoryStorage()
 	cm := NewContextManager(storage)
@@ -465,8 +552,8 @@ func TestConcurrentAccess(t *testing.T) {
 		<-done
 	}
 
-	// Give time for event processing
-	time.Sleep(500 * time.Millisecond)
+	// Let the recorded actions land
+	cm.WaitForEvents()
 
 	// Verify final state is consistent
 	ctx, _ := cm.GetContext(sessionID)
@@ -480,6 +567,48 @@ func TestConcurrentAccess(t *testing.T) {
 	}
 }
 
+func TestConcurrentUpdates_NoLostIncrements(t *testing.T) {
+	cm := NewContextManager(NewMemoryStorage())
+	defer cm.Shutdown()
+
+	sessionID, _ := cm.CreateSession("player123", "TestPlayer")
+	gold, _ := cm.GetGold(sessionID)
+	cm.UpdateReputation(sessionID, -100)
+
+	// 200 increments take reputation from -100 to exactly 100 without ever
+	// clamping; gold is unbounded and takes the rest
+	const updates = 4000
+	const reputationUpdates = 200
+
+	var wg sync.WaitGroup
+	start := make(chan struct{})
+	for i := 0; i < updates; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			<-start
+			if i < reputationUpdates {
+				cm.UpdateReputation(sessionID, 1)
+			} else {
+				cm.AddGold(sessionID, 1, false)
+			}
+			if i%100 == 0 {
+				cm.GetContext(sessionID)
+			}
+		}(i)
+	}
+	close(start)
+	wg.Wait()
+
+	ctx, _ := cm.GetContext(sessionID)
+	if ctx.Character.Reputation != 100 {
+		t.Errorf("Expected reputation 100 after %d increments, got %d", reputationUpdates, ctx.Character.Reputation)
+	}
+	if want := gold + updates - reputationUpdates; ctx.Character.Gold != want {
+		t.Errorf("Expected gold %d, got %d", want, ctx.Character.Gold)
+	}
+}
+
 func BenchmarkContextManager_GetContext(b *testing.B) {
 	storage := NewMemoryStorage()
 	cm := NewContextManager(storage)
@@ -533,3 +662,4365 @@ func BenchmarkContextManager_GenerateAIPrompt(b *testing.B) {
 		}
 	}
 }
+
+func TestContextManager_InventoryStacking(t *testing.T) {
+	storage := NewMemoryStorage()
+	cm := NewContextManager(storage)
+	defer cm.Shutdown()
+
+	sessionID, _ := cm.CreateSession("player123", "TestPlayer")
+
+	potion := InventoryItem{ID: "potion", Name: "Healing Potion", Type: "consumable", Quantity: 2, Value: 10}
+	if err := cm.AddInventoryItem(sessionID, potion); err != nil {
+		t.Fatalf("Failed to add item: %v", err)
+	}
+
+	potion.Quantity = 3
+	if err := cm.AddInventoryItem(sessionID, potion); err != nil {
+		t.Fatalf("Failed to add stacked item: %v", err)
+	}
+
+	inventory, err := cm.GetInventory(sessionID)
+	if err != nil {
+		t.Fatalf("Failed to get inventory: %v", err)
+	}
+
+	if len(inventory) != 1 {
+		t.Fatalf("Expected 1 stacked inventory entry, got %d", len(inventory))
+	}
+
+	if inventory[0].Quantity != 5 {
+		t.Errorf("Expected quantity 5, got %d", inventory[0].Quantity)
+	}
+}
+
+func TestContextManager_RemoveInventoryItem(t *testing.T) {
+	storage := NewMemoryStorage()
+	cm := NewContextManager(storage)
+	defer cm.Shutdown()
+
+	sessionID, _ := cm.CreateSession("player123", "TestPlayer")
+	cm.AddInventoryItem(sessionID, InventoryItem{ID: "arrow", Name: "Arrow", Type: "ammo", Quantity: 10})
+
+	// Partial removal keeps the entry
+	if err := cm.RemoveInventoryItem(sessionID, "arrow", 4); err != nil {
+		t.Fatalf("Failed to remove items: %v", err)
+	}
+
+	inventory, _ := cm.GetInventory(sessionID)
+	if len(inventory) != 1 || inventory[0].Quantity != 6 {
+		t.Fatalf("Expected 6 arrows remaining, got %+v", inventory)
+	}
+
+	// Removing more than exists is an error and changes nothing
+	if err := cm.RemoveInventoryItem(sessionID, "arrow", 7); err == nil {
+		t.Error("Expected error when removing more than available")
+	}
+
+	inventory, _ = cm.GetInventory(sessionID)
+	if inventory[0].Quantity != 6 {
+		t.Errorf("Expected quantity unchanged at 6, got %d", inventory[0].Quantity)
+	}
+
+	// Removing the rest deletes the entry
+	if err := cm.RemoveInventoryItem(sessionID, "arrow", 6); err != nil {
+		t.Fatalf("Failed to remove remaining items: %v", err)
+	}
+
+	inventory, _ = cm.GetInventory(sessionID)
+	if len(inventory) != 0 {
+		t.Errorf("Expected empty inventory, got %d entries", len(inventory))
+	}
+
+	if err := cm.RemoveInventoryItem(sessionID, "missing", 1); err == nil {
+		t.Error("Expected error when removing an item not in inventory")
+	}
+}
+
+func TestContextManager_EquipItem(t *testing.T) {
+	storage := NewMemoryStorage()
+	cm := NewContextManager(storage)
+	defer cm.Shutdown()
+
+	sessionID, _ := cm.CreateSession("player123", "TestPlayer")
+
+	sword := EquipmentItem{ID: "sword", Name: "Iron Sword", Type: "weapon", Slot: SlotMainHand}
+	displaced, err := cm.EquipItem(sessionID, sword)
+	if err != nil {
+		t.Fatalf("Failed to equip item: %v", err)
+	}
+	if displaced.ID != "" {
+		t.Errorf("Expected no displaced item, got '%s'", displaced.ID)
+	}
+
+	// Equipping into an occupied slot returns the displaced item
+	axe := EquipmentItem{ID: "axe", Name: "Battle Axe", Type: "weapon", Slot: SlotMainHand}
+	displaced, err = cm.EquipItem(sessionID, axe)
+	if err != nil {
+		t.Fatalf("Failed to equip item into occupied slot: %v", err)
+	}
+	if displaced.ID != "sword" {
+		t.Errorf("Expected displaced item 'sword', got '%s'", displaced.ID)
+	}
+
+	equipped, ok, err := cm.GetEquippedBySlot(sessionID, SlotMainHand)
+	if err != nil || !ok {
+		t.Fatalf("Expected item in mainhand slot, err: %v", err)
+	}
+	if equipped.ID != "axe" {
+		t.Errorf("Expected 'axe' in mainhand, got '%s'", equipped.ID)
+	}
+
+	// The displaced sword went back to the inventory
+	inventory, _ := cm.GetInventory(sessionID)
+	if len(inventory) != 1 || inventory[0].ID != "sword" {
+		t.Errorf("Expected displaced sword in inventory, got %+v", inventory)
+	}
+
+	// Items without a slot are rejected
+	if _, err := cm.EquipItem(sessionID, EquipmentItem{ID: "ring", Name: "Ring"}); err == nil {
+		t.Error("Expected error when equipping item without a slot")
+	}
+}
+
+func TestContextManager_TwoHandedBlocksOffhand(t *testing.T) {
+	storage := NewMemoryStorage()
+	cm := NewContextManager(storage)
+	defer cm.Shutdown()
+
+	sessionID, _ := cm.CreateSession("player123", "TestPlayer")
+
+	greatsword := EquipmentItem{
+		ID:       "greatsword",
+		Name:     "Greatsword",
+		Type:     "weapon",
+		Slot:     SlotMainHand,
+		Metadata: map[string]interface{}{"two_handed": true},
+	}
+	if _, err := cm.EquipItem(sessionID, greatsword); err != nil {
+		t.Fatalf("Failed to equip two-handed weapon: %v", err)
+	}
+
+	shield := EquipmentItem{ID: "shield", Name: "Shield", Type: "armor", Slot: SlotOffHand}
+	if _, err := cm.EquipItem(sessionID, shield); err == nil {
+		t.Error("Expected offhand to be blocked by two-handed weapon")
+	}
+
+	if err := cm.UnequipItem(sessionID, SlotMainHand); err != nil {
+		t.Fatalf("Failed to unequip: %v", err)
+	}
+
+	if _, err := cm.EquipItem(sessionID, shield); err != nil {
+		t.Errorf("Expected offhand to be free after unequipping, got: %v", err)
+	}
+
+	if err := cm.UnequipItem(sessionID, "head"); err == nil {
+		t.Error("Expected error when unequipping an empty slot")
+	}
+}
+
+func TestGetContextReturnsCopy(t *testing.T) {
+	storage := NewMemoryStorage()
+	cm := NewContextManager(storage)
+	defer cm.Shutdown()
+
+	sessionID, _ := cm.CreateSession("player123", "TestPlayer")
+
+	ctx, _ := cm.GetContext(sessionID)
+	ctx.Character.Reputation = 99
+	ctx.Character.Attributes["strength"] = 1
+	ctx.Actions = append(ctx.Actions, ActionEvent{ID: "rogue"})
+
+	fresh, _ := cm.GetContext(sessionID)
+	if fresh.Character.Reputation != 0 {
+		t.Errorf("Expected cached reputation 0, got %d", fresh.Character.Reputation)
+	}
+	if fresh.Character.Attributes["strength"] != 10 {
+		t.Errorf("Expected cached strength 10, got %d", fresh.Character.Attributes["strength"])
+	}
+	if len(fresh.Actions) != 0 {
+		t.Errorf("Expected no cached actions, got %d", len(fresh.Actions))
+	}
+}
+
+// Run with -race to verify readers never share state with the event processor
+func TestConcurrentReadsDuringActions(t *testing.T) {
+	storage := NewMemoryStorage()
+	cm := NewContextManager(storage)
+	defer cm.Shutdown()
+
+	sessionID, _ := cm.CreateSession("player123", "TestPlayer")
+
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(2)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 20; j++ {
+				cm.RecordAction(sessionID, fmt.Sprintf("/action_%d_%d", i, j), "explore", "target", "location", "outcome",
+					[]string{"reputation_increase"})
+			}
+		}(i)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 20; j++ {
+				ctx, err := cm.GetContext(sessionID)
+				if err != nil {
+					t.Errorf("Failed to get context: %v", err)
+					return
+				}
+				_ = len(ctx.Actions)
+				_ = ctx.Character.Reputation
+				if _, err := cm.GenerateAIPrompt(sessionID); err != nil {
+					t.Errorf("Failed to generate prompt: %v", err)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	time.Sleep(200 * time.Millisecond)
+
+	ctx, _ := cm.GetContext(sessionID)
+	if ctx.SessionStats.TotalActions != 100 {
+		t.Errorf("Expected 100 processed actions, got %d", ctx.SessionStats.TotalActions)
+	}
+}
+
+func TestContextManager_FactionReputation(t *testing.T) {
+	storage := NewMemoryStorage()
+	cm := NewContextManager(storage)
+	defer cm.Shutdown()
+
+	sessionID, _ := cm.CreateSession("player123", "TestPlayer")
+
+	// Unknown factions start at zero
+	standing, err := cm.GetFactionReputation(sessionID, "thieves_guild")
+	if err != nil {
+		t.Fatalf("Failed to get faction reputation: %v", err)
+	}
+	if standing != 0 {
+		t.Errorf("Expected 0 for unknown faction, got %d", standing)
+	}
+
+	cm.UpdateFactionReputation(sessionID, "villagers", 80)
+	cm.UpdateFactionReputation(sessionID, "villagers", 50)
+	cm.UpdateFactionReputation(sessionID, "thieves_guild", -150)
+
+	if standing, _ := cm.GetFactionReputation(sessionID, "villagers"); standing != 100 {
+		t.Errorf("Expected villagers clamped at 100, got %d", standing)
+	}
+	if standing, _ := cm.GetFactionReputation(sessionID, "thieves_guild"); standing != -100 {
+		t.Errorf("Expected thieves_guild clamped at -100, got %d", standing)
+	}
+
+	// Global reputation is unaffected
+	ctx, _ := cm.GetContext(sessionID)
+	if ctx.Character.Reputation != 0 {
+		t.Errorf("Expected global reputation 0, got %d", ctx.Character.Reputation)
+	}
+
+	// Faction standings survive a storage round trip
+	if err := cm.FlushContext(sessionID); err != nil {
+		t.Fatalf("Failed to flush context: %v", err)
+	}
+	stored, err := storage.LoadContext(sessionID)
+	if err != nil {
+		t.Fatalf("Failed to load stored context: %v", err)
+	}
+	if stored.Character.FactionReputation["villagers"] != 100 {
+		t.Errorf("Expected stored villagers standing 100, got %d", stored.Character.FactionReputation["villagers"])
+	}
+
+	prompt, _ := cm.GenerateAIPrompt(sessionID)
+	if !strings.Contains(prompt, "villagers 100") {
+		t.Error("Expected AI prompt to surface top faction standing")
+	}
+}
+
+func newTestWorldMap(t *testing.T) *WorldMap {
+	worldMap := NewWorldMap()
+	for id, name := range map[string]string{
+		"starting_village": "Starting Village",
+		"thornwick_forest": "Thornwick Forest",
+		"old_ruins":        "Old Ruins",
+		"river_crossing":   "River Crossing",
+	} {
+		if err := worldMap.AddLocation(id, name); err != nil {
+			t.Fatalf("Failed to add location: %v", err)
+		}
+	}
+	worldMap.ConnectLocations("starting_village", "thornwick_forest", true)
+	worldMap.ConnectLocations("starting_village", "river_crossing", true)
+	worldMap.ConnectLocations("thornwick_forest", "old_ruins", true)
+	return worldMap
+}
+
+func TestContextManager_MoveToAdjacentLocation(t *testing.T) {
+	storage := NewMemoryStorage()
+	cm := NewContextManager(storage)
+	defer cm.Shutdown()
+	cm.SetWorldMap(newTestWorldMap(t))
+
+	sessionID, _ := cm.CreateSession("player123", "TestPlayer")
+
+	if err := cm.MoveTo(sessionID, "thornwick_forest"); err != nil {
+		t.Fatalf("Failed to move to adjacent location: %v", err)
+	}
+
+	ctx, _ := cm.GetContext(sessionID)
+	if ctx.Location.Current != "thornwick_forest" {
+		t.Errorf("Expected current location 'thornwick_forest', got '%s'", ctx.Location.Current)
+	}
+	if ctx.Location.Previous != "starting_village" {
+		t.Errorf("Expected previous location 'starting_village', got '%s'", ctx.Location.Previous)
+	}
+}
+
+func TestContextManager_MoveToRejectsNonAdjacent(t *testing.T) {
+	storage := NewMemoryStorage()
+	cm := NewContextManager(storage)
+	defer cm.Shutdown()
+	cm.SetWorldMap(newTestWorldMap(t))
+
+	sessionID, _ := cm.CreateSession("player123", "TestPlayer")
+
+	if err := cm.MoveTo(sessionID, "old_ruins"); err == nil {
+		t.Error("Expected error moving to a non-adjacent location")
+	}
+	if err := cm.MoveTo(sessionID, "atlantis"); err == nil {
+		t.Error("Expected error moving to an unknown location")
+	}
+
+	ctx, _ := cm.GetContext(sessionID)
+	if ctx.Location.Current != "starting_village" {
+		t.Errorf("Expected player to stay in 'starting_village', got '%s'", ctx.Location.Current)
+	}
+}
+
+func TestContextManager_GetExits(t *testing.T) {
+	storage := NewMemoryStorage()
+	cm := NewContextManager(storage)
+	defer cm.Shutdown()
+	cm.SetWorldMap(newTestWorldMap(t))
+
+	sessionID, _ := cm.CreateSession("player123", "TestPlayer")
+
+	exits, err := cm.GetExits(sessionID)
+	if err != nil {
+		t.Fatalf("Failed to get exits: %v", err)
+	}
+	if len(exits) != 2 || !contains(exits, "thornwick_forest") || !contains(exits, "river_crossing") {
+		t.Errorf("Expected exits [thornwick_forest river_crossing], got %v", exits)
+	}
+
+	prompt, _ := cm.GenerateAIPrompt(sessionID)
+	if !strings.Contains(prompt, "Thornwick Forest (thornwick_forest)") {
+		t.Error("Expected AI prompt to list available exits")
+	}
+
+	// One-way connections only add an exit in one direction
+	cm.GetWorldMap().ConnectLocations("river_crossing", "old_ruins", false)
+	if exits := cm.GetWorldMap().Exits("old_ruins"); contains(exits, "river_crossing") {
+		t.Errorf("Expected one-way connection, got exits %v", exits)
+	}
+}
+
+func newTestQuest() Quest {
+	return Quest{
+		ID:          "missing_merchant",
+		Title:       "The Missing Merchant",
+		Description: "Find the merchant who vanished on the forest road",
+		Objectives: []Objective{
+			{Description: "Ask around the village"},
+			{Description: "Search the forest road"},
+			{Description: "Return to the tavern keeper"},
+		},
+	}
+}
+
+func TestContextManager_StartQuest(t *testing.T) {
+	storage := NewMemoryStorage()
+	cm := NewContextManager(storage)
+	defer cm.Shutdown()
+
+	sessionID, _ := cm.CreateSession("player123", "TestPlayer")
+
+	if err := cm.StartQuest(sessionID, newTestQuest()); err != nil {
+		t.Fatalf("Failed to start quest: %v", err)
+	}
+	if err := cm.StartQuest(sessionID, newTestQuest()); err == nil {
+		t.Error("Expected error starting an already active quest")
+	}
+
+	quests, err := cm.GetActiveQuests(sessionID)
+	if err != nil {
+		t.Fatalf("Failed to get active quests: %v", err)
+	}
+	if len(quests) != 1 || quests[0].Status != QuestActive {
+		t.Fatalf("Expected 1 active quest, got %+v", quests)
+	}
+
+	prompt, _ := cm.GenerateAIPrompt(sessionID)
+	if !strings.Contains(prompt, "The Missing Merchant: Next: Ask around the village") {
+		t.Error("Expected AI prompt to list the active quest and its next objective")
+	}
+}
+
+func TestContextManager_CompleteObjectivesInOrder(t *testing.T) {
+	storage := NewMemoryStorage()
+	cm := NewContextManager(storage)
+	defer cm.Shutdown()
+
+	sessionID, _ := cm.CreateSession("player123", "TestPlayer")
+	cm.StartQuest(sessionID, newTestQuest())
+
+	if err := cm.CompleteObjective(sessionID, "missing_merchant", 1); err == nil {
+		t.Error("Expected error completing an objective out of order")
+	}
+	if err := cm.CompleteQuest(sessionID, "missing_merchant"); err == nil {
+		t.Error("Expected error completing a quest with open objectives")
+	}
+
+	for i := 0; i < 3; i++ {
+		if err := cm.CompleteObjective(sessionID, "missing_merchant", i); err != nil {
+			t.Fatalf("Failed to complete objective %d: %v", i, err)
+		}
+	}
+
+	if err := cm.CompleteQuest(sessionID, "missing_merchant"); err != nil {
+		t.Fatalf("Failed to complete quest: %v", err)
+	}
+
+	ctx, _ := cm.GetContext(sessionID)
+	if ctx.Quests["missing_merchant"].Status != QuestCompleted {
+		t.Errorf("Expected quest status 'completed', got '%s'", ctx.Quests["missing_merchant"].Status)
+	}
+
+	quests, _ := cm.GetActiveQuests(sessionID)
+	if len(quests) != 0 {
+		t.Errorf("Expected no active quests, got %d", len(quests))
+	}
+}
+
+func TestContextManager_FailQuest(t *testing.T) {
+	storage := NewMemoryStorage()
+	cm := NewContextManager(storage)
+	defer cm.Shutdown()
+
+	sessionID, _ := cm.CreateSession("player123", "TestPlayer")
+	cm.StartQuest(sessionID, newTestQuest())
+
+	if err := cm.FailQuest(sessionID, "missing_merchant"); err != nil {
+		t.Fatalf("Failed to fail quest: %v", err)
+	}
+
+	ctx, _ := cm.GetContext(sessionID)
+	if ctx.Quests["missing_merchant"].Status != QuestFailed {
+		t.Errorf("Expected quest status 'failed', got '%s'", ctx.Quests["missing_merchant"].Status)
+	}
+
+	// Failed quests can't be progressed, but can be restarted
+	if err := cm.CompleteObjective(sessionID, "missing_merchant", 0); err == nil {
+		t.Error("Expected error progressing a failed quest")
+	}
+	if err := cm.StartQuest(sessionID, newTestQuest()); err != nil {
+		t.Errorf("Expected failed quest to be restartable, got %v", err)
+	}
+}
+
+func TestContextManager_TimeOfDayTransitions(t *testing.T) {
+	storage := NewMemoryStorage()
+	cm := NewContextManager(storage)
+	defer cm.Shutdown()
+
+	// Freeze real-time drift so only explicit advances move the clock
+	cm.SetGameTimeScale(0)
+	sessionID, _ := cm.CreateSession("player123", "TestPlayer")
+
+	steps := []struct {
+		minutes  int
+		expected string
+	}{
+		{0, TimeOfDayDay},     // 08:00
+		{599, TimeOfDayDay},   // 17:59
+		{1, TimeOfDayDusk},    // 18:00
+		{179, TimeOfDayDusk},  // 20:59
+		{1, TimeOfDayNight},   // 21:00
+		{479, TimeOfDayNight}, // 04:59
+		{1, TimeOfDayDawn},    // 05:00
+		{180, TimeOfDayDay},   // 08:00
+	}
+
+	for _, step := range steps {
+		if err := cm.AdvanceGameTime(sessionID, step.minutes); err != nil {
+			t.Fatalf("Failed to advance game time: %v", err)
+		}
+		timeOfDay, err := cm.GetTimeOfDay(sessionID)
+		if err != nil {
+			t.Fatalf("Failed to get time of day: %v", err)
+		}
+		if timeOfDay != step.expected {
+			gameTime, _ := cm.GetGameTime(sessionID)
+			t.Errorf("Expected %s at %s, got %s", step.expected, gameTime.Format("15:04"), timeOfDay)
+		}
+	}
+
+	if err := cm.AdvanceGameTime(sessionID, -10); err == nil {
+		t.Error("Expected error moving game time backwards")
+	}
+}
+
+func TestContextManager_TravelAdvancesClock(t *testing.T) {
+	storage := NewMemoryStorage()
+	cm := NewContextManager(storage)
+	defer cm.Shutdown()
+
+	cm.SetGameTimeScale(0)
+	sessionID, _ := cm.CreateSession("player123", "TestPlayer")
+
+	before, _ := cm.GetGameTime(sessionID)
+	cm.UpdateLocation(sessionID, "thornwick_forest")
+	after, _ := cm.GetGameTime(sessionID)
+
+	if after.Sub(before) != 30*time.Minute {
+		t.Errorf("Expected travel to take 30 game minutes, took %v", after.Sub(before))
+	}
+
+	prompt, _ := cm.GenerateAIPrompt(sessionID)
+	if !strings.Contains(prompt, "Time of Day: day (Day 1, 08:30)") {
+		t.Error("Expected AI prompt to include the time of day")
+	}
+}
+
+func TestGameClock_TimeScale(t *testing.T) {
+	clock := GameClock{
+		GameTime:  gameEpoch,
+		SyncedAt:  time.Now().Add(-time.Minute),
+		TimeScale: DefaultGameTimeScale,
+	}
+
+	elapsed := clock.Now(clock.SyncedAt.Add(time.Minute)).Sub(gameEpoch)
+	if elapsed != 10*time.Minute {
+		t.Errorf("Expected 1 real minute to be 10 game minutes, got %v", elapsed)
+	}
+}
+
+func TestContextManager_SnapshotRestore(t *testing.T) {
+	storage := NewMemoryStorage()
+	cm := NewContextManager(storage)
+	defer cm.Shutdown()
+
+	sessionID, _ := cm.CreateSession("player123", "TestPlayer")
+
+	cm.UpdateLocation(sessionID, "thornwick_forest")
+	cm.UpdateReputation(sessionID, 20)
+	cm.AddInventoryItem(sessionID, InventoryItem{ID: "potion", Name: "Healing Potion", Type: "consumable", Quantity: 2})
+
+	snapshotID, err := cm.CreateSnapshot(sessionID, "before the cave")
+	if err != nil {
+		t.Fatalf("Failed to create snapshot: %v", err)
+	}
+
+	cm.UpdateLocation(sessionID, "dark_cave")
+	cm.UpdateReputation(sessionID, -50)
+	cm.UpdateCharacterHealth(sessionID, -15)
+	cm.RemoveInventoryItem(sessionID, "potion", 2)
+
+	if err := cm.RestoreSnapshot(sessionID, snapshotID); err != nil {
+		t.Fatalf("Failed to restore snapshot: %v", err)
+	}
+
+	ctx, _ := cm.GetContext(sessionID)
+	if ctx.Location.Current != "thornwick_forest" {
+		t.Errorf("Expected location 'thornwick_forest', got '%s'", ctx.Location.Current)
+	}
+	if ctx.Character.Reputation != 20 {
+		t.Errorf("Expected reputation 20, got %d", ctx.Character.Reputation)
+	}
+	if ctx.Character.Health.Current != 20 {
+		t.Errorf("Expected health 20, got %d", ctx.Character.Health.Current)
+	}
+	if len(ctx.Character.Inventory) != 1 || ctx.Character.Inventory[0].Quantity != 2 {
+		t.Errorf("Expected 2 potions in inventory, got %+v", ctx.Character.Inventory)
+	}
+
+	// Play after a restore must not change the snapshot itself
+	cm.UpdateReputation(sessionID, 10)
+	cm.RestoreSnapshot(sessionID, snapshotID)
+	ctx, _ = cm.GetContext(sessionID)
+	if ctx.Character.Reputation != 20 {
+		t.Errorf("Expected snapshot reputation 20 after second restore, got %d", ctx.Character.Reputation)
+	}
+
+	// The rollback is persisted, not just cached
+	stored, _ := storage.LoadContext(sessionID)
+	if stored.Location.Current != "thornwick_forest" {
+		t.Errorf("Expected stored location 'thornwick_forest', got '%s'", stored.Location.Current)
+	}
+}
+
+func TestContextManager_ListSnapshots(t *testing.T) {
+	storage := NewMemoryStorage()
+	cm := NewContextManager(storage)
+	defer cm.Shutdown()
+
+	sessionID, _ := cm.CreateSession("player123", "TestPlayer")
+	otherID, _ := cm.CreateSession("player456", "OtherPlayer")
+
+	firstID, _ := cm.CreateSnapshot(sessionID, "start")
+	secondID, _ := cm.CreateSnapshot(sessionID, "after tutorial")
+	cm.CreateSnapshot(otherID, "someone else")
+
+	snapshots, err := cm.ListSnapshots(sessionID)
+	if err != nil {
+		t.Fatalf("Failed to list snapshots: %v", err)
+	}
+	if len(snapshots) != 2 {
+		t.Fatalf("Expected 2 snapshots, got %d", len(snapshots))
+	}
+	if snapshots[0].ID != firstID || snapshots[0].Label != "start" || snapshots[1].ID != secondID {
+		t.Errorf("Unexpected snapshots: %+v", snapshots)
+	}
+	if snapshots[0].CreatedAt.IsZero() {
+		t.Error("Expected snapshot timestamp to be set")
+	}
+
+	if err := cm.RestoreSnapshot(otherID, firstID); err == nil {
+		t.Error("Expected error restoring another session's snapshot")
+	}
+}
+
+func TestContextManager_UndoLastAction(t *testing.T) {
+	storage := NewMemoryStorage()
+	cm := NewContextManager(storage)
+	defer cm.Shutdown()
+
+	sessionID, _ := cm.CreateSession("player123", "TestPlayer")
+
+	if _, err := cm.UndoLastAction(sessionID); err == nil {
+		t.Error("Expected error when undoing with no actions")
+	}
+
+	before, _ := cm.GetContext(sessionID)
+
+	// Undo right away: the action may still be in the event queue
+	err := cm.RecordActionWithMetadata(sessionID, "/attack goblin", "combat", "goblin", "forest", "Won, but took a hit",
+		[]string{"combat_victory", "health_damage"}, map[string]interface{}{"damage": 15})
+	if err != nil {
+		t.Fatalf("Failed to record action: %v", err)
+	}
+
+	undone, err := cm.UndoLastAction(sessionID)
+	if err != nil {
+		t.Fatalf("Failed to undo action: %v", err)
+	}
+	if undone.Command != "/attack goblin" {
+		t.Errorf("Expected undone command '/attack goblin', got '%s'", undone.Command)
+	}
+	if undone.Effects == nil || undone.Effects.ReputationDelta != 2 || undone.Effects.HealthDelta != -15 {
+		t.Errorf("Expected effects of +2 reputation and -15 health, got %+v", undone.Effects)
+	}
+
+	after, _ := cm.GetContext(sessionID)
+	if after.Character.Reputation != before.Character.Reputation {
+		t.Errorf("Expected reputation %d, got %d", before.Character.Reputation, after.Character.Reputation)
+	}
+	if after.Character.Health.Current != before.Character.Health.Current {
+		t.Errorf("Expected health %d, got %d", before.Character.Health.Current, after.Character.Health.Current)
+	}
+	if len(after.Actions) != 0 {
+		t.Errorf("Expected 0 actions, got %d", len(after.Actions))
+	}
+	if after.SessionStats.TotalActions != 0 || after.SessionStats.CombatActions != 0 {
+		t.Errorf("Expected action counters reset, got %+v", after.SessionStats)
+	}
+}
+
+func TestContextManager_RegenerateLastOutcome(t *testing.T) {
+	storage := NewMemoryStorage()
+	cm := NewContextManager(storage)
+	defer cm.Shutdown()
+
+	sessionID, _ := cm.CreateSession("player123", "TestPlayer")
+	generate := func(prompt string) (string, error) { return "Regenerated from " + prompt, nil }
+
+	if _, err := cm.RegenerateLastOutcome(sessionID, generate); err == nil {
+		t.Error("Expected regenerating before any action to fail")
+	}
+
+	cm.RecordAction(sessionID, "/look", "look", "", "village_square", "The square is quiet.", nil)
+	cm.RememberNarration(sessionID, "/look", "the look prompt")
+
+	response, err := cm.RegenerateLastOutcome(sessionID, generate)
+	if err != nil {
+		t.Fatalf("Failed to regenerate: %v", err)
+	}
+	if response != "Regenerated from the look prompt" {
+		t.Errorf("Expected the remembered prompt to be used, got %q", response)
+	}
+	last, _ := cm.LastAction(sessionID)
+	if last.Outcome != response {
+		t.Errorf("Expected the outcome to be replaced, got %q", last.Outcome)
+	}
+
+	// A failed generation leaves the outcome alone
+	if _, err := cm.RegenerateLastOutcome(sessionID, func(string) (string, error) { return "", errors.New("offline") }); err == nil {
+		t.Error("Expected a failed generation to be reported")
+	}
+	if last, _ := cm.LastAction(sessionID); last.Outcome != response {
+		t.Errorf("Expected the outcome to be kept, got %q", last.Outcome)
+	}
+
+	// Actions recorded without a narration can't be regenerated
+	cm.RecordAction(sessionID, "/rest", "rest", "", "village_square", "You rest.", nil)
+	if _, err := cm.RegenerateLastOutcome(sessionID, generate); err == nil {
+		t.Error("Expected regenerating an action without a narration to fail")
+	}
+}
+
+func TestContextManager_UndoLastActionInventory(t *testing.T) {
+	storage := NewMemoryStorage()
+	cm := NewContextManager(storage)
+	defer cm.Shutdown()
+
+	sessionID, _ := cm.CreateSession("player123", "TestPlayer")
+	cm.AddInventoryItem(sessionID, InventoryItem{ID: "rope", Name: "Rope", Type: "tool", Quantity: 1})
+
+	cm.RecordActionWithMetadata(sessionID, "/take sword", "interact", "sword", "forest", "Picked up",
+		[]string{"item_gained"}, map[string]interface{}{
+			"item": map[string]interface{}{"id": "sword", "name": "Sword", "type": "weapon"},
+		})
+	cm.RecordActionWithMetadata(sessionID, "/drop rope", "interact", "rope", "forest", "Dropped",
+		[]string{"item_lost"}, map[string]interface{}{"item_id": "rope"})
+
+	if _, err := cm.UndoLastAction(sessionID); err != nil {
+		t.Fatalf("Failed to undo drop: %v", err)
+	}
+	inventory, _ := cm.GetInventory(sessionID)
+	if len(inventory) != 2 {
+		t.Fatalf("Expected rope restored alongside sword, got %+v", inventory)
+	}
+
+	if _, err := cm.UndoLastAction(sessionID); err != nil {
+		t.Fatalf("Failed to undo pickup: %v", err)
+	}
+	inventory, _ = cm.GetInventory(sessionID)
+	if len(inventory) != 1 || inventory[0].ID != "rope" {
+		t.Errorf("Expected only rope in inventory, got %+v", inventory)
+	}
+}
+
+func TestContextManager_StatusEffectDamageOverTime(t *testing.T) {
+	storage := NewMemoryStorage()
+	cm := NewContextManager(storage)
+	defer cm.Shutdown()
+
+	sessionID, _ := cm.CreateSession("player123", "TestPlayer")
+
+	err := cm.ApplyStatusEffect(sessionID, StatusEffect{
+		Name:          "poisoned",
+		HealthPerTick: -3,
+		ExpiresAt:     time.Now().Add(time.Minute),
+	})
+	if err != nil {
+		t.Fatalf("Failed to apply status effect: %v", err)
+	}
+
+	cm.tickStatusEffects(time.Now())
+	cm.tickStatusEffects(time.Now())
+
+	ctx, _ := cm.GetContext(sessionID)
+	if ctx.Character.Health.Current != 14 {
+		t.Errorf("Expected health 14 after two poison ticks, got %d", ctx.Character.Health.Current)
+	}
+
+	prompt, _ := cm.GenerateAIPrompt(sessionID)
+	if !strings.Contains(prompt, "poisoned (-3 health per tick") {
+		t.Errorf("Expected prompt to mention poison, got:\n%s", prompt)
+	}
+
+	// Once expired the effect is dropped and deals no more damage
+	cm.tickStatusEffects(time.Now().Add(2 * time.Minute))
+
+	ctx, _ = cm.GetContext(sessionID)
+	if ctx.Character.Health.Current != 14 {
+		t.Errorf("Expected health to stay at 14 after expiry, got %d", ctx.Character.Health.Current)
+	}
+	if len(ctx.Character.StatusEffects) != 0 {
+		t.Errorf("Expected expired effect removed, got %+v", ctx.Character.StatusEffects)
+	}
+
+	if err := cm.ApplyStatusEffect(sessionID, StatusEffect{Name: "stale", ExpiresAt: time.Now().Add(-time.Second)}); err == nil {
+		t.Error("Expected error applying an already expired effect")
+	}
+}
+
+func TestContextManager_StatusEffectAttributeBuff(t *testing.T) {
+	storage := NewMemoryStorage()
+	cm := NewContextManager(storage)
+	defer cm.Shutdown()
+
+	sessionID, _ := cm.CreateSession("player123", "TestPlayer")
+
+	cm.ApplyStatusEffect(sessionID, StatusEffect{
+		Name:               "blessed",
+		AttributeModifiers: map[string]int{"strength": 3, "charisma": 2},
+		ExpiresAt:          time.Now().Add(time.Minute),
+	})
+	cm.ApplyStatusEffect(sessionID, StatusEffect{
+		Name:               "giant_strength",
+		AttributeModifiers: map[string]int{"strength": 5},
+		ExpiresAt:          time.Now().Add(time.Hour),
+	})
+
+	attributes, _ := cm.GetEffectiveAttributes(sessionID)
+	if attributes["strength"] != 18 {
+		t.Errorf("Expected stacked strength 18, got %d", attributes["strength"])
+	}
+	if attributes["charisma"] != 12 {
+		t.Errorf("Expected charisma 12, got %d", attributes["charisma"])
+	}
+
+	// Expire the blessing only
+	cm.tickStatusEffects(time.Now().Add(2 * time.Minute))
+
+	ctx, _ := cm.GetContext(sessionID)
+	if ctx.Character.Attributes["strength"] != 10 || ctx.Character.Attributes["charisma"] != 10 {
+		t.Errorf("Expected base attributes untouched, got %v", ctx.Character.Attributes)
+	}
+	if len(ctx.Character.StatusEffects) != 1 || ctx.Character.StatusEffects[0].Name != "giant_strength" {
+		t.Fatalf("Expected only giant_strength to remain, got %+v", ctx.Character.StatusEffects)
+	}
+
+	attributes, _ = cm.GetEffectiveAttributes(sessionID)
+	if attributes["strength"] != 15 {
+		t.Errorf("Expected strength 15 after blessing expired, got %d", attributes["strength"])
+	}
+	if attributes["charisma"] != 10 {
+		t.Errorf("Expected charisma back to 10, got %d", attributes["charisma"])
+	}
+}
+
+func TestContextManager_SpendGold(t *testing.T) {
+	storage := NewMemoryStorage()
+	cm := NewContextManager(storage)
+	defer cm.Shutdown()
+
+	sessionID, _ := cm.CreateSession("player123", "TestPlayer")
+
+	if err := cm.AddGold(sessionID, 50, false); err != nil {
+		t.Fatalf("Failed to add gold: %v", err)
+	}
+	if err := cm.SpendGold(sessionID, 30); err != nil {
+		t.Fatalf("Failed to spend gold: %v", err)
+	}
+
+	if err := cm.SpendGold(sessionID, 25); err == nil {
+		t.Error("Expected error when spending more gold than available")
+	}
+
+	gold, _ := cm.GetGold(sessionID)
+	if gold != 20 {
+		t.Errorf("Expected 20 gold after rejected overspend, got %d", gold)
+	}
+
+	if err := cm.AddGold(sessionID, -5, false); err == nil {
+		t.Error("Expected error adding a negative amount")
+	}
+}
+
+func TestContextManager_GoldConsequences(t *testing.T) {
+	storage := NewMemoryStorage()
+	cm := NewContextManager(storage)
+	defer cm.Shutdown()
+
+	sessionID, _ := cm.CreateSession("player123", "TestPlayer")
+
+	cm.RecordActionWithMetadata(sessionID, "/loot chest", "interact", "chest", "forest", "Found coins",
+		[]string{"gold_gained"}, map[string]interface{}{"gold_amount": 40})
+	cm.RecordActionWithMetadata(sessionID, "/buy bread", "trade", "baker", "village", "Bought bread",
+		[]string{"gold_spent"}, map[string]interface{}{"gold_amount": 15})
+	cm.RecordActionWithMetadata(sessionID, "/buy castle", "trade", "baker", "village", "Too expensive",
+		[]string{"gold_spent"}, map[string]interface{}{"gold_amount": 1000})
+
+	time.Sleep(100 * time.Millisecond)
+
+	gold, _ := cm.GetGold(sessionID)
+	if gold != 25 {
+		t.Errorf("Expected 25 gold, got %d", gold)
+	}
+
+	prompt, _ := cm.GenerateAIPrompt(sessionID)
+	if !strings.Contains(prompt, "Player Gold: 25") {
+		t.Errorf("Expected prompt to include gold, got:\n%s", prompt)
+	}
+}
+
+func TestContextManager_NPCFactDecay(t *testing.T) {
+	storage := NewMemoryStorage()
+	cm := NewContextManager(storage)
+	defer cm.Shutdown()
+	cm.SetNPCMemoryPolicy(12*time.Hour, 1)
+
+	sessionID, _ := cm.CreateSession("player123", "TestPlayer")
+	cm.UpdateNPCRelationship(sessionID, "npc1", "Old Tom", 20, []string{"stole_apples"})
+	cm.UpdateNPCRelationship(sessionID, "npc1", "Old Tom", 0, []string{"saved_the_mill"})
+
+	// Push the interaction and the first fact into the past
+	cm.mutateContext(sessionID, func(ctx *PlayerContext) error {
+		npc := ctx.NPCStates["npc1"]
+		npc.LastInteraction = time.Now().Add(-13 * time.Hour)
+		npc.KnownFacts[0].LearnedAt = time.Now().Add(-13 * time.Hour)
+		ctx.NPCStates["npc1"] = npc
+		return nil
+	})
+
+	prompt, _ := cm.GenerateAIPrompt(sessionID)
+	if strings.Contains(prompt, "stole_apples") {
+		t.Errorf("Expected stale fact omitted from prompt, got:\n%s", prompt)
+	}
+	if !strings.Contains(prompt, "saved_the_mill") {
+		t.Errorf("Expected recent fact in prompt, got:\n%s", prompt)
+	}
+
+	// The stale fact is filtered, not forgotten
+	ctx, _ := cm.GetContext(sessionID)
+	if npc := ctx.NPCStates["npc1"]; len(npc.KnownFacts) != 2 {
+		t.Errorf("Expected 2 stored facts, got %d", len(npc.KnownFacts))
+	}
+}
+
+func TestContextManager_NPCDispositionDecay(t *testing.T) {
+	storage := NewMemoryStorage()
+	cm := NewContextManager(storage)
+	defer cm.Shutdown()
+	cm.SetNPCMemoryPolicy(DefaultFactMemoryWindow, 2)
+
+	sessionID, _ := cm.CreateSession("player123", "TestPlayer")
+	cm.UpdateNPCRelationship(sessionID, "friend", "Mira", 15, nil)
+	cm.UpdateNPCRelationship(sessionID, "foe", "Grell", -5, nil)
+
+	cm.mutateContext(sessionID, func(ctx *PlayerContext) error {
+		for id, npc := range ctx.NPCStates {
+			npc.LastInteraction = time.Now().Add(-5 * 24 * time.Hour)
+			ctx.NPCStates[id] = npc
+		}
+		return nil
+	})
+
+	// Returning after five days, the GM sees the drifted dispositions
+	summary, _ := cm.GetContextSummary(sessionID)
+	dispositions := make(map[string]int)
+	for _, npc := range summary.ActiveNPCs {
+		dispositions[npc.ID] = npc.Disposition
+	}
+	if len(dispositions) != 2 || dispositions["friend"] != 5 || dispositions["foe"] != 0 {
+		t.Errorf("Expected decayed dispositions for NPCs at the location, got %v", dispositions)
+	}
+
+	// Five days at two points a day; the foe only drifts as far as neutral
+	cm.UpdateNPCRelationship(sessionID, "friend", "Mira", 0, nil)
+	cm.UpdateNPCRelationship(sessionID, "foe", "Grell", 0, nil)
+
+	ctx, _ := cm.GetContext(sessionID)
+	if friend := ctx.NPCStates["friend"]; friend.Disposition != 5 {
+		t.Errorf("Expected friend disposition 5, got %d", friend.Disposition)
+	}
+	if foe := ctx.NPCStates["foe"]; foe.Disposition != 0 {
+		t.Errorf("Expected foe disposition 0, got %d", foe.Disposition)
+	}
+}
+
+func TestContextManager_NPCMemoryPolicyConcurrentWithPrompts(t *testing.T) {
+	cm := NewContextManager(NewMemoryStorage())
+	defer cm.Shutdown()
+
+	sessionID, _ := cm.CreateSession("player123", "TestPlayer")
+	cm.UpdateNPCRelationship(sessionID, "friend", "Mira", 15, []string{"saved_the_mill"})
+
+	// Run with -race: changing the policy mustn't race prompts reading it
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 50; i++ {
+			cm.SetNPCMemoryPolicy(time.Duration(i+1)*time.Hour, float64(i%3))
+		}
+	}()
+	for i := 0; i < 50; i++ {
+		if _, err := cm.GenerateAIPrompt(sessionID); err != nil {
+			t.Fatalf("Failed to generate prompt: %v", err)
+		}
+	}
+	wg.Wait()
+}
+
+func TestNPCFact_UnmarshalLegacyString(t *testing.T) {
+	var npc NPCRelationship
+	if err := json.Unmarshal([]byte(`{"known_facts": ["orc_slayer", {"fact": "archer", "learned_at": "2024-01-02T00:00:00Z"}]}`), &npc); err != nil {
+		t.Fatalf("Failed to unmarshal facts: %v", err)
+	}
+
+	if len(npc.KnownFacts) != 2 || npc.KnownFacts[0].Fact != "orc_slayer" || npc.KnownFacts[1].Fact != "archer" {
+		t.Errorf("Expected both fact formats to load, got %+v", npc.KnownFacts)
+	}
+	if !npc.KnownFacts[0].LearnedAt.IsZero() || npc.KnownFacts[1].LearnedAt.IsZero() {
+		t.Errorf("Expected only the structured fact to carry a timestamp, got %+v", npc.KnownFacts)
+	}
+}
+
+func TestCharacterState_UnmarshalLegacyAlive(t *testing.T) {
+	var healthy, fallen, dead CharacterState
+	json.Unmarshal([]byte(`{"health": {"current": 12, "max": 20}}`), &healthy)
+	json.Unmarshal([]byte(`{"health": {"current": 0, "max": 20}}`), &fallen)
+	json.Unmarshal([]byte(`{"health": {"current": 12, "max": 20}, "alive": false, "death_count": 2}`), &dead)
+
+	if !healthy.Alive || fallen.Alive {
+		t.Errorf("Expected legacy characters to be alive only with health left, got %v and %v", healthy.Alive, fallen.Alive)
+	}
+	if dead.Alive || dead.DeathCount != 2 || dead.Health.Current != 12 {
+		t.Errorf("Expected a stored death state to be kept, got %+v", dead)
+	}
+}
+
+func TestContextManager_CreateSessionWithTemplate(t *testing.T) {
+	storage := NewMemoryStorage()
+	cm := NewContextManager(storage)
+	defer cm.Shutdown()
+
+	tmpl := CharacterTemplate{
+		MaxHealth:  35,
+		Attributes: map[string]int{"strength": 14, "wisdom": 8},
+		Gold:       75,
+		Equipment: []EquipmentItem{
+			{ID: "axe", Name: "Hand Axe", Type: "weapon", Slot: "mainhand", Stats: map[string]int{"damage": 6}},
+		},
+		Inventory: []InventoryItem{
+			{ID: "ration", Name: "Ration", Type: "food", Quantity: 3},
+		},
+		StartingLocation: "mountain_pass",
+	}
+
+	sessionID, err := cm.CreateSessionWithTemplate("player123", "TestPlayer", tmpl)
+	if err != nil {
+		t.Fatalf("Failed to create session: %v", err)
+	}
+
+	ctx, _ := cm.GetContext(sessionID)
+	if ctx.Character.Health.Current != 35 || ctx.Character.Health.Max != 35 {
+		t.Errorf("Expected health 35/35, got %d/%d", ctx.Character.Health.Current, ctx.Character.Health.Max)
+	}
+	if ctx.Character.Attributes["strength"] != 14 || ctx.Character.Attributes["wisdom"] != 8 {
+		t.Errorf("Expected template attributes, got %v", ctx.Character.Attributes)
+	}
+	if ctx.Character.Gold != 75 {
+		t.Errorf("Expected 75 gold, got %d", ctx.Character.Gold)
+	}
+	if len(ctx.Character.Equipment) != 1 || ctx.Character.Equipment[0].ID != "axe" {
+		t.Errorf("Expected hand axe equipped, got %+v", ctx.Character.Equipment)
+	}
+	if len(ctx.Character.Inventory) != 1 || ctx.Character.Inventory[0].Quantity != 3 {
+		t.Errorf("Expected 3 rations, got %+v", ctx.Character.Inventory)
+	}
+	if ctx.Location.Current != "mountain_pass" {
+		t.Errorf("Expected location 'mountain_pass', got '%s'", ctx.Location.Current)
+	}
+
+	// The session must not share maps with the template
+	tmpl.Attributes["strength"] = 99
+	ctx, _ = cm.GetContext(sessionID)
+	if ctx.Character.Attributes["strength"] != 14 {
+		t.Errorf("Expected strength 14 after template changed, got %d", ctx.Character.Attributes["strength"])
+	}
+}
+
+func TestCharacterTemplate_Validate(t *testing.T) {
+	storage := NewMemoryStorage()
+	cm := NewContextManager(storage)
+	defer cm.Shutdown()
+
+	invalid := map[string]CharacterTemplate{
+		"zero health":        {MaxHealth: 0},
+		"negative attribute": {MaxHealth: 10, Attributes: map[string]int{"strength": -1}},
+	}
+
+	for name, tmpl := range invalid {
+		if _, err := cm.CreateSessionWithTemplate("player123", "TestPlayer", tmpl); err == nil {
+			t.Errorf("Expected error for template with %s", name)
+		}
+	}
+
+	if err := DefaultCharacterTemplate().Validate(); err != nil {
+		t.Errorf("Expected default template to be valid, got %v", err)
+	}
+}
+
+func TestContextManager_CreateSessionWithClass(t *testing.T) {
+	storage := NewMemoryStorage()
+	cm := NewContextManager(storage)
+	defer cm.Shutdown()
+
+	err := cm.RegisterClass("Paladin", CharacterTemplate{
+		MaxHealth:  28,
+		Attributes: map[string]int{"strength": 13, "charisma": 14},
+	})
+	if err != nil {
+		t.Fatalf("Failed to register class: %v", err)
+	}
+
+	sessionID, err := cm.CreateSessionWithClass("player123", "TestPlayer", "paladin")
+	if err != nil {
+		t.Fatalf("Failed to create session: %v", err)
+	}
+
+	ctx, _ := cm.GetContext(sessionID)
+	if ctx.Character.Class != "paladin" {
+		t.Errorf("Expected class 'paladin', got '%s'", ctx.Character.Class)
+	}
+	if ctx.Character.Attributes["strength"] != 13 || ctx.Character.Attributes["charisma"] != 14 {
+		t.Errorf("Expected paladin attributes, got %v", ctx.Character.Attributes)
+	}
+	if ctx.Character.Health.Max != 28 {
+		t.Errorf("Expected max health 28, got %d", ctx.Character.Health.Max)
+	}
+
+	prompt, _ := cm.GenerateAIPrompt(sessionID)
+	if !strings.Contains(prompt, "- Class: paladin") {
+		t.Errorf("Expected prompt to mention class, got:\n%s", prompt)
+	}
+
+	if _, err := cm.CreateSessionWithClass("player123", "TestPlayer", "bard"); err == nil {
+		t.Error("Expected error for unregistered class")
+	}
+}
+
+func TestContextManager_ClassRestrictedActions(t *testing.T) {
+	storage := NewMemoryStorage()
+	cm := NewContextManager(storage)
+	defer cm.Shutdown()
+
+	cm.RestrictAction("spell", "mage")
+
+	warriorID, _ := cm.CreateSessionWithClass("player1", "Brute", "warrior")
+	mageID, _ := cm.CreateSessionWithClass("player2", "Wizard", "mage")
+	classlessID, _ := cm.CreateSession("player3", "Nobody")
+
+	if err := cm.RecordAction(warriorID, "/cast fireball", "spell", "goblin", "forest", "", nil); err == nil {
+		t.Error("Expected warrior to be rejected from casting spells")
+	}
+	if err := cm.RecordAction(mageID, "/cast fireball", "spell", "goblin", "forest", "", nil); err != nil {
+		t.Errorf("Expected mage to cast spells, got %v", err)
+	}
+	if err := cm.RecordAction(classlessID, "/cast fireball", "spell", "goblin", "forest", "", nil); err != nil {
+		t.Errorf("Expected classless character to be unrestricted, got %v", err)
+	}
+	if err := cm.RecordAction(warriorID, "/attack goblin", "combat", "goblin", "forest", "", nil); err != nil {
+		t.Errorf("Expected unrestricted action to succeed, got %v", err)
+	}
+}
+
+func TestContextManager_ResolveCombatAction(t *testing.T) {
+	storage := NewMemoryStorage()
+	cm := NewContextManager(storage)
+	defer cm.Shutdown()
+	cm.SetDiceSource(rand.NewSource(1))
+
+	sessionID, _ := cm.CreateSession("player123", "TestPlayer")
+
+	// With seed 1 the player misses and the goblin's riposte hits for 6
+	result, err := cm.ResolveCombatAction(sessionID, "goblin")
+	if err != nil {
+		t.Fatalf("Failed to resolve combat: %v", err)
+	}
+	if result.Hit {
+		t.Errorf("Expected the attack to miss, got %+v", result)
+	}
+	if result.Counter == nil || result.Counter.Damage != 6 {
+		t.Fatalf("Expected a counterattack for 6 damage, got %+v", result.Counter)
+	}
+
+	ctx, _ := cm.GetContext(sessionID)
+	if ctx.Character.Health.Current != 14 {
+		t.Errorf("Expected health 14, got %d", ctx.Character.Health.Current)
+	}
+
+	if _, err := cm.ResolveCombatAction(sessionID, ""); err == nil {
+		t.Error("Expected error for missing target")
+	}
+}
+
+func TestContextManager_AttributeCheck(t *testing.T) {
+	cm := NewContextManager(NewMemoryStorage())
+	defer cm.Shutdown()
+	cm.SetDiceSource(rand.NewSource(1))
+
+	tmpl := DefaultCharacterTemplate()
+	tmpl.Attributes["charisma"] = 14
+	sessionID, _ := cm.CreateSessionWithTemplate("player123", "TestPlayer", tmpl)
+
+	// With seed 1 the first d20 is a 2, so charisma's +2 falls short of 12
+	success, roll, total, err := cm.AttributeCheck(sessionID, "Charisma", 12)
+	if err != nil {
+		t.Fatalf("Failed to make check: %v", err)
+	}
+	if success || roll != 2 || total != 4 {
+		t.Errorf("Expected a failed check rolling 2 for a total of 4, got %v, %d, %d", success, roll, total)
+	}
+
+	// The next d20 is an 8, which with +2 just meets 10
+	success, roll, total, _ = cm.AttributeCheck(sessionID, "charisma", 10)
+	if !success || roll != 8 || total != 10 {
+		t.Errorf("Expected a successful check rolling 8 for a total of 10, got %v, %d, %d", success, roll, total)
+	}
+
+	cm.WaitForEvents()
+	actions, _ := cm.GetRecentActions(sessionID, 2)
+	if len(actions) != 2 || actions[1].Type != "attribute_check" || !strings.Contains(actions[1].Outcome, "success") || !strings.Contains(actions[0].Outcome, "failure") {
+		t.Errorf("Expected both checks recorded with their results, got %+v", actions)
+	}
+
+	if _, _, _, err := cm.AttributeCheck(sessionID, "luck", 10); err == nil {
+		t.Error("Expected an error for an unknown attribute")
+	}
+}
+
+func TestContextManager_AllocateAttributePoints(t *testing.T) {
+	cm := NewContextManager(NewMemoryStorage())
+	defer cm.Shutdown()
+
+	sessionID, _ := cm.CreateSession("player123", "TestPlayer")
+	if err := cm.AllocateAttributePoints(sessionID, map[string]int{"strength": 1}); err == nil {
+		t.Error("Expected allocation without unspent points to be rejected")
+	}
+
+	changes, unsubscribe := cm.Subscribe(sessionID)
+	defer unsubscribe()
+	cm.RecordAction(sessionID, "/train", "train", "", "village", "You feel stronger.", []string{"level_up"})
+	cm.WaitForEvents()
+	var leveledUp *ContextChange
+	for i := 0; i < 2 && leveledUp == nil; i++ {
+		if change := nextChange(t, changes); change.Type == ChangeLeveledUp {
+			leveledUp = &change
+		}
+	}
+	if leveledUp == nil || leveledUp.Delta != 3 || leveledUp.Value != 3 {
+		t.Errorf("Expected leveled_up granting 3 points, got %+v", leveledUp)
+	}
+
+	prompt, _ := cm.GenerateAIPrompt(sessionID)
+	if !strings.Contains(prompt, "Unspent Attribute Points: 3") {
+		t.Errorf("Expected the prompt to mention the unspent points, got:\n%s", prompt)
+	}
+
+	// Over-spending or naming an unknown attribute changes nothing
+	if err := cm.AllocateAttributePoints(sessionID, map[string]int{"strength": 2, "dexterity": 2}); err == nil || !strings.Contains(err.Error(), "only 3 unspent") {
+		t.Errorf("Expected an over-spend to be rejected, got %v", err)
+	}
+	if err := cm.AllocateAttributePoints(sessionID, map[string]int{"strength": 1, "luck": 1}); err == nil || !strings.Contains(err.Error(), "unknown attribute: luck") {
+		t.Errorf("Expected an unknown attribute to be rejected, got %v", err)
+	}
+	ctx, _ := cm.GetContext(sessionID)
+	if ctx.Character.UnspentPoints != 3 || ctx.Character.Attributes["strength"] != 10 {
+		t.Fatalf("Expected rejected allocations to change nothing, got %d unspent and strength %d", ctx.Character.UnspentPoints, ctx.Character.Attributes["strength"])
+	}
+
+	if err := cm.AllocateAttributePoints(sessionID, map[string]int{"Strength": 2, "charisma": 1}); err != nil {
+		t.Fatalf("Failed to allocate points: %v", err)
+	}
+	ctx, _ = cm.GetContext(sessionID)
+	if ctx.Character.UnspentPoints != 0 || ctx.Character.Attributes["strength"] != 12 || ctx.Character.Attributes["charisma"] != 11 {
+		t.Errorf("Expected strength 12 and charisma 11 with no points left, got %+v and %d unspent", ctx.Character.Attributes, ctx.Character.UnspentPoints)
+	}
+	if ctx.Character.Health.Max != 24 || ctx.Character.Health.Current != 24 {
+		t.Errorf("Expected strength to raise health to 24/24, got %+v", ctx.Character.Health)
+	}
+	if prompt, _ := cm.GenerateAIPrompt(sessionID); strings.Contains(prompt, "Unspent Attribute Points") {
+		t.Error("Expected no reminder once every point is spent")
+	}
+}
+
+func TestContextManager_RollEncounter(t *testing.T) {
+	cm := NewContextManager(NewMemoryStorage())
+	defer cm.Shutdown()
+	cm.SetDiceSource(rand.NewSource(7))
+
+	sessionID, _ := cm.CreateSession("player123", "TestPlayer")
+
+	// No table, no encounter
+	if encounter, err := cm.RollEncounter(sessionID); err != nil || encounter != nil {
+		t.Errorf("Expected no encounter from an empty table, got %+v, %v", encounter, err)
+	}
+
+	err := cm.SetEncounters("starting_village", []EncounterEntry{
+		{Weight: 3, Template: EncounterTemplate{ID: "wolf", Name: "Grey Wolf", Hostile: true}},
+		{Weight: 1, Template: EncounterTemplate{ID: "peddler", Name: "Wandering Peddler"}},
+	})
+	if err != nil {
+		t.Fatalf("Failed to set encounters: %v", err)
+	}
+
+	counts := make(map[string]int)
+	for i := 0; i < 4000; i++ {
+		encounter, err := cm.RollEncounter(sessionID)
+		if err != nil || encounter == nil {
+			t.Fatalf("Expected an encounter, got %+v, %v", encounter, err)
+		}
+		counts[encounter.ID]++
+	}
+	if share := float64(counts["wolf"]) / 4000; share < 0.72 || share > 0.78 {
+		t.Errorf("Expected wolves about 75%% of the time, got %.3f (%v)", share, counts)
+	}
+
+	// A friendly encounter is introduced as an NPC and the GM is told about it
+	ctx, _ := cm.GetContext(sessionID)
+	if _, met := ctx.NPCStates["peddler"]; !met {
+		t.Error("Expected the peddler to be seeded as an NPC")
+	}
+	prompt, _ := cm.GenerateAIPrompt(sessionID)
+	if !strings.Contains(prompt, "- Encounter: ") {
+		t.Error("Expected the GM prompt to describe the encounter")
+	}
+
+	if err := cm.SetEncounters("starting_village", []EncounterEntry{{Weight: 0}}); err == nil {
+		t.Error("Expected an error for a zero weight")
+	}
+}
+
+func TestContextManager_EncounterOnMove(t *testing.T) {
+	cm := NewContextManager(NewMemoryStorage())
+	defer cm.Shutdown()
+
+	sessionID, _ := cm.CreateSession("player123", "TestPlayer")
+	cm.SetEncounters("thornwick_forest", []EncounterEntry{
+		{Weight: 1, Template: EncounterTemplate{ID: "bandit", Name: "Bandit", Hostile: true, Stats: combat.CombatStats{AttackBonus: 4, Defense: 14, DamageDie: 8}}},
+	})
+
+	cm.UpdateLocation(sessionID, "thornwick_forest")
+	ctx, _ := cm.GetContext(sessionID)
+	if ctx.Encounter == nil || ctx.Encounter.ID != "bandit" || ctx.Encounter.LocationID != "thornwick_forest" {
+		t.Fatalf("Expected to run into the bandit on arrival, got %+v", ctx.Encounter)
+	}
+	if _, met := ctx.NPCStates["bandit"]; met {
+		t.Error("Expected a hostile encounter not to be introduced as an NPC")
+	}
+
+	cm.UpdateLocation(sessionID, "starting_village")
+	ctx, _ = cm.GetContext(sessionID)
+	if ctx.Encounter != nil {
+		t.Errorf("Expected the encounter to be left behind, got %+v", ctx.Encounter)
+	}
+}
+
+func TestMovementValidator(t *testing.T) {
+	storage := NewMemoryStorage()
+	cm := NewContextManager(storage)
+	defer cm.Shutdown()
+
+	worldMap := NewWorldMap()
+	worldMap.AddLocation("starting_village", "Starting Village")
+	worldMap.AddLocation("thornwick_forest", "Thornwick Forest")
+	worldMap.AddLocation("dragon_peak", "Dragon Peak")
+	worldMap.ConnectLocations("starting_village", "thornwick_forest", true)
+	cm.SetWorldMap(worldMap)
+
+	sessionID, _ := cm.CreateSession("player123", "TestPlayer")
+
+	if err := cm.ValidateAction(sessionID, "/move forest"); err != nil {
+		t.Errorf("Expected move to adjacent forest to pass, got %v", err)
+	}
+	if err := cm.ValidateAction(sessionID, "/go dragon_peak"); err == nil {
+		t.Error("Expected move to non-adjacent location to be rejected")
+	}
+	if err := cm.ValidateAction(sessionID, "/fly to the moon"); err != nil {
+		t.Errorf("Expected unrelated command to pass, got %v", err)
+	}
+}
+
+func TestCombatHealthValidator(t *testing.T) {
+	storage := NewMemoryStorage()
+	cm := NewContextManager(storage)
+	defer cm.Shutdown()
+
+	sessionID, _ := cm.CreateSession("player123", "TestPlayer")
+
+	if err := cm.ValidateAction(sessionID, "/attack goblin"); err != nil {
+		t.Errorf("Expected attack at full health to pass, got %v", err)
+	}
+
+	cm.UpdateCharacterHealth(sessionID, -20)
+	if err := cm.ValidateAction(sessionID, "/attack goblin"); err == nil {
+		t.Error("Expected attack at zero health to be rejected")
+	}
+	if err := cm.ValidateAction(sessionID, "/look around"); err != nil {
+		t.Errorf("Expected non-combat action at zero health to pass, got %v", err)
+	}
+}
+
+func TestContextManager_DeathAndRespawn(t *testing.T) {
+	cm := NewContextManagerWithConfig(NewMemoryStorage(), config.ContextConfig{
+		RespawnLocation:        "temple",
+		DeathGoldPenalty:       10,
+		DeathReputationPenalty: 5,
+	})
+	defer cm.Shutdown()
+
+	sessionID, _ := cm.CreateSession("player123", "TestPlayer")
+	cm.AddGold(sessionID, 50, false)
+	cm.UpdateReputation(sessionID, 20)
+	cm.UpdateLocation(sessionID, "dark_cave")
+	changes, unsubscribe := cm.Subscribe(sessionID)
+	defer unsubscribe()
+
+	if err := cm.Respawn(sessionID); err == nil {
+		t.Error("Expected respawning a living player to fail")
+	}
+
+	cm.UpdateCharacterHealth(sessionID, -100)
+	ctx, _ := cm.GetContext(sessionID)
+	if ctx.Character.Alive || ctx.Character.DeathCount != 1 {
+		t.Fatalf("Expected the player to die at zero health, got %+v", ctx.Character)
+	}
+
+	died := false
+	for len(changes) > 0 {
+		if change := <-changes; change.Type == ChangePlayerDied {
+			died = change.Value == 1 && change.To == "dark_cave"
+		}
+	}
+	if !died {
+		t.Error("Expected a player_died change")
+	}
+
+	prompt, _ := cm.GenerateAIPrompt(sessionID)
+	if !strings.Contains(prompt, "The player is DEAD") {
+		t.Error("Expected the GM prompt to note the death")
+	}
+
+	// The dead can look around but nothing else
+	if err := cm.ValidateAction(sessionID, "/talk tavern_keeper"); !errors.Is(err, ErrPlayerDead) {
+		t.Errorf("Expected talking while dead to be rejected, got %v", err)
+	}
+	if err := cm.ValidateAction(sessionID, "/look"); err != nil {
+		t.Errorf("Expected looking while dead to pass, got %v", err)
+	}
+	if _, err := cm.ResolveCombatAction(sessionID, "goblin"); !errors.Is(err, ErrPlayerDead) {
+		t.Errorf("Expected combat while dead to be rejected, got %v", err)
+	}
+	if err := cm.UpdateLocation(sessionID, "forest"); !errors.Is(err, ErrPlayerDead) {
+		t.Errorf("Expected moving while dead to be rejected, got %v", err)
+	}
+	if err := cm.UpdateCharacterHealth(sessionID, 5); !errors.Is(err, ErrPlayerDead) {
+		t.Errorf("Expected healing while dead to be rejected, got %v", err)
+	}
+
+	if err := cm.ValidateAction(sessionID, "/respawn"); err != nil {
+		t.Errorf("Expected /respawn while dead to pass, got %v", err)
+	}
+	if err := cm.Respawn(sessionID); err != nil {
+		t.Fatalf("Failed to respawn: %v", err)
+	}
+
+	ctx, _ = cm.GetContext(sessionID)
+	if !ctx.Character.Alive || ctx.Character.Health.Current != ctx.Character.Health.Max {
+		t.Errorf("Expected to respawn alive at full health, got %+v", ctx.Character)
+	}
+	if ctx.Character.Gold != 45 || ctx.Character.Reputation != 15 {
+		t.Errorf("Expected 45 gold and 15 reputation after the death penalty, got %d and %d", ctx.Character.Gold, ctx.Character.Reputation)
+	}
+	if ctx.Location.Current != "temple" || ctx.Character.DeathCount != 1 {
+		t.Errorf("Expected to respawn in the temple with one death, got %s and %d", ctx.Location.Current, ctx.Character.DeathCount)
+	}
+	if err := cm.ValidateAction(sessionID, "/talk tavern_keeper"); err != nil {
+		t.Errorf("Expected actions after respawning to pass, got %v", err)
+	}
+}
+
+func TestContextManager_Rest(t *testing.T) {
+	cm := NewContextManagerWithConfig(NewMemoryStorage(), config.ContextConfig{RestHealPerHour: 3})
+	defer cm.Shutdown()
+
+	sessionID, _ := cm.CreateSession("player123", "TestPlayer")
+	cm.UpdateCharacterHealth(sessionID, -10)
+	startTime, _ := cm.GetGameTime(sessionID)
+
+	if err := cm.Rest(sessionID, 2); err != nil {
+		t.Fatalf("Failed to rest: %v", err)
+	}
+	ctx, _ := cm.GetContext(sessionID)
+	if ctx.Character.Health.Current != 16 {
+		t.Errorf("Expected 2 hours at 3 per hour to heal 10 to 16, got %d", ctx.Character.Health.Current)
+	}
+
+	// Healing stops at max health
+	if err := cm.Rest(sessionID, 8); err != nil {
+		t.Fatalf("Failed to rest: %v", err)
+	}
+	ctx, _ = cm.GetContext(sessionID)
+	if ctx.Character.Health.Current != ctx.Character.Health.Max {
+		t.Errorf("Expected healing to cap at %d, got %d", ctx.Character.Health.Max, ctx.Character.Health.Current)
+	}
+	if gameTime, _ := cm.GetGameTime(sessionID); gameTime.Sub(startTime) < 10*time.Hour {
+		t.Errorf("Expected 10 hours of rest to pass, got %v", gameTime.Sub(startTime))
+	}
+
+	cm.WaitForEvents()
+	actions, _ := cm.GetRecentActions(sessionID, 1)
+	if len(actions) != 1 || actions[0].Type != "rest" || actions[0].Metadata["health_healed"] != 4 {
+		t.Errorf("Expected the rest to be recorded, got %+v", actions)
+	}
+
+	if err := cm.Rest(sessionID, 0); err == nil {
+		t.Error("Expected a rest of no hours to be rejected")
+	}
+}
+
+func TestContextManager_RestRejections(t *testing.T) {
+	cm := NewContextManagerWithConfig(NewMemoryStorage(), config.ContextConfig{RestEncounterChance: 100})
+	defer cm.Shutdown()
+
+	worldMap := NewWorldMap()
+	worldMap.AddLocation("starting_village", "Starting Village")
+	worldMap.AddLocation("dark_cave", "Dark Cave")
+	worldMap.SetHostile("dark_cave", true)
+	cm.SetWorldMap(worldMap)
+
+	sessionID, _ := cm.CreateSession("player123", "TestPlayer")
+
+	// A certain encounter wakes the player after the first hour
+	if err := cm.Rest(sessionID, 8); err != nil {
+		t.Fatalf("Failed to rest: %v", err)
+	}
+	cm.WaitForEvents()
+	actions, _ := cm.GetRecentActions(sessionID, 1)
+	if len(actions) != 1 || actions[0].Metadata["rest_hours"] != 1 || !contains(actions[0].Consequences, "random_encounter") {
+		t.Errorf("Expected the rest to be interrupted after an hour, got %+v", actions)
+	}
+
+	cm.UpdateLocation(sessionID, "dark_cave")
+	if err := cm.Rest(sessionID, 8); err == nil {
+		t.Error("Expected resting in a hostile location to be rejected")
+	}
+
+	cm.UpdateLocation(sessionID, "starting_village")
+	cm.UpdateCharacterHealth(sessionID, -100)
+	if err := cm.Rest(sessionID, 8); !errors.Is(err, ErrPlayerDead) {
+		t.Errorf("Expected resting at zero health to be rejected, got %v", err)
+	}
+}
+
+func TestGoldValidator(t *testing.T) {
+	storage := NewMemoryStorage()
+	cm := NewContextManager(storage)
+	defer cm.Shutdown()
+
+	sessionID, _ := cm.CreateSession("player123", "TestPlayer")
+	cm.AddGold(sessionID, 20, false)
+
+	if err := cm.ValidateAction(sessionID, "/buy rope 15"); err != nil {
+		t.Errorf("Expected affordable purchase to pass, got %v", err)
+	}
+	if err := cm.ValidateAction(sessionID, "/buy sword 50"); err == nil {
+		t.Error("Expected unaffordable purchase to be rejected")
+	}
+	if err := cm.ValidateAction(sessionID, "/buy bread"); err != nil {
+		t.Errorf("Expected purchase without a price to pass, got %v", err)
+	}
+}
+
+func TestContextManager_AddActionValidator(t *testing.T) {
+	storage := NewMemoryStorage()
+	cm := NewContextManager(storage)
+	defer cm.Shutdown()
+
+	sessionID, _ := cm.CreateSession("player123", "TestPlayer")
+
+	cm.AddActionValidator(ActionValidatorFunc(func(ctx *PlayerContext, command string) error {
+		if strings.HasPrefix(command, "/fly") {
+			return fmt.Errorf("you cannot fly")
+		}
+		return nil
+	}))
+
+	if err := cm.ValidateAction(sessionID, "/fly to the moon"); err == nil {
+		t.Error("Expected custom validator to reject flying")
+	}
+}
+
+func TestContextManager_DialogueHistory(t *testing.T) {
+	storage := NewMemoryStorage()
+	cm := NewContextManager(storage)
+	defer cm.Shutdown()
+	cm.SetMaxDialogue(3)
+
+	sessionID, _ := cm.CreateSession("player123", "TestPlayer")
+
+	for i := 1; i <= 5; i++ {
+		cm.RecordAction(sessionID, fmt.Sprintf("/look %d", i), "examine", "environment", "village",
+			fmt.Sprintf("GM reply %d", i), nil)
+	}
+	// Actions without a GM reply are not part of the dialogue
+	cm.RecordAction(sessionID, "/inventory", "examine", "inventory", "village", "", nil)
+
+	time.Sleep(100 * time.Millisecond)
+
+	history, _ := cm.GetDialogueHistory(sessionID, 0)
+	if len(history) != 3 {
+		t.Fatalf("Expected history trimmed to 3 turns, got %d", len(history))
+	}
+	if history[0].Command != "/look 3" || history[2].Response != "GM reply 5" {
+		t.Errorf("Expected the 3 most recent turns, got %+v", history)
+	}
+
+	prompt, _ := cm.GenerateAIPrompt(sessionID)
+	if !strings.Contains(prompt, "RECENT NARRATIVE:") {
+		t.Error("Expected prompt to have a RECENT NARRATIVE section")
+	}
+	if !strings.Contains(prompt, "Player: /look 5\nGM: GM reply 5") {
+		t.Errorf("Expected latest turn quoted verbatim, got:\n%s", prompt)
+	}
+	if strings.Contains(prompt, "GM reply 2") {
+		t.Error("Expected trimmed turns to be absent from the prompt")
+	}
+}
+
+func TestContextManager_EndSession(t *testing.T) {
+	storage := NewMemoryStorage()
+	cm := NewContextManager(storage)
+	defer cm.Shutdown()
+
+	sessionID, _ := cm.CreateSession("player123", "TestPlayer")
+	cm.RecordAction(sessionID, "/look around", "explore", "surroundings", "starting_village", "", nil)
+
+	if err := cm.EndSession(sessionID); err != nil {
+		t.Fatalf("Failed to end session: %v", err)
+	}
+
+	if cm.IsSessionActive(sessionID) {
+		t.Error("Expected session to be inactive after ending it")
+	}
+	if _, err := storage.LoadContext(sessionID); err == nil {
+		t.Error("Expected session to be removed from storage")
+	}
+
+	// Looking the session up again starts from scratch
+	ctx, err := cm.GetContext(sessionID)
+	if err != nil {
+		t.Fatalf("Failed to get context: %v", err)
+	}
+	if len(ctx.Actions) != 0 || ctx.Character.Name == "TestPlayer" {
+		t.Errorf("Expected a fresh context, got %d actions for %s", len(ctx.Actions), ctx.Character.Name)
+	}
+}
+
+func TestContextManager_EndSessionNotFound(t *testing.T) {
+	storage := NewMemoryStorage()
+	cm := NewContextManager(storage)
+	defer cm.Shutdown()
+
+	err := cm.EndSession("missing")
+	if !errors.Is(err, ErrSessionNotFound) {
+		t.Errorf("Expected ErrSessionNotFound, got %v", err)
+	}
+}
+
+func TestContextManager_GetExistingContext(t *testing.T) {
+	storage := NewMemoryStorage()
+	cm := NewContextManager(storage)
+	defer cm.Shutdown()
+
+	if _, err := cm.GetExistingContext("typo"); !errors.Is(err, ErrSessionNotFound) {
+		t.Errorf("Expected ErrSessionNotFound for an unknown session, got %v", err)
+	}
+	if cm.IsSessionActive("typo") {
+		t.Error("Expected an unknown session not to be created")
+	}
+	if err := cm.ValidateAction("typo", "/look"); !errors.Is(err, ErrSessionNotFound) {
+		t.Errorf("Expected validating an action on an unknown session to fail, got %v", err)
+	}
+
+	// GetContext still starts a session on a miss
+	if _, err := cm.GetContext("typo"); err != nil {
+		t.Fatalf("Expected GetContext to create the session, got %v", err)
+	}
+	if _, err := cm.GetExistingContext("typo"); err != nil {
+		t.Errorf("Expected the created session to exist, got %v", err)
+	}
+
+	// Sessions only in storage are loaded, not reported missing
+	sessionID, _ := cm.CreateSession("player123", "TestPlayer")
+	cm.FlushContext(sessionID)
+	cm.EvictIdle(-time.Hour)
+	if cm.IsSessionActive(sessionID) {
+		t.Fatal("Expected the session to be evicted")
+	}
+	ctx, err := cm.GetExistingContext(sessionID)
+	if err != nil || ctx.Character.Name != "TestPlayer" {
+		t.Errorf("Expected the stored session to be loaded, got %v", err)
+	}
+}
+
+func TestContextManager_SharedWorldNPCs(t *testing.T) {
+	storage := NewMemoryStorage()
+	cm := NewContextManager(storage)
+	defer cm.Shutdown()
+
+	alice, err := cm.JoinWorld("player1", "Alice", "realm")
+	if err != nil {
+		t.Fatalf("Failed to join world: %v", err)
+	}
+	bob, err := cm.JoinWorld("player2", "Bob", "realm")
+	if err != nil {
+		t.Fatalf("Failed to join world: %v", err)
+	}
+	loner, _ := cm.CreateSession("player3", "Loner")
+
+	if err := cm.UpdateNPCRelationship(alice, "tavern_keeper", "Marcus", 30, []string{"paid for a round"}); err != nil {
+		t.Fatalf("Failed to update NPC relationship: %v", err)
+	}
+
+	summary, err := cm.GetContextSummary(bob)
+	if err != nil {
+		t.Fatalf("Failed to get context summary: %v", err)
+	}
+	if len(summary.ActiveNPCs) != 1 || summary.ActiveNPCs[0].ID != "tavern_keeper" {
+		t.Fatalf("Expected Bob to see the tavern keeper Alice met, got %+v", summary.ActiveNPCs)
+	}
+	if summary.ActiveNPCs[0].Disposition != 30 {
+		t.Errorf("Expected shared disposition 30, got %d", summary.ActiveNPCs[0].Disposition)
+	}
+	if summary.WorldState["world_id"] != "realm" {
+		t.Errorf("Expected world_id in world state, got %v", summary.WorldState["world_id"])
+	}
+
+	// Bob's interaction builds on Alice's
+	cm.UpdateNPCRelationship(bob, "tavern_keeper", "Marcus", 10, nil)
+	aliceCtx, _ := cm.GetContext(alice)
+	if got := aliceCtx.NPCStates["tavern_keeper"].Disposition; got != 40 {
+		t.Errorf("Expected Alice to see disposition 40, got %d", got)
+	}
+
+	// Sessions outside the world are unaffected
+	lonerCtx, _ := cm.GetContext(loner)
+	if _, exists := lonerCtx.NPCStates["tavern_keeper"]; exists {
+		t.Error("Expected a session outside the world not to share its NPCs")
+	}
+}
+
+func TestContextManager_SharedWorldLocations(t *testing.T) {
+	storage := NewMemoryStorage()
+	cm := NewContextManager(storage)
+	defer cm.Shutdown()
+
+	alice, _ := cm.JoinWorld("player1", "Alice", "realm")
+	bob, _ := cm.JoinWorld("player2", "Bob", "realm")
+
+	summary, _ := cm.GetContextSummary(alice)
+	if players, _ := summary.WorldState["players_here"].([]string); len(players) != 1 || players[0] != "Bob" {
+		t.Errorf("Expected Alice to see Bob nearby, got %v", summary.WorldState["players_here"])
+	}
+
+	cm.UpdateLocation(bob, "forest")
+	summary, _ = cm.GetContextSummary(alice)
+	if players, _ := summary.WorldState["players_here"].([]string); len(players) != 0 {
+		t.Errorf("Expected Alice to be alone after Bob left, got %v", players)
+	}
+
+	world, ok := cm.GetWorld("realm")
+	if !ok {
+		t.Fatal("Expected world to exist")
+	}
+	forest, ok := world.Location("forest")
+	if !ok || len(forest.Occupants) != 1 || forest.Occupants[0] != bob {
+		t.Errorf("Expected Bob in the forest, got %+v", forest)
+	}
+
+	if err := cm.EndSession(bob); err != nil {
+		t.Fatalf("Failed to end session: %v", err)
+	}
+	if members := world.Members(); len(members) != 1 || members[0] != alice {
+		t.Errorf("Expected only Alice to remain in the world, got %v", members)
+	}
+}
+
+func TestContextManager_SharedWorldConcurrentUpdates(t *testing.T) {
+	storage := NewMemoryStorage()
+	cm := NewContextManager(storage)
+	defer cm.Shutdown()
+
+	sessions := make([]string, 4)
+	for i := range sessions {
+		sessions[i], _ = cm.JoinWorld(fmt.Sprintf("player%d", i), fmt.Sprintf("Player%d", i), "realm")
+	}
+
+	var wg sync.WaitGroup
+	for _, sessionID := range sessions {
+		wg.Add(1)
+		go func(sessionID string) {
+			defer wg.Done()
+			for i := 0; i < 10; i++ {
+				cm.UpdateNPCRelationship(sessionID, "blacksmith", "Thoren", 1, nil)
+				cm.GetContextSummary(sessionID)
+			}
+		}(sessionID)
+	}
+	wg.Wait()
+
+	world, _ := cm.GetWorld("realm")
+	npc := world.NPCStates()["blacksmith"]
+	if npc.Disposition != 40 || npc.InteractionCount != 40 {
+		t.Errorf("Expected 40 interactions and disposition 40, got %d and %d", npc.InteractionCount, npc.Disposition)
+	}
+}
+
+func TestContextManager_JoinWorldRequiresID(t *testing.T) {
+	cm := NewContextManager(NewMemoryStorage())
+	defer cm.Shutdown()
+
+	if _, err := cm.JoinWorld("player1", "Alice", ""); err == nil {
+		t.Error("Expected an error without a world ID")
+	}
+}
+
+// newTestParty creates a world with a party of the named players
+func newTestParty(t *testing.T, cm *ContextManager, names ...string) (string, []string) {
+	t.Helper()
+
+	sessions := make([]string, len(names))
+	for i, name := range names {
+		sessionID, err := cm.JoinWorld("player-"+name, name, "realm")
+		if err != nil {
+			t.Fatalf("Failed to join world: %v", err)
+		}
+		sessions[i] = sessionID
+	}
+
+	partyID, err := cm.CreateParty(sessions[0])
+	if err != nil {
+		t.Fatalf("Failed to create party: %v", err)
+	}
+	for _, sessionID := range sessions[1:] {
+		if err := cm.JoinParty(sessionID, partyID); err != nil {
+			t.Fatalf("Failed to join party: %v", err)
+		}
+	}
+
+	return partyID, sessions
+}
+
+func TestContextManager_PartyGoldSplit(t *testing.T) {
+	storage := NewMemoryStorage()
+	cm := NewContextManager(storage)
+	defer cm.Shutdown()
+
+	_, sessions := newTestParty(t, cm, "Alice", "Bob", "Carol")
+	startingGold := make([]int, len(sessions))
+	for i, sessionID := range sessions {
+		startingGold[i], _ = cm.GetGold(sessionID)
+	}
+
+	if err := cm.AddGold(sessions[0], 100, true); err != nil {
+		t.Fatalf("Failed to add gold: %v", err)
+	}
+
+	// 100 split three ways: the finder keeps the remainder
+	expected := []int{34, 33, 33}
+	for i, sessionID := range sessions {
+		gold, _ := cm.GetGold(sessionID)
+		if gold-startingGold[i] != expected[i] {
+			t.Errorf("Expected member %d to gain %d gold, got %d", i, expected[i], gold-startingGold[i])
+		}
+	}
+
+	// Members elsewhere miss out
+	cm.UpdateLocation(sessions[2], "forest")
+	cm.AddGold(sessions[0], 10, true)
+	gold, _ := cm.GetGold(sessions[2])
+	if gold-startingGold[2] != 33 {
+		t.Errorf("Expected absent member to get nothing more, got %d total", gold-startingGold[2])
+	}
+	gold, _ = cm.GetGold(sessions[1])
+	if gold-startingGold[1] != 38 {
+		t.Errorf("Expected present member to get half of 10, got %d total", gold-startingGold[1])
+	}
+
+	// Without split the finder keeps everything
+	cm.AddGold(sessions[1], 7, false)
+	gold, _ = cm.GetGold(sessions[1])
+	if gold-startingGold[1] != 45 {
+		t.Errorf("Expected unsplit gold to stay with the finder, got %d total", gold-startingGold[1])
+	}
+}
+
+func TestContextManager_PartyQuestProgress(t *testing.T) {
+	storage := NewMemoryStorage()
+	cm := NewContextManager(storage)
+	defer cm.Shutdown()
+
+	_, sessions := newTestParty(t, cm, "Alice", "Bob")
+	quest := Quest{
+		ID:    "goblins",
+		Title: "Goblin Trouble",
+		Objectives: []Objective{
+			{Description: "Find the camp"},
+			{Description: "Defeat the chief"},
+		},
+	}
+	for _, sessionID := range sessions {
+		if err := cm.StartQuest(sessionID, quest); err != nil {
+			t.Fatalf("Failed to start quest: %v", err)
+		}
+	}
+
+	if err := cm.CompleteObjective(sessions[1], "goblins", 0); err != nil {
+		t.Fatalf("Failed to complete objective: %v", err)
+	}
+
+	quests, _ := cm.GetActiveQuests(sessions[0])
+	if len(quests) != 1 || !quests[0].Objectives[0].Done {
+		t.Fatalf("Expected Bob's progress to reach Alice, got %+v", quests)
+	}
+
+	// Alice can carry on from where the party is
+	if err := cm.CompleteObjective(sessions[0], "goblins", 1); err != nil {
+		t.Fatalf("Failed to complete shared objective: %v", err)
+	}
+	if err := cm.CompleteQuest(sessions[1], "goblins"); err != nil {
+		t.Errorf("Expected Bob to be able to complete the quest, got %v", err)
+	}
+}
+
+func TestContextManager_PartyMembership(t *testing.T) {
+	storage := NewMemoryStorage()
+	cm := NewContextManager(storage)
+	defer cm.Shutdown()
+
+	partyID, sessions := newTestParty(t, cm, "Alice", "Bob")
+
+	prompt, err := cm.GenerateAIPrompt(sessions[0])
+	if err != nil {
+		t.Fatalf("Failed to generate prompt: %v", err)
+	}
+	if !strings.Contains(prompt, "Party Members Here: Bob") {
+		t.Error("Expected prompt to mention party members present")
+	}
+
+	solo, _ := cm.CreateSession("player3", "Loner")
+	if err := cm.JoinParty(solo, partyID); err == nil {
+		t.Error("Expected a session outside the world not to join the party")
+	}
+	if _, err := cm.CreateParty(sessions[1]); err == nil {
+		t.Error("Expected a party member not to create another party")
+	}
+
+	cm.LeaveParty(sessions[0])
+	cm.LeaveParty(sessions[1])
+	if _, ok := cm.GetParty(partyID); ok {
+		t.Error("Expected the party to disband once empty")
+	}
+}
+
+// nextChange waits briefly for the next change on a subscription
+func nextChange(t *testing.T, changes <-chan ContextChange) ContextChange {
+	t.Helper()
+
+	select {
+	case change := <-changes:
+		return change
+	case <-time.After(time.Second):
+		t.Fatal("Timed out waiting for a context change")
+		return ContextChange{}
+	}
+}
+
+func TestContextManager_Subscribe(t *testing.T) {
+	storage := NewMemoryStorage()
+	cm := NewContextManager(storage)
+	defer cm.Shutdown()
+
+	sessionID, _ := cm.CreateSession("player123", "TestPlayer")
+	changes, unsubscribe := cm.Subscribe(sessionID)
+	defer unsubscribe()
+
+	err := cm.RecordAction(sessionID, "/help villager", "talk", "villager", "village", "The villager thanks you", []string{"reputation_increase"})
+	if err != nil {
+		t.Fatalf("Failed to record action: %v", err)
+	}
+
+	change := nextChange(t, changes)
+	if change.Type != ChangeActionRecorded || change.Action == nil || change.Action.Command != "/help villager" {
+		t.Fatalf("Expected action_recorded for the command, got %+v", change)
+	}
+
+	change = nextChange(t, changes)
+	if change.Type != ChangeReputationChanged || change.Delta != 5 || change.Value != 5 {
+		t.Errorf("Expected reputation_changed by 5, got %+v", change)
+	}
+
+	cm.UpdateLocation(sessionID, "forest")
+	change = nextChange(t, changes)
+	if change.Type != ChangeLocationChanged || change.To != "forest" || change.SessionID != sessionID {
+		t.Errorf("Expected location_changed to forest, got %+v", change)
+	}
+
+	// Other sessions' changes aren't delivered
+	other, _ := cm.CreateSession("player456", "Other")
+	cm.UpdateLocation(other, "forest")
+	select {
+	case change := <-changes:
+		t.Errorf("Expected no change from another session, got %+v", change)
+	case <-time.After(20 * time.Millisecond):
+	}
+}
+
+func TestContextManager_UnsubscribeTwice(t *testing.T) {
+	storage := NewMemoryStorage()
+	cm := NewContextManager(storage)
+	defer cm.Shutdown()
+
+	sessionID, _ := cm.CreateSession("player123", "TestPlayer")
+	changes, unsubscribe := cm.Subscribe(sessionID)
+	unsubscribe()
+	unsubscribe()
+
+	if _, open := <-changes; open {
+		t.Error("Expected channel to be closed after unsubscribing")
+	}
+
+	// Changes after unsubscribing go nowhere
+	if err := cm.UpdateLocation(sessionID, "forest"); err != nil {
+		t.Errorf("Expected update to succeed without subscribers, got %v", err)
+	}
+}
+
+func TestContextManager_SlowSubscriberDoesNotBlock(t *testing.T) {
+	storage := NewMemoryStorage()
+	cm := NewContextManager(storage)
+	defer cm.Shutdown()
+
+	sessionID, _ := cm.CreateSession("player123", "TestPlayer")
+	changes, unsubscribe := cm.Subscribe(sessionID)
+	defer unsubscribe()
+
+	// Never read while the buffer overflows
+	for i := 0; i < SubscriberBuffer*2; i++ {
+		cm.RecordAction(sessionID, "/look", "look", "", "village", "", nil)
+	}
+
+	done := make(chan error, 1)
+	go func() { done <- cm.waitForQueuedEvents() }()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("Failed waiting for events: %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Event loop stalled on a slow subscriber")
+	}
+
+	if len(changes) != SubscriberBuffer {
+		t.Errorf("Expected the buffer to fill to %d, got %d", SubscriberBuffer, len(changes))
+	}
+	ctx, _ := cm.GetContext(sessionID)
+	if ctx.SessionStats.TotalActions != SubscriberBuffer*2 {
+		t.Errorf("Expected every action recorded, got %d", ctx.SessionStats.TotalActions)
+	}
+}
+
+func TestContextManager_AchievementUnlocksOnce(t *testing.T) {
+	storage := NewMemoryStorage()
+	cm := NewContextManager(storage)
+	defer cm.Shutdown()
+
+	sessionID, _ := cm.CreateSession("player123", "TestPlayer")
+	changes, unsubscribe := cm.Subscribe(sessionID)
+	defer unsubscribe()
+
+	for i := 0; i < 24; i++ {
+		cm.RecordAction(sessionID, "/talk villager", "talk", "villager", "village", "", nil)
+	}
+	cm.WaitForEvents()
+	if achievements, _ := cm.GetAchievements(sessionID); len(achievements) != 0 {
+		t.Fatalf("Expected no achievements below the threshold, got %+v", achievements)
+	}
+
+	// Crossing the threshold unlocks Socialite and pays its reward
+	for i := 0; i < 5; i++ {
+		cm.RecordAction(sessionID, "/talk villager", "talk", "villager", "village", "", nil)
+	}
+	cm.WaitForEvents()
+
+	achievements, _ := cm.GetAchievements(sessionID)
+	if len(achievements) != 1 || achievements[0].ID != "socialite" || achievements[0].UnlockedAt.IsZero() {
+		t.Fatalf("Expected a single timestamped socialite achievement, got %+v", achievements)
+	}
+	ctx, _ := cm.GetContext(sessionID)
+	if ctx.Character.Reputation != 5 {
+		t.Errorf("Expected the unlock reward of 5 reputation, got %d", ctx.Character.Reputation)
+	}
+
+	unlocks := 0
+	for len(changes) > 0 {
+		if change := <-changes; change.Type == ChangeAchievementUnlocked && change.Achievement.ID == "socialite" {
+			unlocks++
+		}
+	}
+	if unlocks != 1 {
+		t.Errorf("Expected one achievement_unlocked change, got %d", unlocks)
+	}
+}
+
+func TestContextManager_CustomAchievementRecrossed(t *testing.T) {
+	storage := NewMemoryStorage()
+	cm := NewContextManager(storage)
+	defer cm.Shutdown()
+
+	err := cm.RegisterAchievement(AchievementDef{
+		ID:   "wealthy",
+		Name: "Wealthy",
+		Unlocked: func(ctx *PlayerContext) bool {
+			return ctx.Character.Gold >= 100
+		},
+		Consequences: []string{"gold_gained"},
+		Metadata:     map[string]interface{}{"gold_amount": 10},
+	})
+	if err != nil {
+		t.Fatalf("Failed to register achievement: %v", err)
+	}
+	if err := cm.RegisterAchievement(AchievementDef{ID: "broken"}); err == nil {
+		t.Error("Expected an achievement without a predicate to be rejected")
+	}
+
+	sessionID, _ := cm.CreateSession("player123", "TestPlayer")
+	startingGold, _ := cm.GetGold(sessionID)
+
+	earn := func(amount int) {
+		cm.RecordActionWithMetadata(sessionID, "/sell loot", "trade", "merchant", "village", "", []string{"gold_gained"},
+			map[string]interface{}{"gold_amount": amount})
+		cm.WaitForEvents()
+	}
+
+	earn(100 - startingGold)
+	gold, _ := cm.GetGold(sessionID)
+	if gold != 110 {
+		t.Errorf("Expected 100 gold plus the 10 gold reward, got %d", gold)
+	}
+
+	// Dropping below the threshold and climbing back doesn't unlock it again
+	cm.SpendGold(sessionID, 50)
+	earn(50)
+
+	achievements, _ := cm.GetAchievements(sessionID)
+	if len(achievements) != 1 {
+		t.Errorf("Expected wealthy to unlock once, got %+v", achievements)
+	}
+	gold, _ = cm.GetGold(sessionID)
+	if gold != 110 {
+		t.Errorf("Expected no second reward, got %d gold", gold)
+	}
+
+	// Undoing the unlocking action takes the achievement and reward back
+	cm.UndoLastAction(sessionID)
+	cm.UndoLastAction(sessionID)
+	if achievements, _ := cm.GetAchievements(sessionID); len(achievements) != 0 {
+		t.Errorf("Expected undo to revoke the achievement, got %+v", achievements)
+	}
+}
+
+func TestContextManager_GetContextDelta(t *testing.T) {
+	cm := NewContextManager(NewMemoryStorage())
+	defer cm.Shutdown()
+
+	sessionID, _ := cm.CreateSession("player123", "TestPlayer")
+	_, since, err := cm.GetContextDelta(sessionID, 0)
+	if err != nil {
+		t.Fatalf("Failed to get initial delta: %v", err)
+	}
+
+	cm.UpdateLocation(sessionID, "forest")
+	cm.UpdateReputation(sessionID, 5)
+
+	delta, version, err := cm.GetContextDelta(sessionID, since)
+	if err != nil {
+		t.Fatalf("Failed to get delta: %v", err)
+	}
+	if version != since+2 {
+		t.Errorf("Expected two mutations to advance the version from %d to %d, got %d", since, since+2, version)
+	}
+	if delta.Location == nil || *delta.Location != "forest" {
+		t.Errorf("Expected the delta to include the new location, got %v", delta.Location)
+	}
+	ctx, _ := cm.GetContext(sessionID)
+	if delta.Reputation == nil || *delta.Reputation != ctx.Character.Reputation {
+		t.Errorf("Expected the delta to include the new reputation, got %v", delta.Reputation)
+	}
+	if delta.Full || delta.Health != nil || len(delta.NewActions) != 0 || len(delta.ChangedNPCs) != 0 {
+		t.Errorf("Expected only location and reputation in the delta, got %+v", delta)
+	}
+
+	// Nothing has changed since the latest version
+	delta, next, _ := cm.GetContextDelta(sessionID, version)
+	if next != version || delta.Location != nil || delta.Reputation != nil {
+		t.Errorf("Expected an empty delta at the current version, got %+v", delta)
+	}
+
+	if _, _, err := cm.GetContextDelta(sessionID, version+1); err == nil {
+		t.Error("Expected a version from the future to be rejected")
+	}
+}
+
+func TestContextManager_GetContextDeltaActionsAndNPCs(t *testing.T) {
+	cm := NewContextManager(NewMemoryStorage())
+	defer cm.Shutdown()
+
+	sessionID, _ := cm.CreateSession("player123", "TestPlayer")
+	cm.UpdateNPCRelationship(sessionID, "npc_1", "Guard", 5, nil)
+	_, since, _ := cm.GetContextDelta(sessionID, 0)
+
+	cm.RecordAction(sessionID, "/look", "explore", "surroundings", "village", "", nil)
+	cm.WaitForEvents()
+	cm.UpdateNPCRelationship(sessionID, "npc_2", "Merchant", 10, nil)
+
+	delta, _, err := cm.GetContextDelta(sessionID, since)
+	if err != nil {
+		t.Fatalf("Failed to get delta: %v", err)
+	}
+	if len(delta.NewActions) != 1 || delta.NewActions[0].Command != "/look" {
+		t.Errorf("Expected only the new action, got %+v", delta.NewActions)
+	}
+	if len(delta.ChangedNPCs) != 1 || delta.ChangedNPCs[0].NPCID != "npc_2" {
+		t.Errorf("Expected only the new NPC, got %+v", delta.ChangedNPCs)
+	}
+}
+
+func TestContextManager_ExportTranscript(t *testing.T) {
+	cm := NewContextManager(NewMemoryStorage())
+	defer cm.Shutdown()
+
+	sessionID, _ := cm.CreateSession("player123", "TestPlayer")
+	cm.UpdateNPCRelationship(sessionID, "npc_1", "Guard", 5, []string{"player is polite"})
+	cm.UpdateLocation(sessionID, "forest")
+	cm.RecordAction(sessionID, "/look around", "explore", "surroundings", "forest", "Tall pines sway overhead.", nil)
+	cm.WaitForEvents()
+
+	transcript, err := cm.ExportTranscript(sessionID)
+	if err != nil {
+		t.Fatalf("Failed to export transcript: %v", err)
+	}
+
+	for _, want := range []string{
+		"# TestPlayer's Adventure",
+		"`/look around`",
+		"> Tall pines sway overhead.",
+		"Arrived at forest",
+		"Met Guard",
+		"## Summary",
+		"TestPlayer finished at forest",
+	} {
+		if !strings.Contains(transcript, want) {
+			t.Errorf("Expected transcript to contain %q, got:\n%s", want, transcript)
+		}
+	}
+
+	// Entries are in time order
+	if strings.Index(transcript, "Met Guard") > strings.Index(transcript, "`/look around`") {
+		t.Error("Expected meeting the guard to come before the later action")
+	}
+
+	again, _ := cm.ExportTranscript(sessionID)
+	if again != transcript {
+		t.Error("Expected exporting twice to produce the same transcript")
+	}
+}
+
+func TestMemoryStorage_BackupRestore(t *testing.T) {
+	storage := NewMemoryStorage()
+	for _, ctx := range []*PlayerContext{
+		{SessionID: "session1", PlayerID: "player1", Character: CharacterState{Name: "Aria", Gold: 42}},
+		{SessionID: "session2", PlayerID: "player2", Character: CharacterState{Name: "Borin"}},
+	} {
+		storage.SaveContext(ctx)
+	}
+
+	backup, err := storage.BackupContexts()
+	if err != nil {
+		t.Fatalf("Failed to back up contexts: %v", err)
+	}
+
+	// Wipe, then restore
+	storage.DeleteContext("session1")
+	storage.DeleteContext("session2")
+
+	imported, err := storage.RestoreContexts(backup)
+	if err != nil {
+		t.Fatalf("Failed to restore contexts: %v", err)
+	}
+	if imported != 2 {
+		t.Errorf("Expected 2 contexts restored, got %d", imported)
+	}
+
+	sessions, _ := storage.ListActiveSessions()
+	if len(sessions) != 2 {
+		t.Errorf("Expected 2 sessions after restore, got %d", len(sessions))
+	}
+	restored, err := storage.LoadContext("session1")
+	if err != nil {
+		t.Fatalf("Failed to load restored context: %v", err)
+	}
+	if restored.Character.Name != "Aria" || restored.Character.Gold != 42 {
+		t.Errorf("Expected Aria with 42 gold, got %+v", restored.Character)
+	}
+}
+
+func TestMemoryStorage_RestoreSkipsMalformedEntries(t *testing.T) {
+	storage := NewMemoryStorage()
+
+	backup := []byte(`[
+		{"session_id": "good", "character": {"name": "Aria"}},
+		{"session_id": 7},
+		{"player_id": "no-session"},
+		{"session_id": "cursed", "character": {"reputation": 500}},
+		"not a context"
+	]`)
+	imported, err := storage.RestoreContexts(backup)
+	if err != nil {
+		t.Fatalf("Failed to restore contexts: %v", err)
+	}
+	if imported != 1 {
+		t.Errorf("Expected only the well-formed context to be restored, got %d", imported)
+	}
+
+	if _, err := storage.RestoreContexts([]byte(`{"session_id": "good"}`)); err == nil {
+		t.Error("Expected a backup that isn't an array to be rejected")
+	}
+}
+
+func TestContextManager_ImportContext(t *testing.T) {
+	storage := NewMemoryStorage()
+	cm := NewContextManager(storage)
+	defer cm.Shutdown()
+
+	if err := cm.ImportContext(&PlayerContext{}); err == nil {
+		t.Error("Expected a context without a session ID to be rejected")
+	}
+
+	sessionID, _ := cm.CreateSession("player123", "TestPlayer")
+	original, _ := cm.GetContext(sessionID)
+
+	invalid := original.Clone()
+	invalid.Character.Health.Current = invalid.Character.Health.Max + 1
+	invalid.Character.Gold = 999
+	var invalidErr *InvalidContextError
+	if err := cm.ImportContext(invalid); !errors.As(err, &invalidErr) {
+		t.Errorf("Expected a context with too much health to be rejected, got %v", err)
+	}
+	if gold, _ := cm.GetGold(sessionID); gold == 999 {
+		t.Error("Expected a rejected import to leave the live context alone")
+	}
+
+	imported := original.Clone()
+	imported.Character.Gold = 999
+	if err := cm.ImportContext(imported); err != nil {
+		t.Fatalf("Failed to import context: %v", err)
+	}
+
+	// The live copy is replaced, not just the stored one
+	if gold, _ := cm.GetGold(sessionID); gold != 999 {
+		t.Errorf("Expected imported gold 999, got %d", gold)
+	}
+	stored, _ := storage.LoadContext(sessionID)
+	if stored.Character.Gold != 999 {
+		t.Errorf("Expected imported context to be saved, got %d gold", stored.Character.Gold)
+	}
+}
+
+// fakeStorage is a third-party backend built from the config it was given
+type fakeStorage struct {
+	*MemoryContextStorage
+	url string
+}
+
+func TestNewStorage_RegisteredBackend(t *testing.T) {
+	RegisterStorage("Fake", func(cfg config.Config) (ContextStorage, error) {
+		return &fakeStorage{MemoryContextStorage: NewMemoryStorage(), url: cfg.Database.URL}, nil
+	})
+
+	cfg := config.Config{Database: config.DatabaseConfig{URL: "fake://db"}}
+	storage, err := NewStorage("fake", cfg)
+	if err != nil {
+		t.Fatalf("Failed to create registered storage: %v", err)
+	}
+	fake, ok := storage.(*fakeStorage)
+	if !ok {
+		t.Fatalf("Expected the fake backend, got %T", storage)
+	}
+	if fake.url != "fake://db" {
+		t.Errorf("Expected the factory to receive the config, got URL %q", fake.url)
+	}
+
+	if _, err := NewStorage("memory", cfg); err != nil {
+		t.Errorf("Expected the built-in memory backend to be registered: %v", err)
+	}
+}
+
+func TestNewStorage_UnknownBackend(t *testing.T) {
+	_, err := NewStorage("mongo", config.Config{})
+	if err == nil {
+		t.Fatal("Expected an unknown backend to be rejected")
+	}
+	if !strings.Contains(err.Error(), "memory") {
+		t.Errorf("Expected the error to list registered backends, got %v", err)
+	}
+
+	RegisterStorage("broken", func(cfg config.Config) (ContextStorage, error) {
+		return nil, errors.New("connection refused")
+	})
+	if _, err := NewStorage("broken", config.Config{}); err == nil || !strings.Contains(err.Error(), "connection refused") {
+		t.Errorf("Expected the factory's error to be returned, got %v", err)
+	}
+}
+
+func TestNewContextManagerWithConfig_MaxActions(t *testing.T) {
+	cm := NewContextManagerWithConfig(NewMemoryStorage(), config.ContextConfig{MaxActions: 3})
+	defer cm.Shutdown()
+
+	sessionID, _ := cm.CreateSession("player123", "TestPlayer")
+	for i := 1; i <= 5; i++ {
+		cm.RecordAction(sessionID, fmt.Sprintf("/look %d", i), "explore", "surroundings", "village", "", nil)
+	}
+	cm.WaitForEvents()
+
+	ctx, _ := cm.GetContext(sessionID)
+	if len(ctx.Actions) != 3 {
+		t.Fatalf("Expected only the last 3 actions to be kept, got %d", len(ctx.Actions))
+	}
+	for i, action := range ctx.Actions {
+		if want := fmt.Sprintf("/look %d", i+3); action.Command != want {
+			t.Errorf("Expected action %d to be %q, got %q", i, want, action.Command)
+		}
+	}
+
+	// Unset values fall back to the defaults
+	if metrics := cm.GetContextMetrics(); metrics["cache_timeout_minutes"] != DefaultCacheTimeout.Minutes() {
+		t.Errorf("Expected the default cache timeout, got %v", metrics["cache_timeout_minutes"])
+	}
+}
+
+// countingStorage counts the contexts the manager saves
+type countingStorage struct {
+	*MemoryContextStorage
+	saves atomic.Int64
+}
+
+func (s *countingStorage) SaveContext(ctx *PlayerContext) error {
+	s.saves.Add(1)
+	return s.MemoryContextStorage.SaveContext(ctx)
+}
+
+// stallingStorage holds the first save after stall is called until release
+type stallingStorage struct {
+	*MemoryContextStorage
+	stalled  atomic.Bool
+	started  chan struct{}
+	released chan struct{}
+}
+
+func (s *stallingStorage) stall() {
+	s.started, s.released = make(chan struct{}), make(chan struct{})
+	s.stalled.Store(true)
+}
+
+func (s *stallingStorage) SaveContext(ctx *PlayerContext) error {
+	if s.stalled.CompareAndSwap(true, false) {
+		close(s.started)
+		<-s.released
+	}
+	return s.MemoryContextStorage.SaveContext(ctx)
+}
+
+func TestContextManager_PeriodicSaveDoesNotOverwriteWriteThrough(t *testing.T) {
+	storage := &stallingStorage{MemoryContextStorage: NewMemoryStorage()}
+	cm := NewContextManagerWithConfig(storage, config.ContextConfig{PersistInterval: time.Hour})
+	defer cm.Shutdown()
+
+	sessionID, _ := cm.CreateSession("player123", "TestPlayer")
+	cm.UpdateLocation(sessionID, "forest")
+
+	// The periodic save of the older state stalls in storage...
+	storage.stall()
+	saved := make(chan struct{})
+	go func() {
+		defer close(saved)
+		cm.saveAllCachedContexts()
+	}()
+	<-storage.started
+
+	// ...while a level-up is written through
+	cm.RecordAction(sessionID, "/train", "train", "", "forest", "", []string{"level_up"})
+	time.Sleep(20 * time.Millisecond)
+	close(storage.released)
+	<-saved
+	cm.WaitForEvents()
+
+	stored, err := storage.LoadContext(sessionID)
+	if err != nil {
+		t.Fatalf("Failed to load context: %v", err)
+	}
+	if stored.Character.UnspentPoints != attributePointsPerLevel {
+		t.Errorf("Expected the level-up to survive the periodic save, got %d unspent points", stored.Character.UnspentPoints)
+	}
+}
+
+func TestContextManager_WriteThroughCriticalUpdates(t *testing.T) {
+	storage := &countingStorage{MemoryContextStorage: NewMemoryStorage()}
+	cm := NewContextManagerWithConfig(storage, config.ContextConfig{PersistInterval: time.Hour})
+	defer cm.Shutdown()
+
+	sessionID, _ := cm.CreateSession("player123", "TestPlayer")
+	cm.StartQuest(sessionID, Quest{ID: "wolves", Title: "Clear the wolves"})
+	cm.RecordAction(sessionID, "/look", "explore", "", "village", "", nil)
+	cm.WaitForEvents()
+	saves := storage.saves.Load()
+
+	// Ordinary updates wait for the periodic saver
+	if stored, _ := storage.LoadContext(sessionID); len(stored.Actions) != 0 {
+		t.Fatalf("Expected the ordinary action to wait for the periodic save, got %d stored actions", len(stored.Actions))
+	}
+
+	if err := cm.CompleteQuest(sessionID, "wolves"); err != nil {
+		t.Fatalf("Failed to complete quest: %v", err)
+	}
+	if storage.saves.Load() != saves+1 {
+		t.Errorf("Expected completing a quest to save once, got %d saves", storage.saves.Load()-saves)
+	}
+	stored, _ := storage.LoadContext(sessionID)
+	if stored.Quests["wolves"].Status != QuestCompleted || len(stored.Actions) != 1 {
+		t.Errorf("Expected the completed quest and earlier action in storage, got %+v", stored.Quests["wolves"])
+	}
+
+	cm.RecordAction(sessionID, "/train", "train", "", "village", "", []string{"level_up"})
+	cm.WaitForEvents()
+	if stored, _ := storage.LoadContext(sessionID); stored.Character.UnspentPoints != attributePointsPerLevel {
+		t.Errorf("Expected the level-up in storage straight away, got %d unspent points", stored.Character.UnspentPoints)
+	}
+
+	cm.UpdateCharacterHealth(sessionID, -100)
+	if stored, _ := storage.LoadContext(sessionID); stored.Character.Alive {
+		t.Error("Expected the death in storage straight away")
+	}
+}
+
+func TestContextManager_PeriodicSaveSkipsUnchangedContexts(t *testing.T) {
+	storage := &countingStorage{MemoryContextStorage: NewMemoryStorage()}
+	cm := NewContextManagerWithConfig(storage, config.ContextConfig{PersistInterval: time.Hour})
+	defer cm.Shutdown()
+
+	idle, _ := cm.CreateSession("player1", "Aria")
+	busy, _ := cm.CreateSession("player2", "Bram")
+	saves := storage.saves.Load()
+
+	// Freshly created sessions are already stored
+	cm.saveAllCachedContexts()
+	if storage.saves.Load() != saves {
+		t.Errorf("Expected unchanged contexts not to be saved, got %d saves", storage.saves.Load()-saves)
+	}
+
+	cm.UpdateLocation(busy, "thornwick_forest")
+	cm.saveAllCachedContexts()
+	if storage.saves.Load() != saves+1 {
+		t.Errorf("Expected only the changed context to be saved, got %d saves", storage.saves.Load()-saves)
+	}
+	if stored, _ := storage.LoadContext(busy); stored.Location.Current != "thornwick_forest" {
+		t.Errorf("Expected the change to be stored, got location %s", stored.Location.Current)
+	}
+
+	// Reads don't make a context dirty, and a saved one stays clean until changed
+	cm.GetContext(busy)
+	cm.GetContext(idle)
+	cm.saveAllCachedContexts()
+	if storage.saves.Load() != saves+1 {
+		t.Errorf("Expected nothing to be saved on the second tick, got %d saves", storage.saves.Load()-saves)
+	}
+	if skipped := cm.GetContextMetrics()["saves_skipped"]; skipped != int64(5) {
+		t.Errorf("Expected 5 skipped saves, got %v", skipped)
+	}
+
+	// Sessions created on a cache miss aren't stored yet, so the saver stores them
+	cm.GetContext("walk-in")
+	cm.saveAllCachedContexts()
+	if _, err := storage.LoadContext("walk-in"); err != nil {
+		t.Errorf("Expected the new session to be saved, got %v", err)
+	}
+	if storage.saves.Load() != saves+2 {
+		t.Errorf("Expected one more save for the new session, got %d saves", storage.saves.Load()-saves)
+	}
+
+	// A forced flush saves regardless
+	if err := cm.FlushAll(); err != nil {
+		t.Fatalf("Failed to flush: %v", err)
+	}
+	if storage.saves.Load() != saves+5 {
+		t.Errorf("Expected a flush to save all 3 contexts, got %d saves", storage.saves.Load()-saves-2)
+	}
+}
+
+func TestContextManager_StatusTickLeavesIdleSessionsAlone(t *testing.T) {
+	storage := &countingStorage{MemoryContextStorage: NewMemoryStorage()}
+	cm := NewContextManagerWithConfig(storage, config.ContextConfig{PersistInterval: time.Hour, StatusTick: 5 * time.Millisecond})
+	defer cm.Shutdown()
+	clock := newTestClock()
+	cm.SetNowFunc(clock.Now)
+	// Slow game time keeps the weather from rolling while the session idles
+	cm.SetGameTimeScale(1)
+
+	sessionID, _ := cm.CreateSession("player123", "TestPlayer")
+	before, _ := cm.GetContext(sessionID)
+	saves := storage.saves.Load()
+
+	// The real ticker runs over an idle session with nothing to regenerate
+	// and no effects
+	clock.Advance(40 * time.Minute)
+	time.Sleep(50 * time.Millisecond)
+
+	after, _ := cm.GetContext(sessionID)
+	if !after.LastUpdate.Equal(before.LastUpdate) || after.Version != before.Version {
+		t.Errorf("Expected idle ticks to leave the session untouched, got version %d -> %d", before.Version, after.Version)
+	}
+	cm.saveAllCachedContexts()
+	if storage.saves.Load() != saves {
+		t.Errorf("Expected the idle session not to be saved again, got %d saves", storage.saves.Load()-saves)
+	}
+	if evicted := cm.EvictIdle(30 * time.Minute); evicted != 1 {
+		t.Errorf("Expected the idle session to be evicted, got %d evicted", evicted)
+	}
+}
+
+func TestContextManager_RegenerationMarksOnlyChangedSessions(t *testing.T) {
+	storage := NewMemoryStorage()
+	cm := NewContextManager(storage)
+	defer cm.Shutdown()
+	clock := newTestClock()
+	cm.SetNowFunc(clock.Now)
+
+	rested, _ := cm.CreateSession("player1", "Aria")
+	tired, _ := cm.CreateSession("player2", "Bram")
+	cm.mutateContext(tired, func(ctx *PlayerContext) error {
+		ctx.Character.Stamina.Current = ctx.Character.Stamina.Max - 1
+		return nil
+	})
+	cm.FlushAll()
+
+	cm.tickStatusEffects(clock.Now())
+	if _, dirty := cm.dirty.Load(rested); dirty {
+		t.Error("Expected full pools not to mark the session changed")
+	}
+	if _, dirty := cm.dirty.Load(tired); !dirty {
+		t.Error("Expected regenerated stamina to mark the session changed")
+	}
+
+	// Once the pool is full again, ticks leave it alone
+	cm.FlushAll()
+	cm.tickStatusEffects(clock.Now())
+	if _, dirty := cm.dirty.Load(tired); dirty {
+		t.Error("Expected a refilled pool not to mark the session changed")
+	}
+}
+
+// cleaningStorage records the pruning requests the manager makes
+type cleaningStorage struct {
+	*MemoryContextStorage
+	cleaned chan time.Duration
+}
+
+func (s *cleaningStorage) CleanupOldContexts(olderThan time.Duration) (int, error) {
+	select {
+	case s.cleaned <- olderThan:
+	default:
+	}
+	return 0, nil
+}
+
+func TestContextManager_CleanupEvictsIdleContexts(t *testing.T) {
+	storage := &cleaningStorage{MemoryContextStorage: NewMemoryStorage(), cleaned: make(chan time.Duration, 1)}
+	cm := NewContextManagerWithConfig(storage, config.ContextConfig{
+		CacheTimeout:    time.Minute,
+		CleanupInterval: 10 * time.Millisecond,
+		MaxContextAge:   24 * time.Hour,
+	})
+	defer cm.Shutdown()
+
+	sessionID, _ := cm.CreateSession("player123", "TestPlayer")
+
+	// Age the cached context past the cache timeout
+	aged := time.Now().Add(-time.Hour)
+	lock := cm.sessionLock(sessionID)
+	lock.Lock()
+	cached, _ := cm.cache.Load(sessionID)
+	cached.(*PlayerContext).LastUpdate = aged
+	lock.Unlock()
+
+	deadline := time.Now().Add(time.Second)
+	for {
+		if _, cached := cm.cache.Load(sessionID); !cached {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("Expected the idle context to be evicted from the cache")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	// The context was saved on its way out
+	stored, err := storage.LoadContext(sessionID)
+	if err != nil {
+		t.Fatalf("Expected the evicted context in storage: %v", err)
+	}
+	if !stored.LastUpdate.Equal(aged) {
+		t.Errorf("Expected the evicted context to be saved, stored LastUpdate is %v", stored.LastUpdate)
+	}
+
+	select {
+	case olderThan := <-storage.cleaned:
+		if olderThan != 24*time.Hour {
+			t.Errorf("Expected storage pruned at the max context age, got %v", olderThan)
+		}
+	case <-time.After(time.Second):
+		t.Error("Expected the cleanup tick to prune storage")
+	}
+}
+
+// stallEventQueue fills a size-1 queue: the processor is left waiting on a
+// locked session, and a second action occupies the only slot. The returned
+// function lets the processor continue.
+func stallEventQueue(t *testing.T, cm *ContextManager, sessionID string) func() {
+	t.Helper()
+
+	blockerID, _ := cm.CreateSession("blocker", "Blocker")
+	lock := cm.sessionLock(blockerID)
+	lock.Lock()
+
+	cm.RecordAction(blockerID, "/wait", "explore", "", "village", "", nil)
+	for deadline := time.Now().Add(time.Second); len(cm.eventQueue) > 0; time.Sleep(time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatal("Expected the processor to pick up the blocking action")
+		}
+	}
+
+	if err := cm.RecordAction(sessionID, "/first", "explore", "", "village", "", nil); err != nil {
+		t.Fatalf("Expected the queue to have room for one action: %v", err)
+	}
+
+	var once sync.Once
+	return func() { once.Do(lock.Unlock) }
+}
+
+func newQueueTestManager(policy string, timeout time.Duration) *ContextManager {
+	return NewContextManagerWithConfig(NewMemoryStorage(), config.ContextConfig{
+		EventQueueSize:    1,
+		EventQueuePolicy:  policy,
+		EventQueueTimeout: timeout,
+	})
+}
+
+func TestRecordAction_RejectWhenQueueFull(t *testing.T) {
+	cm := newQueueTestManager(EventQueueReject, 0)
+	defer cm.Shutdown()
+
+	sessionID, _ := cm.CreateSession("player123", "TestPlayer")
+	release := stallEventQueue(t, cm, sessionID)
+	defer release()
+
+	if err := cm.RecordAction(sessionID, "/second", "explore", "", "village", "", nil); !errors.Is(err, ErrEventQueueFull) {
+		t.Errorf("Expected ErrEventQueueFull, got %v", err)
+	}
+	if dropped := cm.GetContextMetrics()["dropped_events"]; dropped != int64(1) {
+		t.Errorf("Expected 1 dropped event, got %v", dropped)
+	}
+}
+
+func TestRecordAction_BlockWhenQueueFull(t *testing.T) {
+	cm := newQueueTestManager(EventQueueBlock, 20*time.Millisecond)
+	defer cm.Shutdown()
+
+	sessionID, _ := cm.CreateSession("player123", "TestPlayer")
+	release := stallEventQueue(t, cm, sessionID)
+	defer release()
+
+	// Nothing frees up within the timeout
+	start := time.Now()
+	if err := cm.RecordAction(sessionID, "/second", "explore", "", "village", "", nil); !errors.Is(err, ErrEventQueueFull) {
+		t.Errorf("Expected ErrEventQueueFull after the timeout, got %v", err)
+	}
+	if waited := time.Since(start); waited < 20*time.Millisecond {
+		t.Errorf("Expected to wait for the timeout, returned after %v", waited)
+	}
+
+	// Room frees up while waiting
+	time.AfterFunc(5*time.Millisecond, release)
+	cm.queueTimeout = time.Second
+	if err := cm.RecordAction(sessionID, "/third", "explore", "", "village", "", nil); err != nil {
+		t.Errorf("Expected the action to be queued once there was room: %v", err)
+	}
+	cm.WaitForEvents()
+
+	ctx, _ := cm.GetContext(sessionID)
+	if len(ctx.Actions) != 2 || ctx.Actions[1].Command != "/third" {
+		t.Errorf("Expected /first and /third to be recorded, got %+v", ctx.Actions)
+	}
+	if dropped := cm.GetContextMetrics()["dropped_events"]; dropped != int64(1) {
+		t.Errorf("Expected 1 dropped event, got %v", dropped)
+	}
+}
+
+func TestRecordAction_DropOldestWhenQueueFull(t *testing.T) {
+	cm := newQueueTestManager(EventQueueDropOldest, 0)
+	defer cm.Shutdown()
+
+	sessionID, _ := cm.CreateSession("player123", "TestPlayer")
+	release := stallEventQueue(t, cm, sessionID)
+
+	if err := cm.RecordAction(sessionID, "/second", "explore", "", "village", "", nil); err != nil {
+		t.Errorf("Expected the newest action to be queued: %v", err)
+	}
+	release()
+	cm.WaitForEvents()
+
+	ctx, _ := cm.GetContext(sessionID)
+	if len(ctx.Actions) != 1 || ctx.Actions[0].Command != "/second" {
+		t.Errorf("Expected only /second to survive, got %+v", ctx.Actions)
+	}
+	if dropped := cm.GetContextMetrics()["dropped_events"]; dropped != int64(1) {
+		t.Errorf("Expected 1 dropped event, got %v", dropped)
+	}
+}
+
+func TestContextManager_LocationStats(t *testing.T) {
+	cm := NewContextManager(NewMemoryStorage())
+	defer cm.Shutdown()
+	cm.SetGameTimeScale(0) // only explicit time passes
+
+	sessionID, _ := cm.CreateSession("player123", "TestPlayer")
+	start, _ := cm.GetLocationStats(sessionID)
+
+	cm.UpdateLocation(sessionID, "forest")
+	stats, err := cm.GetLocationStats(sessionID)
+	if err != nil {
+		t.Fatalf("Failed to get location stats: %v", err)
+	}
+	if stats.VisitCount != 1 || stats.TimeInLocation != 0 {
+		t.Errorf("Expected a first visit to the forest just now, got %+v", stats)
+	}
+
+	cm.AdvanceGameTime(sessionID, 45)
+	stats, _ = cm.GetLocationStats(sessionID)
+	if stats.TimeInLocation != 45 {
+		t.Errorf("Expected 45 minutes in the forest, got %d", stats.TimeInLocation)
+	}
+
+	cm.UpdateLocation(sessionID, start.Current)
+	stats, _ = cm.GetLocationStats(sessionID)
+	if stats.VisitCount != 2 {
+		t.Errorf("Expected the second visit to %s, got %d", start.Current, stats.VisitCount)
+	}
+	if stats.TimeInLocation != 0 {
+		t.Errorf("Expected the time in location to restart on arrival, got %d", stats.TimeInLocation)
+	}
+	if stats.Visits["forest"] != 1 {
+		t.Errorf("Expected one visit to the forest, got %v", stats.Visits)
+	}
+}
+
+// topicEmbedder embeds text as a one-hot vector of the first topic it
+// mentions, so similarity is exact and predictable
+type topicEmbedder struct {
+	topics []string
+}
+
+func (e topicEmbedder) Embed(text string) ([]float32, error) {
+	vector := make([]float32, len(e.topics)+1)
+	for i, topic := range e.topics {
+		if strings.Contains(strings.ToLower(text), topic) {
+			vector[i] = 1
+			return vector, nil
+		}
+	}
+	vector[len(e.topics)] = 1
+	return vector, nil
+}
+
+func TestContextManager_RetrieveRelevantActions(t *testing.T) {
+	cm := NewContextManager(NewMemoryStorage())
+	defer cm.Shutdown()
+
+	sessionID, _ := cm.CreateSession("player123", "TestPlayer")
+	if _, err := cm.RetrieveRelevantActions(sessionID, "dragon", 1); err == nil {
+		t.Error("Expected an error without an embedder")
+	}
+
+	cm.SetEmbedder(topicEmbedder{topics: []string{"dragon", "bread", "river"}})
+	cm.RecordAction(sessionID, "/ask about the dragon", "talk", "elder", "village", "The elder shudders at the dragon's name.", nil)
+	cm.RecordAction(sessionID, "/buy bread", "trade", "baker", "village", "Warm bread, two coins.", nil)
+	cm.RecordAction(sessionID, "/fish in the river", "explore", "", "village", "Nothing bites.", nil)
+	cm.RecordAction(sessionID, "/buy more bread", "trade", "baker", "village", "The baker smiles.", nil)
+	cm.RecordAction(sessionID, "/sing", "social", "", "village", "Nobody claps.", nil)
+	cm.WaitForEvents()
+
+	relevant, err := cm.RetrieveRelevantActions(sessionID, "Where does the dragon sleep?", 2)
+	if err != nil {
+		t.Fatalf("Failed to retrieve relevant actions: %v", err)
+	}
+	if len(relevant) != 2 || relevant[0].Command != "/ask about the dragon" {
+		t.Errorf("Expected the dragon question first, got %+v", relevant)
+	}
+
+	relevant, _ = cm.RetrieveRelevantActions(sessionID, "bread please", 2)
+	if len(relevant) != 2 || relevant[0].Command != "/buy bread" || relevant[1].Command != "/buy more bread" {
+		t.Errorf("Expected both bread purchases, got %+v", relevant)
+	}
+
+	// The three most recent actions are already listed, so only the dragon
+	// question is worth recalling
+	prompt, err := cm.GenerateAIPromptForCommand(sessionID, "/attack the dragon")
+	if err != nil {
+		t.Fatalf("Failed to generate prompt: %v", err)
+	}
+	history := prompt[strings.Index(prompt, "RELEVANT HISTORY:"):]
+	history = history[:strings.Index(history, "ACTIVE NPCS")]
+	if !strings.Contains(history, "/ask about the dragon") || strings.Contains(history, "/sing") {
+		t.Errorf("Expected the dragon question in the relevant history, got %q", history)
+	}
+
+	plain, _ := cm.GenerateAIPrompt(sessionID)
+	if strings.Contains(plain, "RELEVANT HISTORY") {
+		t.Error("Expected no relevant history without a command")
+	}
+}
+
+// fakeSummarizer records the commands it is asked to summarize and appends
+// them to the prior summary
+type fakeSummarizer struct {
+	mu    sync.Mutex
+	calls int
+}
+
+func (f *fakeSummarizer) SummarizeHistory(actions []ActionEvent, prior string) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.calls++
+
+	commands := make([]string, len(actions))
+	for i, action := range actions {
+		commands[i] = action.Command
+	}
+	return strings.TrimSpace(prior + " " + strings.Join(commands, " ")), nil
+}
+
+func TestContextManager_SummarizesOldActions(t *testing.T) {
+	cm := NewContextManagerWithConfig(NewMemoryStorage(), config.ContextConfig{
+		MaxActions:       10,
+		SummaryThreshold: 5,
+		SummaryBatch:     3,
+	})
+	defer cm.Shutdown()
+
+	summarizer := &fakeSummarizer{}
+	cm.SetSummarizer(summarizer)
+
+	sessionID, _ := cm.CreateSession("player123", "TestPlayer")
+	for i := 1; i <= 6; i++ {
+		cm.RecordAction(sessionID, fmt.Sprintf("/step%d", i), "move", "", "road", "", nil)
+	}
+	cm.WaitForEvents()
+	cm.aiTasks.Wait()
+
+	ctx, _ := cm.GetContext(sessionID)
+	if ctx.HistorySummary != "/step1 /step2 /step3" {
+		t.Errorf("Expected the three oldest actions in the summary, got %q", ctx.HistorySummary)
+	}
+	if len(ctx.Actions) != 3 || ctx.Actions[0].Command != "/step4" {
+		t.Errorf("Expected /step4 to /step6 to remain, got %+v", ctx.Actions)
+	}
+
+	prompt, _ := cm.GenerateAIPrompt(sessionID)
+	if !strings.Contains(prompt, "STORY SO FAR:\n/step1 /step2 /step3") {
+		t.Error("Expected the prompt to open with the history summary")
+	}
+
+	// The next batch extends the summary rather than replacing it
+	for i := 7; i <= 9; i++ {
+		cm.RecordAction(sessionID, fmt.Sprintf("/step%d", i), "move", "", "road", "", nil)
+	}
+	cm.WaitForEvents()
+	cm.aiTasks.Wait()
+
+	ctx, _ = cm.GetContext(sessionID)
+	if ctx.HistorySummary != "/step1 /step2 /step3 /step4 /step5 /step6" {
+		t.Errorf("Expected the summary to grow, got %q", ctx.HistorySummary)
+	}
+	if summarizer.calls != 2 {
+		t.Errorf("Expected 2 summaries, got %d", summarizer.calls)
+	}
+}
+
+func TestContextManager_AssembleAIPromptWithinBudget(t *testing.T) {
+	cm := NewContextManagerWithConfig(NewMemoryStorage(), config.ContextConfig{
+		MaxActions:      500,
+		MaxPromptTokens: 800,
+	})
+	defer cm.Shutdown()
+
+	sessionID, _ := cm.CreateSession("player123", "TestHero")
+	cm.UpdateLocation(sessionID, "dark_forest")
+
+	outcome := strings.Repeat("The trees whisper of ancient things. ", 40)
+	for i := 0; i < 300; i++ {
+		cm.RecordAction(sessionID, fmt.Sprintf("/listen %d", i), "explore", "", "dark_forest", outcome, nil)
+	}
+	cm.WaitForEvents()
+
+	command := "/follow the whispers"
+	prompt, truncated, err := cm.AssembleAIPrompt(sessionID, command)
+	if err != nil {
+		t.Fatalf("Failed to assemble AI prompt: %v", err)
+	}
+	if !truncated {
+		t.Error("Expected a huge history to be truncated")
+	}
+	if tokens := EstimateTokens(prompt) + EstimateTokens(command); tokens > 800 {
+		t.Errorf("Expected the prompt and command to fit 800 tokens, got %d", tokens)
+	}
+
+	for _, essential := range []string{"CURRENT GAME STATE:", "- Location: dark_forest", "- Player Health: 20/20", "GM INSTRUCTIONS:"} {
+		if !strings.Contains(prompt, essential) {
+			t.Errorf("Expected the truncated prompt to keep %q", essential)
+		}
+	}
+	if strings.Contains(prompt, "/listen 297") {
+		t.Error("Expected older actions to be cut before newer ones")
+	}
+
+	// Without a budget nothing is cut
+	unlimited := NewContextManagerWithConfig(NewMemoryStorage(), config.ContextConfig{})
+	defer unlimited.Shutdown()
+	otherID, _ := unlimited.CreateSession("player456", "OtherHero")
+	unlimited.RecordAction(otherID, "/listen", "explore", "", "village", outcome, nil)
+	unlimited.WaitForEvents()
+	if _, truncated, _ := unlimited.AssembleAIPrompt(otherID, command); truncated {
+		t.Error("Expected no truncation without a budget")
+	}
+}
+
+func TestTruncatePrompt_DropsLowestPriorityFirst(t *testing.T) {
+	sections := []promptSection{
+		{title: "STATE", entries: []string{"- Location: cave"}, priority: priorityEssential},
+		{title: "ACTIONS", entries: []string{strings.Repeat("a", 80), strings.Repeat("b", 80)}, priority: priorityRecentActions},
+		{title: "WORLD", entries: []string{strings.Repeat("w", 80)}, priority: priorityWorld},
+	}
+
+	// Room for everything but one action
+	budget := EstimateTokens(renderPrompt(sections)) - 15
+	kept, truncated := truncatePrompt(append([]promptSection(nil), sections...), budget)
+	if !truncated {
+		t.Fatal("Expected truncation")
+	}
+	if len(kept) != 3 || len(kept[1].entries) != 1 || kept[1].entries[0][0] != 'b' {
+		t.Errorf("Expected only the oldest action to go, got %+v", kept)
+	}
+
+	// Too small for anything but the essentials
+	kept, _ = truncatePrompt(append([]promptSection(nil), sections...), 1)
+	if len(kept) != 1 || kept[0].title != "STATE" {
+		t.Errorf("Expected only the essential section to remain, got %+v", kept)
+	}
+
+	if EstimateTokens("abcdefgh") != 2 || EstimateTokens("") != 0 {
+		t.Error("Expected about four characters per token")
+	}
+}
+
+func TestContextManager_GMPersonalityShapesInstructions(t *testing.T) {
+	cm := NewContextManager(NewMemoryStorage())
+	defer cm.Shutdown()
+
+	harsh, _ := cm.CreateSession("player1", "Harsh")
+	gentle, _ := cm.CreateSession("player2", "Gentle")
+
+	if err := cm.SetGMPersonality(harsh, GMPersonality{Helpfulness: 0.1, ChallengeLevel: 0.9, MysteryLevel: 0.9, ImmersionFocus: 0.5}); err != nil {
+		t.Fatalf("Failed to set personality: %v", err)
+	}
+	cm.SetGMPersonality(gentle, GMPersonality{Helpfulness: 0.9, ChallengeLevel: 0.1, MysteryLevel: 0.1, ImmersionFocus: 0.5})
+
+	harshPrompt, _ := cm.GenerateAIPrompt(harsh)
+	gentlePrompt, _ := cm.GenerateAIPrompt(gentle)
+
+	for _, want := range []string{"Present meaningful risks", "Withhold some information", "Give no hints unless asked"} {
+		if !strings.Contains(harshPrompt, want) || strings.Contains(gentlePrompt, want) {
+			t.Errorf("Expected only the harsh GM to be told %q", want)
+		}
+	}
+	for _, want := range []string{"Keep danger light", "Be forthcoming", "Offer clear hints"} {
+		if !strings.Contains(gentlePrompt, want) || strings.Contains(harshPrompt, want) {
+			t.Errorf("Expected only the gentle GM to be told %q", want)
+		}
+	}
+
+	data, _ := cm.GenerateAIPromptData(harsh)
+	if data.GMPersonality.ChallengeLevel != 0.9 {
+		t.Errorf("Expected prompt data to report the session's personality, got %+v", data.GMPersonality)
+	}
+
+	// Sessions without their own personality follow the default
+	other, _ := cm.CreateSession("player3", "Other")
+	cm.SetDefaultGMPersonality(GMPersonality{ChallengeLevel: 1, ImmersionFocus: 0.5})
+	if prompt, _ := cm.GenerateAIPrompt(other); !strings.Contains(prompt, "Present meaningful risks") {
+		t.Error("Expected the default personality to apply")
+	}
+
+	if err := cm.SetGMPersonality(harsh, GMPersonality{MysteryLevel: 1.5}); err == nil {
+		t.Error("Expected an error for a knob above 1")
+	}
+}
+
+// fakeDialogueGenerator records the personality and prompt an NPC is voiced
+// from and replies with a canned line
+type fakeDialogueGenerator struct {
+	npcName     string
+	personality string
+	prompt      string
+}
+
+func (f *fakeDialogueGenerator) GenerateNPCDialogueForSession(sessionID, npcName, personality, prompt string) (string, error) {
+	f.npcName, f.personality, f.prompt = npcName, personality, prompt
+	return "Welcome back, friend.", nil
+}
+
+func TestContextManager_GenerateNPCDialogueInContext(t *testing.T) {
+	cm := NewContextManager(NewMemoryStorage())
+	defer cm.Shutdown()
+
+	sessionID, _ := cm.CreateSession("player123", "TestHero")
+	if _, err := cm.GenerateNPCDialogueInContext(sessionID, "innkeeper", "Hello"); err == nil {
+		t.Error("Expected an error without a dialogue generator")
+	}
+
+	generator := &fakeDialogueGenerator{}
+	cm.SetDialogueGenerator(generator)
+
+	if _, err := cm.GenerateNPCDialogueInContext(sessionID, "innkeeper", "Hello"); err == nil {
+		t.Error("Expected an error for an NPC the player hasn't met")
+	}
+
+	cm.UpdateNPCRelationship(sessionID, "innkeeper", "Mara", 60, []string{"Paid for a room in advance"})
+	cm.UpdateNPCRelationship(sessionID, "guard", "Bren", -60, []string{"Was caught picking a lock"})
+
+	reply, err := cm.GenerateNPCDialogueInContext(sessionID, "innkeeper", "Any rooms free?")
+	if err != nil {
+		t.Fatalf("Failed to generate dialogue: %v", err)
+	}
+	if reply != "Welcome back, friend." || generator.npcName != "Mara" {
+		t.Errorf("Expected Mara's canned reply, got %q from %s", reply, generator.npcName)
+	}
+	if !strings.Contains(generator.personality, "friendly") || !strings.Contains(generator.personality, "warm") {
+		t.Errorf("Expected a warm, friendly personality, got %q", generator.personality)
+	}
+	if !strings.Contains(generator.prompt, "Paid for a room in advance") || !strings.Contains(generator.prompt, `"Any rooms free?"`) {
+		t.Errorf("Expected the prompt to carry Mara's facts and the player's words, got %q", generator.prompt)
+	}
+
+	cm.GenerateNPCDialogueInContext(sessionID, "guard", "Good evening")
+	if !strings.Contains(generator.personality, "hostile") || !strings.Contains(generator.personality, "cold") {
+		t.Errorf("Expected a cold, hostile personality, got %q", generator.personality)
+	}
+	if !strings.Contains(generator.prompt, "Was caught picking a lock") || strings.Contains(generator.prompt, "Paid for a room") {
+		t.Errorf("Expected only the guard's facts, got %q", generator.prompt)
+	}
+}
+
+func TestContextManager_UseAbility(t *testing.T) {
+	cm := NewContextManager(NewMemoryStorage())
+	defer cm.Shutdown()
+	clock := newTestClock()
+	cm.SetNowFunc(clock.Now)
+
+	sessionID, _ := cm.CreateSession("player123", "TestPlayer")
+	result, err := cm.UseAbility(sessionID, "fireball", "goblin")
+	if err != nil {
+		t.Fatalf("Failed to cast fireball: %v", err)
+	}
+	if result.Damage != 8 || result.Target != "goblin" || result.ResourceLeft != DefaultMaxMana-6 {
+		t.Errorf("Expected 8 damage to the goblin for 6 mana, got %+v", result)
+	}
+
+	// Casting again before the cooldown runs out costs nothing
+	clock.Advance(4 * time.Second)
+	_, err = cm.UseAbility(sessionID, "fireball", "goblin")
+	var cooldown *CooldownError
+	if !errors.As(err, &cooldown) || cooldown.Remaining != 6*time.Second {
+		t.Fatalf("Expected a cooldown error with 6s left, got %v", err)
+	}
+	ctx, _ := cm.GetContext(sessionID)
+	if ctx.Character.Mana.Current != DefaultMaxMana-6 {
+		t.Errorf("Expected a rejected cast to cost nothing, got %d mana", ctx.Character.Mana.Current)
+	}
+
+	// Two more casts drain the pool below the cost of a fourth
+	for i := 0; i < 2; i++ {
+		clock.Advance(10 * time.Second)
+		if _, err := cm.UseAbility(sessionID, "fireball", "goblin"); err != nil {
+			t.Fatalf("Failed to cast fireball again: %v", err)
+		}
+	}
+	clock.Advance(10 * time.Second)
+	_, err = cm.UseAbility(sessionID, "fireball", "goblin")
+	var insufficient *InsufficientResourceError
+	if !errors.As(err, &insufficient) || insufficient.Resource != ResourceMana || insufficient.Available != 2 {
+		t.Fatalf("Expected an out-of-mana error with 2 left, got %v", err)
+	}
+
+	// Mana comes back on every tick, up to the maximum
+	cm.tickStatusEffects(clock.Now())
+	cm.tickStatusEffects(clock.Now())
+	if _, err := cm.UseAbility(sessionID, "fireball", "goblin"); err != nil {
+		t.Errorf("Expected regenerated mana to pay for a fireball: %v", err)
+	}
+	for i := 0; i < 20; i++ {
+		cm.tickStatusEffects(clock.Now())
+	}
+	ctx, _ = cm.GetContext(sessionID)
+	if ctx.Character.Mana.Current != DefaultMaxMana {
+		t.Errorf("Expected mana to refill to %d, got %d", DefaultMaxMana, ctx.Character.Mana.Current)
+	}
+
+	result, err = cm.UseAbility(sessionID, "battle_cry", "")
+	if err != nil || result.Buff == nil {
+		t.Fatalf("Expected battle cry to buff the player, got %+v, %v", result, err)
+	}
+	if attributes, _ := cm.GetEffectiveAttributes(sessionID); attributes["strength"] != 12 {
+		t.Errorf("Expected battle cry to raise strength to 12, got %d", attributes["strength"])
+	}
+	if _, err := cm.UseAbility(sessionID, "fireball", ""); err == nil {
+		t.Error("Expected a damaging ability without a target to be rejected")
+	}
+}
+
+func TestContextManager_Shop(t *testing.T) {
+	cm := NewContextManager(NewMemoryStorage())
+	defer cm.Shutdown()
+
+	err := cm.RegisterShop("smith", Shop{Items: []ShopItem{
+		{Item: InventoryItem{ID: "potion", Name: "Healing Potion", Type: "potion", Quantity: 2}, Price: 50},
+		{Item: InventoryItem{ID: "sword", Name: "Longsword", Type: "weapon", Quantity: 1}, Price: 200},
+	}})
+	if err != nil {
+		t.Fatalf("Failed to register shop: %v", err)
+	}
+	if err := cm.RegisterShop("smith", Shop{Items: []ShopItem{{Item: InventoryItem{ID: "axe"}}}}); err == nil {
+		t.Error("Expected an item without a price to be rejected")
+	}
+
+	sessionID, _ := cm.CreateSession("player123", "TestPlayer")
+	cm.AddGold(sessionID, 100, false)
+
+	if err := cm.BuyItem(sessionID, "smith", "potion"); err != nil {
+		t.Fatalf("Failed to buy potion: %v", err)
+	}
+	ctx, _ := cm.GetContext(sessionID)
+	if ctx.Character.Gold != 50 {
+		t.Errorf("Expected 50 gold after paying 50, got %d", ctx.Character.Gold)
+	}
+	if index := findInventoryItem(ctx, "potion"); index < 0 || ctx.Character.Inventory[index].Quantity != 1 {
+		t.Errorf("Expected one potion in the inventory, got %+v", ctx.Character.Inventory)
+	}
+
+	// Too expensive: nothing changes hands
+	if err := cm.BuyItem(sessionID, "smith", "sword"); err == nil || !strings.Contains(err.Error(), "cannot afford") {
+		t.Errorf("Expected the sword to be unaffordable, got %v", err)
+	}
+	if gold, _ := cm.GetGold(sessionID); gold != 50 {
+		t.Errorf("Expected a rejected purchase to leave 50 gold, got %d", gold)
+	}
+
+	// A devoted smith takes 20% off
+	cm.UpdateNPCRelationship(sessionID, "smith", "Brom", 100, nil)
+	items, _ := cm.GetShopItems(sessionID, "smith")
+	if len(items) != 2 || items[0].Price != 40 || items[0].Item.Quantity != 1 {
+		t.Errorf("Expected the last potion at a discounted 40 gold, got %+v", items)
+	}
+	if err := cm.BuyItem(sessionID, "smith", "potion"); err != nil {
+		t.Fatalf("Failed to buy discounted potion: %v", err)
+	}
+	if gold, _ := cm.GetGold(sessionID); gold != 10 {
+		t.Errorf("Expected 10 gold after a discounted purchase, got %d", gold)
+	}
+	if err := cm.BuyItem(sessionID, "smith", "potion"); err == nil {
+		t.Error("Expected a sold-out item to be rejected")
+	}
+
+	// Selling back pays half the price, plus 20% from a devoted smith
+	if err := cm.SellItem(sessionID, "smith", "potion"); err != nil {
+		t.Fatalf("Failed to sell potion: %v", err)
+	}
+	if gold, _ := cm.GetGold(sessionID); gold != 40 {
+		t.Errorf("Expected 30 gold for the potion, leaving 40, got %d", gold)
+	}
+	if inventory, _ := cm.GetInventory(sessionID); inventory[0].Quantity != 1 {
+		t.Errorf("Expected one potion left after selling, got %+v", inventory)
+	}
+}
+
+func TestContextManager_StepDialogue(t *testing.T) {
+	cm := NewContextManager(NewMemoryStorage())
+	defer cm.Shutdown()
+
+	err := cm.RegisterDialogueTree("elder", DialogueTree{
+		NPCName: "Elder Rowan",
+		Start:   "greeting",
+		Nodes: map[string]DialogueNode{
+			"greeting": {ID: "greeting", Text: "Wolves have taken our sheep. Will you help?", Options: []DialogueOption{
+				{ID: "accept", Text: "I'll hunt them down.", Next: "thanks", Consequences: []string{"reputation_increase"}, Facts: []string{"Agreed to hunt the wolves"}, DispositionChange: 10},
+				{ID: "refuse", Text: "Not my problem.", Next: "thanks"},
+			}},
+			"thanks": {ID: "thanks", Text: "Bless you, traveler."},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Failed to register dialogue tree: %v", err)
+	}
+	if err := cm.RegisterDialogueTree("elder", DialogueTree{Start: "missing"}); err == nil {
+		t.Error("Expected a tree without its start node to be rejected")
+	}
+
+	sessionID, _ := cm.CreateSession("player123", "TestHero")
+	node, err := cm.StepDialogue(sessionID, "elder", "")
+	if err != nil || node.ID != "greeting" || len(node.Options) != 2 {
+		t.Fatalf("Expected the greeting with two options, got %+v, %v", node, err)
+	}
+	if _, err := cm.StepDialogue(sessionID, "elder", "bargain"); err == nil {
+		t.Error("Expected an unknown option to be rejected")
+	}
+
+	node, err = cm.StepDialogue(sessionID, "elder", "accept")
+	if err != nil || node.ID != "thanks" {
+		t.Fatalf("Expected accepting to lead to thanks, got %+v, %v", node, err)
+	}
+	cm.WaitForEvents()
+
+	ctx, _ := cm.GetContext(sessionID)
+	if ctx.Character.Reputation != 5 {
+		t.Errorf("Expected the option's reputation_increase to apply, got reputation %d", ctx.Character.Reputation)
+	}
+	elder := ctx.NPCStates["elder"]
+	if elder.Name != "Elder Rowan" || elder.Disposition != 10 || !knowsFact(elder.KnownFacts, "Agreed to hunt the wolves") {
+		t.Errorf("Expected the elder to warm to the player and remember the promise, got %+v", elder)
+	}
+	if actions, _ := cm.GetRecentActions(sessionID, 1); len(actions) != 1 || actions[0].Type != "dialogue" || actions[0].Target != "elder" {
+		t.Errorf("Expected the reply recorded as dialogue with the elder, got %+v", actions)
+	}
+
+	// The conversation ended, so the next one starts over
+	if node, _ := cm.StepDialogue(sessionID, "elder", ""); node.ID != "greeting" {
+		t.Errorf("Expected a finished conversation to restart at the greeting, got %q", node.ID)
+	}
+
+	// NPCs without a tree fall back to the AI
+	if _, err := cm.StepDialogue(sessionID, "innkeeper", ""); !errors.Is(err, ErrNoDialogueTree) {
+		t.Errorf("Expected ErrNoDialogueTree, got %v", err)
+	}
+	cm.SetDialogueGenerator(&fakeDialogueGenerator{})
+	cm.UpdateNPCRelationship(sessionID, "innkeeper", "Mara", 0, nil)
+	if reply, err := cm.TalkToNPC(sessionID, "innkeeper", "Hello"); err != nil || reply != "Welcome back, friend." {
+		t.Errorf("Expected the AI to voice the innkeeper, got %q, %v", reply, err)
+	}
+	if reply, _ := cm.TalkToNPC(sessionID, "elder", ""); !strings.Contains(reply, "[accept] I'll hunt them down.") {
+		t.Errorf("Expected the elder's scripted options, got %q", reply)
+	}
+}
+
+// fakeFactExtractor returns a fixed fact list and records what the NPC
+// already knew
+type fakeFactExtractor struct {
+	mu    sync.Mutex
+	facts []string
+	known []string
+}
+
+func (f *fakeFactExtractor) ExtractNPCFactsForSession(sessionID, npcName string, knownFacts []string, exchange string) ([]string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.known = knownFacts
+	return f.facts, nil
+}
+
+func TestContextManager_ExtractNPCFacts(t *testing.T) {
+	cm := NewContextManagerWithConfig(NewMemoryStorage(), config.ContextConfig{ExtractNPCFacts: true})
+	defer cm.Shutdown()
+
+	sessionID, _ := cm.CreateSession("player123", "TestHero")
+	cm.UpdateNPCRelationship(sessionID, "innkeeper", "Mara", 10, []string{"Paid for a room in advance"})
+
+	extractor := &fakeFactExtractor{facts: []string{"paid for a room in advance.", "Is looking for their brother", "Is looking for their brother"}}
+	cm.SetFactExtractor(extractor)
+
+	added, err := cm.ExtractNPCFacts(sessionID, "innkeeper", "Player: Have you seen my brother?\nMara: Not lately.")
+	if err != nil {
+		t.Fatalf("Failed to extract facts: %v", err)
+	}
+	if len(added) != 1 || added[0] != "Is looking for their brother" {
+		t.Errorf("Expected only the new fact to be added, got %q", added)
+	}
+	if len(extractor.known) != 1 || extractor.known[0] != "Paid for a room in advance" {
+		t.Errorf("Expected the extractor to be told the known facts, got %q", extractor.known)
+	}
+
+	ctx, _ := cm.GetContext(sessionID)
+	if facts := ctx.NPCStates["innkeeper"].KnownFacts; len(facts) != 2 {
+		t.Errorf("Expected 2 known facts after dedup, got %+v", facts)
+	}
+
+	// With extraction enabled, a GM reply to an action aimed at the NPC is
+	// mined for facts in the background
+	extractor.mu.Lock()
+	extractor.facts = []string{"Tipped generously"}
+	extractor.mu.Unlock()
+	cm.RecordAction(sessionID, "/tip Mara", "social", "innkeeper", "tavern", "Mara beams at the silver coin.", nil)
+	cm.WaitForEvents()
+	cm.aiTasks.Wait()
+
+	ctx, _ = cm.GetContext(sessionID)
+	if !knowsFact(ctx.NPCStates["innkeeper"].KnownFacts, "Tipped generously") {
+		t.Errorf("Expected the tip to be remembered, got %+v", ctx.NPCStates["innkeeper"].KnownFacts)
+	}
+
+	if _, err := cm.ExtractNPCFacts(sessionID, "stranger", "Player: Hi"); err == nil {
+		t.Error("Expected an error for an NPC the player hasn't met")
+	}
+}
+
+// fakeCommandInterpreter answers from a fixed phrase table, counting calls
+// and recording the last grounding it was given
+type fakeCommandInterpreter struct {
+	mu              sync.Mutex
+	interpretations map[string]CommandInterpretation
+	calls           int
+	grounding       CommandGrounding
+}
+
+func (f *fakeCommandInterpreter) InterpretCommandForSession(sessionID, freeText string, grounding CommandGrounding) (CommandInterpretation, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.calls++
+	f.grounding = grounding
+	interpretation, ok := f.interpretations[freeText]
+	if !ok {
+		return CommandInterpretation{}, fmt.Errorf("no interpretation for %q", freeText)
+	}
+	return interpretation, nil
+}
+
+func TestContextManager_InterpretCommand(t *testing.T) {
+	cm := NewContextManager(NewMemoryStorage())
+	defer cm.Shutdown()
+
+	sessionID, _ := cm.CreateSession("player123", "TestHero")
+	cm.UpdateNPCRelationship(sessionID, "tavern_keeper", "Marcus", 5, nil)
+
+	// Without an interpreter free text is parsed as typed
+	parsed, err := cm.InterpretCommand(sessionID, "chat with the barkeep")
+	if err != nil {
+		t.Fatalf("Failed to interpret command: %v", err)
+	}
+	if parsed.ActionType != "unknown" {
+		t.Errorf("Expected unknown without an interpreter, got %+v", parsed)
+	}
+
+	interpreter := &fakeCommandInterpreter{interpretations: map[string]CommandInterpretation{
+		"talk to the barkeep": {Verb: "talk", ActionType: "social", Target: "tavern_keeper"},
+		"juggle the mugs":     {Verb: "juggle", ActionType: "performance", Target: "mugs"},
+	}}
+	cm.SetCommandInterpreter(interpreter)
+
+	parsed, _ = cm.InterpretCommand(sessionID, "talk to the barkeep")
+	if parsed.ActionType != "social" || parsed.Target != "tavern_keeper" {
+		t.Errorf("Expected social/tavern_keeper, got %+v", parsed)
+	}
+	if len(parsed.Consequences) != 2 || parsed.Consequences[0] != "social_success" {
+		t.Errorf("Expected the talk verb's consequences, got %q", parsed.Consequences)
+	}
+	if interpreter.grounding.NPCs["tavern_keeper"] != "Marcus" {
+		t.Errorf("Expected met NPCs in the grounding, got %+v", interpreter.grounding.NPCs)
+	}
+	if interpreter.grounding.Location != "starting_village" {
+		t.Errorf("Expected the current location in the grounding, got %q", interpreter.grounding.Location)
+	}
+
+	// The same phrase with different case and punctuation is served from the cache
+	parsed, _ = cm.InterpretCommand(sessionID, "  Talk to the   barkeep!")
+	if parsed.Target != "tavern_keeper" || interpreter.calls != 1 {
+		t.Errorf("Expected a cache hit, got %+v after %d calls", parsed, interpreter.calls)
+	}
+
+	// Another session, or the same one after meeting someone new, may mean
+	// something else by the same words
+	otherID, _ := cm.CreateSession("player456", "OtherHero")
+	cm.InterpretCommand(otherID, "talk to the barkeep")
+	if interpreter.calls != 2 {
+		t.Errorf("Expected another session to be interpreted afresh, got %d calls", interpreter.calls)
+	}
+	cm.UpdateNPCRelationship(sessionID, "barmaid", "Elsa", 5, nil)
+	cm.InterpretCommand(sessionID, "talk to the barkeep")
+	if interpreter.calls != 3 {
+		t.Errorf("Expected a changed grounding to be interpreted afresh, got %d calls", interpreter.calls)
+	}
+
+	// Slash commands never reach the interpreter
+	parsed, _ = cm.InterpretCommand(sessionID, "/look")
+	if parsed.ActionType != "examine" || interpreter.calls != 3 {
+		t.Errorf("Expected /look to be parsed directly, got %+v after %d calls", parsed, interpreter.calls)
+	}
+
+	// A verb the parser doesn't know keeps the AI's action type
+	parsed, _ = cm.InterpretCommand(sessionID, "juggle the mugs")
+	if parsed.ActionType != "performance" || parsed.Target != "mugs" || len(parsed.Consequences) != 0 {
+		t.Errorf("Expected performance/mugs with no consequences, got %+v", parsed)
+	}
+
+	// A failed interpretation degrades to unknown rather than an error
+	parsed, err = cm.InterpretCommand(sessionID, "sing a ballad")
+	if err != nil || parsed.ActionType != "unknown" {
+		t.Errorf("Expected unknown without error, got %+v, %v", parsed, err)
+	}
+}
+
+func TestContextManager_RecordActionIdempotent(t *testing.T) {
+	clock := newTestClock()
+	cm := NewContextManagerWithConfig(NewMemoryStorage(), config.ContextConfig{IdempotencyKeyTTL: time.Hour, IdempotencyKeys: 2})
+	defer cm.Shutdown()
+	cm.SetNowFunc(clock.Now)
+
+	sessionID, _ := cm.CreateSession("player123", "TestHero")
+	otherID, _ := cm.CreateSession("player456", "OtherHero")
+
+	record := func(sessionID, key string) bool {
+		t.Helper()
+		applied, err := cm.RecordActionIdempotent(sessionID, key, "/look", "examine", "", "tavern", "", nil)
+		if err != nil {
+			t.Fatalf("Failed to record action: %v", err)
+		}
+		return applied
+	}
+
+	if !record(sessionID, "key-1") {
+		t.Error("Expected the first submission to be applied")
+	}
+	if record(sessionID, "key-1") {
+		t.Error("Expected the replay to be ignored")
+	}
+	if !cm.IdempotencyKeySeen(sessionID, "key-1") {
+		t.Error("Expected the key to be remembered")
+	}
+
+	// Keys are scoped to their session
+	if !record(otherID, "key-1") {
+		t.Error("Expected the same key in another session to be applied")
+	}
+
+	cm.WaitForEvents()
+	actions, _ := cm.GetRecentActions(sessionID, 10)
+	if len(actions) != 1 {
+		t.Errorf("Expected 1 action to be recorded, got %d", len(actions))
+	}
+
+	// Once full, the least recently seen key is forgotten
+	record(sessionID, "key-2")
+	if cm.IdempotencyKeySeen(sessionID, "key-1") {
+		t.Error("Expected the oldest key to be evicted")
+	}
+
+	// Keys expire after the TTL
+	clock.Advance(time.Hour)
+	if cm.IdempotencyKeySeen(sessionID, "key-2") {
+		t.Error("Expected the key to expire")
+	}
+	if !record(sessionID, "key-2") {
+		t.Error("Expected an expired key to be applied again")
+	}
+}
+
+func TestContextManager_ClaimIdempotencyKey(t *testing.T) {
+	cm := NewContextManager(NewMemoryStorage())
+	defer cm.Shutdown()
+
+	sessionID, _ := cm.CreateSession("player123", "TestHero")
+
+	if !cm.ClaimIdempotencyKey(sessionID, "key-1") {
+		t.Fatal("Expected a new key to be claimed")
+	}
+	if cm.ClaimIdempotencyKey(sessionID, "key-1") {
+		t.Error("Expected a request in progress to hold its key")
+	}
+	if applied, _ := cm.RecordActionIdempotent(sessionID, "key-1", "/look", "examine", "", "tavern", "", nil); applied {
+		t.Error("Expected a claimed key not to record again")
+	}
+
+	// A failed request hands its key back for the retry
+	cm.ReleaseIdempotencyKey(sessionID, "key-1")
+	if !cm.ClaimIdempotencyKey(sessionID, "key-1") {
+		t.Error("Expected a released key to be claimed again")
+	}
+
+	if !cm.ClaimIdempotencyKey(sessionID, "") || !cm.ClaimIdempotencyKey(sessionID, "") {
+		t.Error("Expected requests without a key always to go ahead")
+	}
+}
+
+func TestContextManager_SessionExpiryEvents(t *testing.T) {
+	cm := NewContextManagerWithConfig(NewMemoryStorage(), config.ContextConfig{
+		SessionIdleTTL:       200 * time.Millisecond,
+		SessionExpiryWarning: 100 * time.Millisecond,
+	})
+	defer cm.Shutdown()
+
+	sessionID, _ := cm.CreateSession("player123", "TestPlayer")
+	changes, unsubscribe := cm.Subscribe(sessionID)
+	defer unsubscribe()
+
+	cm.RecordAction(sessionID, "/look", "look", "", "village", "Quiet.", []string{})
+	if change := nextChange(t, changes); change.Type != ChangeActionRecorded {
+		t.Fatalf("Expected the action first, got %+v", change)
+	}
+	ctx, _ := cm.GetContext(sessionID)
+	if ctx.ExpiresAt.IsZero() {
+		t.Fatal("Expected the action to set an expiry")
+	}
+
+	// Left idle, the player is warned and then the session expires
+	warning := nextChange(t, changes)
+	if warning.Type != ChangeSessionExpiring || warning.ExpiresAt == nil || !warning.ExpiresAt.Equal(ctx.ExpiresAt) {
+		t.Fatalf("Expected session_expiring for %v, got %+v", ctx.ExpiresAt, warning)
+	}
+	expired := nextChange(t, changes)
+	if expired.Type != ChangeSessionExpired {
+		t.Fatalf("Expected session_expired after the warning, got %+v", expired)
+	}
+
+	if cm.IsSessionActive(sessionID) {
+		t.Error("Expected an expired session to be inactive")
+	}
+	for _, active := range cm.GetActiveSessions() {
+		if active == sessionID {
+			t.Error("Expected the expired session to be evicted")
+		}
+	}
+}
+
+func TestContextManager_SessionExpiryBeforeSweep(t *testing.T) {
+	// A long TTL keeps the sweeper from running during the test
+	cm := NewContextManagerWithConfig(NewMemoryStorage(), config.ContextConfig{
+		SessionIdleTTL:       time.Hour,
+		SessionExpiryWarning: 10 * time.Minute,
+	})
+	defer cm.Shutdown()
+	clock := newTestClock()
+	cm.SetNowFunc(clock.Now)
+
+	sessionID, _ := cm.CreateSession("player123", "TestPlayer")
+	changes, unsubscribe := cm.Subscribe(sessionID)
+	defer unsubscribe()
+
+	// Activity pushes the expiry back
+	clock.Advance(50 * time.Minute)
+	cm.RecordAction(sessionID, "/look", "look", "", "village", "Quiet.", []string{})
+	cm.WaitForEvents()
+	nextChange(t, changes)
+	clock.Advance(50 * time.Minute)
+	if !cm.IsSessionActive(sessionID) {
+		t.Fatal("Expected a recent action to keep the session active")
+	}
+
+	clock.Advance(11 * time.Minute)
+	if cm.IsSessionActive(sessionID) {
+		t.Error("Expected a session past its expiry to be inactive before it is swept")
+	}
+
+	cm.sweepExpiredSessions(clock.Now())
+	if change := nextChange(t, changes); change.Type != ChangeSessionExpired {
+		t.Errorf("Expected the sweep to expire the session, got %+v", change)
+	}
+}
+
+func TestContextManager_ListSessions(t *testing.T) {
+	cm := NewContextManager(NewMemoryStorage())
+	defer cm.Shutdown()
+	clock := newTestClock()
+	cm.SetNowFunc(clock.Now)
+
+	// Five sessions, a minute apart; the odd ones travel to the forest
+	var sessionIDs []string
+	for i := 0; i < 5; i++ {
+		sessionID, _ := cm.CreateSession(fmt.Sprintf("player%d", i), fmt.Sprintf("Player%d", i))
+		if i%2 == 1 {
+			cm.UpdateLocation(sessionID, "forest")
+		}
+		sessionIDs = append(sessionIDs, sessionID)
+		clock.Advance(time.Minute)
+	}
+
+	sessions, total, err := cm.ListSessions(SessionFilter{Location: "forest"}, 1, 10)
+	if err != nil {
+		t.Fatalf("ListSessions failed: %v", err)
+	}
+	if total != 2 || len(sessions) != 2 {
+		t.Fatalf("Expected 2 sessions in the forest, got %d of %d", len(sessions), total)
+	}
+	// Most recently updated first
+	if sessions[0].SessionID != sessionIDs[3] || sessions[1].SessionID != sessionIDs[1] {
+		t.Errorf("Expected the forest sessions newest first, got %+v", sessions)
+	}
+	if sessions[0].Name != "Player3" || sessions[0].Location != "forest" {
+		t.Errorf("Expected session info for Player3 in the forest, got %+v", sessions[0])
+	}
+
+	sessions, total, _ = cm.ListSessions(SessionFilter{}, 2, 2)
+	if total != 5 || len(sessions) != 2 {
+		t.Fatalf("Expected the second page of 2 from 5 sessions, got %d of %d", len(sessions), total)
+	}
+	if sessions[0].SessionID != sessionIDs[2] || sessions[1].SessionID != sessionIDs[1] {
+		t.Errorf("Expected the second page to hold sessions 2 and 1, got %+v", sessions)
+	}
+	if sessions, _, _ := cm.ListSessions(SessionFilter{}, 3, 2); len(sessions) != 1 {
+		t.Errorf("Expected the last page to hold 1 session, got %d", len(sessions))
+	}
+	if sessions, _, _ := cm.ListSessions(SessionFilter{}, 4, 2); len(sessions) != 0 {
+		t.Errorf("Expected a page past the end to be empty, got %d", len(sessions))
+	}
+
+	cm.UpdateReputation(sessionIDs[0], 30)
+	cm.WaitForEvents()
+	minReputation := 20
+	if sessions, _, _ := cm.ListSessions(SessionFilter{MinReputation: &minReputation}, 1, 10); len(sessions) != 1 || sessions[0].SessionID != sessionIDs[0] {
+		t.Errorf("Expected only the reputable session, got %+v", sessions)
+	}
+	if sessions, _, _ := cm.ListSessions(SessionFilter{PlayerID: "player4"}, 1, 10); len(sessions) != 1 || sessions[0].SessionID != sessionIDs[4] {
+		t.Errorf("Expected only player4's session, got %+v", sessions)
+	}
+	if _, total, _ := cm.ListSessions(SessionFilter{ActiveWithin: 150 * time.Second}, 1, 10); total != 3 {
+		t.Errorf("Expected 3 sessions active within the last 150s, got %d", total)
+	}
+
+	if _, _, err := cm.ListSessions(SessionFilter{}, 0, 10); err == nil {
+		t.Error("Expected page 0 to be rejected")
+	}
+	if _, _, err := cm.ListSessions(SessionFilter{}, 1, 0); err == nil {
+		t.Error("Expected an empty page size to be rejected")
+	}
+}
+
+func TestContextManager_Weather(t *testing.T) {
+	cm := NewContextManager(NewMemoryStorage())
+	defer cm.Shutdown()
+	cm.SetNowFunc(newTestClock().Now)
+
+	sessionID, _ := cm.CreateSession("player123", "TestPlayer")
+	if weather, _ := cm.GetWeather(sessionID); weather != WeatherClear {
+		t.Errorf("Expected new sessions to start in clear weather, got %s", weather)
+	}
+	if err := cm.SetWeather(sessionID, "hail"); err == nil {
+		t.Error("Expected unknown weather to be rejected")
+	}
+
+	if err := cm.SetWeather(sessionID, WeatherStorm); err != nil {
+		t.Fatalf("Failed to set weather: %v", err)
+	}
+	prompt, _ := cm.GenerateAIPrompt(sessionID)
+	if !strings.Contains(prompt, "- Weather: storm (checks: -2 dexterity, -1 intelligence)") {
+		t.Errorf("Expected the storm in the prompt, got:\n%s", prompt)
+	}
+	if scene, _ := cm.SceneContext(sessionID); !strings.Contains(scene, "Weather: storm") {
+		t.Errorf("Expected the storm in the scene context, got %q", scene)
+	}
+
+	// Scripted weather holds until a full interval has passed
+	cm.AdvanceGameTime(sessionID, 60)
+	if weather, _ := cm.GetWeather(sessionID); weather != WeatherStorm {
+		t.Errorf("Expected the storm to last, got %s", weather)
+	}
+
+	// The storm throws off dexterity checks
+	cm.SetDiceSource(rand.NewSource(1))
+	_, roll, total, err := cm.AttributeCheck(sessionID, "dexterity", 10)
+	if err != nil {
+		t.Fatalf("Failed to make check: %v", err)
+	}
+	ctx, _ := cm.GetContext(sessionID)
+	if want := combat.Modifier(ctx.Character.Attributes["dexterity"]) - 2; total-roll != want {
+		t.Errorf("Expected a modifier of %d in the storm, got %d", want, total-roll)
+	}
+}
+
+func TestContextManager_WeatherTransitionsAreReproducible(t *testing.T) {
+	forecast := func(seed int64) []Weather {
+		cm := NewContextManager(NewMemoryStorage())
+		defer cm.Shutdown()
+		cm.SetNowFunc(newTestClock().Now)
+		cm.SetWeatherSource(rand.NewSource(seed))
+
+		worldMap := NewWorldMap()
+		worldMap.AddLocation("starting_village", "Starting Village")
+		if err := worldMap.SetBiome("starting_village", BiomeMountain); err != nil {
+			t.Fatalf("Failed to set biome: %v", err)
+		}
+		cm.SetWorldMap(worldMap)
+
+		sessionID, _ := cm.CreateSession("player123", "TestPlayer")
+		var weather []Weather
+		for i := 0; i < 12; i++ {
+			cm.AdvanceGameTime(sessionID, int(weatherChangeInterval.Minutes()))
+			w, _ := cm.GetWeather(sessionID)
+			weather = append(weather, w)
+		}
+		return weather
+	}
+
+	first, second := forecast(42), forecast(42)
+	seen := make(map[Weather]bool)
+	for i := range first {
+		if first[i] != second[i] {
+			t.Fatalf("Expected the same forecast for the same seed, got %v and %v", first, second)
+		}
+		if !first[i].IsValid() {
+			t.Errorf("Unexpected weather %q", first[i])
+		}
+		seen[first[i]] = true
+	}
+	if len(seen) < 2 {
+		t.Errorf("Expected the weather to change over two days, got %v", first)
+	}
+}
+
+// steadySource always draws the lowest roll, so the weather settles on the
+// first kind in the biome's table
+type steadySource struct{}
+
+func (steadySource) Int63() int64 { return 0 }
+func (steadySource) Seed(int64)   {}
+
+func TestContextManager_WeatherTickMarksOnlyChanges(t *testing.T) {
+	cm := NewContextManager(NewMemoryStorage())
+	defer cm.Shutdown()
+	clock := newTestClock()
+	cm.SetNowFunc(clock.Now)
+	cm.SetWeatherSource(steadySource{})
+
+	sessionID, _ := cm.CreateSession("player123", "TestPlayer")
+	if err := cm.SetWeather(sessionID, WeatherStorm); err != nil {
+		t.Fatalf("Failed to set weather: %v", err)
+	}
+	cm.FlushAll()
+
+	// The storm breaks once a full interval of game time has passed
+	realInterval := time.Duration(float64(weatherChangeInterval)/DefaultGameTimeScale) + time.Minute
+	clock.Advance(realInterval)
+	cm.tickStatusEffects(clock.Now())
+	if weather, _ := cm.GetWeather(sessionID); weather != WeatherClear {
+		t.Fatalf("Expected the storm to clear, got %s", weather)
+	}
+	if _, dirty := cm.dirty.Load(sessionID); !dirty {
+		t.Error("Expected new weather to mark the session changed")
+	}
+
+	// Rolling the same weather again changes nothing worth saving
+	cm.FlushAll()
+	clock.Advance(realInterval)
+	cm.tickStatusEffects(clock.Now())
+	if _, dirty := cm.dirty.Load(sessionID); dirty {
+		t.Error("Expected unchanged weather not to mark the session changed")
+	}
+}
+
+func TestContextManager_RandomSourcesAndMapSwapDuringTicks(t *testing.T) {
+	cm := NewContextManagerWithConfig(NewMemoryStorage(), config.ContextConfig{StatusTick: time.Millisecond})
+	defer cm.Shutdown()
+	cm.SetGameTimeScale(1e7)
+
+	sessionID, _ := cm.CreateSession("player123", "TestPlayer")
+
+	// Run with -race: the status ticker rolls weather against the map and
+	// dice being replaced
+	for i := int64(0); i < 20; i++ {
+		worldMap := NewWorldMap()
+		worldMap.AddLocation("starting_village", "Starting Village")
+		cm.SetWorldMap(worldMap)
+		cm.SetWeatherSource(rand.NewSource(i))
+		cm.SetDiceSource(rand.NewSource(i))
+		cm.AttributeCheck(sessionID, "strength", 10)
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestContextManager_SessionIDs(t *testing.T) {
+	storage := NewMemoryStorage()
+	cm := NewContextManager(storage)
+	defer cm.Shutdown()
+
+	next := 0
+	cm.SetIDGenerator(func() string {
+		next++
+		return fmt.Sprintf("session-%d", next)
+	})
+
+	for _, want := range []string{"session-1", "session-2"} {
+		sessionID, err := cm.CreateSession("player123", "TestPlayer")
+		if err != nil {
+			t.Fatalf("Failed to create session: %v", err)
+		}
+		if sessionID != want {
+			t.Errorf("Expected session ID %s, got %s", want, sessionID)
+		}
+	}
+
+	if err := cm.CreateSessionWithID("player456", "OtherPlayer", "token-abc"); err != nil {
+		t.Fatalf("Failed to create session with ID: %v", err)
+	}
+	ctx, err := cm.GetContext("token-abc")
+	if err != nil || ctx.PlayerID != "player456" || ctx.SessionID != "token-abc" {
+		t.Errorf("Expected the session under the chosen ID, got %+v (%v)", ctx, err)
+	}
+
+	// Taken IDs are rejected, whether chosen or generated
+	if err := cm.CreateSessionWithID("player789", "Intruder", "session-1"); !errors.Is(err, ErrSessionExists) {
+		t.Errorf("Expected ErrSessionExists for a taken ID, got %v", err)
+	}
+	next = 0
+	if _, err := cm.CreateSession("player789", "Intruder"); !errors.Is(err, ErrSessionExists) {
+		t.Errorf("Expected ErrSessionExists when the generator repeats an ID, got %v", err)
+	}
+	if ctx, _ := cm.GetContext("session-1"); ctx.PlayerID != "player123" {
+		t.Errorf("Expected the original session to be untouched, got player %s", ctx.PlayerID)
+	}
+
+	// Sessions only in storage count too
+	other := NewContextManager(storage)
+	defer other.Shutdown()
+	if err := other.CreateSessionWithID("player789", "Intruder", "token-abc"); !errors.Is(err, ErrSessionExists) {
+		t.Errorf("Expected ErrSessionExists for a stored session, got %v", err)
+	}
+}
+
+// unreachableStorage fails every load as if the backend were down
+type unreachableStorage struct {
+	*MemoryContextStorage
+}
+
+func (s *unreachableStorage) LoadContext(sessionID string) (*PlayerContext, error) {
+	return nil, errors.New("connection refused")
+}
+
+func TestContextManager_SessionIDsUnverifiable(t *testing.T) {
+	storage := &unreachableStorage{MemoryContextStorage: NewMemoryStorage()}
+	cm := NewContextManager(storage)
+	defer cm.Shutdown()
+
+	// Storage can't say whether the ID is free, so it isn't taken over
+	err := cm.CreateSessionWithID("player123", "TestPlayer", "token-abc")
+	if err == nil || errors.Is(err, ErrSessionExists) {
+		t.Fatalf("Expected the storage error, got %v", err)
+	}
+	if _, cached := cm.cache.Load("token-abc"); cached {
+		t.Error("Expected no session to be created")
+	}
+}
+
+func TestContextManager_SetIDGeneratorWhileCreating(t *testing.T) {
+	storage := NewMemoryStorage()
+	cm := NewContextManager(storage)
+	defer cm.Shutdown()
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 50; i++ {
+			cm.CreateSession("player123", "TestPlayer")
+		}
+	}()
+	for i := 0; i < 50; i++ {
+		cm.SetIDGenerator(newSessionID)
+	}
+	wg.Wait()
+}
+
+func TestContextManager_ReputationGates(t *testing.T) {
+	cm := NewContextManager(NewMemoryStorage())
+	defer cm.Shutdown()
+	generator := &fakeDialogueGenerator{}
+	cm.SetDialogueGenerator(generator)
+	cm.RegisterShop("merchant", Shop{Items: []ShopItem{
+		{Item: InventoryItem{ID: "potion", Name: "Healing Potion", Type: "potion", Quantity: 5}, Price: 50},
+	}})
+
+	if err := cm.SetReputationGates(10, -10); err == nil {
+		t.Error("Expected a shunned gate above the honored one to be rejected")
+	}
+
+	sessionID, _ := cm.CreateSession("player123", "TestHero")
+	cm.AddGold(sessionID, 200, false)
+	cm.UpdateNPCRelationship(sessionID, "merchant", "Mira the Merchant", 0, nil)
+
+	// A villain is turned away, in dialogue, in trade and in the prompt
+	cm.UpdateReputation(sessionID, -60)
+	reply, err := cm.GenerateNPCDialogueInContext(sessionID, "merchant", "Hello")
+	if err != nil || reply != "Mira the Merchant refuses to speak to you." {
+		t.Errorf("Expected the merchant to refuse to speak, got %q (%v)", reply, err)
+	}
+	if generator.npcName != "" {
+		t.Error("Expected no AI call for a refusal")
+	}
+	if err := cm.BuyItem(sessionID, "merchant", "potion"); err == nil {
+		t.Error("Expected trading with a shunned player to be blocked")
+	}
+	if err := cm.ValidateAction(sessionID, "/talk merchant"); err == nil {
+		t.Error("Expected talking to the merchant to be rejected")
+	}
+	prompt, _ := cm.GenerateAIPrompt(sessionID)
+	if !strings.Contains(prompt, "refuses to speak or trade with the player") {
+		t.Errorf("Expected the refusal in the prompt, got:\n%s", prompt)
+	}
+
+	// An NPC who hates the player attacks on sight instead
+	cm.UpdateNPCRelationship(sessionID, "bandit", "Grim the Bandit", -80, nil)
+	if reply, _ := cm.GenerateNPCDialogueInContext(sessionID, "bandit", "Hello"); reply != "Grim the Bandit attacks you on sight!" {
+		t.Errorf("Expected the bandit to attack, got %q", reply)
+	}
+
+	// A hero is welcomed by the same merchant, with a discount
+	cm.UpdateReputation(sessionID, 120)
+	if _, err := cm.GenerateNPCDialogueInContext(sessionID, "merchant", "Hello"); err != nil {
+		t.Fatalf("Failed to talk to the merchant: %v", err)
+	}
+	if !strings.Contains(generator.personality, "greet them warmly") {
+		t.Errorf("Expected the merchant to greet the hero warmly, got %q", generator.personality)
+	}
+	items, err := cm.GetShopItems(sessionID, "merchant")
+	if err != nil || len(items) != 1 || items[0].Price != 45 {
+		t.Errorf("Expected a 10%% discount on the potion, got %+v (%v)", items, err)
+	}
+	if err := cm.ValidateAction(sessionID, "/talk merchant"); err != nil {
+		t.Errorf("Expected the hero to be allowed to talk, got %v", err)
+	}
+	prompt, _ = cm.GenerateAIPrompt(sessionID)
+	if !strings.Contains(prompt, "greets the player warmly and offers help and discounts") {
+		t.Errorf("Expected the welcome in the prompt, got:\n%s", prompt)
+	}
+}
+
+func TestContextManager_CombatEncounter(t *testing.T) {
+	fight := func(t *testing.T, enemy Enemy) (*ContextManager, string, []CombatTurnResult) {
+		cm := NewContextManager(NewMemoryStorage())
+		t.Cleanup(cm.Shutdown)
+		cm.SetDiceSource(rand.NewSource(3))
+
+		sessionID, _ := cm.CreateSession("player123", "TestPlayer")
+		if _, err := cm.CombatTurn(sessionID, CombatActionAttack); !errors.Is(err, ErrNoCombat) {
+			t.Fatalf("Expected ErrNoCombat before the fight, got %v", err)
+		}
+		if err := cm.BeginCombat(sessionID, enemy); err != nil {
+			t.Fatalf("Failed to begin combat: %v", err)
+		}
+		if err := cm.BeginCombat(sessionID, enemy); err == nil {
+			t.Error("Expected a second fight to be refused while one is on")
+		}
+
+		prompt, _ := cm.GenerateAIPrompt(sessionID)
+		if !strings.Contains(prompt, fmt.Sprintf("- In Combat: fighting %s (ID: %s), turn 0, enemy health %d/%d", enemy.Name, enemy.ID, enemy.MaxHealth, enemy.MaxHealth)) {
+			t.Errorf("Expected the fight in the prompt, got:\n%s", prompt)
+		}
+
+		var turns []CombatTurnResult
+		for i := 0; i < 50; i++ {
+			result, err := cm.CombatTurn(sessionID, CombatActionAttack)
+			if err != nil {
+				t.Fatalf("Turn %d failed: %v", i+1, err)
+			}
+			turns = append(turns, result)
+			if result.Status != CombatOngoing {
+				break
+			}
+		}
+		cm.WaitForEvents()
+		return cm, sessionID, turns
+	}
+
+	t.Run("win", func(t *testing.T) {
+		// Only a natural 20 lets the rat land a blow, and then barely
+		cm, sessionID, turns := fight(t, Enemy{ID: "rat", Name: "Giant Rat", MaxHealth: 15, Stats: combat.CombatStats{AttackBonus: -20, Defense: 5, DamageDie: 1}})
+		last := turns[len(turns)-1]
+		if last.Status != CombatWon || last.EnemyHealth != 0 {
+			t.Fatalf("Expected the rat to be beaten, got %+v", last)
+		}
+		if len(turns) < 2 {
+			t.Errorf("Expected a fight over several turns, got %d", len(turns))
+		}
+		for i, turn := range turns[:len(turns)-1] {
+			if turn.Turn != i+1 || turn.EnemyAttack == nil {
+				t.Errorf("Expected the rat to strike back on turn %d, got %+v", i+1, turn)
+			}
+		}
+
+		ctx, _ := cm.GetContext(sessionID)
+		if ctx.Combat != nil || !ctx.Character.Alive {
+			t.Errorf("Expected the fight over and the player alive, got %+v", ctx.Combat)
+		}
+		if ctx.SessionStats.CombatVictories != 1 || len(ctx.Actions) != len(turns) {
+			t.Errorf("Expected every turn recorded and one victory, got %d actions and %d victories", len(ctx.Actions), ctx.SessionStats.CombatVictories)
+		}
+		if prompt, _ := cm.GenerateAIPrompt(sessionID); strings.Contains(prompt, "In Combat") {
+			t.Error("Expected the finished fight to leave the prompt")
+		}
+	})
+
+	t.Run("loss", func(t *testing.T) {
+		cm, sessionID, turns := fight(t, Enemy{ID: "troll", Name: "Cave Troll", MaxHealth: 200, Stats: combat.CombatStats{AttackBonus: 10, Defense: 30, DamageDie: 6, DamageBonus: 4}})
+		last := turns[len(turns)-1]
+		if last.Status != CombatLost || last.PlayerHealth != 0 {
+			t.Fatalf("Expected the troll to win, got %+v", last)
+		}
+
+		ctx, _ := cm.GetContext(sessionID)
+		if ctx.Character.Alive || ctx.Character.DeathCount != 1 || ctx.Combat != nil {
+			t.Errorf("Expected the player dead and the fight over, got alive=%v deaths=%d", ctx.Character.Alive, ctx.Character.DeathCount)
+		}
+		if _, err := cm.CombatTurn(sessionID, CombatActionAttack); !errors.Is(err, ErrPlayerDead) {
+			t.Errorf("Expected a dead player to be unable to fight, got %v", err)
+		}
+	})
+}
+
+func TestValidateContext(t *testing.T) {
+	storage := NewMemoryStorage()
+	cm := NewContextManager(storage)
+	defer cm.Shutdown()
+
+	sessionID, _ := cm.CreateSession("player123", "TestPlayer")
+	cm.UpdateLocation(sessionID, "thornwick_forest")
+	cm.UpdateLocation(sessionID, "dark_cave")
+	cm.UpdateNPCRelationship(sessionID, "blacksmith", "Gareth", 10, nil)
+	cm.AddInventoryItem(sessionID, InventoryItem{ID: "potion", Name: "Healing Potion", Quantity: 1})
+	cm.RecordAction(sessionID, "/look", "explore", "", "dark_cave", "It is dark", nil)
+	cm.waitForQueuedEvents()
+	valid, _ := cm.GetContext(sessionID)
+	if err := ValidateContext(valid); err != nil {
+		t.Fatalf("Expected a live context to be valid, got %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		field   string
+		corrupt func(ctx *PlayerContext)
+	}{
+		{"empty session ID", "session_id", func(ctx *PlayerContext) { ctx.SessionID = "" }},
+		{"negative health", "character.health.current", func(ctx *PlayerContext) { ctx.Character.Health.Current = -1 }},
+		{"health above max", "character.health.current", func(ctx *PlayerContext) { ctx.Character.Health.Current = ctx.Character.Health.Max + 1 }},
+		{"negative max health", "character.health.max", func(ctx *PlayerContext) { ctx.Character.Health.Max = -5 }},
+		{"mana above max", "character.mana.current", func(ctx *PlayerContext) { ctx.Character.Mana.Current = ctx.Character.Mana.Max + 1 }},
+		{"reputation too high", "character.reputation", func(ctx *PlayerContext) { ctx.Character.Reputation = 101 }},
+		{"faction reputation too low", "character.faction_reputation.thieves_guild", func(ctx *PlayerContext) {
+			ctx.Character.FactionReputation = map[string]int{"thieves_guild": -101}
+		}},
+		{"negative gold", "character.gold", func(ctx *PlayerContext) { ctx.Character.Gold = -1 }},
+		{"empty item ID", "character.inventory[0].id", func(ctx *PlayerContext) { ctx.Character.Inventory[0].ID = "" }},
+		{"previous location without a current one", "location.current", func(ctx *PlayerContext) { ctx.Location.Current = "" }},
+		{"previous location equal to the current one", "location.previous", func(ctx *PlayerContext) { ctx.Location.Previous = ctx.Location.Current }},
+		{"disposition out of range", "npc_states.blacksmith.disposition", func(ctx *PlayerContext) {
+			npc := ctx.NPCStates["blacksmith"]
+			npc.Disposition = -150
+			ctx.NPCStates["blacksmith"] = npc
+		}},
+		{"empty NPC ID", "npc_states.blacksmith.npc_id", func(ctx *PlayerContext) {
+			npc := ctx.NPCStates["blacksmith"]
+			npc.NPCID = ""
+			ctx.NPCStates["blacksmith"] = npc
+		}},
+		{"NPC stored under another ID", "npc_states.smith.npc_id", func(ctx *PlayerContext) {
+			ctx.NPCStates["smith"] = ctx.NPCStates["blacksmith"]
+		}},
+		{"empty action ID", "actions[0].id", func(ctx *PlayerContext) { ctx.Actions[0].ID = "" }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := valid.Clone()
+			tt.corrupt(ctx)
+
+			err := ValidateContext(ctx)
+			var invalid *InvalidContextError
+			if !errors.As(err, &invalid) {
+				t.Fatalf("Expected an *InvalidContextError, got %v", err)
+			}
+			if invalid.Field != tt.field {
+				t.Errorf("Expected the error to name %s, got %s (%v)", tt.field, invalid.Field, err)
+			}
+		})
+	}
+
+	if err := ValidateContext(nil); err == nil {
+		t.Error("Expected a nil context to be invalid")
+	}
+
+	// Several violations are all reported
+	broken := valid.Clone()
+	broken.Character.Gold = -1
+	broken.Character.Reputation = -200
+	if err := ValidateContext(broken); err == nil || !strings.Contains(err.Error(), "character.gold") || !strings.Contains(err.Error(), "character.reputation") {
+		t.Errorf("Expected both violations to be reported, got %v", err)
+	}
+
+	// A corrupted snapshot is refused and the live context kept
+	corrupted := valid.Clone()
+	corrupted.Character.Health.Current = 1000
+	storage.SaveSnapshot(&Snapshot{SnapshotInfo: SnapshotInfo{ID: "corrupted", SessionID: sessionID}, Context: corrupted})
+	if err := cm.RestoreSnapshot(sessionID, "corrupted"); err == nil {
+		t.Error("Expected a corrupted snapshot to be rejected")
+	}
+	if ctx, _ := cm.GetContext(sessionID); ctx.Character.Health.Current == 1000 {
+		t.Error("Expected a rejected snapshot to leave the live context alone")
+	}
+}
+
+func TestContextManager_RegisterConsequence(t *testing.T) {
+	storage := NewMemoryStorage()
+	cm := NewContextManager(storage)
+	defer cm.Shutdown()
+
+	var logs bytes.Buffer
+	cm.SetLogger(slog.New(slog.NewTextHandler(&logs, nil)))
+
+	cm.RegisterConsequence("curse_applied", func(ctx *PlayerContext, action ActionEvent) {
+		ctx.Character.Health.Max -= 5
+		ctx.Character.Health.Current = min(ctx.Character.Health.Current, ctx.Character.Health.Max)
+		ctx.Character.Reputation -= 3
+		if ctx.Character.Metadata == nil {
+			ctx.Character.Metadata = make(map[string]interface{})
+		}
+		ctx.Character.Metadata["curse"] = action.Metadata["curse"]
+	})
+
+	sessionID, _ := cm.CreateSession("player123", "TestPlayer")
+	before, _ := cm.GetContext(sessionID)
+
+	cm.RecordActionWithMetadata(sessionID, "/open cursed chest", "interact", "chest", "crypt", "A chill runs through you",
+		[]string{"curse_applied", "gold_gained"}, map[string]interface{}{"curse": "frailty", "gold_amount": 10})
+	cm.waitForQueuedEvents()
+
+	ctx, _ := cm.GetContext(sessionID)
+	if ctx.Character.Health.Max != before.Character.Health.Max-5 {
+		t.Errorf("Expected the curse to lower max health to %d, got %d", before.Character.Health.Max-5, ctx.Character.Health.Max)
+	}
+	if ctx.Character.Metadata["curse"] != "frailty" {
+		t.Errorf("Expected the curse to be recorded, got %v", ctx.Character.Metadata["curse"])
+	}
+	if ctx.Character.Gold != before.Character.Gold+10 {
+		t.Errorf("Expected built-in consequences to still apply, got %d gold", ctx.Character.Gold)
+	}
+	if effects := ctx.Actions[len(ctx.Actions)-1].Effects; effects == nil || effects.ReputationDelta != -3 {
+		t.Errorf("Expected the custom consequence's reputation change to be recorded, got %+v", effects)
+	}
+
+	// Built-ins can be replaced
+	cm.RegisterConsequence("combat_victory", func(ctx *PlayerContext, action ActionEvent) {
+		ctx.Character.Reputation += 10
+	})
+	cm.RecordAction(sessionID, "/attack goblin", "combat", "goblin", "crypt", "The goblin falls", []string{"combat_victory"})
+	cm.waitForQueuedEvents()
+	ctx, _ = cm.GetContext(sessionID)
+	if effects := ctx.Actions[len(ctx.Actions)-1].Effects; effects == nil || effects.ReputationDelta != 10 {
+		t.Errorf("Expected the replacement handler to run, got %+v", effects)
+	}
+
+	// Unknown consequences are ignored and logged once
+	for i := 0; i < 3; i++ {
+		cm.RecordAction(sessionID, "/dance", "social", "", "crypt", "You dance", []string{"dance_off"})
+	}
+	cm.waitForQueuedEvents()
+	if count := strings.Count(logs.String(), "consequence=dance_off"); count != 1 {
+		t.Errorf("Expected the unknown consequence to be logged once, got %d times:\n%s", count, logs.String())
+	}
+}
+
+// failingStorage refuses to save the sessions it is told to
+type failingStorage struct {
+	*MemoryContextStorage
+	failing map[string]bool
+}
+
+func (s *failingStorage) SaveContext(ctx *PlayerContext) error {
+	if s.failing[ctx.SessionID] {
+		return errors.New("disk full")
+	}
+	return s.MemoryContextStorage.SaveContext(ctx)
+}
+
+func TestContextManager_BulkOperations(t *testing.T) {
+	storage := &failingStorage{MemoryContextStorage: NewMemoryStorage(), failing: map[string]bool{}}
+	cm := NewContextManager(storage)
+	defer cm.Shutdown()
+	clock := newTestClock()
+	cm.SetNowFunc(clock.Now)
+
+	stale1, _ := cm.CreateSession("player1", "Aria")
+	stale2, _ := cm.CreateSession("player2", "Bram")
+	fresh, _ := cm.CreateSession("player3", "Cora")
+
+	// Every cached context is saved, even ones changed since the last save
+	for _, sessionID := range []string{stale1, stale2, fresh} {
+		cm.mutateContext(sessionID, func(ctx *PlayerContext) error {
+			ctx.Character.Gold = 77
+			return nil
+		})
+	}
+	if err := cm.FlushAll(); err != nil {
+		t.Fatalf("Failed to flush contexts: %v", err)
+	}
+	for _, sessionID := range []string{stale1, stale2, fresh} {
+		if stored, err := storage.LoadContext(sessionID); err != nil || stored.Character.Gold != 77 {
+			t.Errorf("Expected session %s to be flushed, got %v", sessionID, err)
+		}
+	}
+
+	// One failed save doesn't stop the rest
+	storage.failing[stale1] = true
+	cm.mutateContext(stale2, func(ctx *PlayerContext) error {
+		ctx.Character.Gold = 88
+		return nil
+	})
+	err := cm.FlushAll()
+	if err == nil || !strings.Contains(err.Error(), stale1) {
+		t.Errorf("Expected the failed session to be reported, got %v", err)
+	}
+	if stored, _ := storage.LoadContext(stale2); stored.Character.Gold != 88 {
+		t.Errorf("Expected other sessions to be flushed despite the failure, got %d gold", stored.Character.Gold)
+	}
+	storage.failing[stale1] = false
+
+	clock.Advance(time.Hour)
+	cm.UpdateLocation(fresh, "thornwick_forest")
+	summaries := cm.SummarizeAll()
+	if len(summaries) != 3 || summaries[0].SessionID != fresh || summaries[0].Location != "thornwick_forest" {
+		t.Fatalf("Expected 3 sessions with the most recently updated first, got %+v", summaries)
+	}
+
+	// Only the sessions idle for longer than the cutoff are evicted
+	if evicted := cm.EvictIdle(30 * time.Minute); evicted != 2 {
+		t.Errorf("Expected 2 idle sessions to be evicted, got %d", evicted)
+	}
+	summaries = cm.SummarizeAll()
+	if len(summaries) != 1 || summaries[0].SessionID != fresh {
+		t.Errorf("Expected only the fresh session to stay cached, got %+v", summaries)
+	}
+	if stored, err := storage.LoadContext(stale1); err != nil || stored.Character.Gold != 77 {
+		t.Errorf("Expected evicted sessions to be kept in storage, got %v", err)
+	}
+	if evicted := cm.EvictIdle(30 * time.Minute); evicted != 0 {
+		t.Errorf("Expected nothing left to evict, got %d", evicted)
+	}
+}
@@ -0,0 +1,106 @@
+package context
+
+import (
+	"fmt"
+	"strings"
+)
+
+// DialogueGenerator voices NPCs. ai.AIService satisfies it.
+type DialogueGenerator interface {
+	GenerateNPCDialogueForSession(sessionID, npcName, personality, prompt string) (string, error)
+}
+
+// npcTones tells the AI how an NPC in each mood should sound
+var npcTones = map[string]string{
+	"friendly":   "Be warm and open with them, as with an old friend.",
+	"helpful":    "Be kind and willing to help.",
+	"neutral":    "Be polite but reserved.",
+	"suspicious": "Be guarded; question their motives and share little.",
+	"unfriendly": "Be curt and unwelcoming.",
+	"hostile":    "Be cold and openly distrustful; you want them gone.",
+}
+
+// SetDialogueGenerator sets the AI used by GenerateNPCDialogueInContext
+func (cm *ContextManager) SetDialogueGenerator(generator DialogueGenerator) {
+	cm.registryMutex.Lock()
+	defer cm.registryMutex.Unlock()
+
+	cm.dialogueGenerator = generator
+}
+
+// GenerateNPCDialogueInContext has an NPC answer the player, with the AI told
+// the NPC's current mood, disposition and what it knows about the player, and
+// the player's reputation, so the NPC reacts to their shared history. NPCs
+// that won't deal with a player of such low reputation say so without the AI.
+func (cm *ContextManager) GenerateNPCDialogueInContext(sessionID, npcID, playerUtterance string) (string, error) {
+	cm.registryMutex.RLock()
+	generator := cm.dialogueGenerator
+	cm.registryMutex.RUnlock()
+
+	if generator == nil {
+		return "", fmt.Errorf("no dialogue generator configured")
+	}
+
+	ctx, err := cm.GetContext(sessionID)
+	if err != nil {
+		return "", err
+	}
+
+	npc, exists := ctx.NPCStates[npcID]
+	if !exists {
+		return "", fmt.Errorf("the player hasn't met NPC %s", npcID)
+	}
+	if refusal, refused := cm.npcRefusal(ctx, npcID, npc.Name); refused {
+		return refusal, nil
+	}
+
+	personality, prompt := cm.npcDialoguePrompt(ctx, npc, playerUtterance)
+	reply, err := generator.GenerateNPCDialogueForSession(sessionID, npc.Name, personality, prompt)
+	if err != nil {
+		return "", err
+	}
+
+	cm.extractNPCFactsInBackground(sessionID, npcID, fmt.Sprintf("Player: %s\n%s: %s", playerUtterance, npc.Name, reply))
+	return reply, nil
+}
+
+// npcDialoguePrompt builds the personality and prompt an NPC speaks from
+func (cm *ContextManager) npcDialoguePrompt(ctx *PlayerContext, npc NPCRelationship, playerUtterance string) (string, string) {
+	now := cm.now()
+	disposition := cm.decayedDisposition(npc, now)
+	mood := MoodForDisposition(disposition)
+
+	personality := fmt.Sprintf("Currently %s toward the player (%s, disposition %d from -100 to 100). %s",
+		mood, RelationshipLevel(disposition), disposition, npcTones[mood])
+	if cm.npcStance(ctx.Character.Reputation, disposition) == NPCStanceWelcomes {
+		personality += " The player's fame precedes them: greet them warmly and offer your help, and a discount on anything you sell."
+	}
+	if len(npc.Notes) > 0 {
+		personality += " " + strings.Join(npc.Notes, " ")
+	}
+
+	facts := cm.recalledFacts(npc.KnownFacts, now)
+	knownFacts := "- Nothing yet"
+	if len(facts) > 0 {
+		knownFacts = "- " + strings.Join(facts, "\n- ")
+	}
+
+	prompt := fmt.Sprintf(`WHAT YOU KNOW ABOUT THE PLAYER:
+%s
+
+THE PLAYER:
+- Name: %s
+- Reputation: %d (%s)
+- Times you have spoken: %d
+
+The player says: "%s"`,
+		knownFacts,
+		ctx.Character.Name,
+		ctx.Character.Reputation,
+		ReputationLabel(ctx.Character.Reputation),
+		npc.InteractionCount,
+		playerUtterance,
+	)
+
+	return personality, prompt
+}
@@ -0,0 +1,133 @@
+package context
+
+import (
+	"fmt"
+	"strings"
+)
+
+// FactExtractor reads an exchange with an NPC and returns what the NPC
+// learned about the player. ai.AIService satisfies it.
+type FactExtractor interface {
+	ExtractNPCFactsForSession(sessionID, npcName string, knownFacts []string, exchange string) ([]string, error)
+}
+
+// SetFactExtractor sets the AI used by ExtractNPCFacts
+func (cm *ContextManager) SetFactExtractor(extractor FactExtractor) {
+	cm.registryMutex.Lock()
+	defer cm.registryMutex.Unlock()
+
+	cm.factExtractor = extractor
+}
+
+// ExtractNPCFacts asks the AI what an NPC learned about the player from an
+// exchange and adds any new facts to the NPC's KnownFacts. Facts the NPC
+// already knows, compared without case or a trailing period, are skipped.
+// It returns the facts added.
+func (cm *ContextManager) ExtractNPCFacts(sessionID, npcID, exchange string) ([]string, error) {
+	cm.registryMutex.RLock()
+	extractor := cm.factExtractor
+	cm.registryMutex.RUnlock()
+
+	if extractor == nil {
+		return nil, fmt.Errorf("no fact extractor configured")
+	}
+
+	ctx, err := cm.GetContext(sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	npc, exists := ctx.NPCStates[npcID]
+	if !exists {
+		return nil, fmt.Errorf("the player hasn't met NPC %s", npcID)
+	}
+
+	known := make([]string, len(npc.KnownFacts))
+	for i, fact := range npc.KnownFacts {
+		known[i] = fact.Fact
+	}
+
+	// Ask outside the session lock; the extractor is remote
+	facts, err := extractor.ExtractNPCFactsForSession(sessionID, npc.Name, known, exchange)
+	if err != nil {
+		return nil, fmt.Errorf("failed to extract NPC facts: %w", err)
+	}
+
+	var added []string
+	err = cm.mutateContext(sessionID, func(ctx *PlayerContext) error {
+		added = cm.mergeNPCFacts(ctx, npcID, facts)
+		return nil
+	})
+	return added, err
+}
+
+// extractNPCFactsInBackground runs ExtractNPCFacts after an exchange when
+// automatic extraction is enabled, without making the caller wait
+func (cm *ContextManager) extractNPCFactsInBackground(sessionID, npcID, exchange string) {
+	if !cm.extractNPCFacts {
+		return
+	}
+
+	cm.aiTasks.Add(1)
+	go func() {
+		defer cm.aiTasks.Done()
+
+		if _, err := cm.ExtractNPCFacts(sessionID, npcID, exchange); err != nil {
+			cm.log().Warn("Failed to extract NPC facts", "npc_id", npcID, "session_id", sessionID, "error", err)
+		}
+	}()
+}
+
+// mergeNPCFacts adds the facts an NPC doesn't already know, in the shared
+// world when the session plays in one, and returns those added
+func (cm *ContextManager) mergeNPCFacts(ctx *PlayerContext, npcID string, facts []string) []string {
+	merge := func(npc NPCRelationship) (NPCRelationship, []string) {
+		var added []string
+		now := cm.now()
+		for _, fact := range facts {
+			fact = strings.TrimSpace(fact)
+			if fact == "" || knowsFact(npc.KnownFacts, fact) {
+				continue
+			}
+			npc.KnownFacts = append(npc.KnownFacts, NPCFact{Fact: fact, LearnedAt: now})
+			added = append(added, fact)
+		}
+		return npc, added
+	}
+
+	world := cm.worldFor(ctx)
+	if world == nil {
+		npc, added := merge(ctx.NPCStates[npcID])
+		ctx.NPCStates[npcID] = npc
+		return added
+	}
+
+	world.mutex.Lock()
+	defer world.mutex.Unlock()
+
+	npc, exists := world.npcStates[npcID]
+	if !exists {
+		npc = ctx.NPCStates[npcID]
+	}
+	npc, added := merge(npc)
+	world.npcStates[npcID] = npc
+	ctx.NPCStates[npcID] = npc.clone()
+	return added
+}
+
+// knowsFact reports whether facts already holds fact, ignoring case and a
+// trailing period
+func knowsFact(facts []NPCFact, fact string) bool {
+	normalized := normalizeFact(fact)
+	for _, known := range facts {
+		if normalizeFact(known.Fact) == normalized {
+			return true
+		}
+	}
+	return false
+}
+
+// normalizeFact reduces a fact to the form facts are compared in
+func normalizeFact(fact string) string {
+	return strings.ToLower(strings.TrimSuffix(strings.TrimSpace(fact), "."))
+}
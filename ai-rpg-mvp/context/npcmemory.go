@@ -0,0 +1,82 @@
+package context
+
+import (
+	"time"
+)
+
+const (
+	// DefaultFactMemoryWindow is how long NPCs keep a fact in mind
+	DefaultFactMemoryWindow = 7 * 24 * time.Hour
+	// DefaultDispositionDecayPerDay is how many disposition points per day
+	// an NPC drifts back toward neutral when left alone
+	DefaultDispositionDecayPerDay = 1.0
+)
+
+// SetNPCMemoryPolicy sets how long NPCs remember facts about the player in AI
+// prompts and how fast their disposition drifts back toward neutral. A zero
+// window keeps every fact; a zero rate disables drift.
+func (cm *ContextManager) SetNPCMemoryPolicy(factWindow time.Duration, dispositionDecayPerDay float64) {
+	cm.registryMutex.Lock()
+	defer cm.registryMutex.Unlock()
+
+	cm.factMemory = factWindow
+	cm.dispositionDecay = dispositionDecayPerDay
+}
+
+// npcMemoryPolicy returns the fact window and disposition decay rate
+func (cm *ContextManager) npcMemoryPolicy() (time.Duration, float64) {
+	cm.registryMutex.RLock()
+	defer cm.registryMutex.RUnlock()
+
+	return cm.factMemory, cm.dispositionDecay
+}
+
+// recalledFacts returns the facts an NPC still remembers. Older facts stay in
+// the stored relationship and are only left out of prompts.
+func (cm *ContextManager) recalledFacts(facts []NPCFact, now time.Time) []string {
+	window, _ := cm.npcMemoryPolicy()
+	recalled := make([]string, 0, len(facts))
+	for _, fact := range facts {
+		// Facts saved before timestamps were recorded have no age
+		if window > 0 && !fact.LearnedAt.IsZero() && now.Sub(fact.LearnedAt) > window {
+			continue
+		}
+		recalled = append(recalled, fact.Fact)
+	}
+	return recalled
+}
+
+// decayedDisposition returns an NPC's disposition after drifting toward
+// neutral for every full day since the last interaction
+func (cm *ContextManager) decayedDisposition(npc NPCRelationship, now time.Time) int {
+	_, decayPerDay := cm.npcMemoryPolicy()
+	if decayPerDay <= 0 || npc.LastInteraction.IsZero() {
+		return npc.Disposition
+	}
+
+	days := int(now.Sub(npc.LastInteraction) / (24 * time.Hour))
+	drift := int(float64(days) * decayPerDay)
+	switch {
+	case npc.Disposition > 0:
+		if drift >= npc.Disposition {
+			return 0
+		}
+		return npc.Disposition - drift
+	case npc.Disposition < 0:
+		if drift >= -npc.Disposition {
+			return 0
+		}
+		return npc.Disposition + drift
+	}
+	return 0
+}
+
+// findFact returns the index of a fact, or -1
+func findFact(facts []NPCFact, fact string) int {
+	for i, known := range facts {
+		if known.Fact == fact {
+			return i
+		}
+	}
+	return -1
+}
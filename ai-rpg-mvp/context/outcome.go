@@ -0,0 +1,96 @@
+package context
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrNoActions is returned when a session has no actions to work on yet
+var ErrNoActions = errors.New("no actions recorded yet")
+
+// LastAction returns the most recent action of a session, after any still
+// waiting in the event queue are processed. It fails with ErrNoActions
+// before the first one.
+func (cm *ContextManager) LastAction(sessionID string) (ActionEvent, error) {
+	if err := cm.waitForQueuedEvents(); err != nil {
+		return ActionEvent{}, err
+	}
+
+	ctx, err := cm.GetContext(sessionID)
+	if err != nil {
+		return ActionEvent{}, err
+	}
+	if len(ctx.Actions) == 0 {
+		return ActionEvent{}, ErrNoActions
+	}
+	return ctx.Actions[len(ctx.Actions)-1], nil
+}
+
+// ReplaceLastOutcome rewrites the outcome of the session's most recent
+// action, and the GM's reply to it in the dialogue history, leaving its
+// consequences as they were. actionID must name that action, so an action
+// recorded in the meantime isn't rewritten by mistake.
+func (cm *ContextManager) ReplaceLastOutcome(sessionID, actionID, outcome string) error {
+	if err := cm.waitForQueuedEvents(); err != nil {
+		return err
+	}
+
+	return cm.mutateContext(sessionID, func(ctx *PlayerContext) error {
+		if len(ctx.Actions) == 0 {
+			return ErrNoActions
+		}
+		last := &ctx.Actions[len(ctx.Actions)-1]
+		if last.ID != actionID {
+			return fmt.Errorf("action %s is no longer the most recent one", actionID)
+		}
+		last.Outcome = outcome
+
+		if n := len(ctx.DialogueHistory); n > 0 && ctx.DialogueHistory[n-1].ActionID == actionID {
+			ctx.DialogueHistory[n-1].Response = outcome
+		}
+		return nil
+	})
+}
+
+// narration is the prompt the GM narrated a command from
+type narration struct {
+	command string
+	prompt  string
+}
+
+// RememberNarration keeps the prompt the GM narrated a session's latest
+// command from, so RegenerateLastOutcome can send it again
+func (cm *ContextManager) RememberNarration(sessionID, command, prompt string) {
+	cm.narrations.Store(sessionID, narration{command: command, prompt: prompt})
+}
+
+// RegenerateLastOutcome asks generate for a new narration of the session's
+// most recent action, from the prompt remembered for it, and stores the
+// result as that action's outcome. The action's game effects stand as they
+// were. Only actions whose narration was remembered since the manager
+// started can be regenerated, as the prompts aren't persisted.
+func (cm *ContextManager) RegenerateLastOutcome(sessionID string, generate func(prompt string) (string, error)) (string, error) {
+	last, err := cm.LastAction(sessionID)
+	if errors.Is(err, ErrNoActions) {
+		return "", fmt.Errorf("there is no response to regenerate yet")
+	}
+	if err != nil {
+		return "", err
+	}
+
+	value, exists := cm.narrations.Load(sessionID)
+	narrated, _ := value.(narration)
+	if !exists || narrated.command != last.Command {
+		return "", fmt.Errorf("the last action, %s, has no GM response that can be regenerated", last.Command)
+	}
+
+	response, err := generate(narrated.prompt)
+	if err != nil {
+		return "", fmt.Errorf("failed to regenerate response: %w", err)
+	}
+
+	if err := cm.ReplaceLastOutcome(sessionID, last.ID, response); err != nil {
+		return "", fmt.Errorf("failed to store regenerated response: %w", err)
+	}
+	return response, nil
+}
@@ -0,0 +1,242 @@
+package context
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/google/uuid"
+)
+
+// Party is a group of players in the same world who split gold drops and
+// share quest progress
+type Party struct {
+	ID      string
+	WorldID string
+
+	members []string // session IDs, in joining order
+	mutex   sync.RWMutex
+}
+
+// Members returns the session IDs of the party's members in joining order
+func (p *Party) Members() []string {
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+
+	return copyStrings(p.members)
+}
+
+// add makes a session a member of the party
+func (p *Party) add(sessionID string) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if !contains(p.members, sessionID) {
+		p.members = append(p.members, sessionID)
+	}
+}
+
+// remove drops a session from the party and reports how many members remain
+func (p *Party) remove(sessionID string) int {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	p.members = removeString(p.members, sessionID)
+	return len(p.members)
+}
+
+// CreateParty starts a party led by the session, which must be in a shared
+// world and not already in a party
+func (cm *ContextManager) CreateParty(sessionID string) (string, error) {
+	partyID := uuid.New().String()
+
+	err := cm.mutateContext(sessionID, func(ctx *PlayerContext) error {
+		if ctx.WorldID == "" {
+			return fmt.Errorf("session %s must join a world before forming a party", sessionID)
+		}
+		if ctx.PartyID != "" {
+			return fmt.Errorf("session %s is already in party %s", sessionID, ctx.PartyID)
+		}
+
+		party := &Party{ID: partyID, WorldID: ctx.WorldID}
+		party.add(sessionID)
+		cm.parties.Store(partyID, party)
+		ctx.PartyID = partyID
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return partyID, nil
+}
+
+// JoinParty adds a session to an existing party in the same world
+func (cm *ContextManager) JoinParty(sessionID, partyID string) error {
+	party, ok := cm.GetParty(partyID)
+	if !ok {
+		return fmt.Errorf("party %s not found", partyID)
+	}
+
+	return cm.mutateContext(sessionID, func(ctx *PlayerContext) error {
+		if ctx.PartyID != "" {
+			return fmt.Errorf("session %s is already in party %s", sessionID, ctx.PartyID)
+		}
+		if ctx.WorldID != party.WorldID {
+			return fmt.Errorf("party %s plays in a different world", partyID)
+		}
+
+		party.add(sessionID)
+		ctx.PartyID = partyID
+		return nil
+	})
+}
+
+// LeaveParty removes a session from its party. The party is disbanded once
+// its last member leaves.
+func (cm *ContextManager) LeaveParty(sessionID string) error {
+	return cm.mutateContext(sessionID, func(ctx *PlayerContext) error {
+		if ctx.PartyID == "" {
+			return fmt.Errorf("session %s is not in a party", sessionID)
+		}
+
+		cm.leaveParty(ctx.PartyID, sessionID)
+		ctx.PartyID = ""
+		return nil
+	})
+}
+
+// GetParty returns a party, or false if it doesn't exist
+func (cm *ContextManager) GetParty(partyID string) (*Party, bool) {
+	party, ok := cm.parties.Load(partyID)
+	if !ok {
+		return nil, false
+	}
+	return party.(*Party), true
+}
+
+// leaveParty drops a member and disbands the party when it empties
+func (cm *ContextManager) leaveParty(partyID, sessionID string) {
+	party, ok := cm.GetParty(partyID)
+	if !ok {
+		return
+	}
+	if party.remove(sessionID) == 0 {
+		cm.parties.Delete(partyID)
+	}
+}
+
+// rejoinParty restores membership for a context loaded from storage, since
+// parties themselves only live in memory
+func (cm *ContextManager) rejoinParty(ctx *PlayerContext) {
+	if ctx.PartyID == "" {
+		return
+	}
+
+	party, _ := cm.parties.LoadOrStore(ctx.PartyID, &Party{ID: ctx.PartyID, WorldID: ctx.WorldID})
+	party.(*Party).add(ctx.SessionID)
+}
+
+// partyMembersHere returns the other members of the session's party who are
+// at the given location. Callers must not hold any session lock.
+func (cm *ContextManager) partyMembersHere(sessionID, partyID, location string) []*PlayerContext {
+	party, ok := cm.GetParty(partyID)
+	if !ok {
+		return nil
+	}
+
+	var present []*PlayerContext
+	for _, memberID := range party.Members() {
+		if memberID == sessionID {
+			continue
+		}
+		member, err := cm.GetContext(memberID)
+		if err == nil && member.PartyID == partyID && member.Location.Current == location {
+			present = append(present, member)
+		}
+	}
+	return present
+}
+
+// splitGold shares gold between the session and the party members standing
+// with it. The recipient keeps any remainder.
+func (cm *ContextManager) splitGold(sessionID string, amount int) error {
+	ctx, err := cm.GetContext(sessionID)
+	if err != nil {
+		return err
+	}
+
+	present := cm.partyMembersHere(sessionID, ctx.PartyID, ctx.Location.Current)
+	share := amount / (len(present) + 1)
+	remainder := amount - share*len(present)
+
+	// Credit one session at a time so no two session locks are ever held
+	if err := cm.creditGold(sessionID, remainder); err != nil {
+		return err
+	}
+	for _, member := range present {
+		if err := cm.creditGold(member.SessionID, share); err != nil {
+			return fmt.Errorf("failed to pay party member %s: %w", member.SessionID, err)
+		}
+	}
+	return nil
+}
+
+// shareObjective brings the other party members' copies of a quest up to the
+// given objective. Members without the quest active are left alone.
+func (cm *ContextManager) shareObjective(sessionID, questID string, objectiveIndex int) error {
+	ctx, err := cm.GetContext(sessionID)
+	if err != nil {
+		return err
+	}
+
+	party, ok := cm.GetParty(ctx.PartyID)
+	if !ok {
+		return nil
+	}
+
+	for _, memberID := range party.Members() {
+		if memberID == sessionID {
+			continue
+		}
+
+		err := cm.mutateContext(memberID, func(member *PlayerContext) error {
+			quest, err := activeQuest(member, questID)
+			if err != nil || objectiveIndex >= len(quest.Objectives) {
+				return nil
+			}
+
+			quest = quest.clone()
+			for i := 0; i <= objectiveIndex; i++ {
+				quest.Objectives[i].Done = true
+			}
+			member.Quests[questID] = quest
+			return nil
+		})
+		if err != nil {
+			return fmt.Errorf("failed to share progress with party member %s: %w", memberID, err)
+		}
+	}
+	return nil
+}
+
+// formatPartyMembers lists the player's party members at the same location.
+// It looks up their contexts, so callers must not hold any session lock.
+func (cm *ContextManager) formatPartyMembers(ctx *PlayerContext) string {
+	if ctx.PartyID == "" {
+		return "Not in a party"
+	}
+
+	members := cm.partyMembersHere(ctx.SessionID, ctx.PartyID, ctx.Location.Current)
+	if len(members) == 0 {
+		return "None present"
+	}
+
+	names := make([]string, 0, len(members))
+	for _, member := range members {
+		names = append(names, member.Character.Name)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
@@ -0,0 +1,156 @@
+package context
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Personality knobs at or above personalityHigh, or at or below
+// personalityLow, add instructions to the GM prompt
+const (
+	personalityHigh = 0.7
+	personalityLow  = 0.3
+)
+
+// GMPersonality tunes how the AI Game Master runs a session. Each knob is
+// between 0 and 1.
+type GMPersonality struct {
+	Helpfulness    float64 `json:"helpfulness"`     // how readily the GM offers hints
+	ChallengeLevel float64 `json:"challenge_level"` // how dangerous and unforgiving the world is
+	MysteryLevel   float64 `json:"mystery_level"`   // how much the GM holds back
+	ImmersionFocus float64 `json:"immersion_focus"` // how strictly the GM stays in character
+}
+
+// DefaultGMPersonality is the personality used until one is set
+func DefaultGMPersonality() GMPersonality {
+	return GMPersonality{
+		Helpfulness:    0.7,
+		ChallengeLevel: 0.6,
+		MysteryLevel:   0.6,
+		ImmersionFocus: 0.9,
+	}
+}
+
+// Validate checks that every knob is between 0 and 1
+func (p GMPersonality) Validate() error {
+	knobs := []struct {
+		name  string
+		value float64
+	}{
+		{"helpfulness", p.Helpfulness},
+		{"challenge level", p.ChallengeLevel},
+		{"mystery level", p.MysteryLevel},
+		{"immersion focus", p.ImmersionFocus},
+	}
+	for _, knob := range knobs {
+		if knob.value < 0 || knob.value > 1 {
+			return fmt.Errorf("%s must be between 0 and 1, got %.2f", knob.name, knob.value)
+		}
+	}
+	return nil
+}
+
+// instructions returns the GM instructions this personality adds to the
+// standard ones
+func (p GMPersonality) instructions() []string {
+	var instructions []string
+
+	switch {
+	case p.Helpfulness >= personalityHigh:
+		instructions = append(instructions, "Offer clear hints when the player seems stuck")
+	case p.Helpfulness <= personalityLow:
+		instructions = append(instructions, "Give no hints unless asked; let the player find their own way")
+	}
+
+	switch {
+	case p.ChallengeLevel >= personalityHigh:
+		instructions = append(instructions, "Present meaningful risks; careless choices should have real consequences")
+	case p.ChallengeLevel <= personalityLow:
+		instructions = append(instructions, "Keep danger light and forgive mistakes")
+	}
+
+	switch {
+	case p.MysteryLevel >= personalityHigh:
+		instructions = append(instructions, "Withhold some information; reveal secrets gradually")
+	case p.MysteryLevel <= personalityLow:
+		instructions = append(instructions, "Be forthcoming; state plainly what the player sees and learns")
+	}
+
+	switch {
+	case p.ImmersionFocus >= personalityHigh:
+		instructions = append(instructions, "Stay fully in character and never break the fourth wall")
+	case p.ImmersionFocus <= personalityLow:
+		instructions = append(instructions, "Keep descriptions brief and to the point")
+	}
+
+	return instructions
+}
+
+// SetDefaultGMPersonality sets the personality for sessions that haven't
+// chosen their own
+func (cm *ContextManager) SetDefaultGMPersonality(p GMPersonality) error {
+	if err := p.Validate(); err != nil {
+		return err
+	}
+
+	cm.registryMutex.Lock()
+	defer cm.registryMutex.Unlock()
+
+	cm.gmPersonality = p
+	return nil
+}
+
+// SetGMPersonality overrides the GM personality for one session
+func (cm *ContextManager) SetGMPersonality(sessionID string, p GMPersonality) error {
+	if err := p.Validate(); err != nil {
+		return err
+	}
+
+	return cm.mutateContext(sessionID, func(ctx *PlayerContext) error {
+		ctx.GMPersonality = &p
+		return nil
+	})
+}
+
+// GetGMPersonality returns the personality the GM uses for a session
+func (cm *ContextManager) GetGMPersonality(sessionID string) (GMPersonality, error) {
+	ctx, err := cm.GetContext(sessionID)
+	if err != nil {
+		return GMPersonality{}, err
+	}
+	return cm.personalityFor(ctx), nil
+}
+
+// personalityFor returns the session's own personality, or the default
+func (cm *ContextManager) personalityFor(ctx *PlayerContext) GMPersonality {
+	if ctx.GMPersonality != nil {
+		return *ctx.GMPersonality
+	}
+
+	cm.registryMutex.RLock()
+	defer cm.registryMutex.RUnlock()
+
+	return cm.gmPersonality
+}
+
+// formatGMInstructions renders the standard GM instructions followed by the
+// ones the personality adds
+func (cm *ContextManager) formatGMInstructions(p GMPersonality) string {
+	lines := []string{
+		"Respond as the omniscient narrator and world",
+		"Maintain consistency with previous interactions",
+		"React appropriately to the player's reputation and recent actions",
+		"Consider NPC relationships and dispositions",
+		"Provide immersive, contextual descriptions",
+		"Balance challenge with player agency",
+	}
+	lines = append(lines, p.instructions()...)
+
+	var b strings.Builder
+	b.WriteString("You are the AI Game Master for this fantasy RPG session. Based on the current context:\n")
+	for i, line := range lines {
+		fmt.Fprintf(&b, "%d. %s\n", i+1, line)
+	}
+	b.WriteString("\nCurrent situation requires your response as Game Master.")
+	return b.String()
+}
@@ -0,0 +1,82 @@
+package context
+
+import (
+	"fmt"
+	"strings"
+)
+
+// attributePointsPerLevel is how many attribute points a level_up consequence
+// grants unless its metadata names another amount
+const attributePointsPerLevel = 3
+
+// healthPerStrengthPoint is how much max health each allocated point of
+// strength adds
+const healthPerStrengthPoint = 2
+
+// allocatableAttributes are the attributes unspent points can raise
+var allocatableAttributes = []string{"strength", "dexterity", "intelligence", "charisma"}
+
+// AllocateAttributePoints spends the character's unspent points, raising
+// each named attribute by its amount. The whole allocation is rejected if it
+// names an unknown attribute, has an amount below 1 or costs more points than
+// are unspent. Strength also raises max health, and current health with it;
+// checks and combat use the new scores straight away.
+func (cm *ContextManager) AllocateAttributePoints(sessionID string, allocations map[string]int) error {
+	if len(allocations) == 0 {
+		return fmt.Errorf("no attribute points to allocate")
+	}
+
+	return cm.mutateContextDurably(sessionID, func(ctx *PlayerContext) error {
+		spent := 0
+		normalized := make(map[string]int, len(allocations))
+		for attribute, points := range allocations {
+			attribute = strings.ToLower(strings.TrimSpace(attribute))
+			if !isAllocatableAttribute(attribute) {
+				return fmt.Errorf("unknown attribute: %s (allowed: %s)", attribute, strings.Join(allocatableAttributes, ", "))
+			}
+			if points < 1 {
+				return fmt.Errorf("must allocate at least 1 point to %s, got %d", attribute, points)
+			}
+			normalized[attribute] += points
+			spent += points
+		}
+		if spent > ctx.Character.UnspentPoints {
+			return fmt.Errorf("cannot allocate %d attribute points, only %d unspent", spent, ctx.Character.UnspentPoints)
+		}
+
+		if ctx.Character.Attributes == nil {
+			ctx.Character.Attributes = make(map[string]int)
+		}
+		for attribute, points := range normalized {
+			ctx.Character.Attributes[attribute] += points
+		}
+		ctx.Character.UnspentPoints -= spent
+
+		if gained := normalized["strength"] * healthPerStrengthPoint; gained > 0 {
+			ctx.Character.Health.Max += gained
+			if ctx.Character.Alive {
+				ctx.Character.Health.Current += gained
+			}
+		}
+		return nil
+	})
+}
+
+// isAllocatableAttribute reports whether unspent points can raise attribute
+func isAllocatableAttribute(attribute string) bool {
+	for _, allowed := range allocatableAttributes {
+		if attribute == allowed {
+			return true
+		}
+	}
+	return false
+}
+
+// formatUnspentPoints reminds the GM of attribute points the player hasn't
+// allocated yet, or says nothing when there are none
+func (cm *ContextManager) formatUnspentPoints(character CharacterState) string {
+	if character.UnspentPoints <= 0 {
+		return ""
+	}
+	return fmt.Sprintf("\n- Unspent Attribute Points: %d. Nudge the player to spend them on %s.", character.UnspentPoints, strings.Join(allocatableAttributes, ", "))
+}
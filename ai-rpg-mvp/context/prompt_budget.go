@@ -0,0 +1,91 @@
+package context
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// DefaultMaxPromptTokens is the GM prompt budget used by NewContextManager
+const DefaultMaxPromptTokens = 6000
+
+// Prompt section priorities. When a prompt is over budget, sections are cut
+// lowest priority first; essential sections are always kept.
+const (
+	priorityRelevantHistory = iota
+	priorityNarrative
+	priorityRecentActions
+	priorityWorld
+	priorityStory
+	priorityQuests
+	priorityNPCs
+	priorityCharacter
+	priorityEssential
+)
+
+// promptSection is one titled part of the GM prompt. Sections with several
+// entries lose their oldest entries before being dropped entirely.
+type promptSection struct {
+	title     string   // rendered as "TITLE:"; empty for untitled text
+	entries   []string // oldest first
+	separator string   // between entries
+	priority  int
+}
+
+// EstimateTokens roughly estimates how many model tokens s takes, at about
+// four characters per token
+func EstimateTokens(s string) int {
+	return (utf8.RuneCountInString(s) + 3) / 4
+}
+
+// renderPrompt joins sections into the prompt text
+func renderPrompt(sections []promptSection) string {
+	parts := make([]string, 0, len(sections))
+	for _, section := range sections {
+		separator := section.separator
+		if separator == "" {
+			separator = "\n"
+		}
+		body := strings.Join(section.entries, separator)
+		if section.title != "" {
+			body = section.title + ":\n" + body
+		}
+		parts = append(parts, body)
+	}
+	return strings.Join(parts, "\n\n")
+}
+
+// truncatePrompt cuts sections until the rendered prompt fits in budget
+// tokens, or only essential sections are left. It reports whether anything
+// was cut.
+func truncatePrompt(sections []promptSection, budget int) ([]promptSection, bool) {
+	truncated := false
+	for EstimateTokens(renderPrompt(sections)) > budget {
+		i := lowestPrioritySection(sections)
+		if i < 0 {
+			break
+		}
+
+		if len(sections[i].entries) > 1 {
+			sections[i].entries = sections[i].entries[1:]
+		} else {
+			sections = append(sections[:i], sections[i+1:]...)
+		}
+		truncated = true
+	}
+	return sections, truncated
+}
+
+// lowestPrioritySection returns the index of the first section with the
+// lowest priority that may be cut, or -1 when every section is essential
+func lowestPrioritySection(sections []promptSection) int {
+	lowest := -1
+	for i, section := range sections {
+		if section.priority >= priorityEssential {
+			continue
+		}
+		if lowest < 0 || section.priority < sections[lowest].priority {
+			lowest = i
+		}
+	}
+	return lowest
+}
@@ -0,0 +1,143 @@
+package context
+
+import (
+	"fmt"
+	"sort"
+)
+
+// StartQuest adds a quest to the player's log as active
+func (cm *ContextManager) StartQuest(sessionID string, quest Quest) error {
+	if quest.ID == "" {
+		return fmt.Errorf("quest ID is required")
+	}
+
+	return cm.mutateContext(sessionID, func(ctx *PlayerContext) error {
+		if existing, exists := ctx.Quests[quest.ID]; exists && existing.Status == QuestActive {
+			return fmt.Errorf("quest %s is already active", quest.ID)
+		}
+
+		quest = quest.clone()
+		quest.Status = QuestActive
+		quest.StartedAt = cm.now()
+		if quest.Objectives == nil {
+			quest.Objectives = []Objective{}
+		}
+
+		if ctx.Quests == nil {
+			ctx.Quests = make(map[string]Quest)
+		}
+		ctx.Quests[quest.ID] = quest
+		return nil
+	})
+}
+
+// CompleteObjective marks an objective done. Objectives must be completed
+// in order, so every earlier objective has to be done first.
+// Progress is shared with the rest of the player's party.
+func (cm *ContextManager) CompleteObjective(sessionID, questID string, objectiveIndex int) error {
+	err := cm.mutateContext(sessionID, func(ctx *PlayerContext) error {
+		quest, err := activeQuest(ctx, questID)
+		if err != nil {
+			return err
+		}
+
+		if objectiveIndex < 0 || objectiveIndex >= len(quest.Objectives) {
+			return fmt.Errorf("quest %s has no objective %d", questID, objectiveIndex)
+		}
+		if next := nextObjective(quest); next != objectiveIndex {
+			return fmt.Errorf("objective %d of quest %s is not the next objective", objectiveIndex, questID)
+		}
+
+		quest.Objectives[objectiveIndex].Done = true
+		ctx.Quests[questID] = quest
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	return cm.shareObjective(sessionID, questID, objectiveIndex)
+}
+
+// CompleteQuest marks an active quest completed once all its objectives are done
+func (cm *ContextManager) CompleteQuest(sessionID, questID string) error {
+	return cm.mutateContextDurably(sessionID, func(ctx *PlayerContext) error {
+		quest, err := activeQuest(ctx, questID)
+		if err != nil {
+			return err
+		}
+
+		if next := nextObjective(quest); next >= 0 {
+			return fmt.Errorf("quest %s still has incomplete objectives", questID)
+		}
+
+		quest.Status = QuestCompleted
+		ctx.Quests[questID] = quest
+		return nil
+	})
+}
+
+// FailQuest marks an active quest failed
+func (cm *ContextManager) FailQuest(sessionID, questID string) error {
+	return cm.mutateContext(sessionID, func(ctx *PlayerContext) error {
+		quest, err := activeQuest(ctx, questID)
+		if err != nil {
+			return err
+		}
+
+		quest.Status = QuestFailed
+		ctx.Quests[questID] = quest
+		return nil
+	})
+}
+
+// GetActiveQuests returns the player's active quests, oldest first
+func (cm *ContextManager) GetActiveQuests(sessionID string) ([]Quest, error) {
+	ctx, err := cm.GetContext(sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	return activeQuests(ctx), nil
+}
+
+// activeQuests returns the active quests in a context, oldest first
+func activeQuests(ctx *PlayerContext) []Quest {
+	quests := []Quest{}
+	for _, quest := range ctx.Quests {
+		if quest.Status == QuestActive {
+			quests = append(quests, quest)
+		}
+	}
+
+	sort.Slice(quests, func(i, j int) bool {
+		if !quests[i].StartedAt.Equal(quests[j].StartedAt) {
+			return quests[i].StartedAt.Before(quests[j].StartedAt)
+		}
+		return quests[i].ID < quests[j].ID
+	})
+
+	return quests
+}
+
+// activeQuest looks up a quest that can still be progressed
+func activeQuest(ctx *PlayerContext, questID string) (Quest, error) {
+	quest, exists := ctx.Quests[questID]
+	if !exists {
+		return Quest{}, fmt.Errorf("quest %s not found", questID)
+	}
+	if quest.Status != QuestActive {
+		return Quest{}, fmt.Errorf("quest %s is %s", questID, quest.Status)
+	}
+	return quest, nil
+}
+
+// nextObjective returns the index of the first incomplete objective, or -1
+func nextObjective(quest Quest) int {
+	for i, objective := range quest.Objectives {
+		if !objective.Done {
+			return i
+		}
+	}
+	return -1
+}
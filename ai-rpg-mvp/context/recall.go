@@ -0,0 +1,165 @@
+package context
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+)
+
+// relevantHistoryActions is how many past actions the GM prompt recalls
+const relevantHistoryActions = 3
+
+// Embedder turns text into a vector whose distance to other vectors reflects
+// how similar their meanings are. ai.Embedder satisfies it.
+type Embedder interface {
+	Embed(text string) ([]float32, error)
+}
+
+// SetEmbedder enables semantic recall. Actions recorded from now on are
+// embedded, and RetrieveRelevantActions searches them by meaning.
+func (cm *ContextManager) SetEmbedder(embedder Embedder) {
+	cm.registryMutex.Lock()
+	defer cm.registryMutex.Unlock()
+
+	cm.embedder = embedder
+}
+
+// currentEmbedder returns the configured embedder, or nil
+func (cm *ContextManager) currentEmbedder() Embedder {
+	cm.registryMutex.RLock()
+	defer cm.registryMutex.RUnlock()
+
+	return cm.embedder
+}
+
+// embedAction embeds an action's command and outcome. It returns nil when no
+// embedder is set or embedding fails, so recording never depends on it.
+func (cm *ContextManager) embedAction(action ActionEvent) []float32 {
+	embedder := cm.currentEmbedder()
+	if embedder == nil {
+		return nil
+	}
+
+	embedding, err := embedder.Embed(actionText(action))
+	if err != nil {
+		cm.log().Warn("Failed to embed action", "action_id", action.ID, "error", err)
+		return nil
+	}
+	return embedding
+}
+
+// actionText is the text an action is embedded from
+func actionText(action ActionEvent) string {
+	text := action.Command
+	if action.Outcome != "" {
+		text += "\n" + action.Outcome
+	}
+	return text
+}
+
+// RetrieveRelevantActions returns up to k of the session's past actions whose
+// meaning is closest to query, most similar first. Actions recorded without an
+// embedding are skipped.
+func (cm *ContextManager) RetrieveRelevantActions(sessionID, query string, k int) ([]ActionEvent, error) {
+	embedder := cm.currentEmbedder()
+	if embedder == nil {
+		return nil, fmt.Errorf("no embedder configured")
+	}
+	if k <= 0 || strings.TrimSpace(query) == "" {
+		return []ActionEvent{}, nil
+	}
+
+	// Embed outside the session lock; the embedder may be remote
+	queryEmbedding, err := embedder.Embed(query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to embed query: %w", err)
+	}
+
+	ctx, err := cm.GetContext(sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	return rankActions(ctx.Actions, queryEmbedding, k), nil
+}
+
+// rankActions returns the k embedded actions most similar to query
+func rankActions(actions []ActionEvent, query []float32, k int) []ActionEvent {
+	type scoredAction struct {
+		action ActionEvent
+		score  float64
+	}
+
+	var scored []scoredAction
+	for _, action := range actions {
+		if len(action.Embedding) == 0 {
+			continue
+		}
+		scored = append(scored, scoredAction{action, cosineSimilarity(query, action.Embedding)})
+	}
+
+	sort.SliceStable(scored, func(i, j int) bool {
+		return scored[i].score > scored[j].score
+	})
+	if len(scored) > k {
+		scored = scored[:k]
+	}
+
+	relevant := make([]ActionEvent, len(scored))
+	for i, s := range scored {
+		relevant[i] = s.action
+	}
+	return relevant
+}
+
+// cosineSimilarity returns the cosine of the angle between a and b, or 0 when
+// they can't be compared
+func cosineSimilarity(a, b []float32) float64 {
+	if len(a) != len(b) || len(a) == 0 {
+		return 0
+	}
+
+	var dot, normA, normB float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		normA += float64(a[i]) * float64(a[i])
+		normB += float64(b[i]) * float64(b[i])
+	}
+	if normA == 0 || normB == 0 {
+		return 0
+	}
+	return dot / (math.Sqrt(normA) * math.Sqrt(normB))
+}
+
+// relevantHistory recalls the past actions most related to command, leaving
+// out the ones the prompt already lists as recent. It returns nothing when
+// recall is disabled or fails.
+func (cm *ContextManager) relevantHistory(sessionID, command string, recent []ActionEvent) []ActionEvent {
+	if cm.currentEmbedder() == nil || strings.TrimSpace(command) == "" {
+		return nil
+	}
+
+	relevant, err := cm.RetrieveRelevantActions(sessionID, command, relevantHistoryActions+len(recent))
+	if err != nil {
+		cm.log().Warn("Failed to recall relevant history", "session_id", sessionID, "error", err)
+		return nil
+	}
+
+	shown := make(map[string]bool, len(recent))
+	for _, action := range recent {
+		shown[action.ID] = true
+	}
+
+	var history []ActionEvent
+	for _, action := range relevant {
+		if shown[action.ID] {
+			continue
+		}
+		history = append(history, action)
+		if len(history) == relevantHistoryActions {
+			break
+		}
+	}
+	return history
+}
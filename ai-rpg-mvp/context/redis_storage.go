@@ -0,0 +1,256 @@
+package context
+
+import (
+	goctx "context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"ai-rpg-mvp/config"
+)
+
+// redisKeyPrefix namespaces context keys in Redis
+const redisKeyPrefix = "rpg:ctx:"
+
+// redisSnapshotPrefix namespaces per-session snapshot hashes in Redis
+const redisSnapshotPrefix = "rpg:snap:"
+
+// RedisContextStorage provides Redis storage for shared, low-latency deployments
+type RedisContextStorage struct {
+	client  *redis.Client
+	ttl     time.Duration
+	timeout time.Duration
+}
+
+// NewRedisStorage creates a new Redis storage instance
+func NewRedisStorage(cfg config.RedisConfig) (*RedisContextStorage, error) {
+	options, err := redisOptions(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	client := redis.NewClient(options)
+
+	timeout := cfg.DialTimeout
+	if timeout == 0 {
+		timeout = 5 * time.Second
+	}
+
+	storage := &RedisContextStorage{
+		client:  client,
+		ttl:     cfg.ContextTTL,
+		timeout: timeout,
+	}
+
+	ctx, cancel := storage.requestContext()
+	defer cancel()
+
+	if err := client.Ping(ctx).Err(); err != nil {
+		client.Close()
+		return nil, fmt.Errorf("failed to ping redis: %w", err)
+	}
+
+	return storage, nil
+}
+
+// redisOptions builds client options from configuration. The URL may be a
+// plain host:port address or a redis:// URL.
+func redisOptions(cfg config.RedisConfig) (*redis.Options, error) {
+	options := &redis.Options{Addr: cfg.URL}
+	if strings.HasPrefix(cfg.URL, "redis://") || strings.HasPrefix(cfg.URL, "rediss://") {
+		parsed, err := redis.ParseURL(cfg.URL)
+		if err != nil {
+			return nil, fmt.Errorf("invalid redis URL: %w", err)
+		}
+		options = parsed
+	}
+
+	if cfg.Password != "" {
+		options.Password = cfg.Password
+	}
+	if cfg.DB != 0 {
+		options.DB = cfg.DB
+	}
+
+	options.MaxRetries = cfg.MaxRetries
+	options.DialTimeout = cfg.DialTimeout
+	options.ReadTimeout = cfg.ReadTimeout
+	options.WriteTimeout = cfg.WriteTimeout
+	options.PoolSize = cfg.PoolSize
+	options.MinIdleConns = cfg.MinIdleConns
+	options.ConnMaxLifetime = cfg.MaxConnAge
+	options.PoolTimeout = cfg.PoolTimeout
+	options.ConnMaxIdleTime = cfg.IdleTimeout
+
+	return options, nil
+}
+
+// requestContext returns a context bounding a single Redis round trip
+func (s *RedisContextStorage) requestContext() (goctx.Context, goctx.CancelFunc) {
+	return goctx.WithTimeout(goctx.Background(), s.timeout)
+}
+
+// LoadContext loads a context from Redis
+func (s *RedisContextStorage) LoadContext(sessionID string) (*PlayerContext, error) {
+	ctx, cancel := s.requestContext()
+	defer cancel()
+
+	data, err := s.client.Get(ctx, redisKeyPrefix+sessionID).Bytes()
+	if err != nil {
+		if err == redis.Nil {
+			return nil, fmt.Errorf("%w: %s", ErrSessionNotFound, sessionID)
+		}
+		return nil, fmt.Errorf("failed to load context: %w", err)
+	}
+
+	var playerCtx PlayerContext
+	if err := json.Unmarshal(data, &playerCtx); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal context: %w", err)
+	}
+
+	return &playerCtx, nil
+}
+
+// SaveContext saves a context to Redis, refreshing its TTL
+func (s *RedisContextStorage) SaveContext(playerCtx *PlayerContext) error {
+	data, err := json.Marshal(playerCtx)
+	if err != nil {
+		return fmt.Errorf("failed to marshal context: %w", err)
+	}
+
+	ctx, cancel := s.requestContext()
+	defer cancel()
+
+	if err := s.client.Set(ctx, redisKeyPrefix+playerCtx.SessionID, data, s.ttl).Err(); err != nil {
+		return fmt.Errorf("failed to save context: %w", err)
+	}
+
+	return nil
+}
+
+// DeleteContext removes a context from Redis
+func (s *RedisContextStorage) DeleteContext(sessionID string) error {
+	ctx, cancel := s.requestContext()
+	defer cancel()
+
+	removed, err := s.client.Del(ctx, redisKeyPrefix+sessionID).Result()
+	if err != nil {
+		return fmt.Errorf("failed to delete context: %w", err)
+	}
+
+	if removed == 0 {
+		return fmt.Errorf("context not found for session %s", sessionID)
+	}
+
+	return nil
+}
+
+// ListActiveSessions returns all stored session IDs. It uses SCAN so large
+// keyspaces don't block the server the way KEYS would.
+func (s *RedisContextStorage) ListActiveSessions() ([]string, error) {
+	ctx, cancel := s.requestContext()
+	defer cancel()
+
+	var sessions []string
+	iter := s.client.Scan(ctx, 0, redisKeyPrefix+"*", 100).Iterator()
+	for iter.Next(ctx) {
+		sessions = append(sessions, strings.TrimPrefix(iter.Val(), redisKeyPrefix))
+	}
+
+	if err := iter.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list sessions: %w", err)
+	}
+
+	return sessions, nil
+}
+
+// SaveSnapshot stores a snapshot in the session's snapshot hash, which
+// expires along with the session
+func (s *RedisContextStorage) SaveSnapshot(snapshot *Snapshot) error {
+	data, err := json.Marshal(snapshot)
+	if err != nil {
+		return fmt.Errorf("failed to marshal snapshot: %w", err)
+	}
+
+	ctx, cancel := s.requestContext()
+	defer cancel()
+
+	key := redisSnapshotPrefix + snapshot.SessionID
+	pipe := s.client.TxPipeline()
+	pipe.HSet(ctx, key, snapshot.ID, data)
+	if s.ttl > 0 {
+		pipe.Expire(ctx, key, s.ttl)
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("failed to save snapshot: %w", err)
+	}
+
+	return nil
+}
+
+// LoadSnapshot loads a snapshot from Redis
+func (s *RedisContextStorage) LoadSnapshot(sessionID, snapshotID string) (*Snapshot, error) {
+	ctx, cancel := s.requestContext()
+	defer cancel()
+
+	data, err := s.client.HGet(ctx, redisSnapshotPrefix+sessionID, snapshotID).Bytes()
+	if err != nil {
+		if err == redis.Nil {
+			return nil, fmt.Errorf("snapshot %s not found for session %s", snapshotID, sessionID)
+		}
+		return nil, fmt.Errorf("failed to load snapshot: %w", err)
+	}
+
+	var snapshot Snapshot
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal snapshot: %w", err)
+	}
+
+	return &snapshot, nil
+}
+
+// ListSnapshots returns the snapshots saved for a session, oldest first
+func (s *RedisContextStorage) ListSnapshots(sessionID string) ([]SnapshotInfo, error) {
+	ctx, cancel := s.requestContext()
+	defer cancel()
+
+	entries, err := s.client.HGetAll(ctx, redisSnapshotPrefix+sessionID).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list snapshots: %w", err)
+	}
+
+	infos := make([]SnapshotInfo, 0, len(entries))
+	for _, data := range entries {
+		var snapshot Snapshot
+		if err := json.Unmarshal([]byte(data), &snapshot); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal snapshot: %w", err)
+		}
+		infos = append(infos, snapshot.SnapshotInfo)
+	}
+
+	sort.Slice(infos, func(i, j int) bool {
+		return infos[i].CreatedAt.Before(infos[j].CreatedAt)
+	})
+
+	return infos, nil
+}
+
+// Ping checks that Redis is reachable
+func (s *RedisContextStorage) Ping() error {
+	ctx, cancel := s.requestContext()
+	defer cancel()
+
+	if err := s.client.Ping(ctx).Err(); err != nil {
+		return fmt.Errorf("failed to ping redis: %w", err)
+	}
+	return nil
+}
+
+// Close closes the Redis connection pool
+func (s *RedisContextStorage) Close() error {
+	return s.client.Close()
+}
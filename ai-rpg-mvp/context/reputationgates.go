@@ -0,0 +1,103 @@
+package context
+
+import "fmt"
+
+// Default reputation gates
+const (
+	DefaultShunnedReputation = -50
+	DefaultHonoredReputation = 50
+)
+
+// How NPCs treat the player once their reputation crosses a gate
+const (
+	NPCStanceRefuses  = "refuses"  // won't talk or trade with the player
+	NPCStanceAttacks  = "attacks"  // attacks the player on sight
+	NPCStanceWelcomes = "welcomes" // greets the player warmly, offering help and discounts
+)
+
+// npcStanceDescriptions tell the GM how NPCs of each stance behave
+var npcStanceDescriptions = map[string]string{
+	NPCStanceRefuses:  "refuses to speak or trade with the player",
+	NPCStanceAttacks:  "attacks the player on sight",
+	NPCStanceWelcomes: "greets the player warmly and offers help and discounts",
+}
+
+// honoredDiscount is the share NPCs who welcome the player take off their
+// shop prices
+const honoredDiscount = 0.1
+
+// SetReputationGates sets the reputations at which NPCs change how they
+// treat the player. At or below shunned, NPCs refuse to talk or trade and
+// hostile ones attack on sight; at or above honored, NPCs who don't dislike
+// the player greet them warmly and offer help and discounts.
+func (cm *ContextManager) SetReputationGates(shunned, honored int) error {
+	if shunned >= honored {
+		return fmt.Errorf("shunned reputation %d must be below honored reputation %d", shunned, honored)
+	}
+	cm.shunnedReputation, cm.honoredReputation = shunned, honored
+	return nil
+}
+
+// npcStance is how an NPC with the given disposition treats a player with the
+// given reputation, or "" if as usual. Hostile NPCs attack shunned players
+// rather than just refusing them, and only NPCs with at least a neutral
+// disposition welcome honored ones.
+func (cm *ContextManager) npcStance(reputation, disposition int) string {
+	switch {
+	case reputation <= cm.shunnedReputation:
+		if MoodForDisposition(disposition) == "hostile" {
+			return NPCStanceAttacks
+		}
+		return NPCStanceRefuses
+	case reputation >= cm.honoredReputation && disposition >= 0:
+		return NPCStanceWelcomes
+	}
+	return ""
+}
+
+// npcDisposition is how the NPC feels about the player, neutral if they
+// haven't met
+func (cm *ContextManager) npcDisposition(ctx *PlayerContext, npcID string) int {
+	npc, exists := ctx.NPCStates[npcID]
+	if !exists {
+		return 0
+	}
+	return cm.decayedDisposition(npc, cm.now())
+}
+
+// npcRefusal is what an NPC that won't deal with the player does instead of
+// answering them, if it won't
+func (cm *ContextManager) npcRefusal(ctx *PlayerContext, npcID, npcName string) (string, bool) {
+	switch cm.npcStance(ctx.Character.Reputation, cm.npcDisposition(ctx, npcID)) {
+	case NPCStanceRefuses:
+		return fmt.Sprintf("%s refuses to speak to you.", npcName), true
+	case NPCStanceAttacks:
+		return fmt.Sprintf("%s attacks you on sight!", npcName), true
+	}
+	return "", false
+}
+
+// reputationGateValidator rejects talking to or trading with NPCs that won't
+// deal with the player
+func (cm *ContextManager) reputationGateValidator() ActionValidator {
+	return ActionValidatorFunc(func(ctx *PlayerContext, command string) error {
+		verb, args := parseCommand(command)
+		if len(args) == 0 {
+			return nil
+		}
+		switch verb {
+		case "talk", "trade", "buy", "sell":
+		default:
+			return nil
+		}
+
+		npc, exists := ctx.NPCStates[args[0]]
+		if !exists {
+			return nil
+		}
+		if refusal, refused := cm.npcRefusal(ctx, npc.NPCID, npc.Name); refused {
+			return fmt.Errorf("%s", refusal)
+		}
+		return nil
+	})
+}
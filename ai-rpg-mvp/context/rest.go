@@ -0,0 +1,78 @@
+package context
+
+import (
+	"fmt"
+)
+
+// Defaults for resting, used by NewContextManager
+const (
+	DefaultRestHealPerHour     = 2
+	DefaultRestEncounterChance = 5 // percent per hour
+
+	// maxRestHours is the longest a single rest can last
+	maxRestHours = 24
+)
+
+// Rest has the player rest for up to hours in-game hours, healing
+// RestHealPerHour health per hour up to their maximum and advancing the game
+// clock. After each hour a random encounter may cut the rest short, rolled
+// from the location's encounter table when it has one. Resting
+// isn't possible in a hostile location or while dead. The rest is recorded as
+// an action so it shows up in the session's history.
+func (cm *ContextManager) Rest(sessionID string, hours int) error {
+	if hours < 1 || hours > maxRestHours {
+		return fmt.Errorf("rest must last between 1 and %d hours, not %d", maxRestHours, hours)
+	}
+
+	var location string
+	var rested, healed int
+	var encounter *Encounter
+	interrupted := false
+	err := cm.mutateContext(sessionID, func(ctx *PlayerContext) error {
+		if err := checkAlive(ctx); err != nil {
+			return err
+		}
+		location = ctx.Location.Current
+		if worldMap := cm.GetWorldMap(); worldMap != nil && worldMap.IsHostile(location) {
+			return fmt.Errorf("you can't rest in %s: it's too dangerous", location)
+		}
+
+		for rested < hours && !interrupted {
+			rested++
+			interrupted = cm.restEncounterChance > 0 && cm.dice.Load().Roll(100) <= cm.restEncounterChance
+		}
+
+		before := ctx.Character.Health.Current
+		ctx.Character.Health.Current += rested * cm.restHealPerHour
+		if ctx.Character.Health.Current > ctx.Character.Health.Max {
+			ctx.Character.Health.Current = ctx.Character.Health.Max
+		}
+		healed = ctx.Character.Health.Current - before
+
+		cm.advanceClock(ctx, rested*60)
+		if interrupted {
+			encounter = cm.rollEncounter(ctx)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	outcome := fmt.Sprintf("Rested for %d hours and recovered %d health.", rested, healed)
+	consequences := []string{"rested"}
+	if interrupted {
+		approached := "something"
+		if encounter != nil {
+			approached = encounter.Name
+		}
+		outcome = fmt.Sprintf("Rested for %d of %d hours and recovered %d health before %s approached.", rested, hours, healed, approached)
+		consequences = append(consequences, "random_encounter")
+	}
+
+	return cm.RecordActionWithMetadata(sessionID, fmt.Sprintf("/rest %d", hours), "rest", "", location, outcome, consequences, map[string]interface{}{
+		"rest_hours":       rested,
+		"health_healed":    healed,
+		"rest_interrupted": interrupted,
+	})
+}
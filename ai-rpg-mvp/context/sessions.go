@@ -0,0 +1,133 @@
+package context
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// maxSessionPageSize caps how many sessions a single ListSessions page holds
+const maxSessionPageSize = 100
+
+// SessionFilter narrows the sessions ListSessions returns. Zero fields don't
+// filter.
+type SessionFilter struct {
+	PlayerID      string        `json:"player_id,omitempty"`
+	Location      string        `json:"location,omitempty"`       // current location ID
+	MinReputation *int          `json:"min_reputation,omitempty"` // nil allows any reputation
+	ActiveWithin  time.Duration `json:"active_within,omitempty"`  // last updated no longer ago than this
+}
+
+// SessionInfo is a lightweight summary of a session for listings
+type SessionInfo struct {
+	SessionID  string    `json:"session_id"`
+	PlayerID   string    `json:"player_id"`
+	Name       string    `json:"name"`
+	Location   string    `json:"location"`
+	Reputation int       `json:"reputation"`
+	LastUpdate time.Time `json:"last_update"`
+}
+
+// matches reports whether a context passes the filter at the given time
+func (f SessionFilter) matches(ctx *PlayerContext, now time.Time) bool {
+	if f.PlayerID != "" && ctx.PlayerID != f.PlayerID {
+		return false
+	}
+	if f.Location != "" && ctx.Location.Current != f.Location {
+		return false
+	}
+	if f.MinReputation != nil && ctx.Character.Reputation < *f.MinReputation {
+		return false
+	}
+	if f.ActiveWithin > 0 && now.Sub(ctx.LastUpdate) > f.ActiveWithin {
+		return false
+	}
+	return true
+}
+
+// ListSessions returns one page of the active sessions matching filter, most
+// recently updated first, along with how many sessions match in total. Pages
+// are numbered from 1 and hold at most maxSessionPageSize sessions; a page
+// past the end is empty. Expired sessions awaiting the sweep are left out.
+func (cm *ContextManager) ListSessions(filter SessionFilter, page, pageSize int) ([]SessionInfo, int, error) {
+	if page < 1 {
+		return nil, 0, fmt.Errorf("page must be at least 1, got %d", page)
+	}
+	if pageSize < 1 || pageSize > maxSessionPageSize {
+		return nil, 0, fmt.Errorf("page size must be between 1 and %d, got %d", maxSessionPageSize, pageSize)
+	}
+
+	now := cm.now()
+	var sessions []SessionInfo
+	cm.cache.Range(func(key, value interface{}) bool {
+		sessionID := key.(string)
+		lock := cm.sessionLock(sessionID)
+		lock.Lock()
+		defer lock.Unlock()
+
+		// The session may have been evicted since Range read it
+		current, ok := cm.cache.Load(sessionID)
+		if !ok {
+			return true
+		}
+		ctx := current.(*PlayerContext)
+		if cm.sessionExpired(ctx, now) || !filter.matches(ctx, now) {
+			return true
+		}
+		sessions = append(sessions, newSessionInfo(ctx))
+		return true
+	})
+	sortSessions(sessions)
+
+	total := len(sessions)
+	start := (page - 1) * pageSize
+	if start >= total {
+		return []SessionInfo{}, total, nil
+	}
+	end := start + pageSize
+	if end > total {
+		end = total
+	}
+	return sessions[start:end], total, nil
+}
+
+// SummarizeAll returns a summary of every cached session, most recently
+// updated first, including expired ones the sweep hasn't removed yet
+func (cm *ContextManager) SummarizeAll() []SessionInfo {
+	sessions := []SessionInfo{}
+	cm.cache.Range(func(key, value interface{}) bool {
+		sessionID := key.(string)
+		lock := cm.sessionLock(sessionID)
+		lock.Lock()
+		defer lock.Unlock()
+
+		if current, ok := cm.cache.Load(sessionID); ok {
+			sessions = append(sessions, newSessionInfo(current.(*PlayerContext)))
+		}
+		return true
+	})
+	sortSessions(sessions)
+	return sessions
+}
+
+// newSessionInfo summarizes a context. Callers must hold the session lock.
+func newSessionInfo(ctx *PlayerContext) SessionInfo {
+	return SessionInfo{
+		SessionID:  ctx.SessionID,
+		PlayerID:   ctx.PlayerID,
+		Name:       ctx.Character.Name,
+		Location:   ctx.Location.Current,
+		Reputation: ctx.Character.Reputation,
+		LastUpdate: ctx.LastUpdate,
+	}
+}
+
+// sortSessions orders sessions most recently updated first, by ID on ties
+func sortSessions(sessions []SessionInfo) {
+	sort.Slice(sessions, func(i, j int) bool {
+		if !sessions[i].LastUpdate.Equal(sessions[j].LastUpdate) {
+			return sessions[i].LastUpdate.After(sessions[j].LastUpdate)
+		}
+		return sessions[i].SessionID < sessions[j].SessionID
+	})
+}
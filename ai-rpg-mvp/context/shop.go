@@ -0,0 +1,245 @@
+package context
+
+import (
+	"fmt"
+	"math"
+)
+
+// defaultBuyBackRate is the share of an item's price shops pay for it when
+// they don't set their own
+const defaultBuyBackRate = 0.5
+
+// dispositionPriceSpread is how far disposition moves prices: a devoted NPC
+// (disposition 100) gives this much off, a hostile one (-100) charges this
+// much extra, and what shops pay the player moves the opposite way
+const dispositionPriceSpread = 0.2
+
+// Shop is what an NPC sells. Stock is shared by every session that trades
+// with the NPC.
+type Shop struct {
+	Items       []ShopItem `json:"items"`
+	BuyBackRate float64    `json:"buy_back_rate"` // share of the price paid for items the player sells; 0 uses 0.5
+}
+
+// ShopItem is an item for sale. Item.Quantity is how many are in stock.
+type ShopItem struct {
+	Item  InventoryItem `json:"item"`
+	Price int           `json:"price"` // gold for one, before the NPC's disposition is taken into account
+}
+
+// Validate checks that every item has an ID, a price and a stock
+func (s Shop) Validate() error {
+	if s.BuyBackRate < 0 || s.BuyBackRate > 1 {
+		return fmt.Errorf("buy-back rate must be between 0 and 1, got %v", s.BuyBackRate)
+	}
+
+	seen := make(map[string]bool, len(s.Items))
+	for _, listing := range s.Items {
+		if listing.Item.ID == "" || seen[listing.Item.ID] {
+			return fmt.Errorf("shop items need unique, non-empty IDs")
+		}
+		seen[listing.Item.ID] = true
+		if listing.Price <= 0 {
+			return fmt.Errorf("item %s needs a positive price, got %d", listing.Item.ID, listing.Price)
+		}
+		if listing.Item.Quantity < 0 {
+			return fmt.Errorf("item %s has negative stock %d", listing.Item.ID, listing.Item.Quantity)
+		}
+	}
+	return nil
+}
+
+// find returns the index of an item in the shop, or -1
+func (s Shop) find(itemID string) int {
+	for i, listing := range s.Items {
+		if listing.Item.ID == itemID {
+			return i
+		}
+	}
+	return -1
+}
+
+// buyBackRate returns the share of the price the shop pays
+func (s Shop) buyBackRate() float64 {
+	if s.BuyBackRate == 0 {
+		return defaultBuyBackRate
+	}
+	return s.BuyBackRate
+}
+
+// RegisterShop sets up what an NPC sells, replacing any stock it had
+func (cm *ContextManager) RegisterShop(npcID string, shop Shop) error {
+	if npcID == "" {
+		return fmt.Errorf("NPC ID is required")
+	}
+	if err := shop.Validate(); err != nil {
+		return fmt.Errorf("invalid shop for %s: %w", npcID, err)
+	}
+
+	items := make([]ShopItem, len(shop.Items))
+	for i, listing := range shop.Items {
+		listing.Item.Metadata = copyMetadata(listing.Item.Metadata)
+		items[i] = listing
+	}
+	shop.Items = items
+
+	cm.registryMutex.Lock()
+	defer cm.registryMutex.Unlock()
+
+	cm.shops[npcID] = &shop
+	return nil
+}
+
+// GetShopItems returns what an NPC has in stock, priced for the player
+func (cm *ContextManager) GetShopItems(sessionID, npcID string) ([]ShopItem, error) {
+	ctx, err := cm.GetContext(sessionID)
+	if err != nil {
+		return nil, err
+	}
+	disposition, discount, err := cm.shopTerms(ctx, npcID)
+	if err != nil {
+		return nil, err
+	}
+
+	cm.registryMutex.RLock()
+	defer cm.registryMutex.RUnlock()
+
+	shop, exists := cm.shops[npcID]
+	if !exists {
+		return nil, fmt.Errorf("%s has no shop", npcID)
+	}
+
+	var items []ShopItem
+	for _, listing := range shop.Items {
+		if listing.Item.Quantity > 0 {
+			listing.Item.Metadata = copyMetadata(listing.Item.Metadata)
+			listing.Price = buyPrice(listing.Price, disposition, discount)
+			items = append(items, listing)
+		}
+	}
+	return items, nil
+}
+
+// BuyItem buys one of an item from an NPC's shop, paying its price after the
+// NPC's disposition discount or markup and any discount for an honored
+// player. It fails if the NPC won't trade with the player, the item is out of
+// stock or the player can't afford it.
+func (cm *ContextManager) BuyItem(sessionID, npcID, itemID string) error {
+	return cm.mutateContext(sessionID, func(ctx *PlayerContext) error {
+		if err := checkAlive(ctx); err != nil {
+			return err
+		}
+		disposition, discount, err := cm.shopTerms(ctx, npcID)
+		if err != nil {
+			return err
+		}
+
+		cm.registryMutex.Lock()
+		defer cm.registryMutex.Unlock()
+
+		shop, exists := cm.shops[npcID]
+		if !exists {
+			return fmt.Errorf("%s has no shop", npcID)
+		}
+		index := shop.find(itemID)
+		if index < 0 || shop.Items[index].Item.Quantity == 0 {
+			return fmt.Errorf("%s has no %s for sale", npcID, itemID)
+		}
+
+		listing := &shop.Items[index]
+		price := buyPrice(listing.Price, disposition, discount)
+		if ctx.Character.Gold < price {
+			return fmt.Errorf("cannot afford %s: it costs %d gold, only %d available", listing.Item.Name, price, ctx.Character.Gold)
+		}
+
+		ctx.Character.Gold -= price
+		listing.Item.Quantity--
+		item := listing.Item
+		item.Quantity = 1
+		addItemToInventory(ctx, item)
+		return nil
+	})
+}
+
+// SellItem sells one of an item from the player's inventory to an NPC's shop,
+// which pays its buy-back rate of the price, more from a friendlier NPC. The
+// shop prices the item as it lists it, or by the item's value if it doesn't
+// stock it, and won't buy worthless items.
+func (cm *ContextManager) SellItem(sessionID, npcID, itemID string) error {
+	return cm.mutateContext(sessionID, func(ctx *PlayerContext) error {
+		if err := checkAlive(ctx); err != nil {
+			return err
+		}
+		inventoryIndex := findInventoryItem(ctx, itemID)
+		if inventoryIndex < 0 {
+			return fmt.Errorf("item %s not in inventory", itemID)
+		}
+		disposition, _, err := cm.shopTerms(ctx, npcID)
+		if err != nil {
+			return err
+		}
+
+		cm.registryMutex.Lock()
+		defer cm.registryMutex.Unlock()
+
+		shop, exists := cm.shops[npcID]
+		if !exists {
+			return fmt.Errorf("%s has no shop", npcID)
+		}
+
+		item := ctx.Character.Inventory[inventoryIndex]
+		index := shop.find(itemID)
+		basePrice := item.Value
+		if index >= 0 {
+			basePrice = shop.Items[index].Price
+		}
+		payment := sellPrice(basePrice, shop.buyBackRate(), disposition)
+		if payment <= 0 {
+			return fmt.Errorf("%s won't buy %s", npcID, item.Name)
+		}
+
+		if index >= 0 {
+			shop.Items[index].Item.Quantity++
+		} else {
+			stocked := item
+			stocked.Quantity = 1
+			stocked.Metadata = copyMetadata(item.Metadata)
+			shop.Items = append(shop.Items, ShopItem{Item: stocked, Price: basePrice})
+		}
+
+		ctx.Character.Gold += payment
+		ctx.Character.Inventory[inventoryIndex].Quantity--
+		if ctx.Character.Inventory[inventoryIndex].Quantity <= 0 {
+			cm.removeItemFromInventory(ctx, itemID)
+		}
+		return nil
+	})
+}
+
+// shopTerms is how an NPC trades with the player: its disposition and any
+// discount it gives, or an error if it won't trade with them at all
+func (cm *ContextManager) shopTerms(ctx *PlayerContext, npcID string) (int, float64, error) {
+	disposition := cm.npcDisposition(ctx, npcID)
+	switch cm.npcStance(ctx.Character.Reputation, disposition) {
+	case NPCStanceRefuses, NPCStanceAttacks:
+		return 0, 0, fmt.Errorf("%s won't trade with you", npcID)
+	case NPCStanceWelcomes:
+		return disposition, honoredDiscount, nil
+	}
+	return disposition, 0, nil
+}
+
+// buyPrice is what the player pays for an item of basePrice after discount,
+// never less than 1 gold
+func buyPrice(basePrice, disposition int, discount float64) int {
+	price := int(math.Round(float64(basePrice) * (1 - dispositionPriceSpread*float64(disposition)/100) * (1 - discount)))
+	if price < 1 {
+		return 1
+	}
+	return price
+}
+
+// sellPrice is what a shop pays the player for an item of basePrice
+func sellPrice(basePrice int, rate float64, disposition int) int {
+	return int(math.Floor(float64(basePrice) * rate * (1 + dispositionPriceSpread*float64(disposition)/100)))
+}
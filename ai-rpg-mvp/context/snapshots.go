@@ -0,0 +1,88 @@
+package context
+
+import (
+	"fmt"
+
+	"github.com/google/uuid"
+)
+
+// CreateSnapshot saves a named copy of the session's current context and
+// returns its ID
+func (cm *ContextManager) CreateSnapshot(sessionID, label string) (string, error) {
+	store, err := cm.snapshotStorage()
+	if err != nil {
+		return "", err
+	}
+
+	ctx, err := cm.GetContext(sessionID)
+	if err != nil {
+		return "", err
+	}
+
+	snapshot := &Snapshot{
+		SnapshotInfo: SnapshotInfo{
+			ID:        uuid.New().String(),
+			SessionID: sessionID,
+			Label:     label,
+			CreatedAt: cm.now(),
+		},
+		Context: ctx,
+	}
+
+	if err := store.SaveSnapshot(snapshot); err != nil {
+		return "", err
+	}
+
+	return snapshot.ID, nil
+}
+
+// RestoreSnapshot replaces the session's live context with a snapshot,
+// unless the snapshot fails ValidateContext
+func (cm *ContextManager) RestoreSnapshot(sessionID, snapshotID string) error {
+	store, err := cm.snapshotStorage()
+	if err != nil {
+		return err
+	}
+
+	snapshot, err := store.LoadSnapshot(sessionID, snapshotID)
+	if err != nil {
+		return err
+	}
+	if snapshot.Context == nil {
+		return fmt.Errorf("snapshot %s has no context", snapshotID)
+	}
+	if err := ValidateContext(snapshot.Context); err != nil {
+		return fmt.Errorf("snapshot %s: %w", snapshotID, err)
+	}
+
+	err = cm.mutateContext(sessionID, func(ctx *PlayerContext) error {
+		*ctx = *snapshot.Context.Clone()
+		ctx.SessionID = sessionID
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	// Persist the rollback right away rather than waiting for the next autosave
+	return cm.saveCachedContext(sessionID)
+}
+
+// ListSnapshots returns the snapshots saved for a session, oldest first
+func (cm *ContextManager) ListSnapshots(sessionID string) ([]SnapshotInfo, error) {
+	store, err := cm.snapshotStorage()
+	if err != nil {
+		return nil, err
+	}
+
+	return store.ListSnapshots(sessionID)
+}
+
+// snapshotStorage returns the storage backend's snapshot support, if any
+func (cm *ContextManager) snapshotStorage() (SnapshotStorage, error) {
+	store, ok := cm.storage.(SnapshotStorage)
+	if !ok {
+		return nil, fmt.Errorf("storage backend does not support snapshots")
+	}
+	return store, nil
+}
@@ -0,0 +1,206 @@
+package context
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// DefaultStatusTick is how often status effects apply their per-tick health change
+const DefaultStatusTick = 30 * time.Second
+
+// ApplyStatusEffect adds a status effect to the player, replacing any active
+// effect with the same name
+func (cm *ContextManager) ApplyStatusEffect(sessionID string, effect StatusEffect) error {
+	if effect.Name == "" {
+		return fmt.Errorf("status effect name is required")
+	}
+	if !effect.ExpiresAt.After(cm.now()) {
+		return fmt.Errorf("status effect %s has already expired", effect.Name)
+	}
+
+	effect.AttributeModifiers = copyIntMap(effect.AttributeModifiers)
+	if effect.AppliedAt.IsZero() {
+		effect.AppliedAt = cm.now()
+	}
+
+	return cm.mutateContext(sessionID, func(ctx *PlayerContext) error {
+		setStatusEffect(ctx, effect)
+		return nil
+	})
+}
+
+// setStatusEffect adds an effect to the character, replacing any with the
+// same name
+func setStatusEffect(ctx *PlayerContext, effect StatusEffect) {
+	for i, existing := range ctx.Character.StatusEffects {
+		if existing.Name == effect.Name {
+			ctx.Character.StatusEffects[i] = effect
+			return
+		}
+	}
+	ctx.Character.StatusEffects = append(ctx.Character.StatusEffects, effect)
+}
+
+// GetStatusEffects returns the player's active status effects
+func (cm *ContextManager) GetStatusEffects(sessionID string) ([]StatusEffect, error) {
+	ctx, err := cm.GetContext(sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	return activeStatusEffects(ctx.Character.StatusEffects, cm.now()), nil
+}
+
+// GetEffectiveAttributes returns the player's attributes with the modifiers of
+// all active status effects added on top of the base values
+func (cm *ContextManager) GetEffectiveAttributes(sessionID string) (map[string]int, error) {
+	ctx, err := cm.GetContext(sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	return effectiveAttributes(ctx.Character, cm.now()), nil
+}
+
+// effectiveAttributes sums base attributes and active modifiers. Base values
+// are never modified, so an expired effect leaves nothing behind.
+func effectiveAttributes(character CharacterState, now time.Time) map[string]int {
+	attributes := copyIntMap(character.Attributes)
+	if attributes == nil {
+		attributes = make(map[string]int)
+	}
+
+	for _, effect := range activeStatusEffects(character.StatusEffects, now) {
+		for attribute, modifier := range effect.AttributeModifiers {
+			attributes[attribute] += modifier
+		}
+	}
+	return attributes
+}
+
+// activeStatusEffects returns the effects that have not expired by now
+func activeStatusEffects(effects []StatusEffect, now time.Time) []StatusEffect {
+	active := make([]StatusEffect, 0, len(effects))
+	for _, effect := range effects {
+		if effect.ExpiresAt.After(now) {
+			active = append(active, effect)
+		}
+	}
+	return active
+}
+
+// statusTicker periodically ticks status effects for cached sessions
+func (cm *ContextManager) statusTicker() {
+	defer cm.wg.Done()
+
+	ticker := time.NewTicker(cm.statusTick)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			cm.tickStatusEffects(cm.now())
+		case <-cm.shutdownCh:
+			return
+		}
+	}
+}
+
+// tickStatusEffects moves the weather on, regenerates mana and stamina,
+// drops expired effects and applies the health change of the remaining ones
+// for every cached session
+func (cm *ContextManager) tickStatusEffects(now time.Time) {
+	cm.cache.Range(func(key, value interface{}) bool {
+		cm.tickSession(key.(string), func(ctx *PlayerContext) bool {
+			changed := cm.updateWeather(ctx)
+			if !ctx.Character.Alive {
+				return changed
+			}
+
+			if regenerateResources(&ctx.Character) {
+				changed = true
+			}
+			if len(ctx.Character.StatusEffects) == 0 {
+				return changed
+			}
+
+			health, effects := ctx.Character.Health.Current, len(ctx.Character.StatusEffects)
+			ctx.Character.StatusEffects = activeStatusEffects(ctx.Character.StatusEffects, now)
+			for _, effect := range ctx.Character.StatusEffects {
+				ctx.Character.Health.Current += effect.HealthPerTick
+			}
+
+			if ctx.Character.Health.Current > ctx.Character.Health.Max {
+				ctx.Character.Health.Current = ctx.Character.Health.Max
+			} else if ctx.Character.Health.Current < 0 {
+				ctx.Character.Health.Current = 0
+			}
+			return changed || ctx.Character.Health.Current != health || len(ctx.Character.StatusEffects) != effects
+		})
+		return true
+	})
+}
+
+// tickSession applies a background tick to a cached session. fn reports
+// whether it changed anything; only then is the change versioned, published
+// and marked for saving. LastUpdate is left alone either way, since a tick
+// isn't the player doing anything, so idle sessions still age out of the
+// cache and skip periodic saves. Sessions evicted since the tick began stay
+// evicted.
+func (cm *ContextManager) tickSession(sessionID string, fn func(ctx *PlayerContext) bool) {
+	lock := cm.sessionLock(sessionID)
+	lock.Lock()
+	defer lock.Unlock()
+
+	cached, ok := cm.cache.Load(sessionID)
+	if !ok {
+		return
+	}
+	ctx := cached.(*PlayerContext)
+
+	locationBefore, reputationBefore := ctx.Location.Current, ctx.Character.Reputation
+	before, version := captureTrackedState(ctx), ctx.Version
+	if !fn(ctx) {
+		return
+	}
+
+	cm.dirty.Store(sessionID, struct{}{})
+	cm.stampChanges(ctx, before, version)
+	cm.publishStateChanges(ctx, locationBefore, reputationBefore)
+	if cm.checkDeath(ctx) {
+		cm.writeThrough(ctx)
+	}
+}
+
+// formatStatusEffects describes active status effects for AI prompts
+func (cm *ContextManager) formatStatusEffects(effects []StatusEffect) string {
+	now := cm.now()
+	active := activeStatusEffects(effects, now)
+	if len(active) == 0 {
+		return "None"
+	}
+
+	descriptions := make([]string, 0, len(active))
+	for _, effect := range active {
+		var details []string
+		if effect.HealthPerTick != 0 {
+			details = append(details, fmt.Sprintf("%+d health per tick", effect.HealthPerTick))
+		}
+
+		attributes := make([]string, 0, len(effect.AttributeModifiers))
+		for attribute := range effect.AttributeModifiers {
+			attributes = append(attributes, attribute)
+		}
+		sort.Strings(attributes)
+		for _, attribute := range attributes {
+			details = append(details, fmt.Sprintf("%+d %s", effect.AttributeModifiers[attribute], attribute))
+		}
+
+		details = append(details, fmt.Sprintf("%s left", effect.ExpiresAt.Sub(now).Round(time.Second)))
+		descriptions = append(descriptions, fmt.Sprintf("%s (%s)", effect.Name, strings.Join(details, ", ")))
+	}
+
+	return strings.Join(descriptions, "; ")
+}
@@ -4,6 +4,8 @@ import (
 	"database/sql"
 	"encoding/json"
 	"fmt"
+	"log/slog"
+	"sort"
 	"sync"
 	"time"
 
@@ -12,14 +14,16 @@ import (
 
 // MemoryContextStorage provides in-memory storage for development
 type MemoryContextStorage struct {
-	contexts map[string]*PlayerContext
-	mutex    sync.RWMutex
+	contexts  map[string]*PlayerContext
+	snapshots map[string][]*Snapshot // session_id -> snapshots, oldest first
+	mutex     sync.RWMutex
 }
 
 // NewMemoryStorage creates a new in-memory storage instance
 func NewMemoryStorage() *MemoryContextStorage {
 	return &MemoryContextStorage{
-		contexts: make(map[string]*PlayerContext),
+		contexts:  make(map[string]*PlayerContext),
+		snapshots: make(map[string][]*Snapshot),
 	}
 }
 
@@ -30,12 +34,11 @@ func (s *MemoryContextStorage) LoadContext(sessionID string) (*PlayerContext, er
 
 	ctx, exists := s.contexts[sessionID]
 	if !exists {
-		return nil, fmt.Errorf("context not found for session %s", sessionID)
+		return nil, fmt.Errorf("%w: %s", ErrSessionNotFound, sessionID)
 	}
 
 	// Return a copy to avoid concurrent modification
-	contextCopy := *ctx
-	return &contextCopy, nil
+	return ctx.Clone(), nil
 }
 
 // SaveContext saves a context to memory
@@ -44,8 +47,7 @@ func (s *MemoryContextStorage) SaveContext(ctx *PlayerContext) error {
 	defer s.mutex.Unlock()
 
 	// Create a copy to avoid sharing references
-	contextCopy := *ctx
-	s.contexts[ctx.SessionID] = &contextCopy
+	s.contexts[ctx.SessionID] = ctx.Clone()
 	return nil
 }
 
@@ -70,6 +72,72 @@ func (s *MemoryContextStorage) ListActiveSessions() ([]string, error) {
 	return sessions, nil
 }
 
+// BackupContexts exports all contexts as a JSON array, ordered by session ID
+func (s *MemoryContextStorage) BackupContexts() ([]byte, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	contexts := make([]*PlayerContext, 0, len(s.contexts))
+	for _, ctx := range s.contexts {
+		contexts = append(contexts, ctx)
+	}
+	sort.Slice(contexts, func(i, j int) bool {
+		return contexts[i].SessionID < contexts[j].SessionID
+	})
+
+	return json.MarshalIndent(contexts, "", "  ")
+}
+
+// RestoreContexts imports a backup made by BackupContexts, replacing any
+// contexts with the same session IDs
+func (s *MemoryContextStorage) RestoreContexts(data []byte) (int, error) {
+	return restoreContexts(data, s.SaveContext)
+}
+
+// Ping always succeeds; memory storage has nothing to reach
+func (s *MemoryContextStorage) Ping() error {
+	return nil
+}
+
+// SaveSnapshot stores a snapshot in memory
+func (s *MemoryContextStorage) SaveSnapshot(snapshot *Snapshot) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	stored := *snapshot
+	stored.Context = snapshot.Context.Clone()
+	s.snapshots[snapshot.SessionID] = append(s.snapshots[snapshot.SessionID], &stored)
+	return nil
+}
+
+// LoadSnapshot loads a snapshot from memory
+func (s *MemoryContextStorage) LoadSnapshot(sessionID, snapshotID string) (*Snapshot, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	for _, snapshot := range s.snapshots[sessionID] {
+		if snapshot.ID == snapshotID {
+			loaded := *snapshot
+			loaded.Context = snapshot.Context.Clone()
+			return &loaded, nil
+		}
+	}
+
+	return nil, fmt.Errorf("snapshot %s not found for session %s", snapshotID, sessionID)
+}
+
+// ListSnapshots returns the snapshots saved for a session, oldest first
+func (s *MemoryContextStorage) ListSnapshots(sessionID string) ([]SnapshotInfo, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	infos := make([]SnapshotInfo, 0, len(s.snapshots[sessionID]))
+	for _, snapshot := range s.snapshots[sessionID] {
+		infos = append(infos, snapshot.SnapshotInfo)
+	}
+	return infos, nil
+}
+
 // GetStats returns storage statistics
 func (s *MemoryContextStorage) GetStats() map[string]interface{} {
 	s.mutex.RLock()
@@ -123,6 +191,16 @@ func (s *PostgreSQLContextStorage) initSchema() error {
 	CREATE INDEX IF NOT EXISTS idx_player_contexts_last_update ON player_contexts(last_update);
 	CREATE INDEX IF NOT EXISTS idx_player_contexts_context_data ON player_contexts USING GIN(context_data);
 
+	CREATE TABLE IF NOT EXISTS context_snapshots (
+		snapshot_id VARCHAR(255) PRIMARY KEY,
+		session_id VARCHAR(255) NOT NULL,
+		label TEXT NOT NULL DEFAULT '',
+		context_data JSONB NOT NULL,
+		created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_context_snapshots_session_id ON context_snapshots(session_id, created_at);
+
 	-- Clean up old contexts periodically (optional)
 	CREATE TABLE IF NOT EXISTS context_cleanup_log (
 		id SERIAL PRIMARY KEY,
@@ -143,7 +221,7 @@ func (s *PostgreSQLContextStorage) LoadContext(sessionID string) (*PlayerContext
 	err := s.db.QueryRow(query, sessionID).Scan(&contextJSON)
 	if err != nil {
 		if err == sql.ErrNoRows {
-			return nil, fmt.Errorf("context not found for session %s", sessionID)
+			return nil, fmt.Errorf("%w: %s", ErrSessionNotFound, sessionID)
 		}
 		return nil, fmt.Errorf("failed to load context: %w", err)
 	}
@@ -227,6 +305,84 @@ func (s *PostgreSQLContextStorage) ListActiveSessions() ([]string, error) {
 	return sessions, nil
 }
 
+// Ping checks that the database is reachable
+func (s *PostgreSQLContextStorage) Ping() error {
+	if err := s.db.Ping(); err != nil {
+		return fmt.Errorf("failed to ping database: %w", err)
+	}
+	return nil
+}
+
+// SaveSnapshot saves a snapshot to PostgreSQL
+func (s *PostgreSQLContextStorage) SaveSnapshot(snapshot *Snapshot) error {
+	query := `
+		INSERT INTO context_snapshots (snapshot_id, session_id, label, context_data, created_at)
+		VALUES ($1, $2, $3, $4, $5)
+	`
+
+	contextJSON, err := json.Marshal(snapshot.Context)
+	if err != nil {
+		return fmt.Errorf("failed to marshal snapshot: %w", err)
+	}
+
+	_, err = s.db.Exec(query, snapshot.ID, snapshot.SessionID, snapshot.Label, contextJSON, snapshot.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to save snapshot: %w", err)
+	}
+
+	return nil
+}
+
+// LoadSnapshot loads a snapshot from PostgreSQL
+func (s *PostgreSQLContextStorage) LoadSnapshot(sessionID, snapshotID string) (*Snapshot, error) {
+	query := `
+		SELECT label, context_data, created_at FROM context_snapshots
+		WHERE session_id = $1 AND snapshot_id = $2
+	`
+
+	snapshot := &Snapshot{SnapshotInfo: SnapshotInfo{ID: snapshotID, SessionID: sessionID}}
+	var contextJSON []byte
+	err := s.db.QueryRow(query, sessionID, snapshotID).Scan(&snapshot.Label, &contextJSON, &snapshot.CreatedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("snapshot %s not found for session %s", snapshotID, sessionID)
+		}
+		return nil, fmt.Errorf("failed to load snapshot: %w", err)
+	}
+
+	if err := json.Unmarshal(contextJSON, &snapshot.Context); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal snapshot: %w", err)
+	}
+
+	return snapshot, nil
+}
+
+// ListSnapshots returns the snapshots saved for a session, oldest first
+func (s *PostgreSQLContextStorage) ListSnapshots(sessionID string) ([]SnapshotInfo, error) {
+	query := "SELECT snapshot_id, label, created_at FROM context_snapshots WHERE session_id = $1 ORDER BY created_at"
+
+	rows, err := s.db.Query(query, sessionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list snapshots: %w", err)
+	}
+	defer rows.Close()
+
+	infos := []SnapshotInfo{}
+	for rows.Next() {
+		info := SnapshotInfo{SessionID: sessionID}
+		if err := rows.Scan(&info.ID, &info.Label, &info.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan snapshot: %w", err)
+		}
+		infos = append(infos, info)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating rows: %w", err)
+	}
+
+	return infos, nil
+}
+
 // GetContextsByPlayer returns all contexts for a specific player
 func (s *PostgreSQLContextStorage) GetContextsByPlayer(playerID string) ([]PlayerContext, error) {
 	query := "SELECT context_data FROM player_contexts WHERE player_id = $1 ORDER BY last_update DESC"
@@ -352,3 +508,43 @@ func (s *PostgreSQLContextStorage) BackupContexts() ([]byte, error) {
 
 	return json.MarshalIndent(contexts, "", "  ")
 }
+
+// RestoreContexts imports a backup made by BackupContexts, replacing any
+// contexts with the same session IDs
+func (s *PostgreSQLContextStorage) RestoreContexts(data []byte) (int, error) {
+	return restoreContexts(data, s.SaveContext)
+}
+
+// restoreContexts saves every well-formed context in a JSON array backup.
+// Entries that don't decode, have no session ID or fail ValidateContext are
+// skipped; the count of contexts saved is returned.
+func restoreContexts(data []byte, save func(ctx *PlayerContext) error) (int, error) {
+	var entries []json.RawMessage
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return 0, fmt.Errorf("failed to parse backup: %w", err)
+	}
+
+	imported := 0
+	for i, entry := range entries {
+		var ctx PlayerContext
+		if err := json.Unmarshal(entry, &ctx); err != nil {
+			slog.Warn("Skipping malformed context in backup", "index", i, "error", err)
+			continue
+		}
+		if ctx.SessionID == "" {
+			slog.Warn("Skipping context in backup with no session ID", "index", i)
+			continue
+		}
+		if err := ValidateContext(&ctx); err != nil {
+			slog.Warn("Skipping invalid context in backup", "index", i, "session_id", ctx.SessionID, "error", err)
+			continue
+		}
+
+		if err := save(&ctx); err != nil {
+			return imported, fmt.Errorf("failed to restore session %s: %w", ctx.SessionID, err)
+		}
+		imported++
+	}
+
+	return imported, nil
+}
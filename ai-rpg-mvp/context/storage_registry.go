@@ -0,0 +1,70 @@
+package context
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"ai-rpg-mvp/config"
+)
+
+// StorageFactory builds a storage backend from the application config
+type StorageFactory func(cfg config.Config) (ContextStorage, error)
+
+var (
+	storageFactories = make(map[string]StorageFactory)
+	storageMutex     sync.RWMutex
+)
+
+func init() {
+	RegisterStorage("memory", func(cfg config.Config) (ContextStorage, error) {
+		return NewMemoryStorage(), nil
+	})
+	RegisterStorage("postgres", func(cfg config.Config) (ContextStorage, error) {
+		return NewPostgreSQLStorage(cfg.Database.URL)
+	})
+	RegisterStorage("redis", func(cfg config.Config) (ContextStorage, error) {
+		return NewRedisStorage(cfg.Redis)
+	})
+}
+
+// RegisterStorage makes a storage backend available to NewStorage under a
+// name, replacing any backend already registered with it. Names are case
+// insensitive.
+func RegisterStorage(name string, factory StorageFactory) {
+	storageMutex.Lock()
+	defer storageMutex.Unlock()
+
+	storageFactories[strings.ToLower(name)] = factory
+}
+
+// NewStorage builds the storage backend registered under name
+func NewStorage(name string, cfg config.Config) (ContextStorage, error) {
+	storageMutex.RLock()
+	factory, exists := storageFactories[strings.ToLower(name)]
+	storageMutex.RUnlock()
+
+	if !exists {
+		return nil, fmt.Errorf("unknown storage backend %q (registered: %s)", name, strings.Join(StorageBackends(), ", "))
+	}
+
+	storage, err := factory(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create %s storage: %w", name, err)
+	}
+	return storage, nil
+}
+
+// StorageBackends returns the names of the registered storage backends, sorted
+func StorageBackends() []string {
+	storageMutex.RLock()
+	defer storageMutex.RUnlock()
+
+	names := make([]string, 0, len(storageFactories))
+	for name := range storageFactories {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,122 @@
+package context
+
+import (
+	"sync"
+	"time"
+)
+
+// SubscriberBuffer is how many changes a subscriber can fall behind by before
+// further changes are dropped for it
+const SubscriberBuffer = 64
+
+// Context change types
+const (
+	ChangeActionRecorded    = "action_recorded"
+	ChangeLocationChanged   = "location_changed"
+	ChangeReputationChanged = "reputation_changed"
+	ChangeLeveledUp         = "leveled_up" // a level_up consequence granted attribute points
+	ChangePlayerDied        = "player_died"
+	ChangeSessionExpiring   = "session_expiring" // an idle session will soon expire
+	ChangeSessionExpired    = "session_expired"  // an idle session expired and was evicted
+)
+
+// ContextChange describes something that changed in a session's context
+type ContextChange struct {
+	Type      string       `json:"type"`
+	SessionID string       `json:"session_id"`
+	Timestamp time.Time    `json:"timestamp"`
+	Action    *ActionEvent `json:"action,omitempty"`     // the recorded action
+	From      string       `json:"from,omitempty"`       // previous location
+	To        string       `json:"to,omitempty"`         // new location, or where the player died
+	Delta     int          `json:"delta,omitempty"`      // reputation change, or attribute points granted
+	Value     int          `json:"value,omitempty"`      // new reputation, unspent attribute points or death count
+	ExpiresAt *time.Time   `json:"expires_at,omitempty"` // when an idle session expires
+
+	Achievement *Achievement `json:"achievement,omitempty"` // the unlocked achievement
+}
+
+// subscriberSet holds the channels listening to each session
+type subscriberSet struct {
+	channels map[string]map[int]chan ContextChange // session_id -> id -> channel
+	nextID   int
+	mutex    sync.RWMutex
+}
+
+// Subscribe returns a channel of changes to a session's context and a
+// function that stops the subscription and closes the channel. Delivery never
+// blocks the manager: a subscriber that falls SubscriberBuffer changes behind
+// misses the changes that follow until it catches up. The returned function
+// is safe to call more than once.
+func (cm *ContextManager) Subscribe(sessionID string) (<-chan ContextChange, func()) {
+	ch := make(chan ContextChange, SubscriberBuffer)
+
+	subs := &cm.subscribers
+	subs.mutex.Lock()
+	if subs.channels == nil {
+		subs.channels = make(map[string]map[int]chan ContextChange)
+	}
+	if subs.channels[sessionID] == nil {
+		subs.channels[sessionID] = make(map[int]chan ContextChange)
+	}
+	id := subs.nextID
+	subs.nextID++
+	subs.channels[sessionID][id] = ch
+	subs.mutex.Unlock()
+
+	var once sync.Once
+	unsubscribe := func() {
+		once.Do(func() {
+			subs.mutex.Lock()
+			defer subs.mutex.Unlock()
+
+			delete(subs.channels[sessionID], id)
+			if len(subs.channels[sessionID]) == 0 {
+				delete(subs.channels, sessionID)
+			}
+			close(ch)
+		})
+	}
+
+	return ch, unsubscribe
+}
+
+// publish fans a change out to the session's subscribers without blocking
+func (cm *ContextManager) publish(change ContextChange) {
+	subs := &cm.subscribers
+	subs.mutex.RLock()
+	defer subs.mutex.RUnlock()
+
+	for _, ch := range subs.channels[change.SessionID] {
+		select {
+		case ch <- change:
+		default:
+			// Subscriber is full; drop rather than stall the caller
+		}
+	}
+}
+
+// publishStateChanges publishes the location and reputation changes between
+// two points in a context's life
+func (cm *ContextManager) publishStateChanges(ctx *PlayerContext, locationBefore string, reputationBefore int) {
+	now := cm.now()
+
+	if ctx.Location.Current != locationBefore {
+		cm.publish(ContextChange{
+			Type:      ChangeLocationChanged,
+			SessionID: ctx.SessionID,
+			Timestamp: now,
+			From:      locationBefore,
+			To:        ctx.Location.Current,
+		})
+	}
+
+	if delta := ctx.Character.Reputation - reputationBefore; delta != 0 {
+		cm.publish(ContextChange{
+			Type:      ChangeReputationChanged,
+			SessionID: ctx.SessionID,
+			Timestamp: now,
+			Delta:     delta,
+			Value:     ctx.Character.Reputation,
+		})
+	}
+}
@@ -0,0 +1,90 @@
+package context
+
+import (
+	"fmt"
+)
+
+// Summarizer folds a session's oldest actions into its running summary.
+// ai.AIService satisfies it.
+type Summarizer interface {
+	SummarizeHistory(actions []ActionEvent, prior string) (string, error)
+}
+
+// SetSummarizer enables history summaries. Once a session holds more than the
+// summary threshold of actions, its oldest batch is summarized in the
+// background and removed, so the GM keeps the story without the prompt
+// growing. Actions past maxActions are still dropped if summaries fall behind.
+func (cm *ContextManager) SetSummarizer(summarizer Summarizer) {
+	cm.registryMutex.Lock()
+	defer cm.registryMutex.Unlock()
+
+	cm.summarizer = summarizer
+}
+
+// currentSummarizer returns the configured summarizer, or nil
+func (cm *ContextManager) currentSummarizer() Summarizer {
+	cm.registryMutex.RLock()
+	defer cm.registryMutex.RUnlock()
+
+	return cm.summarizer
+}
+
+// maybeSummarize starts summarizing a session's oldest actions when it holds
+// too many and no summary is already being written for it. It never waits for
+// the summary.
+func (cm *ContextManager) maybeSummarize(sessionID string, actionCount int) {
+	summarizer := cm.currentSummarizer()
+	if summarizer == nil || actionCount <= cm.summaryThreshold {
+		return
+	}
+	if _, busy := cm.summarizing.LoadOrStore(sessionID, struct{}{}); busy {
+		return
+	}
+
+	cm.aiTasks.Add(1)
+	go func() {
+		defer cm.aiTasks.Done()
+		defer cm.summarizing.Delete(sessionID)
+
+		if err := cm.summarizeOldestActions(sessionID, summarizer); err != nil {
+			cm.log().Warn("Failed to summarize history", "session_id", sessionID, "error", err)
+		}
+	}()
+}
+
+// summarizeOldestActions folds the oldest batch of actions into the session's
+// history summary and removes them. The summarizer runs without the session
+// lock, so actions recorded meanwhile are kept.
+func (cm *ContextManager) summarizeOldestActions(sessionID string, summarizer Summarizer) error {
+	ctx, err := cm.GetContext(sessionID)
+	if err != nil {
+		return err
+	}
+
+	batch := ctx.Actions[:min(cm.summaryBatch, len(ctx.Actions))]
+	if len(batch) == 0 {
+		return nil
+	}
+
+	summary, err := summarizer.SummarizeHistory(batch, ctx.HistorySummary)
+	if err != nil {
+		return fmt.Errorf("summarizer failed: %w", err)
+	}
+
+	folded := make(map[string]bool, len(batch))
+	for _, action := range batch {
+		folded[action.ID] = true
+	}
+
+	return cm.mutateContext(sessionID, func(ctx *PlayerContext) error {
+		kept := make([]ActionEvent, 0, len(ctx.Actions))
+		for _, action := range ctx.Actions {
+			if !folded[action.ID] {
+				kept = append(kept, action)
+			}
+		}
+		ctx.Actions = kept
+		ctx.HistorySummary = summary
+		return nil
+	})
+}
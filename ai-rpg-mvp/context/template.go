@@ -0,0 +1,56 @@
+package context
+
+import (
+	"fmt"
+)
+
+// defaultStartingLocation is where characters start when a template sets none
+const defaultStartingLocation = "starting_village"
+
+// CharacterTemplate describes how a new character starts out
+type CharacterTemplate struct {
+	MaxHealth        int             `json:"max_health"`
+	Attributes       map[string]int  `json:"attributes"`
+	Gold             int             `json:"gold"`
+	Equipment        []EquipmentItem `json:"equipment"`
+	Inventory        []InventoryItem `json:"inventory"`
+	StartingLocation string          `json:"starting_location"` // defaults to "starting_village"
+}
+
+// DefaultCharacterTemplate returns the stats CreateSession gives new characters
+func DefaultCharacterTemplate() CharacterTemplate {
+	return CharacterTemplate{
+		MaxHealth: 20,
+		Attributes: map[string]int{
+			"strength":     10,
+			"dexterity":    10,
+			"intelligence": 10,
+			"charisma":     10,
+		},
+		StartingLocation: defaultStartingLocation,
+	}
+}
+
+// Validate checks that the template describes a playable character
+func (t CharacterTemplate) Validate() error {
+	if t.MaxHealth <= 0 {
+		return fmt.Errorf("max health must be positive, got %d", t.MaxHealth)
+	}
+	if t.Gold < 0 {
+		return fmt.Errorf("starting gold cannot be negative, got %d", t.Gold)
+	}
+	for attribute, value := range t.Attributes {
+		if value < 0 {
+			return fmt.Errorf("attribute %s cannot be negative, got %d", attribute, value)
+		}
+	}
+	for _, item := range t.Inventory {
+		if item.ID == "" {
+			return fmt.Errorf("inventory item ID is required")
+		}
+		if item.Quantity < 0 {
+			return fmt.Errorf("invalid quantity %d for item %s", item.Quantity, item.ID)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,156 @@
+package context
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// transcriptTimeFormat is how transcript entries are timestamped
+const transcriptTimeFormat = "2006-01-02 15:04:05 UTC"
+
+// transcriptEntry is one moment in a session transcript
+type transcriptEntry struct {
+	timestamp time.Time
+	heading   string
+	body      string
+}
+
+// ExportTranscript renders a session as Markdown: a header describing the
+// character, a timeline of actions, GM outcomes, location changes and NPC
+// milestones ordered by time, and a closing summary
+func (cm *ContextManager) ExportTranscript(sessionID string) (string, error) {
+	ctx, err := cm.GetContext(sessionID)
+	if err != nil {
+		return "", err
+	}
+
+	var b strings.Builder
+	character := ctx.Character
+
+	fmt.Fprintf(&b, "# %s's Adventure\n\n", character.Name)
+	if character.Class != "" {
+		fmt.Fprintf(&b, "- **Class:** %s\n", character.Class)
+	}
+	fmt.Fprintf(&b, "- **Reputation:** %d\n", character.Reputation)
+	fmt.Fprintf(&b, "- **Level:** %s\n", cm.determineExperienceLevel(ctx))
+	fmt.Fprintf(&b, "- **Started:** %s\n", formatTranscriptTime(ctx.StartTime))
+	fmt.Fprintf(&b, "- **Duration:** %s\n", ctx.LastUpdate.Sub(ctx.StartTime).Round(time.Second))
+
+	b.WriteString("\n## Timeline\n")
+	entries := transcriptEntries(ctx)
+	if len(entries) == 0 {
+		b.WriteString("\nNothing has happened yet.\n")
+	}
+	for _, entry := range entries {
+		fmt.Fprintf(&b, "\n### %s — %s\n", formatTranscriptTime(entry.timestamp), entry.heading)
+		if entry.body != "" {
+			fmt.Fprintf(&b, "\n%s\n", entry.body)
+		}
+	}
+
+	b.WriteString("\n## Summary\n\n")
+	fmt.Fprintf(&b, "%s finished at %s with %d/%d health, %d gold and a reputation of %d.\n\n",
+		character.Name, ctx.Location.Current, character.Health.Current, character.Health.Max, character.Gold, character.Reputation)
+	fmt.Fprintf(&b, "- **Actions taken:** %d\n", ctx.SessionStats.TotalActions)
+	fmt.Fprintf(&b, "- **Locations visited:** %d\n", ctx.SessionStats.LocationsVisited)
+	fmt.Fprintf(&b, "- **NPCs met:** %d\n", len(ctx.NPCStates))
+	fmt.Fprintf(&b, "- **Quests completed:** %d\n", countQuests(ctx, QuestCompleted))
+	fmt.Fprintf(&b, "- **Achievements:** %d\n", len(ctx.Achievements))
+
+	return b.String(), nil
+}
+
+// transcriptEntries gathers everything worth retelling from a context,
+// oldest first. Entries at the same instant keep the order they were
+// gathered in, so the output is the same on every export.
+func transcriptEntries(ctx *PlayerContext) []transcriptEntry {
+	var entries []transcriptEntry
+
+	for _, visit := range ctx.Location.LocationHistory {
+		entries = append(entries, transcriptEntry{
+			timestamp: visit.EntryTime,
+			heading:   fmt.Sprintf("Arrived at %s", visit.Location),
+		})
+	}
+
+	for _, action := range ctx.Actions {
+		body := fmt.Sprintf("*%s", action.Type)
+		if action.Target != "" {
+			body += fmt.Sprintf(" targeting %s", action.Target)
+		}
+		body += fmt.Sprintf(" at %s*", action.Location)
+		if action.Outcome != "" {
+			body += "\n\n" + quoteMarkdown(action.Outcome)
+		}
+
+		entries = append(entries, transcriptEntry{
+			timestamp: action.Timestamp,
+			heading:   fmt.Sprintf("`%s`", action.Command),
+			body:      body,
+		})
+	}
+
+	npcIDs := make([]string, 0, len(ctx.NPCStates))
+	for id := range ctx.NPCStates {
+		npcIDs = append(npcIDs, id)
+	}
+	sort.Strings(npcIDs)
+
+	for _, id := range npcIDs {
+		npc := ctx.NPCStates[id]
+		entries = append(entries, transcriptEntry{
+			timestamp: npc.FirstMet,
+			heading:   fmt.Sprintf("Met %s", npc.Name),
+		})
+		for _, fact := range npc.KnownFacts {
+			if fact.LearnedAt.IsZero() {
+				continue
+			}
+			entries = append(entries, transcriptEntry{
+				timestamp: fact.LearnedAt,
+				heading:   fmt.Sprintf("%s learned something", npc.Name),
+				body:      fact.Fact,
+			})
+		}
+	}
+
+	for _, achievement := range ctx.Achievements {
+		entries = append(entries, transcriptEntry{
+			timestamp: achievement.UnlockedAt,
+			heading:   fmt.Sprintf("Achievement unlocked: %s", achievement.Name),
+			body:      achievement.Description,
+		})
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].timestamp.Before(entries[j].timestamp)
+	})
+	return entries
+}
+
+// countQuests counts the player's quests with the given status
+func countQuests(ctx *PlayerContext, status string) int {
+	count := 0
+	for _, quest := range ctx.Quests {
+		if quest.Status == status {
+			count++
+		}
+	}
+	return count
+}
+
+// quoteMarkdown renders text as a Markdown blockquote
+func quoteMarkdown(text string) string {
+	lines := strings.Split(strings.TrimSpace(text), "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimRight("> "+line, " ")
+	}
+	return strings.Join(lines, "\n")
+}
+
+// formatTranscriptTime formats a timestamp for a transcript
+func formatTranscriptTime(t time.Time) string {
+	return t.UTC().Format(transcriptTimeFormat)
+}
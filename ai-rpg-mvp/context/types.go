@@ -1,6 +1,7 @@
 package context
 
 import (
+	"encoding/json"
 	"time"
 )
 
@@ -9,20 +10,43 @@ type PlayerContext struct {
 	// Identity & Session
 	PlayerID   string    `json:"player_id"`
 	SessionID  string    `json:"session_id"`
+	WorldID    string    `json:"world_id,omitempty"` // shared world this session plays in, if any
+	PartyID    string    `json:"party_id,omitempty"` // party within that world, if any
+	Version    int       `json:"version"`            // bumped on every change, see GetContextDelta
 	StartTime  time.Time `json:"start_time"`
 	LastUpdate time.Time `json:"last_update"`
+	ExpiresAt  time.Time `json:"expires_at"` // when the session expires if idle; zero when sessions don't expire
 
 	// Character State
 	Character CharacterState `json:"character"`
 
 	// Location & Movement
-	Location LocationState `json:"location"`
+	Location  LocationState `json:"location"`
+	Encounter *Encounter    `json:"encounter,omitempty"` // what the player ran into here, until they move on
+
+	// Fight in progress, if any
+	Combat *CombatEncounter `json:"combat,omitempty"`
+
+	// In-game time
+	Clock GameClock `json:"clock"`
 
 	// Interaction History
-	Actions []ActionEvent `json:"actions"`
+	Actions         []ActionEvent  `json:"actions"`
+	DialogueHistory []DialogueTurn `json:"dialogue_history"` // player commands and the GM's replies
+	HistorySummary  string         `json:"history_summary,omitempty"` // older actions, folded into a narrative
 
 	// Relationships
-	NPCStates map[string]NPCRelationship `json:"npc_states"`
+	NPCStates     map[string]NPCRelationship `json:"npc_states"`
+	DialogueNodes map[string]string          `json:"dialogue_nodes,omitempty"` // NPC ID -> node reached in its dialogue tree
+
+	// Quests
+	Quests map[string]Quest `json:"quests"`
+
+	// Milestones unlocked, oldest first
+	Achievements []Achievement `json:"achievements"`
+
+	// How the GM runs this session; nil uses the manager's default
+	GMPersonality *GMPersonality `json:"gm_personality,omitempty"`
 
 	// Session Metrics
 	SessionStats SessionMetrics `json:"session_stats"`
@@ -30,13 +54,60 @@ type PlayerContext struct {
 
 // CharacterState represents the player's character information
 type CharacterState struct {
-	Name       string                 `json:"name"`
-	Health     HealthStatus           `json:"health"`
-	Equipment  []EquipmentItem        `json:"equipment"`
-	Inventory  []InventoryItem        `json:"inventory"`
-	Reputation int                    `json:"reputation"` // -100 to 100
-	Attributes map[string]int         `json:"attributes"` // strength, charisma, etc.
-	Metadata   map[string]interface{} `json:"metadata"`
+	Name              string                 `json:"name"`
+	Class             string                 `json:"class,omitempty"` // warrior, mage, rogue, etc.
+	Health            HealthStatus           `json:"health"`
+	Alive             bool                   `json:"alive"`       // false from the moment health hits 0 until Respawn
+	DeathCount        int                    `json:"death_count"` // times the character has died
+	Equipment         []EquipmentItem        `json:"equipment"`
+	Inventory         []InventoryItem        `json:"inventory"`
+	Reputation        int                    `json:"reputation"`         // -100 to 100
+	Gold              int                    `json:"gold"`
+	FactionReputation map[string]int         `json:"faction_reputation"` // faction -> -100 to 100
+	Attributes        map[string]int         `json:"attributes"`         // strength, charisma, etc.
+	UnspentPoints     int                    `json:"unspent_points"`     // attribute points granted on level-up, not yet allocated
+	Mana              ResourcePool           `json:"mana"`               // spent casting spells
+	Stamina           ResourcePool           `json:"stamina"`            // spent on physical abilities
+	Cooldowns         map[string]time.Time   `json:"cooldowns"`          // ability ID -> when it can next be used
+	StatusEffects     []StatusEffect         `json:"status_effects"`
+	Metadata          map[string]interface{} `json:"metadata"`
+}
+
+// UnmarshalJSON treats characters saved before death was tracked as alive
+// unless their health is already gone, and gives characters saved before
+// abilities full mana and stamina pools
+func (c *CharacterState) UnmarshalJSON(data []byte) error {
+	type plain CharacterState
+	decoded := struct {
+		*plain
+		Alive *bool `json:"alive"`
+	}{plain: (*plain)(c)}
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		return err
+	}
+
+	if decoded.Alive != nil {
+		c.Alive = *decoded.Alive
+	} else {
+		c.Alive = c.Health.Current > 0
+	}
+
+	if c.Mana.Max == 0 {
+		c.Mana = ResourcePool{Current: DefaultMaxMana, Max: DefaultMaxMana}
+	}
+	if c.Stamina.Max == 0 {
+		c.Stamina = ResourcePool{Current: DefaultMaxStamina, Max: DefaultMaxStamina}
+	}
+	return nil
+}
+
+// StatusEffect is a temporary condition such as poison or a blessing
+type StatusEffect struct {
+	Name               string         `json:"name"`
+	HealthPerTick      int            `json:"health_per_tick"`     // applied on every status tick; negative for damage
+	AttributeModifiers map[string]int `json:"attribute_modifiers"` // added on top of base attributes while active
+	AppliedAt          time.Time      `json:"applied_at"`
+	ExpiresAt          time.Time      `json:"expires_at"`
 }
 
 // HealthStatus tracks character health
@@ -45,6 +116,12 @@ type HealthStatus struct {
 	Max     int `json:"max"`
 }
 
+// ResourcePool is a pool abilities spend from, such as mana or stamina
+type ResourcePool struct {
+	Current int `json:"current"`
+	Max     int `json:"max"`
+}
+
 // EquipmentItem represents equipped items
 type EquipmentItem struct {
 	ID       string                 `json:"id"`
@@ -67,11 +144,15 @@ type InventoryItem struct {
 
 // LocationState tracks player movement and location history
 type LocationState struct {
-	Current        string    `json:"current"`
-	Previous       string    `json:"previous"`
-	VisitCount     int       `json:"visit_count"`
-	FirstVisit     time.Time `json:"first_visit"`
-	TimeInLocation int       `json:"time_in_location"` // minutes
+	Current        string         `json:"current"`
+	Previous       string         `json:"previous"`
+	VisitCount     int            `json:"visit_count"` // times the current location has been entered
+	FirstVisit     time.Time      `json:"first_visit"`
+	TimeInLocation int            `json:"time_in_location"` // game minutes since arriving
+	EnteredAt      time.Time      `json:"entered_at"`       // game time of arrival
+	Visits         map[string]int `json:"visits,omitempty"` // location -> times entered
+	Weather        Weather        `json:"weather,omitempty"`
+	WeatherSince   time.Time      `json:"weather_since"` // game time the weather last changed
 	LocationHistory []LocationVisit `json:"location_history"`
 }
 
@@ -94,6 +175,37 @@ type ActionEvent struct {
 	Outcome      string                 `json:"outcome"`
 	Consequences []string               `json:"consequences"`
 	Metadata     map[string]interface{} `json:"metadata"`
+	Effects      *ActionEffects         `json:"effects,omitempty"` // what the consequences actually changed
+	Embedding    []float32              `json:"embedding,omitempty"` // meaning of the command and outcome, for recall
+}
+
+// ActionEffects records the concrete state changes an action's consequences
+// applied, so the action can be undone precisely
+type ActionEffects struct {
+	ReputationDelta int             `json:"reputation_delta"`
+	HealthDelta     int             `json:"health_delta"`
+	GoldDelta       int             `json:"gold_delta"`
+	ItemsGained     []InventoryItem `json:"items_gained,omitempty"`
+	ItemsLost       []InventoryItem `json:"items_lost,omitempty"`
+	Achievements    []string        `json:"achievements,omitempty"` // IDs of achievements the action unlocked
+	Rewards         *ActionEffects  `json:"rewards,omitempty"`      // what those achievements' consequences changed
+}
+
+// add folds another set of effects into these
+func (e *ActionEffects) add(other *ActionEffects) {
+	e.ReputationDelta += other.ReputationDelta
+	e.HealthDelta += other.HealthDelta
+	e.GoldDelta += other.GoldDelta
+	e.ItemsGained = append(e.ItemsGained, other.ItemsGained...)
+	e.ItemsLost = append(e.ItemsLost, other.ItemsLost...)
+}
+
+// DialogueTurn is one exchange between the player and the GM
+type DialogueTurn struct {
+	ActionID  string    `json:"action_id"`
+	Command   string    `json:"command"`
+	Response  string    `json:"response"`
+	Timestamp time.Time `json:"timestamp"`
 }
 
 // NPCRelationship tracks relationship with specific NPCs
@@ -104,16 +216,59 @@ type NPCRelationship struct {
 	FirstMet         time.Time `json:"first_met"`
 	LastInteraction  time.Time `json:"last_interaction"`
 	InteractionCount int       `json:"interaction_count"`
-	KnownFacts       []string  `json:"known_facts"`
+	KnownFacts       []NPCFact `json:"known_facts"`
 	Mood             string    `json:"mood"` // "friendly", "hostile", "neutral", "suspicious"
 	Location         string    `json:"location"`
 	Notes            []string  `json:"notes"`
 }
 
+// NPCFact is something an NPC knows about the player and when they learned it
+type NPCFact struct {
+	Fact      string    `json:"fact"`
+	LearnedAt time.Time `json:"learned_at"`
+}
+
+// UnmarshalJSON also accepts a bare string, the format facts were stored in
+// before they were timestamped
+func (f *NPCFact) UnmarshalJSON(data []byte) error {
+	var fact string
+	if err := json.Unmarshal(data, &fact); err == nil {
+		*f = NPCFact{Fact: fact}
+		return nil
+	}
+
+	type plain NPCFact
+	return json.Unmarshal(data, (*plain)(f))
+}
+
+// Quest statuses
+const (
+	QuestActive    = "active"
+	QuestCompleted = "completed"
+	QuestFailed    = "failed"
+)
+
+// Quest tracks a storyline the player is pursuing
+type Quest struct {
+	ID          string      `json:"id"`
+	Title       string      `json:"title"`
+	Description string      `json:"description"`
+	Status      string      `json:"status"` // "active", "completed", "failed"
+	Objectives  []Objective `json:"objectives"`
+	StartedAt   time.Time   `json:"started_at"`
+}
+
+// Objective is a single ordered step of a quest
+type Objective struct {
+	Description string `json:"description"`
+	Done        bool   `json:"done"`
+}
+
 // SessionMetrics tracks session statistics
 type SessionMetrics struct {
 	TotalActions   int     `json:"total_actions"`
 	CombatActions  int     `json:"combat_actions"`
+	CombatVictories int    `json:"combat_victories"`
 	SocialActions  int     `json:"social_actions"`
 	ExploreActions int     `json:"explore_actions"`
 	SessionTime    float64 `json:"session_time_minutes"`
@@ -144,6 +299,7 @@ type NPCContextInfo struct {
 	LastSeen     string   `json:"last_seen"`
 	Location     string   `json:"location"`
 	Relationship string   `json:"relationship"` // "stranger", "acquaintance", "friend", "enemy"
+	Stance       string   `json:"stance,omitempty"` // set when the player's reputation crosses a gate: refuses, attacks or welcomes
 }
 
 // ContextEvent represents an event to be processed by the context manager
@@ -151,6 +307,10 @@ type ContextEvent struct {
 	SessionID string      `json:"session_id"`
 	Event     ActionEvent `json:"event"`
 	Timestamp time.Time   `json:"timestamp"`
+
+	// processed, when set, marks a barrier event that carries no action and
+	// is closed once every event queued before it has been processed
+	processed chan struct{}
 }
 
 // ContextStorage interface for different storage implementations
@@ -159,6 +319,29 @@ type ContextStorage interface {
 	SaveContext(ctx *PlayerContext) error
 	DeleteContext(sessionID string) error
 	ListActiveSessions() ([]string, error)
+	Ping() error
+}
+
+// SnapshotStorage is implemented by storage backends that can keep named
+// save points of a session's context
+type SnapshotStorage interface {
+	SaveSnapshot(snapshot *Snapshot) error
+	LoadSnapshot(sessionID, snapshotID string) (*Snapshot, error)
+	ListSnapshots(sessionID string) ([]SnapshotInfo, error)
+}
+
+// SnapshotInfo describes a saved snapshot
+type SnapshotInfo struct {
+	ID        string    `json:"id"`
+	SessionID string    `json:"session_id"`
+	Label     string    `json:"label"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Snapshot is a saved copy of a session's context
+type Snapshot struct {
+	SnapshotInfo
+	Context *PlayerContext `json:"context"`
 }
 
 // AIPromptData contains structured data for AI prompt generation
@@ -167,5 +350,5 @@ type AIPromptData struct {
 	RecentEvents    []ActionEvent   `json:"recent_events"`
 	WorldKnowledge  map[string]interface{} `json:"world_knowledge"`
 	PlayerProfile   map[string]interface{} `json:"player_profile"`
-	GMPersonality   map[string]interface{} `json:"gm_personality"`
+	GMPersonality   GMPersonality          `json:"gm_personality"`
 }
@@ -0,0 +1,118 @@
+package context
+
+import (
+	"fmt"
+)
+
+// UndoLastAction removes the most recent action from a session and reverses
+// the reputation, health, gold and inventory changes its consequences made,
+// along with any achievements it unlocked.
+// Actions still waiting in the event queue are processed first, so the
+// action undone is always the last one recorded.
+func (cm *ContextManager) UndoLastAction(sessionID string) (*ActionEvent, error) {
+	if err := cm.waitForQueuedEvents(); err != nil {
+		return nil, err
+	}
+
+	var undone ActionEvent
+	err := cm.mutateContext(sessionID, func(ctx *PlayerContext) error {
+		if err := checkAlive(ctx); err != nil {
+			return err
+		}
+
+		if len(ctx.Actions) == 0 {
+			return fmt.Errorf("no actions to undo for session %s", sessionID)
+		}
+
+		undone = ctx.Actions[len(ctx.Actions)-1]
+		ctx.Actions = ctx.Actions[:len(ctx.Actions)-1]
+
+		// The GM's reply to the undone action never happened either
+		if n := len(ctx.DialogueHistory); n > 0 && ctx.DialogueHistory[n-1].ActionID == undone.ID {
+			ctx.DialogueHistory = ctx.DialogueHistory[:n-1]
+		}
+
+		if undone.Effects != nil {
+			revertActionEffects(ctx, *undone.Effects)
+		}
+		revertSessionStats(ctx, undone)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	undone = undone.clone()
+	return &undone, nil
+}
+
+// revertActionEffects applies the inverse of recorded action effects
+func revertActionEffects(ctx *PlayerContext, effects ActionEffects) {
+	ctx.Character.Reputation -= effects.ReputationDelta
+	if ctx.Character.Reputation > 100 {
+		ctx.Character.Reputation = 100
+	} else if ctx.Character.Reputation < -100 {
+		ctx.Character.Reputation = -100
+	}
+
+	ctx.Character.Health.Current -= effects.HealthDelta
+	if ctx.Character.Health.Current > ctx.Character.Health.Max {
+		ctx.Character.Health.Current = ctx.Character.Health.Max
+	} else if ctx.Character.Health.Current < 0 {
+		ctx.Character.Health.Current = 0
+	}
+
+	ctx.Character.Gold -= effects.GoldDelta
+	if ctx.Character.Gold < 0 {
+		ctx.Character.Gold = 0
+	}
+
+	// Take back gained items, newest first, without going below zero
+	for i := len(effects.ItemsGained) - 1; i >= 0; i-- {
+		gained := effects.ItemsGained[i]
+		index := findInventoryItem(ctx, gained.ID)
+		if index < 0 {
+			continue
+		}
+		item := &ctx.Character.Inventory[index]
+		item.Quantity -= gained.Quantity
+		if item.Quantity <= 0 {
+			ctx.Character.Inventory = append(ctx.Character.Inventory[:index], ctx.Character.Inventory[index+1:]...)
+		}
+	}
+
+	for _, lost := range effects.ItemsLost {
+		addItemToInventory(ctx, lost)
+	}
+
+	revokeAchievements(ctx, effects.Achievements)
+	if effects.Rewards != nil {
+		revertActionEffects(ctx, *effects.Rewards)
+	}
+}
+
+// revertSessionStats removes an undone action from the session counters
+func revertSessionStats(ctx *PlayerContext, action ActionEvent) {
+	if ctx.SessionStats.TotalActions > 0 {
+		ctx.SessionStats.TotalActions--
+	}
+
+	switch action.Type {
+	case "combat", "attack", "defend":
+		if ctx.SessionStats.CombatActions > 0 {
+			ctx.SessionStats.CombatActions--
+		}
+	case "talk", "dialogue", "social":
+		if ctx.SessionStats.SocialActions > 0 {
+			ctx.SessionStats.SocialActions--
+		}
+	case "move", "explore", "examine", "look":
+		if ctx.SessionStats.ExploreActions > 0 {
+			ctx.SessionStats.ExploreActions--
+		}
+	}
+
+	if contains(action.Consequences, "combat_victory") && ctx.SessionStats.CombatVictories > 0 {
+		ctx.SessionStats.CombatVictories--
+	}
+}
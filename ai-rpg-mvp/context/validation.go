@@ -0,0 +1,151 @@
+package context
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ActionValidator checks whether a player command is allowed before it is
+// acted on. Validators must not modify the context.
+type ActionValidator interface {
+	Validate(ctx *PlayerContext, command string) error
+}
+
+// ActionValidatorFunc adapts a function to the ActionValidator interface
+type ActionValidatorFunc func(ctx *PlayerContext, command string) error
+
+// Validate calls f(ctx, command)
+func (f ActionValidatorFunc) Validate(ctx *PlayerContext, command string) error {
+	return f(ctx, command)
+}
+
+// MovementValidator rejects /move and /go commands to locations that are not
+// adjacent on the world map. Without a map every move is allowed.
+type MovementValidator struct {
+	WorldMap *WorldMap
+}
+
+// Validate implements ActionValidator
+func (v MovementValidator) Validate(ctx *PlayerContext, command string) error {
+	verb, args := parseCommand(command)
+	if (verb != "move" && verb != "go") || v.WorldMap == nil {
+		return nil
+	}
+	if len(args) == 0 {
+		return fmt.Errorf("where do you want to go?")
+	}
+
+	if ResolveExit(v.WorldMap.Exits(ctx.Location.Current), args[0]) == "" {
+		return fmt.Errorf("you can't reach %s from here", args[0])
+	}
+	return nil
+}
+
+// CombatHealthValidator rejects attacks while the player is at zero health
+type CombatHealthValidator struct{}
+
+// Validate implements ActionValidator
+func (CombatHealthValidator) Validate(ctx *PlayerContext, command string) error {
+	verb, _ := parseCommand(command)
+	if (verb == "attack" || verb == "fight") && ctx.Character.Health.Current <= 0 {
+		return fmt.Errorf("you are too badly wounded to fight")
+	}
+	return nil
+}
+
+// GoldValidator rejects /buy, /pay and /spend commands whose trailing amount
+// is more gold than the player has
+type GoldValidator struct{}
+
+// Validate implements ActionValidator
+func (GoldValidator) Validate(ctx *PlayerContext, command string) error {
+	verb, args := parseCommand(command)
+	if (verb != "buy" && verb != "pay" && verb != "spend") || len(args) == 0 {
+		return nil
+	}
+
+	amount, err := strconv.Atoi(args[len(args)-1])
+	if err != nil {
+		return nil // no price given; the GM decides
+	}
+	if amount > ctx.Character.Gold {
+		return fmt.Errorf("you can't afford that: it costs %d gold and you have %d", amount, ctx.Character.Gold)
+	}
+	return nil
+}
+
+// AddActionValidator appends a validator to the chain ValidateAction consults
+func (cm *ContextManager) AddActionValidator(validator ActionValidator) {
+	cm.registryMutex.Lock()
+	defer cm.registryMutex.Unlock()
+
+	cm.validators = append(cm.validators, validator)
+}
+
+// ValidateAction runs a command through every validator, returning the first
+// rejection. Nothing is recorded, and an unknown session fails with
+// ErrSessionNotFound.
+func (cm *ContextManager) ValidateAction(sessionID, command string) error {
+	ctx, err := cm.GetExistingContext(sessionID)
+	if err != nil {
+		return err
+	}
+
+	cm.registryMutex.RLock()
+	validators := append([]ActionValidator{}, cm.validators...)
+	cm.registryMutex.RUnlock()
+
+	for _, validator := range validators {
+		if err := validator.Validate(ctx, command); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// defaultActionValidators returns the built-in validators every context
+// manager starts with
+func (cm *ContextManager) defaultActionValidators() []ActionValidator {
+	return []ActionValidator{
+		DeathValidator{},
+		// Look the map up per call so SetWorldMap takes effect
+		ActionValidatorFunc(func(ctx *PlayerContext, command string) error {
+			return MovementValidator{WorldMap: cm.GetWorldMap()}.Validate(ctx, command)
+		}),
+		CombatHealthValidator{},
+		GoldValidator{},
+		cm.reputationGateValidator(),
+	}
+}
+
+// ResolveExit matches a movement target such as "forest" against the
+// available exits, returning the exit ID or "" if none match
+func ResolveExit(exits []string, target string) string {
+	target = strings.ToLower(target)
+	if target == "" {
+		return ""
+	}
+
+	for _, exit := range exits {
+		if exit == target {
+			return exit
+		}
+	}
+	for _, exit := range exits {
+		if strings.Contains(exit, target) {
+			return exit
+		}
+	}
+	return ""
+}
+
+// parseCommand splits a command like "/buy sword 30" into its lowercased
+// verb and arguments
+func parseCommand(command string) (string, []string) {
+	fields := strings.Fields(command)
+	if len(fields) == 0 {
+		return "", nil
+	}
+	return strings.ToLower(strings.TrimPrefix(fields[0], "/")), fields[1:]
+}
@@ -0,0 +1,170 @@
+package context
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+	"time"
+
+	"ai-rpg-mvp/combat"
+)
+
+// Weather is the sky over the player's current location
+type Weather string
+
+// Kinds of weather
+const (
+	WeatherClear  Weather = "clear"
+	WeatherCloudy Weather = "cloudy"
+	WeatherRain   Weather = "rain"
+	WeatherStorm  Weather = "storm"
+	WeatherFog    Weather = "fog"
+	WeatherSnow   Weather = "snow"
+)
+
+// Biomes decide how likely each kind of weather is. Locations without one
+// are temperate.
+const (
+	BiomeTemperate = "temperate"
+	BiomeForest    = "forest"
+	BiomeMountain  = "mountain"
+	BiomeDesert    = "desert"
+	BiomeCoast     = "coast"
+)
+
+// weatherChangeInterval is how much game time passes between weather rolls
+const weatherChangeInterval = 4 * time.Hour
+
+// weatherChance is the percent chance of one kind of weather
+type weatherChance struct {
+	weather Weather
+	percent int
+}
+
+// weatherTables gives each biome's odds of every kind of weather; each table
+// adds up to 100
+var weatherTables = map[string][]weatherChance{
+	BiomeTemperate: {{WeatherClear, 45}, {WeatherCloudy, 25}, {WeatherRain, 20}, {WeatherFog, 5}, {WeatherStorm, 5}},
+	BiomeForest:    {{WeatherClear, 30}, {WeatherCloudy, 25}, {WeatherRain, 25}, {WeatherFog, 15}, {WeatherStorm, 5}},
+	BiomeMountain:  {{WeatherClear, 30}, {WeatherCloudy, 20}, {WeatherSnow, 30}, {WeatherStorm, 10}, {WeatherFog, 10}},
+	BiomeDesert:    {{WeatherClear, 80}, {WeatherCloudy, 10}, {WeatherStorm, 10}},
+	BiomeCoast:     {{WeatherClear, 35}, {WeatherCloudy, 20}, {WeatherRain, 20}, {WeatherFog, 15}, {WeatherStorm, 10}},
+}
+
+// weatherCheckModifiers are added to attribute checks made in bad weather:
+// storms and snow throw off aim and footing, fog hides what is ahead
+var weatherCheckModifiers = map[Weather]map[string]int{
+	WeatherStorm: {"dexterity": -2, "intelligence": -1},
+	WeatherSnow:  {"dexterity": -1, "strength": -1},
+	WeatherFog:   {"intelligence": -2},
+	WeatherRain:  {"dexterity": -1},
+}
+
+// IsValid reports whether w is a known kind of weather
+func (w Weather) IsValid() bool {
+	switch w {
+	case WeatherClear, WeatherCloudy, WeatherRain, WeatherStorm, WeatherFog, WeatherSnow:
+		return true
+	}
+	return false
+}
+
+// SetWeatherSource sets the random source weather changes draw from, making
+// them reproducible for a fixed seed. It is safe to call while the status
+// ticker is rolling weather.
+func (cm *ContextManager) SetWeatherSource(src rand.Source) {
+	cm.weatherDice.Store(combat.NewRoller(src))
+}
+
+// SetWeather sets the weather at the player's location, as a script might
+// for a storm that must break on cue. It holds until the next weather roll,
+// a full change interval of game time later.
+func (cm *ContextManager) SetWeather(sessionID string, w Weather) error {
+	if !w.IsValid() {
+		return fmt.Errorf("unknown weather: %s", w)
+	}
+
+	return cm.mutateContext(sessionID, func(ctx *PlayerContext) error {
+		ctx.Location.Weather = w
+		ctx.Location.WeatherSince = ctx.Clock.Now(cm.now())
+		return nil
+	})
+}
+
+// GetWeather returns the weather at the player's location
+func (cm *ContextManager) GetWeather(sessionID string) (Weather, error) {
+	ctx, err := cm.GetContext(sessionID)
+	if err != nil {
+		return "", err
+	}
+	return currentWeather(ctx.Location), nil
+}
+
+// currentWeather is the location's weather, clear for contexts that predate
+// weather
+func currentWeather(location LocationState) Weather {
+	if location.Weather == "" {
+		return WeatherClear
+	}
+	return location.Weather
+}
+
+// updateWeather rolls new weather from the biome's table once a change
+// interval of game time has passed since the last change, and reports
+// whether the roll brought different weather
+func (cm *ContextManager) updateWeather(ctx *PlayerContext) bool {
+	gameNow := ctx.Clock.Now(cm.now())
+	if ctx.Location.Weather != "" && gameNow.Sub(ctx.Location.WeatherSince) < weatherChangeInterval {
+		return false
+	}
+
+	previous := ctx.Location.Weather
+
+	table := weatherTables[cm.biome(ctx.Location.Current)]
+	roll := cm.weatherDice.Load().Roll(100)
+	for _, chance := range table {
+		if roll <= chance.percent {
+			ctx.Location.Weather = chance.weather
+			break
+		}
+		roll -= chance.percent
+	}
+	ctx.Location.WeatherSince = gameNow
+	return ctx.Location.Weather != previous
+}
+
+// biome returns the biome of a location on the world map, temperate if it
+// has none or isn't on the map
+func (cm *ContextManager) biome(locationID string) string {
+	if worldMap := cm.GetWorldMap(); worldMap != nil {
+		if location, exists := worldMap.GetLocation(locationID); exists {
+			if _, known := weatherTables[location.Biome]; known {
+				return location.Biome
+			}
+		}
+	}
+	return BiomeTemperate
+}
+
+// weatherCheckModifier is how the weather changes checks of an attribute
+func weatherCheckModifier(w Weather, attribute string) int {
+	return weatherCheckModifiers[w][attribute]
+}
+
+// formatWeather describes the weather for AI prompts, along with the checks
+// it hinders
+func (cm *ContextManager) formatWeather(location LocationState) string {
+	w := currentWeather(location)
+	modifiers := weatherCheckModifiers[w]
+	if len(modifiers) == 0 {
+		return string(w)
+	}
+
+	var penalties []string
+	for _, attribute := range allocatableAttributes {
+		if modifier, exists := modifiers[attribute]; exists {
+			penalties = append(penalties, fmt.Sprintf("%+d %s", modifier, attribute))
+		}
+	}
+	return fmt.Sprintf("%s (checks: %s)", w, strings.Join(penalties, ", "))
+}
@@ -0,0 +1,220 @@
+package context
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// World is state shared by every session that joins it. Members keep their
+// own characters, but NPC relationships are common to all of them and each
+// location knows which players are standing in it.
+type World struct {
+	ID string
+
+	npcStates map[string]NPCRelationship // NPC ID -> shared relationship
+	locations map[string]*WorldLocation  // location ID -> shared state
+	members   map[string]string          // session ID -> character name
+	mutex     sync.RWMutex
+}
+
+// WorldLocation is what a world knows about one location
+type WorldLocation struct {
+	ID        string   `json:"id"`
+	Occupants []string `json:"occupants"` // session IDs of players here
+	Visits    int      `json:"visits"`    // times any member has arrived
+}
+
+// newWorld creates an empty world
+func newWorld(worldID string) *World {
+	return &World{
+		ID:        worldID,
+		npcStates: make(map[string]NPCRelationship),
+		locations: make(map[string]*WorldLocation),
+		members:   make(map[string]string),
+	}
+}
+
+// NPCStates returns a copy of the world's shared NPC relationships
+func (w *World) NPCStates() map[string]NPCRelationship {
+	w.mutex.RLock()
+	defer w.mutex.RUnlock()
+
+	return w.npcStatesLocked()
+}
+
+// npcStatesLocked copies the NPC relationships; callers must hold the mutex
+func (w *World) npcStatesLocked() map[string]NPCRelationship {
+	states := make(map[string]NPCRelationship, len(w.npcStates))
+	for id, npc := range w.npcStates {
+		states[id] = npc.clone()
+	}
+	return states
+}
+
+// Location returns the shared state of a location
+func (w *World) Location(locationID string) (WorldLocation, bool) {
+	w.mutex.RLock()
+	defer w.mutex.RUnlock()
+
+	location, exists := w.locations[locationID]
+	if !exists {
+		return WorldLocation{}, false
+	}
+
+	clone := *location
+	clone.Occupants = copyStrings(location.Occupants)
+	return clone, true
+}
+
+// Members returns the session IDs of the world's players, sorted
+func (w *World) Members() []string {
+	w.mutex.RLock()
+	defer w.mutex.RUnlock()
+
+	members := make([]string, 0, len(w.members))
+	for sessionID := range w.members {
+		members = append(members, sessionID)
+	}
+	sort.Strings(members)
+	return members
+}
+
+// sync registers ctx's session as a member and replaces its NPC states with
+// the shared ones. The first time a session is seen, which includes loading
+// it back from storage, its own NPC states are merged into the world, keeping
+// whichever side interacted with an NPC most recently.
+func (w *World) sync(ctx *PlayerContext) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if _, member := w.members[ctx.SessionID]; !member {
+		for id, npc := range ctx.NPCStates {
+			if shared, exists := w.npcStates[id]; !exists || npc.LastInteraction.After(shared.LastInteraction) {
+				w.npcStates[id] = npc.clone()
+			}
+		}
+		w.addOccupant(ctx.SessionID, ctx.Location.Current)
+	}
+	w.members[ctx.SessionID] = ctx.Character.Name
+
+	ctx.NPCStates = w.npcStatesLocked()
+}
+
+// leave removes a session from the world
+func (w *World) leave(sessionID string) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	for _, location := range w.locations {
+		location.Occupants = removeString(location.Occupants, sessionID)
+	}
+	delete(w.members, sessionID)
+}
+
+// move records a member walking from one location to another
+func (w *World) move(sessionID, from, to string) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if location, exists := w.locations[from]; exists {
+		location.Occupants = removeString(location.Occupants, sessionID)
+	}
+	w.addOccupant(sessionID, to)
+}
+
+// addOccupant places a session at a location; callers must hold the mutex
+func (w *World) addOccupant(sessionID, locationID string) {
+	location, exists := w.locations[locationID]
+	if !exists {
+		location = &WorldLocation{ID: locationID, Occupants: []string{}}
+		w.locations[locationID] = location
+	}
+
+	if !contains(location.Occupants, sessionID) {
+		location.Occupants = append(location.Occupants, sessionID)
+		location.Visits++
+	}
+}
+
+// playersAt returns the names of members at a location other than sessionID
+func (w *World) playersAt(locationID, sessionID string) []string {
+	w.mutex.RLock()
+	defer w.mutex.RUnlock()
+
+	location, exists := w.locations[locationID]
+	if !exists {
+		return nil
+	}
+
+	var names []string
+	for _, occupant := range location.Occupants {
+		if occupant != sessionID {
+			names = append(names, w.members[occupant])
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// JoinWorld creates a session for a new character in a shared world,
+// creating the world if this is its first player
+func (cm *ContextManager) JoinWorld(playerID, playerName, worldID string) (string, error) {
+	if worldID == "" {
+		return "", fmt.Errorf("world ID is required")
+	}
+
+	sessionID, err := cm.CreateSession(playerID, playerName)
+	if err != nil {
+		return "", err
+	}
+
+	err = cm.mutateContext(sessionID, func(ctx *PlayerContext) error {
+		ctx.WorldID = worldID
+		cm.world(worldID).sync(ctx)
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+
+	if err := cm.saveCachedContext(sessionID); err != nil {
+		return "", fmt.Errorf("failed to save new context: %w", err)
+	}
+
+	return sessionID, nil
+}
+
+// GetWorld returns a shared world, or false if no session has joined it
+func (cm *ContextManager) GetWorld(worldID string) (*World, bool) {
+	world, ok := cm.worlds.Load(worldID)
+	if !ok {
+		return nil, false
+	}
+	return world.(*World), true
+}
+
+// world returns the shared world with the given ID, creating it if needed
+func (cm *ContextManager) world(worldID string) *World {
+	world, _ := cm.worlds.LoadOrStore(worldID, newWorld(worldID))
+	return world.(*World)
+}
+
+// worldFor returns the world a context belongs to, or nil if it plays alone
+func (cm *ContextManager) worldFor(ctx *PlayerContext) *World {
+	if ctx.WorldID == "" {
+		return nil
+	}
+	return cm.world(ctx.WorldID)
+}
+
+// removeString returns values without any occurrence of value
+func removeString(values []string, value string) []string {
+	kept := values[:0]
+	for _, v := range values {
+		if v != value {
+			kept = append(kept, v)
+		}
+	}
+	return kept
+}
@@ -0,0 +1,205 @@
+package context
+
+import (
+	"fmt"
+	"sync"
+)
+
+// MapLocation is a node in the world map
+type MapLocation struct {
+	ID      string   `json:"id"`
+	Name    string   `json:"name"`
+	Exits   []string `json:"exits"`           // IDs of directly reachable locations
+	Hostile bool     `json:"hostile"`         // too dangerous to rest in
+	Biome   string   `json:"biome,omitempty"` // decides the odds of each kind of weather; temperate if unset
+}
+
+// WorldMap is a graph of locations connected by exits
+type WorldMap struct {
+	locations map[string]*MapLocation
+	mutex     sync.RWMutex
+}
+
+// NewWorldMap creates an empty world map
+func NewWorldMap() *WorldMap {
+	return &WorldMap{
+		locations: make(map[string]*MapLocation),
+	}
+}
+
+// AddLocation adds a location to the map, renaming it if it already exists
+func (wm *WorldMap) AddLocation(id, name string) error {
+	if id == "" {
+		return fmt.Errorf("location ID is required")
+	}
+
+	wm.mutex.Lock()
+	defer wm.mutex.Unlock()
+
+	if location, exists := wm.locations[id]; exists {
+		location.Name = name
+		return nil
+	}
+
+	wm.locations[id] = &MapLocation{
+		ID:    id,
+		Name:  name,
+		Exits: []string{},
+	}
+	return nil
+}
+
+// ConnectLocations adds an exit from one location to another, and back
+// again when bidirectional is set
+func (wm *WorldMap) ConnectLocations(fromID, toID string, bidirectional bool) error {
+	wm.mutex.Lock()
+	defer wm.mutex.Unlock()
+
+	from, exists := wm.locations[fromID]
+	if !exists {
+		return fmt.Errorf("unknown location: %s", fromID)
+	}
+	to, exists := wm.locations[toID]
+	if !exists {
+		return fmt.Errorf("unknown location: %s", toID)
+	}
+
+	addExit(from, toID)
+	if bidirectional {
+		addExit(to, fromID)
+	}
+	return nil
+}
+
+// SetHostile marks a location as too dangerous to rest in, or safe again
+func (wm *WorldMap) SetHostile(id string, hostile bool) error {
+	wm.mutex.Lock()
+	defer wm.mutex.Unlock()
+
+	location, exists := wm.locations[id]
+	if !exists {
+		return fmt.Errorf("unknown location: %s", id)
+	}
+	location.Hostile = hostile
+	return nil
+}
+
+// SetBiome sets the biome whose weather a location gets
+func (wm *WorldMap) SetBiome(id, biome string) error {
+	if _, known := weatherTables[biome]; !known {
+		return fmt.Errorf("unknown biome: %s", biome)
+	}
+
+	wm.mutex.Lock()
+	defer wm.mutex.Unlock()
+
+	location, exists := wm.locations[id]
+	if !exists {
+		return fmt.Errorf("unknown location: %s", id)
+	}
+	location.Biome = biome
+	return nil
+}
+
+// IsHostile reports whether a location is marked hostile. Locations missing
+// from the map are not.
+func (wm *WorldMap) IsHostile(id string) bool {
+	wm.mutex.RLock()
+	defer wm.mutex.RUnlock()
+
+	location, exists := wm.locations[id]
+	return exists && location.Hostile
+}
+
+// GetLocation returns a copy of a location, if it exists
+func (wm *WorldMap) GetLocation(id string) (MapLocation, bool) {
+	wm.mutex.RLock()
+	defer wm.mutex.RUnlock()
+
+	location, exists := wm.locations[id]
+	if !exists {
+		return MapLocation{}, false
+	}
+
+	clone := *location
+	clone.Exits = copyStrings(location.Exits)
+	return clone, true
+}
+
+// Exits returns the IDs of locations reachable from a location
+func (wm *WorldMap) Exits(id string) []string {
+	wm.mutex.RLock()
+	defer wm.mutex.RUnlock()
+
+	location, exists := wm.locations[id]
+	if !exists {
+		return []string{}
+	}
+	return copyStrings(location.Exits)
+}
+
+// IsAdjacent reports whether toID can be reached directly from fromID
+func (wm *WorldMap) IsAdjacent(fromID, toID string) bool {
+	wm.mutex.RLock()
+	defer wm.mutex.RUnlock()
+
+	location, exists := wm.locations[fromID]
+	return exists && contains(location.Exits, toID)
+}
+
+// addExit appends an exit unless the location already has it
+func addExit(location *MapLocation, toID string) {
+	if !contains(location.Exits, toID) {
+		location.Exits = append(location.Exits, toID)
+	}
+}
+
+// SetWorldMap registers the world map used to validate movement. It is safe
+// to call while the manager's workers are running.
+func (cm *ContextManager) SetWorldMap(worldMap *WorldMap) {
+	cm.worldMap.Store(worldMap)
+}
+
+// GetWorldMap returns the registered world map, or nil if none is set
+func (cm *ContextManager) GetWorldMap() *WorldMap {
+	return cm.worldMap.Load()
+}
+
+// MoveTo moves the player to a location adjacent to their current one
+func (cm *ContextManager) MoveTo(sessionID, destID string) error {
+	worldMap := cm.GetWorldMap()
+	if worldMap == nil {
+		return fmt.Errorf("no world map registered")
+	}
+	if _, exists := worldMap.GetLocation(destID); !exists {
+		return fmt.Errorf("unknown location: %s", destID)
+	}
+
+	return cm.mutateContext(sessionID, func(ctx *PlayerContext) error {
+		if err := checkAlive(ctx); err != nil {
+			return err
+		}
+
+		if !worldMap.IsAdjacent(ctx.Location.Current, destID) {
+			return fmt.Errorf("cannot move from %s to %s: locations are not connected", ctx.Location.Current, destID)
+		}
+
+		cm.applyLocationChange(ctx, destID)
+		cm.rollEncounter(ctx)
+		return nil
+	})
+}
+
+// GetExits returns the locations reachable from the player's current location
+func (cm *ContextManager) GetExits(sessionID string) ([]string, error) {
+	ctx, err := cm.GetContext(sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	worldMap := cm.GetWorldMap()
+	if worldMap == nil {
+		return []string{}, nil
+	}
+	return worldMap.Exits(ctx.Location.Current), nil
+}
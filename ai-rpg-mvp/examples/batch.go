@@ -0,0 +1,147 @@
+package main
+
+import (
+	stdcontext "context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+)
+
+// maxBatchCommands is the most commands a single batch may hold
+const maxBatchCommands = 10
+
+// PlayerCommandBatch is a sequence of commands to be narrated as one turn
+type PlayerCommandBatch struct {
+	SessionID string   `json:"session_id"`
+	Commands  []string `json:"commands"`
+}
+
+// handleGameActionBatch plays several commands in order and answers with a
+// single GM narration covering all of them
+func (s *GameServer) handleGameActionBatch(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var batch PlayerCommandBatch
+	if err := json.NewDecoder(r.Body).Decode(&batch); err != nil {
+		s.sendErrorResponse(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	if batch.SessionID == "" || len(batch.Commands) == 0 {
+		s.sendErrorResponse(w, "SessionID and Commands are required", http.StatusBadRequest)
+		return
+	}
+
+	narration, err := s.ProcessActionBatch(r.Context(), batch.SessionID, batch.Commands)
+	if err != nil {
+		s.sendErrorResponse(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+
+	s.sendJSONResponse(w, GameResponse{Success: true, Message: narration, SessionID: batch.SessionID})
+}
+
+// ProcessActionBatch plays commands in order, applying each one's game
+// effects and consequences as /api/game/action would, then makes a single AI
+// call narrating the whole sequence. Each command is validated just before
+// it is played, so it can rely on the ones before it, such as an action only
+// possible after a move. If one is refused or fails partway through, the
+// commands already played are still narrated and recorded, and the GM is
+// told the rest never happened. Cancelling ctx abandons the AI call.
+func (s *GameServer) ProcessActionBatch(ctx stdcontext.Context, sessionID string, commands []string) (string, error) {
+	if len(commands) == 0 {
+		return "", fmt.Errorf("no commands to play")
+	}
+	if len(commands) > maxBatchCommands {
+		return "", fmt.Errorf("a batch may hold at most %d commands, got %d", maxBatchCommands, len(commands))
+	}
+
+	var turns []gameTurn
+	var stopped string
+	for _, command := range commands {
+		turn, err := s.playBatchCommand(sessionID, command)
+		if err != nil {
+			if len(turns) == 0 {
+				return "", err
+			}
+			slog.Warn("Batch stopped early", "session_id", sessionID, "command", command, "error", err)
+			stopped = fmt.Sprintf("%s (%s)", command, err)
+			break
+		}
+		turns = append(turns, turn)
+	}
+
+	prompt, err := s.batchPrompt(sessionID, turns, stopped)
+	if err != nil {
+		return "", err
+	}
+
+	narration, err := s.aiService.GenerateGMResponseForSessionCtx(ctx, sessionID, prompt)
+	if err != nil {
+		slog.Error("AI service error", "session_id", sessionID, "error", err)
+		var fallbacks []string
+		for _, turn := range turns {
+			fallbacks = append(fallbacks, turn.fallbackResponse())
+		}
+		narration = strings.Join(fallbacks, " ")
+	}
+
+	// The narration is recorded once, on the last action, so it isn't
+	// repeated in later prompts
+	last := turns[len(turns)-1]
+	for i, turn := range turns {
+		outcome := narration
+		if i < len(turns)-1 {
+			outcome = fmt.Sprintf("Narrated together with the rest of the batch, ending with %s", last.command)
+		}
+		if err := s.contextMgr.RecordAction(sessionID, turn.command, turn.actionType, turn.target, turn.location, outcome, turn.consequences); err != nil {
+			return "", fmt.Errorf("failed to record action: %v", err)
+		}
+	}
+	s.contextMgr.RememberNarration(sessionID, last.command, prompt)
+
+	if err := s.contextMgr.WaitForEvents(); err != nil {
+		return "", fmt.Errorf("failed to apply actions: %v", err)
+	}
+	return narration, nil
+}
+
+// playBatchCommand validates a command against the game as the batch has left
+// it, then applies its effects
+func (s *GameServer) playBatchCommand(sessionID, command string) (gameTurn, error) {
+	if err := s.contextMgr.ValidateAction(sessionID, command); err != nil {
+		return gameTurn{}, fmt.Errorf("you can't do that: %s: %w", command, err)
+	}
+	return s.applyGameCommand(sessionID, command)
+}
+
+// batchPrompt builds one GM prompt describing a sequence of played commands
+func (s *GameServer) batchPrompt(sessionID string, turns []gameTurn, stopped string) (string, error) {
+	commands := make([]string, len(turns))
+	for i, turn := range turns {
+		commands[i] = turn.command
+	}
+
+	prompt, err := s.contextMgr.GenerateAIPromptForCommand(sessionID, strings.Join(commands, "; "))
+	if err != nil {
+		return "", fmt.Errorf("failed to generate AI prompt: %v", err)
+	}
+
+	var actions strings.Builder
+	for i, turn := range turns {
+		fmt.Fprintf(&actions, "\n%d. %s", i+1, turn.command)
+		if turn.outcome != "" {
+			fmt.Fprintf(&actions, "\n   %s", turn.outcome)
+		}
+	}
+	if stopped != "" {
+		fmt.Fprintf(&actions, "\n\nThe player also tried %s, which failed; nothing after it happened.", stopped)
+	}
+
+	return fmt.Sprintf("%s\n\nPlayer Actions, in order:%s\n\nAs the Game Master, narrate the outcome of this whole sequence in one cohesive response that moves the story forward.", prompt, actions.String()), nil
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"bytes"
+	stdcontext "context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"ai-rpg-mvp/context"
+)
+
+func TestProcessActionBatch(t *testing.T) {
+	server := newTestServer(t)
+	stub := useStubAI(t, server, "You look around, greet the keeper and head for the trees.")
+	sessionID, _ := server.contextMgr.CreateSession("player123", "TestPlayer")
+
+	commands := []string{"/look", "/talk tavern_keeper", "/move forest"}
+	narration, err := server.ProcessActionBatch(stdcontext.Background(), sessionID, commands)
+	if err != nil {
+		t.Fatalf("Failed to process batch: %v", err)
+	}
+	if narration != "You look around, greet the keeper and head for the trees." {
+		t.Errorf("Expected the GM's narration, got %q", narration)
+	}
+
+	sent := stub.Prompts()
+	if len(sent) != 1 {
+		t.Fatalf("Expected one AI call for the batch, got %d", len(sent))
+	}
+	if !strings.Contains(sent[0], "1. /look\n2. /talk tavern_keeper\n3. /move forest") {
+		t.Errorf("Expected the prompt to list the sequence, got:\n%s", sent[0])
+	}
+
+	actions, _ := server.contextMgr.GetRecentActions(sessionID, 10)
+	if len(actions) != 3 {
+		t.Fatalf("Expected 3 recorded actions, got %d", len(actions))
+	}
+	for i, command := range commands {
+		if actions[i].Command != command {
+			t.Errorf("Expected action %d to be %s, got %s", i, command, actions[i].Command)
+		}
+	}
+	if actions[2].Outcome != narration {
+		t.Errorf("Expected the last action to carry the narration, got %q", actions[2].Outcome)
+	}
+
+	// Each command's effects still applied
+	ctx, _ := server.contextMgr.GetContext(sessionID)
+	if ctx.Location.Current != "thornwick_forest" {
+		t.Errorf("Expected the move to apply, player is in %s", ctx.Location.Current)
+	}
+	if _, met := ctx.NPCStates["tavern_keeper"]; !met {
+		t.Error("Expected the talk to update the tavern keeper's relationship")
+	}
+}
+
+func TestProcessActionBatch_ValidatesEachCommandWhenPlayed(t *testing.T) {
+	server := newTestServer(t)
+	stub := useStubAI(t, server, "You search the undergrowth.")
+	sessionID, _ := server.contextMgr.CreateSession("player123", "TestPlayer")
+
+	// Searching is only possible in the forest, which the batch moves to first
+	server.contextMgr.AddActionValidator(context.ActionValidatorFunc(func(ctx *context.PlayerContext, command string) error {
+		if command == "/search" && ctx.Location.Current != "thornwick_forest" {
+			return errors.New("there is nothing to search here")
+		}
+		return nil
+	}))
+
+	if _, err := server.ProcessActionBatch(stdcontext.Background(), sessionID, []string{"/move forest", "/search"}); err != nil {
+		t.Fatalf("Expected the search to be allowed after the move, got %v", err)
+	}
+	if actions, _ := server.contextMgr.GetRecentActions(sessionID, 10); len(actions) != 2 {
+		t.Errorf("Expected both commands to be recorded, got %d actions", len(actions))
+	}
+
+	// A refused command ends the batch, but what came before it still plays
+	other, _ := server.contextMgr.CreateSession("player456", "OtherPlayer")
+	if _, err := server.ProcessActionBatch(stdcontext.Background(), other, []string{"/search"}); err == nil {
+		t.Error("Expected a batch starting with a refused command to fail")
+	}
+	if _, err := server.ProcessActionBatch(stdcontext.Background(), other, []string{"/look", "/search", "/move forest"}); err != nil {
+		t.Fatalf("Expected the commands before the refused one to play, got %v", err)
+	}
+	if actions, _ := server.contextMgr.GetRecentActions(other, 10); len(actions) != 1 || actions[0].Command != "/look" {
+		t.Errorf("Expected only /look to be recorded, got %+v", actions)
+	}
+	sent := stub.Prompts()
+	if !strings.Contains(sent[len(sent)-1], "/search (you can't do that") {
+		t.Errorf("Expected the GM to be told the search failed, got:\n%s", sent[len(sent)-1])
+	}
+}
+
+func TestHandleGameActionBatch_Rejections(t *testing.T) {
+	server := newTestServer(t)
+	stub := useStubAI(t, server, "Nothing happens.")
+	sessionID, _ := server.contextMgr.CreateSession("player123", "TestPlayer")
+
+	for name, body := range map[string]string{
+		"no commands": `{"session_id": "` + sessionID + `", "commands": []}`,
+		"too many":    `{"session_id": "` + sessionID + `", "commands": ["/look","/look","/look","/look","/look","/look","/look","/look","/look","/look","/look"]}`,
+	} {
+		recorder := httptest.NewRecorder()
+		server.handleGameActionBatch(recorder, httptest.NewRequest(http.MethodPost, "/api/game/batch", bytes.NewBufferString(body)))
+		if recorder.Code == http.StatusOK {
+			t.Errorf("%s: expected the batch to be rejected", name)
+		}
+	}
+
+	if len(stub.Prompts()) != 0 {
+		t.Error("Expected no AI calls for rejected batches")
+	}
+}
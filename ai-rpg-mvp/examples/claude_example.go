@@ -1,3 +1,5 @@
+//go:build ignore
+
 package main
 
 import (
@@ -19,29 +21,48 @@ func main() {
 
 	// Initialize context manager with in-memory storage
 	storage := context.NewMemoryStorage()
-	contextMgr := context.NewContextManager(storage)
+	contextMgr := context.NewContextManagerWithConfig(storage, cfg.Context)
 	defer contextMgr.Shutdown()
 
 	// Initialize AI service with Claude
 	aiConfig := ai.AIConfig{
 		Provider:           cfg.AI.Provider,
 		APIKey:             cfg.AI.APIKey,
+		BaseURL:            cfg.AI.BaseURL,
 		Model:              cfg.AI.Model,
 		MaxTokens:          cfg.AI.MaxTokens,
 		Temperature:        cfg.AI.Temperature,
 		Timeout:            cfg.AI.Timeout,
 		MaxRetries:         cfg.AI.MaxRetries,
 		RetryDelay:         cfg.AI.RetryDelay,
+		RetryMaxDelay:      cfg.AI.RetryMaxDelay,
 		RateLimitRequests:  cfg.AI.RateLimitRequests,
 		RateLimitDuration:  cfg.AI.RateLimitDuration,
 		EnableCaching:      cfg.AI.EnableCaching,
 		CacheTTL:           cfg.AI.CacheTTL,
+		CachePath:          cfg.AI.CachePath,
+		CacheMaxEntries:    cfg.AI.CacheMaxEntries,
+		CostPer1KInput:     cfg.AI.CostPer1KInput,
+		CostPer1KOutput:    cfg.AI.CostPer1KOutput,
+
+		PerSessionRateLimitRequests: cfg.AI.PerSessionRateLimitRequests,
+		PerSessionRateLimitDuration: cfg.AI.PerSessionRateLimitDuration,
+		PerSessionRateLimitIdle:     cfg.AI.PerSessionRateLimitIdle,
+
+		EmbeddingProvider: cfg.AI.EmbeddingProvider,
+		EmbeddingModel:    cfg.AI.EmbeddingModel,
+		EmbeddingBaseURL:  cfg.AI.EmbeddingBaseURL,
+		EmbeddingAPIKey:   cfg.AI.EmbeddingAPIKey,
+	}
+	for _, fallback := range cfg.AI.FallbackProviders() {
+		aiConfig.Fallbacks = append(aiConfig.Fallbacks, ai.ProviderConfig(fallback))
 	}
 
 	aiService, err := ai.NewAIService(aiConfig)
 	if err != nil {
 		log.Fatalf("Failed to initialize AI service: %v", err)
 	}
+	defer aiService.Close()
 
 	fmt.Printf("✅ Initialized AI service with %s provider\n\n", aiService.GetProviderName())
 
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// withCORS wraps next with the CORS policy from the server config. Requests
+// from origins that aren't allowed are rejected with 403, and preflight
+// requests are answered with 204 without reaching next.
+func (s *GameServer) withCORS(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		origin := r.Header.Get("Origin")
+		if origin == "" || s.config == nil || sameOrigin(r, origin) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if !s.allowedOrigin(r) {
+			http.Error(w, "Origin not allowed", http.StatusForbidden)
+			return
+		}
+
+		cors := s.config.Server.CORS
+		header := w.Header()
+		header.Add("Vary", "Origin")
+
+		// Browsers refuse a wildcard origin on credentialed requests, so echo
+		// the caller's origin whenever credentials are allowed; allowedOrigin
+		// only lets listed origins through then
+		if contains(cors.AllowedOrigins, "*") && !cors.AllowCredentials {
+			header.Set("Access-Control-Allow-Origin", "*")
+		} else {
+			header.Set("Access-Control-Allow-Origin", origin)
+		}
+		if cors.AllowCredentials {
+			header.Set("Access-Control-Allow-Credentials", "true")
+		}
+		if len(cors.ExposedHeaders) > 0 {
+			header.Set("Access-Control-Expose-Headers", strings.Join(cors.ExposedHeaders, ", "))
+		}
+
+		if r.Method != http.MethodOptions || r.Header.Get("Access-Control-Request-Method") == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		// Preflight
+		header.Add("Vary", "Access-Control-Request-Method")
+		header.Add("Vary", "Access-Control-Request-Headers")
+		header.Set("Access-Control-Allow-Methods", strings.Join(cors.AllowedMethods, ", "))
+		if contains(cors.AllowedHeaders, "*") {
+			// A literal "*" isn't honored with credentials, so grant the
+			// headers that were asked for instead
+			if requested := r.Header.Get("Access-Control-Request-Headers"); requested != "" {
+				header.Set("Access-Control-Allow-Headers", requested)
+			}
+		} else if len(cors.AllowedHeaders) > 0 {
+			header.Set("Access-Control-Allow-Headers", strings.Join(cors.AllowedHeaders, ", "))
+		}
+		if cors.MaxAge > 0 {
+			header.Set("Access-Control-Max-Age", strconv.Itoa(cors.MaxAge))
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
+
+// allowedOrigin reports whether the request's origin is one of the configured
+// CORS origins; requests without an origin, or from the server's own host,
+// are always allowed. A "*" origin matches any origin only while credentials
+// are off, so a misconfigured server never hands credentialed access to
+// every site.
+func (s *GameServer) allowedOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" || s.config == nil || sameOrigin(r, origin) {
+		return true
+	}
+
+	cors := s.config.Server.CORS
+	for _, allowed := range cors.AllowedOrigins {
+		if (allowed == "*" && !cors.AllowCredentials) || allowed == origin {
+			return true
+		}
+	}
+	return false
+}
+
+// sameOrigin reports whether origin points at the host serving the request,
+// as it does for the bundled web interface
+func sameOrigin(r *http.Request, origin string) bool {
+	parsed, err := url.Parse(origin)
+	return err == nil && parsed.Host == r.Host
+}
+
+// contains reports whether value is one of values
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
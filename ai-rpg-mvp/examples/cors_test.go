@@ -0,0 +1,121 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// corsTestHandler wraps a handler that records whether it was reached
+func corsTestHandler(server *GameServer, reached *bool) http.Handler {
+	return server.withCORS(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*reached = true
+	}))
+}
+
+func TestWithCORS_Preflight(t *testing.T) {
+	server := newTestServer(t)
+	server.config.Server.CORS.AllowedOrigins = []string{"https://play.example.com"}
+	server.config.Server.CORS.AllowCredentials = true
+	server.config.Server.CORS.MaxAge = 600
+
+	var reached bool
+	request := httptest.NewRequest(http.MethodOptions, "/api/game/action", nil)
+	request.Header.Set("Origin", "https://play.example.com")
+	request.Header.Set("Access-Control-Request-Method", http.MethodPost)
+	request.Header.Set("Access-Control-Request-Headers", "Content-Type")
+	recorder := httptest.NewRecorder()
+	corsTestHandler(server, &reached).ServeHTTP(recorder, request)
+
+	if recorder.Code != http.StatusNoContent {
+		t.Errorf("Expected status %d, got %d", http.StatusNoContent, recorder.Code)
+	}
+	if reached {
+		t.Error("Expected preflight to be answered by the middleware")
+	}
+
+	expected := map[string]string{
+		"Access-Control-Allow-Origin":      "https://play.example.com",
+		"Access-Control-Allow-Credentials": "true",
+		"Access-Control-Allow-Methods":     "GET, POST, PUT, DELETE, OPTIONS",
+		"Access-Control-Allow-Headers":     "Content-Type",
+		"Access-Control-Max-Age":           "600",
+	}
+	for name, value := range expected {
+		if got := recorder.Header().Get(name); got != value {
+			t.Errorf("Expected %s %q, got %q", name, value, got)
+		}
+	}
+}
+
+func TestWithCORS_WildcardWithCredentials(t *testing.T) {
+	server := newTestServer(t)
+	server.config.Server.CORS.AllowedOrigins = []string{"*"}
+
+	var reached bool
+	request := httptest.NewRequest(http.MethodGet, "/api/metrics", nil)
+	request.Header.Set("Origin", "https://play.example.com")
+
+	recorder := httptest.NewRecorder()
+	corsTestHandler(server, &reached).ServeHTTP(recorder, request)
+	if got := recorder.Header().Get("Access-Control-Allow-Origin"); got != "*" {
+		t.Errorf("Expected wildcard origin without credentials, got %q", got)
+	}
+
+	if !reached {
+		t.Error("Expected allowed request to reach the handler")
+	}
+
+	// With credentials the wildcard no longer matches; only listed origins
+	// are echoed
+	server.config.Server.CORS.AllowCredentials = true
+	reached = false
+	recorder = httptest.NewRecorder()
+	corsTestHandler(server, &reached).ServeHTTP(recorder, request)
+	if recorder.Code != http.StatusForbidden || reached {
+		t.Errorf("Expected the wildcard to be refused with credentials, got status %d", recorder.Code)
+	}
+	if got := recorder.Header().Get("Access-Control-Allow-Origin"); got != "" {
+		t.Errorf("Expected no CORS headers, got Access-Control-Allow-Origin %q", got)
+	}
+
+	server.config.Server.CORS.AllowedOrigins = []string{"*", "https://play.example.com"}
+	recorder = httptest.NewRecorder()
+	corsTestHandler(server, &reached).ServeHTTP(recorder, request)
+	if got := recorder.Header().Get("Access-Control-Allow-Origin"); got != "https://play.example.com" {
+		t.Errorf("Expected a listed origin to be echoed with credentials, got %q", got)
+	}
+	if !reached {
+		t.Error("Expected listed origin to reach the handler")
+	}
+}
+
+func TestWithCORS_DisallowedOrigin(t *testing.T) {
+	server := newTestServer(t)
+	server.config.Server.CORS.AllowedOrigins = []string{"https://play.example.com"}
+
+	var reached bool
+	request := httptest.NewRequest(http.MethodPost, "/api/game/action", nil)
+	request.Header.Set("Origin", "https://evil.example.com")
+	recorder := httptest.NewRecorder()
+	corsTestHandler(server, &reached).ServeHTTP(recorder, request)
+
+	if recorder.Code != http.StatusForbidden {
+		t.Errorf("Expected status %d, got %d", http.StatusForbidden, recorder.Code)
+	}
+	if reached {
+		t.Error("Expected disallowed origin not to reach the handler")
+	}
+	if got := recorder.Header().Get("Access-Control-Allow-Origin"); got != "" {
+		t.Errorf("Expected no CORS headers, got Access-Control-Allow-Origin %q", got)
+	}
+
+	// The bundled web interface is served from the same host
+	request = httptest.NewRequest(http.MethodPost, "http://localhost:8080/api/game/action", nil)
+	request.Header.Set("Origin", "http://localhost:8080")
+	recorder = httptest.NewRecorder()
+	corsTestHandler(server, &reached).ServeHTTP(recorder, request)
+	if !reached {
+		t.Error("Expected same-origin request to be allowed")
+	}
+}
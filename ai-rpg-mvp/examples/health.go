@@ -0,0 +1,46 @@
+package main
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+)
+
+// readinessReport is the body returned by /readyz
+type readinessReport struct {
+	Status string            `json:"status"`
+	Failed map[string]string `json:"failed,omitempty"` // dependency -> reason
+}
+
+// handleHealthz reports that the process is up. It touches no dependencies
+// so it stays cheap enough to poll often.
+func (s *GameServer) handleHealthz(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Write([]byte(`{"status":"ok"}`))
+}
+
+// handleReadyz reports whether the server can take traffic: the storage
+// backend must answer a ping and an AI provider must be configured. When a
+// dependency fails it responds 503 and names it in the body.
+func (s *GameServer) handleReadyz(w http.ResponseWriter, r *http.Request) {
+	failed := make(map[string]string)
+	if err := s.contextMgr.PingStorage(); err != nil {
+		failed["storage"] = err.Error()
+	}
+	if s.aiService == nil || s.aiService.GetProviderName() == "" {
+		failed["ai"] = "no AI provider configured"
+	}
+
+	report := readinessReport{Status: "ok"}
+	status := http.StatusOK
+	if len(failed) > 0 {
+		report = readinessReport{Status: "unavailable", Failed: failed}
+		status = http.StatusServiceUnavailable
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(report); err != nil {
+		slog.Error("Failed to encode readiness report", "error", err)
+	}
+}
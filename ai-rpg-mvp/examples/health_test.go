@@ -0,0 +1,60 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"ai-rpg-mvp/context"
+)
+
+// unreachableStorage is memory storage whose backend can't be pinged
+type unreachableStorage struct {
+	*context.MemoryContextStorage
+}
+
+func (unreachableStorage) Ping() error {
+	return errors.New("connection refused")
+}
+
+func TestReadyz(t *testing.T) {
+	server := newTestServer(t)
+
+	recorder := httptest.NewRecorder()
+	server.handleReadyz(recorder, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	if recorder.Code != http.StatusOK {
+		t.Errorf("Expected status %d, got %d: %s", http.StatusOK, recorder.Code, recorder.Body.String())
+	}
+}
+
+func TestReadyz_StorageUnreachable(t *testing.T) {
+	server := newTestServer(t)
+	contextMgr := context.NewContextManager(unreachableStorage{context.NewMemoryStorage()})
+	t.Cleanup(contextMgr.Shutdown)
+	server.contextMgr = contextMgr
+
+	recorder := httptest.NewRecorder()
+	server.handleReadyz(recorder, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	if recorder.Code != http.StatusServiceUnavailable {
+		t.Fatalf("Expected status %d, got %d", http.StatusServiceUnavailable, recorder.Code)
+	}
+
+	var report readinessReport
+	if err := json.Unmarshal(recorder.Body.Bytes(), &report); err != nil {
+		t.Fatalf("Failed to decode readiness report: %v", err)
+	}
+	if report.Failed["storage"] == "" {
+		t.Errorf("Expected storage to be reported as failed, got %+v", report)
+	}
+	if _, ok := report.Failed["ai"]; ok {
+		t.Errorf("Expected only storage to fail, got %+v", report)
+	}
+
+	recorder = httptest.NewRecorder()
+	server.handleHealthz(recorder, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	if recorder.Code != http.StatusOK {
+		t.Errorf("Expected /healthz to stay %d, got %d", http.StatusOK, recorder.Code)
+	}
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	stdcontext "context"
+	"encoding/json"
+	"net/http"
+)
+
+// RegenerateRequest asks for a new GM response to a session's last action
+type RegenerateRequest struct {
+	SessionID string `json:"session_id"`
+}
+
+// handleRegenerate replaces the GM's response to the session's last action
+// with a freshly generated one
+func (s *GameServer) handleRegenerate(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var request RegenerateRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		s.sendErrorResponse(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	if request.SessionID == "" {
+		s.sendErrorResponse(w, "SessionID is required", http.StatusBadRequest)
+		return
+	}
+
+	response, err := s.RegenerateLastResponse(r.Context(), request.SessionID)
+	if err != nil {
+		s.sendErrorResponse(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+
+	s.sendJSONResponse(w, GameResponse{Success: true, Message: response, SessionID: request.SessionID})
+}
+
+// RegenerateLastResponse asks the AI again for its narration of the
+// session's most recent action, skipping the cached reply, and stores the
+// new one as that action's outcome. Cancelling ctx abandons the AI call.
+func (s *GameServer) RegenerateLastResponse(ctx stdcontext.Context, sessionID string) (string, error) {
+	return s.contextMgr.RegenerateLastOutcome(sessionID, func(prompt string) (string, error) {
+		return s.aiService.RegenerateGMResponseForSessionCtx(ctx, sessionID, prompt)
+	})
+}
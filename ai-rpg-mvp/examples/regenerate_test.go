@@ -0,0 +1,68 @@
+package main
+
+import (
+	"bytes"
+	stdcontext "context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRegenerateLastResponse(t *testing.T) {
+	server := newTestServer(t)
+	useStubAI(t, server, "The square is quiet.", "Pigeons scatter across the busy square.")
+	sessionID, _ := server.contextMgr.CreateSession("player123", "TestPlayer")
+
+	if _, err := server.RegenerateLastResponse(stdcontext.Background(), sessionID); err == nil {
+		t.Error("Expected regenerating before any action to fail")
+	}
+
+	first, err := server.processGameCommand(stdcontext.Background(), sessionID, "/look", "")
+	if err != nil {
+		t.Fatalf("Failed to play command: %v", err)
+	}
+	if first.Message != "The square is quiet." {
+		t.Fatalf("Expected the first reply, got %q", first.Message)
+	}
+
+	response, err := server.RegenerateLastResponse(stdcontext.Background(), sessionID)
+	if err != nil {
+		t.Fatalf("Failed to regenerate: %v", err)
+	}
+	if response != "Pigeons scatter across the busy square." {
+		t.Errorf("Expected a fresh reply rather than the cached one, got %q", response)
+	}
+
+	actions, _ := server.contextMgr.GetRecentActions(sessionID, 10)
+	if len(actions) != 1 || actions[0].Outcome != response {
+		t.Errorf("Expected the action's outcome to be replaced, got %+v", actions)
+	}
+	history, _ := server.contextMgr.GetDialogueHistory(sessionID, 0)
+	if len(history) != 1 || history[0].Response != response {
+		t.Errorf("Expected the dialogue turn to be replaced, got %+v", history)
+	}
+
+	// Actions the GM didn't narrate can't be regenerated
+	server.contextMgr.RecordAction(sessionID, "/rest", "rest", "", "village_square", "You rest.", nil)
+	if _, err := server.RegenerateLastResponse(stdcontext.Background(), sessionID); err == nil {
+		t.Error("Expected regenerating an action without a GM prompt to fail")
+	}
+}
+
+func TestHandleRegenerate(t *testing.T) {
+	server := newTestServer(t)
+	sessionID, _ := server.contextMgr.CreateSession("player123", "TestPlayer")
+
+	body, _ := json.Marshal(RegenerateRequest{SessionID: sessionID})
+	recorder := httptest.NewRecorder()
+	server.handleRegenerate(recorder, httptest.NewRequest(http.MethodPost, "/api/game/regenerate", bytes.NewReader(body)))
+
+	if recorder.Code != http.StatusUnprocessableEntity {
+		t.Errorf("Expected 422 with nothing to regenerate, got %d", recorder.Code)
+	}
+	if !strings.Contains(recorder.Body.String(), "no response to regenerate") {
+		t.Errorf("Expected a clear error, got %s", recorder.Body.String())
+	}
+}
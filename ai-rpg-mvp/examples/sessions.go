@@ -0,0 +1,99 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"ai-rpg-mvp/context"
+)
+
+// defaultSessionPageSize is how many sessions /api/sessions returns when no
+// page size is given
+const defaultSessionPageSize = 20
+
+// handleListSessions lists active sessions, newest first, filtered by the
+// player_id, location, min_reputation and active_within (a duration such as
+// "15m") query parameters and paged with page and page_size
+func (s *GameServer) handleListSessions(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	query := r.URL.Query()
+	filter, err := sessionFilterFromQuery(query)
+	if err != nil {
+		s.sendErrorResponse(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	page, err := intQueryParam(query, "page", 1)
+	if err != nil {
+		s.sendErrorResponse(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	pageSize, err := intQueryParam(query, "page_size", defaultSessionPageSize)
+	if err != nil {
+		s.sendErrorResponse(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	sessions, total, err := s.contextMgr.ListSessions(filter, page, pageSize)
+	if err != nil {
+		s.sendErrorResponse(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	s.sendJSONResponse(w, GameResponse{
+		Success: true,
+		Message: fmt.Sprintf("%d sessions match", total),
+		Context: map[string]interface{}{
+			"sessions":  sessions,
+			"total":     total,
+			"page":      page,
+			"page_size": pageSize,
+		},
+	})
+}
+
+// sessionFilterFromQuery reads a session filter from query parameters
+func sessionFilterFromQuery(query url.Values) (context.SessionFilter, error) {
+	filter := context.SessionFilter{
+		PlayerID: query.Get("player_id"),
+		Location: query.Get("location"),
+	}
+
+	if value := query.Get("min_reputation"); value != "" {
+		minReputation, err := strconv.Atoi(value)
+		if err != nil {
+			return filter, fmt.Errorf("min_reputation must be a whole number, got %q", value)
+		}
+		filter.MinReputation = &minReputation
+	}
+
+	if value := query.Get("active_within"); value != "" {
+		activeWithin, err := time.ParseDuration(value)
+		if err != nil {
+			return filter, fmt.Errorf("active_within must be a duration such as 15m, got %q", value)
+		}
+		filter.ActiveWithin = activeWithin
+	}
+
+	return filter, nil
+}
+
+// intQueryParam reads a whole-number query parameter, or fallback when unset
+func intQueryParam(query url.Values, name string, fallback int) (int, error) {
+	value := query.Get(name)
+	if value == "" {
+		return fallback, nil
+	}
+
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("%s must be a whole number, got %q", name, value)
+	}
+	return n, nil
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestHandleListSessions(t *testing.T) {
+	server := newTestServer(t)
+	server.contextMgr.CreateSession("player1", "Aragorn")
+	rangerID, _ := server.contextMgr.CreateSession("player2", "Legolas")
+	server.contextMgr.UpdateLocation(rangerID, "forest")
+
+	recorder := httptest.NewRecorder()
+	server.handleListSessions(recorder, httptest.NewRequest(http.MethodGet, "/api/sessions?location=forest&page_size=5", nil))
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, recorder.Code, recorder.Body.String())
+	}
+
+	var response struct {
+		Context struct {
+			Sessions []struct {
+				SessionID string `json:"session_id"`
+				Name      string `json:"name"`
+			} `json:"sessions"`
+			Total    int `json:"total"`
+			PageSize int `json:"page_size"`
+		} `json:"context"`
+	}
+	if err := json.NewDecoder(recorder.Body).Decode(&response); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	listing := response.Context
+	if listing.Total != 1 || len(listing.Sessions) != 1 || listing.Sessions[0].SessionID != rangerID || listing.Sessions[0].Name != "Legolas" {
+		t.Errorf("Expected only Legolas in the forest, got %+v", listing)
+	}
+	if listing.PageSize != 5 {
+		t.Errorf("Expected page size 5, got %d", listing.PageSize)
+	}
+
+	for _, query := range []string{"page=0", "page=one", "page_size=1000", "min_reputation=high", "active_within=soon"} {
+		recorder := httptest.NewRecorder()
+		server.handleListSessions(recorder, httptest.NewRequest(http.MethodGet, "/api/sessions?"+query, nil))
+		if recorder.Code != http.StatusBadRequest {
+			t.Errorf("Expected status %d for %s, got %d", http.StatusBadRequest, query, recorder.Code)
+		}
+	}
+}
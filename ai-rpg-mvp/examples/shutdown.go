@@ -0,0 +1,36 @@
+package main
+
+import (
+	stdcontext "context"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"time"
+)
+
+// runServer serves on listener until a signal arrives on stop, then stops
+// accepting connections and gives in-flight requests up to drainTimeout to
+// finish. It returns an error if the server fails or draining times out.
+func runServer(server *http.Server, listener net.Listener, stop <-chan os.Signal, drainTimeout time.Duration) error {
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- server.Serve(listener)
+	}()
+
+	select {
+	case err := <-serveErr:
+		return fmt.Errorf("server stopped: %w", err)
+	case sig := <-stop:
+		slog.Info("Draining connections", "signal", sig.String(), "timeout", drainTimeout)
+	}
+
+	ctx, cancel := stdcontext.WithTimeout(stdcontext.Background(), drainTimeout)
+	defer cancel()
+
+	if err := server.Shutdown(ctx); err != nil {
+		return fmt.Errorf("failed to drain connections: %w", err)
+	}
+	return nil
+}
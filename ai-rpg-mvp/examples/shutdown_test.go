@@ -0,0 +1,95 @@
+package main
+
+import (
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// startTestServer runs handler through runServer on an ephemeral port and
+// returns its address, the stop channel and the channel runServer reports on
+func startTestServer(t *testing.T, handler http.Handler, drainTimeout time.Duration) (string, chan os.Signal, chan error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+
+	stop := make(chan os.Signal, 1)
+	result := make(chan error, 1)
+	go func() {
+		result <- runServer(&http.Server{Handler: handler}, listener, stop, drainTimeout)
+	}()
+
+	return listener.Addr().String(), stop, result
+}
+
+// waitForListenerClosed waits until the server stops accepting connections
+func waitForListenerClosed(t *testing.T, addr string) {
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		conn, err := net.Dial("tcp", addr)
+		if err != nil {
+			return
+		}
+		conn.Close()
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatal("Server kept accepting connections after shutdown started")
+}
+
+func TestRunServer_DrainsPendingRequest(t *testing.T) {
+	started := make(chan struct{})
+	release := make(chan struct{})
+	addr, stop, result := startTestServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+		io.WriteString(w, "done")
+	}), time.Second)
+
+	responses := make(chan string, 1)
+	go func() {
+		resp, err := http.Get("http://" + addr)
+		if err != nil {
+			responses <- "error: " + err.Error()
+			return
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		responses <- string(body)
+	}()
+
+	<-started
+	stop <- syscall.SIGTERM
+	waitForListenerClosed(t, addr)
+	close(release)
+
+	if body := <-responses; body != "done" {
+		t.Errorf("Expected pending request to complete, got %q", body)
+	}
+	if err := <-result; err != nil {
+		t.Errorf("Expected clean shutdown, got %v", err)
+	}
+}
+
+func TestRunServer_DrainTimeout(t *testing.T) {
+	started := make(chan struct{})
+	release := make(chan struct{})
+	defer close(release)
+	addr, stop, result := startTestServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+	}), 20*time.Millisecond)
+
+	go http.Get("http://" + addr)
+
+	<-started
+	stop <- syscall.SIGTERM
+
+	if err := <-result; err == nil {
+		t.Error("Expected an error when draining times out")
+	}
+}
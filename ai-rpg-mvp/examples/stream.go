@@ -0,0 +1,120 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+)
+
+// streamResult is the outcome of a streamed GM response
+type streamResult struct {
+	response string
+	err      error
+}
+
+// handleGameStream plays a command like /api/game/action but streams the GM's
+// narration as Server-Sent Events while it's generated. Each chunk is sent as
+// a data event holding a JSON string, and the stream ends with a "summary"
+// event carrying the same body /api/game/action returns. If the client goes
+// away mid-stream nothing more is sent, but the command has already taken
+// effect, so it is recorded with the fallback narration.
+func (s *GameServer) handleGameStream(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	sessionID := r.URL.Query().Get("session_id")
+	command := r.URL.Query().Get("command")
+	if sessionID == "" || command == "" {
+		s.sendErrorResponse(w, "session_id and command parameters are required", http.StatusBadRequest)
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		s.sendErrorResponse(w, "Streaming not supported", http.StatusInternalServerError)
+		return
+	}
+
+	// Turn away impossible actions before spending an AI call on them
+	if err := s.contextMgr.ValidateAction(sessionID, command); err != nil {
+		s.sendErrorResponse(w, fmt.Sprintf("You can't do that: %s", err), http.StatusUnprocessableEntity)
+		return
+	}
+
+	turn, err := s.prepareGameCommand(sessionID, command)
+	if err != nil {
+		s.sendErrorResponse(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	// Generate in the background so a client disconnect ends the handler
+	// right away, and the request context cancels the AI call too; chunks are
+	// only written from this goroutine
+	clientGone := r.Context().Done()
+	chunks := make(chan string)
+	done := make(chan streamResult, 1)
+	go func() {
+		response, err := s.aiService.GenerateGMResponseStreamForSessionCtx(r.Context(), sessionID, turn.prompt, func(chunk string) {
+			select {
+			case chunks <- chunk:
+			case <-clientGone:
+			}
+		})
+		done <- streamResult{response: response, err: err}
+	}()
+
+	var result streamResult
+	for finished := false; !finished; {
+		select {
+		case chunk := <-chunks:
+			writeEvent(w, "", chunk)
+			flusher.Flush()
+		case result = <-done:
+			finished = true
+		case <-clientGone:
+			slog.Info("Client left the stream, recording the turn with the fallback narration", "session_id", sessionID)
+			if _, err := s.completeGameCommand(sessionID, turn, turn.fallbackResponse()); err != nil {
+				slog.Error("Failed to record abandoned turn", "session_id", sessionID, "error", err)
+			}
+			return
+		}
+	}
+
+	if result.err != nil {
+		slog.Error("AI service error", "session_id", sessionID, "error", result.err)
+		result.response = turn.fallbackResponse()
+		writeEvent(w, "", result.response)
+	}
+
+	response, err := s.completeGameCommand(sessionID, turn, result.response)
+	if err != nil {
+		writeEvent(w, "error", GameResponse{Success: false, Error: err.Error()})
+	} else {
+		writeEvent(w, "summary", response)
+	}
+	flusher.Flush()
+}
+
+// writeEvent writes one Server-Sent Event with a JSON-encoded payload; an
+// empty name sends an unnamed (message) event
+func writeEvent(w http.ResponseWriter, name string, payload interface{}) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		slog.Error("Failed to encode event", "error", err)
+		return
+	}
+
+	if name != "" {
+		fmt.Fprintf(w, "event: %s\n", name)
+	}
+	fmt.Fprintf(w, "data: %s\n\n", data)
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	stdcontext "context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+// sseEvent is one parsed Server-Sent Event
+type sseEvent struct {
+	name string
+	data string
+}
+
+// parseEvents splits a Server-Sent Events body into its events
+func parseEvents(body string) []sseEvent {
+	var events []sseEvent
+	for _, block := range strings.Split(strings.TrimSpace(body), "\n\n") {
+		var event sseEvent
+		for _, line := range strings.Split(block, "\n") {
+			switch {
+			case strings.HasPrefix(line, "event: "):
+				event.name = strings.TrimPrefix(line, "event: ")
+			case strings.HasPrefix(line, "data: "):
+				event.data = strings.TrimPrefix(line, "data: ")
+			}
+		}
+		if event.data != "" {
+			events = append(events, event)
+		}
+	}
+	return events
+}
+
+func streamURL(sessionID, command string) string {
+	return "/api/game/stream?" + url.Values{"session_id": {sessionID}, "command": {command}}.Encode()
+}
+
+func TestHandleGameStream(t *testing.T) {
+	server := newTestServer(t)
+	useStubAI(t, server, "The forest closes in around you.")
+
+	sessionID, err := server.contextMgr.CreateSession("player123", "TestPlayer")
+	if err != nil {
+		t.Fatalf("Failed to create session: %v", err)
+	}
+
+	recorder := httptest.NewRecorder()
+	server.handleGameStream(recorder, httptest.NewRequest(http.MethodGet, streamURL(sessionID, "/look around"), nil))
+
+	if contentType := recorder.Header().Get("Content-Type"); contentType != "text/event-stream" {
+		t.Errorf("Expected text/event-stream, got %s", contentType)
+	}
+
+	events := parseEvents(recorder.Body.String())
+	if len(events) != 7 {
+		t.Fatalf("Expected a chunk per word and a summary, got %d events: %q", len(events), recorder.Body.String())
+	}
+
+	var narration string
+	for _, event := range events[:6] {
+		var chunk string
+		if err := json.Unmarshal([]byte(event.data), &chunk); err != nil || event.name != "" {
+			t.Fatalf("Expected unnamed chunk event, got %+v", event)
+		}
+		narration += chunk
+	}
+	if narration != "The forest closes in around you." {
+		t.Errorf("Unexpected narration: %s", narration)
+	}
+
+	summary := events[len(events)-1]
+	if summary.name != "summary" {
+		t.Fatalf("Expected stream to end with a summary event, got %+v", summary)
+	}
+	var response GameResponse
+	if err := json.Unmarshal([]byte(summary.data), &response); err != nil {
+		t.Fatalf("Failed to decode summary: %v", err)
+	}
+	if !response.Success || response.Message != narration || response.Context == nil {
+		t.Errorf("Expected summary with the narration and context, got %+v", response)
+	}
+
+	actions, _ := server.contextMgr.GetRecentActions(sessionID, 10)
+	if len(actions) != 1 {
+		t.Errorf("Expected the streamed action to be recorded, got %d actions", len(actions))
+	}
+}
+
+func TestHandleGameStream_ClientDisconnect(t *testing.T) {
+	server := newTestServer(t)
+	useStubAI(t, server, "Too late.")
+
+	sessionID, _ := server.contextMgr.CreateSession("player123", "TestPlayer")
+
+	ctx, cancel := stdcontext.WithCancel(stdcontext.Background())
+	cancel()
+
+	recorder := httptest.NewRecorder()
+	request := httptest.NewRequest(http.MethodGet, streamURL(sessionID, "/look around"), nil).WithContext(ctx)
+	server.handleGameStream(recorder, request)
+
+	if strings.Contains(recorder.Body.String(), "event: summary") {
+		t.Errorf("Expected no summary after the client left, got %q", recorder.Body.String())
+	}
+
+	// The command already took effect, so its turn is recorded all the same
+	server.contextMgr.WaitForEvents()
+	actions, _ := server.contextMgr.GetRecentActions(sessionID, 10)
+	if len(actions) != 1 {
+		t.Fatalf("Expected the action to be recorded after the client left, got %d", len(actions))
+	}
+	if !strings.Contains(actions[0].Outcome, "details are unclear") {
+		t.Errorf("Expected the fallback narration, got %q", actions[0].Outcome)
+	}
+}
+
+func TestHandleGameStream_RequiresParameters(t *testing.T) {
+	server := newTestServer(t)
+
+	recorder := httptest.NewRecorder()
+	server.handleGameStream(recorder, httptest.NewRequest(http.MethodGet, "/api/game/stream?session_id=abc", nil))
+	if recorder.Code != http.StatusBadRequest {
+		t.Errorf("Expected status %d, got %d", http.StatusBadRequest, recorder.Code)
+	}
+}
@@ -1,22 +1,35 @@
 package main
 
 import (
+	stdcontext "context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"log"
+	"log/slog"
+	"net"
 	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
 	"time"
 
 	"ai-rpg-mvp/ai"
 	"ai-rpg-mvp/config"
 	"ai-rpg-mvp/context"
+	"ai-rpg-mvp/logging"
+	"ai-rpg-mvp/metrics"
 )
 
 // GameServer represents our RPG game server
 type GameServer struct {
 	contextMgr *context.ContextManager
 	aiService  *ai.AIService
+	metrics    *metrics.Registry
 	config     *config.Config
+	startTime  time.Time
 }
 
 // PlayerCommand represents a command from the player
@@ -25,6 +38,7 @@ type PlayerCommand struct {
 	Command   string `json:"command"`
 	PlayerID  string `json:"player_id,omitempty"`
 	PlayerName string `json:"player_name,omitempty"`
+	IdempotencyKey string `json:"idempotency_key,omitempty"` // retries with the same key are applied once
 }
 
 // GameResponse represents the server's response
@@ -37,6 +51,13 @@ type GameResponse struct {
 }
 
 func main() {
+	os.Exit(run())
+}
+
+// run starts the server and blocks until it has shut down, returning the
+// process exit code. Deferred cleanup, including persisting every cached
+// context, runs before main exits.
+func run() int {
 	// Load configuration
 	cfg := config.LoadConfig()
 	
@@ -45,45 +66,106 @@ func main() {
 		log.Fatalf("Invalid configuration: %v", err)
 	}
 
-	// Initialize context manager with in-memory storage
-	// In production, you would use PostgreSQL storage
-	storage := context.NewMemoryStorage()
-	contextMgr := context.NewContextManager(storage)
+	logger, logOutput, err := logging.New(cfg.Logging)
+	if err != nil {
+		log.Fatalf("Failed to set up logging: %v", err)
+	}
+	defer logOutput.Close()
+	slog.SetDefault(logger)
+
+	// Initialize context manager with the configured storage backend
+	storage, err := context.NewStorage(cfg.Context.StorageBackend, *cfg)
+	if err != nil {
+		log.Fatalf("Failed to initialize storage: %v", err)
+	}
+	if closer, ok := storage.(io.Closer); ok {
+		defer closer.Close()
+	}
+	contextMgr := context.NewContextManagerWithConfig(storage, cfg.Context)
+	contextMgr.SetLogger(logger)
 	defer contextMgr.Shutdown()
 
 	// Initialize AI service
 	aiConfig := ai.AIConfig{
 		Provider:           cfg.AI.Provider,
 		APIKey:             cfg.AI.APIKey,
+		BaseURL:            cfg.AI.BaseURL,
 		Model:              cfg.AI.Model,
 		MaxTokens:          cfg.AI.MaxTokens,
 		Temperature:        cfg.AI.Temperature,
 		Timeout:            cfg.AI.Timeout,
 		MaxRetries:         cfg.AI.MaxRetries,
 		RetryDelay:         cfg.AI.RetryDelay,
+		RetryMaxDelay:      cfg.AI.RetryMaxDelay,
 		RateLimitRequests:  cfg.AI.RateLimitRequests,
 		RateLimitDuration:  cfg.AI.RateLimitDuration,
 		EnableCaching:      cfg.AI.EnableCaching,
 		CacheTTL:           cfg.AI.CacheTTL,
+		CachePath:          cfg.AI.CachePath,
+		CacheMaxEntries:    cfg.AI.CacheMaxEntries,
+		CostPer1KInput:     cfg.AI.CostPer1KInput,
+		CostPer1KOutput:    cfg.AI.CostPer1KOutput,
+
+		PerSessionRateLimitRequests: cfg.AI.PerSessionRateLimitRequests,
+		PerSessionRateLimitDuration: cfg.AI.PerSessionRateLimitDuration,
+		PerSessionRateLimitIdle:     cfg.AI.PerSessionRateLimitIdle,
+
+		EmbeddingProvider: cfg.AI.EmbeddingProvider,
+		EmbeddingModel:    cfg.AI.EmbeddingModel,
+		EmbeddingBaseURL:  cfg.AI.EmbeddingBaseURL,
+		EmbeddingAPIKey:   cfg.AI.EmbeddingAPIKey,
+		Logger:            logger,
+	}
+	for _, fallback := range cfg.AI.FallbackProviders() {
+		aiConfig.Fallbacks = append(aiConfig.Fallbacks, ai.ProviderConfig(fallback))
 	}
 
 	aiService, err := ai.NewAIService(aiConfig)
 	if err != nil {
 		log.Fatalf("Failed to initialize AI service: %v", err)
 	}
+	defer aiService.Close()
+	contextMgr.SetSummarizer(aiService)
+	contextMgr.SetDialogueGenerator(aiService)
+	contextMgr.SetFactExtractor(aiService)
+	contextMgr.SetCommandInterpreter(aiService)
+
+	registry := metrics.NewRegistry()
+	registry.RegisterSessions(contextMgr)
+	aiService.SetMetrics(registry)
+
+	if aiConfig.EmbeddingProvider != "" {
+		embedder, err := ai.NewEmbedder(aiConfig)
+		if err != nil {
+			log.Fatalf("Failed to initialize embeddings: %v", err)
+		}
+		contextMgr.SetEmbedder(embedder)
+	}
 
 	server := &GameServer{
 		contextMgr: contextMgr,
 		aiService:  aiService,
+		metrics:    registry,
 		config:     cfg,
+		startTime:  time.Now(),
 	}
 
 	// Setup HTTP routes
 	http.HandleFunc("/api/session/create", server.handleCreateSession)
+	http.HandleFunc("/api/session/", server.handleDeleteSession)
+	http.HandleFunc("/api/session/transcript", server.handleTranscript)
+	http.HandleFunc("/api/sessions", server.handleListSessions)
 	http.HandleFunc("/api/game/action", server.handleGameAction)
+	http.HandleFunc("/api/game/batch", server.handleGameActionBatch)
+	http.HandleFunc("/api/game/stream", server.handleGameStream)
+	http.HandleFunc("/api/game/regenerate", server.handleRegenerate)
 	http.HandleFunc("/api/game/status", server.handleGameStatus)
 	http.HandleFunc("/api/ai/prompt", server.handleAIPrompt)
 	http.HandleFunc("/api/metrics", server.handleMetrics)
+	http.Handle("/metrics", registry.Handler())
+	http.HandleFunc("/ws", server.handleWebSocket)
+	http.HandleFunc("/healthz", server.handleHealthz)
+	http.HandleFunc("/readyz", server.handleReadyz)
 
 	// Serve static files for a simple web interface
 	http.HandleFunc("/", server.handleIndex)
@@ -92,12 +174,40 @@ func main() {
 		aiService.GetProviderName(), cfg.Server.Port)
 	fmt.Println("API Endpoints:")
 	fmt.Println("  POST /api/session/create - Create new session")
+	fmt.Println("  DELETE /api/session/:session_id - End a session")
+	fmt.Println("  GET  /api/sessions?location=...&page=... - List active sessions, filtered and paged")
 	fmt.Println("  POST /api/game/action - Execute game action with AI GM")
+	fmt.Println("  POST /api/game/batch - Execute several actions narrated by one AI GM turn")
+	fmt.Println("  GET  /api/game/stream?session_id=...&command=... - Stream the GM's narration (SSE)")
+	fmt.Println("  POST /api/game/regenerate - Regenerate the GM's response to the last action")
 	fmt.Println("  GET  /api/game/status/:session_id - Get game status")
 	fmt.Println("  GET  /api/ai/prompt/:session_id - Get AI prompt")
 	fmt.Println("  GET  /api/metrics - Get system metrics")
+	fmt.Println("  GET  /metrics - Prometheus metrics")
+	fmt.Println("  WS   /ws?session_id=... - Live game session")
+	fmt.Println("  GET  /healthz - Liveness check")
+	fmt.Println("  GET  /readyz - Readiness check (storage and AI provider)")
+
+	httpServer := &http.Server{
+		Handler:      server.withCORS(http.DefaultServeMux),
+		ReadTimeout:  cfg.Server.ReadTimeout,
+		WriteTimeout: cfg.Server.WriteTimeout,
+		IdleTimeout:  cfg.Server.IdleTimeout,
+	}
+	listener, err := net.Listen("tcp", cfg.GetServerAddress())
+	if err != nil {
+		log.Fatalf("Failed to listen on %s: %v", cfg.GetServerAddress(), err)
+	}
 
-	log.Fatal(http.ListenAndServe(cfg.GetServerAddress(), nil))
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
+
+	if err := runServer(httpServer, listener, stop, cfg.Server.WriteTimeout); err != nil {
+		logger.Error("Shutdown error", "error", err)
+		return 1
+	}
+	logger.Info("Server stopped, saving sessions")
+	return 0
 }
 
 func (s *GameServer) handleCreateSession(w http.ResponseWriter, r *http.Request) {
@@ -135,6 +245,36 @@ func (s *GameServer) handleCreateSession(w http.ResponseWriter, r *http.Request)
 	s.sendJSONResponse(w, response)
 }
 
+func (s *GameServer) handleDeleteSession(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	sessionID := strings.TrimPrefix(r.URL.Path, "/api/session/")
+	if sessionID == "" || strings.Contains(sessionID, "/") {
+		s.sendErrorResponse(w, "session ID is required", http.StatusBadRequest)
+		return
+	}
+
+	if err := s.contextMgr.EndSession(sessionID); err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, context.ErrSessionNotFound) {
+			status = http.StatusNotFound
+		}
+		s.sendErrorResponse(w, fmt.Sprintf("Failed to end session: %v", err), status)
+		return
+	}
+
+	response := GameResponse{
+		Success:   true,
+		Message:   "Session ended",
+		SessionID: sessionID,
+	}
+
+	s.sendJSONResponse(w, response)
+}
+
 func (s *GameServer) handleGameAction(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
@@ -152,8 +292,32 @@ func (s *GameServer) handleGameAction(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Process the command and generate response
-	response, err := s.processGameCommand(cmd.SessionID, cmd.Command)
+	// A retried request is answered without replaying the action. The key is
+	// claimed before anything changes, so a retry racing the first attempt
+	// is caught too.
+	key := cmd.IdempotencyKey
+	if key == "" {
+		key = r.Header.Get("Idempotency-Key")
+	}
+	if !s.contextMgr.ClaimIdempotencyKey(cmd.SessionID, key) {
+		s.sendJSONResponse(w, alreadyAppliedResponse())
+		return
+	}
+
+	// Turn away impossible actions before spending an AI call on them
+	if err := s.contextMgr.ValidateAction(cmd.SessionID, cmd.Command); err != nil {
+		s.contextMgr.ReleaseIdempotencyKey(cmd.SessionID, key)
+		if errors.Is(err, context.ErrSessionNotFound) {
+			s.sendErrorResponse(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		s.sendErrorResponse(w, fmt.Sprintf("You can't do that: %s", err), http.StatusUnprocessableEntity)
+		return
+	}
+
+	// Process the command and generate response; the AI call is abandoned
+	// if the client goes away
+	response, err := s.processGameCommand(r.Context(), cmd.SessionID, cmd.Command, key)
 	if err != nil {
 		s.sendErrorResponse(w, err.Error(), http.StatusInternalServerError)
 		return
@@ -174,6 +338,15 @@ func (s *GameServer) handleGameStatus(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Report a mistyped session ID rather than a blank new session
+	if _, err := s.contextMgr.GetExistingContext(sessionID); err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, context.ErrSessionNotFound) {
+			status = http.StatusNotFound
+		}
+		s.sendErrorResponse(w, fmt.Sprintf("Failed to get context: %v", err), status)
+		return
+	}
 	summary, err := s.contextMgr.GetContextSummary(sessionID)
 	if err != nil {
 		s.sendErrorResponse(w, fmt.Sprintf("Failed to get context: %v", err), http.StatusNotFound)
@@ -215,6 +388,33 @@ func (s *GameServer) handleAIPrompt(w http.ResponseWriter, r *http.Request) {
 	s.sendJSONResponse(w, response)
 }
 
+func (s *GameServer) handleTranscript(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	sessionID := r.URL.Query().Get("session_id")
+	if sessionID == "" {
+		s.sendErrorResponse(w, "session_id parameter is required", http.StatusBadRequest)
+		return
+	}
+
+	transcript, err := s.contextMgr.ExportTranscript(sessionID)
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, context.ErrSessionNotFound) {
+			status = http.StatusNotFound
+		}
+		s.sendErrorResponse(w, fmt.Sprintf("Failed to export transcript: %v", err), status)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/markdown; charset=utf-8")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", "transcript-"+sessionID+".md"))
+	w.Write([]byte(transcript))
+}
+
 func (s *GameServer) handleMetrics(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
@@ -224,12 +424,14 @@ func (s *GameServer) handleMetrics(w http.ResponseWriter, r *http.Request) {
 	contextMetrics := s.contextMgr.GetContextMetrics()
 	aiMetrics := s.aiService.GetStats()
 	
+	uptime := time.Since(s.startTime)
 	metrics := map[string]interface{}{
 		"context": contextMetrics,
 		"ai":      aiMetrics,
 		"server": map[string]interface{}{
-			"uptime": time.Since(time.Now()).String(), // This would be calculated from start time
-			"ai_provider": s.aiService.GetProviderName(),
+			"uptime":         uptime.String(),
+			"uptime_seconds": uptime.Seconds(),
+			"ai_provider":    s.aiService.GetProviderName(),
 		},
 	}
 	
@@ -462,87 +664,165 @@ func (s *GameServer) handleIndex(w http.ResponseWriter, r *http.Request) {
 	w.Write([]byte(html))
 }
 
-func (s *GameServer) processGameCommand(sessionID, command string) (GameResponse, error) {
-	// Get current context
-	ctx, err := s.contextMgr.GetContext(sessionID)
+// gameTurn is a command whose game effects have been applied and which is
+// waiting for the GM's narration
+type gameTurn struct {
+	command      string
+	actionType   string
+	target       string
+	location     string
+	consequences []string
+	outcome      string // result the game already decided, for the GM to describe
+	prompt       string // full prompt for the GM
+}
+
+// fallbackResponse is the narration used when the AI can't be reached
+func (t gameTurn) fallbackResponse() string {
+	return fmt.Sprintf("You attempt to %s. The world responds to your action, though the details are unclear at this moment.", t.command)
+}
+
+// processGameCommand plays a command and records it. A non-empty
+// idempotencyKey must already be claimed; it is released if the command
+// fails before taking effect, so the client can retry, and kept once the
+// game has changed. Cancelling ctx abandons the AI call; the command has
+// already taken effect by then, so it is still recorded, with the fallback
+// narration, before the cancellation is reported.
+func (s *GameServer) processGameCommand(ctx stdcontext.Context, sessionID, command, idempotencyKey string) (GameResponse, error) {
+	turn, err := s.applyGameCommand(sessionID, command)
+	if err != nil {
+		s.contextMgr.ReleaseIdempotencyKey(sessionID, idempotencyKey)
+		return GameResponse{}, err
+	}
+
+	// The command has taken effect, so from here on the key stays claimed
+	// and a retry can't apply it again
+	turn, err = s.promptGameTurn(sessionID, turn)
+	if err != nil {
+		return GameResponse{}, err
+	}
+
+	// Get AI response
+	aiResponse, err := s.aiService.GenerateGMResponseForSessionCtx(ctx, sessionID, turn.prompt)
+	if ctx.Err() != nil {
+		// Nobody is left to narrate to, but the game has already changed
+		slog.Info("Request cancelled, recording the turn with the fallback narration", "session_id", sessionID)
+		if _, err := s.completeGameCommand(sessionID, turn, turn.fallbackResponse()); err != nil {
+			return GameResponse{}, err
+		}
+		return GameResponse{}, fmt.Errorf("request cancelled: %w", ctx.Err())
+	}
+	if err != nil {
+		slog.Error("AI service error", "session_id", sessionID, "error", err)
+		// Fallback to a generic response if AI fails
+		aiResponse = turn.fallbackResponse()
+	}
+
+	return s.completeGameCommand(sessionID, turn, aiResponse)
+}
+
+// prepareGameCommand applies a command's game effects and builds the GM prompt for it
+func (s *GameServer) prepareGameCommand(sessionID, command string) (gameTurn, error) {
+	turn, err := s.applyGameCommand(sessionID, command)
 	if err != nil {
-		return GameResponse{}, fmt.Errorf("session not found")
+		return gameTurn{}, err
 	}
+	return s.promptGameTurn(sessionID, turn)
+}
 
-	// Determine action type and basic processing
-	var actionType, target string
-	var consequences []string
+// applyGameCommand applies a command's game effects. An error means nothing
+// was applied.
+func (s *GameServer) applyGameCommand(sessionID, command string) (gameTurn, error) {
+	// Get current context; commands for an unknown session are refused
+	ctx, err := s.contextMgr.GetExistingContext(sessionID)
+	if err != nil {
+		return gameTurn{}, fmt.Errorf("session not found")
+	}
+
+	// Determine action type and basic processing; free text is interpreted by the AI
+	parsed, err := s.contextMgr.InterpretCommand(sessionID, command)
+	if err != nil {
+		return gameTurn{}, fmt.Errorf("failed to interpret command: %w", err)
+	}
+	consequences := parsed.Consequences
+	var combatResult string
 
 	switch {
-	case command == "/look around" || command == "/look":
-		actionType = "examine"
-		target = "environment"
-		consequences = []string{"exploration_success"}
-
-	case command == "/talk tavern_keeper":
-		actionType = "social"
-		target = "tavern_keeper"
-		consequences = []string{"social_success", "npc_noticed"}
-		
+	case parsed.ActionType == "social" && parsed.Target == "tavern_keeper":
 		// Update NPC relationship for social interactions
 		s.contextMgr.UpdateNPCRelationship(sessionID, "tavern_keeper", "Marcus the Tavern Keeper", 5, 
 			[]string{"friendly_conversation", "willing_to_help"})
 
-	case command == "/attack goblin":
-		actionType = "combat"
-		target = "goblin"
-		consequences = []string{"combat_success", "reputation_increase"}
-		
-		// Apply combat consequences
-		s.contextMgr.UpdateReputation(sessionID, 10)
-		s.contextMgr.UpdateCharacterHealth(sessionID, -2) // Small damage taken
-
-	case command == "/move forest" || command == "/go forest":
-		actionType = "move"
-		target = "forest"
-		consequences = []string{"location_change"}
-		
+	case parsed.ActionType == "combat" && parsed.Target != "":
+		// Roll the attack; a miss lets the target strike back
+		result, err := s.contextMgr.ResolveCombatAction(sessionID, parsed.Target)
+		if err != nil {
+			return gameTurn{}, fmt.Errorf("failed to resolve combat: %v", err)
+		}
+		combatResult = fmt.Sprintf("\n\nCombat Result (already decided, describe exactly this): The player %s", result.Describe())
+		if !result.Hit {
+			consequences = []string{"combat_miss"}
+		}
+
+	case parsed.ActionType == "move" && parsed.Target == "forest":
 		// Update location
 		s.contextMgr.UpdateLocation(sessionID, "thornwick_forest")
 
-	case command == "/examine chest" || command == "/search chest":
-		actionType = "examine"
-		target = "chest"
-		consequences = []string{"item_gained", "exploration_success"}
-
-	case command == "/inventory" || command == "/inv":
-		actionType = "examine"
-		target = "inventory"
-		consequences = []string{}
-
-	default:
-		actionType = "unknown"
-		target = "unknown"
-		consequences = []string{}
+	case parsed.ActionType == "respawn":
+		// Bring a dead player back before the GM describes their return
+		if err := s.contextMgr.Respawn(sessionID); err != nil {
+			return gameTurn{}, fmt.Errorf("failed to respawn: %v", err)
+		}
 	}
 
+	return gameTurn{
+		command:      command,
+		actionType:   parsed.ActionType,
+		target:       parsed.Target,
+		location:     ctx.Location.Current,
+		consequences: consequences,
+		outcome:      strings.TrimSpace(combatResult),
+	}, nil
+}
+
+// promptGameTurn builds the GM prompt for a turn whose effects are applied
+func (s *GameServer) promptGameTurn(sessionID string, turn gameTurn) (gameTurn, error) {
 	// Generate AI response using context
-	prompt, err := s.contextMgr.GenerateAIPrompt(sessionID)
+	prompt, err := s.contextMgr.GenerateAIPromptForCommand(sessionID, turn.command)
 	if err != nil {
-		return GameResponse{}, fmt.Errorf("failed to generate AI prompt: %v", err)
+		return gameTurn{}, fmt.Errorf("failed to generate AI prompt: %v", err)
+	}
+
+	var combatResult string
+	if turn.outcome != "" {
+		combatResult = "\n\n" + turn.outcome
 	}
 
 	// Add the player's current command to the prompt
-	fullPrompt := fmt.Sprintf("%s\n\nPlayer Action: %s\n\nAs the Game Master, respond to this player action with an engaging, contextual response that moves the story forward.", prompt, command)
+	turn.prompt = fmt.Sprintf("%s\n\nPlayer Action: %s%s\n\nAs the Game Master, respond to this player action with an engaging, contextual response that moves the story forward.", prompt, turn.command, combatResult)
+	return turn, nil
+}
 
-	// Get AI response
-	aiResponse, err := s.aiService.GenerateGMResponse(fullPrompt)
+// completeGameCommand records a turn with the GM's narration and reports the updated context
+func (s *GameServer) completeGameCommand(sessionID string, turn gameTurn, aiResponse string) (GameResponse, error) {
+	earlier, err := s.contextMgr.GetAchievements(sessionID)
 	if err != nil {
-		log.Printf("AI service error: %v", err)
-		// Fallback to a generic response if AI fails
-		aiResponse = fmt.Sprintf("You attempt to %s. The world responds to your action, though the details are unclear at this moment.", command)
+		return GameResponse{}, fmt.Errorf("failed to get achievements: %v", err)
 	}
 
 	// Record the action with AI-generated outcome
-	err = s.contextMgr.RecordAction(sessionID, command, actionType, target, ctx.Location.Current, aiResponse, consequences)
-	if err != nil {
+	if err := s.contextMgr.RecordAction(sessionID, turn.command, turn.actionType, turn.target, turn.location, aiResponse, turn.consequences); err != nil {
 		return GameResponse{}, fmt.Errorf("failed to record action: %v", err)
 	}
+	s.contextMgr.RememberNarration(sessionID, turn.command, turn.prompt)
+
+	// Let the action land so the response reflects it
+	if err := s.contextMgr.WaitForEvents(); err != nil {
+		return GameResponse{}, fmt.Errorf("failed to apply action: %v", err)
+	}
+	achievements, err := s.contextMgr.GetAchievements(sessionID)
+	if err != nil {
+		return GameResponse{}, fmt.Errorf("failed to get achievements: %v", err)
+	}
 
 	// Get updated context for response
 	summary, err := s.contextMgr.GetContextSummary(sessionID)
@@ -550,24 +830,37 @@ func (s *GameServer) processGameCommand(sessionID, command string) (GameResponse
 		return GameResponse{}, fmt.Errorf("failed to get updated context: %v", err)
 	}
 
+	responseContext := map[string]interface{}{
+		"location":    summary.CurrentLocation,
+		"health":      summary.PlayerHealth,
+		"reputation":  summary.PlayerReputation,
+		"mood":        summary.PlayerMood,
+		"session_time": fmt.Sprintf("%.1f minutes", summary.SessionDuration),
+		"ai_provider": s.aiService.GetProviderName(),
+	}
+	if len(achievements) > len(earlier) {
+		responseContext["achievements_unlocked"] = achievements[len(earlier):]
+	}
+
 	return GameResponse{
 		Success: true,
 		Message: aiResponse,
-		Context: map[string]interface{}{
-			"location":    summary.CurrentLocation,
-			"health":      summary.PlayerHealth,
-			"reputation":  summary.PlayerReputation,
-			"mood":        summary.PlayerMood,
-			"session_time": fmt.Sprintf("%.1f minutes", summary.SessionDuration),
-			"ai_provider": s.aiService.GetProviderName(),
-		},
+		Context: responseContext,
 	}, nil
 }
 
+// alreadyAppliedResponse answers a retry of an action that was already recorded
+func alreadyAppliedResponse() GameResponse {
+	return GameResponse{
+		Success: true,
+		Message: "This action was already applied",
+	}
+}
+
 func (s *GameServer) sendJSONResponse(w http.ResponseWriter, response GameResponse) {
 	w.Header().Set("Content-Type", "application/json")
 	if err := json.NewEncoder(w).Encode(response); err != nil {
-		log.Printf("Error encoding JSON response: %v", err)
+		slog.Error("Failed to encode JSON response", "error", err)
 	}
 }
 
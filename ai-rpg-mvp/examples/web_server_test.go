@@ -0,0 +1,392 @@
+package main
+
+import (
+	stdcontext "context"
+	"encoding/json"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"ai-rpg-mvp/ai"
+	"ai-rpg-mvp/ai/aitest"
+	"ai-rpg-mvp/config"
+	"ai-rpg-mvp/context"
+	"ai-rpg-mvp/metrics"
+)
+
+// newTestServer creates a GameServer backed by in-memory storage
+func newTestServer(t *testing.T) *GameServer {
+	t.Helper()
+
+	aiService, err := ai.NewAIService(ai.AIConfig{Provider: "mock"})
+	if err != nil {
+		t.Fatalf("Failed to create AI service: %v", err)
+	}
+
+	contextMgr := context.NewContextManager(context.NewMemoryStorage())
+	t.Cleanup(contextMgr.Shutdown)
+
+	return &GameServer{
+		contextMgr: contextMgr,
+		aiService:  aiService,
+		config:     config.LoadConfig(),
+		startTime:  time.Now(),
+	}
+}
+
+// getUptimeSeconds calls the metrics handler and returns the reported uptime
+func getUptimeSeconds(t *testing.T, server *GameServer) float64 {
+	t.Helper()
+
+	recorder := httptest.NewRecorder()
+	server.handleMetrics(recorder, httptest.NewRequest(http.MethodGet, "/api/metrics", nil))
+
+	var response struct {
+		Context struct {
+			Server struct {
+				Uptime        string  `json:"uptime"`
+				UptimeSeconds float64 `json:"uptime_seconds"`
+			} `json:"server"`
+		} `json:"context"`
+	}
+	if err := json.NewDecoder(recorder.Body).Decode(&response); err != nil {
+		t.Fatalf("Failed to decode metrics response: %v", err)
+	}
+
+	if response.Context.Server.Uptime == "" {
+		t.Error("Expected uptime string in metrics")
+	}
+
+	return response.Context.Server.UptimeSeconds
+}
+
+func TestHandleMetrics_Uptime(t *testing.T) {
+	server := newTestServer(t)
+
+	time.Sleep(10 * time.Millisecond)
+	first := getUptimeSeconds(t, server)
+	if first <= 0 {
+		t.Fatalf("Expected non-zero uptime, got %f", first)
+	}
+
+	time.Sleep(10 * time.Millisecond)
+	second := getUptimeSeconds(t, server)
+	if second <= first {
+		t.Errorf("Expected uptime to increase, got %f then %f", first, second)
+	}
+}
+
+func TestPrometheusMetrics_CountsAIRequests(t *testing.T) {
+	server := newTestServer(t)
+	useStubAI(t, server, "The tavern falls quiet.")
+
+	registry := metrics.NewRegistry()
+	registry.RegisterSessions(server.contextMgr)
+	server.aiService.SetMetrics(registry)
+
+	if _, err := server.aiService.GenerateGMResponse("look around"); err != nil {
+		t.Fatalf("GenerateGMResponse failed: %v", err)
+	}
+
+	recorder := httptest.NewRecorder()
+	registry.Handler().ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	body := recorder.Body.String()
+
+	for _, name := range []string{
+		"ai_rpg_ai_request_duration_seconds",
+		"ai_rpg_ai_cache_misses_total",
+		"ai_rpg_ai_rate_limited_total",
+		"ai_rpg_active_sessions",
+		"ai_rpg_event_queue_depth",
+	} {
+		if !strings.Contains(body, name) {
+			t.Errorf("Expected %s in scrape output", name)
+		}
+	}
+	if !strings.Contains(body, `ai_rpg_ai_requests_total{outcome="success",provider="ollama"} 1`) {
+		t.Errorf("Expected one successful ollama request, got:\n%s", body)
+	}
+}
+
+func TestHandleGameAction_RejectsInvalidAction(t *testing.T) {
+	server := newTestServer(t)
+
+	sessionID, err := server.contextMgr.CreateSession("player123", "TestPlayer")
+	if err != nil {
+		t.Fatalf("Failed to create session: %v", err)
+	}
+	server.contextMgr.UpdateCharacterHealth(sessionID, -20)
+
+	body := strings.NewReader(`{"session_id": "` + sessionID + `", "command": "/attack goblin"}`)
+	recorder := httptest.NewRecorder()
+	server.handleGameAction(recorder, httptest.NewRequest(http.MethodPost, "/api/game/action", body))
+
+	if recorder.Code != http.StatusUnprocessableEntity {
+		t.Errorf("Expected status %d, got %d", http.StatusUnprocessableEntity, recorder.Code)
+	}
+
+	actions, _ := server.contextMgr.GetRecentActions(sessionID, 10)
+	if len(actions) != 0 {
+		t.Errorf("Expected rejected action not to be recorded, got %d actions", len(actions))
+	}
+}
+
+func TestHandleGameAction_UnknownSession(t *testing.T) {
+	server := newTestServer(t)
+
+	body := strings.NewReader(`{"session_id": "typo", "command": "/look"}`)
+	recorder := httptest.NewRecorder()
+	server.handleGameAction(recorder, httptest.NewRequest(http.MethodPost, "/api/game/action", body))
+	if recorder.Code != http.StatusNotFound {
+		t.Errorf("Expected status %d, got %d: %s", http.StatusNotFound, recorder.Code, recorder.Body.String())
+	}
+
+	recorder = httptest.NewRecorder()
+	server.handleGameStatus(recorder, httptest.NewRequest(http.MethodGet, "/api/game/status?session_id=typo", nil))
+	if recorder.Code != http.StatusNotFound {
+		t.Errorf("Expected status %d, got %d: %s", http.StatusNotFound, recorder.Code, recorder.Body.String())
+	}
+
+	if server.contextMgr.IsSessionActive("typo") {
+		t.Error("Expected no session to be created for an unknown ID")
+	}
+}
+
+func TestHandleGameAction_IdempotencyKey(t *testing.T) {
+	server := newTestServer(t)
+	useStubAI(t, server, "You look around the square.")
+
+	sessionID, err := server.contextMgr.CreateSession("player123", "TestPlayer")
+	if err != nil {
+		t.Fatalf("Failed to create session: %v", err)
+	}
+
+	for i := 0; i < 2; i++ {
+		body := strings.NewReader(`{"session_id": "` + sessionID + `", "command": "/look around", "idempotency_key": "retry-1"}`)
+		recorder := httptest.NewRecorder()
+		server.handleGameAction(recorder, httptest.NewRequest(http.MethodPost, "/api/game/action", body))
+		if recorder.Code != http.StatusOK {
+			t.Fatalf("Expected status %d, got %d", http.StatusOK, recorder.Code)
+		}
+	}
+
+	server.contextMgr.WaitForEvents()
+	actions, _ := server.contextMgr.GetRecentActions(sessionID, 10)
+	if len(actions) != 1 {
+		t.Errorf("Expected the retried action to be recorded once, got %d actions", len(actions))
+	}
+}
+
+// postAction sends a command to the action handler with an idempotency key
+func postAction(server *GameServer, ctx stdcontext.Context, sessionID, command, key string) *httptest.ResponseRecorder {
+	body := strings.NewReader(`{"session_id": "` + sessionID + `", "command": "` + command + `", "idempotency_key": "` + key + `"}`)
+	recorder := httptest.NewRecorder()
+	server.handleGameAction(recorder, httptest.NewRequest(http.MethodPost, "/api/game/action", body).WithContext(ctx))
+	return recorder
+}
+
+// tavernKeeperDisposition returns how the tavern keeper feels about the player
+func tavernKeeperDisposition(t *testing.T, server *GameServer, sessionID string) int {
+	t.Helper()
+
+	server.contextMgr.WaitForEvents()
+	ctx, err := server.contextMgr.GetContext(sessionID)
+	if err != nil {
+		t.Fatalf("Failed to get context: %v", err)
+	}
+	return ctx.NPCStates["tavern_keeper"].Disposition
+}
+
+func TestHandleGameAction_ConcurrentRetry(t *testing.T) {
+	server := newTestServer(t)
+	started, release := useStubAI(t, server, "Marcus pours you an ale.").Hold(t)
+
+	sessionID, err := server.contextMgr.CreateSession("player123", "TestPlayer")
+	if err != nil {
+		t.Fatalf("Failed to create session: %v", err)
+	}
+
+	first := make(chan *httptest.ResponseRecorder)
+	go func() {
+		first <- postAction(server, stdcontext.Background(), sessionID, "/talk tavern_keeper", "retry-1")
+	}()
+	<-started
+
+	// The retry arrives while the first attempt waits on the GM
+	retry := postAction(server, stdcontext.Background(), sessionID, "/talk tavern_keeper", "retry-1")
+	if !strings.Contains(retry.Body.String(), "already applied") {
+		t.Errorf("Expected the retry to be answered as already applied, got %s", retry.Body.String())
+	}
+
+	release()
+	if recorder := <-first; recorder.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d", http.StatusOK, recorder.Code)
+	}
+
+	if disposition := tavernKeeperDisposition(t, server, sessionID); disposition != 5 {
+		t.Errorf("Expected the conversation to count once, got disposition %d", disposition)
+	}
+	actions, _ := server.contextMgr.GetRecentActions(sessionID, 10)
+	if len(actions) != 1 {
+		t.Errorf("Expected the action to be recorded once, got %d actions", len(actions))
+	}
+}
+
+func TestHandleGameAction_RetryAfterCancellation(t *testing.T) {
+	server := newTestServer(t)
+	useStubAI(t, server, "Marcus pours you an ale.")
+
+	sessionID, err := server.contextMgr.CreateSession("player123", "TestPlayer")
+	if err != nil {
+		t.Fatalf("Failed to create session: %v", err)
+	}
+
+	ctx, cancel := stdcontext.WithCancel(stdcontext.Background())
+	cancel()
+	if recorder := postAction(server, ctx, sessionID, "/talk tavern_keeper", "retry-1"); recorder.Code == http.StatusOK {
+		t.Fatalf("Expected the cancelled request to fail, got %s", recorder.Body.String())
+	}
+
+	retry := postAction(server, stdcontext.Background(), sessionID, "/talk tavern_keeper", "retry-1")
+	if retry.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d", http.StatusOK, retry.Code)
+	}
+	if disposition := tavernKeeperDisposition(t, server, sessionID); disposition != 5 {
+		t.Errorf("Expected the retry not to repeat the conversation, got disposition %d", disposition)
+	}
+
+	// The cancelled attempt changed the game, so its turn is on record
+	actions, _ := server.contextMgr.GetRecentActions(sessionID, 10)
+	if len(actions) != 1 {
+		t.Errorf("Expected the cancelled turn to be recorded, got %d actions", len(actions))
+	}
+}
+
+func TestHandleDeleteSession(t *testing.T) {
+	server := newTestServer(t)
+
+	sessionID, err := server.contextMgr.CreateSession("player123", "TestPlayer")
+	if err != nil {
+		t.Fatalf("Failed to create session: %v", err)
+	}
+
+	recorder := httptest.NewRecorder()
+	server.handleDeleteSession(recorder, httptest.NewRequest(http.MethodDelete, "/api/session/"+sessionID, nil))
+	if recorder.Code != http.StatusOK {
+		t.Errorf("Expected status %d, got %d", http.StatusOK, recorder.Code)
+	}
+	if server.contextMgr.IsSessionActive(sessionID) {
+		t.Error("Expected session to be inactive after delete")
+	}
+
+	// Deleting it again reports that it no longer exists
+	recorder = httptest.NewRecorder()
+	server.handleDeleteSession(recorder, httptest.NewRequest(http.MethodDelete, "/api/session/"+sessionID, nil))
+	if recorder.Code != http.StatusNotFound {
+		t.Errorf("Expected status %d, got %d", http.StatusNotFound, recorder.Code)
+	}
+
+	recorder = httptest.NewRecorder()
+	server.handleDeleteSession(recorder, httptest.NewRequest(http.MethodGet, "/api/session/"+sessionID, nil))
+	if recorder.Code != http.StatusMethodNotAllowed {
+		t.Errorf("Expected status %d, got %d", http.StatusMethodNotAllowed, recorder.Code)
+	}
+}
+
+// useStubAI points the server's AI service at a stub answering with each
+// reply in turn
+func useStubAI(t *testing.T, server *GameServer, replies ...string) *aitest.Stub {
+	t.Helper()
+
+	aiService, stub := aitest.NewService(t, replies...)
+	server.aiService = aiService
+	return stub
+}
+
+func TestHandleGameAction_ReportsAchievements(t *testing.T) {
+	server := newTestServer(t)
+	useStubAI(t, server, "You take in the village square.")
+
+	server.contextMgr.RegisterAchievement(context.AchievementDef{
+		ID:   "first_steps",
+		Name: "First Steps",
+		Unlocked: func(ctx *context.PlayerContext) bool {
+			return ctx.SessionStats.TotalActions >= 1
+		},
+	})
+
+	sessionID, err := server.contextMgr.CreateSession("player123", "TestPlayer")
+	if err != nil {
+		t.Fatalf("Failed to create session: %v", err)
+	}
+
+	play := func() map[string]interface{} {
+		body := strings.NewReader(`{"session_id": "` + sessionID + `", "command": "/look around"}`)
+		recorder := httptest.NewRecorder()
+		server.handleGameAction(recorder, httptest.NewRequest(http.MethodPost, "/api/game/action", body))
+
+		var response GameResponse
+		if err := json.Unmarshal(recorder.Body.Bytes(), &response); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+		responseContext, _ := response.Context.(map[string]interface{})
+		return responseContext
+	}
+
+	unlocked, _ := play()["achievements_unlocked"].([]interface{})
+	if len(unlocked) != 1 || unlocked[0].(map[string]interface{})["id"] != "first_steps" {
+		t.Fatalf("Expected first_steps to be reported, got %v", unlocked)
+	}
+
+	if _, ok := play()["achievements_unlocked"]; ok {
+		t.Error("Expected the achievement to be reported only once")
+	}
+}
+
+func TestHandleTranscript(t *testing.T) {
+	server := newTestServer(t)
+
+	sessionID, err := server.contextMgr.CreateSession("player123", "TestPlayer")
+	if err != nil {
+		t.Fatalf("Failed to create session: %v", err)
+	}
+
+	recorder := httptest.NewRecorder()
+	server.handleTranscript(recorder, httptest.NewRequest(http.MethodGet, "/api/session/transcript?session_id="+sessionID, nil))
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d", http.StatusOK, recorder.Code)
+	}
+	if contentType := recorder.Header().Get("Content-Type"); !strings.HasPrefix(contentType, "text/markdown") {
+		t.Errorf("Expected a Markdown content type, got %q", contentType)
+	}
+	if !strings.Contains(recorder.Body.String(), "# TestPlayer's Adventure") {
+		t.Errorf("Expected the transcript heading, got:\n%s", recorder.Body.String())
+	}
+
+	recorder = httptest.NewRecorder()
+	server.handleTranscript(recorder, httptest.NewRequest(http.MethodGet, "/api/session/transcript", nil))
+	if recorder.Code != http.StatusBadRequest {
+		t.Errorf("Expected status %d, got %d", http.StatusBadRequest, recorder.Code)
+	}
+}
+
+func TestProcessGameCommand_AttackReputationIsDeterministic(t *testing.T) {
+	server := newTestServer(t)
+	useStubAI(t, server, "The goblin falls.")
+	server.contextMgr.SetDiceSource(rand.NewSource(103)) // natural 20
+
+	sessionID, _ := server.contextMgr.CreateSession("player123", "TestPlayer")
+	before, _ := server.contextMgr.GetContext(sessionID)
+
+	if _, err := server.processGameCommand(stdcontext.Background(), sessionID, "/attack goblin", ""); err != nil {
+		t.Fatalf("Failed to process attack: %v", err)
+	}
+
+	after, _ := server.contextMgr.GetContext(sessionID)
+	if change := after.Character.Reputation - before.Character.Reputation; change != 5 {
+		t.Errorf("Expected only the reputation_increase consequence (+5), got %+d", change)
+	}
+}
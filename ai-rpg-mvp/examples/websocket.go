@@ -0,0 +1,90 @@
+package main
+
+import (
+	stdcontext "context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"reflect"
+
+	"github.com/gorilla/websocket"
+)
+
+// wsCommand is a frame sent by the client over the game socket
+type wsCommand struct {
+	Command string `json:"command"`
+}
+
+// wsFrame is a frame pushed to the client over the game socket
+type wsFrame struct {
+	Type    string                 `json:"type"` // "response" or "error"
+	Message string                 `json:"message,omitempty"`
+	Context map[string]interface{} `json:"context,omitempty"` // only the values that changed since the last frame
+	Error   string                 `json:"error,omitempty"`
+}
+
+// handleWebSocket runs a live game session over a WebSocket. The socket is
+// bound to the session_id it was opened with: each command frame is played
+// in that session and answered with the GM response and what changed in the
+// context summary.
+func (s *GameServer) handleWebSocket(w http.ResponseWriter, r *http.Request) {
+	sessionID := r.URL.Query().Get("session_id")
+	if sessionID == "" {
+		s.sendErrorResponse(w, "session_id parameter is required", http.StatusBadRequest)
+		return
+	}
+
+	upgrader := websocket.Upgrader{CheckOrigin: s.allowedOrigin}
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		// Upgrade has already written an HTTP error to the client
+		slog.Warn("WebSocket upgrade failed", "error", err)
+		return
+	}
+	defer conn.Close()
+
+	previous := map[string]interface{}{}
+	for {
+		var cmd wsCommand
+		if err := conn.ReadJSON(&cmd); err != nil {
+			if !websocket.IsCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway) {
+				slog.Warn("WebSocket read error", "session_id", sessionID, "error", err)
+			}
+			return
+		}
+
+		frame := s.playWebSocketCommand(r.Context(), sessionID, cmd.Command, previous)
+		if err := conn.WriteJSON(frame); err != nil {
+			slog.Warn("WebSocket write error", "session_id", sessionID, "error", err)
+			return
+		}
+	}
+}
+
+// playWebSocketCommand runs one command and builds the frame answering it,
+// updating previous to the latest context summary
+func (s *GameServer) playWebSocketCommand(ctx stdcontext.Context, sessionID, command string, previous map[string]interface{}) wsFrame {
+	if command == "" {
+		return wsFrame{Type: "error", Error: "command is required"}
+	}
+
+	if err := s.contextMgr.ValidateAction(sessionID, command); err != nil {
+		return wsFrame{Type: "error", Error: fmt.Sprintf("You can't do that: %s", err)}
+	}
+
+	response, err := s.processGameCommand(ctx, sessionID, command, "")
+	if err != nil {
+		return wsFrame{Type: "error", Error: err.Error()}
+	}
+
+	current, _ := response.Context.(map[string]interface{})
+	delta := make(map[string]interface{})
+	for key, value := range current {
+		if old, ok := previous[key]; !ok || !reflect.DeepEqual(old, value) {
+			delta[key] = value
+		}
+		previous[key] = value
+	}
+
+	return wsFrame{Type: "response", Message: response.Message, Context: delta}
+}
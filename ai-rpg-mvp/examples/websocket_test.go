@@ -0,0 +1,74 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestHandleWebSocket(t *testing.T) {
+	server := newTestServer(t)
+	useStubAI(t, server, "You see a quiet village square.")
+
+	sessionID, err := server.contextMgr.CreateSession("player123", "TestPlayer")
+	if err != nil {
+		t.Fatalf("Failed to create session: %v", err)
+	}
+
+	httpServer := httptest.NewServer(http.HandlerFunc(server.handleWebSocket))
+	defer httpServer.Close()
+
+	url := "ws" + strings.TrimPrefix(httpServer.URL, "http") + "?session_id=" + sessionID
+	conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	defer conn.Close()
+
+	if err := conn.WriteJSON(wsCommand{Command: "/look around"}); err != nil {
+		t.Fatalf("Failed to send command: %v", err)
+	}
+
+	var frame wsFrame
+	if err := conn.ReadJSON(&frame); err != nil {
+		t.Fatalf("Failed to read response frame: %v", err)
+	}
+	if frame.Type != "response" || frame.Message != "You see a quiet village square." {
+		t.Errorf("Expected GM response frame, got %+v", frame)
+	}
+	if frame.Context["location"] != "starting_village" {
+		t.Errorf("Expected first frame to carry the full context, got %v", frame.Context)
+	}
+
+	// The next frame only carries what changed
+	conn.WriteJSON(wsCommand{Command: "/look around"})
+	frame = wsFrame{}
+	if err := conn.ReadJSON(&frame); err != nil {
+		t.Fatalf("Failed to read second frame: %v", err)
+	}
+	if _, ok := frame.Context["location"]; ok {
+		t.Errorf("Expected unchanged location to be left out of the delta, got %v", frame.Context)
+	}
+
+	// Invalid commands are answered with an error frame and keep the socket open
+	conn.WriteJSON(wsCommand{})
+	frame = wsFrame{}
+	if err := conn.ReadJSON(&frame); err != nil || frame.Type != "error" {
+		t.Errorf("Expected error frame, got %+v (%v)", frame, err)
+	}
+
+	conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
+}
+
+func TestHandleWebSocket_RequiresSession(t *testing.T) {
+	server := newTestServer(t)
+
+	recorder := httptest.NewRecorder()
+	server.handleWebSocket(recorder, httptest.NewRequest(http.MethodGet, "/ws", nil))
+	if recorder.Code != http.StatusBadRequest {
+		t.Errorf("Expected status %d, got %d", http.StatusBadRequest, recorder.Code)
+	}
+}
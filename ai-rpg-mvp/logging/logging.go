@@ -0,0 +1,80 @@
+package logging
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+
+	"ai-rpg-mvp/config"
+)
+
+// New builds a logger from cfg. Output is "stdout", "stderr" or a file path,
+// which is appended to; the returned closer closes that file and is a no-op
+// otherwise. Rotation settings are not applied here, so rotate log files with
+// an external tool such as logrotate.
+func New(cfg config.LoggingConfig) (*slog.Logger, io.Closer, error) {
+	var output io.WriteCloser
+	switch strings.ToLower(cfg.Output) {
+	case "", "stdout":
+		output = nopCloser{os.Stdout}
+	case "stderr":
+		output = nopCloser{os.Stderr}
+	default:
+		file, err := os.OpenFile(cfg.Output, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to open log file: %w", err)
+		}
+		output = file
+	}
+
+	logger, err := NewWithWriter(cfg, output)
+	if err != nil {
+		output.Close()
+		return nil, nil, err
+	}
+	return logger, output, nil
+}
+
+// NewWithWriter builds a logger that writes to w in cfg's format, dropping
+// records below cfg's level. cfg.Output is ignored.
+func NewWithWriter(cfg config.LoggingConfig, w io.Writer) (*slog.Logger, error) {
+	level, err := ParseLevel(cfg.Level)
+	if err != nil {
+		return nil, err
+	}
+	options := &slog.HandlerOptions{Level: level}
+
+	switch strings.ToLower(cfg.Format) {
+	case "", "json":
+		return slog.New(slog.NewJSONHandler(w, options)), nil
+	case "text":
+		return slog.New(slog.NewTextHandler(w, options)), nil
+	default:
+		return nil, fmt.Errorf("unsupported log format %q (supported: json, text)", cfg.Format)
+	}
+}
+
+// ParseLevel converts a configured level name to a slog level; empty means info
+func ParseLevel(level string) (slog.Level, error) {
+	switch strings.ToLower(level) {
+	case "debug":
+		return slog.LevelDebug, nil
+	case "", "info":
+		return slog.LevelInfo, nil
+	case "warn", "warning":
+		return slog.LevelWarn, nil
+	case "error":
+		return slog.LevelError, nil
+	default:
+		return slog.LevelInfo, fmt.Errorf("unsupported log level %q (supported: debug, info, warn, error)", level)
+	}
+}
+
+// nopCloser keeps the standard streams open when the logger is closed
+type nopCloser struct {
+	io.Writer
+}
+
+func (nopCloser) Close() error { return nil }
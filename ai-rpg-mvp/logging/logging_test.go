@@ -0,0 +1,80 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"ai-rpg-mvp/config"
+)
+
+func TestNewWithWriter_FiltersByLevel(t *testing.T) {
+	var buf bytes.Buffer
+	logger, err := NewWithWriter(config.LoggingConfig{Level: "info", Format: "json"}, &buf)
+	if err != nil {
+		t.Fatalf("NewWithWriter failed: %v", err)
+	}
+
+	logger.Debug("noisy detail")
+	logger.Info("session created", "session_id", "abc")
+
+	output := buf.String()
+	if strings.Contains(output, "noisy detail") {
+		t.Errorf("Expected debug log to be suppressed at info level, got %q", output)
+	}
+
+	var record map[string]interface{}
+	if err := json.Unmarshal([]byte(output), &record); err != nil {
+		t.Fatalf("Expected one JSON record, got %q: %v", output, err)
+	}
+	if record["msg"] != "session created" || record["session_id"] != "abc" {
+		t.Errorf("Unexpected record: %v", record)
+	}
+}
+
+func TestNewWithWriter_TextFormat(t *testing.T) {
+	var buf bytes.Buffer
+	logger, err := NewWithWriter(config.LoggingConfig{Level: "debug", Format: "text"}, &buf)
+	if err != nil {
+		t.Fatalf("NewWithWriter failed: %v", err)
+	}
+
+	logger.Debug("rolled dice", "result", 17)
+	if !strings.Contains(buf.String(), "level=DEBUG") || !strings.Contains(buf.String(), "result=17") {
+		t.Errorf("Expected a text debug record, got %q", buf.String())
+	}
+}
+
+func TestNew_FileOutput(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "server.log")
+	logger, closer, err := New(config.LoggingConfig{Level: "warn", Format: "json", Output: path})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+
+	logger.Info("not written")
+	logger.Warn("written")
+	if err := closer.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read log file: %v", err)
+	}
+	if strings.Contains(string(data), "not written") || !strings.Contains(string(data), "written") {
+		t.Errorf("Expected only the warning in the log file, got %q", data)
+	}
+}
+
+func TestNewWithWriter_RejectsUnknownSettings(t *testing.T) {
+	if _, err := NewWithWriter(config.LoggingConfig{Level: "verbose"}, &bytes.Buffer{}); err == nil {
+		t.Error("Expected error for an unknown level")
+	}
+	if _, err := NewWithWriter(config.LoggingConfig{Format: "xml"}, &bytes.Buffer{}); err == nil {
+		t.Error("Expected error for an unknown format")
+	}
+}
@@ -0,0 +1,133 @@
+package metrics
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Namespace prefixes every exported metric name
+const Namespace = "ai_rpg"
+
+// SessionSource reports context manager state for gauges sampled on scrape.
+// context.ContextManager satisfies it.
+type SessionSource interface {
+	GetActiveSessions() []string
+	EventQueueDepth() int
+	DroppedEvents() int64
+}
+
+// Registry holds the Prometheus metrics for a server. It satisfies ai.Metrics,
+// so an AIService can report to it directly.
+type Registry struct {
+	registry    *prometheus.Registry
+	aiRequests  *prometheus.CounterVec
+	aiLatency   *prometheus.HistogramVec
+	cacheHits   prometheus.Counter
+	cacheMisses prometheus.Counter
+	rateLimited prometheus.Counter
+}
+
+// NewRegistry creates a registry with the AI metrics and the standard Go and
+// process collectors registered
+func NewRegistry() *Registry {
+	r := &Registry{
+		registry: prometheus.NewRegistry(),
+		aiRequests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: Namespace,
+			Name:      "ai_requests_total",
+			Help:      "AI provider calls by provider and outcome.",
+		}, []string{"provider", "outcome"}),
+		aiLatency: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: Namespace,
+			Name:      "ai_request_duration_seconds",
+			Help:      "Latency of AI provider calls.",
+			Buckets:   []float64{0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60},
+		}, []string{"provider"}),
+		cacheHits: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: Namespace,
+			Name:      "ai_cache_hits_total",
+			Help:      "AI responses served from the response cache.",
+		}),
+		cacheMisses: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: Namespace,
+			Name:      "ai_cache_misses_total",
+			Help:      "AI requests that missed the response cache.",
+		}),
+		rateLimited: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: Namespace,
+			Name:      "ai_rate_limited_total",
+			Help:      "AI requests rejected by a rate limit.",
+		}),
+	}
+
+	r.registry.MustRegister(
+		r.aiRequests,
+		r.aiLatency,
+		r.cacheHits,
+		r.cacheMisses,
+		r.rateLimited,
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+	return r
+}
+
+// RegisterSessions exports active session count, event queue depth and
+// dropped events from source, sampled on each scrape
+func (r *Registry) RegisterSessions(source SessionSource) {
+	r.registry.MustRegister(
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Namespace: Namespace,
+			Name:      "active_sessions",
+			Help:      "Sessions currently held in the context cache.",
+		}, func() float64 { return float64(len(source.GetActiveSessions())) }),
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Namespace: Namespace,
+			Name:      "event_queue_depth",
+			Help:      "Context events waiting to be processed.",
+		}, func() float64 { return float64(source.EventQueueDepth()) }),
+		prometheus.NewCounterFunc(prometheus.CounterOpts{
+			Namespace: Namespace,
+			Name:      "dropped_events_total",
+			Help:      "Context events discarded because the queue was full.",
+		}, func() float64 { return float64(source.DroppedEvents()) }),
+	)
+}
+
+// Gatherer exposes the underlying registry, e.g. for tests
+func (r *Registry) Gatherer() prometheus.Gatherer {
+	return r.registry
+}
+
+// Handler serves the metrics in the Prometheus text format
+func (r *Registry) Handler() http.Handler {
+	return promhttp.HandlerFor(r.registry, promhttp.HandlerOpts{})
+}
+
+// AIRequest records one AI provider call
+func (r *Registry) AIRequest(provider string, duration time.Duration, err error) {
+	outcome := "success"
+	if err != nil {
+		outcome = "error"
+	}
+	r.aiRequests.WithLabelValues(provider, outcome).Inc()
+	r.aiLatency.WithLabelValues(provider).Observe(duration.Seconds())
+}
+
+// AICacheLookup records a response cache hit or miss
+func (r *Registry) AICacheLookup(hit bool) {
+	if hit {
+		r.cacheHits.Inc()
+	} else {
+		r.cacheMisses.Inc()
+	}
+}
+
+// AIRateLimited records a request rejected by a rate limit
+func (r *Registry) AIRateLimited() {
+	r.rateLimited.Inc()
+}
@@ -0,0 +1,71 @@
+package metrics
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+type fakeSessions struct {
+	sessions []string
+	depth    int
+	dropped  int64
+}
+
+func (f fakeSessions) GetActiveSessions() []string { return f.sessions }
+func (f fakeSessions) EventQueueDepth() int        { return f.depth }
+func (f fakeSessions) DroppedEvents() int64        { return f.dropped }
+
+func TestRegistry_ExportsMetrics(t *testing.T) {
+	registry := NewRegistry()
+	registry.RegisterSessions(fakeSessions{sessions: []string{"a", "b"}, depth: 3, dropped: 1})
+
+	registry.AIRequest("ollama", 200*time.Millisecond, nil)
+	registry.AIRequest("ollama", time.Second, errors.New("timeout"))
+	registry.AICacheLookup(true)
+	registry.AICacheLookup(false)
+	registry.AICacheLookup(false)
+	registry.AIRateLimited()
+
+	families, err := registry.Gatherer().Gather()
+	if err != nil {
+		t.Fatalf("Failed to gather metrics: %v", err)
+	}
+	names := make(map[string]bool)
+	for _, family := range families {
+		names[family.GetName()] = true
+	}
+	for _, name := range []string{
+		"ai_rpg_ai_requests_total",
+		"ai_rpg_ai_request_duration_seconds",
+		"ai_rpg_ai_cache_hits_total",
+		"ai_rpg_ai_cache_misses_total",
+		"ai_rpg_ai_rate_limited_total",
+		"ai_rpg_active_sessions",
+		"ai_rpg_event_queue_depth",
+		"ai_rpg_dropped_events_total",
+	} {
+		if !names[name] {
+			t.Errorf("Expected metric %s to be exported", name)
+		}
+	}
+
+	checks := []struct {
+		name string
+		got  float64
+		want float64
+	}{
+		{"successful requests", testutil.ToFloat64(registry.aiRequests.WithLabelValues("ollama", "success")), 1},
+		{"failed requests", testutil.ToFloat64(registry.aiRequests.WithLabelValues("ollama", "error")), 1},
+		{"cache hits", testutil.ToFloat64(registry.cacheHits), 1},
+		{"cache misses", testutil.ToFloat64(registry.cacheMisses), 2},
+		{"rate limited", testutil.ToFloat64(registry.rateLimited), 1},
+	}
+	for _, check := range checks {
+		if check.got != check.want {
+			t.Errorf("Expected %s = %v, got %v", check.name, check.want, check.got)
+		}
+	}
+}
@@ -0,0 +1,25 @@
+package main
+
+import (
+	"fmt"
+)
+
+// Skill challenge tools
+
+func (s *AIRPGMCPServer) toolAttributeCheck(args map[string]interface{}) (*MCPToolResult, error) {
+	sessionID := args["sessionID"].(string)
+	attribute := args["attribute"].(string)
+	difficulty := intArgument(args, "difficulty", 0)
+
+	success, roll, total, err := s.contextMgr.AttributeCheck(sessionID, attribute, difficulty)
+	if err != nil {
+		return nil, fmt.Errorf("failed to make attribute check: %w", err)
+	}
+
+	result := "FAILURE"
+	if success {
+		result = "SUCCESS"
+	}
+	return textResult(fmt.Sprintf("%s check against difficulty %d: rolled %d, total %d. %s\n\nNarrate this result as decided; don't reroll or change it.",
+		attribute, difficulty, roll, total, result)), nil
+}
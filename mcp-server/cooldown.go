@@ -0,0 +1,77 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sync"
+	"time"
+)
+
+// throttledError reports a tool called again for a session before its
+// cooldown ran out; it is answered with JSON-RPC code -32000
+type throttledError struct {
+	tool       string
+	retryAfter time.Duration
+}
+
+func (e *throttledError) Error() string {
+	return fmt.Sprintf("%s is cooling down for this session; try again in %ds", e.tool, int(math.Ceil(e.retryAfter.Seconds())))
+}
+
+// toolCooldowns enforces a least time between calls to the same tool for the
+// same session, independently of the AI service's rate limiter
+type toolCooldowns struct {
+	durations map[string]time.Duration  // tool -> cooldown
+	lastCall  map[cooldownKey]time.Time // last allowed call of each tool per session
+	now       func() time.Time
+	mutex     sync.Mutex
+}
+
+type cooldownKey struct {
+	tool      string
+	sessionID string
+}
+
+// newToolCooldowns creates cooldowns for the given tools; tools without a
+// positive duration are never throttled
+func newToolCooldowns(durations map[string]time.Duration) *toolCooldowns {
+	return &toolCooldowns{
+		durations: durations,
+		lastCall:  make(map[cooldownKey]time.Time),
+		now:       time.Now,
+	}
+}
+
+// allow records a call to tool for sessionID, or returns a throttledError if
+// the previous call was too recent
+func (c *toolCooldowns) allow(tool, sessionID string) error {
+	cooldown := c.durations[tool]
+	if cooldown <= 0 || sessionID == "" {
+		return nil
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	now := c.now()
+	c.forgetExpired(now)
+
+	key := cooldownKey{tool: tool, sessionID: sessionID}
+	if last, ok := c.lastCall[key]; ok {
+		if wait := cooldown - now.Sub(last); wait > 0 {
+			return &throttledError{tool: tool, retryAfter: wait}
+		}
+	}
+	c.lastCall[key] = now
+	return nil
+}
+
+// forgetExpired drops calls whose cooldown has passed, so sessions that
+// stop calling don't pile up. The caller must hold the mutex.
+func (c *toolCooldowns) forgetExpired(now time.Time) {
+	for key, last := range c.lastCall {
+		if now.Sub(last) >= c.durations[key.tool] {
+			delete(c.lastCall, key)
+		}
+	}
+}
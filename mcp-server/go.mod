@@ -8,10 +8,14 @@ require ai-rpg-mvp v0.0.0-00010101000000-000000000000
 
 require (
 	github.com/anthropics/anthropic-sdk-go v1.2.0 // indirect
+	github.com/cespare/xxhash/v2 v2.2.0 // indirect
+	github.com/dgryski/go-rendezvous v0.0.0-20200823014737-9f7001d12a5f // indirect
 	github.com/google/uuid v1.4.0 // indirect
 	github.com/lib/pq v1.10.9 // indirect
+	github.com/redis/go-redis/v9 v9.7.0 // indirect
 	github.com/tidwall/gjson v1.18.0 // indirect
 	github.com/tidwall/match v1.1.1 // indirect
 	github.com/tidwall/pretty v1.2.1 // indirect
 	github.com/tidwall/sjson v1.2.5 // indirect
+	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"ai-rpg-mvp/context"
+)
+
+// Inventory and quest tools
+
+func (s *AIRPGMCPServer) toolGetInventory(args map[string]interface{}) (*MCPToolResult, error) {
+	sessionID := args["sessionID"].(string)
+
+	inventory, err := s.contextMgr.GetInventory(sessionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get inventory: %w", err)
+	}
+	gold, err := s.contextMgr.GetGold(sessionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get gold: %w", err)
+	}
+
+	return textResult(fmt.Sprintf("Inventory for %s:\nGold: %d\n\nItems:\n%s",
+		sessionID, gold, formatInventory(inventory))), nil
+}
+
+func (s *AIRPGMCPServer) toolAddItem(args map[string]interface{}) (*MCPToolResult, error) {
+	sessionID := args["sessionID"].(string)
+	itemID := args["itemID"].(string)
+
+	item := context.InventoryItem{
+		ID:       itemID,
+		Name:     itemID,
+		Quantity: intArgument(args, "quantity", 1),
+		Value:    intArgument(args, "value", 0),
+	}
+	if name, ok := args["name"].(string); ok && name != "" {
+		item.Name = name
+	}
+	item.Type, _ = args["type"].(string)
+
+	if err := s.contextMgr.AddInventoryItem(sessionID, item); err != nil {
+		return nil, fmt.Errorf("failed to add item: %w", err)
+	}
+
+	return textResult(fmt.Sprintf("Added %d x %s (ID: %s) to the inventory", item.Quantity, item.Name, item.ID)), nil
+}
+
+func (s *AIRPGMCPServer) toolRemoveItem(args map[string]interface{}) (*MCPToolResult, error) {
+	sessionID := args["sessionID"].(string)
+	itemID := args["itemID"].(string)
+	quantity := intArgument(args, "quantity", 1)
+
+	if err := s.contextMgr.RemoveInventoryItem(sessionID, itemID, quantity); err != nil {
+		return nil, fmt.Errorf("failed to remove item: %w", err)
+	}
+
+	return textResult(fmt.Sprintf("Removed %d x %s from the inventory", quantity, itemID)), nil
+}
+
+func (s *AIRPGMCPServer) toolGetQuests(args map[string]interface{}) (*MCPToolResult, error) {
+	sessionID := args["sessionID"].(string)
+
+	quests, err := s.contextMgr.GetActiveQuests(sessionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get quests: %w", err)
+	}
+
+	return textResult(fmt.Sprintf("Active quests for %s:\n%s", sessionID, formatQuests(quests))), nil
+}
+
+func (s *AIRPGMCPServer) toolStartQuest(args map[string]interface{}) (*MCPToolResult, error) {
+	sessionID := args["sessionID"].(string)
+
+	quest := context.Quest{
+		ID:    args["questID"].(string),
+		Title: args["title"].(string),
+	}
+	quest.Description, _ = args["description"].(string)
+	if objectives, ok := args["objectives"].([]interface{}); ok {
+		for _, objective := range objectives {
+			if description, ok := objective.(string); ok {
+				quest.Objectives = append(quest.Objectives, context.Objective{Description: description})
+			}
+		}
+	}
+
+	if err := s.contextMgr.StartQuest(sessionID, quest); err != nil {
+		return nil, fmt.Errorf("failed to start quest: %w", err)
+	}
+
+	return textResult(fmt.Sprintf("Started quest %s (ID: %s) with %d objectives", quest.Title, quest.ID, len(quest.Objectives))), nil
+}
+
+// formatInventory lists items one per line
+func formatInventory(items []context.InventoryItem) string {
+	if len(items) == 0 {
+		return "- (empty)"
+	}
+
+	var lines []string
+	for _, item := range items {
+		line := fmt.Sprintf("- %s x%d (ID: %s)", item.Name, item.Quantity, item.ID)
+		if item.Type != "" {
+			line += fmt.Sprintf(" [%s]", item.Type)
+		}
+		if item.Value > 0 {
+			line += fmt.Sprintf(", worth %d gold", item.Value)
+		}
+		lines = append(lines, line)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// formatQuests lists quests with their objectives, ticking completed ones
+func formatQuests(quests []context.Quest) string {
+	if len(quests) == 0 {
+		return "- No active quests"
+	}
+
+	var lines []string
+	for _, quest := range quests {
+		lines = append(lines, fmt.Sprintf("- %s (ID: %s)", quest.Title, quest.ID))
+		if quest.Description != "" {
+			lines = append(lines, "  "+quest.Description)
+		}
+		for _, objective := range quest.Objectives {
+			mark := " "
+			if objective.Done {
+				mark = "x"
+			}
+			lines = append(lines, fmt.Sprintf("  [%s] %s", mark, objective.Description))
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// intArgument reads an optional integer argument, which arrives as a JSON
+// number; fallback is used when it is absent
+func intArgument(args map[string]interface{}, name string, fallback int) int {
+	switch value := args[name].(type) {
+	case float64:
+		return int(value)
+	case int:
+		return value
+	default:
+		return fallback
+	}
+}
+
+// textResult wraps text as a tool result
+func textResult(text string) *MCPToolResult {
+	return &MCPToolResult{
+		Content: []MCPContent{
+			{
+				Type: "text",
+				Text: text,
+			},
+		},
+	}
+}
@@ -2,15 +2,23 @@ package main
 
 import (
 	"bufio"
+	stdcontext "context"
+	"bytes"
 	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
+	"io"
 	"log"
+	"log/slog"
 	"os"
 	"strings"
+	"time"
 
 	"ai-rpg-mvp/ai"
 	"ai-rpg-mvp/config"
 	"ai-rpg-mvp/context"
+	"ai-rpg-mvp/logging"
 )
 
 // MCP Protocol Messages (JSON-RPC 2.0 compliant)
@@ -59,53 +67,153 @@ type AIRPGMCPServer struct {
 	contextMgr *context.ContextManager
 	aiService  *ai.AIService
 	config     *config.Config
+	out        io.Writer      // where responses are written; stdout when nil
+	logger     *slog.Logger   // slog.Default() when nil
+	cooldowns  *toolCooldowns // per-session throttling of AI tools; none when nil
+
+	// ctx is the parent of every tool call's context, so cancelling it
+	// abandons AI calls in flight; Background when nil
+	ctx stdcontext.Context
 }
 
 func main() {
+	logFile := flag.String("log-file", "", "write logs to this file instead of stderr")
+	flag.Parse()
+
+	// Stdout belongs to the JSON-RPC stream from here on
+	protocolOut := claimStdout()
+
 	// Load configuration
 	cfg := config.LoadConfig()
+	if *logFile != "" {
+		cfg.Logging.Output = *logFile
+	}
 	if err := cfg.Validate(); err != nil {
 		log.Fatalf("Invalid configuration: %v", err)
 	}
 
-	// Initialize context manager
-	storage := context.NewMemoryStorage()
-	contextMgr := context.NewContextManager(storage)
+	logger, logOutput, err := newLogger(cfg.Logging)
+	if err != nil {
+		log.Fatalf("Failed to set up logging: %v", err)
+	}
+	defer logOutput.Close()
+	slog.SetDefault(logger)
+
+	// Initialize context manager with the configured storage backend
+	storage, err := context.NewStorage(cfg.Context.StorageBackend, *cfg)
+	if err != nil {
+		log.Fatalf("Failed to initialize storage: %v", err)
+	}
+	if closer, ok := storage.(io.Closer); ok {
+		defer closer.Close()
+	}
+	contextMgr := context.NewContextManagerWithConfig(storage, cfg.Context)
+	contextMgr.SetLogger(logger)
 	defer contextMgr.Shutdown()
+	contextMgr.SetWorldMap(newWorldMap())
 
 	// Initialize AI service
 	aiConfig := ai.AIConfig{
 		Provider:           cfg.AI.Provider,
 		APIKey:             cfg.AI.APIKey,
+		BaseURL:            cfg.AI.BaseURL,
 		Model:              cfg.AI.Model,
 		MaxTokens:          cfg.AI.MaxTokens,
 		Temperature:        cfg.AI.Temperature,
 		Timeout:            cfg.AI.Timeout,
 		MaxRetries:         cfg.AI.MaxRetries,
 		RetryDelay:         cfg.AI.RetryDelay,
+		RetryMaxDelay:      cfg.AI.RetryMaxDelay,
 		RateLimitRequests:  cfg.AI.RateLimitRequests,
 		RateLimitDuration:  cfg.AI.RateLimitDuration,
 		EnableCaching:      cfg.AI.EnableCaching,
 		CacheTTL:           cfg.AI.CacheTTL,
+		CachePath:          cfg.AI.CachePath,
+		CacheMaxEntries:    cfg.AI.CacheMaxEntries,
+		CostPer1KInput:     cfg.AI.CostPer1KInput,
+		CostPer1KOutput:    cfg.AI.CostPer1KOutput,
+
+		PerSessionRateLimitRequests: cfg.AI.PerSessionRateLimitRequests,
+		PerSessionRateLimitDuration: cfg.AI.PerSessionRateLimitDuration,
+		PerSessionRateLimitIdle:     cfg.AI.PerSessionRateLimitIdle,
+
+		EmbeddingProvider: cfg.AI.EmbeddingProvider,
+		EmbeddingModel:    cfg.AI.EmbeddingModel,
+		EmbeddingBaseURL:  cfg.AI.EmbeddingBaseURL,
+		EmbeddingAPIKey:   cfg.AI.EmbeddingAPIKey,
+		Logger:            logger,
+	}
+	for _, fallback := range cfg.AI.FallbackProviders() {
+		aiConfig.Fallbacks = append(aiConfig.Fallbacks, ai.ProviderConfig(fallback))
 	}
 
 	aiService, err := ai.NewAIService(aiConfig)
 	if err != nil {
 		log.Fatalf("Failed to initialize AI service: %v", err)
 	}
+	defer aiService.Close()
+	contextMgr.SetSummarizer(aiService)
+	contextMgr.SetDialogueGenerator(aiService)
+	contextMgr.SetFactExtractor(aiService)
+	contextMgr.SetCommandInterpreter(aiService)
+
+	if aiConfig.EmbeddingProvider != "" {
+		embedder, err := ai.NewEmbedder(aiConfig)
+		if err != nil {
+			log.Fatalf("Failed to initialize embeddings: %v", err)
+		}
+		contextMgr.SetEmbedder(embedder)
+	}
 
 	server := &AIRPGMCPServer{
 		contextMgr: contextMgr,
 		aiService:  aiService,
 		config:     cfg,
+		out:        protocolOut,
+		logger:     logger,
+		cooldowns: newToolCooldowns(map[string]time.Duration{
+			"generate_ai_response": cfg.MCP.AIToolCooldown,
+			"regenerate_response":  cfg.MCP.AIToolCooldown,
+		}),
 	}
 
-	log.Println("AI RPG MCP Server started - reading from stdin...")
+	logger.Info("AI RPG MCP Server started - reading from stdin...")
 	server.run()
 }
 
+// claimStdout reserves the process's stdout for JSON-RPC messages. It returns
+// the real stdout for sendMessage and points os.Stdout at stderr, so a stray
+// print anywhere else can't corrupt the protocol stream.
+func claimStdout() io.Writer {
+	protocol := os.Stdout
+	os.Stdout = os.Stderr
+	return protocol
+}
+
+// newLogger builds the server's logger. Stdout carries the JSON-RPC stream, so
+// logs configured for stdout go to stderr instead.
+func newLogger(cfg config.LoggingConfig) (*slog.Logger, io.Closer, error) {
+	if cfg.Output == "" || strings.EqualFold(cfg.Output, "stdout") {
+		cfg.Output = "stderr"
+	}
+	return logging.New(cfg)
+}
+
+// log returns the server's logger
+func (s *AIRPGMCPServer) log() *slog.Logger {
+	if s.logger != nil {
+		return s.logger
+	}
+	return slog.Default()
+}
+
 func (s *AIRPGMCPServer) run() {
-	scanner := bufio.NewScanner(os.Stdin)
+	s.serve(os.Stdin)
+}
+
+// serve answers newline-delimited JSON-RPC messages read from in
+func (s *AIRPGMCPServer) serve(in io.Reader) {
+	scanner := bufio.NewScanner(in)
 	for scanner.Scan() {
 		line := scanner.Text()
 		if strings.TrimSpace(line) == "" {
@@ -113,68 +221,119 @@ func (s *AIRPGMCPServer) run() {
 		}
 
 		// Log incoming message for debugging
-		log.Printf("Received message: %s", line)
+		s.log().Debug("Received message", "message", line)
+
+		if response := s.handleLine([]byte(line)); response != nil {
+			s.sendMessage(response)
+		}
+	}
+}
+
+// handleLine processes a single request or a batch (a JSON array of
+// requests) and returns what should be sent back, or nil when nothing should
+func (s *AIRPGMCPServer) handleLine(data []byte) interface{} {
+	trimmed := bytes.TrimSpace(data)
+	if len(trimmed) > 0 && trimmed[0] == '[' {
+		return s.handleBatch(trimmed)
+	}
+
+	var msg MCPMessage
+	if err := json.Unmarshal(trimmed, &msg); err != nil {
+		s.log().Warn("Parse error", "error", err)
+		return newErrorResponse(nil, -32700, "Parse error")
+	}
+
+	s.log().Debug("Parsed message", "method", msg.Method, "id", msg.ID)
+	if response := s.handleMessage(msg); response != nil {
+		return response
+	}
+	return nil
+}
+
+// handleBatch processes each request of a batch in order and returns their
+// responses as one array. Notifications get no entry, and a batch made only
+// of notifications gets no reply at all.
+func (s *AIRPGMCPServer) handleBatch(data []byte) interface{} {
+	var batch []json.RawMessage
+	if err := json.Unmarshal(data, &batch); err != nil {
+		s.log().Warn("Parse error", "error", err)
+		return newErrorResponse(nil, -32700, "Parse error")
+	}
+
+	if len(batch) == 0 {
+		return newErrorResponse(nil, -32600, "Invalid Request: empty batch")
+	}
 
+	s.log().Debug("Handling batch", "size", len(batch))
+
+	responses := []*MCPResponse{}
+	for _, raw := range batch {
 		var msg MCPMessage
-		if err := json.Unmarshal([]byte(line), &msg); err != nil {
-			log.Printf("Parse error: %v", err)
-			// Send JSON-RPC 2.0 parse error
-			parseErrorResponse := MCPResponse{
-				JSONRPC: "2.0",
-				ID:      nil,
-				Error: &MCPError{
-					Code:    -32700,
-					Message: "Parse error",
-				},
-			}
-			s.sendMessage(parseErrorResponse)
+		if err := json.Unmarshal(raw, &msg); err != nil {
+			responses = append(responses, newErrorResponse(nil, -32600, "Invalid Request"))
 			continue
 		}
 
-		log.Printf("Parsed message - Method: %s, ID: %v", msg.Method, msg.ID)
-		s.handleMessage(msg)
+		if response := s.handleMessage(msg); response != nil {
+			responses = append(responses, response)
+		}
 	}
+
+	if len(responses) == 0 {
+		return nil
+	}
+	return responses
 }
 
-func (s *AIRPGMCPServer) handleMessage(msg MCPMessage) {
+// handleMessage dispatches a request and returns its response, or nil for a
+// notification (a request without an id), which is never answered
+func (s *AIRPGMCPServer) handleMessage(msg MCPMessage) *MCPResponse {
 	// Validate JSON-RPC 2.0 format
 	if msg.JSONRPC != "2.0" {
-		log.Printf("Invalid JSON-RPC version: %s", msg.JSONRPC)
-		s.sendError(msg.ID, -32600, "Invalid Request: jsonrpc field must be '2.0'")
-		return
+		s.log().Warn("Invalid JSON-RPC version", "version", msg.JSONRPC)
+		return newErrorResponse(msg.ID, -32600, "Invalid Request: jsonrpc field must be '2.0'")
 	}
 
 	// Validate method is provided
 	if msg.Method == "" {
-		log.Printf("Missing method field")
-		s.sendError(msg.ID, -32600, "Invalid Request: method field is required")
-		return
+		s.log().Warn("Missing method field")
+		return newErrorResponse(msg.ID, -32600, "Invalid Request: method field is required")
 	}
 
-	log.Printf("Handling method: %s", msg.Method)
+	s.log().Debug("Handling method", "method", msg.Method)
 
+	var response *MCPResponse
 	switch msg.Method {
 	case "initialize":
-		s.handleInitialize(msg.ID)
+		response = s.handleInitialize(msg.ID)
 	case "tools/list":
-		s.handleToolsList(msg.ID)
+		response = s.handleToolsList(msg.ID)
 	case "tools/call":
-		s.handleToolCall(msg.ID, msg.Params)
+		response = s.handleToolCall(msg.ID, msg.Params)
 	case "prompts/list":
-		s.handlePromptsList(msg.ID)
+		response = s.handlePromptsList(msg.ID)
+	case "prompts/get":
+		response = s.handlePromptsGet(msg.ID, msg.Params)
 	default:
-		log.Printf("Unknown method: %s", msg.Method)
-		s.sendError(msg.ID, -32601, "Method not found")
+		s.log().Warn("Unknown method", "method", msg.Method)
+		response = newErrorResponse(msg.ID, -32601, "Method not found")
 	}
+
+	if msg.ID == nil {
+		s.log().Debug("Not responding to notification", "method", msg.Method)
+		return nil
+	}
+	return response
 }
 
-func (s *AIRPGMCPServer) handleInitialize(id interface{}) {
-	log.Printf("Handling initialize request with ID: %v", id)
+func (s *AIRPGMCPServer) handleInitialize(id interface{}) *MCPResponse {
+	s.log().Debug("Handling initialize request", "id", id)
 	
 	result := map[string]interface{}{
 		"protocolVersion": "2024-11-05",
 		"capabilities": map[string]interface{}{
-			"tools": map[string]interface{}{},
+			"tools":   map[string]interface{}{},
+			"prompts": map[string]interface{}{},
 		},
 		"serverInfo": map[string]interface{}{
 			"name":    "ai-rpg-server",
@@ -182,14 +341,26 @@ func (s *AIRPGMCPServer) handleInitialize(id interface{}) {
 		},
 	}
 	
-	log.Printf("Sending initialize response")
-	s.sendResponse(id, result)
+	s.log().Debug("Sending initialize response")
+	return newResponse(id, result)
 }
 
-func (s *AIRPGMCPServer) handleToolsList(id interface{}) {
-	log.Printf("Handling tools/list request with ID: %v", id)
+func (s *AIRPGMCPServer) handleToolsList(id interface{}) *MCPResponse {
+	s.log().Debug("Handling tools/list request", "id", id)
 	
-	tools := []MCPTool{
+	tools := gameTools()
+	result := map[string]interface{}{
+		"tools": tools,
+	}
+	
+	s.log().Debug("Sending tools/list response", "tools", len(tools))
+	return newResponse(id, result)
+}
+
+// gameTools returns the tools offered to MCP clients. Arguments to a tool call
+// are checked against its InputSchema before it runs.
+func gameTools() []MCPTool {
+	return []MCPTool{
 		{
 			Name:        "create_session",
 			Description: "Create a new AI RPG player session",
@@ -222,10 +393,28 @@ func (s *AIRPGMCPServer) handleToolsList(id interface{}) {
 						"type":        "string",
 						"description": "Game command to execute (e.g., '/look around', '/talk tavern_keeper')",
 					},
+					"idempotencyKey": map[string]interface{}{
+						"type":        "string",
+						"description": "Optional key; retrying with the same key applies the action only once",
+					},
 				},
 				"required": []string{"sessionID", "command"},
 			},
 		},
+		{
+			Name:        "regenerate_response",
+			Description: "Ask the AI Game Master again for its response to the player's last action, replacing the stored one; the action's effects stay as they were",
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"sessionID": map[string]interface{}{
+						"type":        "string",
+						"description": "Player session identifier",
+					},
+				},
+				"required": []string{"sessionID"},
+			},
+		},
 		{
 			Name:        "get_session_status",
 			Description: "Get current session status and context",
@@ -323,45 +512,226 @@ func (s *AIRPGMCPServer) handleToolsList(id interface{}) {
 		},
 		{
 			Name:        "list_active_sessions",
-			Description: "List all active player sessions",
+			Description: "List active player sessions, newest first, optionally filtered, one page at a time",
 			InputSchema: map[string]interface{}{
 				"type": "object",
-				"properties": map[string]interface{}{},
+				"properties": map[string]interface{}{
+					"playerID": map[string]interface{}{
+						"type":        "string",
+						"description": "Only sessions of this player",
+					},
+					"location": map[string]interface{}{
+						"type":        "string",
+						"description": "Only sessions at this location ID",
+					},
+					"minReputation": map[string]interface{}{
+						"type":        "integer",
+						"description": "Only sessions with at least this reputation",
+					},
+					"activeWithinMinutes": map[string]interface{}{
+						"type":        "integer",
+						"description": "Only sessions updated within this many minutes",
+					},
+					"page": map[string]interface{}{
+						"type":        "integer",
+						"description": "Page number, from 1 (default 1)",
+					},
+					"pageSize": map[string]interface{}{
+						"type":        "integer",
+						"description": "Sessions per page (default 20, at most 100)",
+					},
+				},
+			},
+		},
+		{
+			Name:        "delete_session",
+			Description: "End a player session and remove its saved state",
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"sessionID": map[string]interface{}{
+						"type":        "string",
+						"description": "Session ID to end",
+					},
+				},
+				"required": []string{"sessionID"},
+			},
+		},
+		{
+			Name:        "export_transcript",
+			Description: "Export a session's full transcript as Markdown",
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"sessionID": map[string]interface{}{
+						"type":        "string",
+						"description": "Player session identifier",
+					},
+				},
+				"required": []string{"sessionID"},
+			},
+		},
+		{
+			Name:        "get_inventory",
+			Description: "List the player's inventory and gold",
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"sessionID": map[string]interface{}{
+						"type":        "string",
+						"description": "Player session identifier",
+					},
+				},
+				"required": []string{"sessionID"},
+			},
+		},
+		{
+			Name:        "add_item",
+			Description: "Add an item to the player's inventory, stacking onto an item with the same ID",
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"sessionID": map[string]interface{}{
+						"type":        "string",
+						"description": "Player session identifier",
+					},
+					"itemID": map[string]interface{}{
+						"type":        "string",
+						"description": "Item identifier",
+					},
+					"name": map[string]interface{}{
+						"type":        "string",
+						"description": "Item display name; defaults to the item ID",
+					},
+					"type": map[string]interface{}{
+						"type":        "string",
+						"description": "Item type (e.g., 'weapon', 'potion')",
+					},
+					"quantity": map[string]interface{}{
+						"type":        "integer",
+						"description": "How many to add (default 1)",
+					},
+					"value": map[string]interface{}{
+						"type":        "integer",
+						"description": "Value of one item in gold",
+					},
+				},
+				"required": []string{"sessionID", "itemID"},
+			},
+		},
+		{
+			Name:        "remove_item",
+			Description: "Remove items from the player's inventory",
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"sessionID": map[string]interface{}{
+						"type":        "string",
+						"description": "Player session identifier",
+					},
+					"itemID": map[string]interface{}{
+						"type":        "string",
+						"description": "Item identifier",
+					},
+					"quantity": map[string]interface{}{
+						"type":        "integer",
+						"description": "How many to remove (default 1)",
+					},
+				},
+				"required": []string{"sessionID", "itemID"},
+			},
+		},
+		{
+			Name:        "get_quests",
+			Description: "List the player's active quests and their objectives",
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"sessionID": map[string]interface{}{
+						"type":        "string",
+						"description": "Player session identifier",
+					},
+				},
+				"required": []string{"sessionID"},
+			},
+		},
+		{
+			Name:        "start_quest",
+			Description: "Start a quest for the player",
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"sessionID": map[string]interface{}{
+						"type":        "string",
+						"description": "Player session identifier",
+					},
+					"questID": map[string]interface{}{
+						"type":        "string",
+						"description": "Quest identifier",
+					},
+					"title": map[string]interface{}{
+						"type":        "string",
+						"description": "Quest title",
+					},
+					"description": map[string]interface{}{
+						"type":        "string",
+						"description": "What the quest is about",
+					},
+					"objectives": map[string]interface{}{
+						"type":        "array",
+						"items":       map[string]interface{}{"type": "string"},
+						"description": "Ordered objectives to complete",
+					},
+				},
+				"required": []string{"sessionID", "questID", "title"},
+			},
+		},
+		{
+			Name:        "attribute_check",
+			Description: "Roll a d20 skill check (persuasion, lockpicking, ...) using the player's attribute modifier; use it instead of deciding the outcome yourself",
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"sessionID": map[string]interface{}{
+						"type":        "string",
+						"description": "Player session identifier",
+					},
+					"attribute": map[string]interface{}{
+						"type":        "string",
+						"description": "Attribute the check uses, such as strength, dexterity, intelligence or charisma",
+					},
+					"difficulty": map[string]interface{}{
+						"type":        "integer",
+						"description": "Total the roll must meet, e.g. 10 easy, 15 hard, 20 very hard",
+					},
+				},
+				"required": []string{"sessionID", "attribute", "difficulty"},
 			},
 		},
 	}
-
-	result := map[string]interface{}{
-		"tools": tools,
-	}
-	
-	log.Printf("Sending tools/list response with %d tools", len(tools))
-	s.sendResponse(id, result)
 }
 
-func (s *AIRPGMCPServer) handlePromptsList(id interface{}) {
-	log.Printf("Handling prompts/list request with ID: %v", id)
+func (s *AIRPGMCPServer) handlePromptsList(id interface{}) *MCPResponse {
+	s.log().Debug("Handling prompts/list request", "id", id)
 	
-	// Return empty prompts list since we don't use prompts
+	prompts := gamePrompts()
 	result := map[string]interface{}{
-		"prompts": []interface{}{},
+		"prompts": prompts,
 	}
 	
-	log.Printf("Sending empty prompts/list response")
-	s.sendResponse(id, result)
+	s.log().Debug("Sending prompts/list response", "prompts", len(prompts))
+	return newResponse(id, result)
 }
 
-func (s *AIRPGMCPServer) handleToolCall(id interface{}, params interface{}) {
+func (s *AIRPGMCPServer) handleToolCall(id interface{}, params interface{}) *MCPResponse {
 	paramsMap, ok := params.(map[string]interface{})
 	if !ok {
-		s.sendError(id, -32602, "Invalid params")
-		return
+		return newErrorResponse(id, -32602, "Invalid params")
 	}
 
 	toolName, ok := paramsMap["name"].(string)
 	if !ok {
-		s.sendError(id, -32602, "Missing tool name")
-		return
+		return newErrorResponse(id, -32602, "Missing tool name")
 	}
 
 	arguments, ok := paramsMap["arguments"].(map[string]interface{})
@@ -369,21 +739,50 @@ func (s *AIRPGMCPServer) handleToolCall(id interface{}, params interface{}) {
 		arguments = make(map[string]interface{})
 	}
 
-	result, err := s.executeToolCall(toolName, arguments)
+	result, err := s.executeToolCall(s.requestContext(), toolName, arguments, progressToken(paramsMap))
+	var invalid *invalidParamsError
+	if errors.As(err, &invalid) {
+		return newErrorResponse(id, -32602, err.Error())
+	}
+	var throttled *throttledError
+	if errors.As(err, &throttled) {
+		return newErrorResponse(id, -32000, err.Error())
+	}
 	if err != nil {
-		s.sendError(id, -32603, err.Error())
-		return
+		return newErrorResponse(id, -32603, err.Error())
 	}
 
-	s.sendResponse(id, result)
+	return newResponse(id, result)
 }
 
-func (s *AIRPGMCPServer) executeToolCall(toolName string, args map[string]interface{}) (*MCPToolResult, error) {
+// requestContext returns the context a tool call runs under
+func (s *AIRPGMCPServer) requestContext() stdcontext.Context {
+	if s.ctx == nil {
+		return stdcontext.Background()
+	}
+	return s.ctx
+}
+
+// executeToolCall runs a tool. When progressToken is set, long-running tools
+// report their progress to the client as notifications.
+func (s *AIRPGMCPServer) executeToolCall(ctx stdcontext.Context, toolName string, args map[string]interface{}, progressToken interface{}) (*MCPToolResult, error) {
+	if err := validateToolArguments(toolName, args); err != nil {
+		return nil, err
+	}
+	if s.cooldowns != nil {
+		sessionID, _ := args["sessionID"].(string)
+		if err := s.cooldowns.allow(toolName, sessionID); err != nil {
+			return nil, err
+		}
+	}
+
 	switch toolName {
 	case "create_session":
 		return s.toolCreateSession(args)
 	case "execute_action":
-		return s.toolExecuteAction(args)
+		return s.toolExecuteAction(ctx, args, s.progressReporter(progressToken))
+	case "regenerate_response":
+		return s.toolRegenerateResponse(args)
 	case "get_session_status":
 		return s.toolGetSessionStatus(args)
 	case "update_location":
@@ -391,11 +790,27 @@ func (s *AIRPGMCPServer) executeToolCall(toolName string, args map[string]interf
 	case "update_npc_relationship":
 		return s.toolUpdateNPCRelationship(args)
 	case "generate_ai_response":
-		return s.toolGenerateAIResponse(args)
+		return s.toolGenerateAIResponse(ctx, args)
 	case "get_session_metrics":
 		return s.toolGetSessionMetrics(args)
 	case "list_active_sessions":
 		return s.toolListActiveSessions(args)
+	case "delete_session":
+		return s.toolDeleteSession(args)
+	case "export_transcript":
+		return s.toolExportTranscript(args)
+	case "get_inventory":
+		return s.toolGetInventory(args)
+	case "add_item":
+		return s.toolAddItem(args)
+	case "remove_item":
+		return s.toolRemoveItem(args)
+	case "get_quests":
+		return s.toolGetQuests(args)
+	case "start_quest":
+		return s.toolStartQuest(args)
+	case "attribute_check":
+		return s.toolAttributeCheck(args)
 	default:
 		return nil, fmt.Errorf("unknown tool: %s", toolName)
 	}
@@ -404,15 +819,8 @@ func (s *AIRPGMCPServer) executeToolCall(toolName string, args map[string]interf
 // Tool Implementations
 
 func (s *AIRPGMCPServer) toolCreateSession(args map[string]interface{}) (*MCPToolResult, error) {
-	playerID, ok := args["playerID"].(string)
-	if !ok {
-		return nil, fmt.Errorf("playerID is required")
-	}
-
-	playerName, ok := args["playerName"].(string)
-	if !ok {
-		return nil, fmt.Errorf("playerName is required")
-	}
+	playerID := args["playerID"].(string)
+	playerName := args["playerName"].(string)
 
 	sessionID, err := s.contextMgr.CreateSession(playerID, playerName)
 	if err != nil {
@@ -434,48 +842,104 @@ func (s *AIRPGMCPServer) toolCreateSession(args map[string]interface{}) (*MCPToo
 	return result, nil
 }
 
-func (s *AIRPGMCPServer) toolExecuteAction(args map[string]interface{}) (*MCPToolResult, error) {
-	sessionID, ok := args["sessionID"].(string)
-	if !ok {
-		return nil, fmt.Errorf("sessionID is required")
+func (s *AIRPGMCPServer) toolExecuteAction(callCtx stdcontext.Context, args map[string]interface{}, progress progressFunc) (*MCPToolResult, error) {
+	sessionID := args["sessionID"].(string)
+	command := args["command"].(string)
+
+	// A retried call is answered without replaying the action. The key is
+	// claimed before anything changes, so a retry racing the first call is
+	// caught too, and handed back if the call fails before taking effect.
+	idempotencyKey, _ := args["idempotencyKey"].(string)
+	if !s.contextMgr.ClaimIdempotencyKey(sessionID, idempotencyKey) {
+		return alreadyAppliedResult(), nil
 	}
+	tookEffect := false
+	defer func() {
+		if !tookEffect {
+			s.contextMgr.ReleaseIdempotencyKey(sessionID, idempotencyKey)
+		}
+	}()
 
-	command, ok := args["command"].(string)
-	if !ok {
-		return nil, fmt.Errorf("command is required")
+	// Get current context; actions on an unknown session are refused
+	ctx, err := s.contextMgr.GetExistingContext(sessionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get context: %w", err)
+	}
+
+	// Turn away impossible actions before spending an AI call on them
+	if err := s.contextMgr.ValidateAction(sessionID, command); err != nil {
+		return &MCPToolResult{
+			Content: []MCPContent{
+				{
+					Type: "text",
+					Text: fmt.Sprintf("You can't do that: %s", err),
+				},
+			},
+		}, nil
 	}
 
-	// Get current context
-	ctx, err := s.contextMgr.GetContext(sessionID)
+	// Determine action type and consequences; free text is interpreted by the AI
+	parsed, err := s.contextMgr.InterpretCommand(sessionID, command)
 	if err != nil {
-		return nil, fmt.Errorf("session not found: %w", err)
+		return nil, fmt.Errorf("failed to interpret command: %w", err)
 	}
+	actionType, target, consequences := parsed.ActionType, parsed.Target, parsed.Consequences
 
-	// Determine action type and consequences
-	actionType, target, consequences := s.parseGameCommand(command)
+	// Resolve attacks with dice so the GM narrates the result instead of inventing one
+	var combatResult string
+	if actionType == "combat" && target != "" {
+		result, err := s.contextMgr.ResolveCombatAction(sessionID, target)
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve combat: %w", err)
+		}
+		combatResult = fmt.Sprintf("\n\nCombat Result (already decided, describe exactly this): The player %s", result.Describe())
+		if !result.Hit {
+			consequences = []string{"combat_miss"}
+		}
+	}
+
+	// Bring a dead player back before the GM describes their return
+	if actionType == "respawn" {
+		if err := s.contextMgr.Respawn(sessionID); err != nil {
+			return nil, fmt.Errorf("failed to respawn: %w", err)
+		}
+	}
+
+	tookEffect = true
 
 	// Generate AI response
-	prompt, err := s.contextMgr.GenerateAIPrompt(sessionID)
+	prompt, err := s.contextMgr.GenerateAIPromptForCommand(sessionID, command)
 	if err != nil {
 		return nil, fmt.Errorf("failed to generate AI prompt: %w", err)
 	}
 
-	fullPrompt := fmt.Sprintf("%s\n\nPlayer Action: %s\n\nAs the Game Master, respond to this player action with an engaging, contextual response.", prompt, command)
+	fullPrompt := fmt.Sprintf("%s\n\nPlayer Action: %s%s\n\nAs the Game Master, respond to this player action with an engaging, contextual response.", prompt, command, combatResult)
 
-	aiResponse, err := s.aiService.GenerateGMResponse(fullPrompt)
-	if err != nil {
-		log.Printf("AI service error: %v", err)
+	progress(0, 1, "Generating GM response")
+	aiResponse, err := s.aiService.GenerateGMResponseForSessionCtx(callCtx, sessionID, fullPrompt)
+	progress(1, 1, "GM response ready")
+	cancelled := callCtx.Err()
+	if cancelled != nil {
+		// The call was abandoned, but the action has already taken effect, so
+		// it is recorded with the fallback narration all the same
+		s.log().Info("Call cancelled, recording the action with the fallback narration", "session_id", sessionID)
+		aiResponse = fmt.Sprintf("You attempt to %s. The world responds to your action.", command)
+	} else if err != nil {
+		s.log().Error("AI service error", "error", err)
 		aiResponse = fmt.Sprintf("You attempt to %s. The world responds to your action.", command)
 	}
 
 	// Record the action
-	err = s.contextMgr.RecordAction(sessionID, command, actionType, target, ctx.Location.Current, aiResponse, consequences)
-	if err != nil {
+	if err := s.contextMgr.RecordAction(sessionID, command, actionType, target, ctx.Location.Current, aiResponse, consequences); err != nil {
 		return nil, fmt.Errorf("failed to record action: %w", err)
 	}
+	s.contextMgr.RememberNarration(sessionID, command, fullPrompt)
 
 	// Apply specific consequences
-	s.applyActionConsequences(sessionID, command, consequences)
+	s.applyActionConsequences(sessionID, command, target, consequences)
+	if cancelled != nil {
+		return nil, fmt.Errorf("request cancelled: %w", cancelled)
+	}
 
 	// Get updated context
 	summary, err := s.contextMgr.GetContextSummary(sessionID)
@@ -496,12 +960,25 @@ func (s *AIRPGMCPServer) toolExecuteAction(args map[string]interface{}) (*MCPToo
 	}, nil
 }
 
-func (s *AIRPGMCPServer) toolGetSessionStatus(args map[string]interface{}) (*MCPToolResult, error) {
-	sessionID, ok := args["sessionID"].(string)
-	if !ok {
-		return nil, fmt.Errorf("sessionID is required")
+// alreadyAppliedResult answers a retry of an action that was already recorded
+func alreadyAppliedResult() *MCPToolResult {
+	return &MCPToolResult{
+		Content: []MCPContent{
+			{
+				Type: "text",
+				Text: "This action was already applied.",
+			},
+		},
 	}
+}
 
+func (s *AIRPGMCPServer) toolGetSessionStatus(args map[string]interface{}) (*MCPToolResult, error) {
+	sessionID := args["sessionID"].(string)
+
+	// Report a mistyped session ID rather than a blank new session
+	if _, err := s.contextMgr.GetExistingContext(sessionID); err != nil {
+		return nil, fmt.Errorf("failed to get context: %w", err)
+	}
 	summary, err := s.contextMgr.GetContextSummary(sessionID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get context: %w", err)
@@ -527,7 +1004,7 @@ Active NPCs:
 		summary.PreviousLocation,
 		summary.PlayerHealth,
 		summary.PlayerReputation,
-		s.getReputationDescription(summary.PlayerReputation),
+		context.ReputationLabel(summary.PlayerReputation),
 		summary.PlayerMood,
 		summary.SessionDuration,
 		strings.Join(summary.RecentActions, "\n"),
@@ -545,15 +1022,8 @@ Active NPCs:
 }
 
 func (s *AIRPGMCPServer) toolUpdateLocation(args map[string]interface{}) (*MCPToolResult, error) {
-	sessionID, ok := args["sessionID"].(string)
-	if !ok {
-		return nil, fmt.Errorf("sessionID is required")
-	}
-
-	location, ok := args["location"].(string)
-	if !ok {
-		return nil, fmt.Errorf("location is required")
-	}
+	sessionID := args["sessionID"].(string)
+	location := args["location"].(string)
 
 	err := s.contextMgr.UpdateLocation(sessionID, location)
 	if err != nil {
@@ -571,20 +1041,9 @@ func (s *AIRPGMCPServer) toolUpdateLocation(args map[string]interface{}) (*MCPTo
 }
 
 func (s *AIRPGMCPServer) toolUpdateNPCRelationship(args map[string]interface{}) (*MCPToolResult, error) {
-	sessionID, ok := args["sessionID"].(string)
-	if !ok {
-		return nil, fmt.Errorf("sessionID is required")
-	}
-
-	npcID, ok := args["npcID"].(string)
-	if !ok {
-		return nil, fmt.Errorf("npcID is required")
-	}
-
-	npcName, ok := args["npcName"].(string)
-	if !ok {
-		return nil, fmt.Errorf("npcName is required")
-	}
+	sessionID := args["sessionID"].(string)
+	npcID := args["npcID"].(string)
+	npcName := args["npcName"].(string)
 
 	dispositionChange := 0
 	if val, ok := args["dispositionChange"].(float64); ok {
@@ -616,25 +1075,18 @@ func (s *AIRPGMCPServer) toolUpdateNPCRelationship(args map[string]interface{})
 	}, nil
 }
 
-func (s *AIRPGMCPServer) toolGenerateAIResponse(args map[string]interface{}) (*MCPToolResult, error) {
-	sessionID, ok := args["sessionID"].(string)
-	if !ok {
-		return nil, fmt.Errorf("sessionID is required")
-	}
-
-	playerAction, ok := args["playerAction"].(string)
-	if !ok {
-		return nil, fmt.Errorf("playerAction is required")
-	}
+func (s *AIRPGMCPServer) toolGenerateAIResponse(callCtx stdcontext.Context, args map[string]interface{}) (*MCPToolResult, error) {
+	sessionID := args["sessionID"].(string)
+	playerAction := args["playerAction"].(string)
 
-	prompt, err := s.contextMgr.GenerateAIPrompt(sessionID)
+	prompt, err := s.contextMgr.GenerateAIPromptForCommand(sessionID, playerAction)
 	if err != nil {
 		return nil, fmt.Errorf("failed to generate AI prompt: %w", err)
 	}
 
 	fullPrompt := fmt.Sprintf("%s\n\nPlayer Action: %s\n\nAs the Game Master, respond to this player action with an engaging, contextual response.", prompt, playerAction)
 
-	aiResponse, err := s.aiService.GenerateGMResponse(fullPrompt)
+	aiResponse, err := s.aiService.GenerateGMResponseForSessionCtx(callCtx, sessionID, fullPrompt)
 	if err != nil {
 		return nil, fmt.Errorf("failed to generate AI response: %w", err)
 	}
@@ -650,14 +1102,11 @@ func (s *AIRPGMCPServer) toolGenerateAIResponse(args map[string]interface{}) (*M
 }
 
 func (s *AIRPGMCPServer) toolGetSessionMetrics(args map[string]interface{}) (*MCPToolResult, error) {
-	sessionID, ok := args["sessionID"].(string)
-	if !ok {
-		return nil, fmt.Errorf("sessionID is required")
-	}
+	sessionID := args["sessionID"].(string)
 
-	ctx, err := s.contextMgr.GetContext(sessionID)
+	ctx, err := s.contextMgr.GetExistingContext(sessionID)
 	if err != nil {
-		return nil, fmt.Errorf("session not found: %w", err)
+		return nil, fmt.Errorf("failed to get context: %w", err)
 	}
 
 	duration, err := s.contextMgr.GetSessionDuration(sessionID)
@@ -679,6 +1128,7 @@ Statistics:
 Character State:
 - Health: %d/%d
 - Reputation: %d
+- Gold: %d
 - Equipment Items: %d
 - Inventory Items: %d`,
 		sessionID,
@@ -692,6 +1142,7 @@ Character State:
 		ctx.Character.Health.Current,
 		ctx.Character.Health.Max,
 		ctx.Character.Reputation,
+		ctx.Character.Gold,
 		len(ctx.Character.Equipment),
 		len(ctx.Character.Inventory),
 	)
@@ -706,96 +1157,60 @@ Character State:
 	}, nil
 }
 
+// defaultSessionPageSize is how many sessions list_active_sessions shows
+// when no page size is given
+const defaultSessionPageSize = 20
+
 func (s *AIRPGMCPServer) toolListActiveSessions(args map[string]interface{}) (*MCPToolResult, error) {
-	sessions := s.contextMgr.GetActiveSessions()
+	filter := context.SessionFilter{
+		ActiveWithin: time.Duration(intArgument(args, "activeWithinMinutes", 0)) * time.Minute,
+	}
+	filter.PlayerID, _ = args["playerID"].(string)
+	filter.Location, _ = args["location"].(string)
+	if _, ok := args["minReputation"]; ok {
+		minReputation := intArgument(args, "minReputation", 0)
+		filter.MinReputation = &minReputation
+	}
 
-	if len(sessions) == 0 {
-		return &MCPToolResult{
-			Content: []MCPContent{
-				{
-					Type: "text",
-					Text: "No active sessions",
-				},
-			},
-		}, nil
+	page := intArgument(args, "page", 1)
+	sessions, total, err := s.contextMgr.ListSessions(filter, page, intArgument(args, "pageSize", defaultSessionPageSize))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list sessions: %w", err)
 	}
 
-	sessionsList := "Active Sessions:\n"
-	for i, sessionID := range sessions {
-		ctx, err := s.contextMgr.GetContext(sessionID)
-		if err != nil {
-			continue
-		}
+	if total == 0 {
+		return textResult("No active sessions"), nil
+	}
+	if len(sessions) == 0 {
+		return textResult(fmt.Sprintf("No sessions on page %d; %d active sessions match", page, total)), nil
+	}
 
-		duration, _ := s.contextMgr.GetSessionDuration(sessionID)
-		sessionsList += fmt.Sprintf("%d. %s - %s (Duration: %s, Location: %s)\n",
-			i+1, sessionID, ctx.Character.Name, duration.String(), ctx.Location.Current)
+	sessionsList := fmt.Sprintf("Active Sessions (page %d, %d matching):\n", page, total)
+	for _, session := range sessions {
+		sessionsList += fmt.Sprintf("- %s - %s (Location: %s, Reputation: %d, Last active: %s ago)\n",
+			session.SessionID, session.Name, session.Location, session.Reputation, time.Since(session.LastUpdate).Round(time.Second))
 	}
 
-	return &MCPToolResult{
-		Content: []MCPContent{
-			{
-				Type: "text",
-				Text: sessionsList,
-			},
-		},
-	}, nil
+	return textResult(sessionsList), nil
 }
 
 // Helper functions
 
-func (s *AIRPGMCPServer) parseGameCommand(command string) (string, string, []string) {
-	var actionType, target string
-	var consequences []string
-
-	switch {
-	case strings.HasPrefix(command, "/look") || strings.HasPrefix(command, "/examine"):
-		actionType = "examine"
-		target = "environment"
-		consequences = []string{"exploration_success"}
-	case strings.HasPrefix(command, "/talk") || strings.HasPrefix(command, "/speak"):
-		actionType = "social"
-		parts := strings.Fields(command)
-		if len(parts) > 1 {
-			target = parts[1]
-		}
-		consequences = []string{"social_success", "npc_noticed"}
-	case strings.HasPrefix(command, "/attack") || strings.HasPrefix(command, "/fight"):
-		actionType = "combat"
-		parts := strings.Fields(command)
-		if len(parts) > 1 {
-			target = parts[1]
-		}
-		consequences = []string{"combat_success", "reputation_increase"}
-	case strings.HasPrefix(command, "/move") || strings.HasPrefix(command, "/go"):
-		actionType = "move"
-		parts := strings.Fields(command)
-		if len(parts) > 1 {
-			target = parts[1]
-		}
-		consequences = []string{"location_change"}
-	default:
-		actionType = "unknown"
-		target = "unknown"
-		consequences = []string{}
-	}
-
-	return actionType, target, consequences
-}
-
-func (s *AIRPGMCPServer) applyActionConsequences(sessionID, command string, consequences []string) {
+func (s *AIRPGMCPServer) applyActionConsequences(sessionID, command, target string, consequences []string) {
 	for _, consequence := range consequences {
 		switch consequence {
-		case "reputation_increase":
-			s.contextMgr.UpdateReputation(sessionID, 5)
 		case "combat_success":
-			s.contextMgr.UpdateReputation(sessionID, 10)
 			s.contextMgr.UpdateCharacterHealth(sessionID, -2)
 		case "location_change":
-			if strings.Contains(command, "forest") {
-				s.contextMgr.UpdateLocation(sessionID, "thornwick_forest")
-			} else if strings.Contains(command, "village") {
-				s.contextMgr.UpdateLocation(sessionID, "starting_village")
+			exits, err := s.contextMgr.GetExits(sessionID)
+			if err != nil {
+				s.log().Warn("Failed to get exits", "error", err)
+				continue
+			}
+			if destination := context.ResolveExit(exits, target); destination != "" {
+				if err := s.contextMgr.MoveTo(sessionID, destination); err != nil {
+					s.log().Warn("Failed to move", "destination", destination, "error", err)
+				}
 			}
 		case "npc_noticed":
 			if strings.Contains(command, "tavern_keeper") {
@@ -806,23 +1221,13 @@ func (s *AIRPGMCPServer) applyActionConsequences(sessionID, command string, cons
 	}
 }
 
-func (s *AIRPGMCPServer) getReputationDescription(reputation int) string {
-	switch {
-	case reputation >= 75:
-		return "Heroic"
-	case reputation >= 50:
-		return "Well-regarded"
-	case reputation >= 25:
-		return "Respected"
-	case reputation >= 0:
-		return "Neutral"
-	case reputation >= -25:
-		return "Mistrusted"
-	case reputation >= -50:
-		return "Disliked"
-	default:
-		return "Notorious"
-	}
+// newWorldMap builds the map of locations players can travel between
+func newWorldMap() *context.WorldMap {
+	worldMap := context.NewWorldMap()
+	worldMap.AddLocation("starting_village", "Starting Village")
+	worldMap.AddLocation("thornwick_forest", "Thornwick Forest")
+	worldMap.ConnectLocations("starting_village", "thornwick_forest", true)
+	return worldMap
 }
 
 func (s *AIRPGMCPServer) formatNPCs(npcs []context.NPCContextInfo) string {
@@ -839,19 +1244,55 @@ func (s *AIRPGMCPServer) formatNPCs(npcs []context.NPCContextInfo) string {
 	return strings.Join(result, "\n")
 }
 
+func (s *AIRPGMCPServer) toolDeleteSession(args map[string]interface{}) (*MCPToolResult, error) {
+	sessionID := args["sessionID"].(string)
+
+	if err := s.contextMgr.EndSession(sessionID); err != nil {
+		return nil, fmt.Errorf("failed to end session: %w", err)
+	}
+
+	return &MCPToolResult{
+		Content: []MCPContent{
+			{
+				Type: "text",
+				Text: fmt.Sprintf("Session %s has ended", sessionID),
+			},
+		},
+	}, nil
+}
+
+func (s *AIRPGMCPServer) toolExportTranscript(args map[string]interface{}) (*MCPToolResult, error) {
+	sessionID := args["sessionID"].(string)
+
+	transcript, err := s.contextMgr.ExportTranscript(sessionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to export transcript: %w", err)
+	}
+
+	return &MCPToolResult{
+		Content: []MCPContent{
+			{
+				Type: "text",
+				Text: transcript,
+			},
+		},
+	}, nil
+}
+
 // MCP Protocol helpers
 
-func (s *AIRPGMCPServer) sendResponse(id interface{}, result interface{}) {
-	response := MCPResponse{
+// newResponse builds a successful JSON-RPC response
+func newResponse(id interface{}, result interface{}) *MCPResponse {
+	return &MCPResponse{
 		JSONRPC: "2.0",
 		ID:      id,
 		Result:  result,
 	}
-	s.sendMessage(response)
 }
 
-func (s *AIRPGMCPServer) sendError(id interface{}, code int, message string) {
-	response := MCPResponse{
+// newErrorResponse builds a JSON-RPC error response
+func newErrorResponse(id interface{}, code int, message string) *MCPResponse {
+	return &MCPResponse{
 		JSONRPC: "2.0",
 		ID:      id,
 		Error: &MCPError{
@@ -859,18 +1300,21 @@ func (s *AIRPGMCPServer) sendError(id interface{}, code int, message string) {
 			Message: message,
 		},
 	}
-	s.sendMessage(response)
 }
 
 func (s *AIRPGMCPServer) sendMessage(msg interface{}) {
 	data, err := json.Marshal(msg)
 	if err != nil {
-		log.Printf("Error marshaling message: %v", err)
+		s.log().Error("Failed to marshal message", "error", err)
 		return
 	}
 	
 	// Log outgoing message for debugging
-	log.Printf("Sending response: %s", string(data))
+	s.log().Debug("Sending response", "message", string(data))
 	
-	fmt.Println(string(data))
+	out := s.out
+	if out == nil {
+		out = os.Stdout
+	}
+	fmt.Fprintln(out, string(data))
 }
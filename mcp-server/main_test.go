@@ -0,0 +1,750 @@
+package main
+
+import (
+	"bytes"
+	stdcontext "context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"ai-rpg-mvp/ai"
+	"ai-rpg-mvp/ai/aitest"
+	gamecommand "ai-rpg-mvp/command"
+	"ai-rpg-mvp/config"
+	"ai-rpg-mvp/context"
+)
+
+func newTestServer(t *testing.T) (*AIRPGMCPServer, *bytes.Buffer) {
+	t.Helper()
+	contextMgr := context.NewContextManager(context.NewMemoryStorage())
+	t.Cleanup(contextMgr.Shutdown)
+
+	aiService, err := ai.NewAIService(ai.AIConfig{Provider: "mock"})
+	if err != nil {
+		t.Fatalf("Failed to create AI service: %v", err)
+	}
+
+	out := &bytes.Buffer{}
+	return &AIRPGMCPServer{contextMgr: contextMgr, aiService: aiService, out: out}, out
+}
+
+// call dispatches a JSON-RPC request and decodes the response as a client would
+func call(t *testing.T, server *AIRPGMCPServer, method string, params interface{}) MCPResponse {
+	t.Helper()
+	response := server.handleMessage(MCPMessage{JSONRPC: "2.0", ID: 1, Method: method, Params: params})
+	if response == nil {
+		t.Fatalf("Expected a response to %s", method)
+	}
+
+	data, _ := json.Marshal(response)
+	var decoded MCPResponse
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("Failed to decode response %s: %v", data, err)
+	}
+	return decoded
+}
+
+func TestHandlePromptsList(t *testing.T) {
+	server, _ := newTestServer(t)
+
+	response := call(t, server, "prompts/list", nil)
+	if response.Error != nil {
+		t.Fatalf("Unexpected error: %s", response.Error.Message)
+	}
+
+	result := response.Result.(map[string]interface{})
+	prompts := result["prompts"].([]interface{})
+	if len(prompts) != 2 {
+		t.Fatalf("Expected 2 prompts, got %d", len(prompts))
+	}
+
+	names := map[string]bool{}
+	for _, p := range prompts {
+		prompt := p.(map[string]interface{})
+		names[prompt["name"].(string)] = true
+		if len(prompt["arguments"].([]interface{})) == 0 {
+			t.Errorf("Expected arguments for prompt %s", prompt["name"])
+		}
+	}
+	if !names["start_adventure"] || !names["summarize_session"] {
+		t.Errorf("Expected start_adventure and summarize_session, got %v", names)
+	}
+}
+
+func TestHandlePromptsGet(t *testing.T) {
+	server, _ := newTestServer(t)
+	sessionID, err := server.contextMgr.CreateSession("player123", "TestPlayer")
+	if err != nil {
+		t.Fatalf("Failed to create session: %v", err)
+	}
+
+	response := call(t, server, "prompts/get", map[string]interface{}{
+		"name":      "start_adventure",
+		"arguments": map[string]interface{}{"sessionID": sessionID, "tone": "mysterious"},
+	})
+	if response.Error != nil {
+		t.Fatalf("Unexpected error: %s", response.Error.Message)
+	}
+
+	result := response.Result.(map[string]interface{})
+	messages := result["messages"].([]interface{})
+	if len(messages) != 1 {
+		t.Fatalf("Expected 1 message, got %d", len(messages))
+	}
+	content := messages[0].(map[string]interface{})["content"].(map[string]interface{})
+	text := content["text"].(string)
+	if !strings.Contains(text, "mysterious") || !strings.Contains(text, "starting_village") {
+		t.Errorf("Expected prompt filled from the session, got: %s", text)
+	}
+
+	response = call(t, server, "prompts/get", map[string]interface{}{
+		"name":      "summarize_session",
+		"arguments": map[string]interface{}{"sessionID": sessionID},
+	})
+	if response.Error != nil {
+		t.Fatalf("Unexpected error: %s", response.Error.Message)
+	}
+}
+
+func TestHandlePromptsGet_Errors(t *testing.T) {
+	server, _ := newTestServer(t)
+
+	tests := []struct {
+		name   string
+		params interface{}
+	}{
+		{"missing params", nil},
+		{"unknown prompt", map[string]interface{}{"name": "cast_fireball", "arguments": map[string]interface{}{"sessionID": "x"}}},
+		{"missing session", map[string]interface{}{"name": "start_adventure"}},
+	}
+
+	for _, tt := range tests {
+		response := call(t, server, "prompts/get", tt.params)
+		if response.Error == nil || response.Error.Code != -32602 {
+			t.Errorf("%s: expected invalid params error, got %+v", tt.name, response.Error)
+		}
+	}
+}
+
+func TestToolDeleteSession(t *testing.T) {
+	server, _ := newTestServer(t)
+	sessionID, _ := server.contextMgr.CreateSession("player123", "TestPlayer")
+
+	response := call(t, server, "tools/call", map[string]interface{}{
+		"name":      "delete_session",
+		"arguments": map[string]interface{}{"sessionID": sessionID},
+	})
+	if response.Error != nil {
+		t.Fatalf("Unexpected error: %s", response.Error.Message)
+	}
+	if server.contextMgr.IsSessionActive(sessionID) {
+		t.Error("Expected session to be inactive after delete_session")
+	}
+
+	response = call(t, server, "tools/call", map[string]interface{}{
+		"name":      "delete_session",
+		"arguments": map[string]interface{}{"sessionID": sessionID},
+	})
+	if response.Error == nil || !strings.Contains(response.Error.Message, "not found") {
+		t.Errorf("Expected not found error, got %+v", response.Error)
+	}
+}
+
+func TestListActiveSessionsTool(t *testing.T) {
+	server, _ := newTestServer(t)
+	server.contextMgr.CreateSession("player1", "Aragorn")
+	rangerID, _ := server.contextMgr.CreateSession("player2", "Legolas")
+	server.contextMgr.UpdateLocation(rangerID, "forest")
+
+	listing := callTool(t, server, "list_active_sessions", map[string]interface{}{"location": "forest"})
+	if !strings.Contains(listing, "1 matching") || !strings.Contains(listing, rangerID+" - Legolas") || strings.Contains(listing, "Aragorn") {
+		t.Errorf("Expected only Legolas in the forest, got:\n%s", listing)
+	}
+
+	listing = callTool(t, server, "list_active_sessions", map[string]interface{}{"page": 2, "pageSize": 1})
+	if !strings.Contains(listing, "page 2, 2 matching") || strings.Count(listing, "\n- ") != 1 {
+		t.Errorf("Expected one session on the second page, got:\n%s", listing)
+	}
+
+	response := call(t, server, "tools/call", map[string]interface{}{
+		"name":      "list_active_sessions",
+		"arguments": map[string]interface{}{"pageSize": 500},
+	})
+	if response.Error == nil || !strings.Contains(response.Error.Message, "page size") {
+		t.Errorf("Expected an oversized page to be rejected, got %+v", response.Error)
+	}
+}
+
+func TestToolCall_ValidatesArgumentsAgainstSchema(t *testing.T) {
+	server, _ := newTestServer(t)
+	sessionID, _ := server.contextMgr.CreateSession("player123", "TestPlayer")
+
+	tests := []struct {
+		name      string
+		arguments map[string]interface{}
+		want      string
+	}{
+		{
+			name:      "missing npcID",
+			arguments: map[string]interface{}{"sessionID": sessionID, "npcName": "Grom"},
+			want:      `Invalid params: missing required argument "npcID"`,
+		},
+		{
+			name:      "dispositionChange as a string",
+			arguments: map[string]interface{}{"sessionID": sessionID, "npcID": "grom", "npcName": "Grom", "dispositionChange": "10"},
+			want:      `Invalid params: argument "dispositionChange" must be an integer, got string`,
+		},
+		{
+			name:      "fractional dispositionChange",
+			arguments: map[string]interface{}{"sessionID": sessionID, "npcID": "grom", "npcName": "Grom", "dispositionChange": 2.5},
+			want:      `Invalid params: argument "dispositionChange" must be an integer, got number`,
+		},
+		{
+			name:      "non-string fact",
+			arguments: map[string]interface{}{"sessionID": sessionID, "npcID": "grom", "npcName": "Grom", "facts": []interface{}{"likes ale", 7}},
+			want:      `Invalid params: argument "facts" item 1 must be a string, got integer`,
+		},
+	}
+
+	for _, tt := range tests {
+		response := call(t, server, "tools/call", map[string]interface{}{
+			"name":      "update_npc_relationship",
+			"arguments": tt.arguments,
+		})
+		if response.Error == nil || response.Error.Code != -32602 {
+			t.Errorf("%s: expected invalid params error, got %+v", tt.name, response.Error)
+			continue
+		}
+		if response.Error.Message != tt.want {
+			t.Errorf("%s: expected message %q, got %q", tt.name, tt.want, response.Error.Message)
+		}
+	}
+
+	response := call(t, server, "tools/call", map[string]interface{}{
+		"name":      "update_npc_relationship",
+		"arguments": map[string]interface{}{"sessionID": sessionID, "npcID": "grom", "npcName": "Grom", "dispositionChange": 10},
+	})
+	if response.Error != nil {
+		t.Errorf("Expected valid arguments to be accepted, got %s", response.Error.Message)
+	}
+}
+
+// callTool calls a tool through tools/call and returns its text result
+func callTool(t *testing.T, server *AIRPGMCPServer, name string, arguments map[string]interface{}) string {
+	t.Helper()
+	response := call(t, server, "tools/call", map[string]interface{}{"name": name, "arguments": arguments})
+	if response.Error != nil {
+		t.Fatalf("%s failed: %s", name, response.Error.Message)
+	}
+
+	data, _ := json.Marshal(response.Result)
+	var result MCPToolResult
+	if err := json.Unmarshal(data, &result); err != nil || len(result.Content) == 0 {
+		t.Fatalf("Unexpected %s result: %s", name, data)
+	}
+	return result.Content[0].Text
+}
+
+func TestInventoryTools(t *testing.T) {
+	server, _ := newTestServer(t)
+	sessionID, _ := server.contextMgr.CreateSession("player123", "TestPlayer")
+
+	callTool(t, server, "add_item", map[string]interface{}{
+		"sessionID": sessionID,
+		"itemID":    "healing_potion",
+		"name":      "Healing Potion",
+		"type":      "potion",
+		"quantity":  3,
+	})
+
+	inventory := callTool(t, server, "get_inventory", map[string]interface{}{"sessionID": sessionID})
+	if !strings.Contains(inventory, "Healing Potion x3 (ID: healing_potion) [potion]") {
+		t.Errorf("Expected the potion in the inventory, got:\n%s", inventory)
+	}
+
+	callTool(t, server, "remove_item", map[string]interface{}{"sessionID": sessionID, "itemID": "healing_potion"})
+	inventory = callTool(t, server, "get_inventory", map[string]interface{}{"sessionID": sessionID})
+	if !strings.Contains(inventory, "Healing Potion x2") {
+		t.Errorf("Expected one potion to be removed, got:\n%s", inventory)
+	}
+
+	response := call(t, server, "tools/call", map[string]interface{}{
+		"name":      "remove_item",
+		"arguments": map[string]interface{}{"sessionID": sessionID, "itemID": "dragon_egg"},
+	})
+	if response.Error == nil || !strings.Contains(response.Error.Message, "not in inventory") {
+		t.Errorf("Expected an error removing a missing item, got %+v", response.Error)
+	}
+}
+
+func TestQuestTools(t *testing.T) {
+	server, _ := newTestServer(t)
+	sessionID, _ := server.contextMgr.CreateSession("player123", "TestPlayer")
+
+	callTool(t, server, "start_quest", map[string]interface{}{
+		"sessionID":  sessionID,
+		"questID":    "rats",
+		"title":      "Rats in the Cellar",
+		"objectives": []interface{}{"Find the cellar", "Clear out the rats"},
+	})
+
+	quests := callTool(t, server, "get_quests", map[string]interface{}{"sessionID": sessionID})
+	for _, want := range []string{"Rats in the Cellar (ID: rats)", "[ ] Find the cellar", "[ ] Clear out the rats"} {
+		if !strings.Contains(quests, want) {
+			t.Errorf("Expected %q in quests, got:\n%s", want, quests)
+		}
+	}
+}
+
+func TestAttributeCheckTool(t *testing.T) {
+	server, _ := newTestServer(t)
+	server.contextMgr.SetDiceSource(rand.NewSource(1))
+	sessionID, _ := server.contextMgr.CreateSession("player123", "TestPlayer")
+
+	// With seed 1 the d20 comes up 2, and an average attribute adds nothing
+	result := callTool(t, server, "attribute_check", map[string]interface{}{
+		"sessionID":  sessionID,
+		"attribute":  "dexterity",
+		"difficulty": 12,
+	})
+	if !strings.Contains(result, "rolled 2, total 2. FAILURE") {
+		t.Errorf("Expected a failed check, got:\n%s", result)
+	}
+
+	response := call(t, server, "tools/call", map[string]interface{}{
+		"name":      "attribute_check",
+		"arguments": map[string]interface{}{"sessionID": sessionID, "attribute": "luck", "difficulty": 10},
+	})
+	if response.Error == nil {
+		t.Error("Expected an error for an unknown attribute")
+	}
+}
+
+func TestToolCall_AIToolCooldown(t *testing.T) {
+	server, _ := newTestServer(t)
+	server.cooldowns = newToolCooldowns(map[string]time.Duration{"generate_ai_response": time.Minute})
+	first, _ := server.contextMgr.CreateSession("player1", "Aria")
+	second, _ := server.contextMgr.CreateSession("player2", "Borin")
+
+	generate := func(sessionID string) MCPResponse {
+		return call(t, server, "tools/call", map[string]interface{}{
+			"name":      "generate_ai_response",
+			"arguments": map[string]interface{}{"sessionID": sessionID, "playerAction": "/look"},
+		})
+	}
+
+	if response := generate(first); response.Error != nil {
+		t.Fatalf("Expected the first call to pass, got %s", response.Error.Message)
+	}
+
+	response := generate(first)
+	if response.Error == nil || response.Error.Code != -32000 {
+		t.Fatalf("Expected the second call to be throttled with -32000, got %+v", response.Error)
+	}
+	if !strings.Contains(response.Error.Message, "try again in 60s") {
+		t.Errorf("Expected a retry hint, got %q", response.Error.Message)
+	}
+
+	if response := generate(second); response.Error != nil {
+		t.Errorf("Expected another session not to be throttled, got %s", response.Error.Message)
+	}
+
+	// Tools without a cooldown are never throttled
+	for i := 0; i < 2; i++ {
+		if response := call(t, server, "tools/call", map[string]interface{}{
+			"name":      "get_session_status",
+			"arguments": map[string]interface{}{"sessionID": first},
+		}); response.Error != nil {
+			t.Errorf("Expected get_session_status to pass, got %s", response.Error.Message)
+		}
+	}
+}
+
+func TestServe_Batch(t *testing.T) {
+	server, out := newTestServer(t)
+
+	batch := `[` +
+		`{"jsonrpc": "2.0", "id": 1, "method": "tools/call", "params": {"name": "create_session", "arguments": {"playerID": "p1", "playerName": "Aragorn"}}},` +
+		`{"jsonrpc": "2.0", "method": "notifications/initialized"},` +
+		`{"jsonrpc": "2.0", "id": "two", "method": "tools/call", "params": {"name": "list_active_sessions"}}` +
+		`]`
+	server.serve(strings.NewReader(batch + "\n"))
+
+	var responses []MCPResponse
+	if err := json.Unmarshal(out.Bytes(), &responses); err != nil {
+		t.Fatalf("Expected a JSON array of responses, got %q: %v", out.String(), err)
+	}
+
+	if len(responses) != 2 {
+		t.Fatalf("Expected 2 responses, got %d", len(responses))
+	}
+	if responses[0].ID != float64(1) || responses[1].ID != "two" {
+		t.Errorf("Expected responses in request order, got ids %v and %v", responses[0].ID, responses[1].ID)
+	}
+	for _, response := range responses {
+		if response.Error != nil {
+			t.Errorf("Unexpected error for id %v: %s", response.ID, response.Error.Message)
+		}
+	}
+}
+
+func TestServe_Notifications(t *testing.T) {
+	server, out := newTestServer(t)
+
+	// Neither a lone notification nor a batch of them gets a reply
+	server.serve(strings.NewReader(
+		`{"jsonrpc": "2.0", "method": "notifications/initialized"}` + "\n" +
+			`[{"jsonrpc": "2.0", "method": "notifications/initialized"}]` + "\n",
+	))
+	if out.Len() != 0 {
+		t.Errorf("Expected no output for notifications, got %q", out.String())
+	}
+
+	// Requests with an id still get one
+	server.serve(strings.NewReader(`{"jsonrpc": "2.0", "id": 7, "method": "tools/list"}` + "\n"))
+	var response MCPResponse
+	if err := json.Unmarshal(out.Bytes(), &response); err != nil || response.ID != float64(7) {
+		t.Errorf("Expected a response with id 7, got %q", out.String())
+	}
+}
+
+func TestServe_InvalidBatch(t *testing.T) {
+	server, out := newTestServer(t)
+
+	server.serve(strings.NewReader("[]\n"))
+	var response MCPResponse
+	if err := json.Unmarshal(out.Bytes(), &response); err != nil || response.Error == nil || response.Error.Code != -32600 {
+		t.Errorf("Expected invalid request error for an empty batch, got %q", out.String())
+	}
+
+	out.Reset()
+	server.serve(strings.NewReader("[1, 2]\n"))
+	var responses []MCPResponse
+	if err := json.Unmarshal(out.Bytes(), &responses); err != nil || len(responses) != 2 {
+		t.Fatalf("Expected 2 error responses, got %q", out.String())
+	}
+	if responses[0].Error == nil || responses[0].Error.Code != -32600 {
+		t.Errorf("Expected invalid request error, got %+v", responses[0].Error)
+	}
+}
+
+func TestApplyActionConsequences_ReputationFromRecordedActionOnly(t *testing.T) {
+	server, _ := newTestServer(t)
+	sessionID, _ := server.contextMgr.CreateSession("p1", "Aragorn")
+
+	command, target := "/attack goblin", "goblin"
+	parsed := gamecommand.Parse(command)
+	actionType, consequences := parsed.ActionType, parsed.Consequences
+	server.contextMgr.RecordAction(sessionID, command, actionType, target, "starting_village", "The goblin falls.", consequences)
+	server.applyActionConsequences(sessionID, command, target, consequences)
+	server.contextMgr.WaitForEvents()
+
+	ctx, _ := server.contextMgr.GetContext(sessionID)
+	if ctx.Character.Reputation != 5 {
+		t.Errorf("Expected exactly the reputation_increase consequence (+5), got %d", ctx.Character.Reputation)
+	}
+}
+
+func TestToolExecuteAction_Cancelled(t *testing.T) {
+	server, _ := newTestServer(t)
+	aiService, err := ai.NewAIService(ai.AIConfig{Provider: "ollama", BaseURL: "http://127.0.0.1:1/api/chat"})
+	if err != nil {
+		t.Fatalf("Failed to create AI service: %v", err)
+	}
+	server.aiService = aiService
+
+	ctx, cancel := stdcontext.WithCancel(stdcontext.Background())
+	cancel()
+	server.ctx = ctx
+
+	sessionID, _ := server.contextMgr.CreateSession("p1", "Aragorn")
+	_, err = server.executeToolCall(server.requestContext(), "execute_action", map[string]interface{}{
+		"sessionID": sessionID,
+		"command":   "/look around",
+	}, nil)
+	if !errors.Is(err, stdcontext.Canceled) {
+		t.Fatalf("Expected the cancelled call to fail with context.Canceled, got %v", err)
+	}
+
+	// The action is recorded with the fallback narration
+	server.contextMgr.WaitForEvents()
+	if actions, _ := server.contextMgr.GetRecentActions(sessionID, 10); len(actions) != 1 {
+		t.Errorf("Expected the cancelled call's action to be recorded, got %d actions", len(actions))
+	}
+}
+
+func TestToolExecuteAction_ConcurrentRetry(t *testing.T) {
+	server, _ := newTestServer(t)
+	arrived, release := useStubAI(t, server, "You swing at the goblin.").Hold(t)
+
+	sessionID, _ := server.contextMgr.CreateSession("p1", "Aragorn")
+	args := map[string]interface{}{"sessionID": sessionID, "command": "/attack goblin", "idempotencyKey": "turn-1"}
+
+	first := make(chan error)
+	go func() {
+		_, err := server.executeToolCall(stdcontext.Background(), "execute_action", args, nil)
+		first <- err
+	}()
+	<-arrived
+
+	// The retry arrives while the first call waits on the GM
+	result, err := server.executeToolCall(stdcontext.Background(), "execute_action", args, nil)
+	if err != nil {
+		t.Fatalf("Retry failed: %v", err)
+	}
+	if text := result.Content[0].Text; !strings.Contains(text, "already applied") {
+		t.Errorf("Expected the retry to be answered as already applied, got %q", text)
+	}
+
+	release()
+	if err := <-first; err != nil {
+		t.Fatalf("execute_action failed: %v", err)
+	}
+	server.contextMgr.WaitForEvents()
+	if actions, _ := server.contextMgr.GetRecentActions(sessionID, 10); len(actions) != 1 {
+		t.Errorf("Expected the attack to be recorded once, got %d actions", len(actions))
+	}
+}
+
+func TestToolExecuteAction_RetryAfterCancellation(t *testing.T) {
+	server, _ := newTestServer(t)
+	useStubAI(t, server, "You swing at the goblin.")
+
+	sessionID, _ := server.contextMgr.CreateSession("p1", "Aragorn")
+	args := map[string]interface{}{"sessionID": sessionID, "command": "/attack goblin", "idempotencyKey": "turn-1"}
+
+	ctx, cancel := stdcontext.WithCancel(stdcontext.Background())
+	cancel()
+	if _, err := server.executeToolCall(ctx, "execute_action", args, nil); !errors.Is(err, stdcontext.Canceled) {
+		t.Fatalf("Expected the cancelled call to fail with context.Canceled, got %v", err)
+	}
+	server.contextMgr.WaitForEvents()
+	attacked, _ := server.contextMgr.GetContext(sessionID)
+
+	// The attack was already rolled; the retry must not roll it again
+	result, err := server.executeToolCall(stdcontext.Background(), "execute_action", args, nil)
+	if err != nil {
+		t.Fatalf("Retry failed: %v", err)
+	}
+	if text := result.Content[0].Text; !strings.Contains(text, "already applied") {
+		t.Errorf("Expected the retry to be answered as already applied, got %q", text)
+	}
+	server.contextMgr.WaitForEvents()
+	if ctx, _ := server.contextMgr.GetContext(sessionID); ctx.Version != attacked.Version {
+		t.Errorf("Expected the retry to change nothing, version went from %d to %d", attacked.Version, ctx.Version)
+	}
+	if actions, _ := server.contextMgr.GetRecentActions(sessionID, 10); len(actions) != 1 {
+		t.Errorf("Expected the attack to be recorded once, got %d actions", len(actions))
+	}
+}
+
+// redirectStdio points os.Stdout and os.Stderr at files for the rest of the
+// test, returning readers for what was written to each
+func redirectStdio(t *testing.T) (stdout, stderr func() string) {
+	t.Helper()
+	dir := t.TempDir()
+	capture := func(target **os.File, name string) func() string {
+		file, err := os.Create(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatalf("Failed to create %s: %v", name, err)
+		}
+		original := *target
+		*target = file
+		t.Cleanup(func() {
+			*target = original
+			file.Close()
+		})
+		return func() string {
+			data, _ := os.ReadFile(file.Name())
+			return string(data)
+		}
+	}
+	return capture(&os.Stdout, "stdout"), capture(&os.Stderr, "stderr")
+}
+
+func TestLogging_StaysOffStdout(t *testing.T) {
+	stdout, stderr := redirectStdio(t)
+
+	logger, closer, err := newLogger(config.LoggingConfig{Level: "debug", Format: "json", Output: "stdout"})
+	if err != nil {
+		t.Fatalf("newLogger failed: %v", err)
+	}
+	defer closer.Close()
+
+	server, _ := newTestServer(t)
+	server.out = nil // respond on stdout, as in production
+	server.logger = logger
+	server.serve(strings.NewReader(`{"jsonrpc": "2.0", "id": 1, "method": "tools/list"}` + "\n"))
+
+	lines := strings.Split(strings.TrimSpace(stdout()), "\n")
+	if len(lines) != 1 {
+		t.Fatalf("Expected only the JSON-RPC response on stdout, got %d lines", len(lines))
+	}
+	var response MCPResponse
+	if err := json.Unmarshal([]byte(lines[0]), &response); err != nil || response.JSONRPC != "2.0" {
+		t.Errorf("Expected a JSON-RPC response on stdout, got %q", lines[0])
+	}
+
+	if !strings.Contains(stderr(), `"msg":"Handling method"`) {
+		t.Errorf("Expected debug logs on stderr, got %q", stderr())
+	}
+}
+
+func TestServe_OnlyJSONRPCOnStdout(t *testing.T) {
+	stdout, stderr := redirectStdio(t)
+
+	server, _ := newTestServer(t)
+	server.out = claimStdout()
+
+	logger, closer, err := newLogger(config.LoggingConfig{Level: "debug", Format: "text"})
+	if err != nil {
+		t.Fatalf("newLogger failed: %v", err)
+	}
+	defer closer.Close()
+	server.logger = logger
+
+	fmt.Println("stray diagnostic output")
+	server.serve(strings.NewReader(strings.Join([]string{
+		`{"jsonrpc": "2.0", "id": 1, "method": "initialize", "params": {"protocolVersion": "2024-11-05"}}`,
+		`{"jsonrpc": "2.0", "method": "notifications/initialized"}`,
+		`{"jsonrpc": "2.0", "id": 2, "method": "tools/list"}`,
+	}, "\n") + "\n"))
+
+	lines := strings.Split(strings.TrimSpace(stdout()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("Expected 2 responses on stdout, got %d: %q", len(lines), stdout())
+	}
+	for i, line := range lines {
+		var response MCPResponse
+		if err := json.Unmarshal([]byte(line), &response); err != nil {
+			t.Fatalf("Stdout line %d is not JSON: %q", i+1, line)
+		}
+		if response.JSONRPC != "2.0" || response.ID != float64(i+1) || response.Error != nil {
+			t.Errorf("Stdout line %d is not a successful JSON-RPC response: %q", i+1, line)
+		}
+	}
+
+	if !strings.Contains(stderr(), "stray diagnostic output") {
+		t.Error("Expected stray prints to be diverted to stderr")
+	}
+}
+
+// useStubAI points the server's AI service at a stub answering with each
+// reply in turn
+func useStubAI(t *testing.T, server *AIRPGMCPServer, replies ...string) *aitest.Stub {
+	t.Helper()
+
+	aiService, stub := aitest.NewService(t, replies...)
+	server.aiService = aiService
+	return stub
+}
+
+func TestToolExecuteAction_ProgressNotifications(t *testing.T) {
+	for _, tt := range []struct {
+		name  string
+		meta  map[string]interface{}
+		token interface{}
+	}{
+		{name: "with token", meta: map[string]interface{}{"progressToken": "turn-1"}, token: "turn-1"},
+		{name: "without token"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			server, out := newTestServer(t)
+			sessionID, _ := server.contextMgr.CreateSession("p1", "Aragorn")
+
+			params := map[string]interface{}{
+				"name":      "execute_action",
+				"arguments": map[string]interface{}{"sessionID": sessionID, "command": "/look around"},
+			}
+			if tt.meta != nil {
+				params["_meta"] = tt.meta
+			}
+			if response := call(t, server, "tools/call", params); response.Error != nil {
+				t.Fatalf("execute_action failed: %s", response.Error.Message)
+			}
+
+			var notifications []MCPMessage
+			for _, line := range strings.Split(strings.TrimSpace(out.String()), "\n") {
+				if line == "" {
+					continue
+				}
+				var notification MCPMessage
+				if err := json.Unmarshal([]byte(line), &notification); err != nil {
+					t.Fatalf("Output line is not JSON: %q", line)
+				}
+				notifications = append(notifications, notification)
+			}
+
+			if tt.token == nil {
+				if len(notifications) != 0 {
+					t.Errorf("Expected no notifications without a progress token, got %d", len(notifications))
+				}
+				return
+			}
+			if len(notifications) != 2 {
+				t.Fatalf("Expected progress before and after the AI call, got %d notifications", len(notifications))
+			}
+			for _, notification := range notifications {
+				params, _ := notification.Params.(map[string]interface{})
+				if notification.Method != "notifications/progress" || notification.ID != nil || params["progressToken"] != tt.token {
+					t.Errorf("Unexpected notification: %+v", notification)
+				}
+			}
+		})
+	}
+}
+
+func TestRegenerateResponseTool(t *testing.T) {
+	server, _ := newTestServer(t)
+	useStubAI(t, server, "The square is quiet.", "Pigeons scatter across the busy square.")
+	sessionID, _ := server.contextMgr.CreateSession("p1", "Aragorn")
+
+	response := call(t, server, "tools/call", map[string]interface{}{
+		"name":      "regenerate_response",
+		"arguments": map[string]interface{}{"sessionID": sessionID},
+	})
+	if response.Error == nil || !strings.Contains(response.Error.Message, "no response to regenerate") {
+		t.Errorf("Expected regenerating before any action to fail cleanly, got %+v", response.Error)
+	}
+
+	callTool(t, server, "execute_action", map[string]interface{}{"sessionID": sessionID, "command": "/look around"})
+	text := callTool(t, server, "regenerate_response", map[string]interface{}{"sessionID": sessionID})
+	if !strings.Contains(text, "Pigeons scatter") {
+		t.Errorf("Expected a fresh response rather than the cached one, got %q", text)
+	}
+
+	actions, _ := server.contextMgr.GetRecentActions(sessionID, 10)
+	if len(actions) != 1 || actions[0].Outcome != "Pigeons scatter across the busy square." {
+		t.Errorf("Expected the action's outcome to be replaced, got %+v", actions)
+	}
+	history, _ := server.contextMgr.GetDialogueHistory(sessionID, 0)
+	if len(history) != 1 || history[0].Response != "Pigeons scatter across the busy square." {
+		t.Errorf("Expected the dialogue turn to be replaced, got %+v", history)
+	}
+}
+
+func TestToolsRejectUnknownSession(t *testing.T) {
+	server, _ := newTestServer(t)
+
+	tools := map[string]map[string]interface{}{
+		"get_session_status":  {"sessionID": "typo"},
+		"get_session_metrics": {"sessionID": "typo"},
+		"execute_action":      {"sessionID": "typo", "command": "/look"},
+	}
+	for name, arguments := range tools {
+		response := call(t, server, "tools/call", map[string]interface{}{"name": name, "arguments": arguments})
+		if response.Error == nil || !strings.Contains(response.Error.Message, "session not found") {
+			t.Errorf("Expected %s to reject an unknown session, got %+v", name, response.Error)
+		}
+	}
+
+	if server.contextMgr.IsSessionActive("typo") {
+		t.Error("Expected no session to be created for an unknown ID")
+	}
+}
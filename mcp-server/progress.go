@@ -0,0 +1,33 @@
+package main
+
+// progressFunc reports how far a long-running tool call has got
+type progressFunc func(progress, total float64, message string)
+
+// progressToken returns the token a client sent in a request's _meta to ask
+// for progress notifications, or nil
+func progressToken(params map[string]interface{}) interface{} {
+	meta, _ := params["_meta"].(map[string]interface{})
+	return meta["progressToken"]
+}
+
+// progressReporter returns a progressFunc that sends notifications/progress
+// for token. Without a token it does nothing, since the client didn't ask.
+// Notifications carry no id and expect no reply.
+func (s *AIRPGMCPServer) progressReporter(token interface{}) progressFunc {
+	if token == nil {
+		return func(float64, float64, string) {}
+	}
+
+	return func(progress, total float64, message string) {
+		s.sendMessage(MCPMessage{
+			JSONRPC: "2.0",
+			Method:  "notifications/progress",
+			Params: map[string]interface{}{
+				"progressToken": token,
+				"progress":      progress,
+				"total":         total,
+				"message":       message,
+			},
+		})
+	}
+}
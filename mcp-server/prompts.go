@@ -0,0 +1,165 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"ai-rpg-mvp/context"
+)
+
+// MCP Prompt Definitions
+type MCPPrompt struct {
+	Name        string              `json:"name"`
+	Description string              `json:"description"`
+	Arguments   []MCPPromptArgument `json:"arguments"`
+}
+
+type MCPPromptArgument struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Required    bool   `json:"required"`
+}
+
+type MCPPromptMessage struct {
+	Role    string     `json:"role"`
+	Content MCPContent `json:"content"`
+}
+
+type MCPPromptResult struct {
+	Description string             `json:"description"`
+	Messages    []MCPPromptMessage `json:"messages"`
+}
+
+// gamePrompts returns the prompt templates offered through prompts/list
+func gamePrompts() []MCPPrompt {
+	sessionArg := MCPPromptArgument{
+		Name:        "sessionID",
+		Description: "Session to build the prompt from",
+		Required:    true,
+	}
+
+	return []MCPPrompt{
+		{
+			Name:        "start_adventure",
+			Description: "Open a new scene for a player, grounded in their current location and standing",
+			Arguments: []MCPPromptArgument{
+				sessionArg,
+				{
+					Name:        "tone",
+					Description: "Mood of the opening scene, e.g. mysterious or lighthearted",
+				},
+			},
+		},
+		{
+			Name:        "summarize_session",
+			Description: "Recap what has happened so far in a player's session",
+			Arguments:   []MCPPromptArgument{sessionArg},
+		},
+	}
+}
+
+func (s *AIRPGMCPServer) handlePromptsGet(id interface{}, params interface{}) *MCPResponse {
+	paramsMap, ok := params.(map[string]interface{})
+	if !ok {
+		return newErrorResponse(id, -32602, "Invalid params")
+	}
+
+	name, ok := paramsMap["name"].(string)
+	if !ok {
+		return newErrorResponse(id, -32602, "Missing prompt name")
+	}
+
+	arguments, ok := paramsMap["arguments"].(map[string]interface{})
+	if !ok {
+		arguments = make(map[string]interface{})
+	}
+
+	result, err := s.buildPrompt(name, arguments)
+	if err != nil {
+		return newErrorResponse(id, -32602, err.Error())
+	}
+
+	s.log().Debug("Sending prompts/get response", "prompt", name)
+	return newResponse(id, result)
+}
+
+// buildPrompt fills the named prompt template from the session's context
+func (s *AIRPGMCPServer) buildPrompt(name string, args map[string]interface{}) (*MCPPromptResult, error) {
+	sessionID, ok := args["sessionID"].(string)
+	if !ok || sessionID == "" {
+		return nil, fmt.Errorf("sessionID is required")
+	}
+
+	summary, err := s.contextMgr.GetContextSummary(sessionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get context: %w", err)
+	}
+
+	var description, text string
+	switch name {
+	case "start_adventure":
+		tone, _ := args["tone"].(string)
+		if tone == "" {
+			tone = "adventurous"
+		}
+
+		description = "Opening scene for the player's adventure"
+		text = fmt.Sprintf(`You are the Game Master. Open a new %s scene for the player.
+
+The player is at %s (arrived from %s), with %s health and a reputation of %d (%s).
+
+NPCs nearby:
+%s
+
+Describe the surroundings, hint at something worth investigating, and end by asking the player what they do.`,
+			tone,
+			summary.CurrentLocation,
+			summary.PreviousLocation,
+			summary.PlayerHealth,
+			summary.PlayerReputation,
+			context.ReputationLabel(summary.PlayerReputation),
+			s.formatNPCs(summary.ActiveNPCs),
+		)
+	case "summarize_session":
+		recent := strings.Join(summary.RecentActions, "\n")
+		if recent == "" {
+			recent = "No actions yet"
+		}
+
+		description = "Recap of the player's session so far"
+		text = fmt.Sprintf(`You are the Game Master. Summarize this session for the player in a few vivid sentences, then remind them of any unfinished business.
+
+Session length: %.1f minutes
+Current location: %s
+Health: %s
+Reputation: %d (%s)
+Mood: %s
+
+Recent actions:
+%s
+
+NPCs met:
+%s`,
+			summary.SessionDuration,
+			summary.CurrentLocation,
+			summary.PlayerHealth,
+			summary.PlayerReputation,
+			context.ReputationLabel(summary.PlayerReputation),
+			summary.PlayerMood,
+			recent,
+			s.formatNPCs(summary.ActiveNPCs),
+		)
+	default:
+		return nil, fmt.Errorf("unknown prompt: %s", name)
+	}
+
+	return &MCPPromptResult{
+		Description: description,
+		Messages: []MCPPromptMessage{
+			{
+				Role:    "user",
+				Content: MCPContent{Type: "text", Text: text},
+			},
+		},
+	}, nil
+}
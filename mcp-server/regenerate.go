@@ -0,0 +1,22 @@
+package main
+
+import "fmt"
+
+// RegenerateLastResponse asks the AI again for its narration of the
+// session's most recent action, skipping the cached reply, and stores the
+// new one as that action's outcome
+func (s *AIRPGMCPServer) RegenerateLastResponse(sessionID string) (string, error) {
+	return s.contextMgr.RegenerateLastOutcome(sessionID, func(prompt string) (string, error) {
+		return s.aiService.RegenerateGMResponseForSessionCtx(s.requestContext(), sessionID, prompt)
+	})
+}
+
+func (s *AIRPGMCPServer) toolRegenerateResponse(args map[string]interface{}) (*MCPToolResult, error) {
+	sessionID := args["sessionID"].(string)
+
+	response, err := s.RegenerateLastResponse(sessionID)
+	if err != nil {
+		return nil, err
+	}
+	return textResult(fmt.Sprintf("GM Response: %s", response)), nil
+}
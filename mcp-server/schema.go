@@ -0,0 +1,108 @@
+package main
+
+import (
+	"fmt"
+	"math"
+)
+
+// invalidParamsError reports tool arguments that don't match the tool's input
+// schema; it is answered with JSON-RPC code -32602
+type invalidParamsError struct {
+	message string
+}
+
+func (e *invalidParamsError) Error() string {
+	return "Invalid params: " + e.message
+}
+
+// validateToolArguments checks args against the input schema the named tool
+// declares in tools/list. Unknown tools pass; executeToolCall reports them.
+func validateToolArguments(toolName string, args map[string]interface{}) error {
+	for _, tool := range gameTools() {
+		if tool.Name == toolName {
+			schema, _ := tool.InputSchema.(map[string]interface{})
+			return validateObject(schema, args)
+		}
+	}
+	return nil
+}
+
+// validateObject checks that every required property is present and every
+// declared property has its declared type. Undeclared properties are allowed.
+func validateObject(schema map[string]interface{}, args map[string]interface{}) error {
+	required, _ := schema["required"].([]string)
+	for _, name := range required {
+		if _, ok := args[name]; !ok {
+			return &invalidParamsError{fmt.Sprintf("missing required argument %q", name)}
+		}
+	}
+
+	properties, _ := schema["properties"].(map[string]interface{})
+	for name, value := range args {
+		property, ok := properties[name].(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if err := validateValue(fmt.Sprintf("argument %q", name), property, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// validateValue checks value against a property schema, naming it as label
+// in any error
+func validateValue(label string, property map[string]interface{}, value interface{}) error {
+	want, _ := property["type"].(string)
+	if want == "" {
+		return nil
+	}
+
+	if got := jsonType(value); got != want && !(want == "number" && got == "integer") {
+		return &invalidParamsError{fmt.Sprintf("%s must be %s %s, got %s", label, article(want), want, got)}
+	}
+
+	if items, ok := property["items"].(map[string]interface{}); ok {
+		for i, item := range value.([]interface{}) {
+			if err := validateValue(fmt.Sprintf("%s item %d", label, i), items, item); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// jsonType names the JSON Schema type of a decoded JSON value; whole numbers
+// are integers. Go integers are accepted for callers that skip decoding.
+func jsonType(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return "null"
+	case string:
+		return "string"
+	case bool:
+		return "boolean"
+	case int, int64:
+		return "integer"
+	case float64:
+		if v == math.Trunc(v) {
+			return "integer"
+		}
+		return "number"
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	default:
+		return fmt.Sprintf("%T", value)
+	}
+}
+
+// article returns the indefinite article for a type name
+func article(typeName string) string {
+	switch typeName[0] {
+	case 'a', 'e', 'i', 'o', 'u':
+		return "an"
+	}
+	return "a"
+}